@@ -1,17 +1,62 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"expvar"
 	"fmt"
+	"html/template"
 	"log"
+	nethttp "net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // statistics take time zones, which slim images lack
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx SQL driver
+	"github.com/prayushdave/url-shortener/internal/accesslog"
+	"github.com/prayushdave/url-shortener/internal/alert"
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/archive"
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/captcha"
+	"github.com/prayushdave/url-shortener/internal/chain"
+	"github.com/prayushdave/url-shortener/internal/clickhouse"
+	"github.com/prayushdave/url-shortener/internal/domain"
+	"github.com/prayushdave/url-shortener/internal/health"
 	"github.com/prayushdave/url-shortener/internal/http"
 	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/jobs"
+	"github.com/prayushdave/url-shortener/internal/kgs"
+	"github.com/prayushdave/url-shortener/internal/lock"
+	"github.com/prayushdave/url-shortener/internal/mail"
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/mirror"
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/purge"
+	"github.com/prayushdave/url-shortener/internal/queue"
+	"github.com/prayushdave/url-shortener/internal/standby"
 	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/upgrade"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+	"github.com/prayushdave/url-shortener/web"
+	_ "modernc.org/sqlite" // registers the sqlite SQL driver
 )
 
+// pipeline is an asynchronous subsystem drained on shutdown
+type pipeline struct {
+	name     string
+	shutdown func(ctx context.Context) error
+}
+
 func main() {
 	// Get configuration from environment variables
 	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
@@ -20,33 +65,890 @@ func main() {
 	serverPort := getEnv("SERVER_PORT", "8080")
 	baseURL := getEnv("BASE_URL", fmt.Sprintf("http://localhost:%s", serverPort))
 
+	// Refuse to start with features keeping state in this process alone,
+	// so any number of replicas can run behind a load balancer
+	stateless := getEnvBool("STATELESS", false)
+	if features := statefulFeatures(); stateless && len(features) > 0 {
+		log.Fatalf("STATELESS forbids instance-local state:\n  %s", strings.Join(features, "\n  "))
+	}
+
+	// Serve links under a path prefix or on a host of their own, out of the
+	// way of the server's other top-level paths
+	redirectPrefix, err := http.ParseRedirectPrefix(getEnv("REDIRECT_PREFIX", ""))
+	if err != nil {
+		log.Fatalf("Invalid REDIRECT_PREFIX: %v", err)
+	}
+	redirects := http.RedirectRoutes{
+		Prefix: redirectPrefix,
+		Host:   strings.ToLower(getEnv("REDIRECT_HOST", "")),
+		Legacy: getEnvBool("LEGACY_REDIRECTS", true),
+	}
+	if u, err := url.Parse(baseURL); redirects.Host != "" && (err != nil || strings.EqualFold(u.Hostname(), redirects.Host)) {
+		log.Fatalf("REDIRECT_HOST must differ from the host of BASE_URL")
+	}
+
 	// Initialize Redis store
-	store := storage.NewRedisStore(redisAddr, redisPassword, redisDB)
+	store := storage.NewRedisStoreWithOptions(redisAddr, redisPassword, redisDB, storage.RedisOptions{
+		PoolSize:        getEnvInt("REDIS_POOL_SIZE", 0),
+		MinIdleConns:    getEnvInt("REDIS_MIN_IDLE_CONNS", 0),
+		DialTimeout:     getEnvDuration("REDIS_DIAL_TIMEOUT", 0),
+		ReadTimeout:     getEnvDuration("REDIS_READ_TIMEOUT", 0),
+		WriteTimeout:    getEnvDuration("REDIS_WRITE_TIMEOUT", 0),
+		PoolTimeout:     getEnvDuration("REDIS_POOL_TIMEOUT", 0),
+		MaxRetries:      getEnvInt("REDIS_MAX_RETRIES", 0),
+		MinRetryBackoff: getEnvDuration("REDIS_MIN_RETRY_BACKOFF", 0),
+		MaxRetryBackoff: getEnvDuration("REDIS_MAX_RETRY_BACKOFF", 0),
+	})
 	defer store.Close()
 
-	// Initialize ID generator
-	generator := id.NewGenerator()
+	// Serve hot records from memory to cut Redis round trips
+	store.EnableCache(getEnvInt("CACHE_SIZE", 0), getEnvDuration("CACHE_TTL", storage.DefaultCacheTTL))
+
+	// Remember unknown keys briefly so scanners don't hit Redis every time
+	store.EnableNegativeCache(getEnvInt("NEGATIVE_CACHE_SIZE", 0), getEnvDuration("NEGATIVE_CACHE_TTL", storage.DefaultNegativeCacheTTL))
+
+	// Choose whether reads extend link lifetimes, and take that bookkeeping
+	// off the redirect path
+	expiryPolicy, err := storage.ParseExpiryPolicy(getEnv("EXPIRY_POLICY", string(storage.ExpirySliding)))
+	if err != nil {
+		log.Fatalf("Invalid EXPIRY_POLICY: %v", err)
+	}
+	store.SetExpiryPolicy(expiryPolicy)
+	store.SetTTL(getEnvDuration("LINK_TTL", storage.DefaultTTL))
+	if getEnvBool("ASYNC_ACCESS", false) {
+		store.EnableAsyncAccess(getEnvInt("ACCESS_QUEUE_SIZE", storage.DefaultAccessQueueSize))
+	}
+
+	// Keep links in a SQL database, using Redis as a cache in front of it.
+	// The pgx and sqlite drivers are linked in; others need a blank import.
+	var links storage.Store = store
+	var previews storage.PreviewStore = store
+	var bulk storage.BulkStore = store
+	var exporter storage.ExportStore = store
+	var keys storage.KeyChecker = store
+	var checks storage.LinkCheckStore = store
+	if driver := getEnv("SQL_DRIVER", ""); driver != "" {
+		db, err := sql.Open(driver, getEnv("SQL_DSN", ""))
+		if err != nil {
+			log.Fatalf("Failed to open SQL database: %v", err)
+		}
+		durable := storage.NewSQLStore(db, driver)
+		defer durable.Close()
+		if err := durable.Migrate(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate SQL database: %v", err)
+		}
+		tiered := storage.NewTieredStore(durable, store)
+		links, previews, bulk, exporter, keys, checks = tiered, tiered, tiered, tiered, tiered, tiered
+	}
+
+	// Background jobs stop and the server shuts down on SIGINT or SIGTERM
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Maintenance jobs run on one instance at a time, under locks that
+	// outlive a dead holder by JOB_LOCK_TTL
+	lockTTL := getEnvDuration("JOB_LOCK_TTL", lock.DefaultTTL)
+
+	// Run background jobs on their schedules, which JOB_SCHEDULES
+	// overrides by job name, keeping their run history in Redis
+	schedules, err := jobs.ParseSchedules(getEnv("JOB_SCHEDULES", ""))
+	if err != nil {
+		log.Fatalf("Invalid JOB_SCHEDULES: %v", err)
+	}
+	scheduler := jobs.NewScheduler(store)
+	registerJob := func(name string, schedule jobs.Schedule, fn jobs.Func, l *lock.Lock) {
+		if s, ok := schedules[name]; ok {
+			schedule = s
+			delete(schedules, name)
+		}
+		if err := scheduler.Register(name, schedule, fn, l); err != nil {
+			log.Fatalf("Failed to register job %s: %v", name, err)
+		}
+	}
+
+	// Serve redirects from a read replica in this region while writes go
+	// to the primary, falling back to the primary while the replica lags
+	if replicaAddr := getEnv("REDIS_REPLICA_ADDR", ""); replicaAddr != "" {
+		store.EnableReplica(replicaAddr, getEnv("REDIS_REPLICA_PASSWORD", redisPassword), redisDB, storage.ReplicaOptions{
+			MaxLag:    getEnvDuration("REPLICA_MAX_LAG", storage.DefaultReplicaMaxLag),
+			Heartbeat: getEnvDuration("REPLICA_HEARTBEAT", storage.DefaultReplicaHeartbeat),
+		})
+		go store.MonitorReplica(ctx)
+	}
+
+	var opts []http.Option
+
+	// Fail fast while the store keeps failing, serving redirects from the
+	// records last read instead of piling up timeouts
+	if getEnvBool("CIRCUIT_BREAKER", false) {
+		breaker := storage.NewBreakerStore(links, storage.BreakerOptions{
+			Failures:  getEnvInt("BREAKER_FAILURES", storage.DefaultBreakerFailures),
+			Cooldown:  getEnvDuration("BREAKER_COOLDOWN", storage.DefaultBreakerCooldown),
+			Retries:   getEnvInt("BREAKER_RETRIES", storage.DefaultBreakerRetries),
+			Backoff:   getEnvDuration("BREAKER_BACKOFF", storage.DefaultBreakerBackoff),
+			StaleSize: getEnvInt("STALE_CACHE_SIZE", storage.DefaultStaleSize),
+			StaleTTL:  getEnvDuration("STALE_CACHE_TTL", storage.DefaultStaleTTL),
+		})
+		links = breaker
+		opts = append(opts, http.WithCircuitBreaker(breaker))
+	}
+
+	// Initialize ID generator. Snowflake keys embed a worker ID, claimed
+	// from Redis unless WORKER_ID pins it.
+	caseInsensitive := getEnvBool("CASE_INSENSITIVE_KEYS", false)
+	alphabet, err := id.ParseAlphabet(getEnv("KEY_ALPHABET", string(id.AlphabetDefault)))
+	if err != nil {
+		log.Fatalf("Invalid KEY_ALPHABET: %v", err)
+	}
+	var generator id.KeyGenerator
+	switch mode := getEnv("KEY_GENERATOR", "random"); mode {
+	case "random":
+		random := id.NewGenerator()
+		if caseInsensitive {
+			random = id.NewCaseInsensitiveGenerator()
+			opts = append(opts, http.WithCaseInsensitiveKeys())
+		}
+		random.UseAlphabet(alphabet)
+		generator = random
+	case "snowflake":
+		if caseInsensitive {
+			log.Fatalf("CASE_INSENSITIVE_KEYS requires KEY_GENERATOR=random")
+		}
+		workerID := getEnvInt("WORKER_ID", -1)
+		if workerID < 0 {
+			lease, err := store.ClaimWorkerID(ctx, id.MaxWorkerID, getEnvDuration("WORKER_LEASE_TTL", storage.DefaultWorkerLeaseTTL))
+			if err != nil {
+				log.Fatalf("Failed to claim worker ID: %v", err)
+			}
+			go lease.Run(ctx)
+			workerID = lease.ID
+		}
+		snowflake, err := id.NewSnowflakeGenerator(workerID)
+		if err != nil {
+			log.Fatalf("Invalid WORKER_ID: %v", err)
+		}
+		snowflake.UseAlphabet(alphabet)
+		generator = snowflake
+	default:
+		log.Fatalf("Invalid KEY_GENERATOR: %q", mode)
+	}
+
+	// Issue keys from a pool pre-generated in Redis, sparing creations the
+	// retries on key collisions
+	if size := getEnvInt("KEY_POOL_SIZE", 0); size > 0 {
+		pool := kgs.New(store, generator, size, getEnvDuration("KEY_POOL_REFILL_INTERVAL", kgs.DefaultRefillInterval))
+		go pool.Run(ctx)
+		generator = pool
+	}
+	pipelines := []pipeline{{storage.AccessQueueName, store.ShutdownAccess}}
+
+	// Configure warm standby export of hot keys
+	standbyPath := getEnv("STANDBY_PATH", "")
+	standbyRedisAddr := getEnv("STANDBY_REDIS_ADDR", "")
+	if standbyPath != "" {
+		// Serve redirects from the latest snapshot if the primary store
+		// fails, picking up each one the exporter writes
+		snapshot := standby.NewStore(standbyPath)
+		if err := snapshot.Reload(); err == nil {
+			log.Printf("Loaded standby snapshot with %d keys from %s", snapshot.Len(), snapshot.CreatedAt())
+		}
+		go snapshot.Watch(ctx, getEnvDuration("STANDBY_RELOAD_INTERVAL", standby.DefaultInterval))
+		opts = append(opts, http.WithFallback(snapshot))
+	}
+	if standbyPath != "" || standbyRedisAddr != "" {
+		var sinks []standby.Sink
+		if standbyPath != "" {
+			sinks = append(sinks, standby.NewFileSink(standbyPath))
+		}
+		if standbyRedisAddr != "" {
+			secondary := storage.NewRedisStore(standbyRedisAddr, getEnv("STANDBY_REDIS_PASSWORD", ""), 0)
+			defer secondary.Close()
+			sinks = append(sinks, standby.NewStoreSink(secondary))
+		}
+		exporter := standby.NewExporter(
+			store,
+			getEnvInt("STANDBY_TOP_N", standby.DefaultTopN),
+			getEnvDuration("STANDBY_INTERVAL", standby.DefaultInterval),
+			sinks...,
+		)
+		go exporter.Run(ctx)
+	}
+
+	// Configure custom domain verification
+	if getEnvBool("CUSTOM_DOMAINS", false) {
+		verifier := domain.NewVerifier(store)
+		go verifier.RunRecheck(ctx, getEnvDuration("DOMAIN_RECHECK_INTERVAL", domain.DefaultRecheckInterval))
+		opts = append(opts, http.WithDomains(verifier))
+	}
+
+	// Probe the destinations of links with fallbacks, failing over while
+	// they are down
+	if getEnvBool("HEALTH_CHECKS", false) {
+		checker := health.NewChecker(
+			store,
+			getEnvDuration("HEALTH_CHECK_INTERVAL", health.DefaultInterval),
+			getEnvInt("HEALTH_CHECK_FAILURES", health.DefaultThreshold),
+		)
+		go checker.Run(ctx)
+	}
+
+	// Tell owners about milestones and other events of their links, through
+	// the channels they configure per link or per workspace
+	mailer := mail.SMTP{
+		Addr:     getEnv("SMTP_ADDR", "localhost:25"),
+		From:     getEnv("SMTP_FROM", "url-shortener@localhost"),
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+	}
+	var notifier *notify.Notifier
+	if getEnvBool("NOTIFICATIONS", false) {
+		notifier = notify.New(store, redirects.BaseURL(baseURL), notify.Email{Sender: mailer}, notify.Slack{})
+		if spec := getEnv("NOTIFY_MILESTONES", ""); spec != "" {
+			milestones, err := notify.ParseMilestones(spec)
+			if err != nil {
+				log.Fatalf("Invalid NOTIFY_MILESTONES: %v", err)
+			}
+			notifier.Milestones = milestones
+		}
+		go notifier.Run(ctx, getEnvDuration("MILESTONE_CHECK_INTERVAL", notify.DefaultInterval))
+		opts = append(opts, http.WithNotifications(store, notifier))
+	}
+
+	// Let editors extend links in one call, and remind owners who asked
+	// for expiry events before their links expire
+	opts = append(opts, http.WithExpiry(store))
+	if getEnvBool("EXPIRY_REMINDERS", false) {
+		if notifier == nil {
+			log.Fatal("EXPIRY_REMINDERS requires NOTIFICATIONS")
+		}
+		if getEnv("SQL_DRIVER", "") != "" {
+			log.Fatal("EXPIRY_REMINDERS requires links to expire, but SQL_DRIVER keeps them forever")
+		}
+		window := getEnvDuration("EXPIRY_REMINDER_WINDOW", notify.DefaultReminderWindow)
+		if window >= store.TTL() {
+			log.Fatalf("EXPIRY_REMINDER_WINDOW (%s) must be shorter than LINK_TTL (%s)", window, store.TTL())
+		}
+		reminder := notify.NewReminder(store, notifier, window, getEnvInt("EXPIRY_REMINDER_BATCH", notify.DefaultReminderBatch))
+		registerJob("expiry-reminders",
+			jobs.Every(getEnvDuration("EXPIRY_REMINDER_INTERVAL", notify.DefaultReminderInterval)),
+			reminder.RemindExpiring,
+			lock.New(store, "expiry-reminders", lockTTL))
+	}
+
+	// Check every destination periodically, alerting when links break
+	if getEnvBool("LINK_ROT_CHECKS", false) {
+		var alerts alert.Multi
+		if webhook := getEnv("ALERT_WEBHOOK_URL", ""); webhook != "" {
+			alerts = append(alerts, alert.Webhook{URL: webhook})
+		}
+		if to := alert.ParseRecipients(getEnv("ALERT_EMAIL_TO", "")); len(to) > 0 {
+			alerts = append(alerts, alert.Email{
+				Addr:     getEnv("SMTP_ADDR", "localhost:25"),
+				From:     getEnv("SMTP_FROM", "url-shortener@localhost"),
+				To:       to,
+				Username: getEnv("SMTP_USERNAME", ""),
+				Password: getEnv("SMTP_PASSWORD", ""),
+			})
+		}
+		rot := health.NewRotChecker(
+			checks,
+			getEnvDuration("LINK_ROT_INTERVAL", health.DefaultRotInterval),
+			getEnvInt("LINK_ROT_BATCH", health.DefaultRotBatch),
+			alerts,
+		)
+		rot.Notifier = notifier
+		registerJob("link-rot", jobs.Every(rot.Tick()), rot.CheckStale, lock.New(store, "link-rot", lockTTL))
+	}
+
+	// Configure authentication for API clients and dashboard sessions
+	users := auth.ParseUsers(getEnv("DASHBOARD_USERS", ""))
+	apiKeys := auth.ParseAPIKeys(getEnv("API_KEYS", ""))
+	var tokens *auth.JWTVerifier
+	if issuer := getEnv("OIDC_ISSUER", ""); issuer != "" {
+		tokens = auth.NewJWTVerifier(auth.JWTConfig{
+			Issuer:       issuer,
+			Audience:     getEnv("OIDC_AUDIENCE", ""),
+			JWKSURL:      getEnv("OIDC_JWKS_URL", ""),
+			SubjectClaim: getEnv("OIDC_SUBJECT_CLAIM", auth.DefaultSubjectClaim),
+			KeyTTL:       getEnvDuration("OIDC_JWKS_TTL", auth.DefaultJWKSTTL),
+			Leeway:       auth.DefaultLeeway,
+		})
+	}
+	if len(users) > 0 || len(apiKeys) > 0 || tokens != nil {
+		defaultRole, err := auth.ParseRole(getEnv("DEFAULT_ROLE", string(auth.RoleEditor)))
+		if err != nil {
+			log.Fatalf("Invalid DEFAULT_ROLE: %v", err)
+		}
+		opts = append(opts, http.WithAuth(auth.NewManager(store, auth.Config{
+			Users:        users,
+			APIKeys:      apiKeys,
+			JWT:          tokens,
+			Admins:       auth.ParseAdmins(getEnv("ADMIN_SUBJECTS", "")),
+			Roles:        auth.ParseRoles(getEnv("ROLES", "")),
+			DefaultRole:  defaultRole,
+			Workspaces:   auth.ParseWorkspaces(getEnv("WORKSPACES", "")),
+			SessionTTL:   getEnvDuration("SESSION_TTL", auth.DefaultSessionTTL),
+			SecureCookie: getEnvBool("SESSION_COOKIE_SECURE", true),
+			Required:     getEnvBool("AUTH_REQUIRED", false),
+		})), http.WithWorkspaces(store))
+
+		// Limit link creations per workspace, or per subject or anonymous client
+		// address outside one
+		limits := http.QuotaLimits{
+			Daily:  int64(getEnvInt("QUOTA_DAILY_LINKS", 0)),
+			Active: int64(getEnvInt("QUOTA_ACTIVE_LINKS", 0)),
+		}
+		if limits.Daily > 0 || limits.Active > 0 {
+			opts = append(opts, http.WithQuotas(store, limits))
+		}
+	}
+
+	// Let admins migrate links in and back them up
+	if getEnvBool("IMPORT_EXPORT", false) {
+		opts = append(opts, http.WithImportExport(bulk, exporter))
+	}
+
+	// Record every change to a link for admins to review
+	if getEnvBool("AUDIT_LOG", false) {
+		opts = append(opts, http.WithAuditLog(store))
+	}
+
+	// Reject changes to links while serving redirects, for maintenance
+	// windows and storage failovers. Admins can toggle it at runtime, for
+	// every instance once it is shared through Redis.
+	readOnly := getEnvBool("READ_ONLY", false)
+	if readOnly {
+		opts = append(opts, http.WithReadOnly())
+	}
+	if getEnvBool("SHARED_READ_ONLY", stateless) {
+		if readOnly {
+			if err := store.SetReadOnly(ctx, true); err != nil {
+				log.Fatalf("Failed to turn on read-only mode: %v", err)
+			}
+		}
+		opts = append(opts, http.WithSharedReadOnly(store))
+	}
+
+	// Throttle or tarpit clients enumerating keys, told apart by the 404s
+	// they get from the redirect route
+	if getEnvBool("SCAN_PROTECTION", false) {
+		opts = append(opts, http.WithScanProtection(store, http.ScanProtection{
+			MaxMisses: int64(getEnvInt("SCAN_MAX_MISSES", http.DefaultScanMaxMisses)),
+			Window:    getEnvDuration("SCAN_WINDOW", http.DefaultScanWindow),
+			Block:     getEnvDuration("SCAN_BLOCK_DURATION", http.DefaultScanBlock),
+			Tarpit:    getEnvDuration("SCAN_TARPIT", 0),
+		}))
+	}
+
+	// Require anonymous creators to solve a CAPTCHA, against spam bots
+	if provider := getEnv("CAPTCHA_PROVIDER", ""); provider != "" {
+		var verifier captcha.SiteVerify
+		switch provider {
+		case "turnstile":
+			verifier = captcha.Turnstile(getEnv("CAPTCHA_SECRET", ""))
+		case "hcaptcha":
+			verifier = captcha.HCaptcha(getEnv("CAPTCHA_SECRET", ""))
+		default:
+			log.Fatalf("Invalid CAPTCHA_PROVIDER: %q", provider)
+		}
+		verifier.URL = getEnv("CAPTCHA_VERIFY_URL", verifier.URL)
+		opts = append(opts, http.WithCaptcha(verifier))
+	}
+
+	// Hold anonymous creations until their creator confirms their email,
+	// through the same SMTP server as alerts
+	if getEnvBool("EMAIL_VERIFICATION", false) {
+		secret := getEnv("VERIFICATION_SECRET", "")
+		if secret == "" {
+			log.Fatal("VERIFICATION_SECRET is required with EMAIL_VERIFICATION")
+		}
+		opts = append(opts, http.WithEmailVerification(http.EmailVerification{
+			Sender: mailer,
+			Secret: []byte(secret),
+			TTL:    getEnvDuration("VERIFICATION_TTL", http.DefaultVerificationTTL),
+		}))
+	}
+
+	// Answer 504 instead of letting a slow Redis stall requests; a timeout
+	// of 0 leaves those requests unbounded
+	opts = append(opts, http.WithRouteTimeouts(http.RouteTimeouts{
+		Redirect: getEnvDuration("REDIRECT_TIMEOUT", http.DefaultRedirectTimeout),
+		API:      getEnvDuration("API_TIMEOUT", http.DefaultAPITimeout),
+		Batch:    getEnvDuration("BATCH_TIMEOUT", http.DefaultBatchTimeout),
+	}))
+
+	// Bound destination URLs and request bodies
+	opts = append(opts, http.WithLimits(http.Limits{
+		MaxURLLength: getEnvInt("MAX_URL_LENGTH", http.DefaultMaxURLLength),
+		MaxBodyBytes: int64(getEnvInt("MAX_BODY_BYTES", http.DefaultMaxBodyBytes)),
+	}))
+
+	// Replay creation responses to clients retrying with an Idempotency-Key
+	opts = append(opts, http.WithIdempotency(store, getEnvDuration("IDEMPOTENCY_TTL", http.DefaultIdempotencyTTL)))
+
+	// Record clicks asynchronously for link statistics
+	ipPrivacy, err := analytics.ParseIPPrivacy(getEnv("IP_PRIVACY", string(analytics.IPTruncated)))
+	if err != nil {
+		log.Fatalf("Invalid IP_PRIVACY: %v", err)
+	}
+	recorder := analytics.NewRecorder(store, getEnvInt("ANALYTICS_QUEUE_SIZE", analytics.DefaultQueueSize))
+	recorder.UseAnonymizer(analytics.NewAnonymizer(
+		ipPrivacy,
+		getEnvDuration("IP_HASH_ROTATION", analytics.DefaultSaltRotation),
+		getEnv("IP_HASH_SECRET", ""),
+	))
+	// Classify clicks into channels by referrer, with local additions
+	if path := getEnv("REFERRER_CHANNELS", ""); path != "" {
+		channels, err := analytics.LoadChannels(path)
+		if err != nil {
+			log.Fatalf("Invalid REFERRER_CHANNELS: %v", err)
+		}
+		recorder.UseClassifier(analytics.NewClassifier(channels))
+	}
+	pipelines = append(pipelines, pipeline{analytics.QueueName, recorder.Shutdown})
+
+	// Export clicks to ClickHouse for long-term analysis, alongside or
+	// instead of Redis rollups. It is drained after the recorder feeding it.
+	if addr := getEnv("CLICKHOUSE_URL", ""); addr != "" {
+		writer, err := clickhouse.New(clickhouse.Config{
+			URL:           addr,
+			User:          getEnv("CLICKHOUSE_USER", ""),
+			Password:      getEnv("CLICKHOUSE_PASSWORD", ""),
+			Table:         getEnv("CLICKHOUSE_TABLE", clickhouse.DefaultTable),
+			BatchSize:     getEnvInt("CLICKHOUSE_BATCH_SIZE", clickhouse.DefaultBatchSize),
+			FlushInterval: getEnvDuration("CLICKHOUSE_FLUSH_INTERVAL", clickhouse.DefaultFlushInterval),
+			BufferSize:    getEnvInt("CLICKHOUSE_BUFFER_SIZE", clickhouse.DefaultBufferSize),
+		})
+		if err != nil {
+			log.Fatalf("Invalid ClickHouse configuration: %v", err)
+		}
+		if getEnvBool("CLICKHOUSE_CREATE_TABLE", true) {
+			if err := writer.CreateTable(ctx); err != nil {
+				log.Fatalf("Failed to create ClickHouse table: %v", err)
+			}
+		}
+		recorder.AddSink(writer)
+		pipelines = append(pipelines, pipeline{clickhouse.QueueName, writer.Shutdown})
+	}
+
+	// Meter links created, redirects served and clicks stored per
+	// workspace, so internal teams can be billed for their traffic
+	var meter *metering.Meter
+	if getEnvBool("USAGE_METERING", false) {
+		var sink metering.Sink
+		if webhook := getEnv("USAGE_WEBHOOK_URL", ""); webhook != "" {
+			sink = metering.Webhook{URL: webhook}
+		}
+		meter = metering.NewMeter(store, sink, getEnvDuration("USAGE_FLUSH_INTERVAL", metering.DefaultFlushInterval))
+		recorder.OnStored(meter.AddClick)
+		go meter.Run(ctx)
+		opts = append(opts, http.WithUsageMetering(meter, store))
+	}
+	if notifier != nil {
+		recorder.OnStored(func(click analytics.Click) { notifier.Clicked(click.Key) })
+	}
+	opts = append(opts, http.WithStats(store, recorder), http.WithTopLinks(store))
+
+	// Group links into campaigns whose statistics roll up
+	if getEnvBool("CAMPAIGNS", false) {
+		opts = append(opts, http.WithCampaigns(store))
+	}
+
+	// Serve keys as landing pages listing several destinations
+	if getEnvBool("LANDING_PAGES", false) {
+		if getEnv("SQL_DRIVER", "") != "" {
+			log.Fatal("LANDING_PAGES requires links in Redis, but SQL_DRIVER keeps them in SQL")
+		}
+		opts = append(opts, http.WithLandingPages(store))
+	}
+
+	// Push clicks to dashboards following links live
+	if getEnvBool("LIVE_CLICKS", false) {
+		store.EnableLiveClicks()
+		opts = append(opts, http.WithClickStream(store, ctx.Done()))
+	}
+
+	// Keep the latest visits of each link with visitor details, and let
+	// owners export and erase the data stored about their links
+	var visits storage.VisitStore
+	if getEnvBool("VISIT_LOG", false) {
+		store.EnableVisits(storage.VisitOptions{
+			Limit:     getEnvInt("VISIT_LOG_LIMIT", storage.DefaultVisitLimit),
+			Retention: getEnvDuration("VISIT_LOG_RETENTION", storage.DefaultVisitRetention),
+		})
+		visits = store
+	}
+	opts = append(opts, http.WithPrivacy(exporter, visits))
+
+	// Only count the clicks of visitors sending Do-Not-Track or Global Privacy Control
+	if getEnvBool("HONOR_DNT", true) {
+		opts = append(opts, http.WithDoNotTrack())
+	}
+
+	// Roll clicks into hourly and daily aggregates per key, country and referrer
+	if getEnv("ARCHIVE_BUCKET", "") != "" && !getEnvBool("ROLLUPS", false) {
+		log.Fatal("ARCHIVE_BUCKET requires ROLLUPS, which records raw click events")
+	}
+	if getEnvBool("ROLLUPS", false) {
+		store.EnableRollups(storage.RollupOptions{
+			RawRetention:    getEnvDuration("RAW_EVENT_RETENTION", storage.DefaultRawRetention),
+			HourlyRetention: getEnvDuration("HOURLY_ROLLUP_RETENTION", storage.DefaultHourlyRetention),
+			DailyRetention:  getEnvDuration("DAILY_ROLLUP_RETENTION", storage.DefaultDailyRetention),
+		})
+		// Archive raw click events to S3 or GCS before they are trimmed, so
+		// Redis keeps little raw history while batch analytics keep all of it
+		if bucket := getEnv("ARCHIVE_BUCKET", ""); bucket != "" {
+			region := getEnv("ARCHIVE_REGION", "us-east-1")
+			store.EnableArchive()
+			archiver := archive.New(store, &archive.S3{
+				Endpoint:  getEnv("ARCHIVE_ENDPOINT", "https://s3."+region+".amazonaws.com"),
+				Region:    region,
+				Bucket:    bucket,
+				AccessKey: getEnv("ARCHIVE_ACCESS_KEY", ""),
+				SecretKey: getEnv("ARCHIVE_SECRET_KEY", ""),
+			}, getEnv("ARCHIVE_PREFIX", archive.DefaultPrefix), getEnvInt("ARCHIVE_BATCH_SIZE", archive.DefaultBatchSize))
+			// The archiver takes a lease of its own, so it needs no lock
+			registerJob("archive", jobs.Every(getEnvDuration("ARCHIVE_INTERVAL", archive.DefaultInterval)), archiver.Archive, nil)
+		}
+		registerJob("rollups",
+			jobs.Every(getEnvDuration("ROLLUP_INTERVAL", analytics.DefaultAggregateInterval)),
+			store.Aggregate,
+			lock.New(store, "rollups", lockTTL))
+		opts = append(opts, http.WithRollups(store))
+	}
+
+	// Fetch destination titles and descriptions, optionally serving them
+	// to social network preview bots
+	linkPreviews := getEnvBool("LINK_PREVIEWS", false)
+	if getEnvBool("FETCH_METADATA", false) || linkPreviews {
+		prefetcher := metadata.NewPrefetcher(
+			metadata.Fetcher{},
+			previews,
+			getEnvInt("PREVIEW_QUEUE_SIZE", metadata.DefaultQueueSize),
+			getEnvDuration("METADATA_TIMEOUT", metadata.DefaultTimeout),
+		)
+		pipelines = append(pipelines, pipeline{metadata.QueueName, prefetcher.Shutdown})
+		if linkPreviews {
+			opts = append(opts, http.WithPreviews(prefetcher))
+		} else {
+			opts = append(opts, http.WithMetadata(prefetcher))
+		}
+	}
+
+	// Configure how crawlers and link-preview bots are handled
+	botMode, err := http.ParseBotMode(getEnv("BOT_MODE", string(http.BotExclude)))
+	if err != nil {
+		log.Fatalf("Invalid BOT_MODE: %v", err)
+	}
+	botDetector := useragent.NewBotDetector(useragent.ParsePatterns(getEnv("BOT_USER_AGENTS", ""))...)
+	opts = append(opts, http.WithBotFilter(botDetector, botMode))
+
+	// Mirror a sample of redirect traffic to staging
+	if target := getEnv("MIRROR_URL", ""); target != "" {
+		m, err := mirror.New(target, getEnvFloat("MIRROR_SAMPLE_RATE", 0.01), getEnvInt("MIRROR_QUEUE_SIZE", mirror.DefaultQueueSize))
+		if err != nil {
+			log.Fatalf("Invalid MIRROR_URL: %v", err)
+		}
+		pipelines = append(pipelines, pipeline{mirror.QueueName, m.Shutdown})
+		opts = append(opts, http.WithMirror(m))
+	}
+
+	// Let links be created under custom keys, with suggestions for free ones
+	if getEnvBool("VANITY_KEYS", false) {
+		opts = append(opts, http.WithVanityKeys(keys))
+	}
+
+	// Let links require a signature on redirects, so they can't be found
+	// by enumerating keys
+	if secret := getEnv("LINK_SIGNING_SECRET", ""); secret != "" {
+		opts = append(opts, http.WithLinkSigning([]byte(secret)))
+	}
+
+	// Let links be used up by their first redirect
+	if getEnvBool("SINGLE_USE_LINKS", false) {
+		if getEnv("SQL_DRIVER", "") != "" {
+			log.Fatal("SINGLE_USE_LINKS requires links in Redis, but SQL_DRIVER keeps them in SQL")
+		}
+		opts = append(opts, http.WithSingleUseLinks(store))
+	}
+
+	// Let links stop redirecting after a number of clicks
+	if getEnvBool("CLICK_LIMITS", false) {
+		if getEnv("SQL_DRIVER", "") != "" {
+			log.Fatal("CLICK_LIMITS requires links in Redis, but SQL_DRIVER keeps them in SQL")
+		}
+		opts = append(opts, http.WithClickLimits(store))
+	}
+
+	// Let editors rename links, keeping the former key redirecting to the
+	// new one for a grace period
+	if getEnvBool("KEY_RENAMES", false) {
+		if !getEnvBool("VANITY_KEYS", false) {
+			log.Fatal("KEY_RENAMES requires VANITY_KEYS")
+		}
+		if getEnv("SQL_DRIVER", "") != "" {
+			log.Fatal("KEY_RENAMES requires links in Redis, but SQL_DRIVER keeps them in SQL")
+		}
+		opts = append(opts, http.WithRenames(store, getEnvDuration("RENAME_GRACE_PERIOD", storage.DefaultAliasGrace)))
+	}
+
+	// Write redirect hits to an access log for log-analysis tooling
+	if dest := getEnv("ACCESS_LOG", ""); dest != "" {
+		format, err := accesslog.ParseFormat(getEnv("ACCESS_LOG_FORMAT", string(accesslog.FormatCombined)))
+		if err != nil {
+			log.Fatalf("Invalid ACCESS_LOG_FORMAT: %v", err)
+		}
+		w := accesslog.NopCloser(os.Stdout)
+		if dest != "stdout" {
+			w, err = accesslog.OpenRotatingFile(dest, int64(getEnvInt("ACCESS_LOG_MAX_SIZE", 100))<<20, getEnvInt("ACCESS_LOG_MAX_BACKUPS", 5))
+			if err != nil {
+				log.Fatalf("Failed to open ACCESS_LOG: %v", err)
+			}
+		}
+		l := accesslog.New(w, format, getEnvInt("ACCESS_LOG_QUEUE_SIZE", accesslog.DefaultQueueSize))
+		pipelines = append(pipelines, pipeline{accesslog.QueueName, l.Shutdown})
+		opts = append(opts, http.WithAccessLog(l))
+	}
+
+	// Purge updated and deleted links from the CDN's edge cache
+	if name := getEnv("PURGE_BACKEND", ""); name != "" {
+		backend, err := purge.NewBackend(name, purge.Config{
+			Token:  getEnv("PURGE_API_TOKEN", ""),
+			ZoneID: getEnv("PURGE_ZONE_ID", ""),
+		})
+		if err != nil {
+			log.Fatalf("Invalid PURGE_BACKEND: %v", err)
+		}
+		p := purge.New(backend, getEnvInt("PURGE_QUEUE_SIZE", purge.DefaultQueueSize))
+		pipelines = append(pipelines, pipeline{purge.QueueName, p.Shutdown})
+		opts = append(opts, http.WithPurge(p))
+	}
+
+	// Derive keys from a salted hash of the URL for deterministic shortening
+	if salt := getEnv("DETERMINISTIC_SALT", ""); salt != "" {
+		hashGenerator, err := id.NewHashGenerator(salt, getEnvInt("DETERMINISTIC_KEY_LENGTH", id.DefaultHashKeyLength))
+		if err != nil {
+			log.Fatalf("Invalid DETERMINISTIC_KEY_LENGTH: %v", err)
+		}
+		opts = append(opts, http.WithDeterministic(hashGenerator))
+	}
+
+	// Configure the root path behavior
+	rootConfig, err := http.ParseRootConfig(getEnv("ROOT_MODE", string(http.RootNotFound)))
+	if err != nil {
+		log.Fatalf("Invalid ROOT_MODE: %v", err)
+	}
+	rootHosts, err := http.ParseRootHosts(getEnv("ROOT_HOSTS", ""))
+	if err != nil {
+		log.Fatalf("Invalid ROOT_HOSTS: %v", err)
+	}
+	opts = append(opts, http.WithRoot(rootConfig, rootHosts))
+	opts = append(opts, http.WithRedirectRoutes(redirects))
+
+	// Serve the dashboard under /app/ when it was embedded at build time
+	if app := web.FS(); app != nil && getEnvBool("SERVE_APP", true) {
+		opts = append(opts, http.WithApp(app))
+	}
+
+	// Store the final destination of links through other shorteners,
+	// including this one
+	if getEnvBool("RESOLVE_SHORT_LINKS", false) {
+		hosts := chain.DefaultHosts
+		if spec := getEnv("SHORTENER_HOSTS", ""); spec != "" {
+			hosts = chain.ParseHosts(spec)
+		}
+		if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+			hosts = append(slices.Clone(hosts), u.Hostname())
+		}
+		if redirects.Host != "" {
+			hosts = append(slices.Clone(hosts), redirects.Host)
+		}
+		resolver := chain.NewResolver(hosts, getEnvInt("SHORT_LINK_MAX_HOPS", chain.DefaultMaxHops), chain.DefaultTimeout)
+		opts = append(opts, http.WithChainResolution(resolver))
+	}
+
+	// Upgrade or refuse destinations using plain HTTP
+	httpPolicy, err := http.ParseHTTPPolicy(getEnv("HTTP_DESTINATIONS", string(http.HTTPAllow)))
+	if err != nil {
+		log.Fatalf("Invalid HTTP_DESTINATIONS: %v", err)
+	}
+	opts = append(opts, http.WithHTTPDestinations(httpPolicy, upgrade.NewChecker(getEnvDuration("HTTPS_CHECK_TIMEOUT", upgrade.DefaultTimeout))))
+
+	// Answer deletes of unknown keys like successful ones
+	if getEnvBool("IDEMPOTENT_DELETES", false) {
+		opts = append(opts, http.WithIdempotentDeletes())
+	}
+
+	// Announce when API v1 stops being served
+	if sunset := getEnv("API_V1_SUNSET", ""); sunset != "" {
+		t, err := time.Parse(time.RFC3339, sunset)
+		if err != nil {
+			log.Fatalf("Invalid API_V1_SUNSET: %v", err)
+		}
+		opts = append(opts, http.WithV1Sunset(t))
+	}
+
+	// Brand the pages browsers see for unknown and inactive links
+	errorPages := http.ErrorPages{Brand: getEnv("BRAND_NAME", "")}
+	if dir := getEnv("ERROR_PAGES_DIR", ""); dir != "" {
+		errorPages, err = http.LoadErrorPages(dir, errorPages.Brand)
+		if err != nil {
+			log.Fatalf("Failed to load error pages: %v", err)
+		}
+	}
+	opts = append(opts, http.WithErrorPages(errorPages))
+
+	// Load custom page for links outside their activation window
+	if path := getEnv("INACTIVE_PAGE_TEMPLATE", ""); path != "" {
+		tmpl, err := template.ParseFiles(path)
+		if err != nil {
+			log.Fatalf("Failed to load inactive page template: %v", err)
+		}
+		opts = append(opts, http.WithInactivePage(tmpl))
+	}
+
+	// Serve crawler rules and the security contact
+	var wellKnown http.WellKnownConfig
+	if path := getEnv("ROBOTS_TXT_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load robots.txt: %v", err)
+		}
+		wellKnown.RobotsTxt = string(data)
+	}
+	if path := getEnv("SECURITY_TXT_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to load security.txt: %v", err)
+		}
+		wellKnown.SecurityTxt = string(data)
+	} else if contacts := http.ParseContacts(getEnv("SECURITY_CONTACT", "")); len(contacts) > 0 {
+		wellKnown.SecurityTxt = http.FormatSecurityTxt(http.SecurityTxtFields{
+			Contact:   contacts,
+			Expires:   time.Now().Add(getEnvDuration("SECURITY_TXT_EXPIRES_IN", 180*24*time.Hour)),
+			Policy:    getEnv("SECURITY_POLICY_URL", ""),
+			Canonical: strings.TrimSuffix(baseURL, "/") + "/.well-known/security.txt",
+		})
+	}
+	opts = append(opts, http.WithWellKnown(wellKnown))
+
+	// Let browsers and CDNs cache redirects of links without their own setting
+	opts = append(opts, http.WithRedirectCache(getEnvDuration("REDIRECT_CACHE_MAX_AGE", 0)))
 
 	// Initialize HTTP handler
-	handler := http.NewHandler(store, generator, baseURL)
+	for name := range schedules {
+		log.Fatalf("JOB_SCHEDULES names job %s, which isn't enabled", name)
+	}
+	go scheduler.Run(ctx)
+	opts = append(opts, http.WithJobs(scheduler))
 
-	// Set up Gin router
-	router := gin.Default()
+	handler := http.NewHandler(links, generator, baseURL, opts...)
+
+	// Only trust X-Forwarded-For from our own proxies, so clients can't
+	// pick the address they are rate limited and flagged by
+	var proxies []string
+	if v := getEnv("TRUSTED_PROXIES", ""); v != "" {
+		proxies = strings.Split(v, ",")
+	}
 
 	// Configure CORS
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:5173"} // Vite's default dev server port
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept"}
-	router.Use(cors.New(config))
+	config.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", auth.APIKeyHeader, auth.CSRFHeader}
+	config.AllowCredentials = true // Dashboard sessions use cookies
+
+	// Set up Gin routers
+	newRouter := func() *gin.Engine {
+		router := gin.Default()
+		if err := router.SetTrustedProxies(proxies); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		router.Use(cors.New(config))
+		return router
+	}
+	router := newRouter()
+	handler.SetupRedirectRoutes(router)
+	servers := []*nethttp.Server{{Addr: fmt.Sprintf(":%s", serverPort), Handler: router}}
+
+	// Serve the management API on a port of its own, or not at all, so the
+	// redirect edge can be exposed publicly while the API stays internal
+	management := router
+	if apiPort := getEnv("API_PORT", serverPort); apiPort != serverPort {
+		management = newRouter()
+		servers = append(servers, &nethttp.Server{Addr: fmt.Sprintf(":%s", apiPort), Handler: management})
+	}
+	if getEnvBool("SERVE_API", true) {
+		handler.SetupAPIRoutes(management)
+	}
+
+	// Expose runtime metrics, including dropped queue items
+	if getEnvBool("DEBUG_VARS", false) {
+		management.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	}
+
+	// Start servers
+	for _, server := range servers {
+		go func() {
+			log.Printf("Starting server on %s...\n", server.Addr)
+			if err := server.ListenAndServe(); err != nil && err != nethttp.ErrServerClosed {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+		}()
+	}
 
-	handler.SetupRoutes(router)
+	<-ctx.Done()
+	log.Println("Shutting down...")
 
-	// Start server
-	log.Printf("Starting server on port %s...\n", serverPort)
-	if err := router.Run(fmt.Sprintf(":%s", serverPort)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Stop taking requests, then drain the async pipelines so the last
+	// clicks aren't lost, all within one bounded deadline
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second))
+	defer shutdownCancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown incomplete: %v", err)
+		}
+	}
+	for _, p := range pipelines {
+		if err := p.shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to drain %s queue: %v", p.name, err)
+		}
+		if n := queue.Dropped(p.name); n > 0 {
+			log.Printf("%s queue dropped %d items", p.name, n)
+		}
+	}
+
+	// Flush the usage of the drained clicks last
+	if meter != nil {
+		if err := meter.Flush(shutdownCtx); err != nil {
+			log.Printf("Failed to flush usage: %v", err)
+		}
+	}
+}
+
+// statefulFeatures lists the enabled features whose state lives in this
+// process alone, which replicas behind a load balancer would disagree on.
+// State kept per instance by design, such as the circuit breaker's, is
+// left out.
+func statefulFeatures() []string {
+	var features []string
+	if getEnvInt("CACHE_SIZE", 0) > 0 {
+		features = append(features, "CACHE_SIZE: records cached by one instance go stale when another changes them")
+	}
+	if getEnvInt("NEGATIVE_CACHE_SIZE", 0) > 0 {
+		features = append(features, "NEGATIVE_CACHE_SIZE: keys cached as unknown by one instance stay unknown after another creates them")
+	}
+	if getEnv("KEY_GENERATOR", "random") == "snowflake" && getEnvInt("WORKER_ID", -1) >= 0 {
+		features = append(features, "WORKER_ID: replicas sharing a pinned worker ID generate colliding keys")
+	}
+	if getEnv("IP_PRIVACY", "") == string(analytics.IPHashed) && getEnv("IP_HASH_SECRET", "") == "" {
+		features = append(features, "IP_PRIVACY=hashed without IP_HASH_SECRET: each instance hashes visitors with a secret of its own")
 	}
+	if !getEnvBool("SHARED_READ_ONLY", true) {
+		features = append(features, "SHARED_READ_ONLY=false: read-only mode is toggled on one instance at a time")
+	}
+	return features
 }
 
 func getEnv(key, defaultValue string) string {
@@ -55,3 +957,43 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("Invalid boolean for %s, using default %t", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		log.Printf("Invalid integer for %s, using default %d", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("Invalid number for %s, using default %g", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		log.Printf("Invalid duration for %s, using default %s", key, defaultValue)
+	}
+	return defaultValue
+}
@@ -0,0 +1,166 @@
+// Command urlctl administers the store of a URL shortener deployment
+// directly, without going through its API.
+//
+//	urlctl backup [-o FILE] [-since TIME | -after BACKUP]
+//	urlctl restore [-i FILE]
+//	urlctl migrate
+//
+// It connects to Redis with the REDIS_ADDR and REDIS_PASSWORD environment
+// variables of the API server, and restores links without an
+// expiry with its LINK_TTL.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/backup"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const usage = `Usage:
+  urlctl backup [-o FILE] [-since TIME | -after BACKUP]
+  urlctl restore [-i FILE]
+  urlctl migrate
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("urlctl: ")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "backup":
+		err = runBackup(ctx, os.Args[2:])
+	case "restore":
+		err = runRestore(ctx, os.Args[2:])
+	case "migrate":
+		err = runMigrate(ctx)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runBackup writes a full or incremental backup to a file or stdout
+func runBackup(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "-", "file to write the backup to, - for stdout")
+	sinceFlag := flags.String("since", "", "only back up links created at or after this RFC 3339 time")
+	after := flags.String("after", "", "only back up links created since this earlier backup began")
+	flags.Parse(args)
+
+	var since *time.Time
+	switch {
+	case *sinceFlag != "" && *after != "":
+		return fmt.Errorf("-since and -after are exclusive")
+	case *sinceFlag != "":
+		t, err := time.Parse(time.RFC3339, *sinceFlag)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		since = &t
+	case *after != "":
+		f, err := os.Open(*after)
+		if err != nil {
+			return err
+		}
+		header, err := backup.ReadHeader(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *after, err)
+		}
+		since = &header.CreatedAt
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	store := openStore()
+	defer store.Close()
+	n, err := backup.Write(ctx, w, store, since)
+	if err != nil {
+		return err
+	}
+	log.Printf("backed up %d links", n)
+	return nil
+}
+
+// runRestore restores a backup from a file or stdin
+func runRestore(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := flags.String("i", "-", "file to read the backup from, - for stdin")
+	flags.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	store := openStore()
+	defer store.Close()
+	res, err := backup.Restore(ctx, r, store)
+	if res != nil {
+		summary, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Fprintln(os.Stderr, string(summary))
+	}
+	return err
+}
+
+// runMigrate upgrades every record stored with an older schema version
+func runMigrate(ctx context.Context) error {
+	store := openStore()
+	defer store.Close()
+	n, err := store.MigrateRecords(ctx)
+	log.Printf("upgraded %d records to schema version %d", n, storage.SchemaVersion)
+	return err
+}
+
+// openStore connects to the deployment's Redis
+func openStore() *storage.RedisStore {
+	ttl, err := time.ParseDuration(getEnv("LINK_TTL", storage.DefaultTTL.String()))
+	if err != nil {
+		log.Fatalf("invalid LINK_TTL: %v", err)
+	}
+	store := storage.NewRedisStore(getEnv("REDIS_ADDR", "localhost:6379"), getEnv("REDIS_PASSWORD", ""), 0)
+	store.SetTTL(ttl)
+	return store
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,177 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
+)
+
+const (
+	// DefaultQueueSize is the default number of entries buffered before new ones are dropped
+	DefaultQueueSize = 10000
+
+	// QueueName identifies the access log queue in the dropped items metric
+	QueueName = "accesslog"
+
+	// combinedTimeLayout is the timestamp layout of the Combined Log Format
+	combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+// Format is the layout of access log lines
+type Format string
+
+const (
+	// FormatCombined is the Apache/NCSA Combined Log Format
+	FormatCombined Format = "combined"
+
+	// FormatJSON writes one JSON object per line
+	FormatJSON Format = "json"
+)
+
+// ErrUnknownFormat is returned for unsupported access log formats
+var ErrUnknownFormat = errors.New("access log format must be combined or json")
+
+// ParseFormat parses an access log format
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCombined, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// Entry is a single request served for a short link
+type Entry struct {
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Size       int       `json:"size"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+
+	// Key and Location are only written in JSON lines
+	Key      string `json:"key,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+// Logger writes access log entries in the background, so redirects never
+// wait on the log's disk or pipe
+type Logger struct {
+	w      io.WriteCloser
+	format Format
+	queue  *queue.Queue[Entry]
+}
+
+// New creates a new Logger writing to w and starts its worker
+func New(w io.WriteCloser, format Format, queueSize int) *Logger {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	l := &Logger{w: w, format: format}
+	l.queue = queue.New(QueueName, queueSize, l.write)
+	return l
+}
+
+// Log queues an entry, dropping it if the queue is full
+func (l *Logger) Log(e Entry) {
+	l.queue.Push(e)
+}
+
+// Flush waits until queued entries have been written or ctx is done
+func (l *Logger) Flush(ctx context.Context) error {
+	return l.queue.Flush(ctx)
+}
+
+// Shutdown stops accepting entries, waits for queued ones to be written
+// until ctx is done and closes the writer
+func (l *Logger) Shutdown(ctx context.Context) error {
+	err := l.queue.Shutdown(ctx)
+	if closeErr := l.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// write formats a single entry onto the writer
+func (l *Logger) write(e Entry) {
+	var line []byte
+	if l.format == FormatJSON {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(Combined(e))
+	}
+	if _, err := l.w.Write(line); err != nil {
+		log.Printf("failed to write access log: %v", err)
+	}
+}
+
+// Combined formats an entry as a Combined Log Format line, including the
+// trailing newline
+func Combined(e Entry) string {
+	size := "-"
+	if e.Size > 0 {
+		size = strconv.Itoa(e.Size)
+	}
+
+	var b strings.Builder
+	b.WriteString(orDash(e.RemoteAddr))
+	b.WriteString(" - ")
+	b.WriteString(orDash(escape(e.User)))
+	b.WriteString(" [")
+	b.WriteString(e.Time.Format(combinedTimeLayout))
+	b.WriteString(`] "`)
+	b.WriteString(escape(e.Method + " " + e.URI + " " + e.Proto))
+	b.WriteString(`" `)
+	b.WriteString(strconv.Itoa(e.Status))
+	b.WriteString(" ")
+	b.WriteString(size)
+	b.WriteString(` "`)
+	b.WriteString(orDash(escape(e.Referer)))
+	b.WriteString(`" "`)
+	b.WriteString(orDash(escape(e.UserAgent)))
+	b.WriteString("\"\n")
+	return b.String()
+}
+
+// orDash returns "-" for empty fields, as the Combined Log Format expects
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// escape quotes backslashes, double quotes and control characters, so a
+// request can't forge log fields or lines
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
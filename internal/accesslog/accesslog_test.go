@@ -0,0 +1,105 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buffer is a concurrency-safe in-memory log destination
+type buffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+
+	_, err = ParseFormat("common")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestCombined(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 7, 9, 0, time.FixedZone("", -7*3600))
+	line := Combined(Entry{
+		RemoteAddr: "203.0.113.7",
+		Time:       at,
+		Method:     "GET",
+		URI:        "/abc123?utm_source=x",
+		Proto:      "HTTP/1.1",
+		Status:     302,
+		Size:       0,
+		UserAgent:  "curl/8.0 \"quoted\"\n",
+	})
+	assert.Equal(t, `203.0.113.7 - - [05/Mar/2024:14:07:09 -0700] "GET /abc123?utm_source=x HTTP/1.1" 302 - "-" "curl/8.0 \"quoted\"\x0a"`+"\n", line)
+}
+
+func TestLogger(t *testing.T) {
+	out := &buffer{}
+	l := New(out, FormatJSON, 10)
+
+	l.Log(Entry{RemoteAddr: "203.0.113.7", Method: "GET", URI: "/abc123", Status: 302, Key: "abc123", Location: "https://example.com"})
+	require.NoError(t, l.Flush(context.Background()))
+
+	var e Entry
+	require.NoError(t, json.Unmarshal(out.buf.Bytes(), &e))
+	assert.Equal(t, "abc123", e.Key)
+	assert.Equal(t, "https://example.com", e.Location)
+	assert.Equal(t, 302, e.Status)
+
+	require.NoError(t, l.Shutdown(context.Background()))
+	assert.True(t, out.closed)
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// Reopening appends to the current file
+	f, err = OpenRotatingFile(path, 100, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("fifth\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "fourth\nfifth\n", read(path))
+}
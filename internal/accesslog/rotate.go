@@ -0,0 +1,115 @@
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only file that is renamed to path.1 once it
+// grows past MaxSize bytes, shifting older backups up to path.MaxBackups
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens or creates the log file at path
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current log file for appending
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would take the file past MaxSize
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups up by one, drops the oldest and starts a new file
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.MaxBackups > 0 {
+		for i := f.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(backupName(f.Path, i), backupName(f.Path, i+1))
+		}
+		if err := os.Rename(f.Path, backupName(f.Path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.Path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Close closes the current log file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// backupName names the nth backup of path
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// nopCloser is a writer whose Close leaves the underlying stream open
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// NopCloser wraps a stream the logger shares with the rest of the process,
+// such as stdout, so shutting the logger down doesn't close it
+func NopCloser(w io.Writer) io.WriteCloser {
+	return nopCloser{w}
+}
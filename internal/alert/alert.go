@@ -0,0 +1,155 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default time allowed to deliver an alert
+const DefaultTimeout = 10 * time.Second
+
+// ErrUnexpectedStatus is returned when a webhook rejects an alert
+var ErrUnexpectedStatus = errors.New("webhook returned an unexpected status")
+
+// Alert is a message for operators and link owners. Links carries the
+// affected links, so webhook receivers can route them to their owners.
+type Alert struct {
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	Links   []Link    `json:"links,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Link is a short link an alert is about
+type Link struct {
+	Key       string `json:"key"`
+	URL       string `json:"url"`
+	Owner     string `json:"owner,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// Sender delivers alerts
+type Sender interface {
+	Send(ctx context.Context, a Alert) error
+}
+
+// Webhook posts alerts as JSON to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the alert, failing unless the receiver answers with a 2xx status
+func (w Webhook) Send(ctx context.Context, a Alert) error {
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends alerts as plain text mail through an SMTP server. Username
+// and Password are optional; when set, PLAIN authentication is used.
+type Email struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+// Send mails the alert to every recipient
+func (e Email) Send(ctx context.Context, a Alert) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, err := net.SplitHostPort(e.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.Addr, auth, e.From, e.To, e.message(a))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseRecipients parses a comma-separated list of email addresses
+func ParseRecipients(spec string) []string {
+	var to []string
+	for _, addr := range strings.Split(spec, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return to
+}
+
+// message formats the alert as an RFC 5322 message
+func (e Email) message(a Alert) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + e.From + "\r\n")
+	b.WriteString("To: " + strings.Join(e.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + headerValue(a.Subject) + "\r\n")
+	b.WriteString("Date: " + a.Time.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(a.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks, so a value can't inject headers
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// Multi delivers alerts through several senders
+type Multi []Sender
+
+// Send delivers the alert through every sender, returning the errors of
+// those that failed
+func (m Multi) Send(ctx context.Context, a Alert) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Send(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
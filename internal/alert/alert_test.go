@@ -0,0 +1,56 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_Send(t *testing.T) {
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	a := Alert{
+		Subject: "1 broken short links",
+		Text:    "abc12345 -> https://example.com (404 Not Found)",
+		Links:   []Link{{Key: "abc12345", URL: "https://example.com", Owner: "alice"}},
+		Time:    time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, Webhook{URL: server.URL}.Send(context.Background(), a))
+	assert.Equal(t, a, received)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.ErrorIs(t, Webhook{URL: failing.URL}.Send(context.Background(), a), ErrUnexpectedStatus)
+}
+
+func TestEmail_Message(t *testing.T) {
+	e := Email{From: "shortener@example.com", To: []string{"ops@example.com", "alice@example.com"}}
+	msg := string(e.message(Alert{
+		Subject: "Broken\r\nBcc: attacker@example.com",
+		Text:    "line one\nline two",
+		Time:    time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+	}))
+
+	assert.Contains(t, msg, "To: ops@example.com, alice@example.com\r\n")
+	assert.Contains(t, msg, "Subject: Broken  Bcc: attacker@example.com\r\n", "line breaks can't inject headers")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nline one\r\nline two\r\n"))
+}
+
+func TestParseRecipients(t *testing.T) {
+	assert.Equal(t, []string{"ops@example.com", "alice@example.com"}, ParseRecipients(" ops@example.com, ,alice@example.com"))
+	assert.Nil(t, ParseRecipients(""))
+}
@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/lock"
+)
+
+// DefaultAggregateInterval is the default time between aggregation runs
+const DefaultAggregateInterval = time.Minute
+
+// Rollup aggregates recorded click events, returning how many it processed
+type Rollup interface {
+	Aggregate(ctx context.Context) (int, error)
+}
+
+// Aggregator periodically rolls raw click events into aggregates
+type Aggregator struct {
+	rollup   Rollup
+	interval time.Duration
+
+	// Lock, if set, keeps aggregation to one instance at a time
+	Lock *lock.Lock
+}
+
+// NewAggregator creates a new Aggregator running every interval
+func NewAggregator(rollup Rollup, interval time.Duration) *Aggregator {
+	if interval <= 0 {
+		interval = DefaultAggregateInterval
+	}
+	return &Aggregator{rollup: rollup, interval: interval}
+}
+
+// Run aggregates click events until ctx is done
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := a.Lock.Do(ctx, func(ctx context.Context) error {
+			_, err := a.rollup.Aggregate(ctx)
+			return err
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("click aggregation failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
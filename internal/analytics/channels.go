@@ -0,0 +1,157 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Channels clicks are classified into by their referrer. Social networks
+// are named after SocialPrefix, such as social:facebook.
+const (
+	ChannelDirect = "direct"
+	ChannelSearch = "search"
+	ChannelEmail  = "email"
+	ChannelOther  = "other"
+
+	SocialPrefix = "social:"
+)
+
+// DefaultChannels maps referring hosts to their channel. A host also
+// matches its subdomains, and the most specific match wins.
+var DefaultChannels = map[string]string{
+	// Social networks, including their link shorteners and redirectors
+	"facebook.com":         SocialPrefix + "facebook",
+	"fb.com":               SocialPrefix + "facebook",
+	"fb.me":                SocialPrefix + "facebook",
+	"messenger.com":        SocialPrefix + "facebook",
+	"instagram.com":        SocialPrefix + "instagram",
+	"twitter.com":          SocialPrefix + "twitter",
+	"x.com":                SocialPrefix + "twitter",
+	"t.co":                 SocialPrefix + "twitter",
+	"linkedin.com":         SocialPrefix + "linkedin",
+	"lnkd.in":              SocialPrefix + "linkedin",
+	"reddit.com":           SocialPrefix + "reddit",
+	"youtube.com":          SocialPrefix + "youtube",
+	"youtu.be":             SocialPrefix + "youtube",
+	"tiktok.com":           SocialPrefix + "tiktok",
+	"pinterest.com":        SocialPrefix + "pinterest",
+	"pin.it":               SocialPrefix + "pinterest",
+	"threads.net":          SocialPrefix + "threads",
+	"bsky.app":             SocialPrefix + "bluesky",
+	"mastodon.social":      SocialPrefix + "mastodon",
+	"news.ycombinator.com": SocialPrefix + "hackernews",
+	"t.me":                 SocialPrefix + "telegram",
+	"web.telegram.org":     SocialPrefix + "telegram",
+	"web.whatsapp.com":     SocialPrefix + "whatsapp",
+	"wa.me":                SocialPrefix + "whatsapp",
+	"discord.com":          SocialPrefix + "discord",
+	"quora.com":            SocialPrefix + "quora",
+	"vk.com":               SocialPrefix + "vk",
+
+	// Webmail, which most desktop and mobile mail clients don't reveal
+	"mail.google.com":       ChannelEmail,
+	"outlook.live.com":      ChannelEmail,
+	"outlook.office.com":    ChannelEmail,
+	"outlook.office365.com": ChannelEmail,
+	"mail.yahoo.com":        ChannelEmail,
+	"mail.proton.me":        ChannelEmail,
+	"mail.aol.com":          ChannelEmail,
+	"icloud.com":            ChannelEmail,
+	"mail.yandex.ru":        ChannelEmail,
+	"mail.zoho.com":         ChannelEmail,
+	"fastmail.com":          ChannelEmail,
+
+	// Search engines with a single host; those with a host per country are
+	// in searchEngines
+	"duckduckgo.com":   ChannelSearch,
+	"search.brave.com": ChannelSearch,
+	"ecosia.org":       ChannelSearch,
+	"startpage.com":    ChannelSearch,
+	"qwant.com":        ChannelSearch,
+	"baidu.com":        ChannelSearch,
+	"naver.com":        ChannelSearch,
+	"kagi.com":         ChannelSearch,
+}
+
+// searchEngines are the names of search engines serving a host per country,
+// such as google.de, matched by any label but the top-level domain
+var searchEngines = map[string]bool{"google": true, "bing": true, "yahoo": true, "yandex": true}
+
+// Classifier classifies clicks into channels by their referring host
+type Classifier struct {
+	hosts map[string]string
+}
+
+// NewClassifier creates a Classifier using DefaultChannels, with hosts
+// mapped by overrides taking precedence
+func NewClassifier(overrides map[string]string) *Classifier {
+	hosts := make(map[string]string, len(DefaultChannels)+len(overrides))
+	for host, channel := range DefaultChannels {
+		hosts[host] = channel
+	}
+	for host, channel := range overrides {
+		hosts[strings.ToLower(host)] = channel
+	}
+	return &Classifier{hosts: hosts}
+}
+
+// LoadChannels reads a JSON object mapping referring hosts to channels,
+// such as {"intranet.example.com": "other", "mastodon.example": "social:mastodon"}
+func LoadChannels(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var channels map[string]string
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, err
+	}
+	for host, channel := range channels {
+		if !validChannel(channel) {
+			return nil, fmt.Errorf("invalid channel %q for %s", channel, host)
+		}
+	}
+	return channels, nil
+}
+
+// validChannel reports whether a channel is one of the fixed channels or
+// names a social network
+func validChannel(channel string) bool {
+	switch channel {
+	case ChannelDirect, ChannelSearch, ChannelEmail, ChannelOther:
+		return true
+	}
+	network, ok := strings.CutPrefix(channel, SocialPrefix)
+	return ok && network != "" && !strings.ContainsAny(network, " :")
+}
+
+// Classify returns the channel of a referring host, as recorded in
+// Click.Referrer: direct when there is none, and other when it's unknown
+func (c *Classifier) Classify(referrer string) string {
+	if referrer == "" {
+		return ChannelDirect
+	}
+	host := strings.ToLower(referrer)
+	for h := host; h != ""; {
+		if channel, ok := c.hosts[h]; ok {
+			return channel
+		}
+		_, parent, ok := strings.Cut(h, ".")
+		if !ok {
+			break
+		}
+		h = parent
+	}
+
+	// Match google.com, google.co.uk and www.bing.com alike by the labels
+	// before the last, which is at least the top-level domain
+	labels := strings.Split(host, ".")
+	for _, label := range labels[:len(labels)-1] {
+		if searchEngines[label] {
+			return ChannelSearch
+		}
+	}
+	return ChannelOther
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifier_Classify(t *testing.T) {
+	c := NewClassifier(map[string]string{"Intranet.Example.com": ChannelEmail, "facebook.com": ChannelOther})
+	for referrer, want := range map[string]string{
+		"":                     ChannelDirect,
+		"t.co":                 "social:twitter",
+		"l.instagram.com":      "social:instagram",
+		"news.ycombinator.com": "social:hackernews",
+		"mail.google.com":      ChannelEmail,
+		"google.com":           ChannelSearch,
+		"google.co.uk":         ChannelSearch,
+		"bing.com":             ChannelSearch,
+		"duckduckgo.com":       ChannelSearch,
+		"mail.yahoo.com":       ChannelEmail,
+		"intranet.example.com": ChannelEmail,
+		"m.facebook.com":       ChannelOther,
+		"news.example":         ChannelOther,
+		"google":               ChannelOther,
+	} {
+		assert.Equal(t, want, c.Classify(referrer), referrer)
+	}
+}
+
+func TestLoadChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "channels.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"mastodon.example": "social:mastodon", "wiki.example": "other"}`), 0o600))
+	channels, err := LoadChannels(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"mastodon.example": "social:mastodon", "wiki.example": "other"}, channels)
+
+	for _, invalid := range []string{`{"a.example": "paid"}`, `{"a.example": "social:"}`, `["a.example"]`} {
+		require.NoError(t, os.WriteFile(path, []byte(invalid), 0o600))
+		_, err := LoadChannels(path)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// DefaultSaltRotation is how long a hashing salt is used by default
+const DefaultSaltRotation = 24 * time.Hour
+
+// IPPrivacy is how much of a visitor's IP address is recorded with clicks
+type IPPrivacy string
+
+const (
+	// IPFull records the address as is
+	IPFull IPPrivacy = "full"
+
+	// IPTruncated records the /24 network of IPv4 and the /48 network of
+	// IPv6 addresses
+	IPTruncated IPPrivacy = "truncated"
+
+	// IPHashed records a keyed hash of the address. The key rotates, so a
+	// visitor can only be recognized within one rotation period.
+	IPHashed IPPrivacy = "hashed"
+
+	// IPNone records no address at all
+	IPNone IPPrivacy = "none"
+)
+
+// ErrUnknownIPPrivacy is returned for unknown IP privacy levels
+var ErrUnknownIPPrivacy = errors.New("ip privacy must be full, truncated, hashed or none")
+
+// ParseIPPrivacy parses an IP privacy level
+func ParseIPPrivacy(s string) (IPPrivacy, error) {
+	switch p := IPPrivacy(s); p {
+	case IPFull, IPTruncated, IPHashed, IPNone:
+		return p, nil
+	}
+	return "", ErrUnknownIPPrivacy
+}
+
+// Anonymizer applies an IP privacy level to visitor addresses
+type Anonymizer struct {
+	level    IPPrivacy
+	rotation time.Duration
+	secret   []byte
+
+	mu     sync.Mutex
+	period int64
+	salt   []byte
+}
+
+// NewAnonymizer creates a new Anonymizer. Hashing salts are derived from
+// secret and the current rotation period, so instances sharing a secret
+// hash alike; without a secret each instance picks a random one.
+func NewAnonymizer(level IPPrivacy, rotation time.Duration, secret string) *Anonymizer {
+	if rotation <= 0 {
+		rotation = DefaultSaltRotation
+	}
+	a := &Anonymizer{level: level, rotation: rotation, secret: []byte(secret), period: -1}
+	if len(a.secret) == 0 {
+		a.secret = make([]byte, 32)
+		rand.Read(a.secret)
+	}
+	return a
+}
+
+// Anonymize returns the address to record for a visitor. Unparseable
+// addresses are never recorded.
+func (a *Anonymizer) Anonymize(ip string) string {
+	switch a.level {
+	case IPFull:
+		if _, err := netip.ParseAddr(ip); err != nil {
+			return ""
+		}
+		return ip
+	case IPTruncated:
+		return TruncateIP(ip)
+	case IPHashed:
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return ""
+		}
+		mac := hmac.New(sha256.New, a.currentSalt(time.Now()))
+		mac.Write([]byte(addr.Unmap().String()))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	return ""
+}
+
+// currentSalt returns the salt of the rotation period containing now
+func (a *Anonymizer) currentSalt(now time.Time) []byte {
+	period := now.UnixNano() / int64(a.rotation)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if period != a.period {
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], uint64(period))
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write(buf[:])
+		a.salt = mac.Sum(nil)
+		a.period = period
+	}
+	return a.salt
+}
+
+// TruncateIP zeroes the host part of an address, keeping the /24 network of
+// IPv4 addresses and the /48 network of IPv6 addresses, so stored clicks
+// can still be told apart by network but no longer identify a visitor.
+// Unparseable input yields an empty string.
+func TruncateIP(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.77":        "203.0.113.0",
+		"::ffff:203.0.113.77": "203.0.113.0",
+		"2001:db8:abcd:12::1": "2001:db8:abcd::",
+		"fe80::1%eth0":        "fe80::",
+		"not an ip":           "",
+		"":                    "",
+	}
+	for ip, expected := range tests {
+		assert.Equal(t, expected, TruncateIP(ip), ip)
+	}
+}
+
+func TestAnonymizer(t *testing.T) {
+	assert.Equal(t, "203.0.113.77", NewAnonymizer(IPFull, 0, "").Anonymize("203.0.113.77"))
+	assert.Equal(t, "", NewAnonymizer(IPFull, 0, "").Anonymize("forged"))
+	assert.Equal(t, "203.0.113.0", NewAnonymizer(IPTruncated, 0, "").Anonymize("203.0.113.77"))
+	assert.Equal(t, "", NewAnonymizer(IPNone, 0, "").Anonymize("203.0.113.77"))
+
+	// Hashes are stable within a period and across instances sharing a secret
+	a := NewAnonymizer(IPHashed, time.Hour, "secret")
+	hashed := a.Anonymize("203.0.113.77")
+	assert.Len(t, hashed, 32)
+	assert.NotContains(t, hashed, "203")
+	assert.Equal(t, hashed, a.Anonymize("::ffff:203.0.113.77"))
+	assert.Equal(t, hashed, NewAnonymizer(IPHashed, time.Hour, "secret").Anonymize("203.0.113.77"))
+	assert.NotEqual(t, hashed, a.Anonymize("203.0.113.78"))
+	assert.NotEqual(t, hashed, NewAnonymizer(IPHashed, time.Hour, "other").Anonymize("203.0.113.77"))
+
+	// but the salt changes every period
+	now := time.Now()
+	assert.Equal(t, a.currentSalt(now), a.currentSalt(now))
+	assert.NotEqual(t, a.currentSalt(now), a.currentSalt(now.Add(time.Hour)))
+}
+
+func TestParseIPPrivacy(t *testing.T) {
+	for _, level := range []IPPrivacy{IPFull, IPTruncated, IPHashed, IPNone} {
+		parsed, err := ParseIPPrivacy(string(level))
+		require.NoError(t, err)
+		assert.Equal(t, level, parsed)
+	}
+	_, err := ParseIPPrivacy("partial")
+	assert.Equal(t, ErrUnknownIPPrivacy, err)
+}
+
+func TestRecorder_AnonymizesIPs(t *testing.T) {
+	sink := &ipSink{}
+	r := NewRecorder(sink, 10)
+	defer r.Close()
+
+	r.Record(Click{Key: "k", IP: "203.0.113.77"})
+	r.UseAnonymizer(NewAnonymizer(IPNone, 0, ""))
+	r.Record(Click{Key: "k", IP: "203.0.113.77"})
+	require.NoError(t, r.Flush(context.Background()))
+	assert.Equal(t, []string{"203.0.113.0", ""}, sink.ips)
+}
+
+// ipSink keeps the IPs of recorded clicks
+type ipSink struct {
+	mu  sync.Mutex
+	ips []string
+}
+
+func (s *ipSink) RecordClick(_ context.Context, click Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ips = append(s.ips, click.IP)
+	return nil
+}
@@ -0,0 +1,157 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
+)
+
+const (
+	// DefaultQueueSize is the default number of clicks buffered before new ones are dropped
+	DefaultQueueSize = 10000
+
+	// QueueName identifies the click queue in the dropped items metric
+	QueueName = "analytics"
+)
+
+// Click is a single redirect served for a short link
+type Click struct {
+	Key       string
+	Workspace string
+	Owner     string
+	Time      time.Time
+	Variant   string
+
+	// Country is the visitor's ISO 3166-1 alpha-2 country code and Referrer
+	// the host of the referring page, when known
+	Country  string
+	Referrer string
+
+	// Channel is the kind of source the visitor came from, classified from
+	// the referrer when the click is recorded
+	Channel string
+
+	// Device, OS and Browser are parsed from the visitor's User-Agent
+	// header, when known. Unlike UserAgent they are always set.
+	Device  string
+	OS      string
+	Browser string
+
+	// IP and UserAgent identify the visitor. They are only set when visits
+	// are recorded, and IP is anonymized by the recorder's privacy level.
+	IP        string
+	UserAgent string
+
+	// NoTrack is set when the visitor asked not to be tracked. Such clicks
+	// only update aggregate counters.
+	NoTrack bool
+}
+
+// Sink persists recorded clicks
+type Sink interface {
+	RecordClick(ctx context.Context, click Click) error
+}
+
+// Recorder records clicks asynchronously so redirects never wait on analytics
+type Recorder struct {
+	sink       Sink
+	exports    []Sink
+	queue      *queue.Queue[Click]
+	anonymizer *Anonymizer
+	classifier *Classifier
+	onStored   []func(Click)
+}
+
+// NewRecorder creates a new Recorder and starts its worker
+func NewRecorder(sink Sink, queueSize int) *Recorder {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	r := &Recorder{sink: sink, anonymizer: NewAnonymizer(IPTruncated, 0, ""), classifier: NewClassifier(nil)}
+	r.queue = queue.New(QueueName, queueSize, r.write)
+	return r
+}
+
+// UseAnonymizer sets how visitor IPs are anonymized before clicks are
+// queued. Recorders truncate them by default. It must be called before
+// clicks are recorded.
+func (r *Recorder) UseAnonymizer(a *Anonymizer) {
+	r.anonymizer = a
+}
+
+// UseClassifier sets how clicks are classified into channels by their
+// referrer. Recorders use DefaultChannels by default. It must be called
+// before clicks are recorded.
+func (r *Recorder) UseClassifier(c *Classifier) {
+	r.classifier = c
+}
+
+// AddSink adds a sink, such as an analytics database, every click is
+// exported to after the main sink. Its failures are logged and don't affect
+// the main sink. It must be called before clicks are recorded.
+func (r *Recorder) AddSink(sink Sink) {
+	r.exports = append(r.exports, sink)
+}
+
+// OnStored adds a function called with each click once the sink stored it,
+// such as usage metering. It must be called before clicks are recorded.
+func (r *Recorder) OnStored(fn func(Click)) {
+	r.onStored = append(r.onStored, fn)
+}
+
+// Record queues a click, dropping it if the queue is full. The visitor's IP
+// is anonymized first, so full addresses never reach the sink unless the
+// privacy level allows it, and the click is classified into its channel.
+func (r *Recorder) Record(click Click) {
+	if click.Channel == "" {
+		click.Channel = r.classifier.Classify(click.Referrer)
+	}
+	if click.IP != "" {
+		click.IP = r.anonymizer.Anonymize(click.IP)
+	}
+	if !r.queue.Push(click) {
+		log.Printf("analytics queue full or closed, dropping click for %s", click.Key)
+	}
+}
+
+// Flush waits until queued clicks have been written or ctx is done
+func (r *Recorder) Flush(ctx context.Context) error {
+	return r.queue.Flush(ctx)
+}
+
+// Shutdown stops accepting clicks and waits for queued ones to be written
+// until ctx is done
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	return r.queue.Shutdown(ctx)
+}
+
+// Close stops accepting clicks and waits for queued ones to be written
+func (r *Recorder) Close() {
+	r.queue.Close()
+}
+
+// write stores a single click in the sink, then exports it to the added sinks
+func (r *Recorder) write(click Click) {
+	if err := store(r.sink, click); err != nil {
+		log.Printf("failed to record click for %s: %v", click.Key, err)
+	} else {
+		for _, fn := range r.onStored {
+			fn(click)
+		}
+	}
+	for _, sink := range r.exports {
+		if err := store(sink, click); err != nil {
+			log.Printf("failed to export click for %s: %v", click.Key, err)
+		}
+	}
+}
+
+// store records a click in a sink, allowing it a few seconds
+func store(sink Sink, click Click) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sink.RecordClick(ctx, click)
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink counts recorded clicks per key
+type memorySink struct {
+	mu     sync.Mutex
+	clicks map[string]int
+	err    error
+}
+
+func (m *memorySink) RecordClick(_ context.Context, click Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if m.clicks == nil {
+		m.clicks = make(map[string]int)
+	}
+	m.clicks[click.Key]++
+	return nil
+}
+
+func TestRecorder_Record(t *testing.T) {
+	sink := &memorySink{}
+	r := NewRecorder(sink, 100)
+
+	for i := 0; i < 10; i++ {
+		r.Record(Click{Key: "aB1cD2eF", Time: time.Now()})
+	}
+	r.Record(Click{Key: "gH3iJ4kL", Time: time.Now()})
+
+	// Close drains the queue
+	r.Close()
+	assert.Equal(t, map[string]int{"aB1cD2eF": 10, "gH3iJ4kL": 1}, sink.clicks)
+}
+
+func TestRecorder_SinkError(t *testing.T) {
+	sink := &memorySink{err: errors.New("unavailable")}
+	r := NewRecorder(sink, 0)
+
+	r.Record(Click{Key: "aB1cD2eF"})
+	r.Close()
+	assert.Empty(t, sink.clicks)
+}
+
+func TestRecorder_Flush(t *testing.T) {
+	sink := &memorySink{}
+	r := NewRecorder(sink, 100)
+	defer r.Close()
+
+	for i := 0; i < 5; i++ {
+		r.Record(Click{Key: "aB1cD2eF", Time: time.Now()})
+	}
+	require.NoError(t, r.Flush(context.Background()))
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, 5, sink.clicks["aB1cD2eF"])
+}
+
+func TestRecorder_AddSink(t *testing.T) {
+	sink := &memorySink{err: errors.New("unavailable")}
+	export := &memorySink{}
+	r := NewRecorder(sink, 100)
+	r.AddSink(export)
+	var stored int
+	r.OnStored(func(Click) { stored++ })
+
+	// Clicks are exported even when the main sink fails
+	r.Record(Click{Key: "aB1cD2eF"})
+	r.Close()
+	assert.Equal(t, map[string]int{"aB1cD2eF": 1}, export.clicks)
+	assert.Zero(t, stored)
+}
@@ -0,0 +1,135 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultInterval is the default time between archive runs
+	DefaultInterval = time.Hour
+
+	// DefaultBatchSize is the default number of click events read at once
+	DefaultBatchSize = 10000
+
+	// DefaultPrefix is the default prefix of archived object names
+	DefaultPrefix = "clicks/"
+
+	// leaseTTL is how long an instance may archive before another takes over
+	leaseTTL = 10 * time.Minute
+)
+
+// Bucket stores objects, such as an S3 or GCS bucket
+type Bucket interface {
+	Put(ctx context.Context, name, contentType string, body []byte) error
+}
+
+// Archiver periodically uploads raw click events to a bucket as gzipped
+// JSON Lines, one object per day and batch under dt=YYYY-MM-DD/, so they
+// can be trimmed from Redis while their history stays available for batch
+// analytics
+type Archiver struct {
+	store  storage.ArchiveStore
+	bucket Bucket
+	prefix string
+	batch  int
+}
+
+// New creates an Archiver uploading click events under prefix
+func New(store storage.ArchiveStore, bucket Bucket, prefix string, batch int) *Archiver {
+	if batch <= 0 {
+		batch = DefaultBatchSize
+	}
+	return &Archiver{store: store, bucket: bucket, prefix: prefix, batch: batch}
+}
+
+// Run archives click events every interval until ctx is done
+func (a *Archiver) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := a.Archive(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("click archive failed: %v", err)
+			}
+		}
+	}
+}
+
+// Archive uploads the click events recorded since the last run, returning
+// how many it archived. Only one instance archives at a time; the others
+// return 0. Events are marked archived batch by batch once uploaded, so a
+// failure retries only the failed batch on the next run.
+func (a *Archiver) Archive(ctx context.Context) (int, error) {
+	token, err := a.store.ClaimArchive(ctx, leaseTTL)
+	if err != nil || token == "" {
+		return 0, err
+	}
+	defer func() {
+		if err := a.store.ReleaseArchive(context.WithoutCancel(ctx), token); err != nil {
+			log.Printf("failed to release click archive: %v", err)
+		}
+	}()
+
+	total := 0
+	for {
+		events, err := a.store.UnarchivedClickEvents(ctx, a.batch)
+		if err != nil || len(events) == 0 {
+			return total, err
+		}
+		if err := a.upload(ctx, events); err != nil {
+			return total, err
+		}
+		if err := a.store.MarkArchived(ctx, events[len(events)-1].ID); err != nil {
+			return total, err
+		}
+		total += len(events)
+		if len(events) < a.batch {
+			return total, nil
+		}
+	}
+}
+
+// upload writes a batch of events as one object per day, named after the
+// first event in it so a batch retried after a failure replaces its
+// earlier upload
+func (a *Archiver) upload(ctx context.Context, events []storage.ClickEvent) error {
+	for len(events) > 0 {
+		day := events[0].Time.Format("2006-01-02")
+		n := 1
+		for n < len(events) && events[n].Time.Format("2006-01-02") == day {
+			n++
+		}
+
+		var body bytes.Buffer
+		zw := gzip.NewWriter(&body)
+		enc := json.NewEncoder(zw)
+		for _, e := range events[:n] {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		name := a.prefix + "dt=" + day + "/" + events[0].ID + ".jsonl.gz"
+		if err := a.bucket.Put(ctx, name, "application/x-ndjson", body.Bytes()); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
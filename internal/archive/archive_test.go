@@ -0,0 +1,129 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// memoryStore serves click events from memory
+type memoryStore struct {
+	events   []storage.ClickEvent
+	archived int
+	claimed  bool
+}
+
+func (m *memoryStore) ClaimArchive(context.Context, time.Duration) (string, error) {
+	if m.claimed {
+		return "", nil
+	}
+	m.claimed = true
+	return "token", nil
+}
+
+func (m *memoryStore) ReleaseArchive(context.Context, string) error {
+	m.claimed = false
+	return nil
+}
+
+func (m *memoryStore) UnarchivedClickEvents(_ context.Context, n int) ([]storage.ClickEvent, error) {
+	return m.events[m.archived:min(m.archived+n, len(m.events))], nil
+}
+
+func (m *memoryStore) MarkArchived(_ context.Context, id string) error {
+	for i, e := range m.events {
+		if e.ID == id {
+			m.archived = i + 1
+		}
+	}
+	return nil
+}
+
+// memoryBucket keeps uploaded objects, failing while err is set
+type memoryBucket struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (m *memoryBucket) Put(_ context.Context, name, _ string, body []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.objects == nil {
+		m.objects = make(map[string][]byte)
+	}
+	m.objects[name] = body
+	return nil
+}
+
+// lines decompresses an object and decodes its events
+func lines(t *testing.T, body []byte) []storage.ClickEvent {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	var events []storage.ClickEvent
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var e storage.ClickEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestArchiver_Archive(t *testing.T) {
+	midnight := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	store := &memoryStore{}
+	for i := 0; i < 5; i++ {
+		at := midnight.Add(time.Duration(i-2) * time.Hour)
+		store.events = append(store.events, storage.ClickEvent{ID: fmt.Sprintf("%d-0", at.UnixMilli()), Key: "aB1cD2eF", Time: at})
+	}
+	bucket := &memoryBucket{}
+	a := New(store, bucket, "clicks/", 3)
+	ctx := context.Background()
+
+	// A failed upload archives nothing and is retried on the next run
+	bucket.err = errors.New("unavailable")
+	n, err := a.Archive(ctx)
+	assert.Error(t, err)
+	assert.Zero(t, n)
+	assert.False(t, store.claimed)
+
+	bucket.err = nil
+	n, err = a.Archive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, 5, store.archived)
+
+	// Batches are split by day, each object named after its first event
+	names := make([]string, 0, len(bucket.objects))
+	for name := range bucket.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{
+		"clicks/dt=2024-03-01/" + store.events[0].ID + ".jsonl.gz",
+		"clicks/dt=2024-03-02/" + store.events[2].ID + ".jsonl.gz",
+		"clicks/dt=2024-03-02/" + store.events[3].ID + ".jsonl.gz",
+	}, names)
+	assert.Equal(t, store.events[:2], lines(t, bucket.objects[names[0]]))
+	assert.Equal(t, store.events[3:], lines(t, bucket.objects[names[2]]))
+
+	// Instances that can't claim the archive leave it alone
+	store.events = append(store.events, storage.ClickEvent{ID: "9999999999999-0", Time: midnight})
+	store.claimed = true
+	n, err = a.Archive(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
@@ -0,0 +1,461 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// SessionCookie is the name of the dashboard session cookie
+	SessionCookie = "session"
+
+	// CSRFHeader carries the session's CSRF token on state-changing requests
+	CSRFHeader = "X-CSRF-Token"
+
+	// APIKeyHeader carries an API key for programmatic clients
+	APIKeyHeader = "X-API-Key"
+
+	// DefaultSessionTTL is the default lifetime of a dashboard session
+	DefaultSessionTTL = 12 * time.Hour
+
+	// principalKey is the gin context key holding the authenticated principal
+	principalKey = "auth.principal"
+)
+
+// workspacePattern restricts workspace names to characters safe in storage keys
+var workspacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Authentication methods recorded on a Principal
+const (
+	MethodSession = "session"
+	MethodAPIKey  = "api_key"
+	MethodJWT     = "jwt"
+)
+
+// Role is the level of access a principal has. Each role includes the
+// permissions of the roles below it.
+type Role string
+
+// Roles, from least to most privileged
+const (
+	// RoleViewer may read links and their statistics
+	RoleViewer Role = "viewer"
+
+	// RoleEditor may also create links and manage the links it owns
+	RoleEditor Role = "editor"
+
+	// RoleAdmin may manage every link and use operator endpoints
+	RoleAdmin Role = "admin"
+)
+
+// roleRanks orders the roles
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// Errors returned by the authentication manager
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrCSRFMismatch       = errors.New("missing or invalid csrf token")
+	ErrUnknownRole        = errors.New("unknown role")
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject   string
+	Method    string
+	SessionID string
+	Workspace string
+	Role      Role
+	Admin     bool
+}
+
+// HasRole reports whether the principal's role includes the given role
+func (p *Principal) HasRole(role Role) bool {
+	return roleRanks[p.Role] >= roleRanks[role]
+}
+
+// IsBrowser reports whether the principal authenticated with a dashboard session
+func (p *Principal) IsBrowser() bool {
+	return p.Method == MethodSession
+}
+
+// Config holds the authentication settings
+type Config struct {
+	// Users maps dashboard usernames to bcrypt password hashes
+	Users map[string]string
+
+	// APIKeys maps API keys to the subject they authenticate as
+	APIKeys map[string]string
+
+	// JWT, if set, accepts bearer tokens from an OIDC provider, mapping
+	// the configured subject claim to link ownership
+	JWT *JWTVerifier
+
+	// Admins lists the subjects allowed to manage every link. They have
+	// the admin role whatever Roles says.
+	Admins map[string]bool
+
+	// Roles maps subjects to their role
+	Roles map[string]Role
+
+	// DefaultRole is the role of subjects missing from Roles
+	DefaultRole Role
+
+	// Workspaces maps subjects to the workspace their links belong to.
+	// Subjects without a workspace only manage the links they own.
+	Workspaces map[string]string
+
+	// SessionTTL is the lifetime of a dashboard session
+	SessionTTL time.Duration
+
+	// SecureCookie marks the session cookie as HTTPS-only
+	SecureCookie bool
+
+	// Required rejects unauthenticated requests to protected routes
+	Required bool
+}
+
+// Manager authenticates API clients and dashboard sessions
+type Manager struct {
+	sessions storage.SessionStore
+	config   Config
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+// NewManager creates a new Manager instance
+func NewManager(sessions storage.SessionStore, config Config) *Manager {
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultSessionTTL
+	}
+	if config.DefaultRole == "" {
+		config.DefaultRole = RoleEditor
+	}
+	return &Manager{
+		sessions: sessions,
+		config:   config,
+	}
+}
+
+// Login checks dashboard credentials and starts a new session
+func (m *Manager) Login(ctx context.Context, username, password string) (*storage.Session, error) {
+	hash, ok := m.config.Users[username]
+	if !ok {
+		// Compare anyway so unknown users take as long as wrong passwords
+		m.dummyOnce.Do(func() {
+			m.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(m.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	sess := &storage.Session{
+		ID:        id,
+		Subject:   username,
+		CSRFToken: csrf,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.config.SessionTTL),
+	}
+	if err := m.sessions.CreateSession(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Logout ends a dashboard session
+func (m *Manager) Logout(ctx context.Context, sessionID string) error {
+	return m.sessions.DeleteSession(ctx, sessionID)
+}
+
+// Session returns the dashboard session with the given ID
+func (m *Manager) Session(ctx context.Context, sessionID string) (*storage.Session, error) {
+	return m.sessions.GetSession(ctx, sessionID)
+}
+
+// SetCookie writes the session cookie for a new session
+func (m *Manager) SetCookie(c *gin.Context, sess *storage.Session) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookie, sess.ID, int(time.Until(sess.ExpiresAt).Seconds()), "/", "", m.config.SecureCookie, true)
+}
+
+// ClearCookie removes the session cookie
+func (m *Manager) ClearCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookie, "", -1, "/", "", m.config.SecureCookie, true)
+}
+
+// Middleware authenticates the request. API clients send an API key or an
+// OIDC bearer token and are exempt from CSRF checks; browsers send the
+// session cookie and must echo the session's CSRF token on state-changing
+// requests. Unauthenticated requests pass through anonymously unless
+// authentication is required, and so do requests with a session cookie
+// that expired or was logged out, which is cleared.
+func (m *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := m.authenticate(c)
+		switch err {
+		case nil:
+		case storage.ErrSessionNotFound:
+			m.ClearCookie(c)
+		case ErrCSRFMismatch:
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		case ErrInvalidAPIKey, ErrInvalidToken:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate request"})
+			return
+		}
+
+		if principal == nil && m.config.Required {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		if principal != nil {
+			c.Set(principalKey, principal)
+		}
+		c.Next()
+	}
+}
+
+// authenticate resolves the principal for a request, or nil if anonymous
+func (m *Manager) authenticate(c *gin.Context) (*Principal, error) {
+	if key := apiKey(c.Request); key != "" {
+		if m.config.JWT != nil && isJWT(key) {
+			subject, err := m.config.JWT.Verify(c.Request.Context(), key)
+			if err != nil {
+				return nil, err
+			}
+			return m.principal(subject, MethodJWT, ""), nil
+		}
+		for candidate, subject := range m.config.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+				return m.principal(subject, MethodAPIKey, ""), nil
+			}
+		}
+		return nil, ErrInvalidAPIKey
+	}
+
+	sessionID, err := c.Cookie(SessionCookie)
+	if err != nil || sessionID == "" {
+		return nil, nil
+	}
+
+	sess, err := m.sessions.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSafeMethod(c.Request.Method) {
+		token := c.GetHeader(CSRFHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(sess.CSRFToken)) != 1 {
+			return nil, ErrCSRFMismatch
+		}
+	}
+
+	return m.principal(sess.Subject, MethodSession, sess.ID), nil
+}
+
+// principal builds the principal for an authenticated subject
+func (m *Manager) principal(subject, method, sessionID string) *Principal {
+	role := m.role(subject)
+	return &Principal{
+		Subject:   subject,
+		Method:    method,
+		SessionID: sessionID,
+		Workspace: m.config.Workspaces[subject],
+		Role:      role,
+		Admin:     role == RoleAdmin,
+	}
+}
+
+// role returns the role of an authenticated subject
+func (m *Manager) role(subject string) Role {
+	if m.config.Admins[subject] {
+		return RoleAdmin
+	}
+	if role, ok := m.config.Roles[subject]; ok {
+		return role
+	}
+	return m.config.DefaultRole
+}
+
+// PrincipalFrom returns the authenticated principal for the request, if any
+func PrincipalFrom(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+	return nil
+}
+
+// RequirePrincipal rejects requests without an authenticated principal
+func RequirePrincipal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if PrincipalFrom(c) == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests without an authenticated admin principal
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if principal == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		if !principal.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Only an admin may do this"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole rejects principals whose role doesn't include the given role.
+// Anonymous requests, allowed when authentication isn't required, pass
+// through; handlers let them create links but not manage existing ones.
+func RequireRole(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if principal != nil && !principal.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Requires the " + string(role) + " role"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ParseAdmins parses a comma-separated list of admin subjects
+func ParseAdmins(spec string) map[string]bool {
+	admins := make(map[string]bool)
+	for _, subject := range strings.Split(spec, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			admins[subject] = true
+		}
+	}
+	return admins
+}
+
+// ParseRole parses a role name
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := roleRanks[role]; !ok {
+		return "", ErrUnknownRole
+	}
+	return role, nil
+}
+
+// ParseRoles parses a comma-separated list of subject:role pairs. Pairs
+// with unknown roles are ignored.
+func ParseRoles(spec string) map[string]Role {
+	roles := make(map[string]Role)
+	for subject, name := range parsePairs(spec) {
+		if role, err := ParseRole(name); err == nil {
+			roles[subject] = role
+		}
+	}
+	return roles
+}
+
+// ParseUsers parses a comma-separated list of user:bcrypt-hash pairs
+func ParseUsers(spec string) map[string]string {
+	return parsePairs(spec)
+}
+
+// ParseAPIKeys parses a comma-separated list of key:subject pairs
+func ParseAPIKeys(spec string) map[string]string {
+	return parsePairs(spec)
+}
+
+// ParseWorkspaces parses a comma-separated list of subject:workspace pairs.
+// Workspace names must be lowercase letters, digits, '-' or '_'; pairs with
+// other names are ignored.
+func ParseWorkspaces(spec string) map[string]string {
+	workspaces := parsePairs(spec)
+	for subject, workspace := range workspaces {
+		if !ValidWorkspace(workspace) {
+			delete(workspaces, subject)
+		}
+	}
+	return workspaces
+}
+
+// ValidWorkspace reports whether name is a valid workspace name
+func ValidWorkspace(name string) bool {
+	return workspacePattern.MatchString(name)
+}
+
+// parsePairs parses a comma-separated list of name:value pairs
+func parsePairs(spec string) map[string]string {
+	pairs := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || value == "" {
+			continue
+		}
+		pairs[name] = value
+	}
+	return pairs
+}
+
+// apiKey extracts an API key from the request headers
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return ""
+}
+
+// isSafeMethod reports whether the HTTP method cannot change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// randomToken generates a random URL-safe token
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
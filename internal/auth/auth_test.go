@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// memorySessions is an in-memory SessionStore for tests
+type memorySessions struct {
+	mu       sync.Mutex
+	sessions map[string]storage.Session
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{sessions: make(map[string]storage.Session)}
+}
+
+func (m *memorySessions) CreateSession(_ context.Context, s *storage.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = *s
+	return nil
+}
+
+func (m *memorySessions) GetSession(_ context.Context, id string) (*storage.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, storage.ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+func (m *memorySessions) DeleteSession(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func setupManager(t *testing.T, required bool) (*Manager, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	m := NewManager(newMemorySessions(), Config{
+		Users:    map[string]string{"alice": string(hash)},
+		APIKeys:  map[string]string{"test-key": "integration"},
+		Required: required,
+	})
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	handler := func(c *gin.Context) {
+		subject, method := "", ""
+		if p := PrincipalFrom(c); p != nil {
+			subject, method = p.Subject, p.Method
+		}
+		c.String(http.StatusOK, subject+"|"+method)
+	}
+	router.GET("/resource", handler)
+	router.POST("/resource", handler)
+
+	return m, router
+}
+
+func TestManager_Login(t *testing.T) {
+	m, _ := setupManager(t, false)
+	ctx := context.Background()
+
+	sess, err := m.Login(ctx, "alice", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", sess.Subject)
+	assert.NotEmpty(t, sess.ID)
+	assert.NotEmpty(t, sess.CSRFToken)
+	assert.NotEqual(t, sess.ID, sess.CSRFToken)
+
+	_, err = m.Login(ctx, "alice", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = m.Login(ctx, "mallory", "secret")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	require.NoError(t, m.Logout(ctx, sess.ID))
+	_, err = m.Session(ctx, sess.ID)
+	assert.Equal(t, storage.ErrSessionNotFound, err)
+}
+
+func TestManager_Middleware(t *testing.T) {
+	m, router := setupManager(t, false)
+	sess, err := m.Login(context.Background(), "alice", "secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		headers        map[string]string
+		cookie         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Anonymous", method: http.MethodGet, expectedStatus: http.StatusOK, expectedBody: "|"},
+		{name: "API key header", method: http.MethodPost, headers: map[string]string{APIKeyHeader: "test-key"}, expectedStatus: http.StatusOK, expectedBody: "integration|api_key"},
+		{name: "API key bearer", method: http.MethodPost, headers: map[string]string{"Authorization": "Bearer test-key"}, expectedStatus: http.StatusOK, expectedBody: "integration|api_key"},
+		{name: "Invalid API key", method: http.MethodGet, headers: map[string]string{APIKeyHeader: "nope"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Session safe method", method: http.MethodGet, cookie: sess.ID, expectedStatus: http.StatusOK, expectedBody: "alice|session"},
+		{name: "Session without CSRF token", method: http.MethodPost, cookie: sess.ID, expectedStatus: http.StatusForbidden},
+		{name: "Session with wrong CSRF token", method: http.MethodPost, cookie: sess.ID, headers: map[string]string{CSRFHeader: "wrong"}, expectedStatus: http.StatusForbidden},
+		{name: "Session with CSRF token", method: http.MethodPost, cookie: sess.ID, headers: map[string]string{CSRFHeader: sess.CSRFToken}, expectedStatus: http.StatusOK, expectedBody: "alice|session"},
+		{name: "Unknown session", method: http.MethodGet, cookie: "expired", expectedStatus: http.StatusOK, expectedBody: "|"},
+		{name: "Unknown session changing state", method: http.MethodPost, cookie: "expired", expectedStatus: http.StatusOK, expectedBody: "|"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/resource", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+			if tt.cookie == "expired" {
+				// The stale cookie is cleared
+				cookies := w.Result().Cookies()
+				require.Len(t, cookies, 1)
+				assert.Equal(t, SessionCookie, cookies[0].Name)
+				assert.Negative(t, cookies[0].MaxAge)
+			}
+		})
+	}
+}
+
+func TestManager_MiddlewareRequired(t *testing.T) {
+	_, router := setupManager(t, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: "expired"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set(APIKeyHeader, "test-key")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestParsePairs(t *testing.T) {
+	pairs := ParseAPIKeys(" key1:alice, key2:bob,invalid,:empty,novalue: ")
+	assert.Equal(t, map[string]string{"key1": "alice", "key2": "bob"}, pairs)
+	assert.Empty(t, ParseUsers(""))
+
+	users := ParseUsers("alice:$2a$10$abc")
+	assert.True(t, strings.HasPrefix(users["alice"], "$2a$"))
+}
+
+func TestManager_Workspace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewManager(newMemorySessions(), Config{
+		APIKeys:    map[string]string{"acme-key": "ci", "solo-key": "bob"},
+		Workspaces: map[string]string{"ci": "acme"},
+	})
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/resource", func(c *gin.Context) {
+		c.String(http.StatusOK, PrincipalFrom(c).Workspace)
+	})
+
+	for key, expected := range map[string]string{"acme-key": "acme", "solo-key": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+		req.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, expected, w.Body.String())
+	}
+}
+
+func TestParseWorkspaces(t *testing.T) {
+	workspaces := ParseWorkspaces("alice:acme, ci:acme,bob:Bad Name,eve:-dash,carol:team_2")
+	assert.Equal(t, map[string]string{"alice": "acme", "ci": "acme", "carol": "team_2"}, workspaces)
+	assert.Empty(t, ParseWorkspaces(""))
+}
+
+func TestManager_Roles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewManager(newMemorySessions(), Config{
+		APIKeys: map[string]string{"viewer-key": "vera", "editor-key": "ed", "admin-key": "root", "legacy-key": "ops"},
+		Roles:   map[string]Role{"vera": RoleViewer, "root": RoleAdmin, "ops": RoleViewer},
+		Admins:  map[string]bool{"ops": true},
+	})
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/resource", RequireRole(RoleViewer), func(c *gin.Context) {
+		var role Role
+		if p := PrincipalFrom(c); p != nil {
+			role = p.Role
+		}
+		c.String(http.StatusOK, string(role))
+	})
+	router.POST("/resource", RequireRole(RoleEditor), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		key        string
+		role       Role
+		postStatus int
+	}{
+		{key: "viewer-key", role: RoleViewer, postStatus: http.StatusForbidden},
+		{key: "editor-key", role: RoleEditor, postStatus: http.StatusNoContent},
+		{key: "admin-key", role: RoleAdmin, postStatus: http.StatusNoContent},
+		{key: "legacy-key", role: RoleAdmin, postStatus: http.StatusNoContent},
+		{key: "anonymous", postStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+			if tt.role != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, string(tt.role), w.Body.String())
+
+			req = httptest.NewRequest(http.MethodPost, "/resource", nil)
+			if tt.role != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.postStatus, w.Code)
+		})
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	roles := ParseRoles("alice:viewer, bob:Editor,root:admin,eve:owner")
+	assert.Equal(t, map[string]Role{"alice": RoleViewer, "bob": RoleEditor, "root": RoleAdmin}, roles)
+
+	_, err := ParseRole("superuser")
+	assert.Equal(t, ErrUnknownRole, err)
+}
@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSubjectClaim is the default claim mapped to link ownership
+	DefaultSubjectClaim = "sub"
+
+	// DefaultJWKSTTL is the default time signing keys are cached
+	DefaultJWKSTTL = time.Hour
+
+	// DefaultLeeway is the default clock skew allowed when checking token times
+	DefaultLeeway = time.Minute
+
+	// minKeyRefresh limits how often unknown key IDs trigger a refetch, so
+	// forged tokens can't hammer the provider
+	minKeyRefresh = time.Minute
+
+	// discoveryPath is where OIDC providers publish their configuration
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+// Errors returned by the token verifier
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// JWTConfig holds the settings for validating tokens from an OIDC provider
+type JWTConfig struct {
+	// Issuer must match the iss claim. The signing keys are discovered
+	// from the issuer unless JWKSURL is set.
+	Issuer string
+
+	// Audience, if set, must be one of the aud claims
+	Audience string
+
+	// JWKSURL is the URL of the provider's signing keys
+	JWKSURL string
+
+	// SubjectClaim names the claim used as the subject owning links,
+	// such as "email". It must be a string.
+	SubjectClaim string
+
+	// KeyTTL is the time signing keys are cached
+	KeyTTL time.Duration
+
+	// Leeway is the clock skew allowed when checking exp and nbf
+	Leeway time.Duration
+
+	// Client is used to fetch the provider configuration and keys
+	Client *http.Client
+}
+
+// JWTVerifier validates JWTs signed by an OIDC provider
+type JWTVerifier struct {
+	config JWTConfig
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewJWTVerifier creates a new JWTVerifier
+func NewJWTVerifier(config JWTConfig) *JWTVerifier {
+	if config.SubjectClaim == "" {
+		config.SubjectClaim = DefaultSubjectClaim
+	}
+	if config.KeyTTL <= 0 {
+		config.KeyTTL = DefaultJWKSTTL
+	}
+	if config.Leeway < 0 {
+		config.Leeway = 0
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWTVerifier{config: config}
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks a token's signature, issuer, audience and validity period,
+// and returns the subject it authenticates. Tokens that fail any check return
+// ErrInvalidToken; other errors mean the signing keys couldn't be fetched.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", ErrInvalidToken
+	}
+	hash, ok := signatureHash(header.Alg)
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err == ErrUnknownKey {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if !verifySignature(header.Alg, key, hash, parts[0]+"."+parts[1], sig) {
+		return "", ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", ErrInvalidToken
+	}
+	if !v.validClaims(claims, time.Now()) {
+		return "", ErrInvalidToken
+	}
+	subject, ok := claims[v.config.SubjectClaim].(string)
+	if !ok || subject == "" {
+		return "", ErrInvalidToken
+	}
+	return subject, nil
+}
+
+// validClaims checks the registered claims of a token
+func (v *JWTVerifier) validClaims(claims map[string]any, now time.Time) bool {
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return false
+	}
+	if v.config.Audience != "" && !hasAudience(claims["aud"], v.config.Audience) {
+		return false
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-v.config.Leeway).After(time.Unix(int64(exp), 0)) {
+		return false
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return false
+	}
+	return true
+}
+
+// hasAudience reports whether an aud claim, a string or a list of strings,
+// includes the audience
+func hasAudience(aud any, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns the signing key with the given ID. Keys are refetched once
+// they expire, or when a token names a key not seen yet because the
+// provider rotated its keys.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.fetched)
+	key, ok := v.lookup(kid)
+	if ok && age < v.config.KeyTTL {
+		return key, nil
+	}
+	if !ok && v.keys != nil && age < minKeyRefresh {
+		return nil, ErrUnknownKey
+	}
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		// Keep serving cached keys while the provider is unreachable
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	v.keys = keys
+	v.fetched = time.Now()
+
+	if key, ok = v.lookup(kid); !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// lookup finds a cached key. Tokens without a key ID may be signed with
+// the provider's only key.
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// jwk is a JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchKeys downloads the provider's signing keys, discovering their URL
+// from the issuer if it isn't configured. Keys of unsupported types are
+// skipped.
+func (v *JWTVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.config.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.config.Issuer, "/")+discoveryPath, &discovery); err != nil {
+			return nil, err
+		}
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("provider configuration has no jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// getJSON fetches and decodes a JSON document
+func (v *JWTVerifier) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// publicKey converts an RSA or EC key to its crypto representation
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+// signatureHash returns the hash used by a supported algorithm. Only
+// asymmetric algorithms are accepted, so a token can't be signed with a
+// public key or with "none".
+func signatureHash(alg string) (crypto.Hash, bool) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, true
+	case "RS384", "ES384":
+		return crypto.SHA384, true
+	case "RS512", "ES512":
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// verifySignature checks a token signature against a key of the type the
+// algorithm requires
+func verifySignature(alg string, key crypto.PublicKey, hash crypto.Hash, signed string, sig []byte) bool {
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512([]byte(signed))
+		digest = sum[:]
+	}
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		// ES signatures are the fixed-size r and s values concatenated
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// isJWT reports whether a bearer credential looks like a JWT rather than
+// an API key
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
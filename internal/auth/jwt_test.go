@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a minimal OIDC provider publishing its signing keys
+type testProvider struct {
+	server  *httptest.Server
+	keys    atomic.Value
+	fetches atomic.Int32
+}
+
+func newTestProvider(t *testing.T, keys ...jwk) *testProvider {
+	p := &testProvider{}
+	p.keys.Store(keys)
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		p.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": p.keys.Load()})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// signToken builds a token signed with an RSA or EC P-256 key
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(jwtHeader{Alg: alg, Kid: kid})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	provider := newTestProvider(t, rsaJWK("rsa", rsaKey), ecJWK("ec", ecKey))
+	verifier := NewJWTVerifier(JWTConfig{
+		Issuer:       provider.server.URL,
+		Audience:     "shortener",
+		SubjectClaim: "email",
+	})
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"iss":   provider.server.URL,
+			"aud":   []string{"other", "shortener"},
+			"sub":   "0001",
+			"email": "alice@example.com",
+			"exp":   now + 300,
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "RS256", token: signToken(t, "RS256", "rsa", rsaKey, claims(nil)), valid: true},
+		{name: "ES256", token: signToken(t, "ES256", "ec", ecKey, claims(nil)), valid: true},
+		{name: "Single audience", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"aud": "shortener"})), valid: true},
+		{name: "Wrong audience", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"aud": "other"}))},
+		{name: "Wrong issuer", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"iss": "https://evil.example"}))},
+		{name: "Expired", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": now - 120}))},
+		{name: "Without expiry", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"exp": nil}))},
+		{name: "Not yet valid", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"nbf": now + 300}))},
+		{name: "Without subject claim", token: signToken(t, "RS256", "rsa", rsaKey, claims(map[string]any{"email": nil}))},
+		{name: "Wrong key", token: signToken(t, "RS256", "rsa", otherKey, claims(nil))},
+		{name: "Algorithm mismatch", token: signToken(t, "ES256", "rsa", rsaKey, claims(nil))},
+		{name: "Unsigned", token: signToken(t, "none", "rsa", rsaKey, claims(nil))},
+		{name: "Malformed", token: "eyJhbGciOi.abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := verifier.Verify(context.Background(), tt.token)
+			if tt.valid {
+				require.NoError(t, err)
+				assert.Equal(t, "alice@example.com", subject)
+			} else {
+				assert.Equal(t, ErrInvalidToken, err)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_KeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	provider := newTestProvider(t, rsaJWK("old", oldKey))
+	verifier := NewJWTVerifier(JWTConfig{Issuer: provider.server.URL, JWKSURL: provider.server.URL + "/keys"})
+	claims := map[string]any{"iss": provider.server.URL, "sub": "alice", "exp": time.Now().Unix() + 300}
+
+	_, err = verifier.Verify(context.Background(), signToken(t, "RS256", "old", oldKey, claims))
+	require.NoError(t, err)
+
+	// Unknown key IDs are refetched at most once a minute
+	provider.keys.Store([]jwk{rsaJWK("old", oldKey), rsaJWK("new", newKey)})
+	token := signToken(t, "RS256", "new", newKey, claims)
+	_, err = verifier.Verify(context.Background(), token)
+	assert.Equal(t, ErrInvalidToken, err)
+	assert.Equal(t, int32(1), provider.fetches.Load())
+
+	verifier.fetched = verifier.fetched.Add(-minKeyRefresh)
+	subject, err := verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", subject)
+	assert.Equal(t, int32(2), provider.fetches.Load())
+}
+
+func TestManager_MiddlewareJWT(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	provider := newTestProvider(t, rsaJWK("rsa", key))
+
+	m := NewManager(newMemorySessions(), Config{
+		APIKeys:    map[string]string{"test-key": "integration"},
+		JWT:        NewJWTVerifier(JWTConfig{Issuer: provider.server.URL}),
+		Workspaces: map[string]string{"alice": "acme"},
+	})
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.POST("/resource", func(c *gin.Context) {
+		p := PrincipalFrom(c)
+		c.String(http.StatusOK, p.Subject+"|"+p.Method+"|"+p.Workspace)
+	})
+
+	claims := map[string]any{"iss": provider.server.URL, "sub": "alice", "exp": time.Now().Unix() + 300}
+	tests := []struct {
+		name           string
+		bearer         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Token", bearer: signToken(t, "RS256", "rsa", key, claims), expectedStatus: http.StatusOK, expectedBody: "alice|jwt|acme"},
+		{name: "API key", bearer: "test-key", expectedStatus: http.StatusOK, expectedBody: "integration|api_key|"},
+		{name: "Invalid token", bearer: signToken(t, "RS256", "unknown", key, claims), expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/resource", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// Format names backup files in their header
+	Format = "url-shortener-backup"
+
+	// Version is the version of the backup format written, and the newest read
+	Version = 1
+
+	// restoreBatchSize is the number of links created per store round trip
+	restoreBatchSize = 500
+
+	// maxRestoreErrors is the most failed lines reported by a restore
+	maxRestoreErrors = 100
+
+	// maxLine is the longest line accepted on restore
+	maxLine = 1 << 20
+)
+
+// ErrInvalidBackup is returned when restoring from something that doesn't
+// start with a backup header of a known version
+var ErrInvalidBackup = errors.New("not a backup of a supported version")
+
+// Header is the first line of a backup. CreatedAt is when the backup began;
+// an incremental backup taken later with it as Since picks up where this
+// one ended. Schema is the storage schema version of its records, which are
+// migrated on restore; backups from before it was recorded are version 0.
+type Header struct {
+	Format    string     `json:"format"`
+	Version   int        `json:"version"`
+	Schema    int        `json:"schema"`
+	CreatedAt time.Time  `json:"created_at"`
+	Since     *time.Time `json:"since,omitempty"`
+}
+
+// Entry is a link in a backup, with its whole record
+type Entry struct {
+	Key       string          `json:"key"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Record    *storage.Record `json:"record"`
+}
+
+// Result summarizes a restore
+type Result struct {
+	Restored int     `json:"restored"`
+	Existing int     `json:"existing"`
+	Expired  int     `json:"expired"`
+	Failed   int     `json:"failed"`
+	Errors   []Error `json:"errors,omitempty"`
+}
+
+// Error reports a line that was not restored
+type Error struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// Write streams a backup of every link to w as JSON Lines: a Header, then
+// an Entry per link. With since, only links created at or after it are
+// included. Nothing is written until the first link is read or the walk
+// ends, so a store failing up front leaves w untouched. It returns the
+// number of links written.
+func Write(ctx context.Context, w io.Writer, store storage.ExportStore, since *time.Time) (int, error) {
+	header := Header{Format: Format, Version: Version, Schema: storage.SchemaVersion, CreatedAt: time.Now().UTC(), Since: since}
+	enc := json.NewEncoder(w)
+	written := 0
+	err := store.Walk(ctx, func(r storage.SearchResult) error {
+		if since != nil && r.Record.CreatedAt.Before(*since) {
+			return nil
+		}
+		if written == 0 {
+			if err := enc.Encode(header); err != nil {
+				return err
+			}
+		}
+		written++
+		return enc.Encode(Entry{Key: r.Key, ExpiresAt: r.ExpiresAt, Record: r.Record})
+	})
+	if err != nil || written > 0 {
+		return written, err
+	}
+	return 0, enc.Encode(header)
+}
+
+// ReadHeader reads the header of a backup, such as to take an incremental
+// backup from where it ended
+func ReadHeader(r io.Reader) (*Header, error) {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parseHeader(line)
+}
+
+// parseHeader decodes a header line, checking its format and version
+func parseHeader(line []byte) (*Header, error) {
+	var h Header
+	if json.Unmarshal(line, &h) != nil || h.Format != Format || h.Version < 1 || h.Version > Version {
+		return nil, ErrInvalidBackup
+	}
+	return &h, nil
+}
+
+// Restore creates the links of a backup in batches, leaving existing keys
+// untouched. Links that expired since the backup are skipped, and links that
+// didn't expire get the store's TTL. Invalid lines are reported in the
+// Result; the error is for an unreadable backup or a failing store.
+func Restore(ctx context.Context, r io.Reader, store storage.BulkStore) (*Result, error) {
+	res := &Result{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	var batch []storage.BulkRecord
+	var lines []int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		errs, err := store.CreateMany(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for i, err := range errs {
+			switch {
+			case err == nil:
+				res.Restored++
+			case errors.Is(err, storage.ErrKeyExists):
+				res.Existing++
+			case errors.Is(err, storage.ErrExpired):
+				res.Expired++
+			default:
+				res.fail(lines[i], batch[i].Key, err.Error())
+			}
+		}
+		batch, lines = batch[:0], lines[:0]
+		return nil
+	}
+
+	var header *Header
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if header == nil {
+			var err error
+			if header, err = parseHeader([]byte(text)); err != nil {
+				return res, err
+			}
+			continue
+		}
+
+		var e struct {
+			Key       string          `json:"key"`
+			ExpiresAt *time.Time      `json:"expires_at"`
+			Record    json.RawMessage `json:"record"`
+		}
+		if err := json.Unmarshal([]byte(text), &e); err != nil || e.Key == "" || len(e.Record) == 0 {
+			res.fail(line, e.Key, "invalid entry")
+			continue
+		}
+		rec, err := storage.ParseRecord(e.Record, header.Schema)
+		if err != nil {
+			res.fail(line, e.Key, "invalid entry")
+			continue
+		}
+		batch = append(batch, storage.BulkRecord{Key: e.Key, Record: rec, ExpiresAt: e.ExpiresAt})
+		lines = append(lines, line)
+		if len(batch) >= restoreBatchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return res, err
+	}
+	if header == nil {
+		return res, ErrInvalidBackup
+	}
+	return res, flush()
+}
+
+// fail records a line that was not restored
+func (r *Result) fail(line int, key, reason string) {
+	r.Failed++
+	if len(r.Errors) < maxRestoreErrors {
+		r.Errors = append(r.Errors, Error{Line: line, Key: key, Error: reason})
+	}
+}
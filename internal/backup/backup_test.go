@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+func TestBackupRestore(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	errs, err := store.CreateMany(ctx, []storage.BulkRecord{
+		{Key: "backup01", Record: &storage.Record{URL: "https://example.com/one", Owner: "alice", Workspace: "acme", CreatedAt: old, Tags: []string{"launch"}, Label: "One", Disabled: true}},
+		{Key: "backup02", Record: &storage.Record{URL: "https://example.com/two", CreatedAt: time.Now().UTC()}, ExpiresAt: &expiry},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []error{nil, nil}, errs)
+
+	var full bytes.Buffer
+	n, err := Write(ctx, &full, store, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	header, err := ReadHeader(bytes.NewReader(full.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, Version, header.Version)
+	assert.Nil(t, header.Since)
+
+	// Incremental backups only hold links created since
+	since := old.Add(time.Hour)
+	var incremental bytes.Buffer
+	n, err = Write(ctx, &incremental, store, &since)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Contains(t, incremental.String(), "backup02")
+	assert.NotContains(t, incremental.String(), "backup01")
+
+	// Restoring recreates every link with its whole record and expiry,
+	// leaving existing keys alone
+	require.NoError(t, store.FlushDB(ctx))
+	res, err := Restore(ctx, bytes.NewReader(full.Bytes()), store)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Restored: 2}, res)
+	rec, err := store.GetRecord(ctx, "backup01")
+	require.NoError(t, err)
+	assert.Equal(t, &storage.Record{URL: "https://example.com/one", Owner: "alice", Workspace: "acme", CreatedAt: old, Tags: []string{"launch"}, Label: "One", Disabled: true}, rec)
+	expiresAt, err := store.ExpiresAt(ctx, "backup02")
+	require.NoError(t, err)
+	require.NotNil(t, expiresAt)
+	assert.WithinDuration(t, expiry, *expiresAt, time.Second)
+
+	res, err = Restore(ctx, bytes.NewReader(incremental.Bytes()), store)
+	require.NoError(t, err)
+	assert.Equal(t, &Result{Existing: 1}, res)
+
+	res, err = Restore(ctx, strings.NewReader(full.String()+"not json\n"), store)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Existing)
+	assert.Equal(t, []Error{{Line: 4, Error: "invalid entry"}}, res.Errors)
+}
+
+func TestRestore_InvalidBackup(t *testing.T) {
+	for _, input := range []string{"", `{"key":"backup01","url":"https://example.com"}`, `{"format":"url-shortener-backup","version":2}`} {
+		_, err := Restore(context.Background(), strings.NewReader(input), nil)
+		assert.ErrorIs(t, err, ErrInvalidBackup, input)
+	}
+}
@@ -0,0 +1,101 @@
+// Package captcha verifies CAPTCHA tokens solved by clients, keeping
+// automated clients from creating links anonymously.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TurnstileURL is Cloudflare Turnstile's verification endpoint
+	TurnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+	// HCaptchaURL is hCaptcha's verification endpoint
+	HCaptchaURL = "https://api.hcaptcha.com/siteverify"
+
+	// DefaultTimeout bounds each verification request
+	DefaultTimeout = 5 * time.Second
+)
+
+var (
+	// ErrRejected is returned for tokens the provider did not accept, such
+	// as invalid, expired or already used ones
+	ErrRejected = errors.New("captcha rejected")
+
+	// ErrUnexpectedStatus is returned when the provider answers with a
+	// non-2xx status
+	ErrUnexpectedStatus = errors.New("unexpected status from captcha provider")
+)
+
+// Verifier checks a CAPTCHA token solved by the client at remoteIP,
+// returning ErrRejected if the token isn't valid
+type Verifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// SiteVerify verifies tokens against a siteverify endpoint, the protocol
+// shared by Turnstile, hCaptcha and reCAPTCHA
+type SiteVerify struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Turnstile returns a verifier of Cloudflare Turnstile tokens
+func Turnstile(secret string) SiteVerify {
+	return SiteVerify{URL: TurnstileURL, Secret: secret}
+}
+
+// HCaptcha returns a verifier of hCaptcha tokens
+func HCaptcha(secret string) SiteVerify {
+	return SiteVerify{URL: HCaptchaURL, Secret: secret}
+}
+
+// siteVerifyResponse is the outcome of a verification
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify posts the token to the provider and checks it accepted it
+func (v SiteVerify) Verify(ctx context.Context, token, remoteIP string) error {
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	form := url.Values{"secret": {v.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrRejected, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteVerify_Verify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "203.0.113.7", r.PostForm.Get("remoteip"))
+		if r.PostForm.Get("response") == "solved" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	v := SiteVerify{URL: server.URL, Secret: "secret"}
+	ctx := context.Background()
+	assert.NoError(t, v.Verify(ctx, "solved", "203.0.113.7"))
+
+	err := v.Verify(ctx, "forged", "203.0.113.7")
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.ErrorContains(t, err, "invalid-input-response")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.ErrorIs(t, SiteVerify{URL: failing.URL}.Verify(ctx, "solved", ""), ErrUnexpectedStatus)
+}
@@ -0,0 +1,160 @@
+// Package chain follows links through URL shorteners to their final
+// destination
+package chain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxHops is the default number of shortener redirects followed
+	DefaultMaxHops = 5
+
+	// DefaultTimeout is the default time allowed for each hop
+	DefaultTimeout = 5 * time.Second
+
+	// userAgent identifies the resolver to shorteners
+	userAgent = "url-shortener-resolver/1.0"
+)
+
+// DefaultHosts are well-known URL shorteners
+var DefaultHosts = []string{
+	"bit.ly", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly",
+	"rebrand.ly", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+}
+
+// Errors returned by Resolve
+var (
+	ErrTooManyHops = errors.New("too many shortener redirects")
+	ErrLoop        = errors.New("shortener redirects loop")
+)
+
+// Resolver follows redirects while they stay on known shorteners. Only
+// shortener hosts are ever requested, so destinations themselves are never
+// fetched.
+type Resolver struct {
+	hosts   map[string]bool
+	maxHops int
+	client  *http.Client
+}
+
+// NewResolver creates a Resolver for the given shortener hosts, following
+// at most maxHops redirects
+func NewResolver(hosts []string, maxHops int, timeout time.Duration) *Resolver {
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	r := &Resolver{
+		hosts:   make(map[string]bool, len(hosts)),
+		maxHops: maxHops,
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			r.hosts[host] = true
+		}
+	}
+	return r
+}
+
+// ParseHosts parses a comma-separated list of shortener hosts
+func ParseHosts(spec string) []string {
+	var hosts []string
+	for _, host := range strings.Split(spec, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// IsShortener reports whether a URL points at a known shortener, or one of
+// its subdomains
+func (r *Resolver) IsShortener(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if r.hosts[host] {
+			return true
+		}
+		_, host, _ = strings.Cut(host, ".")
+	}
+	return false
+}
+
+// Resolve follows a URL through shortener redirects, returning the first
+// URL outside the known shorteners and the number of redirects followed.
+// A shortener answering without a redirect ends the chain at its URL.
+func (r *Resolver) Resolve(ctx context.Context, rawURL string) (string, int, error) {
+	seen := map[string]bool{}
+	for hops := 0; ; hops++ {
+		if !r.IsShortener(rawURL) {
+			return rawURL, hops, nil
+		}
+		if seen[rawURL] {
+			return "", hops, ErrLoop
+		}
+		if hops == r.maxHops {
+			return "", hops, ErrTooManyHops
+		}
+		seen[rawURL] = true
+
+		next, err := r.next(ctx, rawURL)
+		if err != nil {
+			return "", hops, err
+		}
+		if next == "" {
+			return rawURL, hops, nil
+		}
+		rawURL = next
+	}
+}
+
+// next returns where a shortener redirects a URL to, or "" if it doesn't.
+// Shorteners refusing HEAD are asked again with GET.
+func (r *Resolver) next(ctx context.Context, rawURL string) (string, error) {
+	resp, err := r.request(ctx, http.MethodHead, rawURL)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		resp.Body.Close()
+		resp, err = r.request(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+		return "", nil
+	}
+	next, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return next.String(), nil
+}
+
+func (r *Resolver) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return r.client.Do(req)
+}
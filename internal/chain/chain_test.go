@@ -0,0 +1,82 @@
+package chain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_IsShortener(t *testing.T) {
+	r := NewResolver([]string{"bit.ly", " T.co "}, 0, 0)
+	assert.True(t, r.IsShortener("https://bit.ly/abc"))
+	assert.True(t, r.IsShortener("https://www.bit.ly/abc"))
+	assert.True(t, r.IsShortener("http://t.co/abc"))
+	assert.False(t, r.IsShortener("https://notbit.ly/abc"))
+	assert.False(t, r.IsShortener("https://example.com/bit.ly"))
+	assert.False(t, r.IsShortener("::"))
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	var methods []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method)
+		http.Redirect(w, req, "/b", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, req *http.Request) {
+		// Some shorteners only answer GET
+		if req.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		methods = append(methods, req.Method)
+		http.Redirect(w, req, "https://example.com/final", http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/deep/", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, req.URL.Path+"x", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	r := NewResolver([]string{"127.0.0.1"}, 3, 0)
+	ctx := context.Background()
+
+	final, hops, err := r.Resolve(ctx, server.URL+"/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/final", final)
+	assert.Equal(t, 2, hops)
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methods)
+
+	// Destinations outside shorteners are returned as is, without a request
+	final, hops, err = r.Resolve(ctx, "https://example.com/direct")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/direct", final)
+	assert.Equal(t, 0, hops)
+
+	// A shortener that doesn't redirect ends the chain
+	final, _, err = r.Resolve(ctx, server.URL+"/page")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/page", final)
+
+	_, _, err = r.Resolve(ctx, server.URL+"/loop")
+	assert.Equal(t, ErrLoop, err)
+
+	_, hops, err = r.Resolve(ctx, server.URL+"/deep/")
+	assert.Equal(t, ErrTooManyHops, err)
+	assert.Equal(t, 3, hops)
+}
+
+func TestParseHosts(t *testing.T) {
+	assert.Equal(t, []string{"bit.ly", "t.co"}, ParseHosts(" bit.ly,, t.co "))
+	assert.Nil(t, ParseHosts(""))
+}
@@ -0,0 +1,304 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// DefaultTable is the default table clicks are inserted into
+	DefaultTable = "clicks"
+
+	// DefaultBatchSize is the default number of clicks inserted at once
+	DefaultBatchSize = 1000
+
+	// DefaultFlushInterval is the default longest time clicks wait for a batch to fill
+	DefaultFlushInterval = 5 * time.Second
+
+	// DefaultBufferSize is the default number of clicks buffered before
+	// recording waits for inserts to catch up
+	DefaultBufferSize = 10000
+
+	// DefaultTimeout is the default time allowed for each insert
+	DefaultTimeout = 10 * time.Second
+
+	// QueueName identifies the writer in shutdown logs
+	QueueName = "clickhouse"
+
+	// maxAttempts is how many times a batch is inserted before it is dropped
+	maxAttempts = 3
+
+	// timeFormat is how click times are sent, as DateTime64(3) in UTC
+	timeFormat = "2006-01-02 15:04:05.000"
+)
+
+var (
+	// ErrInvalidURL is returned when the server URL is not an absolute http(s) URL
+	ErrInvalidURL = errors.New("clickhouse url must be an absolute http(s) url")
+
+	// ErrInvalidTable is returned for table names that aren't plain identifiers
+	ErrInvalidTable = errors.New("clickhouse table must be a name, optionally qualified by its database")
+
+	// ErrClosed is returned when recording clicks after the writer shut down
+	ErrClosed = errors.New("clickhouse writer is closed")
+)
+
+// tablePattern matches table names, optionally qualified by their database
+var tablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Config configures a Writer. Zero values keep the defaults.
+type Config struct {
+	// URL is the server's HTTP interface, such as http://localhost:8123
+	URL      string
+	User     string
+	Password string
+
+	Table         string
+	BatchSize     int
+	FlushInterval time.Duration
+	BufferSize    int
+	Timeout       time.Duration
+}
+
+// row is a click as inserted, one JSON object per row
+type row struct {
+	Key       string `json:"key"`
+	Workspace string `json:"workspace"`
+	Owner     string `json:"owner"`
+	Time      string `json:"time"`
+	Variant   string `json:"variant"`
+	Country   string `json:"country"`
+	Referrer  string `json:"referrer"`
+	Channel   string `json:"channel"`
+	Device    string `json:"device"`
+	OS        string `json:"os"`
+	Browser   string `json:"browser"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// Writer inserts clicks into ClickHouse in batches, for analysis over
+// longer ranges and more dimensions than rollups keep. It is an
+// analytics.Sink: clicks are buffered and inserted in the background, and
+// when the buffer is full recording waits for inserts to catch up, so a
+// slow server backs clicks up into the recorder's queue instead of
+// growing memory without bound.
+type Writer struct {
+	cfg    Config
+	insert string
+	client *http.Client
+	rows   chan row
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a Writer and starts inserting the clicks it is given
+func New(cfg Config) (*Writer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if !tablePattern.MatchString(cfg.Table) {
+		return nil, ErrInvalidTable
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+
+	w := &Writer{
+		cfg:    cfg,
+		insert: "INSERT INTO " + cfg.Table + " FORMAT JSONEachRow",
+		client: &http.Client{Timeout: cfg.Timeout},
+		rows:   make(chan row, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// CreateTable creates the table clicks are inserted into unless it exists.
+// Clicks are partitioned by month and sorted by key and time, which suits
+// per-link queries over time ranges.
+func (w *Writer) CreateTable(ctx context.Context) error {
+	return w.exec(ctx, "CREATE TABLE IF NOT EXISTS "+w.cfg.Table+` (
+	key String,
+	workspace LowCardinality(String),
+	owner String,
+	time DateTime64(3, 'UTC'),
+	variant LowCardinality(String),
+	country LowCardinality(String),
+	referrer String,
+	channel LowCardinality(String),
+	device LowCardinality(String),
+	os LowCardinality(String),
+	browser LowCardinality(String),
+	ip String,
+	user_agent String
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (key, time)`, nil)
+}
+
+// RecordClick buffers a click for insertion, waiting while the buffer is
+// full until ctx is done. Clicks of visitors who asked not to be tracked
+// are inserted without their IP and User-Agent.
+func (w *Writer) RecordClick(ctx context.Context, click analytics.Click) error {
+	r := row{
+		Key:       click.Key,
+		Workspace: click.Workspace,
+		Owner:     click.Owner,
+		Time:      click.Time.UTC().Format(timeFormat),
+		Variant:   click.Variant,
+		Country:   click.Country,
+		Referrer:  click.Referrer,
+		Channel:   click.Channel,
+		Device:    click.Device,
+		OS:        click.OS,
+		Browser:   click.Browser,
+	}
+	if !click.NoTrack {
+		r.IP, r.UserAgent = click.IP, click.UserAgent
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrClosed
+	}
+	select {
+	case w.rows <- r:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("clickhouse buffer full: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops accepting clicks and waits for buffered ones to be
+// inserted until ctx is done
+func (w *Writer) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.rows)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run inserts buffered clicks whenever a batch fills or the flush interval
+// passes, until the writer shuts down
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]row, 0, w.cfg.BatchSize)
+	for {
+		select {
+		case r, ok := <-w.rows:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, r)
+			if len(batch) < w.cfg.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		w.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// flush inserts a batch, retrying with backoff before dropping it. Inserts
+// block the writer, so while the server is slow or down the buffer fills
+// and recording waits.
+func (w *Writer) flush(batch []row) {
+	if len(batch) == 0 {
+		return
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, r := range batch {
+		enc.Encode(r)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), w.cfg.Timeout)
+		err = w.exec(ctx, w.insert, bytes.NewReader(body.Bytes()))
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("failed to insert %d clicks into clickhouse, dropping them: %v", len(batch), err)
+}
+
+// exec runs a query over the HTTP interface. Queries with data, such as
+// inserts, are sent in the URL so the data can follow in the body.
+func (w *Writer) exec(ctx context.Context, query string, data io.Reader) error {
+	target, body := w.cfg.URL, io.Reader(bytes.NewBufferString(query))
+	if data != nil {
+		u, _ := url.Parse(w.cfg.URL)
+		params := u.Query()
+		params.Set("query", query)
+		u.RawQuery = params.Encode()
+		target, body = u.String(), data
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return err
+	}
+	if w.cfg.User != "" {
+		req.Header.Set("X-ClickHouse-User", w.cfg.User)
+		req.Header.Set("X-ClickHouse-Key", w.cfg.Password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse: %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+// server records the queries and inserted rows it receives
+type server struct {
+	mu      sync.Mutex
+	queries []string
+	batches [][]row
+	release chan struct{}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.release != nil {
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if query := r.URL.Query().Get("query"); query != "" {
+		s.queries = append(s.queries, query)
+		var batch []row
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var rw row
+			json.Unmarshal(scanner.Bytes(), &rw)
+			batch = append(batch, rw)
+		}
+		s.batches = append(s.batches, batch)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.queries = append(s.queries, string(body))
+}
+
+func TestWriter_Batches(t *testing.T) {
+	srv := &server{}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	w, err := New(Config{URL: ts.URL, Table: "analytics.clicks", BatchSize: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, w.CreateTable(ctx))
+
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, w.RecordClick(ctx, analytics.Click{Key: "aB1cD2eF", Time: at, Country: "FR", Device: "mobile", IP: "192.0.2.0"}))
+	require.NoError(t, w.RecordClick(ctx, analytics.Click{Key: "aB1cD2eF", Time: at, IP: "192.0.2.0", UserAgent: "curl/8", NoTrack: true}))
+	require.NoError(t, w.RecordClick(ctx, analytics.Click{Key: "gH3iJ4kL", Time: at}))
+
+	// Shutting down inserts the partial batch
+	require.NoError(t, w.Shutdown(ctx))
+	assert.ErrorIs(t, w.RecordClick(ctx, analytics.Click{Key: "gH3iJ4kL"}), ErrClosed)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	require.Len(t, srv.queries, 3)
+	assert.True(t, strings.HasPrefix(srv.queries[0], "CREATE TABLE IF NOT EXISTS analytics.clicks"), srv.queries[0])
+	assert.Equal(t, "INSERT INTO analytics.clicks FORMAT JSONEachRow", srv.queries[1])
+	require.Len(t, srv.batches, 2)
+	require.Len(t, srv.batches[0], 2)
+	assert.Equal(t, row{Key: "aB1cD2eF", Time: "2024-03-01 12:30:00.000", Country: "FR", Device: "mobile", IP: "192.0.2.0"}, srv.batches[0][0])
+	assert.Empty(t, srv.batches[0][1].IP)
+	assert.Empty(t, srv.batches[0][1].UserAgent)
+	assert.Len(t, srv.batches[1], 1)
+}
+
+func TestWriter_Backpressure(t *testing.T) {
+	srv := &server{release: make(chan struct{})}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	defer close(srv.release)
+
+	w, err := New(Config{URL: ts.URL, BatchSize: 1, BufferSize: 1, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	// The first click is being inserted and the second fills the buffer, so
+	// the third waits until its context is done
+	ctx := context.Background()
+	require.NoError(t, w.RecordClick(ctx, analytics.Click{Key: "aB1cD2eF"}))
+	require.Eventually(t, func() bool { return len(w.rows) == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, w.RecordClick(ctx, analytics.Click{Key: "aB1cD2eF"}))
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.RecordClick(timeout, analytics.Click{Key: "aB1cD2eF"}), context.DeadlineExceeded)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Config{URL: "localhost:8123"})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	_, err = New(Config{URL: "http://localhost:8123", Table: "clicks; DROP TABLE clicks"})
+	assert.ErrorIs(t, err, ErrInvalidTable)
+}
@@ -0,0 +1,309 @@
+package domain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// MethodDNS verifies a domain through a TXT record
+	MethodDNS = "dns"
+
+	// MethodHTTP verifies a domain through a file served over HTTP
+	MethodHTTP = "http"
+
+	// ChallengePrefix is the label under which the DNS TXT record must be published
+	ChallengePrefix = "_url-shortener-challenge"
+
+	// ChallengePath is the path at which the HTTP challenge file must be served
+	ChallengePath = "/.well-known/url-shortener-challenge"
+
+	// MaxPendingAttempts is the number of failed checks before a pending domain fails
+	MaxPendingAttempts = 10
+
+	// MaxRecheckFailures is the number of consecutive failed re-checks before a verified domain fails
+	MaxRecheckFailures = 3
+
+	// DefaultRecheckInterval is the default time between re-check runs
+	DefaultRecheckInterval = time.Hour
+
+	// UnverifiedTTL is how long a domain is kept without being verified
+	UnverifiedTTL = 7 * 24 * time.Hour
+)
+
+// Errors returned by the verifier
+var (
+	ErrInvalidDomain = errors.New("invalid domain name")
+	ErrInvalidMethod = errors.New("invalid verification method")
+	ErrTokenMissing  = errors.New("verification token not found")
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Checker checks whether a domain publishes its verification token
+type Checker interface {
+	Check(ctx context.Context, d *storage.Domain) error
+}
+
+// DNSChecker looks for the token in a TXT record
+type DNSChecker struct {
+	Resolver *net.Resolver
+}
+
+// Check looks up the challenge TXT record for the domain
+func (c DNSChecker) Check(ctx context.Context, d *storage.Domain) error {
+	resolver := c.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	records, err := resolver.LookupTXT(ctx, RecordName(d.Name))
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if strings.TrimSpace(record) == d.Token {
+			return nil
+		}
+	}
+	return ErrTokenMissing
+}
+
+// HTTPChecker looks for the token in a file served by the domain
+type HTTPChecker struct {
+	Client *http.Client
+}
+
+// Check fetches the challenge file from the domain
+func (c HTTPChecker) Check(ctx context.Context, d *storage.Domain) error {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ChallengeURL(d.Name), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("challenge file returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != d.Token {
+		return ErrTokenMissing
+	}
+	return nil
+}
+
+// RecordName returns the DNS name that must hold the TXT challenge
+func RecordName(name string) string {
+	return ChallengePrefix + "." + name
+}
+
+// ChallengeURL returns the URL that must serve the HTTP challenge
+func ChallengeURL(name string) string {
+	return "http://" + name + ChallengePath
+}
+
+// Verifier manages custom domain registration and verification
+type Verifier struct {
+	store    storage.DomainStore
+	checkers map[string]Checker
+}
+
+// NewVerifier creates a new Verifier using DNS and HTTP checks
+func NewVerifier(store storage.DomainStore) *Verifier {
+	return &Verifier{
+		store: store,
+		checkers: map[string]Checker{
+			MethodDNS:  DNSChecker{},
+			MethodHTTP: HTTPChecker{},
+		},
+	}
+}
+
+// SetChecker replaces the checker used for a verification method
+func (v *Verifier) SetChecker(method string, c Checker) {
+	v.checkers[method] = c
+}
+
+// Register creates a pending domain with a fresh verification token. The
+// domain belongs to workspace, which may be empty. A domain nobody has
+// verified can be registered again, so it goes to whoever passes the
+// challenge; unverified domains expire after UnverifiedTTL.
+func (v *Verifier) Register(ctx context.Context, name, method, workspace string) (*storage.Domain, error) {
+	name = Normalize(name)
+	if !domainPattern.MatchString(name) {
+		return nil, ErrInvalidDomain
+	}
+	if method == "" {
+		method = MethodDNS
+	}
+	if _, ok := v.checkers[method]; !ok {
+		return nil, ErrInvalidMethod
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &storage.Domain{
+		Name:      name,
+		Method:    method,
+		Token:     token,
+		Workspace: workspace,
+		Status:    storage.DomainPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := v.store.CreateDomain(ctx, d, UnverifiedTTL); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Get returns a registered domain
+func (v *Verifier) Get(ctx context.Context, name string) (*storage.Domain, error) {
+	return v.store.GetDomain(ctx, Normalize(name))
+}
+
+// Delete removes a registered domain
+func (v *Verifier) Delete(ctx context.Context, name string) error {
+	return v.store.DeleteDomain(ctx, Normalize(name))
+}
+
+// Verify runs the domain's challenge check and records the outcome
+func (v *Verifier) Verify(ctx context.Context, name string) (*storage.Domain, error) {
+	d, err := v.store.GetDomain(ctx, Normalize(name))
+	if err != nil {
+		return nil, err
+	}
+
+	checker, ok := v.checkers[d.Method]
+	if !ok {
+		return nil, ErrInvalidMethod
+	}
+
+	Transition(d, checker.Check(ctx, d), time.Now().UTC())
+	if err := v.store.SaveDomain(ctx, d, UnverifiedTTL); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// IsVerified reports whether links may be created under the domain
+func (v *Verifier) IsVerified(ctx context.Context, name string) (bool, error) {
+	d, err := v.store.GetDomain(ctx, Normalize(name))
+	if err == storage.ErrDomainNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return d.Status == storage.DomainVerified, nil
+}
+
+// Recheck verifies every registered domain once, so a failed domain comes
+// back if its owner fixes the challenge before it expires
+func (v *Verifier) Recheck(ctx context.Context) error {
+	domains, err := v.store.ListDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range domains {
+		if _, err := v.Verify(ctx, d.Name); err != nil {
+			log.Printf("domain recheck for %s failed: %v", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// RunRecheck re-checks domains on every interval until ctx is cancelled
+func (v *Verifier) RunRecheck(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRecheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.Recheck(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("domain recheck failed: %v", err)
+			}
+		}
+	}
+}
+
+// Transition applies the result of a verification check to the domain state.
+// A successful check always verifies the domain. Failed checks leave a pending
+// domain pending until it runs out of attempts, and only fail a verified domain
+// after several consecutive failed re-checks so a DNS blip doesn't break links.
+func Transition(d *storage.Domain, checkErr error, now time.Time) {
+	d.CheckedAt = &now
+
+	if checkErr == nil {
+		d.Status = storage.DomainVerified
+		d.Failures = 0
+		d.LastError = ""
+		if d.VerifiedAt == nil {
+			d.VerifiedAt = &now
+		}
+		return
+	}
+
+	d.Failures++
+	d.LastError = checkErr.Error()
+
+	switch d.Status {
+	case storage.DomainPending:
+		if d.Failures >= MaxPendingAttempts {
+			d.Status = storage.DomainFailed
+		}
+	case storage.DomainVerified:
+		if d.Failures >= MaxRecheckFailures {
+			d.Status = storage.DomainFailed
+			d.VerifiedAt = nil
+		}
+	}
+}
+
+// Normalize lowercases a domain name and strips a trailing dot
+func Normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// newToken generates a random verification token
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	return "url-shortener-verification=" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,217 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// memoryStore is an in-memory DomainStore for tests
+type memoryStore struct {
+	mu      sync.Mutex
+	domains map[string]storage.Domain
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{domains: make(map[string]storage.Domain)}
+}
+
+func (m *memoryStore) CreateDomain(_ context.Context, d *storage.Domain, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.domains[d.Name]; ok && current.Status == storage.DomainVerified {
+		return storage.ErrKeyExists
+	}
+	m.domains[d.Name] = *d
+	return nil
+}
+
+func (m *memoryStore) GetDomain(_ context.Context, name string) (*storage.Domain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.domains[name]
+	if !ok {
+		return nil, storage.ErrDomainNotFound
+	}
+	return &d, nil
+}
+
+func (m *memoryStore) SaveDomain(_ context.Context, d *storage.Domain, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.domains[d.Name]; !ok || current.Token != d.Token {
+		return storage.ErrDomainNotFound
+	}
+	m.domains[d.Name] = *d
+	return nil
+}
+
+func (m *memoryStore) DeleteDomain(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.domains[name]; !ok {
+		return storage.ErrDomainNotFound
+	}
+	delete(m.domains, name)
+	return nil
+}
+
+func (m *memoryStore) ListDomains(_ context.Context) ([]*storage.Domain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var domains []*storage.Domain
+	for _, d := range m.domains {
+		d := d
+		domains = append(domains, &d)
+	}
+	return domains, nil
+}
+
+// stubChecker returns a fixed result for every check
+type stubChecker struct {
+	err error
+}
+
+func (s *stubChecker) Check(_ context.Context, _ *storage.Domain) error {
+	return s.err
+}
+
+func TestVerifier_Register(t *testing.T) {
+	v := NewVerifier(newMemoryStore())
+	ctx := context.Background()
+
+	d, err := v.Register(ctx, "Go.Example.com.", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "go.example.com", d.Name)
+	assert.Equal(t, MethodDNS, d.Method)
+	assert.Equal(t, storage.DomainPending, d.Status)
+	assert.NotEmpty(t, d.Token)
+
+	// A pending domain goes to whoever registers it next, with a new token
+	claim, err := v.Register(ctx, "go.example.com", MethodDNS, "acme")
+	require.NoError(t, err)
+	assert.NotEqual(t, d.Token, claim.Token)
+	assert.Equal(t, "acme", claim.Workspace)
+
+	_, err = v.Register(ctx, "not a domain", MethodDNS, "")
+	assert.Equal(t, ErrInvalidDomain, err)
+
+	_, err = v.Register(ctx, "other.example.com", "carrier-pigeon", "")
+	assert.Equal(t, ErrInvalidMethod, err)
+
+	d, err = v.Register(ctx, "links.acme.com", MethodHTTP, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", d.Workspace)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	v := NewVerifier(newMemoryStore())
+	checker := &stubChecker{err: ErrTokenMissing}
+	v.SetChecker(MethodDNS, checker)
+	ctx := context.Background()
+
+	_, err := v.Register(ctx, "go.example.com", MethodDNS, "")
+	require.NoError(t, err)
+
+	// Token not published yet
+	d, err := v.Verify(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DomainPending, d.Status)
+	assert.Equal(t, 1, d.Failures)
+
+	verified, err := v.IsVerified(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	// Token published
+	checker.err = nil
+	d, err = v.Verify(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DomainVerified, d.Status)
+	assert.NotNil(t, d.VerifiedAt)
+
+	verified, err = v.IsVerified(ctx, "GO.example.com")
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	// Verified domains can't be claimed again
+	_, err = v.Register(ctx, "go.example.com", MethodDNS, "acme")
+	assert.Equal(t, storage.ErrKeyExists, err)
+
+	// Unknown domains are never verified
+	verified, err = v.IsVerified(ctx, "unknown.example.com")
+	require.NoError(t, err)
+	assert.False(t, verified)
+}
+
+func TestVerifier_Recheck(t *testing.T) {
+	v := NewVerifier(newMemoryStore())
+	checker := &stubChecker{}
+	v.SetChecker(MethodDNS, checker)
+	ctx := context.Background()
+
+	_, err := v.Register(ctx, "go.example.com", MethodDNS, "")
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, "go.example.com")
+	require.NoError(t, err)
+
+	// The token is removed and re-checks start failing
+	checker.err = ErrTokenMissing
+	for i := 0; i < MaxRecheckFailures; i++ {
+		require.NoError(t, v.Recheck(ctx))
+	}
+
+	d, err := v.Get(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DomainFailed, d.Status)
+
+	// Failed domains are still re-checked, and come back once fixed
+	checker.err = nil
+	require.NoError(t, v.Recheck(ctx))
+	d, err = v.Get(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, storage.DomainVerified, d.Status)
+}
+
+func TestTransition(t *testing.T) {
+	now := time.Now()
+	checkErr := errors.New("lookup failed")
+
+	tests := []struct {
+		name     string
+		status   storage.DomainStatus
+		failures int
+		err      error
+		want     storage.DomainStatus
+	}{
+		{name: "Pending success", status: storage.DomainPending, err: nil, want: storage.DomainVerified},
+		{name: "Pending failure", status: storage.DomainPending, err: checkErr, want: storage.DomainPending},
+		{name: "Pending out of attempts", status: storage.DomainPending, failures: MaxPendingAttempts - 1, err: checkErr, want: storage.DomainFailed},
+		{name: "Verified single failure", status: storage.DomainVerified, err: checkErr, want: storage.DomainVerified},
+		{name: "Verified repeated failures", status: storage.DomainVerified, failures: MaxRecheckFailures - 1, err: checkErr, want: storage.DomainFailed},
+		{name: "Failed success", status: storage.DomainFailed, failures: 5, err: nil, want: storage.DomainVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &storage.Domain{Status: tt.status, Failures: tt.failures}
+			Transition(d, tt.err, now)
+			assert.Equal(t, tt.want, d.Status)
+			assert.Equal(t, &now, d.CheckedAt)
+			if tt.err == nil {
+				assert.Zero(t, d.Failures)
+				assert.Empty(t, d.LastError)
+			} else {
+				assert.Equal(t, tt.failures+1, d.Failures)
+				assert.Equal(t, checkErr.Error(), d.LastError)
+			}
+		})
+	}
+}
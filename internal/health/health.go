@@ -0,0 +1,180 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultInterval is the default time between health check rounds
+	DefaultInterval = time.Minute
+
+	// DefaultThreshold is the default number of consecutive failed checks
+	// before a destination is marked down
+	DefaultThreshold = 3
+
+	// DefaultTimeout is the default time allowed for a single probe
+	DefaultTimeout = 10 * time.Second
+
+	// userAgent identifies the health checker to destination sites
+	userAgent = "url-shortener-health/1.0"
+)
+
+// ErrUnhealthyStatus is returned when a destination answers with a server error
+var ErrUnhealthyStatus = errors.New("destination returned a server error")
+
+// Checker probes the destinations of links with fallbacks, marking those
+// that keep failing down so redirects move on to the next fallback, and
+// marking them up again as soon as they recover
+type Checker struct {
+	store     storage.HealthStore
+	interval  time.Duration
+	threshold int
+
+	// Client is used for probes. The default client refuses to connect to
+	// loopback, private and link-local addresses.
+	Client *http.Client
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// NewChecker creates a new Checker
+func NewChecker(store storage.HealthStore, interval time.Duration, threshold int) *Checker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Checker{
+		store:     store,
+		interval:  interval,
+		threshold: threshold,
+		Client:    metadata.PublicClient(DefaultTimeout),
+		failures:  make(map[string]int),
+	}
+}
+
+// Run checks every link with fallbacks each interval until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.CheckAll(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("health check failed: %v", err)
+			}
+		}
+	}
+}
+
+// CheckAll probes the destinations of every link with fallbacks once and
+// saves which are down. Destinations shared by several links are probed once.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	links, err := c.store.FallbackLinks(ctx)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, link := range links {
+		var down []string
+		for _, dest := range link.Record.Destinations() {
+			if _, ok := results[dest]; !ok {
+				results[dest] = c.observe(dest, c.Probe(ctx, dest))
+			}
+			seen[dest] = true
+			if results[dest] {
+				down = append(down, dest)
+			}
+		}
+		if err := c.store.SaveHealth(ctx, link.Key, down); err != nil && err != storage.ErrNotFound {
+			return err
+		}
+	}
+
+	// Forget destinations no link uses anymore
+	c.mu.Lock()
+	for dest := range c.failures {
+		if !seen[dest] {
+			delete(c.failures, dest)
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Probe requests a destination, reporting an error if it can't be reached
+// or answers with a server error. Servers rejecting HEAD are asked with GET.
+func (c *Checker) Probe(ctx context.Context, dest string) error {
+	status, err := probe(ctx, c.Client, dest)
+	if err != nil {
+		return err
+	}
+	if status >= 500 {
+		return fmt.Errorf("%w: %d", ErrUnhealthyStatus, status)
+	}
+	return nil
+}
+
+// probe requests a destination with HEAD, or with GET if the server
+// rejects HEAD, and returns the response status
+func probe(ctx context.Context, client *http.Client, dest string) (int, error) {
+	status, err := request(ctx, client, http.MethodHead, dest)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = request(ctx, client, http.MethodGet, dest)
+	}
+	return status, err
+}
+
+// request sends a single probe and returns the response status
+func request(ctx context.Context, client *http.Client, method, dest string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, dest, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// observe records the outcome of a probe and reports whether the
+// destination is down: after threshold consecutive failures, until it
+// succeeds again
+func (c *Checker) observe(dest string, probeErr error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if probeErr == nil {
+		if c.failures[dest] >= c.threshold {
+			log.Printf("destination %s recovered", dest)
+		}
+		delete(c.failures, dest)
+		return false
+	}
+
+	c.failures[dest]++
+	if c.failures[dest] == c.threshold {
+		log.Printf("destination %s is down: %v", dest, probeErr)
+	}
+	return c.failures[dest] >= c.threshold
+}
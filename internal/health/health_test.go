@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+func TestChecker_CheckAll(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	var failing atomic.Bool
+	failing.Store(true)
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer fallback.Close()
+
+	require.NoError(t, store.Create(ctx, "sale0001", &storage.Record{
+		URL:       primary.URL,
+		Fallbacks: []string{fallback.URL},
+		CreatedAt: time.Now().UTC(),
+	}))
+	require.NoError(t, store.Create(ctx, "plain001", &storage.Record{URL: primary.URL, CreatedAt: time.Now().UTC()}))
+
+	checker := NewChecker(store, time.Minute, 2)
+	checker.Client = http.DefaultClient
+	check := func() *storage.Record {
+		require.NoError(t, checker.CheckAll(ctx))
+		rec, err := store.GetRecord(ctx, "sale0001")
+		require.NoError(t, err)
+		return rec
+	}
+
+	// A single failure isn't enough to fail over
+	rec := check()
+	assert.Empty(t, rec.Down)
+	assert.Equal(t, primary.URL, rec.HealthyURL())
+
+	rec = check()
+	assert.Equal(t, []string{primary.URL}, rec.Down)
+	assert.Equal(t, fallback.URL, rec.HealthyURL())
+
+	// Links without fallbacks are never checked
+	plain, err := store.GetRecord(ctx, "plain001")
+	require.NoError(t, err)
+	assert.Empty(t, plain.Down)
+
+	// The primary is served again as soon as it recovers
+	failing.Store(false)
+	rec = check()
+	assert.Empty(t, rec.Down)
+	assert.Equal(t, primary.URL, rec.HealthyURL())
+
+	// Links whose fallbacks are removed leave the index
+	rec.Fallbacks = nil
+	require.NoError(t, store.Update(ctx, "sale0001", rec))
+	links, err := store.FallbackLinks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
+
+func TestChecker_Probe(t *testing.T) {
+	checker := NewChecker(nil, 0, 0)
+
+	// The default client refuses to probe internal addresses
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	assert.Error(t, checker.Probe(context.Background(), server.URL))
+
+	checker.Client = http.DefaultClient
+	assert.NoError(t, checker.Probe(context.Background(), server.URL))
+}
@@ -0,0 +1,193 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/alert"
+	"github.com/prayushdave/url-shortener/internal/lock"
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultRotInterval is the default time before a destination is checked again
+	DefaultRotInterval = 24 * time.Hour
+
+	// DefaultRotBatch is the default number of destinations checked per run
+	DefaultRotBatch = 1000
+
+	// maxRotTick bounds the time between runs, so large datasets are
+	// checked in several batches well within the interval
+	maxRotTick = time.Hour
+)
+
+// errBatchFull stops a walk once a run has collected enough links
+var errBatchFull = errors.New("batch full")
+
+// RotChecker periodically requests the destination of every link, records
+// whether it still resolves, and alerts when destinations break
+type RotChecker struct {
+	store    storage.LinkCheckStore
+	interval time.Duration
+	batch    int
+	alerts   alert.Sender
+
+	// Client is used for checks. The default client refuses to connect to
+	// loopback, private and link-local addresses.
+	Client *http.Client
+
+	// Notifier, if set, also tells the owners of each link that broke
+	Notifier *notify.Notifier
+
+	// Lock, if set, keeps checks to one instance at a time, so destinations
+	// aren't requested by every instance
+	Lock *lock.Lock
+}
+
+// NewRotChecker creates a new RotChecker. Alerts may be nil.
+func NewRotChecker(store storage.LinkCheckStore, interval time.Duration, batch int, alerts alert.Sender) *RotChecker {
+	if interval <= 0 {
+		interval = DefaultRotInterval
+	}
+	if batch <= 0 {
+		batch = DefaultRotBatch
+	}
+	return &RotChecker{
+		store:    store,
+		interval: interval,
+		batch:    batch,
+		alerts:   alerts,
+		Client:   metadata.PublicClient(DefaultTimeout),
+	}
+}
+
+// Tick returns the time between runs, short enough for every link to be
+// checked within the interval in batches
+func (c *RotChecker) Tick() time.Duration {
+	return min(c.interval, maxRotTick)
+}
+
+// Run checks stale destinations in batches until ctx is done
+func (c *RotChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Tick())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := c.Lock.Do(ctx, func(ctx context.Context) error {
+				_, err := c.CheckStale(ctx)
+				return err
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("link-rot check failed: %v", err)
+			}
+		}
+	}
+}
+
+// CheckStale checks up to a batch of links whose destination wasn't checked
+// within the interval, and alerts about the links that broke since their
+// last check. It returns the number of links checked.
+func (c *RotChecker) CheckStale(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-c.interval)
+	var stale []storage.SearchResult
+	err := c.store.Walk(ctx, func(r storage.SearchResult) error {
+		if r.Record.Check != nil && r.Record.Check.CheckedAt.After(cutoff) {
+			return nil
+		}
+		stale = append(stale, r)
+		if len(stale) >= c.batch {
+			return errBatchFull
+		}
+		return nil
+	})
+	if err != nil && err != errBatchFull {
+		return 0, err
+	}
+
+	// Destinations shared by several links are requested once
+	checks := make(map[string]*storage.DestinationCheck)
+	var broke []alert.Link
+	for _, r := range stale {
+		check, ok := checks[r.Record.URL]
+		if !ok {
+			check = c.Check(ctx, r.Record.URL)
+			checks[r.Record.URL] = check
+		}
+		if err := c.store.SaveCheck(ctx, r.Key, r.Record.URL, check); err != nil && err != storage.ErrNotFound {
+			return 0, err
+		}
+		if check.Result == storage.CheckBroken && r.Record.CheckStatus() != storage.CheckBroken {
+			broke = append(broke, alert.Link{
+				Key:       r.Key,
+				URL:       r.Record.URL,
+				Owner:     r.Record.Owner,
+				Workspace: r.Record.Workspace,
+				Detail:    checkDetail(check),
+			})
+			if c.Notifier != nil {
+				c.Notifier.Dispatch(c.Notifier.Event(notify.Broken, r.Key, r.Record, checkDetail(check)), r.Record)
+			}
+		}
+	}
+
+	if len(broke) > 0 && c.alerts != nil {
+		if err := c.alerts.Send(ctx, brokenAlert(broke)); err != nil {
+			log.Printf("failed to send link-rot alert: %v", err)
+		}
+	}
+	return len(stale), nil
+}
+
+// Check requests a destination. It is broken if it can't be reached, is
+// gone or answers with a server error; other statuses, such as a login
+// wall, mean the page still exists.
+func (c *RotChecker) Check(ctx context.Context, dest string) *storage.DestinationCheck {
+	status, err := probe(ctx, c.Client, dest)
+	check := &storage.DestinationCheck{
+		Status:    status,
+		Result:    storage.CheckOK,
+		CheckedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	if err != nil || status == http.StatusNotFound || status == http.StatusGone || status >= 500 {
+		check.Result = storage.CheckBroken
+	}
+	return check
+}
+
+// checkDetail describes why a destination is broken
+func checkDetail(check *storage.DestinationCheck) string {
+	if check.Error != "" {
+		return check.Error
+	}
+	return strconv.Itoa(check.Status) + " " + http.StatusText(check.Status)
+}
+
+// brokenAlert builds the alert about links whose destination broke
+func brokenAlert(links []alert.Link) alert.Alert {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d short links point to destinations that no longer resolve:\n\n", len(links))
+	for _, l := range links {
+		fmt.Fprintf(&b, "%s -> %s (%s)\n", l.Key, l.URL, l.Detail)
+	}
+	return alert.Alert{
+		Subject: fmt.Sprintf("%d broken short links", len(links)),
+		Text:    b.String(),
+		Links:   links,
+		Time:    time.Now().UTC(),
+	}
+}
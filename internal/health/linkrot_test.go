@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/alert"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// alertRecorder collects the alerts sent to it
+type alertRecorder struct {
+	alerts []alert.Alert
+}
+
+func (r *alertRecorder) Send(_ context.Context, a alert.Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestRotChecker_CheckStale(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/private":
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "live0001", &storage.Record{URL: server.URL + "/live", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "gone0001", &storage.Record{URL: server.URL + "/gone", Owner: "alice", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "priv0001", &storage.Record{URL: server.URL + "/private", CreatedAt: now}))
+
+	alerts := &alertRecorder{}
+	checker := NewRotChecker(store, time.Hour, 0, alerts)
+	checker.Client = http.DefaultClient
+
+	checked, err := checker.CheckStale(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, checked)
+
+	status := func(key string) *storage.DestinationCheck {
+		rec, err := store.GetRecord(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, rec.Check)
+		return rec.Check
+	}
+	assert.Equal(t, storage.CheckOK, status("live0001").Result)
+	assert.Equal(t, storage.CheckOK, status("priv0001").Result, "pages behind a login still exist")
+	gone := status("gone0001")
+	assert.Equal(t, storage.CheckBroken, gone.Result)
+	assert.Equal(t, http.StatusGone, gone.Status)
+
+	require.Len(t, alerts.alerts, 1)
+	assert.Equal(t, []alert.Link{{Key: "gone0001", URL: server.URL + "/gone", Owner: "alice", Detail: "410 Gone"}}, alerts.alerts[0].Links)
+
+	// Recently checked links wait for the interval, and links that stay
+	// broken aren't alerted about again
+	checked, err = checker.CheckStale(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, checked)
+
+	checker.interval = time.Nanosecond
+	_, err = checker.CheckStale(ctx)
+	require.NoError(t, err)
+	assert.Len(t, alerts.alerts, 1)
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// owner returns the subject new links should be owned by
+func owner(c *gin.Context) string {
+	if principal := auth.PrincipalFrom(c); principal != nil {
+		return principal.Subject
+	}
+	return ""
+}
+
+// workspace returns the workspace new links and domains should belong to
+func workspace(c *gin.Context) string {
+	if principal := auth.PrincipalFrom(c); principal != nil {
+		return principal.Workspace
+	}
+	return ""
+}
+
+// canManage reports whether the caller may perform owner-only operations on
+// a link. Members of a workspace manage every link in it. Without
+// authentication configured every caller is trusted.
+func (h *Handler) canManage(c *gin.Context, rec *storage.Record) bool {
+	return h.ownedBy(c, rec.Owner, rec.Workspace)
+}
+
+// ownedBy reports whether the caller may manage a resource created by the
+// owner subject within a workspace
+func (h *Handler) ownedBy(c *gin.Context, owner, workspace string) bool {
+	if h.auth == nil {
+		return true
+	}
+
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		return false
+	}
+	return principal.Admin ||
+		(owner != "" && owner == principal.Subject) ||
+		inWorkspace(principal, workspace)
+}
+
+// canAccessWorkspace reports whether the caller may act on resources
+// belonging to a workspace. Resources outside any workspace are shared.
+func (h *Handler) canAccessWorkspace(c *gin.Context, name string) bool {
+	if h.auth == nil || name == "" {
+		return true
+	}
+
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		return false
+	}
+	return principal.Admin || inWorkspace(principal, name)
+}
+
+// inWorkspace reports whether the principal is a member of a workspace
+func inWorkspace(principal *auth.Principal, name string) bool {
+	return name != "" && principal.Workspace == name
+}
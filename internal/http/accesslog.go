@@ -0,0 +1,37 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/accesslog"
+)
+
+// WithAccessLog writes every redirect request to an access log, kept
+// separate from the application logs
+func WithAccessLog(l *accesslog.Logger) Option {
+	return func(h *Handler) {
+		h.accessLog = l
+	}
+}
+
+// logAccess records the request once the rest of the chain has served it
+func (h *Handler) logAccess(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	h.accessLog.Log(accesslog.Entry{
+		RemoteAddr: c.ClientIP(),
+		Time:       start,
+		Method:     c.Request.Method,
+		URI:        c.Request.RequestURI,
+		Proto:      c.Request.Proto,
+		Status:     c.Writer.Status(),
+		Size:       c.Writer.Size(),
+		Referer:    c.Request.Referer(),
+		UserAgent:  c.Request.UserAgent(),
+		Key:        c.Param("key"),
+		Location:   c.Writer.Header().Get("Location"),
+	})
+}
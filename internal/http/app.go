@@ -0,0 +1,56 @@
+package http
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AppPath is where the dashboard is served
+const AppPath = "/app"
+
+// WithApp serves the dashboard, a single-page app, from fsys under /app/
+func WithApp(fsys fs.FS) Option {
+	return func(h *Handler) {
+		h.app = fsys
+	}
+}
+
+// App serves a file of the dashboard. Paths that aren't files, such as the
+// app's own client-side routes, get index.html so they survive a reload;
+// missing files with an extension are not found rather than a page.
+func (h *Handler) App(c *gin.Context) {
+	name := strings.TrimPrefix(path.Clean(c.Param("path")), "/")
+	if name == "" || name == "." {
+		name = "index.html"
+	}
+
+	if info, err := fs.Stat(h.app, name); err != nil || info.IsDir() {
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if path.Ext(name) != "" && name != "index.html" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		name = "index.html"
+	}
+
+	// Built assets have content hashes in their names, so they never change
+	if strings.HasPrefix(name, "assets/") {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	http.ServeFileFS(c.Writer, c.Request, h.app, name)
+}
+
+// redirectToApp adds the trailing slash the dashboard's relative paths need
+func (h *Handler) redirectToApp(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, AppPath+"/")
+}
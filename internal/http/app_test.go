@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+)
+
+func TestApp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewHandler(nil, id.NewGenerator(), "http://localhost:8080", WithApp(fstest.MapFS{
+		"index.html":           {Data: []byte("<!DOCTYPE html><title>Dashboard</title>")},
+		"favicon.svg":          {Data: []byte("<svg></svg>")},
+		"assets/index-1a2b.js": {Data: []byte("console.log(1)")},
+	})).SetupRoutes(router)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		expectedCache  string
+	}{
+		{"Index", http.MethodGet, "/app/", http.StatusOK, "<title>Dashboard</title>", "no-cache"},
+		{"Client-side route", http.MethodGet, "/app/urls/abc123", http.StatusOK, "<title>Dashboard</title>", "no-cache"},
+		{"Static file", http.MethodGet, "/app/favicon.svg", http.StatusOK, "<svg></svg>", "no-cache"},
+		{"Hashed asset", http.MethodGet, "/app/assets/index-1a2b.js", http.StatusOK, "console.log(1)", "public, max-age=31536000, immutable"},
+		{"Missing asset", http.MethodGet, "/app/assets/index-ffff.js", http.StatusNotFound, "", ""},
+		{"Escaping the app", http.MethodGet, "/app/../../etc/passwd", http.StatusBadRequest, "", ""},
+		{"Head", http.MethodHead, "/app/", http.StatusOK, "", "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+			assert.Equal(t, tt.expectedCache, w.Header().Get("Cache-Control"))
+		})
+	}
+
+	t.Run("Trailing slash", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app", nil))
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/app/", w.Header().Get("Location"))
+	})
+}
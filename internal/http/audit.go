@@ -0,0 +1,124 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// DefaultAuditLimit is the number of audit entries returned when no limit is given
+const DefaultAuditLimit = 50
+
+// AuditResponse represents a page of the audit log
+type AuditResponse struct {
+	Entries []storage.AuditEntry `json:"entries"`
+
+	// Next is the cursor for the following, older page, if there may be one
+	Next string `json:"next,omitempty"`
+}
+
+// WithAuditLog records every change to a link in an append-only audit log
+// and lets admins query it
+func WithAuditLog(store storage.AuditStore) Option {
+	return func(h *Handler) {
+		h.auditLog = store
+	}
+}
+
+// snapshot captures a link as it is now, before a handler changes it in
+// place. It returns nil when auditing is disabled.
+func (h *Handler) snapshot(rec *storage.Record) json.RawMessage {
+	if h.auditLog == nil || rec == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// audit records a change to a link made by the caller. The change has
+// already been made, so failures are logged rather than failing the request.
+func (h *Handler) audit(c *gin.Context, action storage.AuditAction, key string, before json.RawMessage, after *storage.Record) {
+	if h.auditLog == nil {
+		return
+	}
+	h.appendAudit(c, h.auditEntry(c, action, key, before, after))
+}
+
+// auditEntry builds the audit entry for a change made by the caller
+func (h *Handler) auditEntry(c *gin.Context, action storage.AuditAction, key string, before json.RawMessage, after *storage.Record) *storage.AuditEntry {
+	return &storage.AuditEntry{
+		Time:   time.Now().UTC(),
+		Actor:  owner(c),
+		IP:     c.ClientIP(),
+		Action: action,
+		Key:    key,
+		Before: before,
+		After:  h.snapshot(after),
+	}
+}
+
+// appendAudit appends entries to the audit log, logging failures
+func (h *Handler) appendAudit(c *gin.Context, entries ...*storage.AuditEntry) {
+	if h.auditLog == nil || len(entries) == 0 {
+		return
+	}
+	if err := h.auditLog.AppendAudit(c.Request.Context(), entries...); err != nil {
+		log.Printf("failed to record %d audit entries: %v", len(entries), err)
+	}
+}
+
+// ListAudit returns audit entries newest first, optionally filtered by key,
+// actor, action and time range. Pass the returned next cursor as before to
+// read older entries.
+func (h *Handler) ListAudit(c *gin.Context) {
+	q := storage.AuditQuery{
+		Key:    h.foldKey(c.Query("key")),
+		Actor:  c.Query("actor"),
+		Action: storage.AuditAction(c.Query("action")),
+		Before: c.Query("before"),
+		Limit:  DefaultAuditLimit,
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + ". Must be an RFC 3339 time"})
+				return
+			}
+			*t = parsed
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > storage.MaxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		q.Limit = n
+	}
+
+	entries, err := h.auditLog.ListAudit(c.Request.Context(), q)
+	if err == storage.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audit log"})
+		return
+	}
+
+	response := AuditResponse{Entries: entries}
+	if len(entries) == q.Limit {
+		response.Next = entries[len(entries)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// LoginRequest represents the request body for dashboard login
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// SessionResponse represents an active dashboard session
+type SessionResponse struct {
+	Subject   string `json:"subject"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+// WithAuth enables API key and dashboard session authentication
+func WithAuth(m *auth.Manager) Option {
+	return func(h *Handler) {
+		h.auth = m
+	}
+}
+
+// Login handles dashboard login and starts a cookie session
+func (h *Handler) Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+
+	sess, err := h.auth.Login(c.Request.Context(), req.Username, req.Password)
+	if err == auth.ErrInvalidCredentials {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	h.auth.SetCookie(c, sess)
+	c.JSON(http.StatusOK, SessionResponse{
+		Subject:   sess.Subject,
+		CSRFToken: sess.CSRFToken,
+	})
+}
+
+// Logout ends the current dashboard session
+func (h *Handler) Logout(c *gin.Context) {
+	principal := auth.PrincipalFrom(c)
+	if !principal.IsBrowser() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not a session"})
+		return
+	}
+
+	if err := h.auth.Logout(c.Request.Context(), principal.SessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+
+	h.auth.ClearCookie(c)
+	c.Status(http.StatusNoContent)
+}
+
+// GetSession returns the current dashboard session so the frontend can recover its CSRF token
+func (h *Handler) GetSession(c *gin.Context) {
+	principal := auth.PrincipalFrom(c)
+	if !principal.IsBrowser() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not a session"})
+		return
+	}
+
+	sess, err := h.auth.Session(c.Request.Context(), principal.SessionID)
+	if err == storage.ErrSessionNotFound {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Subject:   sess.Subject,
+		CSRFToken: sess.CSRFToken,
+	})
+}
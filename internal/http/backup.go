@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/backup"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// BackupURLs streams a backup of every link with its whole record, or with
+// since only of the links created since then
+func (h *Handler) BackupURLs(c *gin.Context) {
+	var since *time.Time
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since. Must be an RFC 3339 time"})
+			return
+		}
+		since = &t
+	}
+
+	// Headers are only committed once the backup writes, so a store failure
+	// up front still gets an error response
+	w := &lazyWriter{start: func() {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="backup-`+time.Now().UTC().Format("20060102T150405Z")+`.jsonl"`)
+		c.Status(http.StatusOK)
+	}, w: c.Writer}
+	written, err := backup.Write(c.Request.Context(), w, h.exporter, since)
+	switch {
+	case err != nil && !w.started:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to back up links"})
+	case err != nil:
+		// The response is already committed; the client sees a truncated body
+		log.Printf("backup failed after %d links: %v", written, err)
+	}
+}
+
+// Errors reported for backup entries refused like imported rows
+var (
+	errRestoreKey  = errors.New("invalid key")
+	errRestoreURL  = errors.New("invalid url")
+	errRestoreHTTP = errors.New("plain http not allowed")
+)
+
+// RestoreURLs creates the links of a backup in the request body, leaving
+// existing keys untouched
+func (h *Handler) RestoreURLs(c *gin.Context) {
+	restore := checkedRestore{BulkStore: h.bulk, h: h, c: c, upgraded: map[string]string{}}
+	res, err := backup.Restore(c.Request.Context(), c.Request.Body, restore)
+	switch {
+	case errors.Is(err, backup.ErrInvalidBackup) || errors.Is(err, bufio.ErrTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid backup: " + err.Error(), "restored": res.Restored})
+	case err != nil:
+		log.Printf("restore failed after %d links: %v", res.Restored, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore links", "restored": res.Restored})
+	default:
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// checkedRestore refuses the links of a restore whose key or destinations
+// an import would refuse, and records the links it creates in the audit
+// log. Each destination is checked against the plain-HTTP policy once,
+// with the results kept in upgraded.
+type checkedRestore struct {
+	storage.BulkStore
+	h        *Handler
+	c        *gin.Context
+	upgraded map[string]string
+}
+
+func (r checkedRestore) CreateMany(ctx context.Context, items []storage.BulkRecord) ([]error, error) {
+	errs := make([]error, len(items))
+	var valid []storage.BulkRecord
+	var positions []int
+	for i, item := range items {
+		if errs[i] = r.check(ctx, item); errs[i] == nil {
+			valid = append(valid, item)
+			positions = append(positions, i)
+		}
+	}
+	if len(valid) == 0 {
+		return errs, nil
+	}
+
+	created, err := r.BulkStore.CreateMany(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*storage.AuditEntry
+	for i, err := range created {
+		errs[positions[i]] = err
+		if err == nil && r.h.auditLog != nil {
+			entries = append(entries, r.h.auditEntry(r.c, storage.AuditRestore, valid[i].Key, nil, valid[i].Record))
+		}
+	}
+	r.h.appendAudit(r.c, entries...)
+	return errs, nil
+}
+
+// check validates a backup entry's key and destinations like an imported
+// row's, so a backup can't write outside the links' keyspace
+func (r checkedRestore) check(ctx context.Context, item storage.BulkRecord) error {
+	if !r.h.validKey(item.Key) {
+		return errRestoreKey
+	}
+	dests := destinationFields(item.Record)
+	for _, dest := range dests {
+		if !validDestination(*dest) {
+			return errRestoreURL
+		}
+	}
+	if !r.h.applyHTTPPolicy(ctx, dests, r.upgraded) {
+		return errRestoreHTTP
+	}
+	return nil
+}
+
+// lazyWriter calls start before its first write
+type lazyWriter struct {
+	start   func()
+	started bool
+	w       gin.ResponseWriter
+}
+
+func (l *lazyWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.started = true
+		l.start()
+	}
+	return l.w.Write(p)
+}
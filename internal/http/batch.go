@@ -0,0 +1,205 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/domain"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// MaxBatchDelete is the most links a single batch delete removes. Filters
+// matching more are deleted in several requests.
+const MaxBatchDelete = storage.MaxSearchLimit
+
+// BatchDeleteRequest represents the request body for deleting many links,
+// either listed by key or matched by a filter
+type BatchDeleteRequest struct {
+	Keys   []string      `json:"keys"`
+	Filter *DeleteFilter `json:"filter"`
+
+	// DryRun reports what would be deleted without deleting anything
+	DryRun bool `json:"dry_run"`
+}
+
+// DeleteFilter selects links to delete. Every set field must match.
+type DeleteFilter struct {
+	Tag           string     `json:"tag"`
+	Domain        string     `json:"domain"`
+	CreatedBefore *time.Time `json:"created_before"`
+}
+
+// BatchDeleteResponse represents the outcome of a batch delete
+type BatchDeleteResponse struct {
+	DryRun  bool               `json:"dry_run"`
+	Deleted int                `json:"deleted"`
+	Links   []URLResponse      `json:"links"`
+	Errors  []BatchDeleteError `json:"errors,omitempty"`
+
+	// More reports that the filter may match further links
+	More bool `json:"more,omitempty"`
+}
+
+// BatchDeleteError reports a listed key that could not be deleted
+type BatchDeleteError struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// DeleteURLs deletes the listed links, or up to MaxBatchDelete links
+// matching a filter. Callers may only delete links they could delete one by
+// one; listed links they may not manage are reported as errors.
+func (h *Handler) DeleteURLs(c *gin.Context) {
+	var req BatchDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	if (len(req.Keys) > 0) == (req.Filter != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of keys or filter is required"})
+		return
+	}
+
+	response := BatchDeleteResponse{DryRun: req.DryRun, Links: []URLResponse{}}
+	var targets []storage.SearchResult
+	if req.Filter != nil {
+		q, ok := deleteQuery(req.Filter)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter. Set at least one of tag, domain or created_before"})
+			return
+		}
+		if !h.scopeSearch(c, &q) {
+			return
+		}
+		results, err := h.store.Search(c.Request.Context(), q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search URLs"})
+			return
+		}
+		targets = results
+		response.More = len(results) == MaxBatchDelete
+	} else {
+		if len(req.Keys) > MaxBatchDelete {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Too many keys. At most 500 may be deleted at once"})
+			return
+		}
+		for i, key := range req.Keys {
+			req.Keys[i] = h.foldKey(key)
+		}
+		keys := dedupe(req.Keys)
+		recs, readErrs, err := h.batchRecords(c, keys)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URLs"})
+			return
+		}
+		for i, key := range keys {
+			if errMsg := h.batchError(c, key, recs[i], readErrs[i]); errMsg != "" {
+				response.Errors = append(response.Errors, BatchDeleteError{Key: key, Error: errMsg})
+				continue
+			}
+			targets = append(targets, storage.SearchResult{Key: key, Record: recs[i]})
+		}
+	}
+
+	for _, t := range targets {
+		if !req.DryRun {
+			err := h.store.Delete(c.Request.Context(), t.Key)
+			if err == storage.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				response.Errors = append(response.Errors, BatchDeleteError{Key: t.Key, Error: "Failed to delete URL"})
+				continue
+			}
+			h.purgeLink(t.Key, t.Record)
+			h.audit(c, storage.AuditDelete, t.Key, h.snapshot(t.Record), nil)
+		}
+		response.Links = append(response.Links, h.newURLResponse(t.Key, t.Record, nil))
+	}
+	response.Deleted = len(response.Links)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// batchRecords reads listed links in one go, in order, with nil for keys
+// holding none and an error for links that can't be read. Invalid keys
+// aren't read, since they may name auxiliary keys.
+func (h *Handler) batchRecords(c *gin.Context, keys []string) ([]*storage.Record, []error, error) {
+	var valid []string
+	for _, key := range keys {
+		if h.validKey(key) {
+			valid = append(valid, key)
+		}
+	}
+	found, foundErrs, err := h.store.GetMulti(c.Request.Context(), valid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recs := make([]*storage.Record, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if len(valid) > 0 && valid[0] == key {
+			recs[i], errs[i] = found[0], foundErrs[0]
+			found, foundErrs, valid = found[1:], foundErrs[1:], valid[1:]
+		}
+	}
+	return recs, errs, nil
+}
+
+// batchError returns the reason a listed link, read as rec or failing to
+// be read with readErr, can't be deleted, or "" if it can
+func (h *Handler) batchError(c *gin.Context, key string, rec *storage.Record, readErr error) string {
+	if !h.validKey(key) {
+		return "Invalid URL key format"
+	}
+	if readErr != nil {
+		return "Failed to retrieve URL"
+	}
+	if rec == nil {
+		return "URL not found"
+	}
+	if !h.canManage(c, rec) {
+		return "Only the link owner or an admin may do this"
+	}
+	return ""
+}
+
+// deleteQuery turns a delete filter into a search, reporting false if the
+// filter is empty or invalid
+func deleteQuery(f *DeleteFilter) (storage.SearchQuery, bool) {
+	q := storage.SearchQuery{Limit: MaxBatchDelete}
+	if f.Tag != "" {
+		tags, ok := normalizeTags([]string{f.Tag})
+		if !ok {
+			return q, false
+		}
+		q.Tag = tags[0]
+	}
+	if f.Domain != "" {
+		q.Host = domain.Normalize(f.Domain)
+		if q.Host == "" || strings.ContainsAny(q.Host, "/:@ ") {
+			return q, false
+		}
+	}
+	if f.CreatedBefore != nil {
+		q.CreatedBefore = *f.CreatedBefore
+	}
+	return q, q.Tag != "" || q.Host != "" || !q.CreatedBefore.IsZero()
+}
+
+// dedupe returns the keys without repeats, in their original order
+func dedupe(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	unique := keys[:0:0]
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+// BotMode selects how redirect requests from bots are handled
+type BotMode string
+
+// Supported bot handling modes
+const (
+	// BotCount redirects bots and counts their clicks like any other visitor
+	BotCount BotMode = "count"
+
+	// BotExclude redirects bots but leaves them out of link statistics
+	BotExclude BotMode = "exclude"
+
+	// BotPreview serves bots a metadata page instead of a redirect and
+	// leaves them out of link statistics
+	BotPreview BotMode = "preview"
+)
+
+// previewPage is served to bots instead of a redirect. Link-preview fetchers
+// read the Open Graph and Twitter Card tags; anything that renders the page
+// is sent on to the destination.
+const previewPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{or .Title .URL}}</title>
+  <meta property="og:url" content="{{.URL}}">
+  <meta property="og:title" content="{{or .Title .URL}}">
+  {{- with .Description}}
+  <meta property="og:description" content="{{.}}">
+  <meta name="description" content="{{.}}">
+  {{- end}}
+  {{- with .Image}}
+  <meta property="og:image" content="{{.}}">
+  {{- end}}
+  {{- with .SiteName}}
+  <meta property="og:site_name" content="{{.}}">
+  {{- end}}
+  <meta name="twitter:card" content="{{if .Image}}summary_large_image{{else}}summary{{end}}">
+  <link rel="canonical" href="{{.URL}}">
+  <meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body>
+  <p><a href="{{.URL}}">{{or .Title .URL}}</a></p>
+</body>
+</html>
+`
+
+var previewTemplate = template.Must(template.New("preview").Parse(previewPage))
+
+// PreviewPageData is the data passed to the bot preview page template
+type PreviewPageData struct {
+	Key         string
+	URL         string
+	Title       string
+	Description string
+	Image       string
+	SiteName    string
+}
+
+// WithBotFilter sets how redirect requests from detected bots are handled
+func WithBotFilter(detector *useragent.BotDetector, mode BotMode) Option {
+	return func(h *Handler) {
+		h.bots = detector
+		h.botMode = mode
+	}
+}
+
+// ParseBotMode parses a bot handling mode
+func ParseBotMode(spec string) (BotMode, error) {
+	switch mode := BotMode(spec); mode {
+	case BotCount, BotExclude, BotPreview:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown bot mode %q", spec)
+}
+
+// isBot reports whether the request comes from a bot the handler filters
+func (h *Handler) isBot(c *gin.Context) bool {
+	return h.bots != nil && h.botMode != BotCount && h.bots.IsBot(c.Request.UserAgent())
+}
+
+// renderPreview renders the bot preview page for a destination, including
+// the destination's cached metadata when available
+func renderPreview(c *gin.Context, key, dest string, preview *storage.Preview) {
+	data := PreviewPageData{Key: key, URL: dest}
+	if preview != nil {
+		data.Title = preview.Title
+		data.Description = preview.Description
+		data.Image = preview.Image
+		data.SiteName = preview.SiteName
+	}
+	renderHTML(c, http.StatusOK, previewTemplate, data)
+}
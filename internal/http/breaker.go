@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// WithCircuitBreaker answers API requests with 503 Service Unavailable at
+// once while the breaker guarding the store is open, instead of letting them
+// wait on timeouts. The breaker must guard the handler's store; redirects
+// keep being served from the records it last saw.
+func WithCircuitBreaker(breaker *storage.BreakerStore) Option {
+	return func(h *Handler) {
+		h.breaker = breaker
+	}
+}
+
+// failFast rejects requests while the circuit breaker is open
+func (h *Handler) failFast(c *gin.Context) {
+	if h.breaker != nil {
+		if open, _ := h.breaker.Open(); open {
+			h.unavailable(c)
+			return
+		}
+	}
+	c.Next()
+}
+
+// unavailable answers a request the store can't serve right now, telling
+// the client when the breaker next lets a call through
+func (h *Handler) unavailable(c *gin.Context) {
+	retry := 1
+	if h.breaker != nil {
+		_, wait := h.breaker.Open()
+		retry = int(wait.Seconds()) + 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retry))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Storage is temporarily unavailable. Try again later"})
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxCacheMaxAge is the longest a link may ask for its redirect to be cached
+const maxCacheMaxAge = 365 * 24 * 60 * 60
+
+// WithRedirectCache lets browsers and CDNs cache redirects for maxAge unless
+// a link sets its own cache_max_age. Zero keeps redirects uncached.
+func WithRedirectCache(maxAge time.Duration) Option {
+	return func(h *Handler) {
+		h.redirectMaxAge = int(maxAge / time.Second)
+	}
+}
+
+// validCacheMaxAge reports whether a link's cache lifetime is acceptable
+func validCacheMaxAge(maxAge *int) bool {
+	return maxAge == nil || (*maxAge >= 0 && *maxAge <= maxCacheMaxAge)
+}
+
+// redirectStatus returns the status code to redirect to a link's destination with
+func redirectStatus(rec *storage.Record) int {
+	if rec.Permanent {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusFound
+}
+
+// setRedirectCacheHeaders sets Cache-Control and Expires on a redirect.
+// Redirects that pick a variant at random, may fail over or are counted
+// against a limit are never cached, those that depend on the device vary
+// by User-Agent, and no redirect is cached past the end of the link's
+// activation window or its next scheduled rotation.
+func (h *Handler) setRedirectCacheHeaders(c *gin.Context, rec *storage.Record, now time.Time) {
+	maxAge := h.redirectMaxAge
+	if rec.CacheMaxAge != nil {
+		maxAge = *rec.CacheMaxAge
+	}
+	if len(rec.Variants) > 0 || len(rec.Fallbacks) > 0 || len(rec.Rules) > 0 || rec.SingleUse || rec.MaxClicks > 0 {
+		maxAge = 0
+	}
+	for _, until := range []*time.Time{rec.ActiveUntil, rec.NextScheduleChange(now)} {
+		if until == nil {
+			continue
+		}
+		if remaining := int(until.Sub(now) / time.Second); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+
+	if maxAge <= 0 {
+		c.Header("Cache-Control", "private, max-age=0")
+		c.Header("Expires", now.UTC().Format(http.TimeFormat))
+		return
+	}
+	if len(rec.DeviceRules) > 0 {
+		c.Writer.Header().Add("Vary", "User-Agent")
+	}
+	if len(rec.LocaleRules) > 0 {
+		c.Writer.Header().Add("Vary", "Accept-Language")
+	}
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	c.Header("Expires", now.Add(time.Duration(maxAge)*time.Second).UTC().Format(http.TimeFormat))
+}
+
+// writeConditionalJSON writes a JSON response with an ETag, answering 304
+// Not Modified when the request's If-None-Match already names it
+func writeConditionalJSON(c *gin.Context, status int, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(status, "application/json; charset=utf-8", data)
+}
+
+// etagMatches reports whether an If-None-Match header names the ETag,
+// using the weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// MaxCampaignLinks is the most links added to a campaign per request
+const MaxCampaignLinks = storage.MaxSearchLimit
+
+// CampaignRequest represents the request body for creating a campaign,
+// optionally with its first links
+type CampaignRequest struct {
+	Name string   `json:"name" binding:"required"`
+	Keys []string `json:"keys"`
+}
+
+// CampaignLinksRequest represents the request body for adding links to a campaign
+type CampaignLinksRequest struct {
+	Keys []string `json:"keys" binding:"required"`
+}
+
+// CampaignResponse represents a campaign and the keys of its links. Errors
+// report listed links that could not be added.
+type CampaignResponse struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Workspace string             `json:"workspace,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	Keys      []string           `json:"keys"`
+	Errors    []BatchDeleteError `json:"errors,omitempty"`
+}
+
+// CampaignStatsResponse represents the roll-up statistics of a campaign
+type CampaignStatsResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	*storage.CampaignStats
+}
+
+// WithCampaigns enables grouping links into campaigns with roll-up statistics
+func WithCampaigns(campaigns storage.CampaignStore) Option {
+	return func(h *Handler) {
+		h.campaigns = campaigns
+	}
+}
+
+// CreateCampaign creates a campaign owned by the caller, adding the listed links
+func (h *Handler) CreateCampaign(c *gin.Context) {
+	var req CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || !validLabel(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid name. Must be 1 to 100 characters on one line"})
+		return
+	}
+	if len(req.Keys) > MaxCampaignLinks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many keys. At most 500 may be added at once"})
+		return
+	}
+
+	id, err := newCampaignID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		return
+	}
+	campaign := &storage.Campaign{
+		ID:        id,
+		Name:      req.Name,
+		Owner:     owner(c),
+		Workspace: workspace(c),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.campaigns.CreateCampaign(c.Request.Context(), campaign); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create campaign"})
+		return
+	}
+
+	h.respondCampaign(c, http.StatusCreated, campaign, req.Keys)
+}
+
+// GetCampaign returns a campaign and the keys of its links
+func (h *Handler) GetCampaign(c *gin.Context) {
+	campaign := h.managedCampaign(c)
+	if campaign == nil {
+		return
+	}
+
+	h.respondCampaign(c, http.StatusOK, campaign, nil)
+}
+
+// AddCampaignLinks adds the listed links to a campaign. Callers may only add
+// links they may manage; others are reported as errors.
+func (h *Handler) AddCampaignLinks(c *gin.Context) {
+	campaign := h.managedCampaign(c)
+	if campaign == nil {
+		return
+	}
+
+	var req CampaignLinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	if len(req.Keys) > MaxCampaignLinks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many keys. At most 500 may be added at once"})
+		return
+	}
+
+	h.respondCampaign(c, http.StatusOK, campaign, req.Keys)
+}
+
+// GetCampaignStats returns the number of links in a campaign and their
+// clicks in the current measurement period, in total and per link
+func (h *Handler) GetCampaignStats(c *gin.Context) {
+	campaign := h.managedCampaign(c)
+	if campaign == nil {
+		return
+	}
+
+	stats, err := h.campaigns.GetCampaignStats(c.Request.Context(), campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, CampaignStatsResponse{ID: campaign.ID, Name: campaign.Name, CampaignStats: stats})
+}
+
+// respondCampaign adds the listed links the caller may manage to a campaign
+// and writes the campaign with its links
+func (h *Handler) respondCampaign(c *gin.Context, status int, campaign *storage.Campaign, keys []string) {
+	response := CampaignResponse{
+		ID:        campaign.ID,
+		Name:      campaign.Name,
+		Workspace: campaign.Workspace,
+		CreatedAt: campaign.CreatedAt,
+	}
+
+	var added []string
+	for i, key := range keys {
+		keys[i] = h.foldKey(key)
+	}
+	keys = dedupe(keys)
+	recs, readErrs, err := h.batchRecords(c, keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URLs"})
+		return
+	}
+	for i, key := range keys {
+		if errMsg := h.batchError(c, key, recs[i], readErrs[i]); errMsg != "" {
+			response.Errors = append(response.Errors, BatchDeleteError{Key: key, Error: errMsg})
+			continue
+		}
+		added = append(added, key)
+	}
+	if err := h.campaigns.AddCampaignLinks(c.Request.Context(), campaign.ID, added); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add links to campaign"})
+		return
+	}
+
+	linked, err := h.campaigns.CampaignLinks(c.Request.Context(), campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve campaign"})
+		return
+	}
+	response.Keys = append([]string{}, linked...)
+
+	c.JSON(status, response)
+}
+
+// managedCampaign loads a campaign and checks the caller may manage it. It
+// writes the error response and returns nil on failure.
+func (h *Handler) managedCampaign(c *gin.Context) *storage.Campaign {
+	campaign, err := h.campaigns.GetCampaign(c.Request.Context(), c.Param("campaign"))
+	if err == storage.ErrCampaignNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve campaign"})
+		return nil
+	}
+
+	if !h.ownedBy(c, campaign.Owner, campaign.Workspace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the campaign owner or an admin may do this"})
+		return nil
+	}
+	return campaign
+}
+
+// newCampaignID returns a random campaign ID
+func newCampaignID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
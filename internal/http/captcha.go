@@ -0,0 +1,66 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/captcha"
+)
+
+// captchaRequest is the part of a creation request carrying the CAPTCHA
+// token, whatever the rest of the body holds
+type captchaRequest struct {
+	CaptchaToken string `json:"captcha_token"`
+}
+
+// WithCaptcha requires anonymous callers to pass a solved CAPTCHA token as
+// captcha_token in the body of every link creation, checked by verifier.
+// Authenticated callers are trusted.
+func WithCaptcha(verifier captcha.Verifier) Option {
+	return func(h *Handler) {
+		h.captcha = verifier
+	}
+}
+
+// requireCaptcha rejects anonymous creations without a valid CAPTCHA token
+func (h *Handler) requireCaptcha(c *gin.Context) {
+	// Dry runs store nothing, so forms can be checked before the CAPTCHA
+	// is solved
+	if auth.PrincipalFrom(c) != nil || dryRun(c) {
+		c.Next()
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req captchaRequest
+	json.Unmarshal(body, &req)
+	if req.CaptchaToken == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "captcha_token is required"})
+		return
+	}
+
+	err = h.captcha.Verify(c.Request.Context(), req.CaptchaToken, c.ClientIP())
+	if errors.Is(err, captcha.ErrRejected) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "CAPTCHA verification failed"})
+		return
+	}
+	if err != nil {
+		log.Printf("failed to verify captcha: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify CAPTCHA"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithCaseInsensitiveKeys makes keys case-insensitive: keys are stored in
+// lowercase and looked up in lowercase whatever case they arrive in. The
+// generator must issue lowercase keys as well.
+func WithCaseInsensitiveKeys() Option {
+	return func(h *Handler) {
+		h.foldKeys = true
+	}
+}
+
+// foldKey returns a key as it is stored
+func (h *Handler) foldKey(key string) string {
+	if h.foldKeys {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+// foldKeyParam lowercases the key route parameter before any handler reads it
+func (h *Handler) foldKeyParam(c *gin.Context) {
+	for i, p := range c.Params {
+		if p.Key == "key" {
+			c.Params[i].Value = strings.ToLower(p.Value)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/chain"
+)
+
+// WithChainResolution stores the final destination of links pointing at
+// other short links, instead of redirecting visitors through every hop
+func WithChainResolution(r *chain.Resolver) Option {
+	return func(h *Handler) {
+		h.chains = r
+	}
+}
+
+// resolveChain replaces a destination on a known shortener by where its
+// redirects lead. It writes an error response and returns false if they
+// can't be followed.
+func (h *Handler) resolveChain(c *gin.Context, dest *string) bool {
+	if h.chains == nil || !h.chains.IsShortener(*dest) {
+		return true
+	}
+
+	final, _, err := h.chains.Resolve(c.Request.Context(), *dest)
+	switch {
+	case err == chain.ErrLoop:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Its short links redirect in a loop"})
+		return false
+	case err == chain.ErrTooManyHops:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. It redirects through too many short links"})
+		return false
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to resolve short link"})
+		return false
+	}
+	if !validDestination(final) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Its short link leads to an invalid destination"})
+		return false
+	}
+	*dest = canonicalDestination(final)
+	return h.secureDestinations(c, []*string{dest})
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// WithClickLimits lets links be created with max_clicks, no longer
+// redirecting once they have been followed that many times
+func WithClickLimits(limiter storage.ClickLimiter) Option {
+	return func(h *Handler) {
+		h.clickLimits = limiter
+	}
+}
+
+// validMaxClicks checks a requested click limit, answering the request
+// itself if it can't be set
+func (h *Handler) validMaxClicks(c *gin.Context, maxClicks int) bool {
+	if maxClicks < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_clicks. Must not be negative"})
+		return false
+	}
+	if maxClicks > 0 && h.clickLimits == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Click limits are not enabled"})
+		return false
+	}
+	return true
+}
+
+// claimClick counts a redirect of a link with a click limit, answering the
+// request itself if the link has used up its clicks. HEAD requests, bots
+// and link-preview fetchers don't count, like for single-use links, but
+// aren't redirected past the limit either.
+func (h *Handler) claimClick(c *gin.Context, key string, rec *storage.Record) bool {
+	if h.clickLimits == nil {
+		return true
+	}
+	var err error
+	if h.notAVisit(c) {
+		err = h.clickLimits.CheckClickLimit(c.Request.Context(), key, rec.MaxClicks)
+	} else {
+		err = h.clickLimits.ClaimClick(c.Request.Context(), key, rec.MaxClicks)
+	}
+	switch err {
+	case nil:
+		return true
+	case storage.ErrClickLimitReached:
+		h.renderInactive(c, InactivePageData{Key: key})
+	case storage.ErrNotFound:
+		h.notFound(c, key, "URL not found")
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// CloneRequest represents the optional request body for cloning a link
+type CloneRequest struct {
+	// Key is the custom key of the clone; a key is generated if empty
+	Key        string `json:"key"`
+	OnConflict string `json:"on_conflict"`
+
+	// Workspace is the workspace the clone belongs to, by default the
+	// workspace of the cloned link
+	Workspace string `json:"workspace"`
+
+	// Email is where anonymous callers receive the link verifying their
+	// email, when email verification is enabled
+	Email string `json:"email"`
+}
+
+// CloneURL duplicates a link's configuration under a new key
+func (h *Handler) CloneURL(c *gin.Context) {
+	if key, rec, ok := h.cloneLink(c); ok {
+		c.JSON(http.StatusCreated, h.urlResponse(c, key, rec))
+	}
+}
+
+// cloneLink stores a copy of a link's destination, rules, tags and expiry
+// policy under a new key, answering the request itself if it can't. The
+// clone starts afresh: it belongs to the caller, and has no clicks, health
+// checks or former keys yet.
+func (h *Handler) cloneLink(c *gin.Context) (string, *storage.Record, bool) {
+	var req CloneRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		h.invalidBody(c, err)
+		return "", nil, false
+	}
+
+	_, source := h.managedRecord(c)
+	if source == nil {
+		return "", nil, false
+	}
+
+	clone := *source
+	clone.Owner = owner(c)
+	clone.CreatedAt = time.Now().UTC()
+	clone.Down = nil
+	clone.Check = nil
+	clone.Aliases = nil
+
+	if req.Workspace != "" && req.Workspace != source.Workspace {
+		if !auth.ValidWorkspace(req.Workspace) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace name"})
+			return "", nil, false
+		}
+		if !h.canAccessWorkspace(c, req.Workspace) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only workspace members or an admin may do this"})
+			return "", nil, false
+		}
+		if !h.domainInWorkspace(c, clone.Domain, req.Workspace) {
+			return "", nil, false
+		}
+		clone.Workspace = req.Workspace
+	}
+	if !h.holdForVerification(c, req.Email, &clone) {
+		return "", nil, false
+	}
+
+	key, ok := h.storeLink(c, req.Key, req.OnConflict, &clone)
+	if !ok {
+		return "", nil, false
+	}
+
+	h.sendVerification(key, &clone)
+	if clone.Preview == nil {
+		h.prefetchPreview(key, &clone)
+	}
+	h.audit(c, storage.AuditCreate, key, nil, &clone)
+	h.meterUsage(&clone, metering.LinksCreated)
+
+	return key, &clone, true
+}
+
+// domainInWorkspace checks that links in a workspace may be served under a
+// custom domain, answering the request itself if they can't
+func (h *Handler) domainInWorkspace(c *gin.Context, name, workspace string) bool {
+	if name == "" || h.domains == nil {
+		return true
+	}
+	d, err := h.domains.Get(c.Request.Context(), name)
+	if err != nil && err != storage.ErrDomainNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check domain"})
+		return false
+	}
+	if err == storage.ErrDomainNotFound || (d.Workspace != "" && d.Workspace != workspace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Domain belongs to another workspace"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,335 @@
+package http
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/rules"
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/urlutil"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+const (
+	// maxQueryParams is the maximum number of templated query parameters per link
+	maxQueryParams = 20
+
+	// maxDeviceRules is the maximum number of device routing rules per link
+	maxDeviceRules = 10
+
+	// maxVariants is the maximum number of split test destinations per link
+	maxVariants = 10
+
+	// maxFallbacks is the maximum number of fallback destinations per link
+	maxFallbacks = 5
+
+	// variantCookiePrefix prefixes the cookie pinning a visitor to a variant
+	variantCookiePrefix = "variant_"
+
+	// variantCookieMaxAge is how long a visitor stays pinned to a variant (30 days)
+	variantCookieMaxAge = 30 * 24 * 60 * 60
+)
+
+// Route sources, naming what picked the destination of a request
+const (
+	RouteDefault  = "default"
+	RouteFallback = "fallback"
+	RouteSchedule = "schedule"
+	RouteRule     = "rule"
+	RouteDevice   = "device_rule"
+	RouteLocale   = "locale_rule"
+	RouteVariant  = "variant"
+)
+
+// route is how a request for a link is routed, before query parameters are
+// added to the destination
+type route struct {
+	URL    string
+	Source string
+
+	// Index is that of the rule, device rule or locale rule matched
+	Index int
+
+	// Variant is the split test variant served. With pin the visitor is
+	// pinned to it by a cookie.
+	Variant string
+	pin     bool
+}
+
+// destination returns the URL a request for the record should be redirected
+// to, and the name of the split test variant served, if any
+func (h *Handler) destination(c *gin.Context, key string, rec *storage.Record) (string, string, error) {
+	r := routeRequest(c.Request, key, rec, time.Now())
+	if r.pin {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(variantCookiePrefix+key, r.Variant, variantCookieMaxAge, h.redirects.Prefix+"/"+key, "", false, true)
+	}
+
+	dest, err := withQueryParams(r.URL, key, rec)
+	if err != nil {
+		return "", "", err
+	}
+	return dest, r.Variant, nil
+}
+
+// routeRequest picks the destination of a request for the record at a time
+func routeRequest(req *http.Request, key string, rec *storage.Record, now time.Time) route {
+	// Fall back while the health checker finds the destination down
+	r := route{URL: rec.HealthyURL(), Source: RouteDefault, Index: -1}
+	if r.URL != rec.URL {
+		r.Source = RouteFallback
+	}
+
+	// A scheduled destination replaces the default while it is in effect
+	scheduled, rotated := rec.ScheduledURL(now)
+	if rotated {
+		r.URL, r.Source = scheduled, RouteSchedule
+	}
+
+	// Rules take precedence over every other routing
+	if len(rec.Rules) > 0 {
+		if i, ok := rules.Evaluate(rec.Rules, rulesRequest(req, now)); ok {
+			return route{URL: rec.Rules[i].URL, Source: RouteRule, Index: i}
+		}
+	}
+
+	// Route by device when the link has platform-specific destinations
+	if len(rec.DeviceRules) > 0 {
+		platform := useragent.Detect(req.UserAgent())
+		for i, rule := range rec.DeviceRules {
+			if platform.Matches(useragent.Platform(rule.Platform)) {
+				return route{URL: rule.URL, Source: RouteDevice, Index: i}
+			}
+		}
+	}
+
+	// Then by the visitor's preferred languages
+	if len(rec.LocaleRules) > 0 {
+		if i, ok := localeRule(req.Header.Get("Accept-Language"), rec.LocaleRules); ok {
+			return route{URL: rec.LocaleRules[i].URL, Source: RouteLocale, Index: i}
+		}
+	}
+
+	// Split test the default destination
+	if !rotated && len(rec.Variants) > 0 {
+		v, pin := pickVariant(req, key, rec)
+		r = route{URL: v.URL, Source: RouteVariant, Index: -1, Variant: v.Name, pin: pin}
+	}
+	return r
+}
+
+// withQueryParams adds the record's templated query parameters to dest
+func withQueryParams(dest, key string, rec *storage.Record) (string, error) {
+	if len(rec.QueryParams) == 0 {
+		return dest, nil
+	}
+	vars := map[string]string{
+		"key":    key,
+		"domain": rec.Domain,
+	}
+	params := make(map[string]string, len(rec.QueryParams))
+	for name, value := range rec.QueryParams {
+		params[name] = urlutil.ExpandTemplate(value, vars)
+	}
+	return urlutil.MergeQuery(dest, params)
+}
+
+// pickVariant chooses a split test variant by weight. With sticky variants a
+// returning visitor gets the variant recorded in their cookie, and new
+// visitors are to be pinned to theirs.
+func pickVariant(req *http.Request, key string, rec *storage.Record) (storage.Variant, bool) {
+	if rec.StickyVariants {
+		if cookie, err := req.Cookie(variantCookiePrefix + key); err == nil {
+			for _, v := range rec.Variants {
+				if v.Name == cookie.Value {
+					return v, false
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range rec.Variants {
+		total += v.Weight
+	}
+	n := rand.IntN(total)
+	chosen := rec.Variants[len(rec.Variants)-1]
+	for _, v := range rec.Variants {
+		if n < v.Weight {
+			chosen = v
+			break
+		}
+		n -= v.Weight
+	}
+	return chosen, rec.StickyVariants
+}
+
+// normalizeVariants validates split test variants and names unnamed ones
+func normalizeVariants(variants []storage.Variant) bool {
+	if len(variants) > maxVariants {
+		return false
+	}
+
+	seen := make(map[string]bool, len(variants))
+	for i := range variants {
+		v := &variants[i]
+		if v.Name == "" {
+			v.Name = fmt.Sprintf("v%d", i+1)
+		}
+		if seen[v.Name] || v.Weight <= 0 || !validDestination(v.URL) {
+			return false
+		}
+		seen[v.Name] = true
+	}
+	return true
+}
+
+// validQueryParams checks templated query parameters supplied by the owner
+func validQueryParams(params map[string]string) bool {
+	if len(params) > maxQueryParams {
+		return false
+	}
+	for name := range params {
+		if name == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validDestination checks that a destination is an absolute http(s) URL,
+// whose host, if internationalized, converts to punycode
+func validDestination(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	_, err = urlutil.Canonical(raw)
+	return err == nil
+}
+
+// canonicalDestination returns the form of a valid destination to store,
+// with a punycode host and percent-encoded path
+func canonicalDestination(raw string) string {
+	if canonical, err := urlutil.Canonical(raw); err == nil {
+		return canonical
+	}
+	return raw
+}
+
+// displayURL returns the readable form of a stored destination, or "" if
+// it reads the same
+func displayURL(stored string) string {
+	if display := urlutil.Display(stored); display != stored {
+		return display
+	}
+	return ""
+}
+
+// canonicalizeDestinations puts every destination of a link in the form
+// to store
+func canonicalizeDestinations(rec *storage.Record) {
+	for _, dest := range destinationFields(rec) {
+		*dest = canonicalDestination(*dest)
+	}
+}
+
+// canonicalizeUpdate puts the destinations an update sets in the form to
+// store
+func canonicalizeUpdate(req *UpdateURLRequest) {
+	for _, dest := range updateFields(req) {
+		*dest = canonicalDestination(*dest)
+	}
+}
+
+// updateFields points at the destinations an update sets
+func updateFields(req *UpdateURLRequest) []*string {
+	// The record's URL is a copy, so the request's is used instead
+	fields := destinationFields(updateDestinations(*req))[1:]
+	if req.URL != nil {
+		fields = append(fields, req.URL)
+	}
+	return fields
+}
+
+// updateDestinations gathers the destinations an update sets into a
+// record. Its slices are the request's, so changing their destinations
+// changes the request's.
+func updateDestinations(req UpdateURLRequest) *storage.Record {
+	var rec storage.Record
+	if req.URL != nil {
+		rec.URL = *req.URL
+	}
+	if req.Fallbacks != nil {
+		rec.Fallbacks = *req.Fallbacks
+	}
+	if req.Variants != nil {
+		rec.Variants = *req.Variants
+	}
+	if req.Rules != nil {
+		rec.Rules = *req.Rules
+	}
+	if req.DeviceRules != nil {
+		rec.DeviceRules = *req.DeviceRules
+	}
+	if req.LocaleRules != nil {
+		rec.LocaleRules = *req.LocaleRules
+	}
+	return &rec
+}
+
+// destinationFields points at every destination a link may send visitors
+// to
+func destinationFields(rec *storage.Record) []*string {
+	fields := []*string{&rec.URL}
+	for i := range rec.Fallbacks {
+		fields = append(fields, &rec.Fallbacks[i])
+	}
+	for i := range rec.Variants {
+		fields = append(fields, &rec.Variants[i].URL)
+	}
+	for i := range rec.Schedule {
+		fields = append(fields, &rec.Schedule[i].URL)
+	}
+	for i := range rec.Rules {
+		fields = append(fields, &rec.Rules[i].URL)
+	}
+	for i := range rec.DeviceRules {
+		fields = append(fields, &rec.DeviceRules[i].URL)
+	}
+	for i := range rec.LocaleRules {
+		fields = append(fields, &rec.LocaleRules[i].URL)
+	}
+	return fields
+}
+
+// validFallbacks checks fallback destinations supplied by the owner
+func validFallbacks(fallbacks []string) bool {
+	if len(fallbacks) > maxFallbacks {
+		return false
+	}
+	for _, dest := range fallbacks {
+		if !validDestination(dest) {
+			return false
+		}
+	}
+	return true
+}
+
+// validDeviceRules checks device routing rules supplied by the owner
+func validDeviceRules(rules []storage.DeviceRule) bool {
+	if len(rules) > maxDeviceRules {
+		return false
+	}
+	for _, rule := range rules {
+		if !useragent.Platform(rule.Platform).Valid() || !validDestination(rule.URL) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/urlutil"
+)
+
+// DeterministicURLRequest represents the request body for deterministic shortening
+type DeterministicURLRequest struct {
+	URL string `json:"url" binding:"required"`
+}
+
+// WithDeterministic enables the deterministic shortening endpoint, deriving
+// keys from a salted hash of the normalized URL
+func WithDeterministic(g *id.HashGenerator) Option {
+	return func(h *Handler) {
+		h.hashGenerator = g
+	}
+}
+
+// validKey reports whether a key could have been issued by either generator,
+// or chosen as a custom key
+func (h *Handler) validKey(key string) bool {
+	if h.generator.ValidateKey(key) {
+		return true
+	}
+	if h.vanity != nil && vanityKeyPattern.MatchString(key) {
+		return true
+	}
+	return h.hashGenerator != nil && h.hashGenerator.ValidateKey(key)
+}
+
+// CreateDeterministicURL shortens a URL under a key derived from the URL
+// itself. Repeating the request returns the existing link, so pipelines can
+// retry freely and every instance sharing the salt agrees on the key. Keys
+// of links in a workspace are also derived from the workspace, so tenants
+// never share a link. Anonymous callers would share links too, so they may
+// not use it while email verification is enabled.
+func (h *Handler) CreateDeterministicURL(c *gin.Context) {
+	if h.verification != nil && auth.PrincipalFrom(c) == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required while email verification is enabled"})
+		return
+	}
+
+	var req DeterministicURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+
+	if !validDestination(req.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Must be absolute with http(s) scheme"})
+		return
+	}
+	req.URL = canonicalDestination(req.URL)
+	if !h.secureDestinations(c, []*string{&req.URL}) {
+		return
+	}
+	if !h.withinURLLength(c, req.URL) {
+		return
+	}
+	normalized, err := urlutil.Normalize(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Must be absolute with http(s) scheme"})
+		return
+	}
+
+	ws := workspace(c)
+	input := normalized
+	if ws != "" {
+		input = ws + "\x00" + normalized
+	}
+	rec := &storage.Record{
+		URL:       normalized,
+		Owner:     owner(c),
+		Workspace: ws,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Walk the derived key and its extensions until one is free or already
+	// ours. Retries take the same path, so they land on the same key.
+	for _, key := range h.hashGenerator.Keys(input) {
+		key = h.foldKey(key)
+		err = h.store.Create(c.Request.Context(), key, rec)
+		if err == nil {
+			h.prefetchPreview(key, rec)
+			h.audit(c, storage.AuditCreate, key, nil, rec)
+			h.meterUsage(rec, metering.LinksCreated)
+			c.JSON(http.StatusCreated, h.urlResponse(c, key, rec))
+			return
+		}
+		if err != storage.ErrKeyExists {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store URL"})
+			return
+		}
+
+		// The key is taken; it is only ours if it already points at this URL
+		existing, err := h.store.GetRecord(c.Request.Context(), key)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+			return
+		}
+		if current, err := urlutil.Normalize(existing.URL); err == nil && current == normalized && existing.Workspace == ws {
+			c.JSON(http.StatusOK, h.urlResponse(c, key, existing))
+			return
+		}
+	}
+
+	c.JSON(http.StatusConflict, gin.H{"error": "Derived key and its extensions are already in use by other URLs"})
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+func TestDeterministicURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	hashGenerator, err := id.NewHashGenerator("test-salt", id.DefaultHashKeyLength)
+	require.NoError(t, err)
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithDeterministic(hashGenerator),
+	).SetupRoutes(router)
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls/deterministic", map[string]string{"url": "https://Example.com?b=2&a=1"})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+	assert.Equal(t, hashGenerator.Key("https://example.com/?a=1&b=2"), link.ShortKey)
+	assert.Equal(t, "https://example.com/?a=1&b=2", link.URL)
+
+	// Equivalent URLs return the existing link
+	again := sendJSON(t, router, http.MethodPost, "/api/v1/urls/deterministic", map[string]string{"url": "https://example.com/?a=1&b=2"})
+	require.Equal(t, http.StatusOK, again.Code)
+	var existing URLResponse
+	require.NoError(t, json.NewDecoder(again.Body).Decode(&existing))
+	assert.Equal(t, link.ShortKey, existing.ShortKey)
+
+	// Hash-derived keys redirect like generated ones
+	w := sendJSON(t, router, http.MethodGet, "/"+link.ShortKey, nil)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/?a=1&b=2", w.Header().Get("Location"))
+
+	// A key taken by a different URL is extended by a character, and
+	// retries land on the extended key
+	other := "https://example.org/"
+	keys := hashGenerator.Keys(other)
+	require.NoError(t, store.Set(context.Background(), keys[0], "https://example.net/"))
+	for _, status := range []int{http.StatusCreated, http.StatusOK} {
+		w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/deterministic", map[string]string{"url": other})
+		require.Equal(t, status, w.Code)
+		var extended URLResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&extended))
+		assert.Equal(t, keys[1], extended.ShortKey)
+	}
+
+	// Once every extension is taken it is a conflict
+	taken := "https://example.com/taken"
+	for _, key := range hashGenerator.Keys(taken) {
+		require.NoError(t, store.Set(context.Background(), key, "https://example.net/"))
+	}
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/deterministic", map[string]string{"url": taken})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Invalid URLs are rejected
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/deterministic", map[string]string{"url": "ftp://example.com"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,157 @@
+package http
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/domain"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// DomainRequest represents the request body for registering a custom domain
+type DomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+	Method string `json:"method"`
+}
+
+// DomainResponse represents a custom domain and its verification instructions
+type DomainResponse struct {
+	Domain       string               `json:"domain"`
+	Workspace    string               `json:"workspace,omitempty"`
+	Status       storage.DomainStatus `json:"status"`
+	Method       string               `json:"method"`
+	Token        string               `json:"token"`
+	RecordName   string               `json:"record_name,omitempty"`
+	ChallengeURL string               `json:"challenge_url,omitempty"`
+	LastError    string               `json:"last_error,omitempty"`
+}
+
+// WithDomains enables custom domain registration and verification
+func WithDomains(v *domain.Verifier) Option {
+	return func(h *Handler) {
+		h.domains = v
+	}
+}
+
+// newDomainResponse builds the response for a custom domain
+func newDomainResponse(d *storage.Domain) DomainResponse {
+	resp := DomainResponse{
+		Domain:    d.Name,
+		Workspace: d.Workspace,
+		Status:    d.Status,
+		Method:    d.Method,
+		Token:     d.Token,
+		LastError: d.LastError,
+	}
+	switch d.Method {
+	case domain.MethodDNS:
+		resp.RecordName = domain.RecordName(d.Name)
+	case domain.MethodHTTP:
+		resp.ChallengeURL = domain.ChallengeURL(d.Name)
+	}
+	return resp
+}
+
+// CreateDomain handles custom domain registration
+func (h *Handler) CreateDomain(c *gin.Context) {
+	var req DomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+
+	d, err := h.domains.Register(c.Request.Context(), req.Domain, req.Method, workspace(c))
+	switch err {
+	case nil:
+	case domain.ErrInvalidDomain:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain name"})
+		return
+	case domain.ErrInvalidMethod:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification method. Must be dns or http"})
+		return
+	case storage.ErrKeyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Domain already verified"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register domain"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newDomainResponse(d))
+}
+
+// GetDomain returns a custom domain and its verification state
+func (h *Handler) GetDomain(c *gin.Context) {
+	d := h.managedDomain(c)
+	if d == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, newDomainResponse(d))
+}
+
+// VerifyDomain runs the verification check for a custom domain
+func (h *Handler) VerifyDomain(c *gin.Context) {
+	if h.managedDomain(c) == nil {
+		return
+	}
+
+	d, err := h.domains.Verify(c.Request.Context(), c.Param("domain"))
+	if err == storage.ErrDomainNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify domain"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newDomainResponse(d))
+}
+
+// DeleteDomain removes a custom domain, leaving it free to register
+func (h *Handler) DeleteDomain(c *gin.Context) {
+	d := h.managedDomain(c)
+	if d == nil {
+		return
+	}
+
+	err := h.domains.Delete(c.Request.Context(), d.Name)
+	if err != nil && err != storage.ErrDomainNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete domain"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// onDomain reports whether a request is for a custom domain's host
+func onDomain(r *http.Request, name string) bool {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return domain.Normalize(host) == name
+}
+
+// managedDomain loads a custom domain and checks the caller's workspace may
+// manage it. It writes the error response and returns nil on failure.
+func (h *Handler) managedDomain(c *gin.Context) *storage.Domain {
+	d, err := h.domains.Get(c.Request.Context(), c.Param("domain"))
+	if err == storage.ErrDomainNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve domain"})
+		return nil
+	}
+
+	if !h.canAccessWorkspace(c, d.Workspace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Domain belongs to another workspace"})
+		return nil
+	}
+	return d
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/domain"
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// stubChecker returns a fixed result for every domain check
+type stubChecker struct {
+	err error
+}
+
+func (s *stubChecker) Check(_ context.Context, _ *storage.Domain) error {
+	return s.err
+}
+
+func TestDomainVerification_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	checker := &stubChecker{err: domain.ErrTokenMissing}
+	verifier := domain.NewVerifier(store)
+	verifier.SetChecker(domain.MethodDNS, checker)
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithDomains(verifier)).SetupRoutes(router)
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		jsonBody, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	createLink := func() int {
+		return post("/api/v1/urls", map[string]interface{}{
+			"url":    "https://example.com",
+			"domain": "go.example.com",
+		}).Code
+	}
+
+	// Unregistered domains cannot be used
+	assert.Equal(t, http.StatusForbidden, createLink())
+
+	w := post("/api/v1/domains", map[string]interface{}{"domain": "go.example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp DomainResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, storage.DomainPending, resp.Status)
+	assert.Equal(t, domain.RecordName("go.example.com"), resp.RecordName)
+	assert.NotEmpty(t, resp.Token)
+
+	// Pending domains can be registered again, replacing the token
+	w = post("/api/v1/domains", map[string]interface{}{"domain": "go.example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var again DomainResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&again))
+	assert.NotEqual(t, resp.Token, again.Token)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/domains", map[string]interface{}{"domain": "not a domain"}).Code)
+
+	// Pending domains cannot be used
+	assert.Equal(t, http.StatusForbidden, createLink())
+
+	checker.err = nil
+	w = post("/api/v1/domains/go.example.com/verify", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, storage.DomainVerified, resp.Status)
+
+	assert.Equal(t, http.StatusConflict, post("/api/v1/domains", map[string]interface{}{"domain": "go.example.com"}).Code)
+
+	w = post("/api/v1/urls", map[string]interface{}{"url": "https://example.com", "domain": "go.example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	// Links under the domain are only served on it
+	redirect := func(host string) int {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusFound, redirect("GO.example.com:443"))
+	assert.Equal(t, http.StatusNotFound, redirect("localhost:8080"))
+	assert.Equal(t, http.StatusNotFound, redirect("other.example.com"))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/domains/go.example.com", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusForbidden, createLink())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/domains/unknown.example.com", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DryRunParam is the query parameter asking a create request to be
+	// validated without storing anything
+	DryRunParam = "dry_run"
+
+	// dryRunContextKey marks requests to routes honouring DryRunParam that ask
+	// for a dry run
+	dryRunContextKey = "dry_run"
+)
+
+// dryRunnable chains a link creation handler honouring DryRunParam behind
+// the middleware creation does. Only such routes let dry runs skip the
+// middleware guarding stored links, so the parameter can't be used to get
+// past it elsewhere.
+func (h *Handler) dryRunnable(handler gin.HandlerFunc) []gin.HandlerFunc {
+	return append([]gin.HandlerFunc{allowDryRun}, h.creation(handler)...)
+}
+
+// allowDryRun marks a request asking for a dry run
+func allowDryRun(c *gin.Context) {
+	if v, _ := strconv.ParseBool(c.Query(DryRunParam)); v {
+		c.Set(dryRunContextKey, true)
+	}
+}
+
+// dryRun reports whether a request only asks to be validated
+func dryRun(c *gin.Context) bool {
+	return c.GetBool(dryRunContextKey)
+}
+
+// dryRunKey runs the checks storing a link under a requested custom key
+// does, without storing it, answering the request itself if they fail. It
+// returns the key the link would get: the requested one, a free suffixed
+// one under the suffix policy, or "" for a generated one.
+func (h *Handler) dryRunKey(c *gin.Context, requested, onConflict string) (string, bool) {
+	key := h.foldKey(requested)
+	if key == "" {
+		return "", true
+	}
+	if !h.validCustomKey(c, key, onConflict) {
+		return "", false
+	}
+
+	taken, ok := h.landingPageTaken(c, key)
+	if !ok {
+		return "", false
+	}
+	if !taken {
+		exist, err := h.vanity.KeysExist(c.Request.Context(), []string{key})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check keys"})
+			return "", false
+		}
+		taken = exist[0]
+	}
+	if !taken {
+		return key, true
+	}
+
+	if onConflict == ConflictSuffix {
+		available, err := h.availableKeys(c.Request.Context(), suffixCandidates(key), 1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check keys"})
+			return "", false
+		}
+		if len(available) > 0 {
+			return available[0], true
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Key is already taken"})
+		return "", false
+	}
+	// Without the suffix policy, resolving the conflict stores nothing
+	return h.keyConflict(c, key, onConflict, nil)
+}
+
+// dryRunURLResponse describes the link a dry run would create. Links with
+// generated keys have no short URL yet.
+func (h *Handler) dryRunURLResponse(key string, rec *storage.Record) URLResponse {
+	response := h.newURLResponse(key, rec, nil)
+	if key == "" {
+		response.ShortURL = ""
+	}
+	return response
+}
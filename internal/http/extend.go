@@ -0,0 +1,103 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// ExtendRequest represents a request to extend a link's expiry. ExpiresAt
+// defaults to a full TTL from now.
+type ExtendRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// WithExpiry lets editors extend the expiry of links in one call, by at
+// most the store's TTL from now
+func WithExpiry(store storage.ExpiryStore) Option {
+	return func(h *Handler) {
+		h.expiry = store
+	}
+}
+
+// ExtendURL extends a link's expiry, answering with the link
+func (h *Handler) ExtendURL(c *gin.Context) {
+	if key, rec, ok := h.extendLink(c); ok {
+		c.JSON(http.StatusOK, h.urlResponse(c, key, rec))
+	}
+}
+
+// ExtendLink extends a link's expiry, answering with its Link
+func (h *Handler) ExtendLink(c *gin.Context) {
+	key, rec, ok := h.extendLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusOK, h.link(details, rec))
+}
+
+// extendLink moves the expiry of the requested link, answering the request
+// itself if it can't. Links can't be shortened this way, nor extended past
+// a TTL from now, and links that never expire can't be extended.
+func (h *Handler) extendLink(c *gin.Context) (string, *storage.Record, bool) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return "", nil, false
+	}
+	var req ExtendRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		h.invalidBody(c, err)
+		return "", nil, false
+	}
+
+	ctx := c.Request.Context()
+	current, err := h.store.ExpiresAt(ctx, key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return "", nil, false
+	}
+	if current == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link never expires"})
+		return "", nil, false
+	}
+
+	now := time.Now()
+	latest := now.Add(h.expiry.TTL())
+	until := latest
+	if req.ExpiresAt != nil {
+		until = *req.ExpiresAt
+	}
+	if !until.After(now) || until.After(latest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at. Must be in the future and within the TTL of " + h.expiry.TTL().String()})
+		return "", nil, false
+	}
+	if until.Before(*current) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at. Must not be before the current expiry"})
+		return "", nil, false
+	}
+
+	before := h.snapshot(rec)
+	err = h.expiry.Extend(ctx, key, until)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to extend URL"})
+		return "", nil, false
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditExtend, key, before, rec)
+
+	return key, rec, true
+}
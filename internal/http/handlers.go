@@ -1,80 +1,761 @@
 package http
 
 import (
+	"html/template"
+	"io/fs"
 	"net/http"
-	"net/url"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/prayushdave/url-shortener/internal/accesslog"
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/captcha"
+	"github.com/prayushdave/url-shortener/internal/chain"
+	"github.com/prayushdave/url-shortener/internal/domain"
 	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/jobs"
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/mirror"
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/purge"
+	"github.com/prayushdave/url-shortener/internal/rules"
 	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/upgrade"
+	"github.com/prayushdave/url-shortener/internal/useragent"
 )
 
 // URLRequest represents the request body for URL shortening
 type URLRequest struct {
-	URL string `json:"url" binding:"required"`
+	URL         string     `json:"url" binding:"required"`
+	Key         string     `json:"key"`
+	Domain      string     `json:"domain"`
+	ActiveFrom  *time.Time `json:"active_from"`
+	ActiveUntil *time.Time `json:"active_until"`
+
+	QueryParams map[string]string    `json:"query_params"`
+	Rules       []rules.Rule         `json:"rules"`
+	DeviceRules []storage.DeviceRule `json:"device_rules"`
+	LocaleRules []storage.LocaleRule `json:"locale_rules"`
+
+	Variants       []storage.Variant `json:"variants"`
+	StickyVariants bool              `json:"sticky_variants"`
+
+	Fallbacks []string                       `json:"fallbacks"`
+	Schedule  []storage.ScheduledDestination `json:"schedule"`
+
+	Tags  []string `json:"tags"`
+	Label string   `json:"label"`
+	Note  string   `json:"note"`
+
+	Permanent   bool `json:"permanent"`
+	CacheMaxAge *int `json:"cache_max_age"`
+
+	// Interstitial shows a page for a few seconds before redirecting
+	Interstitial *storage.Interstitial `json:"interstitial"`
+
+	// SingleUse deletes the link on its first redirect, when single-use
+	// links are enabled
+	SingleUse bool `json:"single_use"`
+
+	// MaxClicks is how many redirects the link allows, when click limits
+	// are enabled
+	MaxClicks int `json:"max_clicks"`
+
+	// Signed requires the link's signature on redirects, when link signing
+	// is enabled
+	Signed bool `json:"signed"`
+
+	Expiry storage.ExpiryPolicy `json:"expiry"`
+
+	// OnConflict is what to do when the custom key is taken: error,
+	// suggest or suffix
+	OnConflict string `json:"on_conflict"`
+
+	// Email is where anonymous creators receive the link verifying their
+	// email, when email verification is enabled
+	Email string `json:"email"`
+}
+
+// UpdateURLRequest represents the request body for updating a URL mapping.
+// Omitted fields are left unchanged.
+type UpdateURLRequest struct {
+	URL         *string               `json:"url"`
+	ActiveFrom  *time.Time            `json:"active_from"`
+	ActiveUntil *time.Time            `json:"active_until"`
+	QueryParams *map[string]string    `json:"query_params"`
+	Rules       *[]rules.Rule         `json:"rules"`
+	DeviceRules *[]storage.DeviceRule `json:"device_rules"`
+	LocaleRules *[]storage.LocaleRule `json:"locale_rules"`
+
+	Variants       *[]storage.Variant `json:"variants"`
+	StickyVariants *bool              `json:"sticky_variants"`
+
+	Fallbacks *[]string `json:"fallbacks"`
+
+	Tags  *[]string `json:"tags"`
+	Label *string   `json:"label"`
+	Note  *string   `json:"note"`
+
+	Permanent   *bool `json:"permanent"`
+	CacheMaxAge *int  `json:"cache_max_age"`
+	Signed      *bool `json:"signed"`
+	SingleUse   *bool `json:"single_use"`
+
+	// MaxClicks replaces the link's click limit; 0 removes it
+	MaxClicks *int `json:"max_clicks"`
+
+	// Interstitial replaces the link's interstitial; zero seconds removes it
+	Interstitial *storage.Interstitial `json:"interstitial"`
+
+	Expiry *storage.ExpiryPolicy `json:"expiry"`
 }
 
 // URLResponse represents the response for URL shortening
 type URLResponse struct {
-	ShortKey string `json:"short_key"`
-	URL      string `json:"url"`
+	ShortKey  string     `json:"short_key"`
+	ShortURL  string     `json:"short_url"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+
+	// DisplayURL is URL as people read it, set when it has an
+	// internationalized host or path
+	DisplayURL string `json:"display_url,omitempty"`
+
+	// Unverified is set while the link awaits its creator's verification
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+// LinkResponse represents the full details of a stored link
+type LinkResponse struct {
+	ShortKey    string     `json:"short_key"`
+	URL         string     `json:"url"`
+	DisplayURL  string     `json:"display_url,omitempty"`
+	Domain      string     `json:"domain,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Workspace   string     `json:"workspace,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	Unverified  bool       `json:"unverified,omitempty"`
+
+	QueryParams    map[string]string              `json:"query_params,omitempty"`
+	Rules          []rules.Rule                   `json:"rules,omitempty"`
+	DeviceRules    []storage.DeviceRule           `json:"device_rules,omitempty"`
+	LocaleRules    []storage.LocaleRule           `json:"locale_rules,omitempty"`
+	Variants       []storage.Variant              `json:"variants,omitempty"`
+	StickyVariants bool                           `json:"sticky_variants,omitempty"`
+	Fallbacks      []string                       `json:"fallbacks,omitempty"`
+	Down           []string                       `json:"down,omitempty"`
+	Schedule       []storage.ScheduledDestination `json:"schedule,omitempty"`
+	Tags           []string                       `json:"tags"`
+	Label          string                         `json:"label,omitempty"`
+	Note           string                         `json:"note,omitempty"`
+	Permanent      bool                           `json:"permanent"`
+	CacheMaxAge    *int                           `json:"cache_max_age,omitempty"`
+	Signed         bool                           `json:"signed,omitempty"`
+	SingleUse      bool                           `json:"single_use,omitempty"`
+	MaxClicks      int                            `json:"max_clicks,omitempty"`
+	Interstitial   *storage.Interstitial          `json:"interstitial,omitempty"`
+	Expiry         storage.ExpiryPolicy           `json:"expiry,omitempty"`
+	Aliases        []storage.Alias                `json:"aliases,omitempty"`
+
+	// Clicks counts redirects in the current statistics period, when
+	// statistics are enabled
+	Clicks *int64 `json:"clicks,omitempty"`
+
+	// Check is the outcome of the last link-rot check, when enabled
+	Check *storage.DestinationCheck `json:"check,omitempty"`
+
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 // Handler handles HTTP requests for the URL shortener
 type Handler struct {
 	store     storage.Store
-	generator *id.Generator
+	generator id.KeyGenerator
 	baseURL   string
+	fallback  storage.Reader
+	domains   *domain.Verifier
+	auth      *auth.Manager
+	stats     storage.StatsStore
+	rollups   storage.RollupStore
+	recorder  *analytics.Recorder
+	mirror    *mirror.Mirror
+	purger    *purge.Purger
+	accessLog *accesslog.Logger
+
+	clickStream storage.ClickStream
+	streamsDone <-chan struct{}
+	topLinks    storage.TopLinkStore
+
+	workspaces   storage.WorkspaceStore
+	campaigns    storage.CampaignStore
+	landingPages storage.LandingPageStore
+	quotas       storage.QuotaStore
+	quotaLimits  QuotaLimits
+
+	bulk     storage.BulkStore
+	exporter storage.ExportStore
+	auditLog storage.AuditStore
+
+	meter *metering.Meter
+	usage storage.UsageStore
+
+	breaker  *storage.BreakerStore
+	timeouts RouteTimeouts
+
+	scanners       storage.ScannerStore
+	scanProtection ScanProtection
+	blocklist      *blocklist
+
+	jobs *jobs.Scheduler
+
+	captcha      captcha.Verifier
+	verification *EmailVerification
+
+	notifications storage.NotificationStore
+	notifier      *notify.Notifier
+
+	expiry storage.ExpiryStore
+
+	privacyLinks storage.ExportStore
+	visits       storage.VisitStore
+	honorDNT     bool
+
+	idempotency    storage.IdempotencyStore
+	idempotencyTTL time.Duration
+	limits         Limits
+	httpPolicy     HTTPPolicy
+	upgrader       *upgrade.Checker
+
+	hashGenerator  *id.HashGenerator
+	vanity         storage.KeyChecker
+	consumer       storage.Consumer
+	clickLimits    storage.ClickLimiter
+	signingSecret  []byte
+	aliases        storage.AliasStore
+	aliasGrace     time.Duration
+	foldKeys       bool
+	bots           *useragent.BotDetector
+	botMode        BotMode
+	metadata       *metadata.Prefetcher
+	socialPreviews bool
+
+	redirectMaxAge int
+
+	inactivePage *template.Template
+	errorPages   ErrorPages
+	root         RootConfig
+	app          fs.FS
+	redirects    RedirectRoutes
+	rootHosts    map[string]RootConfig
+	wellKnown    WellKnownConfig
+	v1Sunset     time.Time
+
+	idempotentDeletes bool
+	chains            *chain.Resolver
+
+	// readOnly rejects changes to links, for maintenance windows and
+	// storage failovers. With sharedReadOnly it caches the shared state.
+	readOnly       atomic.Bool
+	sharedReadOnly storage.ReadOnlyStore
+}
+
+// Option configures optional Handler behavior
+type Option func(*Handler)
+
+// WithFallback sets a reader used to resolve redirects when the primary store fails
+func WithFallback(r storage.Reader) Option {
+	return func(h *Handler) {
+		h.fallback = r
+	}
+}
+
+// WithMirror forwards a sample of redirect requests to a staging instance
+func WithMirror(m *mirror.Mirror) Option {
+	return func(h *Handler) {
+		h.mirror = m
+	}
 }
 
 // NewHandler creates a new Handler instance
-func NewHandler(store storage.Store, generator *id.Generator, baseURL string) *Handler {
-	return &Handler{
+func NewHandler(store storage.Store, generator id.KeyGenerator, baseURL string, opts ...Option) *Handler {
+	h := &Handler{
 		store:     store,
 		generator: generator,
 		baseURL:   baseURL,
+		limits:    Limits{MaxURLLength: DefaultMaxURLLength, MaxBodyBytes: DefaultMaxBodyBytes},
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // SetupRoutes configures the routes for the handler
 func (h *Handler) SetupRoutes(r *gin.Engine) {
-	v1 := r.Group("/api/v1")
+	h.SetupAPIRoutes(r)
+	h.SetupRedirectRoutes(r)
+}
+
+// SetupAPIRoutes configures the management API and the dashboard, so they
+// can be served apart from redirects
+func (h *Handler) SetupAPIRoutes(r *gin.Engine) {
+	if h.auth != nil {
+		// Login must stay reachable without credentials
+		r.POST("/api/v1/auth/login", h.Login)
+	}
+
+	h.setupV2(h.apiGroup(r, "/api/v2"))
+
+	v1 := h.apiGroup(r, "/api/v1", h.deprecateV1)
 	{
-		v1.POST("/urls", h.CreateURL)
-		v1.DELETE("/urls/:key", h.DeleteURL)
+		v1.GET("/limits", h.GetLimits)
+		v1.GET("/urls", h.ListURLs)
+		v1.DELETE("/urls", h.editor(h.DeleteURLs)...)
+		v1.POST("/urls", h.dryRunnable(h.CreateURL)...)
+		if h.hashGenerator != nil {
+			v1.POST("/urls/deterministic", h.creation(h.CreateDeterministicURL)...)
+		}
+		if h.vanity != nil {
+			v1.GET("/keys/suggest", h.SuggestKeys)
+			v1.GET("/keys/:key/available", h.KeyAvailability)
+		}
+		v1.GET("/urls/:key", h.GetURL)
+		v1.PATCH("/urls/:key", h.editor(h.UpdateURL)...)
+		v1.DELETE("/urls/:key", h.editor(h.DeleteURL)...)
+		v1.POST("/urls/:key/clone", h.creation(h.CloneURL)...)
+		if h.verification != nil {
+			v1.GET("/urls/:key/verify", h.rejectWhileReadOnly, h.VerifyURL)
+		}
+		v1.POST("/urls/:key/disable", h.editor(h.DisableURL)...)
+		v1.POST("/urls/:key/enable", h.editor(h.EnableURL)...)
+		v1.GET("/urls/:key/schedule", h.GetSchedule)
+		v1.PUT("/urls/:key/schedule", h.editor(h.SetSchedule)...)
+		if h.expiry != nil {
+			v1.POST("/urls/:key/extend", h.editor(h.ExtendURL)...)
+		}
+		if h.aliases != nil {
+			v1.POST("/urls/:key/rename", h.editor(h.RenameURL)...)
+		}
+		v1.POST("/urls/:key/simulate", h.SimulateURL)
+		v1.POST("/rules/validate", h.ValidateRules)
+
+		if h.stats != nil {
+			v1.GET("/urls/:key/stats", h.GetStats)
+			v1.GET("/urls/:key/stats/export", h.ExportStats)
+			v1.POST("/urls/:key/stats/reset", h.editor(h.ResetStats)...)
+		}
+		if h.clickStream != nil {
+			v1.GET("/urls/:key/stream", h.StreamClicks)
+		}
+
+		if h.workspaces != nil {
+			v1.GET("/workspaces/:workspace/stats", h.GetWorkspaceStats)
+		}
+		if h.notifier != nil {
+			v1.GET("/urls/:key/notifications", h.GetNotifications)
+			v1.PUT("/urls/:key/notifications", h.editor(h.SetNotifications)...)
+			v1.GET("/workspaces/:workspace/notifications", h.GetWorkspaceNotifications)
+			v1.PUT("/workspaces/:workspace/notifications", h.editor(h.SetWorkspaceNotifications)...)
+		}
+
+		if h.campaigns != nil {
+			v1.POST("/campaigns", h.editor(h.CreateCampaign)...)
+			v1.GET("/campaigns/:campaign", h.GetCampaign)
+			v1.POST("/campaigns/:campaign/links", h.editor(h.AddCampaignLinks)...)
+			v1.GET("/campaigns/:campaign/stats", h.GetCampaignStats)
+			v1.GET("/campaigns/:campaign/stats/export", h.ExportCampaignStats)
+		}
+
+		if h.landingPages != nil {
+			v1.POST("/pages", h.creation(h.CreateLandingPage)...)
+			v1.GET("/pages/:page", h.GetLandingPage)
+			v1.PUT("/pages/:page", h.editor(h.UpdateLandingPage)...)
+			v1.DELETE("/pages/:page", h.editor(h.DeleteLandingPage)...)
+		}
+
+		if h.domains != nil {
+			v1.POST("/domains", h.editor(h.CreateDomain)...)
+			v1.GET("/domains/:domain", h.GetDomain)
+			v1.POST("/domains/:domain/verify", h.editor(h.VerifyDomain)...)
+			v1.DELETE("/domains/:domain", h.editor(h.DeleteDomain)...)
+		}
+
+		admin := v1.Group("/admin", h.requireAdmin()...)
+		admin.GET("/read-only", h.GetReadOnly)
+		// Bulk changes and control of the service are never open to
+		// anonymous callers
+		if h.auth != nil {
+			admin.PUT("/read-only", h.SetReadOnly)
+		}
+		if h.bulk != nil && h.auth != nil {
+			admin.POST("/import", h.rejectWhileReadOnly, h.ImportURLs)
+			admin.GET("/export", h.ExportURLs)
+			admin.GET("/backup", h.BackupURLs)
+			admin.POST("/restore", h.rejectWhileReadOnly, h.RestoreURLs)
+		}
+		if h.auditLog != nil {
+			admin.GET("/audit", h.ListAudit)
+		}
+		if h.usage != nil {
+			admin.GET("/usage", h.GetUsage)
+		}
+		if h.scanners != nil {
+			admin.GET("/scanners", h.ListScanners)
+			admin.DELETE("/scanners/:ip", h.UnblockScanner)
+		}
+		if h.jobs != nil {
+			admin.GET("/jobs", h.ListJobs)
+			admin.GET("/jobs/:job/runs", h.ListJobRuns)
+			if h.auth != nil {
+				admin.POST("/jobs/:job/run", h.TriggerJob)
+			}
+		}
+
+		if h.topLinks != nil {
+			v1.GET("/stats/top", append(h.requireAdmin(), h.GetTopLinks)...)
+		}
+
+		if h.privacyLinks != nil {
+			v1.GET("/privacy/export", h.ExportPersonalData)
+			v1.DELETE("/privacy/visits", h.editor(h.EraseVisits)...)
+		}
+
+		if h.auth != nil {
+			v1.GET("/auth/session", auth.RequirePrincipal(), h.GetSession)
+			v1.POST("/auth/logout", auth.RequirePrincipal(), h.Logout)
+		}
+	}
+
+	if h.app != nil {
+		// Static routes take precedence over /:key, so no key is shadowed
+		// but "app", which can't be chosen as a custom key
+		r.GET(AppPath, h.redirectToApp)
+		r.GET(AppPath+"/*path", h.App)
+		r.HEAD(AppPath+"/*path", h.App)
+	}
+}
+
+// SetupRedirectRoutes configures the routes public visitors reach: short
+// links, the root page and the files crawlers look for
+func (h *Handler) SetupRedirectRoutes(r *gin.Engine) {
+	r.GET("/robots.txt", h.RobotsTxt)
+	r.GET("/favicon.ico", h.Favicon)
+	if h.wellKnown.SecurityTxt != "" {
+		r.GET("/.well-known/security.txt", h.SecurityTxt)
 	}
 
 	// Add redirect route at root level
-	r.GET("/:key", h.RedirectURL)
+	r.GET("/", h.Root)
+	var redirect []gin.HandlerFunc
+	if h.scanners != nil {
+		redirect = append(redirect, h.guardScanners)
+	}
+	redirect = append(redirect, h.redirectDeadline)
+	if h.foldKeys {
+		redirect = append(redirect, h.foldKeyParam)
+	}
+	if h.accessLog != nil {
+		redirect = append(redirect, h.logAccess)
+	}
+	if h.mirror != nil {
+		redirect = append(redirect, h.mirror.Middleware())
+	}
+	redirect = append(redirect, h.RedirectURL)
+	if h.redirects.Host != "" {
+		redirect = append([]gin.HandlerFunc{h.requireRedirectHost}, redirect...)
+	}
+	path := h.redirects.Prefix + "/:key"
+	r.GET(path, redirect...)
+	r.HEAD(path, redirect...)
+	r.OPTIONS(path, h.RedirectOptions)
+	if h.redirects.Prefix != "" && h.redirects.Legacy {
+		r.GET("/:key", h.legacyRedirect)
+		r.HEAD("/:key", h.legacyRedirect)
+	}
+}
+
+// apiGroup creates the group serving an API version, with the middleware
+// every version shares
+func (h *Handler) apiGroup(r *gin.Engine, path string, middleware ...gin.HandlerFunc) *gin.RouterGroup {
+	g := r.Group(path, h.apiDeadline, h.negotiate, h.failFast, h.limitBody)
+	g.Use(middleware...)
+	if h.auth != nil {
+		g.Use(h.auth.Middleware())
+	}
+	if h.foldKeys {
+		g.Use(h.foldKeyParam)
+	}
+	return g
+}
+
+// requireAdmin returns the middleware restricting operator endpoints to
+// admins. Without authentication configured every caller is trusted.
+func (h *Handler) requireAdmin() []gin.HandlerFunc {
+	if h.auth == nil {
+		return nil
+	}
+	return []gin.HandlerFunc{auth.RequireAdmin()}
+}
+
+// requireRole returns the middleware restricting a route to principals
+// whose role includes the given role
+func (h *Handler) requireRole(role auth.Role) []gin.HandlerFunc {
+	if h.auth == nil {
+		return nil
+	}
+	return []gin.HandlerFunc{auth.RequireRole(role)}
+}
+
+// editor chains a handler that changes links or their settings behind the
+// editor role check, which viewers fail, and the read-only check
+func (h *Handler) editor(handler gin.HandlerFunc) []gin.HandlerFunc {
+	return append(h.requireRole(auth.RoleEditor), h.rejectWhileReadOnly, handler)
+}
+
+// creation chains a link creation handler behind the middleware guarding
+// creations. Idempotent replays come first so they never use up quota.
+func (h *Handler) creation(handler gin.HandlerFunc) []gin.HandlerFunc {
+	chain := append(h.requireRole(auth.RoleEditor), h.rejectWhileReadOnly)
+	if h.idempotency != nil {
+		chain = append(chain, h.idempotent())
+	}
+	if h.captcha != nil {
+		// After idempotency, so retries replayed don't spend a new token
+		chain = append(chain, h.requireCaptcha)
+	}
+	if h.quotas != nil {
+		chain = append(chain, h.enforceQuotas())
+	}
+	return append(chain, handler)
 }
 
 // CreateURL handles the URL shortening request
 func (h *Handler) CreateURL(c *gin.Context) {
+	key, rec, ok := h.createLink(c)
+	switch {
+	case !ok:
+	case dryRun(c):
+		c.JSON(http.StatusOK, h.dryRunURLResponse(key, rec))
+	default:
+		c.JSON(http.StatusCreated, h.urlResponse(c, key, rec))
+	}
+}
+
+// createLink stores the link described by the request body, answering the
+// request itself if it can't
+func (h *Handler) createLink(c *gin.Context) (string, *storage.Record, bool) {
 	var req URLRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
+		h.invalidBody(c, err)
+		return "", nil, false
 	}
 
 	// Validate URL
-	parsedURL, err := url.Parse(req.URL)
-	if err != nil || (!parsedURL.IsAbs() || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https")) {
+	if !validDestination(req.URL) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Must be absolute with http(s) scheme"})
-		return
+		return "", nil, false
+	}
+	req.URL = canonicalDestination(req.URL)
+	if !h.withinURLLength(c, req.URL) {
+		return "", nil, false
+	}
+	if !h.resolveChain(c, &req.URL) {
+		return "", nil, false
 	}
 
-	// Generate a unique key
-	var key string
-	for attempts := 0; attempts < 3; attempts++ {
+	// Links under a custom domain require the domain to be verified
+	if req.Domain != "" {
+		if h.domains == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Custom domains are not enabled"})
+			return "", nil, false
+		}
+		req.Domain = domain.Normalize(req.Domain)
+		d, err := h.domains.Get(c.Request.Context(), req.Domain)
+		if err != nil && err != storage.ErrDomainNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check domain"})
+			return "", nil, false
+		}
+		if err == storage.ErrDomainNotFound || d.Status != storage.DomainVerified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Domain is not verified"})
+			return "", nil, false
+		}
+		if !h.canAccessWorkspace(c, d.Workspace) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Domain belongs to another workspace"})
+			return "", nil, false
+		}
+	}
+
+	rec := &storage.Record{
+		URL:         req.URL,
+		Domain:      req.Domain,
+		Owner:       owner(c),
+		Workspace:   workspace(c),
+		CreatedAt:   time.Now().UTC(),
+		ActiveFrom:  req.ActiveFrom,
+		ActiveUntil: req.ActiveUntil,
+		QueryParams: req.QueryParams,
+		Rules:       req.Rules,
+		DeviceRules: req.DeviceRules,
+		LocaleRules: req.LocaleRules,
+
+		Variants:       req.Variants,
+		StickyVariants: req.StickyVariants,
+		Fallbacks:      req.Fallbacks,
+		Schedule:       req.Schedule,
+
+		Label: req.Label,
+		Note:  req.Note,
+
+		Permanent:   req.Permanent,
+		CacheMaxAge: req.CacheMaxAge,
+		Signed:      req.Signed,
+		SingleUse:   req.SingleUse,
+		MaxClicks:   req.MaxClicks,
+		Expiry:      req.Expiry,
+
+		Interstitial: req.Interstitial,
+	}
+	canonicalizeDestinations(rec)
+	if err := rec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid activation window. active_until must be after active_from"})
+		return "", nil, false
+	}
+	if !validQueryParams(rec.QueryParams) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return "", nil, false
+	}
+	if err := rules.Validate(rec.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rules: " + err.Error()})
+		return "", nil, false
+	}
+	if !validDeviceRules(rec.DeviceRules) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device rules"})
+		return "", nil, false
+	}
+	if !validLocaleRules(rec.LocaleRules) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locale rules"})
+		return "", nil, false
+	}
+	if !normalizeVariants(rec.Variants) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variants"})
+		return "", nil, false
+	}
+	if !validFallbacks(rec.Fallbacks) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallbacks. At most 5 absolute http(s) URLs"})
+		return "", nil, false
+	}
+	if !normalizeSchedule(rec.Schedule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": scheduleError})
+		return "", nil, false
+	}
+	if !validCacheMaxAge(rec.CacheMaxAge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cache_max_age"})
+		return "", nil, false
+	}
+	if !validInterstitial(rec.Interstitial) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": interstitialError})
+		return "", nil, false
+	}
+	if rec.Signed && h.signingSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Signed links are not enabled"})
+		return "", nil, false
+	}
+	if rec.SingleUse && h.consumer == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Single-use links are not enabled"})
+		return "", nil, false
+	}
+	if !h.validMaxClicks(c, rec.MaxClicks) {
+		return "", nil, false
+	}
+	if !rec.Expiry.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry. Must be sliding or absolute"})
+		return "", nil, false
+	}
+	tags, ok := normalizeTags(req.Tags)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags"})
+		return "", nil, false
+	}
+	rec.Tags = tags
+	if !validLabel(rec.Label) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label. Must be at most 100 characters on one line"})
+		return "", nil, false
+	}
+	if !validNote(rec.Note) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note. Must be at most 2000 characters"})
+		return "", nil, false
+	}
+	if !h.secureDestinations(c, destinationFields(rec)) {
+		return "", nil, false
+	}
+	if !h.withinURLLength(c, recordURLs(rec)...) {
+		return "", nil, false
+	}
+	if !h.holdForVerification(c, req.Email, rec) {
+		return "", nil, false
+	}
+	if dryRun(c) {
+		key, ok := h.dryRunKey(c, req.Key, req.OnConflict)
+		return key, rec, ok
+	}
+
+	key, ok := h.storeLink(c, req.Key, req.OnConflict, rec)
+	if !ok {
+		return "", nil, false
+	}
+
+	h.sendVerification(key, rec)
+	h.prefetchPreview(key, rec)
+	h.audit(c, storage.AuditCreate, key, nil, rec)
+	h.meterUsage(rec, metering.LinksCreated)
+
+	return key, rec, true
+}
+
+// storeLink stores a new link under the requested custom key, or under a
+// generated unique one if none is requested, answering the request itself
+// if it can't
+func (h *Handler) storeLink(c *gin.Context, requested, onConflict string, rec *storage.Record) (string, bool) {
+	key := h.foldKey(requested)
+	var err error
+	if key != "" {
+		if !h.validCustomKey(c, key, onConflict) {
+			return "", false
+		}
+		// Keys holding a landing page are taken just like those holding
+		// links, which the store checks as it creates the link
+		err = h.store.Create(c.Request.Context(), key, rec)
+		if err == storage.ErrKeyExists {
+			var ok bool
+			if key, ok = h.keyConflict(c, key, onConflict, rec); !ok {
+				return "", false
+			}
+			err = nil
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store URL"})
+			return "", false
+		}
+	}
+	for attempts := 0; requested == "" && attempts < 3; attempts++ {
 		key, err = h.generator.Generate()
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
-			return
+			return "", false
 		}
 
 		// Try to store the URL
-		err = h.store.Set(c.Request.Context(), key, req.URL)
+		err = h.store.Create(c.Request.Context(), key, rec)
 		if err == nil {
 			break
 		}
@@ -82,7 +763,7 @@ func (h *Handler) CreateURL(c *gin.Context) {
 		// If we got an error other than collision, return error
 		if err != storage.ErrKeyExists {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store URL"})
-			return
+			return "", false
 		}
 
 		// On collision, try again with a new key
@@ -91,15 +772,49 @@ func (h *Handler) CreateURL(c *gin.Context) {
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate unique key after multiple attempts"})
-		return
+		return "", false
 	}
 
-	response := URLResponse{
-		ShortKey: key,
-		URL:      req.URL,
+	return key, true
+}
+
+// validCustomKey checks a requested custom key and conflict policy,
+// answering the request itself if they are invalid
+func (h *Handler) validCustomKey(c *gin.Context, key, onConflict string) bool {
+	if h.vanity == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Custom keys are not enabled"})
+		return false
 	}
+	if !validVanityKey(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidVanityKey})
+		return false
+	}
+	if !validConflictPolicy(onConflict) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid on_conflict. Must be error, suggest or suffix"})
+		return false
+	}
+	return true
+}
 
-	c.JSON(http.StatusCreated, response)
+// urlResponse describes a stored link, looking up when it expires. Lookup
+// failures leave expires_at empty rather than failing a request whose
+// change is already stored.
+func (h *Handler) urlResponse(c *gin.Context, key string, rec *storage.Record) URLResponse {
+	expiresAt, _ := h.store.ExpiresAt(c.Request.Context(), key)
+	return h.newURLResponse(key, rec, expiresAt)
+}
+
+// newURLResponse describes a link served under key
+func (h *Handler) newURLResponse(key string, rec *storage.Record, expiresAt *time.Time) URLResponse {
+	return URLResponse{
+		ShortKey:   key,
+		ShortURL:   h.linkURL(key, rec),
+		URL:        rec.URL,
+		CreatedAt:  rec.CreatedAt,
+		ExpiresAt:  expiresAt,
+		DisplayURL: displayURL(rec.URL),
+		Unverified: rec.Unverified,
+	}
 }
 
 // RedirectURL handles the URL redirection
@@ -107,15 +822,38 @@ func (h *Handler) RedirectURL(c *gin.Context) {
 	key := c.Param("key")
 
 	// Validate key format
-	if !h.generator.ValidateKey(key) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid URL key format"})
+	if !h.validKey(key) {
+		h.notFound(c, key, "Invalid URL key format")
 		return
 	}
 
 	// Get the original URL from storage
-	url, err := h.store.Get(c.Request.Context(), key)
+	rec, err := h.store.GetRecord(c.Request.Context(), key)
+	if err != nil && err != storage.ErrNotFound && h.fallback != nil {
+		// Primary store is unavailable, try the standby snapshot, whose
+		// links still get their domain and activation window checked
+		if fallbackRec, fallbackErr := h.fallback.GetRecord(c.Request.Context(), key); fallbackErr == nil {
+			rec, err = fallbackRec, nil
+		}
+	}
 	if err == storage.ErrNotFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		if !h.serveLandingPage(c, key) && !h.aliasRedirect(c, key) {
+			h.notFound(c, key, "URL not found")
+		}
+		return
+	}
+	if err == nil && rec.Domain != "" && !onDomain(c.Request, rec.Domain) {
+		// Links under a custom domain are served on it alone
+		h.notFound(c, key, "URL not found")
+		return
+	}
+	if err == nil && rec.Signed && !h.validLinkSignature(c, key) {
+		// Signed links are hidden from requests without their signature
+		h.notFound(c, key, "URL not found")
+		return
+	}
+	if err == storage.ErrUnavailable {
+		h.unavailable(c)
 		return
 	}
 	if err != nil {
@@ -123,30 +861,437 @@ func (h *Handler) RedirectURL(c *gin.Context) {
 		return
 	}
 
+	// Enforce the activation window, the disabled toggle and verification
+	if err := rec.CheckActive(time.Now()); err != nil {
+		h.renderInactive(c, InactivePageData{
+			Key:          key,
+			Disabled:     err == storage.ErrDisabled,
+			Unverified:   err == storage.ErrUnverified,
+			NotYetActive: err == storage.ErrNotYetActive,
+			ActiveFrom:   rec.ActiveFrom,
+			ActiveUntil:  rec.ActiveUntil,
+		})
+		return
+	}
+
+	// Single-use links redirect from the record deleted by this request,
+	// so however many requests race for a link, only one is redirected
+	if rec.SingleUse {
+		var ok bool
+		if rec, ok = h.consumeLink(c, key); !ok {
+			return
+		}
+	}
+	if rec.MaxClicks > 0 && !h.claimClick(c, key, rec) {
+		return
+	}
+
+	dest, variant, err := h.destination(c, key, rec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build destination URL"})
+		return
+	}
+
+	// Bots and HEAD requests from link checkers are left out of statistics,
+	// and bots may get metadata instead of a redirect
+	if !h.isBot(c) && c.Request.Method != http.MethodHead && !rec.SingleUse {
+		h.recordClick(c, key, rec, variant)
+	}
+	if h.wantsPreview(c, rec) {
+		renderPreview(c, key, dest, rec.Preview)
+		return
+	}
+	if rec.Interstitial != nil {
+		h.meterUsage(rec, metering.Redirects)
+		h.setRedirectCacheHeaders(c, rec, time.Now())
+		h.renderInterstitial(c, key, dest, rec.Interstitial)
+		return
+	}
+
 	// Redirect to the original URL
-	c.Redirect(http.StatusFound, url)
+	h.meterUsage(rec, metering.Redirects)
+	h.setRedirectCacheHeaders(c, rec, time.Now())
+	c.Redirect(redirectStatus(rec), dest)
+}
+
+// RedirectOptions lists the methods short links support
+func (h *Handler) RedirectOptions(c *gin.Context) {
+	c.Header("Allow", "GET, HEAD, OPTIONS")
+	c.Status(http.StatusNoContent)
+}
+
+// GetURL returns the details of a link without redirecting to it
+func (h *Handler) GetURL(c *gin.Context) {
+	if response, _, ok := h.linkDetails(c); ok {
+		writeConditionalJSON(c, http.StatusOK, response)
+	}
+}
+
+// linkDetails looks up the link named in the path with its expiry and
+// clicks, answering the request itself if it can't
+func (h *Handler) linkDetails(c *gin.Context) (LinkResponse, *storage.Record, bool) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return LinkResponse{}, nil, false
+	}
+
+	expiresAt, err := h.store.ExpiresAt(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return LinkResponse{}, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return LinkResponse{}, nil, false
+	}
+
+	response := linkResponse(key, rec)
+	response.ExpiresAt = expiresAt
+	if h.stats != nil {
+		stats, err := h.stats.GetStats(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+			return LinkResponse{}, nil, false
+		}
+		response.Clicks = &stats.Clicks
+	}
+	return response, rec, true
+}
+
+// linkResponse builds the details of a link from its record
+func linkResponse(key string, rec *storage.Record) LinkResponse {
+	response := LinkResponse{
+		ShortKey:       key,
+		URL:            rec.URL,
+		DisplayURL:     displayURL(rec.URL),
+		Domain:         rec.Domain,
+		Owner:          rec.Owner,
+		Workspace:      rec.Workspace,
+		CreatedAt:      rec.CreatedAt,
+		ActiveFrom:     rec.ActiveFrom,
+		ActiveUntil:    rec.ActiveUntil,
+		Disabled:       rec.Disabled,
+		Unverified:     rec.Unverified,
+		QueryParams:    rec.QueryParams,
+		Rules:          rec.Rules,
+		DeviceRules:    rec.DeviceRules,
+		LocaleRules:    rec.LocaleRules,
+		Variants:       rec.Variants,
+		StickyVariants: rec.StickyVariants,
+		Fallbacks:      rec.Fallbacks,
+		Down:           rec.Down,
+		Schedule:       rec.Schedule,
+		Tags:           rec.Tags,
+		Label:          rec.Label,
+		Note:           rec.Note,
+		Permanent:      rec.Permanent,
+		CacheMaxAge:    rec.CacheMaxAge,
+		Signed:         rec.Signed,
+		SingleUse:      rec.SingleUse,
+		MaxClicks:      rec.MaxClicks,
+		Interstitial:   rec.Interstitial,
+		Expiry:         rec.Expiry,
+		Aliases:        rec.Aliases,
+		Check:          rec.Check,
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	if rec.Preview != nil {
+		response.Title = rec.Preview.Title
+		response.Description = rec.Preview.Description
+	}
+	return response
+}
+
+// UpdateURL handles changes to an existing URL mapping
+func (h *Handler) UpdateURL(c *gin.Context) {
+	if key, rec, ok := h.updateLink(c); ok {
+		c.JSON(http.StatusOK, h.urlResponse(c, key, rec))
+	}
 }
 
-// DeleteURL handles the URL deletion request
+// updateLink applies the changes in the request body to a link, answering
+// the request itself if it can't
+func (h *Handler) updateLink(c *gin.Context) (string, *storage.Record, bool) {
+	key := c.Param("key")
+
+	// Validate key format
+	if !h.validKey(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL key format"})
+		return "", nil, false
+	}
+
+	var req UpdateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return "", nil, false
+	}
+	canonicalizeUpdate(&req)
+
+	rec, err := h.store.GetRecord(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return "", nil, false
+	}
+	if !h.canManage(c, rec) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the link owner or an admin may do this"})
+		return "", nil, false
+	}
+	if !h.secureDestinations(c, updateFields(&req)) {
+		return "", nil, false
+	}
+	before := h.snapshot(rec)
+
+	// Apply the supplied changes
+	urlChanged := false
+	if req.URL != nil {
+		if !validDestination(*req.URL) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Must be absolute with http(s) scheme"})
+			return "", nil, false
+		}
+		if !h.resolveChain(c, req.URL) {
+			return "", nil, false
+		}
+		if *req.URL != rec.URL {
+			// The cached preview, health and status belong to the old destination
+			rec.URL = *req.URL
+			rec.Preview = nil
+			rec.Down = nil
+			rec.Check = nil
+			urlChanged = true
+		}
+	}
+	if req.ActiveFrom != nil {
+		rec.ActiveFrom = req.ActiveFrom
+	}
+	if req.ActiveUntil != nil {
+		rec.ActiveUntil = req.ActiveUntil
+	}
+	if req.QueryParams != nil {
+		if !validQueryParams(*req.QueryParams) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+			return "", nil, false
+		}
+		rec.QueryParams = *req.QueryParams
+	}
+	if req.Rules != nil {
+		if err := rules.Validate(*req.Rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rules: " + err.Error()})
+			return "", nil, false
+		}
+		rec.Rules = *req.Rules
+	}
+	if req.DeviceRules != nil {
+		if !validDeviceRules(*req.DeviceRules) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device rules"})
+			return "", nil, false
+		}
+		rec.DeviceRules = *req.DeviceRules
+	}
+	if req.LocaleRules != nil {
+		if !validLocaleRules(*req.LocaleRules) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locale rules"})
+			return "", nil, false
+		}
+		rec.LocaleRules = *req.LocaleRules
+	}
+	if req.Variants != nil {
+		if !normalizeVariants(*req.Variants) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variants"})
+			return "", nil, false
+		}
+		rec.Variants = *req.Variants
+	}
+	if req.StickyVariants != nil {
+		rec.StickyVariants = *req.StickyVariants
+	}
+	if req.Fallbacks != nil {
+		if !validFallbacks(*req.Fallbacks) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fallbacks. At most 5 absolute http(s) URLs"})
+			return "", nil, false
+		}
+		rec.Fallbacks = *req.Fallbacks
+		rec.Down = nil
+	}
+	if req.Tags != nil {
+		tags, ok := normalizeTags(*req.Tags)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags"})
+			return "", nil, false
+		}
+		rec.Tags = tags
+	}
+	if req.Label != nil {
+		if !validLabel(*req.Label) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid label. Must be at most 100 characters on one line"})
+			return "", nil, false
+		}
+		rec.Label = *req.Label
+	}
+	if req.Note != nil {
+		if !validNote(*req.Note) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note. Must be at most 2000 characters"})
+			return "", nil, false
+		}
+		rec.Note = *req.Note
+	}
+	if req.Permanent != nil {
+		rec.Permanent = *req.Permanent
+	}
+	if req.Signed != nil {
+		if *req.Signed && h.signingSecret == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Signed links are not enabled"})
+			return "", nil, false
+		}
+		rec.Signed = *req.Signed
+	}
+	if req.SingleUse != nil {
+		if *req.SingleUse && h.consumer == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Single-use links are not enabled"})
+			return "", nil, false
+		}
+		rec.SingleUse = *req.SingleUse
+	}
+	if req.MaxClicks != nil {
+		if !h.validMaxClicks(c, *req.MaxClicks) {
+			return "", nil, false
+		}
+		rec.MaxClicks = *req.MaxClicks
+	}
+	if req.CacheMaxAge != nil {
+		if !validCacheMaxAge(req.CacheMaxAge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cache_max_age"})
+			return "", nil, false
+		}
+		rec.CacheMaxAge = req.CacheMaxAge
+	}
+	if req.Interstitial != nil {
+		rec.Interstitial = req.Interstitial
+		if rec.Interstitial.Seconds == 0 {
+			rec.Interstitial = nil
+		}
+		if !validInterstitial(rec.Interstitial) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": interstitialError})
+			return "", nil, false
+		}
+	}
+	if req.Expiry != nil {
+		if !req.Expiry.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry. Must be sliding or absolute"})
+			return "", nil, false
+		}
+		rec.Expiry = *req.Expiry
+	}
+	if err := rec.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid activation window. active_until must be after active_from"})
+		return "", nil, false
+	}
+	if !h.withinURLLength(c, updatedURLs(req)...) {
+		return "", nil, false
+	}
+
+	err = h.store.Update(c.Request.Context(), key, rec)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return "", nil, false
+	}
+	if urlChanged {
+		h.prefetchPreview(key, rec)
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditUpdate, key, before, rec)
+
+	return key, rec, true
+}
+
+// DeleteURL handles the URL deletion request. It answers 204 once the link
+// is deleted and 404 for unknown keys, or 204 for those too with
+// idempotent deletes. A created_at query parameter guards against deleting
+// a link re-created under the same key since the caller last saw it. Only
+// the link that was checked is deleted, so one created meanwhile survives.
 func (h *Handler) DeleteURL(c *gin.Context) {
 	key := c.Param("key")
 
 	// Validate key format
-	if !h.generator.ValidateKey(key) {
+	if !h.validKey(key) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL key format"})
 		return
 	}
 
-	// Delete the URL mapping
-	err := h.store.Delete(c.Request.Context(), key)
+	var createdAt *time.Time
+	if value := c.Query("created_at"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid created_at. Must be an RFC 3339 timestamp"})
+			return
+		}
+		createdAt = &t
+	}
+
+	rec, err := h.store.GetRecord(c.Request.Context(), key)
 	if err == storage.ErrNotFound {
-		c.Status(http.StatusNoContent)
+		h.deleteMissing(c)
 		return
 	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete URL"})
 		return
 	}
+	if !h.canManage(c, rec) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the link owner or an admin may do this"})
+		return
+	}
+	if createdAt != nil && !rec.CreatedAt.Equal(*createdAt) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Key now belongs to a link created at another time"})
+		return
+	}
 
-	c.Status(http.StatusOK)
+	// Delete the URL mapping, unless the key was reused since it was read
+	err = h.store.DeleteIfCreated(c.Request.Context(), key, rec.CreatedAt)
+	if err == storage.ErrNotFound {
+		h.deleteMissing(c)
+		return
+	}
+	if err == storage.ErrLinkReplaced {
+		if createdAt != nil {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Key now belongs to a link created at another time"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Link changed while it was deleted. Try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete URL"})
+		return
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditDelete, key, h.snapshot(rec), nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// WithIdempotentDeletes answers deletes of unknown keys with 204 like
+// successful ones, so clients retrying a delete don't see it fail
+func WithIdempotentDeletes() Option {
+	return func(h *Handler) {
+		h.idempotentDeletes = true
+	}
+}
+
+// deleteMissing answers a delete of a key that doesn't exist
+func (h *Handler) deleteMissing(c *gin.Context) {
+	if h.idempotentDeletes {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
 }
@@ -4,19 +4,35 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/prayushdave/url-shortener/internal/accesslog"
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/captcha"
+	"github.com/prayushdave/url-shortener/internal/chain"
 	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/mail"
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/purge"
 	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/upgrade"
 )
 
 func setupTestServer(t *testing.T) (*gin.Engine, *storage.RedisStore) {
@@ -67,7 +83,11 @@ func TestCreateURL_Integration(t *testing.T) {
 
 				// Validate response format
 				assert.NotEmpty(t, response.ShortKey)
+				assert.Equal(t, "http://localhost:8080/"+response.ShortKey, response.ShortURL)
 				assert.Equal(t, "https://example.com", response.URL)
+				assert.WithinDuration(t, time.Now(), response.CreatedAt, time.Minute)
+				require.NotNil(t, response.ExpiresAt)
+				assert.WithinDuration(t, time.Now().Add(storage.DefaultTTL), *response.ExpiresAt, time.Minute)
 
 				// Verify URL was stored in Redis
 				url, err := store.Get(context.Background(), response.ShortKey)
@@ -410,19 +430,11 @@ func TestRedirectURL_EdgeCases(t *testing.T) {
 			path:           "/",
 			expectedStatus: http.StatusNotFound,
 			validateResp: func(t *testing.T, w *httptest.ResponseRecorder) {
-				// For root path with empty key, Gin might return different response
-				// This tests the actual behavior
-				if w.Code == http.StatusNotFound {
-					// Check if it's JSON error response or HTML 404
-					contentType := w.Header().Get("Content-Type")
-					if strings.Contains(contentType, "application/json") {
-						var response map[string]string
-						err := json.NewDecoder(w.Body).Decode(&response)
-						if err == nil {
-							assert.Contains(t, response["error"], "Invalid URL key format")
-						}
-					}
-				}
+				// The root path is not a key lookup
+				var response map[string]string
+				err := json.NewDecoder(w.Body).Decode(&response)
+				require.NoError(t, err)
+				assert.Equal(t, "Not found", response["error"])
 			},
 		},
 		{
@@ -479,7 +491,7 @@ func TestDeleteURL_Integration(t *testing.T) {
 				resp := createTestURL(t, router, "https://example.com")
 				return resp.ShortKey
 			},
-			expectedStatus: http.StatusOK,
+			expectedStatus: http.StatusNoContent,
 			validateState: func(t *testing.T, key string) {
 				// Verify URL was deleted from Redis
 				_, err := store.Get(context.Background(), key)
@@ -489,7 +501,7 @@ func TestDeleteURL_Integration(t *testing.T) {
 		{
 			name:           "Non-existent key",
 			key:            "abcd1234", // Valid format (8 chars, base62) but doesn't exist
-			expectedStatus: http.StatusNoContent,
+			expectedStatus: http.StatusNotFound,
 			validateState: func(t *testing.T, key string) {
 				// Verify key still doesn't exist
 				_, err := store.Get(context.Background(), key)
@@ -521,10 +533,10 @@ func TestDeleteURL_Integration(t *testing.T) {
 				req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/api/v1/urls/%s", key), nil)
 				w := httptest.NewRecorder()
 				router.ServeHTTP(w, req)
-				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, http.StatusNoContent, w.Code)
 				return key
 			},
-			expectedStatus: http.StatusNoContent,
+			expectedStatus: http.StatusNotFound,
 			validateState: func(t *testing.T, key string) {
 				// Verify URL is still deleted
 				_, err := store.Get(context.Background(), key)
@@ -547,11 +559,76 @@ func TestDeleteURL_Integration(t *testing.T) {
 			router.ServeHTTP(w, req)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
+			if w.Code == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+			}
 			tt.validateState(t, key)
 		})
 	}
 }
 
+func TestDeleteURLs_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	old := time.Now().UTC().Add(-48 * time.Hour)
+	require.NoError(t, store.Create(ctx, "sale0001", &storage.Record{URL: "https://shop.example.com/a", Tags: []string{"sale"}, CreatedAt: old}))
+	require.NoError(t, store.Create(ctx, "sale0002", &storage.Record{URL: "https://example.org/b", Tags: []string{"sale"}, CreatedAt: old}))
+	require.NoError(t, store.Create(ctx, "keep0001", &storage.Record{URL: "https://example.com/c", CreatedAt: time.Now().UTC()}))
+
+	remove := func(body map[string]interface{}) BatchDeleteResponse {
+		w := sendJSON(t, router, http.MethodDelete, "/api/v1/urls", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response BatchDeleteResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+	exists := func(key string) bool {
+		_, err := store.GetRecord(ctx, key)
+		return err == nil
+	}
+
+	// A dry run reports the matches and deletes nothing
+	response := remove(map[string]interface{}{
+		"filter":  map[string]interface{}{"tag": "sale", "domain": "example.com"},
+		"dry_run": true,
+	})
+	assert.True(t, response.DryRun)
+	assert.Equal(t, 1, response.Deleted)
+	assert.Equal(t, []URLResponse{{
+		ShortKey:  "sale0001",
+		ShortURL:  "http://localhost:8080/sale0001",
+		URL:       "https://shop.example.com/a",
+		CreatedAt: old,
+	}}, response.Links)
+	assert.True(t, exists("sale0001"))
+
+	// Filters match every set field
+	response = remove(map[string]interface{}{
+		"filter": map[string]interface{}{"created_before": time.Now().UTC().Add(-24 * time.Hour)},
+	})
+	assert.Equal(t, 2, response.Deleted)
+	assert.False(t, exists("sale0001"))
+	assert.False(t, exists("sale0002"))
+	assert.True(t, exists("keep0001"))
+
+	// Listed keys that can't be deleted are reported individually
+	response = remove(map[string]interface{}{"keys": []string{"keep0001", "missing1", "keep0001", "bad key!"}})
+	assert.Equal(t, 1, response.Deleted)
+	assert.Equal(t, []BatchDeleteError{
+		{Key: "missing1", Error: "URL not found"},
+		{Key: "bad key!", Error: "Invalid URL key format"},
+	}, response.Errors)
+	assert.False(t, exists("keep0001"))
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodDelete, "/api/v1/urls", map[string]interface{}{"filter": map[string]interface{}{}}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodDelete, "/api/v1/urls", map[string]interface{}{
+		"keys":   []string{"keep0001"},
+		"filter": map[string]interface{}{"tag": "sale"},
+	}).Code)
+}
+
 func TestDeleteURL_Concurrent(t *testing.T) {
 	router, store := setupTestServer(t)
 	defer store.Close()
@@ -578,7 +655,7 @@ func TestDeleteURL_Concurrent(t *testing.T) {
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
-			if w.Code != http.StatusOK && w.Code != http.StatusNoContent {
+			if w.Code != http.StatusNoContent && w.Code != http.StatusNotFound {
 				errCh <- fmt.Errorf("unexpected status code: %d", w.Code)
 				return
 			}
@@ -597,23 +674,2973 @@ func TestDeleteURL_Concurrent(t *testing.T) {
 	}
 
 	// Verify results
-	okCount := 0
 	noContentCount := 0
+	notFoundCount := 0
 	for code := range successCh {
 		switch code {
-		case http.StatusOK:
-			okCount++
 		case http.StatusNoContent:
 			noContentCount++
+		case http.StatusNotFound:
+			notFoundCount++
 		}
 	}
 
-	// We should have exactly one OK (the first successful deletion)
-	// and the rest should be NoContent (subsequent attempts)
-	assert.Equal(t, 1, okCount, "Expected exactly one successful deletion")
-	assert.Equal(t, n-1, noContentCount, "Expected all other attempts to return NoContent")
+	// We should have exactly one NoContent (the first successful deletion)
+	// and the rest should be NotFound (subsequent attempts)
+	assert.Equal(t, 1, noContentCount, "Expected exactly one successful deletion")
+	assert.Equal(t, n-1, notFoundCount, "Expected all other attempts to return NotFound")
 
 	// Verify the URL is actually deleted
 	_, err := store.Get(context.Background(), key)
 	assert.ErrorIs(t, err, storage.ErrNotFound, "URL should be deleted after concurrent deletion attempts")
 }
+
+func TestRedirectURL_ActivationWindow(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	now := time.Now().UTC()
+	create := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		return sendJSON(t, router, http.MethodPost, "/api/v1/urls", body)
+	}
+	redirect := func(w *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+		return followCreated(t, router, w)
+	}
+
+	// Within the window
+	w := redirect(create(map[string]interface{}{
+		"url":          "https://example.com/live",
+		"active_from":  now.Add(-time.Hour),
+		"active_until": now.Add(time.Hour),
+	}))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/live", w.Header().Get("Location"))
+
+	// Before the window
+	w = redirect(create(map[string]interface{}{
+		"url":         "https://example.com/launch",
+		"active_from": now.Add(time.Hour),
+	}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "not active yet")
+
+	// After the window
+	w = redirect(create(map[string]interface{}{
+		"url":          "https://example.com/ended",
+		"active_until": now.Add(-time.Minute),
+	}))
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), "no longer active")
+
+	// Inverted window
+	w = create(map[string]interface{}{
+		"url":          "https://example.com",
+		"active_from":  now.Add(time.Hour),
+		"active_until": now,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDisableURL_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	key := createTestURL(t, router, "https://example.com/paused").ShortKey
+	redirect := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+key, nil))
+		return w
+	}
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls/"+key+"/disable", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var toggled ToggleResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&toggled))
+	assert.Equal(t, ToggleResponse{ShortKey: key, Disabled: true}, toggled)
+
+	// Disabled links show the unavailable page and count no clicks
+	w = redirect()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), "temporarily unavailable")
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+key, nil)
+	var link LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.True(t, link.Disabled)
+	assert.Equal(t, "https://example.com/paused", link.URL)
+
+	// Enabling is idempotent and restores the redirect
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPost, "/api/v1/urls/"+key+"/enable", nil).Code)
+	}
+	w = redirect()
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/paused", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodPost, "/api/v1/urls/missing1/disable", nil).Code)
+}
+
+func TestSchedule_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	key := createTestURL(t, router, "https://example.com/teaser").ShortKey
+	redirect := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+key, nil))
+		return w
+	}
+
+	now := time.Now().UTC()
+	next := now.Add(time.Hour).Truncate(time.Second)
+	w := sendJSON(t, router, http.MethodPut, "/api/v1/urls/"+key+"/schedule", map[string]interface{}{
+		"schedule": []map[string]interface{}{
+			{"from": next, "url": "https://example.com/replay"},
+			{"from": now.Add(-time.Minute), "url": "https://example.com/live"},
+		},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var schedule ScheduleResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&schedule))
+	require.Len(t, schedule.Schedule, 2)
+	assert.Equal(t, "https://example.com/live", schedule.Schedule[0].URL, "entries are sorted by start")
+	assert.Equal(t, "https://example.com/live", schedule.Current)
+	require.NotNil(t, schedule.NextChange)
+	assert.True(t, next.Equal(*schedule.NextChange))
+
+	// The redirect follows the schedule
+	w = redirect()
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/live", w.Header().Get("Location"))
+
+	// An empty schedule restores the default destination
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/"+key+"/schedule", map[string]interface{}{"schedule": []interface{}{}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://example.com/teaser", redirect().Header().Get("Location"))
+
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/"+key+"/schedule", map[string]interface{}{
+		"schedule": []map[string]interface{}{{"from": next, "url": "javascript:alert(1)"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/"+key+"/schedule", map[string]interface{}{
+		"schedule": []map[string]interface{}{{"from": next, "url": "https://example.com/a"}, {"from": next, "url": "https://example.com/b"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFallbacks_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":       "https://example.com/sale",
+		"fallbacks": []string{"https://backup.example.com/sale"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	redirect := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortKey, nil))
+		return w
+	}
+
+	w = redirect()
+	assert.Equal(t, "https://example.com/sale", w.Header().Get("Location"))
+	assert.Equal(t, "private, max-age=0", w.Header().Get("Cache-Control"))
+
+	// While the health checker finds the primary down, the fallback is served
+	require.NoError(t, store.SaveHealth(ctx, created.ShortKey, []string{"https://example.com/sale"}))
+	assert.Equal(t, "https://backup.example.com/sale", redirect().Header().Get("Location"))
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+created.ShortKey, nil)
+	var link LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, []string{"https://backup.example.com/sale"}, link.Fallbacks)
+	assert.Equal(t, []string{"https://example.com/sale"}, link.Down)
+
+	// Changing the destination forgets its health
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"url": "https://example.com/sale2"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://example.com/sale2", redirect().Header().Get("Location"))
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":       "https://example.com",
+		"fallbacks": []string{"ftp://example.com"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListURLs_Status(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "live0001", &storage.Record{URL: "https://example.com/live", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "dead0001", &storage.Record{URL: "https://example.com/dead", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "new00001", &storage.Record{URL: "https://example.com/new", CreatedAt: now}))
+	require.NoError(t, store.SaveCheck(ctx, "live0001", "https://example.com/live", &storage.DestinationCheck{Status: 200, Result: storage.CheckOK, CheckedAt: now}))
+	require.NoError(t, store.SaveCheck(ctx, "dead0001", "https://example.com/dead", &storage.DestinationCheck{Status: 404, Result: storage.CheckBroken, CheckedAt: now}))
+
+	list := func(status string) []LinkResponse {
+		w := sendJSON(t, router, http.MethodGet, "/api/v1/urls?status="+status, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response ListURLsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response.URLs
+	}
+
+	broken := list("broken")
+	require.Len(t, broken, 1)
+	assert.Equal(t, "dead0001", broken[0].ShortKey)
+	assert.Equal(t, 404, broken[0].Check.Status)
+	assert.Len(t, list("ok"), 1)
+	assert.Len(t, list("unchecked"), 1)
+
+	// A check of a previous destination is discarded
+	require.NoError(t, store.SaveCheck(ctx, "new00001", "https://example.com/old", &storage.DestinationCheck{Result: storage.CheckBroken, CheckedAt: now}))
+	assert.Len(t, list("broken"), 1)
+
+	// Changing the destination clears its status
+	w := sendJSON(t, router, http.MethodPatch, "/api/v1/urls/dead0001", map[string]string{"url": "https://example.com/fixed"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, list("broken"))
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/urls?status=dead", nil).Code)
+}
+
+// Helper function to send a JSON request and return the recorded response
+func sendJSON(t *testing.T, router *gin.Engine, method, path string, body interface{}) *httptest.ResponseRecorder {
+	return sendJSONWithHeaders(t, router, method, path, nil, body)
+}
+
+// Helper function to send a JSON request with extra headers
+func sendJSONWithHeaders(t *testing.T, router *gin.Engine, method, path string, headers map[string]string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to request the short link from a create response
+func followCreated(t *testing.T, router *gin.Engine, created *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+	require.Equal(t, http.StatusCreated, created.Code)
+	var response URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&response))
+
+	req := httptest.NewRequest(http.MethodGet, "/"+response.ShortKey, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRedirectURL_QueryParams(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com/landing?ref=home#top",
+		"query_params": map[string]string{
+			"utm_source":   "newsletter",
+			"utm_campaign": "link-{key}",
+		},
+	})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var response URLResponse
+	require.NoError(t, json.NewDecoder(bytes.NewReader(created.Body.Bytes())).Decode(&response))
+
+	w := followCreated(t, router, created)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t,
+		"https://example.com/landing?ref=home&utm_campaign=link-"+response.ShortKey+"&utm_source=newsletter#top",
+		w.Header().Get("Location"))
+
+	// Empty parameter names are rejected
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":          "https://example.com",
+		"query_params": map[string]string{"": "x"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRedirectURL_DeviceRules(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com/web",
+		"device_rules": []map[string]string{
+			{"platform": "ios", "url": "https://apps.apple.com/app/example"},
+			{"platform": "android", "url": "https://play.google.com/store/apps/details?id=com.example"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var response URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&response))
+
+	tests := []struct {
+		name     string
+		ua       string
+		expected string
+	}{
+		{name: "iOS", ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", expected: "https://apps.apple.com/app/example"},
+		{name: "Android", ua: "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile", expected: "https://play.google.com/store/apps/details?id=com.example"},
+		{name: "Desktop", ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64)", expected: "https://example.com/web"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+response.ShortKey, nil)
+			req.Header.Set("User-Agent", tt.ua)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusFound, w.Code)
+			assert.Equal(t, tt.expected, w.Header().Get("Location"))
+		})
+	}
+
+	// Unknown platforms are rejected
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":          "https://example.com",
+		"device_rules": []map[string]string{{"platform": "toaster", "url": "https://example.com"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRedirectURL_Variants(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com",
+		"variants": []map[string]interface{}{
+			{"name": "a", "url": "https://example.com/a", "weight": 1},
+			{"name": "b", "url": "https://example.com/b", "weight": 1},
+		},
+		"sticky_variants": true,
+	})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var response URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&response))
+
+	// Every variant is served eventually
+	served := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+response.ShortKey, nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		served[w.Header().Get("Location")] = true
+	}
+	assert.Equal(t, map[string]bool{"https://example.com/a": true, "https://example.com/b": true}, served)
+
+	// A sticky visitor keeps the variant from their cookie
+	req := httptest.NewRequest(http.MethodGet, "/"+response.ShortKey, nil)
+	req.AddCookie(&http.Cookie{Name: "variant_" + response.ShortKey, Value: "b"})
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "https://example.com/b", w.Header().Get("Location"))
+	}
+
+	// Under a redirect prefix, the cookie is scoped to where the link is
+	// served
+	prefixed := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithRedirectRoutes(RedirectRoutes{Prefix: "/r"})).SetupRoutes(prefixed)
+	w := httptest.NewRecorder()
+	prefixed.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/r/"+response.ShortKey, nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/r/"+response.ShortKey, cookies[0].Path)
+
+	// Invalid variants are rejected
+	invalid := []map[string]interface{}{
+		{"url": "https://example.com/a", "weight": 0},
+		{"name": "a", "url": "ftp://example.com", "weight": 1},
+	}
+	for _, v := range invalid {
+		w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+			"url":      "https://example.com",
+			"variants": []map[string]interface{}{v},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	}
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com",
+		"variants": []map[string]interface{}{
+			{"name": "a", "url": "https://example.com/a", "weight": 1},
+			{"name": "a", "url": "https://example.com/b", "weight": 1},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateURL_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	key := createTestURL(t, router, "https://example.com/old").ShortKey
+
+	tests := []struct {
+		name           string
+		key            string
+		body           map[string]interface{}
+		expectedStatus int
+	}{
+		{
+			name:           "Change destination and rules",
+			key:            key,
+			body:           map[string]interface{}{"url": "https://example.com/new", "device_rules": []map[string]string{{"platform": "mobile", "url": "https://m.example.com"}}},
+			expectedStatus: http.StatusOK,
+		},
+		{name: "Invalid URL", key: key, body: map[string]interface{}{"url": "not-a-url"}, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid rules", key: key, body: map[string]interface{}{"device_rules": []map[string]string{{"platform": "ios", "url": "ftp://x"}}}, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown key", key: "abcd1234", body: map[string]interface{}{"url": "https://example.com"}, expectedStatus: http.StatusNotFound},
+		{name: "Invalid key format", key: "abc", body: map[string]interface{}{"url": "https://example.com"}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+tt.key, tt.body)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	rec, err := store.GetRecord(context.Background(), key)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", rec.URL)
+	assert.Equal(t, []storage.DeviceRule{{Platform: "mobile", URL: "https://m.example.com"}}, rec.DeviceRules)
+}
+
+func TestGetURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	recorder := analytics.NewRecorder(store, 100)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithStats(store, recorder),
+	).SetupRoutes(router)
+
+	activeUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":          "https://example.com",
+		"active_until": activeUntil,
+		"query_params": map[string]string{"utm_source": "newsletter"},
+	})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusFound, sendJSON(t, router, http.MethodGet, "/"+link.ShortKey, nil).Code)
+	}
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	w := sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&detail))
+	assert.Equal(t, link.ShortKey, detail.ShortKey)
+	assert.Equal(t, "https://example.com", detail.URL)
+	assert.WithinDuration(t, time.Now(), detail.CreatedAt, time.Minute)
+	require.NotNil(t, detail.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(storage.DefaultTTL), *detail.ExpiresAt, time.Minute)
+	require.NotNil(t, detail.ActiveUntil)
+	assert.True(t, activeUntil.Equal(*detail.ActiveUntil))
+	assert.Equal(t, map[string]string{"utm_source": "newsletter"}, detail.QueryParams)
+	require.NotNil(t, detail.Clicks)
+	assert.Equal(t, int64(2), *detail.Clicks)
+
+	// Reading a link doesn't count as a click
+	require.NoError(t, recorder.Flush(context.Background()))
+	stats, err := store.GetStats(context.Background(), link.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Clicks)
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodGet, "/api/v1/urls/zzzzzzzz", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/urls/bad!", nil).Code)
+	recorder.Close()
+}
+
+func TestListURLs_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "bob-key": "bob", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+	list := func(path, apiKey string) []string {
+		w := send(http.MethodGet, path, apiKey, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response ListURLsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		var urls []string
+		for _, link := range response.URLs {
+			urls = append(urls, link.URL)
+		}
+		return urls
+	}
+
+	created := send(http.MethodPost, "/api/v1/urls", "alice-key", map[string]interface{}{
+		"url":  "https://example.com/spring-sale",
+		"tags": []string{"Sale", "spring", "sale"},
+	})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/api/v1/urls", "bob-key", map[string]interface{}{
+		"url":  "https://example.com/summer-sale",
+		"tags": []string{"sale"},
+	}).Code)
+
+	// Tags are normalized and returned with the link
+	w := send(http.MethodGet, "/api/v1/urls/"+link.ShortKey, "alice-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&detail))
+	assert.Equal(t, []string{"sale", "spring"}, detail.Tags)
+
+	// Callers only see their own links; admins see every link
+	assert.Equal(t, []string{"https://example.com/spring-sale"}, list("/api/v1/urls?tag=sale", "alice-key"))
+	assert.Len(t, list("/api/v1/urls?tag=sale", "admin-key"), 2)
+	assert.Equal(t, []string{"https://example.com/summer-sale"}, list("/api/v1/urls?q=summer", "admin-key"))
+	assert.Empty(t, list("/api/v1/urls?tag=winter", "admin-key"))
+
+	// Tags can be replaced on update
+	w = send(http.MethodPatch, "/api/v1/urls/"+link.ShortKey, "alice-key", map[string]interface{}{"tags": []string{"archive"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, list("/api/v1/urls?tag=spring", "alice-key"))
+	assert.Equal(t, []string{"https://example.com/spring-sale"}, list("/api/v1/urls?tag=archive", "alice-key"))
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/urls", "alice-key", map[string]interface{}{
+		"url":  "https://example.com",
+		"tags": []string{"not a tag"},
+	}).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/urls?tag=not+a+tag", "alice-key", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, sendJSON(t, router, http.MethodGet, "/api/v1/urls", nil).Code)
+}
+
+func TestLabelsAndNotes_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":   "https://example.com/q3-report",
+		"label": "Q3 report",
+		"note":  "Sent to investors.\nRotate after the call.",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+
+	get := func() LinkResponse {
+		w := sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+created.ShortKey, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var link LinkResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+		return link
+	}
+	link := get()
+	assert.Equal(t, "Q3 report", link.Label)
+	assert.Equal(t, "Sent to investors.\nRotate after the call.", link.Note)
+
+	// Labels and notes are searchable and show up in listings
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls?q=investors", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed ListURLsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Len(t, listed.URLs, 1)
+	assert.Equal(t, "Q3 report", listed.URLs[0].Label)
+
+	// Updates change only the supplied field; an empty string clears it
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"note": ""}).Code)
+	link = get()
+	assert.Equal(t, "Q3 report", link.Label)
+	assert.Empty(t, link.Note)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"label": "two\nlines"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":   "https://example.com",
+		"label": strings.Repeat("x", 101),
+	}).Code)
+}
+
+func TestVanityKeys_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store)).SetupRoutes(router)
+
+	suggest := func(query string) []string {
+		w := sendJSON(t, router, http.MethodGet, "/api/v1/keys/suggest?"+query, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response KeySuggestionsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response.Suggestions
+	}
+	assert.Equal(t, []string{"summer-sale", "summersale", "summer-sale-2"}, suggest("hint=Summer+Sale!&count=3"))
+
+	// Links can be created under a suggested key and redirect from it
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/summer", "key": "summer-sale"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "summer-sale", created.ShortKey)
+
+	req := httptest.NewRequest(http.MethodGet, "/summer-sale", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/summer", w.Header().Get("Location"))
+
+	// Taken keys are no longer suggested
+	assert.Equal(t, []string{"summersale", "summer-sale-2"}, suggest("hint=summer-sale&count=2"))
+	assert.Equal(t, http.StatusConflict, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "summer-sale"}).Code)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "api"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "a:b"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/keys/suggest?hint=%21%21", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/keys/suggest?hint=sale&count=50", nil).Code)
+
+	// Without vanity keys, custom keys are refused
+	router, plain := setupTestServer(t)
+	defer plain.Close()
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "summer-sale"}).Code)
+}
+
+func TestVanityKeyConflicts_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store)).SetupRoutes(router)
+
+	create := func(key, onConflict string) *httptest.ResponseRecorder {
+		return sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+			"url":         "https://example.com/" + key,
+			"key":         key,
+			"on_conflict": onConflict,
+		})
+	}
+	require.Equal(t, http.StatusCreated, create("launch", "").Code)
+	require.Equal(t, http.StatusCreated, create("launch-2", "").Code)
+
+	// Errors stay the default
+	assert.Equal(t, http.StatusConflict, create("launch", "").Code)
+	assert.Equal(t, http.StatusConflict, create("launch", "error").Code)
+	assert.Equal(t, http.StatusBadRequest, create("launch", "retry").Code)
+
+	// Suggestions skip taken keys
+	w := create("launch", "suggest")
+	require.Equal(t, http.StatusConflict, w.Code)
+	var conflict struct {
+		Error       string   `json:"error"`
+		Suggestions []string `json:"suggestions"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&conflict))
+	assert.Equal(t, "Key is already taken", conflict.Error)
+	require.Len(t, conflict.Suggestions, DefaultSuggestions)
+	assert.Equal(t, []string{"launch-3", "launch-4"}, conflict.Suggestions[:2])
+
+	// Suffixing creates the link under the first free key
+	w = create("launch", "suffix")
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "launch-3", created.ShortKey)
+	assert.Equal(t, "http://localhost:8080/launch-3", created.ShortURL)
+
+	// Free keys are created as requested
+	w = create("fresh", "suffix")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "fresh", created.ShortKey)
+
+	// Once the numeric suffixes run out, random ones are used
+	for n := 2; n < 2+maxSuffixAttempts-randomSuffixAttempts; n++ {
+		create("promo-"+strconv.Itoa(n), "")
+	}
+	require.Equal(t, http.StatusCreated, create("promo", "").Code)
+	w = create("promo", "suffix")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Regexp(t, `^promo-[a-z][a-z0-9]$`, created.ShortKey)
+}
+
+func TestCaseInsensitiveKeys_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewCaseInsensitiveGenerator(), "http://localhost:8080", WithCaseInsensitiveKeys(), WithVanityKeys(store)).SetupRoutes(router)
+
+	key := createTestURL(t, router, "https://example.com/phone").ShortKey
+	assert.Equal(t, strings.ToLower(key), key)
+
+	// Keys resolve whatever case they are typed in
+	for _, typed := range []string{key, strings.ToUpper(key)} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+typed, nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/phone", w.Header().Get("Location"))
+	}
+	assert.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+strings.ToUpper(key), nil).Code)
+
+	// Custom keys are stored folded, so case variants are the same key
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/sale", "key": "Summer-Sale"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "summer-sale", created.ShortKey)
+	assert.Equal(t, http.StatusConflict, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "SUMMER-SALE"}).Code)
+}
+
+func TestWorkspaces_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys:    map[string]string{"alice-key": "alice", "ci-key": "ci", "bob-key": "bob", "admin-key": "admin"},
+		Admins:     map[string]bool{"admin": true},
+		Workspaces: map[string]string{"alice": "acme", "ci": "acme", "bob": "globex"},
+	})
+	recorder := analytics.NewRecorder(store, 10)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithStats(store, recorder), WithWorkspaces(store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+
+	w := send(http.MethodPost, "/api/v1/urls", "ci-key", map[string]interface{}{"url": "https://example.com/acme"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/api/v1/urls", "bob-key", map[string]interface{}{"url": "https://example.com/globex"}).Code)
+
+	// Members of the workspace manage each other's links; other tenants cannot
+	w = send(http.MethodGet, "/api/v1/urls/"+link.ShortKey, "alice-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&detail))
+	assert.Equal(t, "acme", detail.Workspace)
+	assert.Equal(t, "ci", detail.Owner)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/urls/"+link.ShortKey, "bob-key", nil).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPatch, "/api/v1/urls/"+link.ShortKey, "bob-key", map[string]interface{}{"url": "https://evil.example"}).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodDelete, "/api/v1/urls/"+link.ShortKey, "bob-key", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodPatch, "/api/v1/urls/"+link.ShortKey, "alice-key", map[string]interface{}{"tags": []string{"team"}}).Code)
+
+	// Listing is scoped to the caller's workspace
+	w = send(http.MethodGet, "/api/v1/urls", "alice-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListURLsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.URLs, 1)
+	assert.Equal(t, link.ShortKey, list.URLs[0].ShortKey)
+
+	// Clicks are counted per workspace
+	redirect := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+	redirect.Header.Set("User-Agent", "Mozilla/5.0")
+	router.ServeHTTP(httptest.NewRecorder(), redirect)
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	w = send(http.MethodGet, "/api/v1/workspaces/acme/stats", "alice-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats storage.WorkspaceStats
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, storage.WorkspaceStats{Workspace: "acme", Links: 1, Clicks: 1}, stats)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/workspaces/acme/stats", "bob-key", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/workspaces/acme/stats", "admin-key", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/workspaces/Not:Valid/stats", "admin-key", nil).Code)
+
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/api/v1/urls/"+link.ShortKey, "alice-key", nil).Code)
+}
+
+func TestRoles_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys:     map[string]string{"viewer-key": "vera", "editor-key": "ed", "admin-key": "root"},
+		Roles:       map[string]auth.Role{"ed": auth.RoleEditor, "root": auth.RoleAdmin},
+		Workspaces:  map[string]string{"vera": "acme", "ed": "acme"},
+		DefaultRole: auth.RoleViewer,
+	})
+	recorder := analytics.NewRecorder(store, 10)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithStats(store, recorder), WithImportExport(store, store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+
+	// Viewers can't create or change links
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/urls", "viewer-key", map[string]interface{}{"url": "https://example.com"}).Code)
+	w := send(http.MethodPost, "/api/v1/urls", "editor-key", map[string]interface{}{"url": "https://example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	path := "/api/v1/urls/" + link.ShortKey
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPatch, path, "viewer-key", map[string]interface{}{"url": "https://evil.example"}).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, path+"/stats/reset", "viewer-key", nil).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodDelete, path, "viewer-key", nil).Code)
+
+	// but they read the links and statistics of their workspace
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, path, "viewer-key", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, path+"/stats", "viewer-key", nil).Code)
+
+	// Only admins use operator endpoints
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/admin/export", "editor-key", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/admin/export", "admin-key", nil).Code)
+
+	assert.Equal(t, http.StatusOK, send(http.MethodPatch, path, "editor-key", map[string]interface{}{"url": "https://example.com/new"}).Code)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodDelete, path, "admin-key", nil).Code)
+}
+
+func TestQuotas_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys:    map[string]string{"ci-key": "ci", "alice-key": "alice", "bob-key": "bob", "admin-key": "admin"},
+		Admins:     map[string]bool{"admin": true},
+		Workspaces: map[string]string{"ci": "acme", "alice": "acme"},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithQuotas(store, QuotaLimits{Daily: 3, Active: 2})).SetupRoutes(router)
+
+	create := func(apiKey, url string) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls",
+			map[string]string{auth.APIKeyHeader: apiKey}, map[string]interface{}{"url": url})
+	}
+
+	w := create("ci-key", "https://example.com/1")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "3", w.Header().Get(HeaderDailyLimit))
+	assert.Equal(t, "2", w.Header().Get(HeaderDailyRemaining))
+	assert.Equal(t, "1", w.Header().Get(HeaderActiveRemaining))
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	// Rejected requests do not use up the daily quota
+	assert.Equal(t, http.StatusBadRequest, create("alice-key", "not a url").Code)
+
+	// The workspace shares its quotas between members
+	require.Equal(t, http.StatusCreated, create("alice-key", "https://example.com/2").Code)
+	w = create("ci-key", "https://example.com/3")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderActiveRemaining))
+
+	// Deleting a link frees an active slot, leaving the last daily creation
+	require.Equal(t, http.StatusNoContent, sendJSONWithHeaders(t, router, http.MethodDelete, "/api/v1/urls/"+link.ShortKey,
+		map[string]string{auth.APIKeyHeader: "ci-key"}, nil).Code)
+	w = create("ci-key", "https://example.com/3")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "0", w.Header().Get(HeaderDailyRemaining))
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	require.Equal(t, http.StatusNoContent, sendJSONWithHeaders(t, router, http.MethodDelete, "/api/v1/urls/"+link.ShortKey,
+		map[string]string{auth.APIKeyHeader: "ci-key"}, nil).Code)
+	w = create("ci-key", "https://example.com/4")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Other subjects have their own quotas and admins are not limited
+	assert.Equal(t, http.StatusCreated, create("bob-key", "https://example.com/5").Code)
+	w = create("admin-key", "https://example.com/6")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(HeaderDailyLimit))
+
+	// Anonymous callers share a daily quota per client address, but their
+	// links belong to no one and aren't counted as active
+	for i := 0; i < 3; i++ {
+		w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/anonymous"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, strconv.Itoa(2-i), w.Header().Get(HeaderDailyRemaining))
+		assert.Empty(t, w.Header().Get(HeaderActiveLimit))
+	}
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/anonymous"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestIdempotency_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "bob-key": "bob"},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithIdempotency(store, time.Hour), WithQuotas(store, QuotaLimits{Daily: 1})).SetupRoutes(router)
+
+	create := func(apiKey, idempotencyKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls", map[string]string{
+			auth.APIKeyHeader:    apiKey,
+			IdempotencyKeyHeader: idempotencyKey,
+		}, body)
+	}
+	body := map[string]interface{}{"url": "https://example.com/order"}
+
+	// Failed requests are not stored and can be retried with the same key
+	assert.Equal(t, http.StatusBadRequest, create("alice-key", "order-1", map[string]interface{}{"url": "nope"}).Code)
+
+	first := create("alice-key", "order-1", body)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	// Retries replay the original link without using up quota
+	retry := create("alice-key", "order-1", body)
+	require.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, create("alice-key", "order-1", map[string]interface{}{"url": "https://example.com/other"}).Code)
+
+	// Keys are scoped to the caller
+	other := create("bob-key", "order-1", body)
+	require.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get(IdempotentReplayedHeader))
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, create("alice-key", strings.Repeat("k", 256), body).Code)
+}
+
+func TestRedirectURL_Caching(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithRedirectCache(time.Minute)).SetupRoutes(router)
+
+	create := func(body map[string]interface{}) string {
+		w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", body)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var response URLResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response.ShortKey
+	}
+	redirect := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+key, nil))
+		return w
+	}
+
+	tests := []struct {
+		name         string
+		body         map[string]interface{}
+		status       int
+		cacheControl string
+		vary         string
+	}{
+		{name: "Server default", body: map[string]interface{}{}, status: http.StatusFound, cacheControl: "public, max-age=60"},
+		{name: "Permanent with own max age", body: map[string]interface{}{"permanent": true, "cache_max_age": 86400}, status: http.StatusMovedPermanently, cacheControl: "public, max-age=86400"},
+		{name: "Uncached link", body: map[string]interface{}{"cache_max_age": 0}, status: http.StatusFound, cacheControl: "private, max-age=0"},
+		{name: "Variants", body: map[string]interface{}{"variants": []map[string]interface{}{{"name": "a", "url": "https://example.com/a", "weight": 1}}}, status: http.StatusFound, cacheControl: "private, max-age=0"},
+		{name: "Device rules", body: map[string]interface{}{"device_rules": []map[string]string{{"platform": "ios", "url": "https://apps.apple.com"}}}, status: http.StatusFound, cacheControl: "public, max-age=60", vary: "User-Agent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.body["url"] = "https://example.com"
+			w := redirect(create(tt.body))
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.cacheControl, w.Header().Get("Cache-Control"))
+			assert.NotEmpty(t, w.Header().Get("Expires"))
+			assert.Equal(t, tt.vary, w.Header().Get("Vary"))
+		})
+	}
+
+	// Redirects are not cached past the end of the activation window
+	until := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+	w := redirect(create(map[string]interface{}{"url": "https://example.com", "cache_max_age": 3600, "active_until": until}))
+	assert.Regexp(t, `^public, max-age=(2\d|30)$`, w.Header().Get("Cache-Control"))
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls",
+		map[string]interface{}{"url": "https://example.com", "cache_max_age": -1}).Code)
+}
+
+func TestGetURL_ETag(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	key := createTestURL(t, router, "https://example.com/etag").ShortKey
+	get := func(etag string) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, http.MethodGet, "/api/v1/urls/"+key, map[string]string{"If-None-Match": etag}, nil)
+	}
+
+	w := get("")
+	require.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	w = get(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotModified, get(`"other", W/`+etag).Code)
+	assert.Equal(t, http.StatusOK, get(`"other"`).Code)
+
+	// Changing the link changes its ETag
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+key, map[string]interface{}{"permanent": true}).Code)
+	w = get(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+// purgeRecorder records the URLs it is asked to purge
+type purgeRecorder struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (p *purgeRecorder) Purge(_ context.Context, urls []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.urls = append(p.urls, urls...)
+	return nil
+}
+
+func TestPurge_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	backend := &purgeRecorder{}
+	purger := purge.New(backend, 10)
+	defer purger.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "https://sho.rt/", WithPurge(purger)).SetupRoutes(router)
+
+	key := createTestURL(t, router, "https://example.com/old").ShortKey
+	require.NoError(t, store.Create(context.Background(), "custom12", &storage.Record{URL: "https://example.com", Domain: "go.example.com"}))
+
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+key, map[string]interface{}{"url": "https://example.com/new"}).Code)
+	require.Equal(t, http.StatusNoContent, sendJSON(t, router, http.MethodDelete, "/api/v1/urls/custom12", nil).Code)
+	require.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodDelete, "/api/v1/urls/custom12", nil).Code)
+	require.NoError(t, purger.Flush(context.Background()))
+
+	assert.Equal(t, []string{"https://sho.rt/" + key, "https://sho.rt/custom12", "https://go.example.com/custom12"}, backend.urls)
+}
+
+func TestImportExport_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithVanityKeys(store), WithImportExport(store, store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	jsonl := `{"key":"legacy1","url":"https://example.com/one","created_at":"2020-01-02T03:04:05Z","tags":["Old"]}
+{"key":"legacy2","url":"https://example.com/two","expires_at":"` + expiry.Format(time.RFC3339) + `"}
+
+{"key":"bad:key","url":"https://example.com/bad"}
+not json
+{"key":"expired","url":"https://example.com/gone","expires_at":"2000-01-01T00:00:00Z"}
+`
+
+	// Only admins may import or export
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/admin/import", "alice-key", jsonl).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/admin/export", "alice-key", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/admin/import?format=xml", "admin-key", "").Code)
+
+	w := send(http.MethodPost, "/api/v1/admin/import", "admin-key", jsonl)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp ImportResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, 2, resp.Imported)
+	assert.Equal(t, 3, resp.Failed)
+	require.Len(t, resp.Errors, 3)
+	assert.Equal(t, ImportError{Line: 4, Key: "bad:key", Error: "invalid key"}, resp.Errors[0])
+	assert.Equal(t, 5, resp.Errors[1].Line)
+	assert.Equal(t, ImportError{Line: 6, Key: "expired", Error: storage.ErrExpired.Error()}, resp.Errors[2])
+
+	rec, err := store.GetRecord(context.Background(), "legacy1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/one", rec.URL)
+	assert.Equal(t, []string{"old"}, rec.Tags)
+	assert.Equal(t, "admin", rec.Owner)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/legacy1", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/one", w.Header().Get("Location"))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/v1/urls/legacy1", "admin-key", "").Code)
+	expiresAt, err := store.ExpiresAt(context.Background(), "legacy2")
+	require.NoError(t, err)
+	require.NotNil(t, expiresAt)
+	assert.WithinDuration(t, expiry, *expiresAt, 2*time.Second)
+
+	// Existing keys are reported rather than overwritten
+	csvBody := "url,key,tags\nhttps://example.com/three,legacy3,a b\nhttps://example.com/other,legacy1,\n"
+	w = send(http.MethodPost, "/api/v1/admin/import?format=csv", "admin-key", csvBody)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = ImportResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, 1, resp.Imported)
+	assert.Equal(t, []ImportError{{Line: 3, Key: "legacy1", Error: storage.ErrKeyExists.Error()}}, resp.Errors)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/admin/import?format=csv", "admin-key", "link,target\n").Code)
+
+	// Keys redirects wouldn't resolve are refused
+	w = send(http.MethodPost, "/api/v1/admin/import", "admin-key", `{"key":"ab","url":"https://example.com/promo"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp = ImportResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, []ImportError{{Line: 1, Key: "ab", Error: "invalid key"}}, resp.Errors)
+
+	t.Run("JSONL export", func(t *testing.T) {
+		w := send(http.MethodGet, "/api/v1/admin/export", "admin-key", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		exported := make(map[string]TransferRecord)
+		decoder := json.NewDecoder(w.Body)
+		for decoder.More() {
+			var row TransferRecord
+			require.NoError(t, decoder.Decode(&row))
+			exported[row.Key] = row
+		}
+		require.Len(t, exported, 3)
+		assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), exported["legacy1"].CreatedAt)
+		assert.Equal(t, []string{"a", "b"}, exported["legacy3"].Tags)
+		require.NotNil(t, exported["legacy2"].ExpiresAt)
+	})
+
+	t.Run("CSV export", func(t *testing.T) {
+		w := send(http.MethodGet, "/api/v1/admin/export?format=csv", "admin-key", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, "key,url,created_at,expires_at,tags", lines[0])
+		found := false
+		for _, line := range lines[1:] {
+			if strings.HasPrefix(line, "legacy3,https://example.com/three,") && strings.HasSuffix(line, ",a b") {
+				found = true
+			}
+		}
+		assert.True(t, found, w.Body.String())
+	})
+}
+
+func TestBackupRestore_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithImportExport(store, store), WithAuditLog(store)).SetupRoutes(router)
+	send := func(method, path, apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/api/v1/urls", "alice-key", `{"url":"https://example.com/backup","label":"Backed up"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/admin/backup", "alice-key", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/backup?since=yesterday", "admin-key", "").Code)
+
+	w = send(http.MethodGet, "/api/v1/admin/backup", "admin-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	backup := w.Body.String()
+	lines := strings.Split(strings.TrimSpace(backup), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"format":"url-shortener-backup"`)
+	assert.Contains(t, lines[1], `"owner":"alice"`)
+
+	// Incremental backups from now on hold no links yet
+	w = send(http.MethodGet, "/api/v1/admin/backup?since="+time.Now().Add(time.Minute).UTC().Format(time.RFC3339), "admin-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, strings.Split(strings.TrimSpace(w.Body.String()), "\n"), 1)
+
+	require.NoError(t, store.FlushDB(ctx))
+	w = send(http.MethodPost, "/api/v1/admin/restore", "admin-key", backup)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"restored":1,"existing":0,"expired":0,"failed":0}`, w.Body.String())
+	rec, err := store.GetRecord(ctx, created.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, "Backed up", rec.Label)
+	assert.Equal(t, "alice", rec.Owner)
+
+	// Restored links are audited as the admin's
+	w = send(http.MethodGet, "/api/v1/admin/audit?action=restore", "admin-key", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var audit AuditResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 1)
+	assert.Equal(t, created.ShortKey, audit.Entries[0].Key)
+	assert.Equal(t, "admin", audit.Entries[0].Actor)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/admin/restore", "admin-key", `{"key":"legacy1"}`).Code)
+
+	// Entries are checked like imported rows, so they can't write outside
+	// the links' keyspace
+	header := lines[0] + "\n"
+	w = send(http.MethodPost, "/api/v1/admin/restore", "admin-key", header+
+		`{"key":"session:forged","record":{"url":"https://example.com"}}`+"\n"+
+		`{"key":"bad12345","record":{"url":"javascript:alert(1)"}}`+"\n")
+	require.Equal(t, http.StatusOK, w.Code)
+	var res struct {
+		Restored int `json:"restored"`
+		Failed   int `json:"failed"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, 0, res.Restored)
+	assert.Equal(t, 2, res.Failed)
+	_, err = store.GetRecord(ctx, "session:forged")
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	// Without authentication, bulk routes aren't served at all
+	open := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithImportExport(store, store)).SetupRoutes(open)
+	for _, path := range []string{"/api/v1/admin/restore", "/api/v1/admin/import"} {
+		w := httptest.NewRecorder()
+		open.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(backup)))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+func TestAuditLog_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager), WithAuditLog(store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+
+	w := send(http.MethodPost, "/api/v1/urls", "alice-key", map[string]interface{}{"url": "https://example.com/old"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	path := "/api/v1/urls/" + link.ShortKey
+	require.Equal(t, http.StatusOK, send(http.MethodPatch, path, "alice-key", map[string]interface{}{"url": "https://example.com/new"}).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPost, path+"/disable", "alice-key", nil).Code)
+	require.Equal(t, http.StatusNoContent, send(http.MethodDelete, path, "admin-key", nil).Code)
+
+	// Only admins read the log
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/admin/audit", "alice-key", nil).Code)
+
+	w = send(http.MethodGet, "/api/v1/admin/audit?key="+link.ShortKey, "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var audit AuditResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 4)
+
+	actions := make([]storage.AuditAction, len(audit.Entries))
+	for i, e := range audit.Entries {
+		actions[i] = e.Action
+		assert.NotEmpty(t, e.IP)
+	}
+	assert.Equal(t, []storage.AuditAction{storage.AuditDelete, storage.AuditDisable, storage.AuditUpdate, storage.AuditCreate}, actions)
+	assert.Equal(t, "admin", audit.Entries[0].Actor)
+	assert.Empty(t, audit.Entries[0].After)
+	assert.Empty(t, audit.Entries[3].Before)
+
+	// Updates carry the link before and after the change
+	var before, after storage.Record
+	require.NoError(t, json.Unmarshal(audit.Entries[2].Before, &before))
+	require.NoError(t, json.Unmarshal(audit.Entries[2].After, &after))
+	assert.Equal(t, "https://example.com/old", before.URL)
+	assert.Equal(t, "https://example.com/new", after.URL)
+
+	// Pages continue from the returned cursor
+	w = send(http.MethodGet, "/api/v1/admin/audit?actor=alice&limit=2", "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 2)
+	require.NotEmpty(t, audit.Next)
+	w = send(http.MethodGet, "/api/v1/admin/audit?actor=alice&limit=2&before="+audit.Next, "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	audit = AuditResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 1)
+	assert.Equal(t, storage.AuditCreate, audit.Entries[0].Action)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/audit?since=yesterday", "admin-key", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/audit?before=bogus", "admin-key", nil).Code)
+}
+
+func TestExpiryPolicy_Integration(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "expiry": "forever"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "expiry": "absolute"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+
+	rec, err := store.GetRecord(context.Background(), created.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, storage.ExpiryAbsolute, rec.Expiry)
+
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"expiry": "sliding"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+created.ShortKey, nil)
+	var link LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, storage.ExpirySliding, link.Expiry)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"expiry": "never"}).Code)
+}
+
+// logBuffer collects access log lines in memory
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) Close() error { return nil }
+
+func TestAccessLog_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	out := &logBuffer{}
+	logger := accesslog.New(out, accesslog.FormatCombined, 10)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAccessLog(logger)).SetupRoutes(router)
+
+	key := createTestURL(t, router, "https://example.com").ShortKey
+
+	req := httptest.NewRequest(http.MethodGet, "/"+key+"?ref=mail", nil)
+	req.Header.Set("Referer", "https://news.example.org/")
+	req.Header.Set("User-Agent", "TestAgent/1.0")
+	req.RemoteAddr = "203.0.113.7:4321"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing1", nil))
+	require.NoError(t, logger.Shutdown(context.Background()))
+
+	// API requests stay out of the access log
+	lines := strings.Split(strings.TrimSuffix(out.buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "203.0.113.7 - - ["), lines[0])
+	assert.Contains(t, lines[0], `"GET /`+key+`?ref=mail HTTP/1.1" 302 `)
+	assert.True(t, strings.HasSuffix(lines[0], `"https://news.example.org/" "TestAgent/1.0"`), lines[0])
+	assert.Contains(t, lines[1], `"GET /missing1 HTTP/1.1" 404 `)
+}
+
+func TestWellKnown_Integration(t *testing.T) {
+	router, _ := setupTestServer(t)
+
+	// Crawlers are kept away from short keys by default
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, DefaultRobotsTxt, w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, "public, max-age=86400", w.Header().Get("Cache-Control"))
+
+	// security.txt is only served once configured
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/security.txt", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithWellKnown(WellKnownConfig{
+		RobotsTxt: "User-agent: *\nDisallow:\n",
+		SecurityTxt: FormatSecurityTxt(SecurityTxtFields{
+			Contact:   ParseContacts("mailto:security@example.com, https://example.com/report"),
+			Expires:   expires,
+			Canonical: "http://localhost:8080/.well-known/security.txt",
+		}),
+	})).SetupRoutes(router)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/robots.txt", nil))
+	assert.Equal(t, "User-agent: *\nDisallow:\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/security.txt", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Contact: mailto:security@example.com\n"+
+		"Contact: https://example.com/report\n"+
+		"Expires: 2030-01-02T03:04:05Z\n"+
+		"Canonical: http://localhost:8080/.well-known/security.txt\n", w.Body.String())
+}
+
+func TestErrorPages_Integration(t *testing.T) {
+	const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	get := func(router *gin.Engine, path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router, _ := setupTestServer(t)
+
+	// API clients keep getting JSON
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		w := get(router, "/missing1", accept)
+		assert.Equal(t, http.StatusNotFound, w.Code, accept)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+	}
+
+	// while browsers get a page, for malformed keys too
+	for _, path := range []string{"/missing1", "/a"} {
+		w := get(router, path, browserAccept)
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html", path)
+		assert.Contains(t, w.Body.String(), "This link doesn't exist", path)
+	}
+
+	// Without a favicon browsers are told there is none
+	w := get(router, "/favicon.ico", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Pages and the favicon can be branded
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "404.html"), []byte("<p>{{.Brand}} has no {{.Key}}</p>"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "favicon.png"), []byte("png"), 0o644))
+	pages, err := LoadErrorPages(dir, "Acme")
+	require.NoError(t, err)
+	assert.Nil(t, pages.Gone)
+
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithErrorPages(pages)).SetupRoutes(router)
+
+	w = get(router, "/missing1", browserAccept)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "<p>Acme has no missing1</p>", w.Body.String())
+
+	w = get(router, "/favicon.ico", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "png", w.Body.String())
+
+	// The built-in inactive page carries the brand
+	key := createTestURL(t, router, "https://example.com").ShortKey
+	resp := sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls/"+key+"/disable", nil, nil)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	w = get(router, "/"+key, browserAccept)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "Link temporarily unavailable - Acme")
+}
+
+func TestContentNegotiation_Integration(t *testing.T) {
+	router, _ := setupTestServer(t)
+	key := createTestURL(t, router, "https://example.com/page").ShortKey
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// JSON stays the default
+	w := get("/api/v1/urls/"+key, "*/*")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	// curl asking for text gets just the short URL
+	w = get("/api/v1/urls/"+key, "text/plain")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "http://localhost:8080/"+key+"\n", w.Body.String())
+	assert.Empty(t, w.Header().Get("ETag"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"url":"https://example.com/other"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "http://localhost:8080/"), w.Body.String())
+
+	// and just the message for errors
+	w = get("/api/v1/urls/missing1", "text/plain")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "URL not found\n", w.Body.String())
+
+	// Browsers get a page
+	w = get("/api/v1/urls/"+key, "text/html,application/xhtml+xml,*/*;q=0.8")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `<a href="http://localhost:8080/`+key+`">`)
+	assert.Contains(t, w.Body.String(), "https://example.com/page")
+
+	w = get("/api/v1/urls/missing1", "text/html")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Not Found</h1>")
+	assert.Contains(t, w.Body.String(), "<p>URL not found</p>")
+}
+
+func TestAPIVersions_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	sunset := time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithV1Sunset(sunset)).SetupRoutes(router)
+
+	// v1 keeps working, marked as deprecated
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/v1"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, "@"+strconv.FormatInt(V1Deprecated.Unix(), 10), w.Header().Get("Deprecation"))
+	assert.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v2>; rel="successor-version"`, w.Header().Get("Link"))
+
+	// v2 serves Link resources
+	w = sendJSON(t, router, http.MethodPost, "/api/v2/links", map[string]interface{}{
+		"url":    "https://example.com/v2",
+		"expiry": "absolute",
+		"tags":   []string{"launch"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	var link Link
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, "http://localhost:8080/"+link.ShortKey, link.ShortURL)
+	assert.Equal(t, "https://example.com/v2", link.URL)
+	assert.Equal(t, []string{"launch"}, link.Tags)
+	assert.Equal(t, storage.ExpiryAbsolute, link.Expiry.Policy)
+	require.NotNil(t, link.Expiry.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(storage.DefaultTTL), *link.Expiry.ExpiresAt, time.Minute)
+
+	// Metadata is included once fetched
+	require.NoError(t, store.SavePreview(context.Background(), link.ShortKey, link.URL, &storage.Preview{Title: "Launch"}))
+	w = sendJSON(t, router, http.MethodGet, "/api/v2/links/"+link.ShortKey, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	require.NotNil(t, link.Metadata)
+	assert.Equal(t, "Launch", link.Metadata.Title)
+
+	w = sendJSON(t, router, http.MethodPatch, "/api/v2/links/"+link.ShortKey, map[string]interface{}{"label": "Spring launch"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, "Spring launch", link.Label)
+	assert.Equal(t, "http://localhost:8080/"+link.ShortKey, link.ShortURL)
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v2/links?tag=launch", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list LinkList
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Links, 1)
+	assert.Equal(t, link.ShortKey, list.Links[0].ShortKey)
+
+	w = sendJSON(t, router, http.MethodDelete, "/api/v2/links/"+link.ShortKey, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = sendJSON(t, router, http.MethodGet, "/api/v2/links/"+link.ShortKey, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteURL_Preconditions(t *testing.T) {
+	router, store := setupTestServer(t)
+	defer store.Close()
+	link := createTestURL(t, router, "https://example.com/first")
+	path := "/api/v1/urls/" + link.ShortKey
+
+	// A stale created_at doesn't delete the link now under the key
+	stale := link.CreatedAt.Add(-time.Second).Format(time.RFC3339Nano)
+	w := sendJSON(t, router, http.MethodDelete, path+"?created_at="+url.QueryEscape(stale), nil)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	_, err := store.GetRecord(context.Background(), link.ShortKey)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodDelete, path+"?created_at=yesterday", nil).Code)
+
+	current := link.CreatedAt.Format(time.RFC3339Nano)
+	w = sendJSON(t, router, http.MethodDelete, path+"?created_at="+url.QueryEscape(current), nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Idempotent deletes treat unknown keys as deleted
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithIdempotentDeletes()).SetupRoutes(router)
+	w = sendJSON(t, router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestChainResolution_Integration(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/landing", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	shortener := httptest.NewServer(mux)
+	defer shortener.Close()
+
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithChainResolution(chain.NewResolver([]string{"127.0.0.1"}, 2, 0))).SetupRoutes(router)
+
+	// The final destination is stored
+	link := createTestURL(t, router, shortener.URL+"/hop")
+	assert.Equal(t, "https://example.com/landing", link.URL)
+	dest, err := store.Get(context.Background(), link.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/landing", dest)
+
+	// Other destinations are left alone
+	assert.Equal(t, "https://example.com/direct", createTestURL(t, router, "https://example.com/direct").URL)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": shortener.URL + "/loop"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "loop")
+
+	// Updates are resolved too
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+link.ShortKey, map[string]interface{}{"url": shortener.URL + "/final"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	dest, err = store.Get(context.Background(), link.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/landing", dest)
+}
+
+func TestCloneURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store)).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":          "https://example.com/launch",
+		"key":          "launch",
+		"tags":         []string{"campaign"},
+		"query_params": map[string]string{"utm_source": "newsletter"},
+		"device_rules": []map[string]string{{"platform": "ios", "url": "https://apps.apple.com/app"}},
+		"expiry":       "absolute",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Without a body, the clone gets a generated key
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/launch/clone", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.NotEqual(t, "launch", created.ShortKey)
+
+	source, err := store.GetRecord(context.Background(), "launch")
+	require.NoError(t, err)
+	clone, err := store.GetRecord(context.Background(), created.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, source.URL, clone.URL)
+	assert.Equal(t, source.Tags, clone.Tags)
+	assert.Equal(t, source.QueryParams, clone.QueryParams)
+	assert.Equal(t, source.DeviceRules, clone.DeviceRules)
+	assert.Equal(t, storage.ExpiryAbsolute, clone.Expiry)
+
+	// Clones may take a custom key and move to another workspace
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/launch/clone", map[string]interface{}{
+		"key":         "launch",
+		"on_conflict": "suffix",
+		"workspace":   "marketing",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "launch-2", created.ShortKey)
+	clone, err = store.GetRecord(context.Background(), "launch-2")
+	require.NoError(t, err)
+	assert.Equal(t, "marketing", clone.Workspace)
+
+	assert.Equal(t, http.StatusConflict, sendJSON(t, router, http.MethodPost, "/api/v1/urls/launch/clone", map[string]interface{}{"key": "launch"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls/launch/clone", map[string]interface{}{"workspace": "not a workspace"}).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodPost, "/api/v1/urls/missing/clone", nil).Code)
+
+	// v2 answers with the clone's Link
+	w = sendJSON(t, router, http.MethodPost, "/api/v2/links/launch/clone", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link Link
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, []string{"campaign"}, link.Tags)
+	assert.Equal(t, storage.ExpiryAbsolute, link.Expiry.Policy)
+}
+
+func TestUsageMetering_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys:    map[string]string{"alice-key": "alice", "bob-key": "bob", "admin-key": "admin"},
+		Admins:     map[string]bool{"admin": true},
+		Workspaces: map[string]string{"alice": "marketing"},
+	})
+	meter := metering.NewMeter(store, nil, time.Minute)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager), WithUsageMetering(meter, store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+
+	w := send(http.MethodPost, "/api/v1/urls", "alice-key", map[string]interface{}{"url": "https://example.com/alice"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	require.Equal(t, http.StatusCreated, send(http.MethodPost, "/api/v1/urls", "bob-key", map[string]interface{}{"url": "https://example.com/bob"}).Code)
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+		require.Equal(t, http.StatusFound, w.Code)
+	}
+	require.NoError(t, meter.Flush(context.Background()))
+
+	today := storage.GranularityDay.Truncate(time.Now())
+	w = send(http.MethodGet, "/api/v1/admin/usage", "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response UsageResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, today.Format(time.DateOnly), response.To)
+	assert.Equal(t, []storage.Usage{
+		{Day: today, Owner: "bob", Metric: metering.LinksCreated, Quantity: 1},
+		{Day: today, Workspace: "marketing", Metric: metering.LinksCreated, Quantity: 1},
+		{Day: today, Workspace: "marketing", Metric: metering.Redirects, Quantity: 2},
+	}, response.Usage)
+
+	w = send(http.MethodGet, "/api/v1/admin/usage?workspace=marketing&metric=redirects&from="+today.Format(time.DateOnly), "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var filtered UsageResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&filtered))
+	assert.Equal(t, []storage.Usage{{Day: today, Workspace: "marketing", Metric: metering.Redirects, Quantity: 2}}, filtered.Usage)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/usage?from=yesterday", "admin-key", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/usage?from=2026-10-15&to=2026-10-01", "admin-key", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/api/v1/admin/usage?from=2024-01-01&to=2026-10-01", "admin-key", nil).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/admin/usage", "alice-key", nil).Code)
+}
+
+func TestReadOnlyMode_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager), WithReadOnly()).SetupRoutes(router)
+	admin := map[string]string{auth.APIKeyHeader: "admin-key"}
+
+	w := sendJSONWithHeaders(t, router, http.MethodGet, "/api/v1/admin/read-only", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled": true}`, w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls", admin, map[string]interface{}{"url": "https://example.com"}).Code)
+
+	require.Equal(t, http.StatusOK, sendJSONWithHeaders(t, router, http.MethodPut, "/api/v1/admin/read-only", admin, map[string]interface{}{"enabled": false}).Code)
+	w = sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls", admin, map[string]interface{}{"url": "https://example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	path := "/api/v1/urls/" + created.ShortKey
+
+	// Changes are rejected, while reads and redirects keep being served
+	require.Equal(t, http.StatusOK, sendJSONWithHeaders(t, router, http.MethodPut, "/api/v1/admin/read-only", admin, map[string]interface{}{"enabled": true}).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, sendJSONWithHeaders(t, router, http.MethodPatch, path, admin, map[string]interface{}{"url": "https://example.com/new"}).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, sendJSONWithHeaders(t, router, http.MethodDelete, path, admin, nil).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, sendJSONWithHeaders(t, router, http.MethodPost, "/api/v2/links", admin, map[string]interface{}{"url": "https://example.com"}).Code)
+	assert.Equal(t, http.StatusOK, sendJSONWithHeaders(t, router, http.MethodGet, path, admin, nil).Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortKey, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusBadRequest, sendJSONWithHeaders(t, router, http.MethodPut, "/api/v1/admin/read-only", admin, "on").Code)
+
+	// Without authentication nobody may turn the service off
+	open := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(open)
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, open, http.MethodPut, "/api/v1/admin/read-only", map[string]interface{}{"enabled": true}).Code)
+	assert.Equal(t, http.StatusOK, sendJSON(t, open, http.MethodGet, "/api/v1/admin/read-only", nil).Code)
+}
+
+func TestSharedReadOnlyMode_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	// Two instances behind a load balancer share the mode
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	first, second := gin.New(), gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager), WithSharedReadOnly(store)).SetupRoutes(first)
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithAuth(manager), WithSharedReadOnly(store)).SetupRoutes(second)
+	admin := map[string]string{auth.APIKeyHeader: "admin-key"}
+
+	require.Equal(t, http.StatusOK, sendJSONWithHeaders(t, first, http.MethodPut, "/api/v1/admin/read-only", admin, map[string]interface{}{"enabled": true}).Code)
+	w := sendJSONWithHeaders(t, second, http.MethodGet, "/api/v1/admin/read-only", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled": true}`, w.Body.String())
+	assert.Equal(t, http.StatusServiceUnavailable, sendJSONWithHeaders(t, second, http.MethodPost, "/api/v1/urls", admin, map[string]interface{}{"url": "https://example.com"}).Code)
+
+	require.Equal(t, http.StatusOK, sendJSONWithHeaders(t, second, http.MethodPut, "/api/v1/admin/read-only", admin, map[string]interface{}{"enabled": false}).Code)
+	assert.Equal(t, http.StatusCreated, sendJSONWithHeaders(t, first, http.MethodPost, "/api/v1/urls", admin, map[string]interface{}{"url": "https://example.com"}).Code)
+}
+
+func TestCircuitBreaker_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	require.NoError(t, store.FlushDB(context.Background()))
+	breaker := storage.NewBreakerStore(store, storage.BreakerOptions{Failures: 2, Cooldown: time.Minute, Retries: 1, Backoff: time.Millisecond})
+
+	router := gin.New()
+	NewHandler(breaker, id.NewGenerator(), "http://localhost:8080", WithCircuitBreaker(breaker)).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/up"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	require.Equal(t, http.StatusFound, sendJSON(t, router, http.MethodGet, "/"+created.ShortKey, nil).Code)
+
+	// With Redis gone, links read before keep redirecting
+	require.NoError(t, store.Close())
+	w = sendJSON(t, router, http.MethodGet, "/"+created.ShortKey, nil)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/up", w.Header().Get("Location"))
+
+	// Once the circuit opens, other requests fail fast
+	w = sendJSON(t, router, http.MethodGet, "/missing1", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+created.ShortKey, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+// stalledStore stalls reads of links until the request gives up
+type stalledStore struct {
+	*storage.RedisStore
+}
+
+func (s stalledStore) GetRecord(ctx context.Context, key string) (*storage.Record, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRouteTimeouts_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	require.NoError(t, store.Set(context.Background(), "stalled1", "https://example.com"))
+
+	router := gin.New()
+	timeouts := RouteTimeouts{Redirect: 20 * time.Millisecond, API: 50 * time.Millisecond}
+	NewHandler(stalledStore{store}, id.NewGenerator(), "http://localhost:8080", WithRouteTimeouts(timeouts)).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodGet, "/stalled1", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"error": "Request timed out"}`, w.Body.String())
+	assert.Equal(t, http.StatusGatewayTimeout, sendJSON(t, router, http.MethodGet, "/api/v1/urls/stalled1", nil).Code)
+
+	// Requests answering in time are unaffected
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestScanProtection_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	require.NoError(t, store.Set(context.Background(), "known123", "https://example.com"))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithScanProtection(store, ScanProtection{MaxMisses: 3})).SetupRoutes(router)
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodGet, fmt.Sprintf("/missing%d", i), nil).Code)
+	}
+
+	// Past the limit the client is turned away, even from known links
+	w := sendJSON(t, router, http.MethodGet, "/known123", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/admin/scanners", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed ScannersResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Len(t, listed.Scanners, 1)
+	assert.Equal(t, int64(4), listed.Scanners[0].Misses)
+	assert.True(t, listed.Scanners[0].BlockedUntil.After(time.Now()))
+
+	require.Equal(t, http.StatusNoContent, sendJSON(t, router, http.MethodDelete, "/api/v1/admin/scanners/"+listed.Scanners[0].IP, nil).Code)
+	assert.Equal(t, http.StatusFound, sendJSON(t, router, http.MethodGet, "/known123", nil).Code)
+}
+
+// stubVerifier accepts the token "solved"
+type stubVerifier struct {
+	err error
+}
+
+func (v stubVerifier) Verify(_ context.Context, token, _ string) error {
+	if v.err != nil {
+		return v.err
+	}
+	if token != "solved" {
+		return captcha.ErrRejected
+	}
+	return nil
+}
+
+func TestCaptcha_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithCaptcha(stubVerifier{})).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "captcha_token is required"}`, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "captcha_token": "forged"}).Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v2/links", map[string]interface{}{"url": "https://example.com", "captcha_token": "solved"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created Link
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "https://example.com", created.URL)
+
+	// A provider outage fails closed
+	failing := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithCaptcha(stubVerifier{err: errors.New("timeout")})).SetupRoutes(failing)
+	assert.Equal(t, http.StatusInternalServerError, sendJSON(t, failing, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "captcha_token": "solved"}).Code)
+}
+
+type stubSender struct {
+	sent chan mail.Message
+}
+
+func (s stubSender) Send(_ context.Context, m mail.Message) error {
+	s.sent <- m
+	return nil
+}
+
+func TestEmailVerification_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	sender := stubSender{sent: make(chan mail.Message, 1)}
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithEmailVerification(EmailVerification{
+		Sender: sender,
+		Secret: []byte("secret"),
+	})).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "email is required"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "email": "not an email"}).Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "email": "alice@example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.True(t, created.Unverified)
+
+	// The link is held until its creator follows the emailed link
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortKey, nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "awaiting confirmation")
+
+	var m mail.Message
+	select {
+	case m = <-sender.sent:
+	case <-time.After(time.Second):
+		t.Fatal("verification email not sent")
+	}
+	assert.Equal(t, "alice@example.com", m.To)
+	prefix := "http://localhost:8080/api/v1/urls/" + created.ShortKey + "/verify?token="
+	start := strings.Index(m.Text, prefix)
+	require.NotEqual(t, -1, start)
+	link := strings.Fields(m.Text[start:])[0]
+	token, err := url.QueryUnescape(strings.TrimPrefix(link, prefix))
+	require.NoError(t, err)
+
+	forged := "/api/v1/urls/" + created.ShortKey + "/verify?token=" + url.QueryEscape(token+"x")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, forged, nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(link, "http://localhost:8080"), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var verified URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&verified))
+	assert.False(t, verified.Unverified)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortKey, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	// Verifying again is harmless, and v2 verifies the same way
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/links/"+created.ShortKey+"/verify?token="+url.QueryEscape(token), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Anonymous callers would share deterministic links, so they can't use them
+	hashGenerator, err := id.NewHashGenerator("salt", id.DefaultHashKeyLength)
+	require.NoError(t, err)
+	deterministic := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithDeterministic(hashGenerator),
+		WithEmailVerification(EmailVerification{Sender: sender, Secret: []byte("secret")}),
+	).SetupRoutes(deterministic)
+	assert.Equal(t, http.StatusUnauthorized, sendJSON(t, deterministic, http.MethodPost, "/api/v1/urls/deterministic", map[string]interface{}{"url": "https://example.com"}).Code)
+}
+
+// notifyChannel collects the events sent through it
+type notifyChannel struct {
+	sent chan notify.Event
+}
+
+func (notifyChannel) Name() string { return "stub" }
+
+func (notifyChannel) Validate(recipient string) error {
+	if recipient == "" {
+		return errors.New("empty recipient")
+	}
+	return nil
+}
+
+func (c notifyChannel) Send(_ context.Context, _ string, e notify.Event) error {
+	c.sent <- e
+	return nil
+}
+
+func TestNotifications_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	ch := notifyChannel{sent: make(chan notify.Event, 1)}
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithNotifications(store, notify.New(store, "http://localhost:8080", ch)),
+	).SetupRoutes(router)
+	require.NoError(t, store.Create(ctx, "abc12345", &storage.Record{URL: "https://example.com", Workspace: "acme", CreatedAt: time.Now().UTC()}))
+
+	// Links use their workspace's settings until they have their own
+	w := sendJSON(t, router, http.MethodPut, "/api/v1/workspaces/acme/notifications", map[string]interface{}{
+		"channels": map[string][]string{"stub": {"team"}},
+		"events":   []string{"broken"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got NotificationsResponse
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/abc12345/notifications", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.True(t, got.Inherited)
+	assert.Equal(t, []string{"broken"}, got.Notifications.Events)
+
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/abc12345/notifications", map[string]interface{}{
+		"channels": map[string][]string{"fax": {"555"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/abc12345/notifications", map[string]interface{}{
+		"channels": map[string][]string{"stub": {"alice"}},
+		"events":   []string{"disabled"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	got = NotificationsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.False(t, got.Inherited)
+	assert.Equal(t, map[string][]string{"stub": {"alice"}}, got.Notifications.Channels)
+
+	// Disabling the link tells its owners
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPost, "/api/v1/urls/abc12345/disable", nil).Code)
+	select {
+	case e := <-ch.sent:
+		assert.Equal(t, notify.Disabled, e.Kind)
+		assert.Equal(t, "http://localhost:8080/abc12345", e.ShortURL)
+	case <-time.After(time.Second):
+		t.Fatal("disabling the link sent no notification")
+	}
+
+	// Clearing a link's settings returns it to the workspace's
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/urls/abc12345/notifications", map[string]interface{}{})
+	require.Equal(t, http.StatusOK, w.Code)
+	got = NotificationsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.True(t, got.Inherited)
+}
+
+func TestExtendURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithExpiry(store)).SetupRoutes(router)
+	require.NoError(t, store.Create(ctx, "abc12345", &storage.Record{URL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	require.NoError(t, store.Extend(ctx, "abc12345", time.Now().Add(time.Hour)))
+
+	// Without a body, links are extended by a full TTL
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls/abc12345/extend", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+	require.NotNil(t, got.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(store.TTL()), *got.ExpiresAt, 2*time.Second)
+
+	// Links can't be shortened, nor extended past a TTL from now
+	for _, until := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(2 * store.TTL()), time.Now().Add(-time.Minute)} {
+		w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/abc12345/extend", map[string]interface{}{"expires_at": until})
+		assert.Equal(t, http.StatusBadRequest, w.Code, until)
+	}
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v2/links/abc12345/extend", map[string]interface{}{"expires_at": time.Now().Add(store.TTL())})
+	require.Equal(t, http.StatusOK, w.Code)
+	var link Link
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	require.NotNil(t, link.Expiry.ExpiresAt)
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodPost, "/api/v1/urls/missing1/extend", nil).Code)
+}
+
+func TestRedirectRoutes_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	serve := func(routes RedirectRoutes) *gin.Engine {
+		router := gin.New()
+		NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithRedirectRoutes(routes)).SetupRoutes(router)
+		return router
+	}
+	create := func(router *gin.Engine, url string) URLResponse {
+		w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]string{"url": url})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var resp URLResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+	get := func(router *gin.Engine, host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Prefix", func(t *testing.T) {
+		router := serve(RedirectRoutes{Prefix: "/r", Legacy: true})
+		link := create(router, "https://example.com/prefixed")
+		assert.Equal(t, "http://localhost:8080/r/"+link.ShortKey, link.ShortURL)
+
+		w := get(router, "localhost:8080", "/r/"+link.ShortKey)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/prefixed", w.Header().Get("Location"))
+
+		// Links shared before the move redirect to their new path
+		w = get(router, "localhost:8080", "/"+link.ShortKey+"?utm_source=mail")
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "/r/"+link.ShortKey+"?utm_source=mail", w.Header().Get("Location"))
+
+		w = get(serve(RedirectRoutes{Prefix: "/r"}), "localhost:8080", "/"+link.ShortKey)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Host", func(t *testing.T) {
+		routes := RedirectRoutes{Host: "go.example.com", Legacy: true}
+		router := serve(routes)
+		link := create(router, "https://example.com/hosted")
+		assert.Equal(t, "http://go.example.com/"+link.ShortKey, link.ShortURL)
+
+		w := get(router, "go.example.com", "/"+link.ShortKey)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/hosted", w.Header().Get("Location"))
+
+		w = get(router, "localhost:8080", "/"+link.ShortKey)
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "http://go.example.com/"+link.ShortKey, w.Header().Get("Location"))
+
+		routes.Legacy = false
+		w = get(serve(routes), "localhost:8080", "/"+link.ShortKey)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestRenameURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store), WithRenames(store, time.Hour)).SetupRoutes(router)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/sale", "key": "summer-sale"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, http.StatusFound, get("/summer-sale").Code)
+	require.Equal(t, http.StatusCreated, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/taken", "key": "taken"}).Code)
+
+	assert.Equal(t, http.StatusConflict, sendJSON(t, router, http.MethodPost, "/api/v1/urls/summer-sale/rename", map[string]string{"key": "taken"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls/summer-sale/rename", map[string]string{"key": "summer-sale"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls/summer-sale/rename", map[string]string{"key": "api"}).Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/summer-sale/rename", map[string]string{"key": "autumn-sale"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var renamed URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&renamed))
+	assert.Equal(t, "autumn-sale", renamed.ShortKey)
+	assert.Equal(t, "http://localhost:8080/autumn-sale", renamed.ShortURL)
+
+	w = get("/autumn-sale")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/sale", w.Header().Get("Location"))
+
+	// The former key redirects to the new one, and so does it once the
+	// link is renamed again
+	w = get("/summer-sale?utm_source=mail")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/autumn-sale?utm_source=mail", w.Header().Get("Location"))
+
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPost, "/api/v1/urls/autumn-sale/rename", map[string]string{"key": "winter-sale"}).Code)
+	assert.Equal(t, "/winter-sale", get("/summer-sale").Header().Get("Location"))
+	assert.Equal(t, "/winter-sale", get("/autumn-sale").Header().Get("Location"))
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/winter-sale", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var details LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&details))
+	require.Len(t, details.Aliases, 2)
+	assert.Equal(t, "autumn-sale", details.Aliases[0].Key)
+	assert.Equal(t, "summer-sale", details.Aliases[1].Key)
+
+	// Renaming a link back to a former key ends that key's redirect
+	require.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPost, "/api/v1/urls/winter-sale/rename", map[string]string{"key": "summer-sale"}).Code)
+	assert.Equal(t, http.StatusFound, get("/summer-sale").Code)
+	assert.Equal(t, "/summer-sale", get("/winter-sale").Header().Get("Location"))
+
+	// Deleting the link ends the redirects of its former keys
+	require.Equal(t, http.StatusNoContent, sendJSON(t, router, http.MethodDelete, "/api/v1/urls/summer-sale", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get("/winter-sale").Code)
+	assert.Equal(t, http.StatusNotFound, get("/autumn-sale").Code)
+}
+
+func TestSignedLinks_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	// Signed links are refused until signing is enabled
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/reset", "signed": true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithLinkSigning([]byte("secret"))).SetupRoutes(router)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/reset", "signed": true})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	shortURL, err := url.Parse(link.ShortURL)
+	require.NoError(t, err)
+	sig := shortURL.Query().Get(SignatureParam)
+	require.NotEmpty(t, sig)
+
+	w = get("/" + link.ShortKey + "?" + SignatureParam + "=" + sig)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/reset", w.Header().Get("Location"))
+
+	assert.Equal(t, http.StatusNotFound, get("/"+link.ShortKey).Code)
+	assert.Equal(t, http.StatusNotFound, get("/"+link.ShortKey+"?"+SignatureParam+"=forged").Code)
+
+	// Another link's signature doesn't open it
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/other", "signed": true})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var other URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&other))
+	otherURL, err := url.Parse(other.ShortURL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, get("/"+link.ShortKey+"?"+SignatureParam+"="+otherURL.Query().Get(SignatureParam)).Code)
+
+	// Dropping the requirement opens the link to every request
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+link.ShortKey, map[string]interface{}{"signed": false})
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+	assert.Equal(t, "http://localhost:8080/"+link.ShortKey, updated.ShortURL)
+	assert.Equal(t, http.StatusFound, get("/"+link.ShortKey).Code)
+}
+
+func TestSingleUseLinks_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	// Single-use links are refused until they are enabled
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/invite", "single_use": true})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithSingleUseLinks(store)).SetupRoutes(router)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/invite", "single_use": true})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	// HEAD requests and link previews don't use the link up
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+	req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Of concurrent requests, exactly one is redirected
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+			codes[i] = w.Code
+			if w.Code == http.StatusFound {
+				assert.Equal(t, "https://example.com/invite", w.Header().Get("Location"))
+				assert.Contains(t, w.Header().Get("Cache-Control"), "max-age=0")
+			}
+		}(i)
+	}
+	wg.Wait()
+	redirected := 0
+	for _, code := range codes {
+		if code == http.StatusFound {
+			redirected++
+		} else {
+			assert.Equal(t, http.StatusNotFound, code)
+		}
+	}
+	assert.Equal(t, 1, redirected)
+
+	_, err := store.GetRecord(context.Background(), link.ShortKey)
+	assert.Equal(t, storage.ErrNotFound, err)
+}
+
+func TestLandingPages_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store), WithLandingPages(store), WithAuditLog(store)).SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/pages", map[string]interface{}{"key": "jane", "title": "Jane", "links": []interface{}{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/pages", map[string]interface{}{
+		"key":   "jane",
+		"title": "Jane Doe",
+		"links": []map[string]string{
+			{"title": "Portfolio", "url": "https://example.com/portfolio", "icon": "https://example.com/icon.png"},
+			{"title": "Book a shoot", "url": "https://example.com/book"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var page LandingPageResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Equal(t, "http://localhost:8080/jane", page.URL)
+
+	// The key serves the page, listing its buttons in order
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jane", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "<h1>Jane Doe</h1>")
+	assert.Contains(t, body, `<img src="https://example.com/icon.png"`)
+	assert.Less(t, strings.Index(body, "https://example.com/portfolio"), strings.Index(body, "https://example.com/book"))
+
+	// Links and pages share keys
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "jane"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "key": "john"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/pages", map[string]interface{}{
+		"key": "john", "title": "John", "links": []map[string]string{{"title": "Blog", "url": "https://example.com/blog"}},
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = sendJSON(t, router, http.MethodPut, "/api/v1/pages/jane", map[string]interface{}{
+		"title": "Jane", "links": []map[string]string{{"title": "Blog", "url": "https://example.com/blog"}},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jane", nil))
+	assert.NotContains(t, w.Body.String(), "https://example.com/portfolio")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/pages/jane", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jane", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Every change to the page is audited, newest first
+	entries, err := store.ListAudit(context.Background(), storage.AuditQuery{Key: "jane"})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, storage.AuditDeletePage, entries[0].Action)
+	assert.Equal(t, storage.AuditUpdatePage, entries[1].Action)
+	assert.Contains(t, string(entries[1].Before), "Jane Doe")
+	assert.Equal(t, storage.AuditCreatePage, entries[2].Action)
+}
+
+func TestInterstitial_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com/partner", "interstitial": map[string]interface{}{"seconds": 60},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com/partner", "interstitial": map[string]interface{}{"seconds": 5, "message": "You are leaving our site"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `content="5; url=https://example.com/partner"`)
+	assert.Contains(t, body, "You are leaving our site")
+
+	// Zero seconds removes the interstitial
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+link.ShortKey, map[string]interface{}{"interstitial": map[string]interface{}{"seconds": 0}})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestLocaleRules_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":          "https://example.com/en",
+		"locale_rules": []map[string]string{{"language": "en_US", "url": "https://example.com/us"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com/en",
+		"locale_rules": []map[string]string{
+			{"language": "pt-BR", "url": "https://example.com/br"},
+			{"language": "de", "url": "https://example.com/de"},
+		},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	for header, want := range map[string]string{
+		"pt-BR,pt;q=0.9":  "https://example.com/br",
+		"fr, de-AT;q=0.8": "https://example.com/de",
+		"fr":              "https://example.com/en",
+		"":                "https://example.com/en",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		req.Header.Set("Accept-Language", header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code, header)
+		assert.Equal(t, want, w.Header().Get("Location"), header)
+	}
+}
+
+func TestRules_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":   "https://example.com",
+		"rules": []map[string]interface{}{{"when": map[string]interface{}{"countries": []string{"DEU"}}, "url": "https://example.com/de"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com",
+		"rules": []map[string]interface{}{
+			{"name": "german ios", "when": map[string]interface{}{"countries": []string{"DE"}, "platforms": []string{"ios"}}, "url": "https://example.com/de-ios"},
+		},
+		"device_rules": []map[string]string{{"platform": "ios", "url": "https://example.com/ios"}},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	// Rules are evaluated ahead of device rules
+	for country, want := range map[string]string{"DE": "https://example.com/de-ios", "FR": "https://example.com/ios"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+		req.Header.Set("CF-IPCountry", country)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Header().Get("Location"), country)
+		assert.Equal(t, "private, max-age=0", w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestSimulateURL_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url": "https://example.com",
+		"rules": []map[string]interface{}{
+			{"name": "german", "when": map[string]interface{}{"countries": []string{"DE"}}, "url": "https://example.com/de"},
+		},
+		"device_rules": []map[string]string{{"platform": "android", "url": "https://example.com/android"}},
+		"query_params": map[string]string{"utm_source": "short"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	simulate := func(body map[string]interface{}) SimulateResponse {
+		w := sendJSON(t, router, http.MethodPost, "/api/v1/urls/"+link.ShortKey+"/simulate", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result SimulateResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return result
+	}
+
+	result := simulate(map[string]interface{}{"country": "DE", "device": "android"})
+	assert.True(t, result.Active)
+	assert.Equal(t, RouteRule, result.Route)
+	require.NotNil(t, result.Rule)
+	assert.Equal(t, 0, *result.Rule)
+	assert.Equal(t, "german", result.Name)
+	assert.Equal(t, "https://example.com/de?utm_source=short", result.URL)
+	assert.Equal(t, http.StatusFound, result.Status)
+
+	result = simulate(map[string]interface{}{"headers": map[string]string{"CF-IPCountry": "FR"}, "device": "android"})
+	assert.Equal(t, RouteDevice, result.Route)
+	assert.Equal(t, "https://example.com/android?utm_source=short", result.URL)
+
+	result = simulate(map[string]interface{}{"device": "desktop"})
+	assert.Equal(t, RouteDefault, result.Route)
+	assert.Nil(t, result.Rule)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls/"+link.ShortKey+"/simulate", map[string]interface{}{"device": "toaster"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDryRun_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store)).SetupRoutes(router)
+
+	// Dry runs answer with the would-be link without storing it
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "https://example.com/launch", "key": "launch"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, "launch", link.ShortKey)
+	assert.Equal(t, "http://localhost:8080/launch", link.ShortURL)
+	assert.Equal(t, "https://example.com/launch", link.URL)
+	_, err := store.GetRecord(context.Background(), "launch")
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	// Generated keys aren't picked
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "https://example.com/launch"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Empty(t, link.ShortKey)
+	assert.Empty(t, link.ShortURL)
+
+	// Validation fails as it would on creation
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "ftp://example.com"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "https://example.com", "key": "a"}).Code)
+
+	// Taken keys conflict, or resolve by the conflict policy
+	require.Equal(t, http.StatusCreated, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/launch", "key": "launch"}).Code)
+	assert.Equal(t, http.StatusConflict, sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "https://example.com/launch", "key": "launch"}).Code)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls?dry_run=true", map[string]interface{}{"url": "https://example.com/launch", "key": "launch", "on_conflict": "suffix"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+	assert.Equal(t, "launch-2", link.ShortKey)
+	_, err = store.GetRecord(context.Background(), "launch-2")
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	// v2 answers with the would-be Link
+	w = sendJSON(t, router, http.MethodPost, "/api/v2/links?dry_run=true", map[string]interface{}{"url": "https://example.com/docs", "key": "docs"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var v2 Link
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&v2))
+	assert.Equal(t, "docs", v2.ShortKey)
+	_, err = store.GetRecord(context.Background(), "docs")
+	assert.Equal(t, storage.ErrNotFound, err)
+}
+
+func TestKeyAvailability_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithVanityKeys(store), WithLandingPages(store)).SetupRoutes(router)
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, store.Create(context.Background(), "launch", &storage.Record{URL: "https://example.com/launch", ActiveUntil: &past}))
+	require.NoError(t, store.CreateLandingPage(context.Background(), &storage.LandingPage{Key: "links", Title: "Links", Links: []storage.LandingLink{{Title: "Home", URL: "https://example.com"}}}))
+
+	check := func(key string) KeyAvailabilityResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/keys/"+key+"/available", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var response KeyAvailabilityResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		return response
+	}
+
+	assert.Equal(t, KeyAvailabilityResponse{Key: "summer-sale", Available: true}, check("summer-sale"))
+	assert.Equal(t, KeyAvailabilityResponse{Key: "api", Reason: KeyReserved}, check("api"))
+	assert.Equal(t, KeyAvailabilityResponse{Key: "ab", Reason: KeyInvalid}, check("ab"))
+	assert.Equal(t, KeyAvailabilityResponse{Key: "launch", Reason: KeyTaken, Expired: true, Owned: true}, check("launch"))
+	assert.Equal(t, KeyAvailabilityResponse{Key: "links", Reason: KeyTaken, Owned: true}, check("links"))
+}
+
+func TestURLLengthLimit_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithLimits(Limits{MaxURLLength: 64})).SetupRoutes(router)
+	long := "https://example.com/" + strings.Repeat("a", 64)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": long}).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "fallbacks": []string{long}}).Code)
+
+	// Links stored under a higher limit can still be edited
+	require.NoError(t, store.Create(context.Background(), "legacy", &storage.Record{URL: long}))
+	assert.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/legacy", map[string]interface{}{"label": "Legacy"}).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/legacy", map[string]interface{}{"url": long + "b"}).Code)
+	assert.Equal(t, http.StatusOK, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/legacy", map[string]interface{}{"url": "https://example.com"}).Code)
+}
+
+func TestInternationalizedDestinations_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{
+		"url":       "https://bücher.example/café",
+		"fallbacks": []string{"https://BÜCHER.example/"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "https://xn--bcher-kva.example/caf%C3%A9", created.URL)
+	assert.Equal(t, "https://bücher.example/café", created.DisplayURL)
+
+	rec, err := store.GetRecord(context.Background(), created.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, "https://xn--bcher-kva.example/caf%C3%A9", rec.URL)
+	assert.Equal(t, []string{"https://xn--bcher-kva.example/"}, rec.Fallbacks)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortKey, nil))
+	assert.Equal(t, "https://xn--bcher-kva.example/caf%C3%A9", w.Header().Get("Location"))
+
+	// The same URL typed in either form is stored the same way
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"url": "https://xn--bcher-kva.example/caf%C3%A9"})
+	require.Equal(t, http.StatusOK, w.Code)
+	rec, err = store.GetRecord(context.Background(), created.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, "https://xn--bcher-kva.example/caf%C3%A9", rec.URL)
+
+	// ASCII URLs have no separate display form
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/a%20b"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "https://example.com/a%20b", created.URL)
+	assert.Empty(t, created.DisplayURL)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://exa\u200dmple.com/"}).Code)
+}
+
+func TestHTTPDestinations_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	// Refused wherever they appear
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithHTTPDestinations(HTTPReject, nil), WithImportExport(store, store)).SetupRoutes(router)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "http://example.com"}).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com", "fallbacks": []string{"http://example.org"}}).Code)
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+created.ShortKey, map[string]interface{}{"url": "http://example.com"}).Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/import", strings.NewReader(`{"key":"abcdefgh","url":"http://example.com"}`+"\n")))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"failed":1`)
+	assert.Contains(t, w.Body.String(), "plain http not allowed")
+
+	// Hosts not serving HTTPS keep their plain-HTTP destinations
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithHTTPDestinations(HTTPUpgrade, upgrade.NewChecker(time.Second))).SetupRoutes(router)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "http://127.0.0.1:1/page"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	assert.Equal(t, "http://127.0.0.1:1/page", created.URL)
+}
+
+func TestClickLimits_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	// Click limits are refused until they are enabled
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/webinar", "max_clicks": 3})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	router = gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithClickLimits(store)).SetupRoutes(router)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/webinar", "max_clicks": -1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]interface{}{"url": "https://example.com/webinar", "max_clicks": 3})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+
+	// HEAD requests and link-preview fetchers don't count
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+	req.Header.Set("User-Agent", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Of concurrent requests, exactly the limit are redirected
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+			codes[i] = w.Code
+			if w.Code == http.StatusFound {
+				assert.Contains(t, w.Header().Get("Cache-Control"), "max-age=0")
+			}
+		}(i)
+	}
+	wg.Wait()
+	redirected := 0
+	for _, code := range codes {
+		if code == http.StatusFound {
+			redirected++
+		} else {
+			assert.Equal(t, http.StatusGone, code)
+		}
+	}
+	assert.Equal(t, 3, redirected)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+
+	// Raising the limit lets the link redirect again
+	w = sendJSON(t, router, http.MethodPatch, "/api/v1/urls/"+link.ShortKey, map[string]interface{}{"max_clicks": 4})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for safely retrying a creation
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader marks responses replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is the default time responses are kept for replay
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// maxIdempotencyKeyLength bounds the accepted header value
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyLockMargin is how long past the API timeout a claimed
+	// Idempotency-Key stays locked while its request is handled
+	idempotencyLockMargin = 5 * time.Second
+)
+
+// WithIdempotency replays link creation responses for repeated
+// Idempotency-Key headers within ttl
+func WithIdempotency(store storage.IdempotencyStore, ttl time.Duration) Option {
+	return func(h *Handler) {
+		if ttl <= 0 {
+			ttl = DefaultIdempotencyTTL
+		}
+		h.idempotency = store
+		h.idempotencyTTL = ttl
+	}
+}
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent makes link creations safe to retry. The first request with an
+// Idempotency-Key is handled normally and its successful response stored;
+// repeats of it from the same client get the stored response instead of a
+// new link. Failed requests are not stored so they can be retried.
+func (h *Handler) idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || dryRun(c) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid Idempotency-Key"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			h.invalidBody(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		client := idempotencyClient(c)
+		fingerprint := requestFingerprint(c.Request, body)
+
+		previous, err := h.idempotency.BeginIdempotent(ctx, client, key, fingerprint, h.idempotencyLock())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		if previous != nil {
+			switch {
+			case previous.Fingerprint != fingerprint:
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case previous.Status == 0:
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(previous.Status, previous.ContentType, previous.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < 200 || status >= 300 {
+			err = h.idempotency.AbortIdempotent(ctx, client, key)
+		} else {
+			err = h.idempotency.CompleteIdempotent(ctx, client, key, &storage.IdempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: c.Writer.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, h.idempotencyTTL)
+		}
+		if err != nil {
+			log.Printf("failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// idempotencyLock is how long a claimed Idempotency-Key stays locked while
+// its request is handled. Requests that crash or time out free their key
+// for retries soon after the API timeout.
+func (h *Handler) idempotencyLock() time.Duration {
+	timeout := h.timeouts.API
+	if timeout <= 0 {
+		timeout = DefaultAPITimeout
+	}
+	return timeout + idempotencyLockMargin
+}
+
+// idempotencyClient identifies the caller an idempotency key belongs to:
+// the authenticated subject, or the client address for anonymous callers
+func idempotencyClient(c *gin.Context) string {
+	if principal := auth.PrincipalFrom(c); principal != nil {
+		return "subject:" + principal.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// requestFingerprint identifies a request by its route and body so a key
+// reused for a different request can be refused
+func requestFingerprint(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
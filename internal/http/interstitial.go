@@ -0,0 +1,77 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxInterstitialSeconds is the longest a link's interstitial may be shown
+const maxInterstitialSeconds = 30
+
+// interstitialError is the error returned for invalid interstitials
+const interstitialError = "Invalid interstitial. seconds must be 1 to 30 and message at most 2000 characters"
+
+// defaultInterstitialPage counts down before sending the visitor on to the
+// destination. The refresh works without JavaScript; the script only
+// updates the countdown.
+const defaultInterstitialPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <meta name="robots" content="noindex">
+  <meta http-equiv="refresh" content="{{.Seconds}}; url={{.URL}}">
+  <title>Redirecting{{with .Brand}} - {{.}}{{end}}</title>
+</head>
+<body>
+  {{- with .Message}}
+  <p>{{.}}</p>
+  {{- end}}
+  <p>You will be redirected in <span id="countdown">{{.Seconds}}</span> seconds.</p>
+  <p><a href="{{.URL}}">Continue now</a></p>
+  <script>
+    var left = {{.Seconds}};
+    var timer = setInterval(function () {
+      left--;
+      document.getElementById("countdown").textContent = Math.max(left, 0);
+      if (left <= 0) clearInterval(timer);
+    }, 1000);
+  </script>
+</body>
+</html>
+`
+
+var defaultInterstitialTemplate = template.Must(template.New("interstitial").Parse(defaultInterstitialPage))
+
+// InterstitialPageData is the data passed to the interstitial page template
+type InterstitialPageData struct {
+	Key     string
+	URL     string
+	Seconds int
+	Message string
+	Brand   string
+}
+
+// validInterstitial checks an interstitial supplied by the owner
+func validInterstitial(i *storage.Interstitial) bool {
+	return i == nil || (i.Seconds >= 1 && i.Seconds <= maxInterstitialSeconds && validNote(i.Message))
+}
+
+// renderInterstitial renders the page shown before redirecting to dest
+func (h *Handler) renderInterstitial(c *gin.Context, key, dest string, i *storage.Interstitial) {
+	tmpl := h.errorPages.Interstitial
+	if tmpl == nil {
+		tmpl = defaultInterstitialTemplate
+	}
+	renderHTML(c, http.StatusOK, tmpl, InterstitialPageData{
+		Key:     key,
+		URL:     dest,
+		Seconds: i.Seconds,
+		Message: i.Message,
+		Brand:   h.errorPages.Brand,
+	})
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/jobs"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// DefaultJobRunsLimit is the number of runs returned when no limit is given
+const DefaultJobRunsLimit = 20
+
+// JobsResponse lists the registered background jobs
+type JobsResponse struct {
+	Jobs []jobs.Info `json:"jobs"`
+}
+
+// JobRunsResponse lists a job's latest runs, newest first
+type JobRunsResponse struct {
+	Runs []storage.JobRun `json:"runs"`
+}
+
+// WithJobs lets admins list the background jobs, read their run history
+// and run them on demand
+func WithJobs(scheduler *jobs.Scheduler) Option {
+	return func(h *Handler) {
+		h.jobs = scheduler
+	}
+}
+
+// ListJobs returns every background job with its schedule and latest run
+func (h *Handler) ListJobs(c *gin.Context) {
+	infos, err := h.jobs.Jobs(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, JobsResponse{Jobs: infos})
+}
+
+// ListJobRuns returns a job's latest runs on any instance
+func (h *Handler) ListJobRuns(c *gin.Context) {
+	limit := DefaultJobRunsLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > storage.MaxJobRuns {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.jobs.Runs(c.Request.Context(), c.Param("job"), limit)
+	switch {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve job runs"})
+	default:
+		c.JSON(http.StatusOK, JobRunsResponse{Runs: runs})
+	}
+}
+
+// TriggerJob starts a run of a job on this instance. It answers once the
+// run has started; its outcome shows in the job's run history.
+func (h *Handler) TriggerJob(c *gin.Context) {
+	err := h.jobs.Trigger(c.Param("job"))
+	switch {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, jobs.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Jobs are shutting down"})
+	default:
+		c.Status(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,319 @@
+package http
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// MaxLandingLinks is the most buttons on a landing page
+const MaxLandingLinks = 50
+
+// landingPage lists a landing page's buttons in order
+const landingPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}{{with .Brand}} - {{.}}{{end}}</title>
+  <meta property="og:title" content="{{.Title}}">
+  {{- with .Description}}
+  <meta property="og:description" content="{{.}}">
+  <meta name="description" content="{{.}}">
+  {{- end}}
+  <style>
+    body { max-width: 32rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; text-align: center; }
+    a { display: flex; align-items: center; justify-content: center; gap: .5rem; margin: .75rem 0; padding: .9rem; border: 1px solid #ccc; border-radius: .5rem; color: inherit; text-decoration: none; }
+    a img { width: 1.5rem; height: 1.5rem; }
+  </style>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+  {{- with .Description}}
+  <p>{{.}}</p>
+  {{- end}}
+  <nav>
+    {{- range .Links}}
+    <a href="{{.URL}}" rel="noopener">{{with .Icon}}<img src="{{.}}" alt="">{{end}}<span>{{.Title}}</span></a>
+    {{- end}}
+  </nav>
+</body>
+</html>
+`
+
+var landingTemplate = template.Must(template.New("landing").Parse(landingPage))
+
+// LandingPageData is the data passed to the landing page template
+type LandingPageData struct {
+	Key         string
+	Title       string
+	Description string
+	Links       []storage.LandingLink
+	Brand       string
+}
+
+// LandingPageRequest represents the request body for creating or replacing
+// a landing page. Key is only read on creation.
+type LandingPageRequest struct {
+	Key         string                `json:"key"`
+	Title       string                `json:"title" binding:"required"`
+	Description string                `json:"description"`
+	Links       []storage.LandingLink `json:"links"`
+}
+
+// LandingPageResponse represents a landing page and the URL it is served at
+type LandingPageResponse struct {
+	*storage.LandingPage
+	URL string `json:"url"`
+}
+
+// WithLandingPages lets keys be served as landing pages listing several
+// destinations, managed under /api/v1/pages
+func WithLandingPages(pages storage.LandingPageStore) Option {
+	return func(h *Handler) {
+		h.landingPages = pages
+	}
+}
+
+// CreateLandingPage creates a landing page owned by the caller, under the
+// requested custom key or a generated one
+func (h *Handler) CreateLandingPage(c *gin.Context) {
+	var req LandingPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	now := time.Now().UTC()
+	page := &storage.LandingPage{
+		Owner:     owner(c),
+		Workspace: workspace(c),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !h.applyLandingPage(c, page, req) {
+		return
+	}
+
+	key := h.foldKey(req.Key)
+	var err error
+	if key != "" {
+		if h.vanity == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Custom keys are not enabled"})
+			return
+		}
+		if !validVanityKey(key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": invalidVanityKey})
+			return
+		}
+		page.Key = key
+		err = h.landingPages.CreateLandingPage(c.Request.Context(), page)
+		if err == storage.ErrKeyExists {
+			c.JSON(http.StatusConflict, gin.H{"error": "Key already exists"})
+			return
+		}
+	}
+	for attempts := 0; key == "" && attempts < 3; attempts++ {
+		if page.Key, err = h.generator.Generate(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+			return
+		}
+		if err = h.landingPages.CreateLandingPage(c.Request.Context(), page); err != storage.ErrKeyExists {
+			break
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store landing page"})
+		return
+	}
+	h.auditLandingPage(c, storage.AuditCreatePage, page.Key, nil, page)
+
+	c.JSON(http.StatusCreated, h.landingPageResponse(page))
+}
+
+// GetLandingPage returns a landing page
+func (h *Handler) GetLandingPage(c *gin.Context) {
+	if page := h.managedLandingPage(c); page != nil {
+		c.JSON(http.StatusOK, h.landingPageResponse(page))
+	}
+}
+
+// UpdateLandingPage replaces a landing page's title, description and
+// buttons
+func (h *Handler) UpdateLandingPage(c *gin.Context) {
+	page := h.managedLandingPage(c)
+	if page == nil {
+		return
+	}
+	var req LandingPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	before := h.pageSnapshot(page)
+	if !h.applyLandingPage(c, page, req) {
+		return
+	}
+	page.UpdatedAt = time.Now().UTC()
+
+	err := h.landingPages.UpdateLandingPage(c.Request.Context(), page)
+	if err == storage.ErrLandingPageNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Landing page not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store landing page"})
+		return
+	}
+	h.auditLandingPage(c, storage.AuditUpdatePage, page.Key, before, page)
+
+	c.JSON(http.StatusOK, h.landingPageResponse(page))
+}
+
+// DeleteLandingPage deletes a landing page, freeing its key
+func (h *Handler) DeleteLandingPage(c *gin.Context) {
+	page := h.managedLandingPage(c)
+	if page == nil {
+		return
+	}
+
+	err := h.landingPages.DeleteLandingPage(c.Request.Context(), page.Key)
+	if err == storage.ErrLandingPageNotFound {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete landing page"})
+		return
+	}
+	h.auditLandingPage(c, storage.AuditDeletePage, page.Key, h.pageSnapshot(page), nil)
+
+	c.Status(http.StatusNoContent)
+}
+
+// applyLandingPage validates a landing page request and copies it onto
+// page, answering the request itself if it is invalid
+func (h *Handler) applyLandingPage(c *gin.Context, page *storage.LandingPage, req LandingPageRequest) bool {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || !validLabel(req.Title) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid title. Must be 1 to 100 characters on one line"})
+		return false
+	}
+	if !validNote(req.Description) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid description. Must be at most 2000 characters"})
+		return false
+	}
+	if len(req.Links) == 0 || len(req.Links) > MaxLandingLinks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid links. A landing page lists 1 to 50 links"})
+		return false
+	}
+	for i := range req.Links {
+		link := &req.Links[i]
+		link.Title = strings.TrimSpace(link.Title)
+		if link.Title == "" || !validLabel(link.Title) || !validDestination(link.URL) || (link.Icon != "" && !validDestination(link.Icon)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid links. Each needs a title of 1 to 100 characters and an absolute http(s) URL, and may have an http(s) icon URL"})
+			return false
+		}
+		link.URL = canonicalDestination(link.URL)
+		if link.Icon != "" {
+			link.Icon = canonicalDestination(link.Icon)
+		}
+		if !h.secureDestinations(c, []*string{&link.URL, &link.Icon}) || !h.withinURLLength(c, link.URL, link.Icon) {
+			return false
+		}
+	}
+
+	page.Title = req.Title
+	page.Description = req.Description
+	page.Links = req.Links
+	return true
+}
+
+// pageSnapshot serializes a landing page for the audit log, or returns nil
+// if changes aren't audited
+func (h *Handler) pageSnapshot(page *storage.LandingPage) json.RawMessage {
+	if h.auditLog == nil || page == nil {
+		return nil
+	}
+	data, err := json.Marshal(page)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// auditLandingPage records a change to a landing page made by the caller
+func (h *Handler) auditLandingPage(c *gin.Context, action storage.AuditAction, key string, before json.RawMessage, after *storage.LandingPage) {
+	if h.auditLog == nil {
+		return
+	}
+	entry := h.auditEntry(c, action, key, before, nil)
+	entry.After = h.pageSnapshot(after)
+	h.appendAudit(c, entry)
+}
+
+// landingPageResponse describes a landing page with the URL it is served at
+func (h *Handler) landingPageResponse(page *storage.LandingPage) LandingPageResponse {
+	return LandingPageResponse{LandingPage: page, URL: h.shortURL(page.Key, "")}
+}
+
+// managedLandingPage loads a landing page and checks the caller may manage
+// it. It writes the error response and returns nil on failure.
+func (h *Handler) managedLandingPage(c *gin.Context) *storage.LandingPage {
+	page, err := h.landingPages.GetLandingPage(c.Request.Context(), h.foldKey(c.Param("page")))
+	if err == storage.ErrLandingPageNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Landing page not found"})
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve landing page"})
+		return nil
+	}
+
+	if !h.ownedBy(c, page.Owner, page.Workspace) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the landing page owner or an admin may do this"})
+		return nil
+	}
+	return page
+}
+
+// landingPageTaken reports whether a landing page holds key, answering the
+// request itself if that can't be checked
+func (h *Handler) landingPageTaken(c *gin.Context, key string) (taken, ok bool) {
+	if h.landingPages == nil {
+		return false, true
+	}
+	_, err := h.landingPages.GetLandingPage(c.Request.Context(), key)
+	if err != nil && err != storage.ErrLandingPageNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check key"})
+		return false, false
+	}
+	return err == nil, true
+}
+
+// serveLandingPage renders the landing page held by a key that holds no
+// link. It reports false if there is none.
+func (h *Handler) serveLandingPage(c *gin.Context, key string) bool {
+	if h.landingPages == nil {
+		return false
+	}
+	page, err := h.landingPages.VisitLandingPage(c.Request.Context(), key)
+	if err != nil {
+		return false
+	}
+	// Pages change in place, so caches must check back before reusing them
+	c.Header("Cache-Control", "no-cache")
+	renderHTML(c, http.StatusOK, landingTemplate, LandingPageData{
+		Key:         page.Key,
+		Title:       page.Title,
+		Description: page.Description,
+		Links:       page.Links,
+		Brand:       h.errorPages.Brand,
+	})
+	return true
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/rules"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultMaxURLLength is the longest destination URL accepted by
+	// default, in bytes
+	DefaultMaxURLLength = 2048
+
+	// DefaultMaxBodyBytes is the largest API request body accepted by
+	// default
+	DefaultMaxBodyBytes = 1 << 20
+
+	// maxTagLength is the longest tag tagPattern accepts
+	maxTagLength = 32
+)
+
+// streamedRoutes stream their request bodies, which may be far larger
+// than any other, so they aren't limited
+var streamedRoutes = map[string]bool{
+	"/api/v1/admin/import":  true,
+	"/api/v1/admin/restore": true,
+}
+
+// Limits bounds what API callers may send. Zero fields take their defaults.
+type Limits struct {
+	// MaxURLLength is the longest destination URL, in bytes
+	MaxURLLength int
+
+	// MaxBodyBytes is the largest request body. Imports and restores
+	// stream their bodies and aren't limited.
+	MaxBodyBytes int64
+}
+
+// LimitsResponse lists the limits on link fields, so clients can check
+// input before sending it
+type LimitsResponse struct {
+	MaxURLLength     int   `json:"max_url_length"`
+	MaxBodyBytes     int64 `json:"max_body_bytes"`
+	MinKeyLength     int   `json:"min_key_length"`
+	MaxKeyLength     int   `json:"max_key_length"`
+	MaxTags          int   `json:"max_tags"`
+	MaxTagLength     int   `json:"max_tag_length"`
+	MaxLabelLength   int   `json:"max_label_length"`
+	MaxNoteLength    int   `json:"max_note_length"`
+	MaxQueryParams   int   `json:"max_query_params"`
+	MaxFallbacks     int   `json:"max_fallbacks"`
+	MaxVariants      int   `json:"max_variants"`
+	MaxScheduleItems int   `json:"max_schedule_entries"`
+	MaxRules         int   `json:"max_rules"`
+	MaxDeviceRules   int   `json:"max_device_rules"`
+	MaxLocaleRules   int   `json:"max_locale_rules"`
+	MaxLandingLinks  int   `json:"max_landing_links"`
+}
+
+// WithLimits replaces the default limits on destination URLs and request
+// bodies
+func WithLimits(limits Limits) Option {
+	return func(h *Handler) {
+		if limits.MaxURLLength <= 0 {
+			limits.MaxURLLength = DefaultMaxURLLength
+		}
+		if limits.MaxBodyBytes <= 0 {
+			limits.MaxBodyBytes = DefaultMaxBodyBytes
+		}
+		h.limits = limits
+	}
+}
+
+// GetLimits returns the limits on link fields
+func (h *Handler) GetLimits(c *gin.Context) {
+	c.JSON(http.StatusOK, LimitsResponse{
+		MaxURLLength:     h.limits.MaxURLLength,
+		MaxBodyBytes:     h.limits.MaxBodyBytes,
+		MinKeyLength:     minVanityKeyLength,
+		MaxKeyLength:     maxVanityKeyLength,
+		MaxTags:          maxTags,
+		MaxTagLength:     maxTagLength,
+		MaxLabelLength:   maxLabelLength,
+		MaxNoteLength:    maxNoteLength,
+		MaxQueryParams:   maxQueryParams,
+		MaxFallbacks:     maxFallbacks,
+		MaxVariants:      maxVariants,
+		MaxScheduleItems: maxScheduleEntries,
+		MaxRules:         rules.MaxRules,
+		MaxDeviceRules:   maxDeviceRules,
+		MaxLocaleRules:   maxLocaleRules,
+		MaxLandingLinks:  MaxLandingLinks,
+	})
+}
+
+// limitBody refuses request bodies over the limit with 413. Bodies
+// declaring their length are refused up front; others are cut off at the
+// limit, failing to decode.
+func (h *Handler) limitBody(c *gin.Context) {
+	if streamedRoutes[c.FullPath()] {
+		return
+	}
+	if c.Request.ContentLength > h.limits.MaxBodyBytes {
+		h.bodyTooLarge(c)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxBodyBytes)
+}
+
+// bodyTooLarge answers a request whose body is over the limit with 413
+func (h *Handler) bodyTooLarge(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large. Must be at most " + strconv.FormatInt(h.limits.MaxBodyBytes, 10) + " bytes"})
+}
+
+// invalidBody answers a request whose body couldn't be read or decoded:
+// with 413 if limitBody cut it off, otherwise with 400
+func (h *Handler) invalidBody(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.bodyTooLarge(c)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+}
+
+// withinURLLength checks destination URLs against the length limit,
+// answering 422 if any is too long
+func (h *Handler) withinURLLength(c *gin.Context, urls ...string) bool {
+	for _, u := range urls {
+		if len(u) > h.limits.MaxURLLength {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "URL too long. Must be at most " + strconv.Itoa(h.limits.MaxURLLength) + " characters"})
+			return false
+		}
+	}
+	return true
+}
+
+// updatedURLs lists the destinations an update sets. Only those are
+// checked, so links stored before a lower limit can still be edited.
+func updatedURLs(req UpdateURLRequest) []string {
+	return recordURLs(updateDestinations(req))
+}
+
+// recordURLs lists every destination a link may send visitors to
+func recordURLs(rec *storage.Record) []string {
+	var urls []string
+	for _, dest := range destinationFields(rec) {
+		urls = append(urls, *dest)
+	}
+	return urls
+}
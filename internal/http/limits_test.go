@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+)
+
+func TestLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewHandler(nil, id.NewGenerator(), "http://localhost:8080", WithLimits(Limits{MaxBodyBytes: 1024})).SetupRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/limits", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var limits LimitsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&limits))
+	assert.Equal(t, DefaultMaxURLLength, limits.MaxURLLength)
+	assert.Equal(t, int64(1024), limits.MaxBodyBytes)
+	assert.Equal(t, maxTags, limits.MaxTags)
+	assert.Equal(t, maxNoteLength, limits.MaxNoteLength)
+
+	// Key lengths are those custom keys are checked against
+	assert.True(t, validVanityKey(strings.Repeat("a", limits.MinKeyLength)))
+	assert.False(t, validVanityKey(strings.Repeat("a", limits.MinKeyLength-1)))
+	assert.True(t, validVanityKey(strings.Repeat("a", limits.MaxKeyLength)))
+	assert.False(t, validVanityKey(strings.Repeat("a", limits.MaxKeyLength+1)))
+
+	// Bodies over the limit are refused before reaching the handler
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]string{"url": "https://example.com/?q=" + strings.Repeat("a", 2048)})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Bodies not declaring their length are cut off at the limit
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"url":"https://example.com/?q=`+strings.Repeat("a", 2048)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestRecordURLLength(t *testing.T) {
+	h := NewHandler(nil, id.NewGenerator(), "http://localhost:8080", WithLimits(Limits{MaxURLLength: 30}))
+	long := "https://example.com/" + strings.Repeat("a", 20)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	assert.True(t, h.withinURLLength(c, "https://example.com/"))
+
+	w := httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	assert.False(t, h.withinURLLength(c, updatedURLs(UpdateURLRequest{Fallbacks: &[]string{"https://example.com", long}})...))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
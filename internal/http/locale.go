@@ -0,0 +1,37 @@
+package http
+
+import (
+	"github.com/prayushdave/url-shortener/internal/rules"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxLocaleRules is the maximum number of locale routing rules per link
+const maxLocaleRules = 20
+
+// localeRule returns the index of the rule matching the most preferred
+// language of an Accept-Language header. A rule for "en" matches
+// "en-US" too, while a rule for "en-US" only matches that. Among rules
+// matching the same language the first one wins.
+func localeRule(acceptLanguage string, localeRules []storage.LocaleRule) (int, bool) {
+	for _, lang := range rules.PreferredLanguages(acceptLanguage) {
+		for i, rule := range localeRules {
+			if rules.LanguageMatches(lang, rule.Language) {
+				return i, true
+			}
+		}
+	}
+	return -1, false
+}
+
+// validLocaleRules checks locale routing rules supplied by the owner
+func validLocaleRules(localeRules []storage.LocaleRule) bool {
+	if len(localeRules) > maxLocaleRules {
+		return false
+	}
+	for _, rule := range localeRules {
+		if !rules.ValidLanguage(rule.Language) || !validDestination(rule.URL) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+func TestLocaleRule(t *testing.T) {
+	rules := []storage.LocaleRule{
+		{Language: "pt-BR", URL: "https://example.com/br"},
+		{Language: "pt", URL: "https://example.com/pt"},
+		{Language: "de", URL: "https://example.com/de"},
+	}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"pt-BR,pt;q=0.9", "https://example.com/br"},
+		{"pt-PT", "https://example.com/pt"},
+		{"DE-at", "https://example.com/de"},
+		{"en-US, de;q=0.5", "https://example.com/de"},
+		{"deu", ""},
+		{"en-US", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		i, ok := localeRule(tt.header, rules)
+		if assert.Equal(t, tt.want != "", ok, tt.header) && ok {
+			assert.Equal(t, tt.want, rules[i].URL, tt.header)
+		}
+	}
+}
+
+func TestValidLocaleRules(t *testing.T) {
+	assert.True(t, validLocaleRules(nil))
+	assert.True(t, validLocaleRules([]storage.LocaleRule{{Language: "zh-Hant-TW", URL: "https://example.com/tw"}}))
+	assert.False(t, validLocaleRules([]storage.LocaleRule{{Language: "en_US", URL: "https://example.com"}}))
+	assert.False(t, validLocaleRules([]storage.LocaleRule{{Language: "en", URL: "ftp://example.com"}}))
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxLabelLength and maxNoteLength bound a link's label and note, in characters
+	maxLabelLength = 100
+	maxNoteLength  = 2000
+)
+
+// validLabel checks that a label fits on one line of a listing
+func validLabel(label string) bool {
+	if utf8.RuneCountInString(label) > maxLabelLength || !utf8.ValidString(label) {
+		return false
+	}
+	for _, r := range label {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// validNote checks a note's length; unlike labels, notes may span lines
+func validNote(note string) bool {
+	return utf8.ValidString(note) && utf8.RuneCountInString(note) <= maxNoteLength
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// NotificationsResponse represents the notification settings in effect for
+// a link. Inherited is set when they are its workspace's.
+type NotificationsResponse struct {
+	ShortKey      string                 `json:"short_key"`
+	Notifications *storage.Notifications `json:"notifications"`
+	Inherited     bool                   `json:"inherited"`
+}
+
+// WorkspaceNotificationsResponse represents the notification settings of
+// the links of a workspace
+type WorkspaceNotificationsResponse struct {
+	Workspace     string                 `json:"workspace"`
+	Notifications *storage.Notifications `json:"notifications"`
+}
+
+// WithNotifications lets owners configure notifications of their links'
+// events, per link or per workspace, delivered by notifier
+func WithNotifications(store storage.NotificationStore, notifier *notify.Notifier) Option {
+	return func(h *Handler) {
+		h.notifications = store
+		h.notifier = notifier
+	}
+}
+
+// notify announces an event of a link in the background, when
+// notifications are enabled
+func (h *Handler) notify(kind notify.Kind, key string, rec *storage.Record, detail string) {
+	if h.notifier == nil {
+		return
+	}
+	h.notifier.Dispatch(h.notifier.Event(kind, key, rec, detail), rec)
+}
+
+// bindNotifications reads notification settings from the request body,
+// answering the request itself if they are invalid. Settings without
+// channels clear them, returning nil.
+func (h *Handler) bindNotifications(c *gin.Context) (*storage.Notifications, bool) {
+	var req storage.Notifications
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return nil, false
+	}
+	if len(req.Channels) == 0 {
+		return nil, true
+	}
+	if err := h.notifier.Validate(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notifications: " + err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+// GetNotifications returns the notification settings in effect for a link
+func (h *Handler) GetNotifications(c *gin.Context) {
+	if key, rec := h.managedRecord(c); rec != nil {
+		h.writeNotifications(c, key, rec)
+	}
+}
+
+// writeNotifications answers with the notification settings in effect for
+// a link
+func (h *Handler) writeNotifications(c *gin.Context, key string, rec *storage.Record) {
+	settings, err := h.notifier.Settings(c.Request.Context(), rec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, NotificationsResponse{
+		ShortKey:      key,
+		Notifications: settings,
+		Inherited:     rec.Notifications == nil && settings != nil,
+	})
+}
+
+// SetNotifications replaces a link's own notification settings. Clearing
+// them makes the link use its workspace's again.
+func (h *Handler) SetNotifications(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+	settings, ok := h.bindNotifications(c)
+	if !ok {
+		return
+	}
+
+	before := h.snapshot(rec)
+	rec.Notifications = settings
+	err := h.store.Update(c.Request.Context(), key, rec)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return
+	}
+	h.audit(c, storage.AuditUpdate, key, before, rec)
+
+	h.writeNotifications(c, key, rec)
+}
+
+// GetWorkspaceNotifications returns the notification settings of the links
+// of a workspace
+func (h *Handler) GetWorkspaceNotifications(c *gin.Context) {
+	name, ok := h.workspaceName(c)
+	if !ok {
+		return
+	}
+
+	settings, err := h.notifications.GetWorkspaceNotifications(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, WorkspaceNotificationsResponse{Workspace: name, Notifications: settings})
+}
+
+// SetWorkspaceNotifications replaces the notification settings of the
+// links of a workspace, which apply to those without settings of their own
+func (h *Handler) SetWorkspaceNotifications(c *gin.Context) {
+	name, ok := h.workspaceName(c)
+	if !ok {
+		return
+	}
+	settings, ok := h.bindNotifications(c)
+	if !ok {
+		return
+	}
+
+	if err := h.notifications.SetWorkspaceNotifications(c.Request.Context(), name, settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, WorkspaceNotificationsResponse{Workspace: name, Notifications: settings})
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultInactivePage is shown for links resolved outside their activation
+// window, while disabled or before their creator verified their email
+const defaultInactivePage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{if .Disabled}}Link temporarily unavailable{{else if .Unverified}}Link awaiting confirmation{{else if .NotYetActive}}Link not yet active{{else}}Link no longer active{{end}}{{with .Brand}} - {{.}}{{end}}</title>
+</head>
+<body>
+  {{if .Disabled}}
+  <h1>This link is temporarily unavailable</h1>
+  <p>Please try again later.</p>
+  {{else if .Unverified}}
+  <h1>This link is awaiting confirmation by its creator</h1>
+  <p>It will work once they confirm their email address.</p>
+  {{else if .NotYetActive}}
+  <h1>This link is not active yet</h1>
+  {{if .ActiveFrom}}<p>Check back after {{.ActiveFrom.Format "Jan 2, 2006 15:04 MST"}}.</p>{{end}}
+  {{else}}
+  <h1>This link is no longer active</h1>
+  {{end}}
+</body>
+</html>
+`
+
+// InactivePageData is the data passed to the inactive link page template
+type InactivePageData struct {
+	Key          string
+	Disabled     bool
+	Unverified   bool
+	NotYetActive bool
+	ActiveFrom   *time.Time
+	ActiveUntil  *time.Time
+	Brand        string
+}
+
+// defaultNotFoundPage is shown to browsers resolving unknown keys
+const defaultNotFoundPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Link not found{{with .Brand}} - {{.}}{{end}}</title>
+</head>
+<body>
+  <h1>This link doesn't exist</h1>
+  <p>Check that it was typed correctly, or ask whoever shared it for a new one.</p>
+</body>
+</html>
+`
+
+// ErrorPageData is the data passed to the not found page template
+type ErrorPageData struct {
+	Key   string
+	Brand string
+}
+
+// ErrorPages brands the pages browsers see for links that can't be
+// followed. Nil templates keep the built-in pages.
+type ErrorPages struct {
+	// Brand is passed to every page template as .Brand
+	Brand string
+
+	// NotFound is rendered for unknown keys
+	NotFound *template.Template
+
+	// Gone is rendered for inactive links, like WithInactivePage
+	Gone *template.Template
+
+	// Interstitial is rendered before redirecting links with an
+	// interstitial
+	Interstitial *template.Template
+
+	// Favicon is served at /favicon.ico with FaviconType
+	Favicon     []byte
+	FaviconType string
+}
+
+// Files looked up by LoadErrorPages
+const (
+	notFoundPageFile = "404.html"
+	gonePageFile     = "410.html"
+	interstitialFile = "interstitial.html"
+)
+
+// faviconFiles are the favicon files looked up by LoadErrorPages, in order,
+// with their content types
+var faviconFiles = []struct{ name, contentType string }{
+	{"favicon.ico", "image/x-icon"},
+	{"favicon.png", "image/png"},
+	{"favicon.svg", "image/svg+xml"},
+}
+
+// WithErrorPages sets the pages rendered for unknown and inactive links and
+// the favicon
+func WithErrorPages(pages ErrorPages) Option {
+	return func(h *Handler) {
+		h.errorPages = pages
+		if pages.Gone != nil {
+			h.inactivePage = pages.Gone
+		}
+	}
+}
+
+// LoadErrorPages loads page templates and a favicon from dir. Files missing
+// from dir keep the built-in pages.
+func LoadErrorPages(dir, brand string) (ErrorPages, error) {
+	pages := ErrorPages{Brand: brand}
+
+	var err error
+	if pages.NotFound, err = parseOptionalTemplate(filepath.Join(dir, notFoundPageFile)); err != nil {
+		return ErrorPages{}, err
+	}
+	if pages.Gone, err = parseOptionalTemplate(filepath.Join(dir, gonePageFile)); err != nil {
+		return ErrorPages{}, err
+	}
+	if pages.Interstitial, err = parseOptionalTemplate(filepath.Join(dir, interstitialFile)); err != nil {
+		return ErrorPages{}, err
+	}
+
+	for _, favicon := range faviconFiles {
+		data, err := os.ReadFile(filepath.Join(dir, favicon.name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return ErrorPages{}, err
+		}
+		pages.Favicon = data
+		pages.FaviconType = favicon.contentType
+		break
+	}
+	return pages, nil
+}
+
+// parseOptionalTemplate parses a template file, returning nil if it doesn't
+// exist
+func parseOptionalTemplate(path string) (*template.Template, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return template.ParseFiles(path)
+}
+
+// WithInactivePage sets the template rendered for links outside their
+// activation window or disabled by their owner
+func WithInactivePage(tmpl *template.Template) Option {
+	return func(h *Handler) {
+		h.inactivePage = tmpl
+	}
+}
+
+// renderHTML renders a page template with the given status code
+func renderHTML(c *gin.Context, status int, tmpl *template.Template, data interface{}) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(c.Writer, data); err != nil {
+		c.Error(err)
+	}
+}
+
+var (
+	defaultInactiveTemplate = template.Must(template.New("inactive").Parse(defaultInactivePage))
+	defaultNotFoundTemplate = template.Must(template.New("not_found").Parse(defaultNotFoundPage))
+)
+
+// wantsHTML reports whether the client prefers HTML over JSON, as browsers
+// navigating to a link do
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// notFound answers a request for an unknown key: with the not found page
+// for browsers, and with a JSON error for API clients
+func (h *Handler) notFound(c *gin.Context, key, message string) {
+	if !wantsHTML(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": message})
+		return
+	}
+	tmpl := h.errorPages.NotFound
+	if tmpl == nil {
+		tmpl = defaultNotFoundTemplate
+	}
+	renderHTML(c, http.StatusNotFound, tmpl, ErrorPageData{Key: key, Brand: h.errorPages.Brand})
+}
+
+// Favicon serves the configured favicon. Without one it answers 204, so
+// browsers don't look it up as a short key.
+func (h *Handler) Favicon(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	if len(h.errorPages.Favicon) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.Data(http.StatusOK, h.errorPages.FaviconType, h.errorPages.Favicon)
+}
+
+// renderInactive renders the inactive link page for a record outside its
+// window, disabled or unverified
+func (h *Handler) renderInactive(c *gin.Context, data InactivePageData) {
+	tmpl := h.inactivePage
+	if tmpl == nil {
+		tmpl = defaultInactiveTemplate
+	}
+	data.Brand = h.errorPages.Brand
+
+	status := http.StatusGone
+	switch {
+	case data.Disabled:
+		// A paused link may come back, so it must not be cached as gone
+		status = http.StatusServiceUnavailable
+		c.Header("Cache-Control", "no-store")
+	case data.Unverified:
+		// Verification activates the link, so it must not be cached
+		status = http.StatusForbidden
+		c.Header("Cache-Control", "no-store")
+	case data.NotYetActive:
+		status = http.StatusForbidden
+	}
+	renderHTML(c, status, tmpl, data)
+}
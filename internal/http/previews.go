@@ -0,0 +1,43 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+// WithMetadata fetches the destination's title and description in the
+// background when links are created or their URL changes
+func WithMetadata(prefetcher *metadata.Prefetcher) Option {
+	return func(h *Handler) {
+		h.metadata = prefetcher
+	}
+}
+
+// WithPreviews fetches destination metadata like WithMetadata and also
+// serves it to social network preview bots instead of a redirect
+func WithPreviews(prefetcher *metadata.Prefetcher) Option {
+	return func(h *Handler) {
+		h.metadata = prefetcher
+		h.socialPreviews = true
+	}
+}
+
+// prefetchPreview queues a fetch of the destination's preview metadata
+func (h *Handler) prefetchPreview(key string, rec *storage.Record) {
+	if h.metadata == nil {
+		return
+	}
+	h.metadata.Enqueue(key, rec.URL)
+}
+
+// wantsPreview reports whether the request should get the preview page
+// instead of a redirect
+func (h *Handler) wantsPreview(c *gin.Context, rec *storage.Record) bool {
+	if h.isBot(c) && h.botMode == BotPreview {
+		return true
+	}
+	return h.socialPreviews && rec.Preview != nil && useragent.IsSocialPreview(c.Request.UserAgent())
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// Erasure modes for recorded visits
+const (
+	// EraseAnonymize strips IPs and user agents but keeps the visits
+	EraseAnonymize = "anonymize"
+
+	// ErasePurge removes the visits entirely
+	ErasePurge = "purge"
+)
+
+// PersonalDataExport holds everything stored about an owner's links
+type PersonalDataExport struct {
+	Owner      string         `json:"owner"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Links      []PersonalLink `json:"links"`
+}
+
+// PersonalLink is an exported link with its statistics and recorded visits
+type PersonalLink struct {
+	ShortKey string          `json:"short_key"`
+	Link     *storage.Record `json:"link"`
+	Stats    *storage.Stats  `json:"stats,omitempty"`
+	Visits   []storage.Visit `json:"visits,omitempty"`
+}
+
+// ErasureResponse reports the visits erased for an owner
+type ErasureResponse struct {
+	Owner  string `json:"owner"`
+	Mode   string `json:"mode"`
+	Links  int    `json:"links"`
+	Visits int    `json:"visits"`
+}
+
+// WithPrivacy lets owners export the data stored about their links and
+// erase the visitor details recorded for them. Visits, if not nil, are
+// recorded with the visitor's IP, anonymized by the click recorder, and
+// user agent.
+func WithPrivacy(links storage.ExportStore, visits storage.VisitStore) Option {
+	return func(h *Handler) {
+		h.privacyLinks = links
+		h.visits = visits
+	}
+}
+
+// ExportPersonalData returns every link of an owner with its statistics
+// and recorded visits. Callers export their own data; admins may name
+// another owner.
+func (h *Handler) ExportPersonalData(c *gin.Context) {
+	owner, ok := h.dataOwner(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	links, err := h.ownedLinks(ctx, owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URLs"})
+		return
+	}
+
+	export := PersonalDataExport{Owner: owner, ExportedAt: time.Now().UTC(), Links: []PersonalLink{}}
+	for _, l := range links {
+		link := PersonalLink{ShortKey: l.Key, Link: l.Record}
+		if h.stats != nil {
+			if link.Stats, err = h.stats.GetStats(ctx, l.Key); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+				return
+			}
+		}
+		if h.visits != nil {
+			if link.Visits, err = h.visits.GetVisits(ctx, l.Key); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve visits"})
+				return
+			}
+		}
+		export.Links = append(export.Links, link)
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// EraseVisits anonymizes or purges the visits recorded for an owner's
+// links. Click counts and aggregates hold no visitor details and are kept.
+func (h *Handler) EraseVisits(c *gin.Context) {
+	mode := c.DefaultQuery("mode", EraseAnonymize)
+	if mode != EraseAnonymize && mode != ErasePurge {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode. Must be anonymize or purge"})
+		return
+	}
+	owner, ok := h.dataOwner(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	links, err := h.ownedLinks(ctx, owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URLs"})
+		return
+	}
+
+	response := ErasureResponse{Owner: owner, Mode: mode, Links: len(links)}
+	for _, l := range links {
+		if h.visits == nil {
+			break
+		}
+		var n int
+		if mode == ErasePurge {
+			n, err = h.visits.DeleteVisits(ctx, l.Key)
+		} else {
+			n, err = h.visits.AnonymizeVisits(ctx, l.Key)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase visits"})
+			return
+		}
+		response.Visits += n
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// dataOwner returns the owner whose data a privacy request is about: the
+// caller, or for admins the owner they name. Without authentication
+// configured the owner must be named.
+func (h *Handler) dataOwner(c *gin.Context) (string, bool) {
+	requested := c.Query("owner")
+	if h.auth == nil {
+		if requested == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Owner is required"})
+			return "", false
+		}
+		return requested, true
+	}
+
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return "", false
+	}
+	if requested == "" || requested == principal.Subject {
+		return principal.Subject, true
+	}
+	if !principal.Admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only an admin may act on another owner's data"})
+		return "", false
+	}
+	return requested, true
+}
+
+// ownedLinks returns every link created by an owner
+func (h *Handler) ownedLinks(ctx context.Context, owner string) ([]storage.SearchResult, error) {
+	var links []storage.SearchResult
+	err := h.privacyLinks.Walk(ctx, func(r storage.SearchResult) error {
+		if r.Record.Owner == owner {
+			links = append(links, r)
+		}
+		return nil
+	})
+	return links, err
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/url"
+
+	"github.com/prayushdave/url-shortener/internal/purge"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// WithPurge purges short links from the edge cache when they are updated or deleted
+func WithPurge(p *purge.Purger) Option {
+	return func(h *Handler) {
+		h.purger = p
+	}
+}
+
+// purgeLink schedules a purge of every URL a link is served under, so edges
+// stop serving cached redirects to its old destination
+func (h *Handler) purgeLink(key string, rec *storage.Record) {
+	if h.purger == nil {
+		return
+	}
+	h.purger.Enqueue(h.shortURLs(key, rec)...)
+}
+
+// shortURLs returns the URLs a link is served under: the base URL and, for
+// links under a custom domain, that domain with the base URL's scheme. Signed
+// links are also served with their signature.
+func (h *Handler) shortURLs(key string, rec *storage.Record) []string {
+	urls := []string{h.shortURL(key, "")}
+	if rec.Domain != "" {
+		urls = append(urls, h.shortURL(key, rec.Domain))
+	}
+	if rec.Signed && h.signingSecret != nil {
+		sig := "?" + SignatureParam + "=" + url.QueryEscape(h.linkSignature(key))
+		for _, u := range urls {
+			urls = append(urls, u+sig)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// Quota headers reported on link creation requests
+const (
+	HeaderDailyLimit      = "X-Quota-Daily-Limit"
+	HeaderDailyRemaining  = "X-Quota-Daily-Remaining"
+	HeaderDailyReset      = "X-Quota-Daily-Reset"
+	HeaderActiveLimit     = "X-Quota-Active-Limit"
+	HeaderActiveRemaining = "X-Quota-Active-Remaining"
+)
+
+// anonymousQuotaPrefix marks the scope anonymous creations are charged to,
+// keeping client addresses apart from subjects
+const anonymousQuotaPrefix = "anonymous:"
+
+// QuotaLimits are the creation limits applied to each workspace, or to each
+// authenticated subject outside a workspace. Zero disables a limit.
+type QuotaLimits struct {
+	// Daily is the number of links that may be created per UTC day. It
+	// also applies to each anonymous client address.
+	Daily int64
+
+	// Active is the number of links that may exist at once. It is a soft
+	// limit: concurrent creations may each pass the check before any of
+	// their links is counted, overshooting it by as many. Anonymous links
+	// belong to no one, so they are not counted.
+	Active int64
+}
+
+// WithQuotas enforces creation quotas
+func WithQuotas(store storage.QuotaStore, limits QuotaLimits) Option {
+	return func(h *Handler) {
+		h.quotas = store
+		h.quotaLimits = limits
+	}
+}
+
+// enforceQuotas rejects link creations over the caller's quotas. Creations
+// that do not go through are returned to the daily quota. Anonymous callers
+// are charged the daily quota by client address; admins are not limited.
+func (h *Handler) enforceQuotas() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := auth.PrincipalFrom(c)
+		if principal != nil && principal.Admin {
+			c.Next()
+			return
+		}
+		limits := h.quotaLimits
+		var scope storage.Scope
+		switch {
+		case principal == nil:
+			scope.Owner = anonymousQuotaPrefix + c.ClientIP()
+			limits.Active = 0
+		case principal.Workspace != "":
+			scope.Workspace = principal.Workspace
+		default:
+			scope.Owner = principal.Subject
+		}
+		ctx := c.Request.Context()
+
+		if limits.Active > 0 {
+			active, err := h.quotas.ActiveLinks(ctx, scope, limits.Active)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+				return
+			}
+			// Remaining counts the link this request creates
+			c.Header(HeaderActiveLimit, strconv.FormatInt(limits.Active, 10))
+			c.Header(HeaderActiveRemaining, strconv.FormatInt(max(limits.Active-active-1, 0), 10))
+			if active >= limits.Active {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Active link quota exceeded"})
+				return
+			}
+		}
+
+		if limits.Daily <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now().UTC()
+		reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		used, err := h.quotas.ReserveDaily(ctx, scope, now, limits.Daily)
+		if err != nil && err != storage.ErrQuotaExceeded {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+			return
+		}
+		c.Header(HeaderDailyLimit, strconv.FormatInt(limits.Daily, 10))
+		c.Header(HeaderDailyRemaining, strconv.FormatInt(limits.Daily-used, 10))
+		c.Header(HeaderDailyReset, strconv.FormatInt(reset.Unix(), 10))
+		if err == storage.ErrQuotaExceeded {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Daily link quota exceeded"})
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() != http.StatusCreated {
+			if err := h.quotas.ReleaseDaily(ctx, scope, now); err != nil {
+				log.Printf("failed to release quota for %+v: %v", scope, err)
+			}
+		}
+	}
+}
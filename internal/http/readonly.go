@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// ReadOnlyState reports whether changes to links are rejected
+type ReadOnlyState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// WithReadOnly starts the handler in read-only mode, rejecting changes to
+// links until an admin turns it off
+func WithReadOnly() Option {
+	return func(h *Handler) {
+		h.readOnly.Store(true)
+	}
+}
+
+// WithSharedReadOnly keeps read-only mode in the store, so turning it on or
+// off applies to every instance rather than the one asked
+func WithSharedReadOnly(store storage.ReadOnlyStore) Option {
+	return func(h *Handler) {
+		h.sharedReadOnly = store
+	}
+}
+
+// isReadOnly reports whether read-only mode is on. While the shared state
+// can't be read, the state last read is assumed.
+func (h *Handler) isReadOnly(ctx context.Context) bool {
+	if h.sharedReadOnly == nil {
+		return h.readOnly.Load()
+	}
+	enabled, err := h.sharedReadOnly.ReadOnly(ctx)
+	if err != nil {
+		log.Printf("failed to read read-only mode: %v", err)
+		return h.readOnly.Load()
+	}
+	h.readOnly.Store(enabled)
+	return enabled
+}
+
+// rejectWhileReadOnly answers changes to links with 503 Service Unavailable
+// while read-only mode is on. Redirects and reads keep being served.
+func (h *Handler) rejectWhileReadOnly(c *gin.Context) {
+	if h.isReadOnly(c.Request.Context()) {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Service is read-only for maintenance. Try again later"})
+		return
+	}
+	c.Next()
+}
+
+// GetReadOnly returns whether read-only mode is on
+func (h *Handler) GetReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, ReadOnlyState{Enabled: h.isReadOnly(c.Request.Context())})
+}
+
+// SetReadOnly turns read-only mode on or off for this instance, or for
+// every instance when it is shared
+func (h *Handler) SetReadOnly(c *gin.Context) {
+	var req ReadOnlyState
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	if h.sharedReadOnly != nil {
+		if err := h.sharedReadOnly.SetReadOnly(c.Request.Context(), req.Enabled); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set read-only mode"})
+			return
+		}
+	}
+	h.readOnly.Store(req.Enabled)
+	c.JSON(http.StatusOK, req)
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedirectRoutes configures where short links are served. By default they
+// are served at /:key on every host, where keys compete with the server's
+// other top-level paths.
+type RedirectRoutes struct {
+	// Prefix serves links under a path, such as /r for /r/:key
+	Prefix string
+
+	// Host serves links on a host of their own, with the base URL's scheme.
+	// Requests for the base URL's host aren't redirected; custom domains
+	// keep serving their links.
+	Host string
+
+	// Legacy keeps links shared before they moved working, permanently
+	// redirecting /:key on the base URL's host to where the key is served
+	Legacy bool
+}
+
+// redirectPrefixPattern accepts path prefixes of one or more segments
+var redirectPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// WithRedirectRoutes moves short links under a path prefix or onto a host
+// of their own
+func WithRedirectRoutes(routes RedirectRoutes) Option {
+	return func(h *Handler) {
+		h.redirects = routes
+	}
+}
+
+// ParseRedirectPrefix parses the path prefix short links are served under.
+// An empty prefix serves them at the root.
+func ParseRedirectPrefix(spec string) (string, error) {
+	prefix := strings.TrimSuffix(strings.TrimSpace(spec), "/")
+	if prefix == "" {
+		return "", nil
+	}
+	if !redirectPrefixPattern.MatchString(prefix) {
+		return "", fmt.Errorf("invalid redirect prefix %q", spec)
+	}
+	first, _, _ := strings.Cut(prefix[1:], "/")
+	if reservedKeys[strings.ToLower(first)] {
+		return "", fmt.Errorf("redirect prefix %q collides with a top-level route", spec)
+	}
+	return prefix, nil
+}
+
+// BaseURL returns the URL short links are served under, given the server's
+// base URL
+func (r RedirectRoutes) BaseURL(baseURL string) string {
+	base := strings.TrimSuffix(baseURL, "/")
+	if r.Host != "" {
+		scheme := "https"
+		if u, err := url.Parse(baseURL); err == nil && u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + r.Prefix
+}
+
+// onBaseHost reports whether a request is for the base URL's host
+func (h *Handler) onBaseHost(r *http.Request) bool {
+	u, err := url.Parse(h.baseURL)
+	if err != nil {
+		return false
+	}
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.EqualFold(host, u.Hostname())
+}
+
+// requireRedirectHost keeps links off the base URL's host when they are
+// served on a host of their own
+func (h *Handler) requireRedirectHost(c *gin.Context) {
+	if !h.onBaseHost(c.Request) {
+		c.Next()
+		return
+	}
+	if h.redirects.Legacy {
+		h.legacyRedirect(c)
+	} else {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	}
+	c.Abort()
+}
+
+// legacyRedirect permanently redirects a link requested where it was served
+// before it moved, keeping its query string
+func (h *Handler) legacyRedirect(c *gin.Context) {
+	target := h.redirects.Prefix + "/" + url.PathEscape(c.Param("key"))
+	if h.redirects.Host != "" && h.onBaseHost(c.Request) {
+		target = h.redirects.BaseURL(h.baseURL) + "/" + url.PathEscape(c.Param("key"))
+	}
+	if query := c.Request.URL.RawQuery; query != "" {
+		target += "?" + query
+	}
+	c.Redirect(http.StatusMovedPermanently, target)
+}
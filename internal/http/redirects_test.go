@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+)
+
+func TestParseRedirectPrefix(t *testing.T) {
+	prefix, err := ParseRedirectPrefix(" /r/ ")
+	require.NoError(t, err)
+	assert.Equal(t, "/r", prefix)
+
+	prefix, err = ParseRedirectPrefix("/go/links")
+	require.NoError(t, err)
+	assert.Equal(t, "/go/links", prefix)
+
+	prefix, err = ParseRedirectPrefix("")
+	require.NoError(t, err)
+	assert.Empty(t, prefix)
+
+	for _, spec := range []string{"r", "/r/:key", "/r//x", "/api", "/App/links"} {
+		_, err := ParseRedirectPrefix(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRedirectRoutes_BaseURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8080", RedirectRoutes{}.BaseURL("http://localhost:8080/"))
+	assert.Equal(t, "http://localhost:8080/r", RedirectRoutes{Prefix: "/r"}.BaseURL("http://localhost:8080"))
+	assert.Equal(t, "https://go.example.com/r", RedirectRoutes{Prefix: "/r", Host: "go.example.com"}.BaseURL("https://example.com"))
+}
+
+func TestSetupRoutes_Split(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(nil, id.NewGenerator(), "http://localhost:8080")
+	redirects, api := gin.New(), gin.New()
+	h.SetupRedirectRoutes(redirects)
+	h.SetupAPIRoutes(api)
+
+	status := func(router *gin.Engine, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, status(redirects, "/robots.txt"))
+	assert.Equal(t, http.StatusNotFound, status(redirects, "/api/v1/admin/read-only"))
+	assert.Equal(t, http.StatusOK, status(api, "/api/v1/admin/read-only"))
+	assert.Equal(t, http.StatusNotFound, status(api, "/robots.txt"))
+	assert.Equal(t, http.StatusNotFound, status(api, "/abc12345"))
+}
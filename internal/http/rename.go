@@ -0,0 +1,128 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// RenameRequest represents a request to move a link to a new custom key
+type RenameRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// WithRenames lets editors move links to new custom keys, with the former
+// key redirecting to the new one for grace
+func WithRenames(store storage.AliasStore, grace time.Duration) Option {
+	return func(h *Handler) {
+		if grace <= 0 {
+			grace = storage.DefaultAliasGrace
+		}
+		h.aliases = store
+		h.aliasGrace = grace
+	}
+}
+
+// RenameURL moves a link to a new custom key, answering with the link
+func (h *Handler) RenameURL(c *gin.Context) {
+	if key, rec, ok := h.renameLink(c); ok {
+		c.JSON(http.StatusOK, h.urlResponse(c, key, rec))
+	}
+}
+
+// RenameLink moves a link to a new custom key, answering with its Link
+func (h *Handler) RenameLink(c *gin.Context) {
+	key, rec, ok := h.renameLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusOK, h.link(details, rec))
+}
+
+// renameLink moves the requested link to the custom key in the body,
+// answering the request itself if it can't. Statistics move along with
+// the link, and its former key answers 301 Moved Permanently until the
+// grace period is over.
+func (h *Handler) renameLink(c *gin.Context) (string, *storage.Record, bool) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return "", nil, false
+	}
+	var req RenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return "", nil, false
+	}
+	newKey := h.foldKey(req.Key)
+	if !validVanityKey(newKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalidVanityKey})
+		return "", nil, false
+	}
+	if newKey == key {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link already has this key"})
+		return "", nil, false
+	}
+
+	if taken, ok := h.landingPageTaken(c, newKey); !ok {
+		return "", nil, false
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Key already exists"})
+		return "", nil, false
+	}
+
+	before := h.snapshot(rec)
+	renamed, err := h.aliases.Rename(c.Request.Context(), key, newKey, h.aliasGrace)
+	switch err {
+	case nil:
+	case storage.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	case storage.ErrKeyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Key already exists"})
+		return "", nil, false
+	case storage.ErrLinkChanged:
+		c.JSON(http.StatusConflict, gin.H{"error": "Link changed while it was renamed. Try again"})
+		return "", nil, false
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename URL"})
+		return "", nil, false
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditRename, newKey, before, renamed)
+	return newKey, renamed, true
+}
+
+// aliasRedirect permanently redirects a renamed link's former key to its
+// new key, keeping the query string. It reports false if key isn't a
+// former key in its grace period.
+func (h *Handler) aliasRedirect(c *gin.Context, key string) bool {
+	if h.aliases == nil {
+		return false
+	}
+	newKey, err := h.aliases.ResolveAlias(c.Request.Context(), key)
+	if err != nil {
+		return false
+	}
+	target := h.redirects.Prefix + "/" + url.PathEscape(newKey)
+	query := c.Request.URL.RawQuery
+	if h.validLinkSignature(c, key) {
+		// Signatures are of keys, so a signed link's signature is swapped
+		// for that of its new key
+		q := c.Request.URL.Query()
+		q.Set(SignatureParam, h.linkSignature(newKey))
+		query = q.Encode()
+	}
+	if query != "" {
+		target += "?" + query
+	}
+	// The former key may go to another link once the grace period is over
+	c.Header("Cache-Control", "private, max-age=0")
+	c.Redirect(http.StatusMovedPermanently, target)
+	return true
+}
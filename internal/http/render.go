@@ -0,0 +1,171 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiPage renders API responses for browsers
+const apiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+</head>
+<body>
+  <h1>{{.Title}}</h1>
+  {{if .Error}}
+  <p>{{.Error}}</p>
+  {{else}}
+  {{with .ShortURL}}<p><a href="{{.}}">{{.}}</a></p>{{end}}
+  <pre>{{.Body}}</pre>
+  {{end}}
+</body>
+</html>
+`
+
+var apiPageTemplate = template.Must(template.New("api").Parse(apiPage))
+
+// apiPageData is the data passed to the API page template
+type apiPageData struct {
+	Title    string
+	Error    string
+	ShortURL string
+	Body     string
+}
+
+// negotiatedWriter holds back JSON responses so they can be rendered in the
+// format the client asked for. Other responses, like streams and exports,
+// are passed through.
+type negotiatedWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+func (w *negotiatedWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.buffering = mediaType == gin.MIMEJSON
+	}
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *negotiatedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// negotiate renders JSON responses as HTML for browsers and as plain text
+// for clients asking for it. JSON stays the default, including for clients
+// accepting anything.
+func (h *Handler) negotiate(c *gin.Context) {
+	c.Writer.Header().Add("Vary", "Accept")
+	format := c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML, gin.MIMEPlain)
+	if format != gin.MIMEHTML && format != gin.MIMEPlain {
+		c.Next()
+		return
+	}
+
+	original := c.Writer
+	w := &negotiatedWriter{ResponseWriter: original}
+	c.Writer = w
+	c.Next()
+	c.Writer = original
+	if !w.buffering {
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(w.body.Bytes(), &body); err != nil {
+		original.Write(w.body.Bytes())
+		return
+	}
+
+	// The ETag identifies the JSON representation
+	original.Header().Del("ETag")
+	original.Header().Del("Content-Type")
+	status := original.Status()
+	if format == gin.MIMEHTML {
+		renderHTML(c, status, apiPageTemplate, h.apiPageData(status, body))
+		return
+	}
+	c.Data(status, "text/plain; charset=utf-8", []byte(h.plainText(body)+"\n"))
+}
+
+// apiPageData describes a decoded API response for the API page
+func (h *Handler) apiPageData(status int, body interface{}) apiPageData {
+	data := apiPageData{
+		Title:    http.StatusText(status),
+		Error:    errorMessage(body),
+		ShortURL: h.responseShortURL(body),
+	}
+	if indented, err := json.MarshalIndent(body, "", "  "); err == nil {
+		data.Body = string(indented)
+	}
+	return data
+}
+
+// plainText renders a decoded API response as text: just the short URL for
+// responses about a link, just the message for errors, and indented JSON
+// for everything else
+func (h *Handler) plainText(body interface{}) string {
+	if shortURL := h.responseShortURL(body); shortURL != "" {
+		return shortURL
+	}
+	if message := errorMessage(body); message != "" {
+		return message
+	}
+	indented, _ := json.MarshalIndent(body, "", "  ")
+	return string(indented)
+}
+
+// responseShortURL returns the short URL of a response about a single link
+func (h *Handler) responseShortURL(body interface{}) string {
+	fields, ok := body.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if shortURL, ok := fields["short_url"].(string); ok {
+		return shortURL
+	}
+	key, ok := fields["short_key"].(string)
+	if !ok || key == "" {
+		return ""
+	}
+	domain, _ := fields["domain"].(string)
+	return h.shortURL(key, domain)
+}
+
+// shortURL returns the URL a key is served under, on a custom domain with
+// the base URL's scheme if one is given
+func (h *Handler) shortURL(key, domain string) string {
+	if domain == "" {
+		return h.redirects.BaseURL(h.baseURL) + "/" + key
+	}
+	scheme := "https"
+	if u, err := url.Parse(h.baseURL); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return scheme + "://" + domain + h.redirects.Prefix + "/" + key
+}
+
+// errorMessage returns the message of an error response
+func errorMessage(body interface{}) string {
+	fields, ok := body.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	message, _ := fields["error"].(string)
+	return message
+}
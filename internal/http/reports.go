@@ -0,0 +1,179 @@
+package http
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// Data exported by the statistics exports
+const (
+	// ReportRollups exports clicks aggregated per hour, day or week
+	ReportRollups = "rollups"
+
+	// ReportClicks exports every recorded visit
+	ReportClicks = "clicks"
+)
+
+var (
+	// rollupColumns is the header of rollup exports
+	rollupColumns = []string{"start", "clicks", "countries", "referrers", "channels", "devices", "os", "browsers"}
+
+	// clickColumns is the header of click exports
+	clickColumns = []string{"time", "country", "referrer", "variant", "user_agent", "ip"}
+)
+
+// ExportStats streams the statistics of a link as CSV: its rollups over the
+// requested range and interval, or with data=clicks every recorded visit
+func (h *Handler) ExportStats(c *gin.Context) {
+	if !csvReport(c) {
+		return
+	}
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	switch c.DefaultQuery("data", ReportRollups) {
+	case ReportRollups:
+		q, ok := h.rollupRange(c, string(storage.GranularityDay))
+		if !ok {
+			return
+		}
+		rollups := h.rollupsFor(c, key, q)
+		if rollups == nil {
+			return
+		}
+		w := startCSV(c, key+"-"+string(q.interval)+".csv", rollupColumns)
+		for _, b := range rollups.Buckets {
+			w.Write(rollupRow(b))
+		}
+		finishCSV(c, w)
+
+	case ReportClicks:
+		if h.visits == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visits are not recorded"})
+			return
+		}
+		visits, err := h.visits.GetVisits(c.Request.Context(), key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+			return
+		}
+		w := startCSV(c, key+"-clicks.csv", clickColumns)
+		for _, v := range visits {
+			w.Write([]string{v.Time.UTC().Format(time.RFC3339), v.Country, v.Referrer, v.Variant, v.UserAgent, v.IP})
+		}
+		finishCSV(c, w)
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data. Must be rollups or clicks"})
+	}
+}
+
+// ExportCampaignStats streams the rollups of every link in a campaign over
+// the requested range and interval as CSV, one row per link and bucket
+func (h *Handler) ExportCampaignStats(c *gin.Context) {
+	if !csvReport(c) {
+		return
+	}
+	campaign := h.managedCampaign(c)
+	if campaign == nil {
+		return
+	}
+	q, ok := h.rollupRange(c, string(storage.GranularityDay))
+	if !ok {
+		return
+	}
+	keys, err := h.campaigns.CampaignLinks(c.Request.Context(), campaign.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve campaign"})
+		return
+	}
+
+	// Links are read one at a time and flushed as they go, so large
+	// campaigns stream instead of being held in memory. A failure once
+	// rows are sent can only cut the export short.
+	w := startCSV(c, "campaign-"+campaign.ID+"-"+string(q.interval)+".csv", append([]string{"key"}, rollupColumns...))
+	for _, key := range keys {
+		rollups, err := h.readRollups(c.Request.Context(), key, q)
+		if err != nil {
+			log.Printf("campaign export of %s stopped at %s: %v", campaign.ID, key, err)
+			break
+		}
+		for _, b := range rollups.Buckets {
+			w.Write(append([]string{key}, rollupRow(b)...))
+		}
+		w.Flush()
+		c.Writer.Flush()
+	}
+	finishCSV(c, w)
+}
+
+// csvReport checks the requested export format, which must be CSV. It
+// writes the error response and returns false otherwise.
+func csvReport(c *gin.Context) bool {
+	if c.DefaultQuery("format", FormatCSV) != FormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be csv"})
+		return false
+	}
+	return true
+}
+
+// startCSV commits the headers of a CSV attachment and writes its header row
+func startCSV(c *gin.Context, filename string, columns []string) *csv.Writer {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	w := csv.NewWriter(c.Writer)
+	w.Write(columns)
+	return w
+}
+
+// finishCSV flushes the rows of a CSV attachment, logging failures since
+// the response is already under way
+func finishCSV(c *gin.Context, w *csv.Writer) {
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("failed to write CSV export: %v", err)
+	}
+	c.Writer.Flush()
+}
+
+// rollupRow formats a bucket as a CSV row. Each breakdown is a
+// space-separated list of name:clicks pairs, most clicks first.
+func rollupRow(b storage.Bucket) []string {
+	return []string{
+		b.Start.Format(time.RFC3339), strconv.FormatInt(b.Clicks, 10),
+		formatCounts(b.Countries), formatCounts(b.Referrers), formatCounts(b.Channels),
+		formatCounts(b.Devices), formatCounts(b.OS), formatCounts(b.Browsers),
+	}
+}
+
+// formatCounts formats counters as space-separated name:count pairs,
+// highest first
+func formatCounts(counts map[string]int64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + ":" + strconv.FormatInt(counts[name], 10)
+	}
+	return strings.Join(pairs, " ")
+}
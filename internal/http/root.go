@@ -0,0 +1,135 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RootMode selects how GET / is answered
+type RootMode string
+
+// Supported root path behaviors
+const (
+	RootNotFound RootMode = "not_found"
+	RootRedirect RootMode = "redirect"
+	RootForm     RootMode = "form"
+)
+
+// RootConfig configures the behavior of GET /
+type RootConfig struct {
+	Mode        RootMode
+	RedirectURL string
+}
+
+// rootFormPage is the inline link creation form served at GET /
+const rootFormPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>URL Shortener</title>
+</head>
+<body>
+  <h1>URL Shortener</h1>
+  <form id="shorten">
+    <input type="url" name="url" placeholder="https://example.com/very/long/url" required>
+    <button type="submit">Shorten</button>
+  </form>
+  <p id="result"></p>
+  <script>
+    document.getElementById("shorten").addEventListener("submit", async function (e) {
+      e.preventDefault();
+      const result = document.getElementById("result");
+      const resp = await fetch("/api/v1/urls", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ url: e.target.url.value }),
+      });
+      const body = await resp.json();
+      result.textContent = resp.ok ? body.short_url : body.error;
+    });
+  </script>
+</body>
+</html>
+`
+
+var rootFormTemplate = template.Must(template.New("root").Parse(rootFormPage))
+
+// WithRoot configures GET / with a default behavior and per-host overrides
+func WithRoot(defaultConfig RootConfig, hosts map[string]RootConfig) Option {
+	return func(h *Handler) {
+		h.root = defaultConfig
+		h.rootHosts = hosts
+	}
+}
+
+// ParseRootConfig parses a root behavior of the form mode or redirect:URL
+func ParseRootConfig(spec string) (RootConfig, error) {
+	mode, target, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	cfg := RootConfig{Mode: RootMode(mode)}
+
+	switch cfg.Mode {
+	case RootNotFound, RootForm:
+		return cfg, nil
+	case RootRedirect:
+		u, err := url.Parse(target)
+		if err != nil || !u.IsAbs() {
+			return cfg, fmt.Errorf("invalid root redirect URL %q", target)
+		}
+		cfg.RedirectURL = target
+		return cfg, nil
+	}
+	return cfg, fmt.Errorf("invalid root mode %q", mode)
+}
+
+// ParseRootHosts parses comma-separated host=mode[:URL] root overrides
+func ParseRootHosts(spec string) (map[string]RootConfig, error) {
+	hosts := make(map[string]RootConfig)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid root host entry %q", entry)
+		}
+		cfg, err := ParseRootConfig(value)
+		if err != nil {
+			return nil, err
+		}
+		hosts[strings.ToLower(strings.TrimSpace(host))] = cfg
+	}
+	return hosts, nil
+}
+
+// rootConfig returns the root behavior for the request's host
+func (h *Handler) rootConfig(r *http.Request) RootConfig {
+	host := r.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if cfg, ok := h.rootHosts[strings.ToLower(host)]; ok {
+		return cfg
+	}
+	return h.root
+}
+
+// Root handles requests for the root path
+func (h *Handler) Root(c *gin.Context) {
+	cfg := h.rootConfig(c.Request)
+
+	switch cfg.Mode {
+	case RootRedirect:
+		c.Redirect(http.StatusFound, cfg.RedirectURL)
+	case RootForm:
+		renderHTML(c, http.StatusOK, rootFormTemplate, nil)
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+)
+
+func TestParseRootConfig(t *testing.T) {
+	cfg, err := ParseRootConfig("redirect:https://example.com/home")
+	require.NoError(t, err)
+	assert.Equal(t, RootConfig{Mode: RootRedirect, RedirectURL: "https://example.com/home"}, cfg)
+
+	cfg, err = ParseRootConfig("form")
+	require.NoError(t, err)
+	assert.Equal(t, RootForm, cfg.Mode)
+
+	_, err = ParseRootConfig("redirect:not-a-url")
+	assert.Error(t, err)
+
+	_, err = ParseRootConfig("teapot")
+	assert.Error(t, err)
+}
+
+func TestParseRootHosts(t *testing.T) {
+	hosts, err := ParseRootHosts("Go.Example.com=redirect:https://example.com, links.example.com=form")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]RootConfig{
+		"go.example.com":    {Mode: RootRedirect, RedirectURL: "https://example.com"},
+		"links.example.com": {Mode: RootForm},
+	}, hosts)
+
+	_, err = ParseRootHosts("missing-mode")
+	assert.Error(t, err)
+}
+
+func TestRoot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewHandler(nil, id.NewGenerator(), "http://localhost:8080", WithRoot(
+		RootConfig{Mode: RootForm},
+		map[string]RootConfig{
+			"go.example.com":  {Mode: RootRedirect, RedirectURL: "https://example.com"},
+			"api.example.com": {Mode: RootNotFound},
+		},
+	)).SetupRoutes(router)
+
+	tests := []struct {
+		name           string
+		host           string
+		expectedStatus int
+		validateResp   func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:           "Default form",
+			host:           "localhost:8080",
+			expectedStatus: http.StatusOK,
+			validateResp: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+				assert.Contains(t, w.Body.String(), "<form")
+			},
+		},
+		{
+			name:           "Host redirect",
+			host:           "GO.example.com:443",
+			expectedStatus: http.StatusFound,
+			validateResp: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+			},
+		},
+		{
+			name:           "Host not found",
+			host:           "api.example.com",
+			expectedStatus: http.StatusNotFound,
+			validateResp:   func(t *testing.T, w *httptest.ResponseRecorder) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			tt.validateResp(t, w)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/rules"
+)
+
+// ValidateRulesRequest represents rules to check and a sample visit to
+// dry-run them against. URL is the destination of visits no rule matches.
+type ValidateRulesRequest struct {
+	Rules   []rules.Rule  `json:"rules"`
+	URL     string        `json:"url"`
+	Request rules.Request `json:"request"`
+}
+
+// ValidateRulesResponse reports whether rules are valid and, if so, which
+// rule the sample visit matched and where it would be redirected
+type ValidateRulesResponse struct {
+	Valid   bool   `json:"valid"`
+	Error   string `json:"error,omitempty"`
+	Matched bool   `json:"matched"`
+	Rule    *int   `json:"rule,omitempty"`
+	Name    string `json:"name,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// ValidateRules checks redirect rules and dry-runs a sample visit against
+// them, without storing anything. The visit is at the current time unless
+// the request says otherwise.
+func (h *Handler) ValidateRules(c *gin.Context) {
+	var req ValidateRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	if err := rules.Validate(req.Rules); err != nil {
+		c.JSON(http.StatusBadRequest, ValidateRulesResponse{Error: err.Error()})
+		return
+	}
+	if req.URL != "" && !validDestination(req.URL) {
+		c.JSON(http.StatusBadRequest, ValidateRulesResponse{Error: "url must be absolute with http(s) scheme"})
+		return
+	}
+	if req.Request.Time.IsZero() {
+		req.Request.Time = time.Now()
+	}
+
+	response := ValidateRulesResponse{Valid: true, URL: req.URL}
+	if i, ok := rules.Evaluate(req.Rules, req.Request); ok {
+		response.Matched = true
+		response.Rule = &i
+		response.Name = req.Rules[i].Name
+		response.URL = req.Rules[i].URL
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// rulesRequest describes a redirect request at a time to evaluate rules
+// against
+func rulesRequest(req *http.Request, now time.Time) rules.Request {
+	return rules.Request{
+		Country:        clickCountry(req),
+		UserAgent:      req.UserAgent(),
+		AcceptLanguage: req.Header.Get("Accept-Language"),
+		Time:           now,
+	}
+}
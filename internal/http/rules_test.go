@@ -0,0 +1,48 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+)
+
+func TestValidateRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewHandler(nil, id.NewGenerator(), "http://localhost:8080").SetupRoutes(router)
+
+	rules := []map[string]interface{}{
+		{"name": "weekend", "when": map[string]interface{}{"weekdays": []string{"sat", "sun"}}, "url": "https://example.com/weekend"},
+	}
+	w := sendJSON(t, router, http.MethodPost, "/api/v1/rules/validate", map[string]interface{}{
+		"rules": rules, "url": "https://example.com", "request": map[string]string{"time": "2026-10-17T12:00:00Z"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	var result ValidateRulesResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.True(t, result.Valid)
+	assert.True(t, result.Matched)
+	assert.Equal(t, "weekend", result.Name)
+	assert.Equal(t, "https://example.com/weekend", result.URL)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/rules/validate", map[string]interface{}{
+		"rules": rules, "url": "https://example.com", "request": map[string]string{"time": "2026-10-14T12:00:00Z"},
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	result = ValidateRulesResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.False(t, result.Matched)
+	assert.Equal(t, "https://example.com", result.URL)
+
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/rules/validate", map[string]interface{}{
+		"rules": []map[string]interface{}{{"when": map[string]interface{}{"hours": "9-5"}, "url": "https://example.com"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "rule 1: invalid hours")
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultScanMaxMisses is the default number of unknown keys a client
+	// may request per window before it is flagged
+	DefaultScanMaxMisses = 20
+
+	// DefaultScanWindow is the default window misses are counted over
+	DefaultScanWindow = time.Minute
+
+	// DefaultScanBlock is the default time a flagged client stays blocked
+	// after its latest miss
+	DefaultScanBlock = 15 * time.Minute
+
+	// blocklistRefresh is how often each instance reloads the flagged
+	// clients, so redirects don't read Redis to check them
+	blocklistRefresh = time.Second
+)
+
+// scanStats counts unknown keys requested and flagged clients turned away,
+// published at /debug/vars
+var scanStats = expvar.NewMap("scan_protection")
+
+// ScanProtection sets when clients requesting unknown keys are treated as
+// enumerating the keyspace, and how they are slowed down
+type ScanProtection struct {
+	// MaxMisses is the number of redirects to unknown keys a client may
+	// request within Window before it is flagged
+	MaxMisses int64
+	Window    time.Duration
+
+	// Block is the time a flagged client stays flagged after its latest miss
+	Block time.Duration
+
+	// Tarpit, when set, delays every redirect of a flagged client this long
+	// instead of answering it 429 Too Many Requests, so scanners waste their
+	// time without learning they were caught
+	Tarpit time.Duration
+}
+
+// ScannersResponse lists the clients currently flagged as scanners
+type ScannersResponse struct {
+	Scanners []storage.Scanner `json:"scanners"`
+}
+
+// blocklist is an instance's copy of the flagged clients
+type blocklist struct {
+	mu     sync.Mutex
+	until  map[string]time.Time
+	loaded time.Time
+}
+
+// WithScanProtection counts the unknown keys each client requests from the
+// redirect route, and throttles or tarpits clients requesting too many, as
+// bots enumerating keys do. Clients are told apart by IP address.
+func WithScanProtection(store storage.ScannerStore, protection ScanProtection) Option {
+	return func(h *Handler) {
+		if protection.MaxMisses <= 0 {
+			protection.MaxMisses = DefaultScanMaxMisses
+		}
+		if protection.Window <= 0 {
+			protection.Window = DefaultScanWindow
+		}
+		if protection.Block <= 0 {
+			protection.Block = DefaultScanBlock
+		}
+		h.scanners = store
+		h.scanProtection = protection
+		h.blocklist = &blocklist{}
+	}
+}
+
+// guardScanners slows down flagged clients, and counts the unknown keys
+// every other redirect was answered for
+func (h *Handler) guardScanners(c *gin.Context) {
+	ip := c.ClientIP()
+	ctx := c.Request.Context()
+	if until, ok := h.flagged(ctx, ip); ok {
+		if h.scanProtection.Tarpit <= 0 {
+			scanStats.Add("throttled", 1)
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many unknown links requested. Try again later"})
+			return
+		}
+		scanStats.Add("tarpitted", 1)
+		select {
+		case <-ctx.Done():
+			c.Abort()
+			return
+		case <-time.After(h.scanProtection.Tarpit):
+		}
+	}
+
+	c.Next()
+
+	if c.Writer.Status() != http.StatusNotFound {
+		return
+	}
+	scanStats.Add("misses", 1)
+	p := h.scanProtection
+	flagged, err := h.scanners.CountMiss(ctx, ip, p.Window, p.MaxMisses, p.Block)
+	if err != nil {
+		log.Printf("failed to count miss for %s: %v", ip, err)
+		return
+	}
+	if flagged {
+		h.blocklist.flag(ip, time.Now().Add(p.Block))
+	}
+}
+
+// flagged reports whether a client is flagged, and until when. The request
+// finding this instance's copy of the blocklist out of date reloads it,
+// while concurrent requests keep using the copy they have.
+func (h *Handler) flagged(ctx context.Context, ip string) (time.Time, bool) {
+	b := h.blocklist
+	now := time.Now()
+
+	b.mu.Lock()
+	reload := now.Sub(b.loaded) >= blocklistRefresh
+	if reload {
+		// Failed loads are retried on the next refresh, not every request
+		b.loaded = now
+	}
+	b.mu.Unlock()
+
+	if reload {
+		if scanners, err := h.scanners.Scanners(ctx); err != nil {
+			log.Printf("failed to load scanners: %v", err)
+		} else {
+			until := make(map[string]time.Time, len(scanners))
+			for _, s := range scanners {
+				until[s.IP] = s.BlockedUntil
+			}
+			b.mu.Lock()
+			b.until = until
+			b.mu.Unlock()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.until[ip]
+	return until, ok && now.Before(until)
+}
+
+// flag adds a client flagged by this instance to its copy of the blocklist
+func (b *blocklist) flag(ip string, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.until == nil {
+		b.until = make(map[string]time.Time)
+	}
+	b.until[ip] = until
+}
+
+// forget drops an unblocked client from the copy of the blocklist
+func (b *blocklist) forget(ip string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.until, ip)
+}
+
+// ListScanners returns the clients currently flagged as scanners
+func (h *Handler) ListScanners(c *gin.Context) {
+	scanners, err := h.scanners.Scanners(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve scanners"})
+		return
+	}
+	c.JSON(http.StatusOK, ScannersResponse{Scanners: scanners})
+}
+
+// UnblockScanner lifts the block on a client flagged by mistake. Other
+// instances stop turning it away once they reload the blocklist.
+func (h *Handler) UnblockScanner(c *gin.Context) {
+	ip := c.Param("ip")
+	if err := h.scanners.Unblock(c.Request.Context(), ip); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock client"})
+		return
+	}
+	h.blocklist.forget(ip)
+	c.Status(http.StatusNoContent)
+}
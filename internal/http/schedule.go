@@ -0,0 +1,125 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxScheduleEntries is the maximum number of scheduled destinations per link
+const maxScheduleEntries = 50
+
+// scheduleError is the error returned for invalid schedules
+const scheduleError = "Invalid schedule. Entries need distinct from times and absolute http(s) URLs"
+
+// ScheduleRequest represents the request body for replacing a link's
+// schedule. An empty schedule clears it.
+type ScheduleRequest struct {
+	Schedule []storage.ScheduledDestination `json:"schedule"`
+}
+
+// ScheduleResponse represents a link's schedule together with the
+// destination in effect now and when it next changes
+type ScheduleResponse struct {
+	ShortKey   string                         `json:"short_key"`
+	Schedule   []storage.ScheduledDestination `json:"schedule"`
+	Current    string                         `json:"current"`
+	NextChange *time.Time                     `json:"next_change,omitempty"`
+}
+
+// normalizeSchedule validates scheduled destinations and sorts them by start
+func normalizeSchedule(schedule []storage.ScheduledDestination) bool {
+	if len(schedule) > maxScheduleEntries {
+		return false
+	}
+
+	sort.Slice(schedule, func(i, j int) bool {
+		return schedule[i].From.Before(schedule[j].From)
+	})
+	for i, entry := range schedule {
+		if entry.From.IsZero() || !validDestination(entry.URL) {
+			return false
+		}
+		if i > 0 && entry.From.Equal(schedule[i-1].From) {
+			return false
+		}
+	}
+	return true
+}
+
+// scheduleResponse builds the schedule of a link as it stands now
+func scheduleResponse(key string, rec *storage.Record) ScheduleResponse {
+	now := time.Now()
+	response := ScheduleResponse{
+		ShortKey:   key,
+		Schedule:   rec.Schedule,
+		Current:    rec.URL,
+		NextChange: rec.NextScheduleChange(now),
+	}
+	if dest, ok := rec.ScheduledURL(now); ok {
+		response.Current = dest
+	}
+	if response.Schedule == nil {
+		response.Schedule = []storage.ScheduledDestination{}
+	}
+	return response
+}
+
+// GetSchedule returns a link's scheduled destinations
+func (h *Handler) GetSchedule(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduleResponse(key, rec))
+}
+
+// SetSchedule replaces a link's scheduled destinations
+func (h *Handler) SetSchedule(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	if !normalizeSchedule(req.Schedule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": scheduleError})
+		return
+	}
+	scheduled := &storage.Record{Schedule: req.Schedule}
+	canonicalizeDestinations(scheduled)
+	if !h.secureDestinations(c, destinationFields(scheduled)[1:]) {
+		return
+	}
+	if !h.withinURLLength(c, recordURLs(scheduled)...) {
+		return
+	}
+
+	before := h.snapshot(rec)
+	rec.Schedule = req.Schedule
+	if len(rec.Schedule) == 0 {
+		rec.Schedule = nil
+	}
+	err := h.store.Update(c.Request.Context(), key, rec)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditUpdate, key, before, rec)
+
+	c.JSON(http.StatusOK, scheduleResponse(key, rec))
+}
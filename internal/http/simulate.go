@@ -0,0 +1,152 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+// simulatedUserAgents stand in for a device when a simulated request names
+// one without a User-Agent
+var simulatedUserAgents = map[useragent.Platform]string{
+	useragent.IOS:     "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148",
+	useragent.Android: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Mobile Safari/537.36",
+	useragent.Mobile:  "Mozilla/5.0 (Mobile; rv:120.0) Gecko/120.0 Firefox/120.0",
+	useragent.Desktop: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36",
+}
+
+// SimulateRequest describes a visit to simulate. Headers are applied first,
+// and the other fields override them. Time defaults to now.
+type SimulateRequest struct {
+	Headers        map[string]string `json:"headers"`
+	IP             string            `json:"ip"`
+	Country        string            `json:"country"`
+	Device         string            `json:"device"`
+	UserAgent      string            `json:"user_agent"`
+	AcceptLanguage string            `json:"accept_language"`
+	Time           *time.Time        `json:"time"`
+}
+
+// SimulateResponse describes how a simulated visit would be served. Route
+// names what picked the destination, with Rule the index of the rule,
+// device rule or locale rule matched. Inactive links report why instead.
+type SimulateResponse struct {
+	Key          string                `json:"key"`
+	Active       bool                  `json:"active"`
+	Inactive     string                `json:"inactive,omitempty"`
+	Route        string                `json:"route,omitempty"`
+	Rule         *int                  `json:"rule,omitempty"`
+	Name         string                `json:"name,omitempty"`
+	Variant      string                `json:"variant,omitempty"`
+	URL          string                `json:"url,omitempty"`
+	Status       int                   `json:"status,omitempty"`
+	Interstitial *storage.Interstitial `json:"interstitial,omitempty"`
+}
+
+// SimulateURL reports which routing a visit to a link would match and
+// where it would be redirected, without counting a click or using up
+// single-use links
+func (h *Handler) SimulateURL(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+	var sim SimulateRequest
+	if err := c.ShouldBindJSON(&sim); err != nil {
+		h.invalidBody(c, err)
+		return
+	}
+	req, ok := h.simulatedRequest(c, key, sim)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if sim.Time != nil {
+		now = *sim.Time
+	}
+
+	response := SimulateResponse{Key: key}
+	if err := rec.CheckActive(now); err != nil {
+		response.Inactive = inactiveReason(err)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	r := routeRequest(req, key, rec, now)
+	dest, err := withQueryParams(r.URL, key, rec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build destination URL"})
+		return
+	}
+	response.Active = true
+	response.Route = r.Source
+	if r.Index >= 0 {
+		response.Rule = &r.Index
+	}
+	if r.Source == RouteRule {
+		response.Name = rec.Rules[r.Index].Name
+	}
+	response.Variant = r.Variant
+	response.URL = dest
+	response.Status = redirectStatus(rec)
+	response.Interstitial = rec.Interstitial
+	c.JSON(http.StatusOK, response)
+}
+
+// simulatedRequest builds the redirect request a simulation describes,
+// answering the request itself if the description is invalid
+func (h *Handler) simulatedRequest(c *gin.Context, key string, sim SimulateRequest) (*http.Request, bool) {
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, h.redirects.Prefix+"/"+key, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to simulate request"})
+		return nil, false
+	}
+	for name, value := range sim.Headers {
+		req.Header.Set(name, value)
+	}
+	if sim.IP != "" {
+		ip := net.ParseIP(sim.IP)
+		if ip == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ip"})
+			return nil, false
+		}
+		req.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+	}
+	if sim.Country != "" {
+		// Countries are read from the headers CDNs set
+		req.Header.Set(countryHeaders[0], sim.Country)
+	}
+	if sim.Device != "" {
+		ua, ok := simulatedUserAgents[useragent.Platform(sim.Device)]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device. Must be ios, android, mobile or desktop"})
+			return nil, false
+		}
+		req.Header.Set("User-Agent", ua)
+	}
+	if sim.UserAgent != "" {
+		req.Header.Set("User-Agent", sim.UserAgent)
+	}
+	if sim.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", sim.AcceptLanguage)
+	}
+	return req, true
+}
+
+// inactiveReason names why a link is inactive
+func inactiveReason(err error) string {
+	switch err {
+	case storage.ErrDisabled:
+		return "disabled"
+	case storage.ErrUnverified:
+		return "unverified"
+	case storage.ErrNotYetActive:
+		return "not_yet_active"
+	}
+	return "no_longer_active"
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+// WithSingleUseLinks lets links be created with single_use, deleting them
+// on their first redirect
+func WithSingleUseLinks(consumer storage.Consumer) Option {
+	return func(h *Handler) {
+		h.consumer = consumer
+	}
+}
+
+// consumeLink uses up a single-use link, returning its record as it was
+// deleted, and answering the request itself if it can't. Only one of
+// concurrent requests gets the record; the others are answered like
+// unknown keys. HEAD requests, bots and link-preview fetchers never use a
+// link up, so they are answered like unknown keys too, without learning
+// its destination.
+func (h *Handler) consumeLink(c *gin.Context, key string) (*storage.Record, bool) {
+	if h.consumer == nil || h.notAVisit(c) {
+		h.notFound(c, key, "URL not found")
+		return nil, false
+	}
+
+	rec, err := h.consumer.Consume(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		h.notFound(c, key, "URL not found")
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return nil, false
+	}
+	h.audit(c, storage.AuditConsume, key, h.snapshot(rec), nil)
+	return rec, true
+}
+
+// notAVisit reports whether a redirect request can't be a person following
+// the link: anything but GET, link-preview fetchers and bots, whatever the
+// bot mode
+func (h *Handler) notAVisit(c *gin.Context) bool {
+	ua := c.Request.UserAgent()
+	return c.Request.Method != http.MethodGet || useragent.IsSocialPreview(ua) || (h.bots != nil && h.bots.IsBot(ua))
+}
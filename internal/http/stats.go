@@ -0,0 +1,358 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+const (
+	// maxRollupBuckets is the most buckets a statistics request may span
+	maxRollupBuckets = 1000
+
+	// maxRollupReads is the most stored aggregates read to answer a
+	// statistics request, bounding days and weeks built from hourly ones
+	maxRollupReads = 10000
+)
+
+// countryHeaders are the request headers CDNs and load balancers use to
+// report the visitor's country, in order of preference
+var countryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-AppEngine-Country", "X-Country-Code"}
+
+// ComparePrevious compares rollups with the period of the same length just
+// before them
+const ComparePrevious = "previous"
+
+// StatsResponse represents the click statistics of a short link. Rollups
+// are included when an interval is requested, and compared with the
+// previous period on request.
+type StatsResponse struct {
+	ShortKey   string           `json:"short_key"`
+	Current    *storage.Stats   `json:"current"`
+	Archived   []*storage.Stats `json:"archived"`
+	Rollups    *storage.Rollups `json:"rollups,omitempty"`
+	Comparison *Comparison      `json:"comparison,omitempty"`
+}
+
+// Comparison holds the rollups of the previous period and how the clicks
+// changed since. Change is a percentage, omitted when the previous period
+// had no clicks.
+type Comparison struct {
+	Previous *storage.Rollups `json:"previous"`
+	Clicks   int64            `json:"clicks"`
+	Change   *float64         `json:"change,omitempty"`
+}
+
+// newComparison compares rollups with those of the previous period
+func newComparison(current, previous *storage.Rollups) *Comparison {
+	cmp := &Comparison{Previous: previous, Clicks: current.Clicks - previous.Clicks}
+	if previous.Clicks > 0 {
+		change := math.Round(float64(cmp.Clicks)/float64(previous.Clicks)*1000) / 10
+		cmp.Change = &change
+	}
+	return cmp
+}
+
+// WithStats enables click recording and the statistics endpoints
+func WithStats(stats storage.StatsStore, recorder *analytics.Recorder) Option {
+	return func(h *Handler) {
+		h.stats = stats
+		h.recorder = recorder
+	}
+}
+
+// WithRollups serves hourly and daily click aggregates from the statistics endpoint
+func WithRollups(rollups storage.RollupStore) Option {
+	return func(h *Handler) {
+		h.rollups = rollups
+	}
+}
+
+// managedRecord loads the record for a management request and checks the
+// caller may manage it. It writes the error response and returns nil on failure.
+func (h *Handler) managedRecord(c *gin.Context) (string, *storage.Record) {
+	key := c.Param("key")
+
+	// Validate key format
+	if !h.validKey(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL key format"})
+		return "", nil
+	}
+
+	rec, err := h.store.GetRecord(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return "", nil
+	}
+
+	if !h.canManage(c, rec) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the link owner or an admin may do this"})
+		return "", nil
+	}
+	return key, rec
+}
+
+// GetStats returns the click statistics of a short link
+func (h *Handler) GetStats(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	current, err := h.stats.GetStats(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+	if current.Since == nil && !rec.CreatedAt.IsZero() {
+		current.Since = &rec.CreatedAt
+	}
+
+	archived, err := h.stats.GetArchivedStats(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+
+	response := StatsResponse{
+		ShortKey: key,
+		Current:  current,
+		Archived: archived,
+	}
+	if c.Query("interval") != "" || c.Query("granularity") != "" {
+		q, ok := h.rollupRange(c, "")
+		if !ok {
+			return
+		}
+		if response.Rollups = h.rollupsFor(c, key, q); response.Rollups == nil {
+			return
+		}
+		switch c.Query("compare") {
+		case "":
+		case ComparePrevious:
+			previous := h.rollupsFor(c, key, q.previous())
+			if previous == nil {
+				return
+			}
+			response.Comparison = newComparison(response.Rollups, previous)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid compare. Must be previous"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// rollupsFor reads the aggregates requested by the interval (or
+// granularity), tz, from and to query parameters. It writes the error
+// response and returns nil on failure.
+func (h *Handler) rollupsFor(c *gin.Context, key string, q rollupQuery) *storage.Rollups {
+	rollups, err := h.readRollups(c.Request.Context(), key, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return nil
+	}
+	return rollups
+}
+
+// rollupQuery is a range of aggregates in buckets of an interval, with days
+// and weeks starting at midnight in loc
+type rollupQuery struct {
+	interval storage.Granularity
+	loc      *time.Location
+	from, to time.Time
+}
+
+// source returns the stored granularity the query's buckets are built
+// from. Days outside UTC don't line up with stored days, so they and their
+// weeks are built from hourly aggregates.
+func (q rollupQuery) source() storage.Granularity {
+	if q.interval == storage.GranularityHour || q.loc != time.UTC {
+		return storage.GranularityHour
+	}
+	return storage.GranularityDay
+}
+
+// previous returns the query for the period of the same length just before
+func (q rollupQuery) previous() rollupQuery {
+	q.from, q.to = q.from.Add(-q.to.Sub(q.from)), q.from
+	return q
+}
+
+// readRollups reads the aggregates of a query, regrouping stored buckets
+// into the query's interval and time zone
+func (h *Handler) readRollups(ctx context.Context, key string, q rollupQuery) (*storage.Rollups, error) {
+	source := q.source()
+	rollups, err := h.rollups.GetRollups(ctx, key, source, q.from, q.to)
+	if err != nil {
+		return nil, err
+	}
+	if q.interval != source || q.loc != time.UTC {
+		rollups = rollups.Regroup(q.interval, q.loc)
+	}
+	return rollups, nil
+}
+
+// rollupRange reads the interval of rollups (or its older name,
+// granularity), tz, from and to query parameters, defaulting to the given
+// interval. Ranges default to the last 24 hours for hourly buckets, the
+// last 30 days for daily ones and the last 12 weeks for weekly ones, and
+// start at the beginning of a bucket. It writes the error response and
+// returns false on failure.
+func (h *Handler) rollupRange(c *gin.Context, interval string) (rollupQuery, bool) {
+	if h.rollups == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rollups are not enabled"})
+		return rollupQuery{}, false
+	}
+	if v := c.DefaultQuery("interval", c.Query("granularity")); v != "" {
+		interval = v
+	}
+	q := rollupQuery{interval: storage.Granularity(interval), loc: time.UTC}
+	switch q.interval {
+	case storage.GranularityHour, storage.GranularityDay, storage.GranularityWeek:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval. Must be hour, day or week"})
+		return rollupQuery{}, false
+	}
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tz. Must be an IANA time zone such as Europe/Paris"})
+			return rollupQuery{}, false
+		}
+		q.loc = loc
+	}
+
+	var err error
+	q.to = time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		if q.to, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to. Must be an RFC 3339 time"})
+			return rollupQuery{}, false
+		}
+	}
+	switch q.interval {
+	case storage.GranularityHour:
+		q.from = q.to.Add(-24 * time.Hour)
+	case storage.GranularityDay:
+		q.from = q.to.AddDate(0, 0, -30)
+	case storage.GranularityWeek:
+		q.from = q.to.AddDate(0, 0, -7*12)
+	}
+	if v := c.Query("from"); v != "" {
+		if q.from, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from. Must be an RFC 3339 time"})
+			return rollupQuery{}, false
+		}
+	}
+	q.from = q.interval.TruncateIn(q.from, q.loc)
+
+	span := q.to.Sub(q.from)
+	if !q.from.Before(q.to) || span > maxRollupBuckets*q.interval.Span() || span > maxRollupReads*q.source().Span() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid range. from must be before to and span at most 1000 buckets"})
+		return rollupQuery{}, false
+	}
+	return q, true
+}
+
+// ResetStats archives the current statistics of a short link and starts a new period
+func (h *Handler) ResetStats(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	archived, err := h.stats.ResetStats(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset statistics"})
+		return
+	}
+	h.audit(c, storage.AuditResetStats, key, nil, nil)
+	if archived.Period == 1 && !rec.CreatedAt.IsZero() {
+		archived.Since = &rec.CreatedAt
+	}
+
+	current, err := h.stats.GetStats(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StatsResponse{
+		ShortKey: key,
+		Current:  current,
+		Archived: []*storage.Stats{archived},
+	})
+}
+
+// recordClick queues a click for the statistics of a short link and its workspace
+func (h *Handler) recordClick(c *gin.Context, key string, rec *storage.Record, variant string) {
+	if h.recorder == nil {
+		return
+	}
+	click := analytics.Click{
+		Key:       key,
+		Workspace: rec.Workspace,
+		Owner:     rec.Owner,
+		Time:      time.Now().UTC(),
+		Variant:   variant,
+		Country:   clickCountry(c.Request),
+		Referrer:  clickReferrer(c.Request),
+	}
+	agent := useragent.Parse(c.Request.UserAgent())
+	click.Device, click.OS, click.Browser = agent.Device, agent.OS, agent.Browser
+	if h.honorDNT && doNotTrack(c.Request) {
+		click.NoTrack = true
+	} else if h.visits != nil {
+		click.IP = c.ClientIP()
+		click.UserAgent = c.Request.UserAgent()
+	}
+	h.recorder.Record(click)
+}
+
+// WithDoNotTrack honors the DNT and Sec-GPC headers: clicks of visitors
+// sending either are counted, but no per-visitor details are recorded
+func WithDoNotTrack() Option {
+	return func(h *Handler) {
+		h.honorDNT = true
+	}
+}
+
+// doNotTrack reports whether the visitor asked not to be tracked
+func doNotTrack(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("DNT")) == "1" || strings.TrimSpace(r.Header.Get("Sec-GPC")) == "1"
+}
+
+// clickCountry returns the visitor's country as reported by a CDN or load
+// balancer in front of the service, or "" if unknown
+func clickCountry(r *http.Request) string {
+	for _, header := range countryHeaders {
+		code := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+		if len(code) == 2 && code != "XX" && code[0] >= 'A' && code[0] <= 'Z' && code[1] >= 'A' && code[1] <= 'Z' {
+			return code
+		}
+	}
+	return ""
+}
+
+// clickReferrer returns the host of the referring page, or "" for direct visits
+func clickReferrer(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,706 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/metadata"
+	"github.com/prayushdave/url-shortener/internal/storage"
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+func TestStats_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	recorder := analytics.NewRecorder(store, 100)
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"owner-key": "owner", "other-key": "other", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager),
+		WithStats(store, recorder),
+	).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		headers := map[string]string{}
+		if apiKey != "" {
+			headers[auth.APIKeyHeader] = apiKey
+		}
+		return sendJSONWithHeaders(t, router, method, path, headers, body)
+	}
+
+	created := send(http.MethodPost, "/api/v1/urls", "owner-key", map[string]string{"url": "https://example.com"})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusFound, send(http.MethodGet, "/"+link.ShortKey, "", nil).Code)
+	}
+
+	// Wait for the recorder to write the clicks
+	assert.Eventually(t, func() bool {
+		stats, err := store.GetStats(context.Background(), link.ShortKey)
+		return err == nil && stats.Clicks == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// Only the owner or an admin may see or reset statistics
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats", "other-key", nil).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/urls/"+link.ShortKey+"/stats/reset", "other-key", nil).Code)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/urls/"+link.ShortKey+"/stats/reset", "", nil).Code)
+
+	w := send(http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats", "owner-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats StatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, int64(3), stats.Current.Clicks)
+	assert.Equal(t, 1, stats.Current.Period)
+	assert.NotNil(t, stats.Current.Since)
+	assert.Empty(t, stats.Archived)
+
+	w = send(http.MethodPost, "/api/v1/urls/"+link.ShortKey+"/stats/reset", "admin-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, int64(0), stats.Current.Clicks)
+	assert.Equal(t, 2, stats.Current.Period)
+	require.Len(t, stats.Archived, 1)
+	assert.Equal(t, int64(3), stats.Archived[0].Clicks)
+	assert.Equal(t, 1, stats.Archived[0].Period)
+
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/api/v1/urls/abcd1234/stats", "admin-key", nil).Code)
+}
+
+func TestBotFilter_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	const (
+		browserUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+		botUA     = "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"
+	)
+
+	for _, mode := range []BotMode{BotExclude, BotPreview} {
+		t.Run(string(mode), func(t *testing.T) {
+			recorder := analytics.NewRecorder(store, 100)
+			router := gin.New()
+			NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+				WithStats(store, recorder),
+				WithBotFilter(useragent.NewBotDetector(), mode),
+			).SetupRoutes(router)
+
+			created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]string{"url": "https://example.com"})
+			require.Equal(t, http.StatusCreated, created.Code)
+			var link URLResponse
+			require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+			visit := func(ua string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+				req.Header.Set("User-Agent", ua)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			assert.Equal(t, http.StatusFound, visit(browserUA).Code)
+
+			w := visit(botUA)
+			if mode == BotPreview {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Contains(t, w.Body.String(), `<meta property="og:url" content="https://example.com">`)
+			} else {
+				assert.Equal(t, http.StatusFound, w.Code)
+			}
+
+			// Close drains the queue; only the browser click is counted
+			recorder.Close()
+			stats, err := store.GetStats(context.Background(), link.ShortKey)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), stats.Clicks)
+		})
+	}
+}
+
+func TestLinkPreview_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Launch day"><meta property="og:image" content="/cover.png"></head></html>`))
+	}))
+	defer destination.Close()
+
+	prefetcher := metadata.NewPrefetcher(metadata.Fetcher{Client: destination.Client()}, store, 10, time.Second)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithPreviews(prefetcher),
+	).SetupRoutes(router)
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]string{"url": destination.URL})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+	// Close waits for the preview to be fetched
+	prefetcher.Close()
+
+	visit := func(ua string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		req.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := visit("Twitterbot/1.0")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<meta property="og:title" content="Launch day">`)
+	assert.Contains(t, w.Body.String(), `<meta property="og:image" content="`+destination.URL+`/cover.png">`)
+	assert.Contains(t, w.Body.String(), `<meta name="twitter:card" content="summary_large_image">`)
+
+	// Browsers and other bots are redirected
+	assert.Equal(t, http.StatusFound, visit("Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0").Code)
+	assert.Equal(t, http.StatusFound, visit("Mozilla/5.0 (compatible; Googlebot/2.1)").Code)
+}
+
+func TestGetURL_Metadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Pricing</title><meta name="description" content="Plans for every team"></head></html>`))
+	}))
+	defer destination.Close()
+
+	prefetcher := metadata.NewPrefetcher(metadata.Fetcher{Client: destination.Client()}, store, 10, time.Second)
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithMetadata(prefetcher),
+	).SetupRoutes(router)
+
+	created := sendJSON(t, router, http.MethodPost, "/api/v1/urls", map[string]string{"url": destination.URL})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+	// Wait for the metadata to be fetched
+	require.NoError(t, prefetcher.Flush(context.Background()))
+
+	w := sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response LinkResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, link.ShortKey, response.ShortKey)
+	assert.Equal(t, destination.URL, response.URL)
+	assert.Equal(t, "Pricing", response.Title)
+	assert.Equal(t, "Plans for every team", response.Description)
+
+	// Metadata alone doesn't turn redirects for social bots into previews
+	req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+	req.Header.Set("User-Agent", "Twitterbot/1.0")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodGet, "/api/v1/urls/zzzzzzzz", nil).Code)
+	prefetcher.Close()
+}
+
+func TestStats_Rollups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	store.EnableRollups(storage.RollupOptions{})
+
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithStats(store, recorder), WithRollups(store)).SetupRoutes(router)
+
+	link := createTestURL(t, router, "https://example.com")
+	for _, headers := range []map[string]string{
+		{"CF-IPCountry": "fr", "Referer": "https://www.News.example/story"},
+		{"CF-IPCountry": "XX", "Referer": "android-app://com.example"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	require.NoError(t, recorder.Flush(context.Background()))
+	_, err := store.Aggregate(context.Background())
+	require.NoError(t, err)
+
+	w := sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats?granularity=hour", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp StatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Rollups)
+	assert.Equal(t, storage.GranularityHour, resp.Rollups.Granularity)
+	assert.Len(t, resp.Rollups.Buckets, 25)
+	assert.Equal(t, int64(2), resp.Rollups.Clicks)
+	assert.Equal(t, map[string]int64{"FR": 1}, resp.Rollups.Countries)
+	assert.Equal(t, map[string]int64{"news.example": 1}, resp.Rollups.Referrers)
+	assert.Equal(t, map[string]int64{"other": 1, "direct": 1}, resp.Rollups.Channels)
+
+	// Plain statistics requests skip the rollups
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = StatsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Nil(t, resp.Rollups)
+
+	// Days and weeks can start at midnight in another time zone, and be
+	// compared with the period before
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats?interval=week&tz=Asia/Tokyo&compare=previous", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = StatsResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Rollups)
+	assert.Equal(t, storage.GranularityWeek, resp.Rollups.Granularity)
+	assert.Equal(t, int64(2), resp.Rollups.Clicks)
+	last := resp.Rollups.Buckets[len(resp.Rollups.Buckets)-1]
+	assert.Equal(t, int64(2), last.Clicks)
+	assert.Equal(t, time.Monday, last.Start.Weekday())
+	_, offset := last.Start.Zone()
+	assert.Equal(t, 9*3600, offset)
+	require.NotNil(t, resp.Comparison)
+	assert.Equal(t, int64(0), resp.Comparison.Previous.Clicks)
+	assert.Equal(t, int64(2), resp.Comparison.Clicks)
+	assert.Nil(t, resp.Comparison.Change)
+
+	for _, query := range []string{"granularity=month", "interval=day&compare=last-year", "interval=day&tz=Mars/Olympus", "granularity=day&from=yesterday", "granularity=hour&from=2026-01-01T00:00:00Z&to=2025-01-01T00:00:00Z", "granularity=hour&from=2020-01-01T00:00:00Z"} {
+		assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats?"+query, nil).Code, query)
+	}
+}
+
+func TestStreamClicks_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+	store.EnableLiveClicks()
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"owner-key": "owner", "other-key": "other"},
+	})
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	done := make(chan struct{})
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithStats(store, recorder), WithClickStream(store, done)).SetupRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	created := sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls", map[string]string{auth.APIKeyHeader: "owner-key"}, map[string]string{"url": "https://example.com"})
+	require.Equal(t, http.StatusCreated, created.Code)
+	var link URLResponse
+	require.NoError(t, json.NewDecoder(created.Body).Decode(&link))
+
+	open := func(apiKey string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/urls/"+link.ShortKey+"/stream", nil)
+		require.NoError(t, err)
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Only those who may manage the link may follow it
+	resp := open("other-key")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = open("owner-key")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+	req.Header.Set("CF-IPCountry", "NL")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, "event:click", lines[0])
+	var event ClickEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data:")), &event))
+	assert.Equal(t, link.ShortKey, event.ShortKey)
+	assert.Equal(t, "NL", event.Country)
+
+	// Streams end on shutdown
+	close(done)
+	_, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+}
+
+func TestTopLinks_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	require.NoError(t, store.FlushDB(context.Background()))
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"user-key": "user", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithStats(store, recorder), WithTopLinks(store)).SetupRoutes(router)
+
+	send := func(path, apiKey string) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, http.MethodGet, path, map[string]string{auth.APIKeyHeader: apiKey}, nil)
+	}
+
+	link := createTestURL(t, router, "https://example.com")
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil))
+	}
+	require.NoError(t, recorder.Flush(context.Background()))
+
+	assert.Equal(t, http.StatusForbidden, send("/api/v1/stats/top", "user-key").Code)
+
+	w := send("/api/v1/stats/top?window=6h&limit=5", "admin-key")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp TopLinksResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "6h0m0s", resp.Window)
+	assert.Equal(t, int64(2), resp.Clicks)
+	assert.Equal(t, []storage.TopLink{{Key: link.ShortKey, URL: "https://example.com", Clicks: 2}}, resp.Links)
+
+	for _, query := range []string{"window=30m", "window=1y", "window=200h", "limit=0", "limit=101"} {
+		assert.Equal(t, http.StatusBadRequest, send("/api/v1/stats/top?"+query, "admin-key").Code, query)
+	}
+}
+
+func TestCampaigns_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"owner-key": "owner", "other-key": "other"},
+	})
+
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager),
+		WithStats(store, recorder),
+		WithCampaigns(store),
+	).SetupRoutes(router)
+
+	send := func(method, path, apiKey string, body interface{}) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, body)
+	}
+
+	require.NoError(t, store.Create(ctx, "launch01", &storage.Record{URL: "https://example.com/a", Owner: "owner"}))
+	require.NoError(t, store.Create(ctx, "launch02", &storage.Record{URL: "https://example.com/b", Owner: "owner"}))
+	require.NoError(t, store.Create(ctx, "foreign1", &storage.Record{URL: "https://example.com/c", Owner: "other"}))
+
+	// Links the caller may not manage are reported, the rest are added
+	w := send(http.MethodPost, "/api/v1/campaigns", "owner-key", map[string]interface{}{
+		"name": "Autumn launch",
+		"keys": []string{"launch01", "foreign1", "missing1"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var campaign CampaignResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&campaign))
+	assert.Equal(t, "Autumn launch", campaign.Name)
+	assert.Equal(t, []string{"launch01"}, campaign.Keys)
+	assert.Equal(t, []BatchDeleteError{
+		{Key: "foreign1", Error: "Only the link owner or an admin may do this"},
+		{Key: "missing1", Error: "URL not found"},
+	}, campaign.Errors)
+
+	w = send(http.MethodPost, "/api/v1/campaigns/"+campaign.ID+"/links", "owner-key", map[string]interface{}{"keys": []string{"launch02", "launch01"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&campaign))
+	assert.Equal(t, []string{"launch01", "launch02"}, campaign.Keys)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusFound, send(http.MethodGet, "/launch02", "", nil).Code)
+	}
+	assert.Equal(t, http.StatusFound, send(http.MethodGet, "/launch01", "", nil).Code)
+	assert.Equal(t, http.StatusFound, send(http.MethodGet, "/foreign1", "", nil).Code)
+	require.NoError(t, recorder.Flush(ctx))
+
+	w = send(http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/stats", "owner-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats CampaignStatsResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	assert.Equal(t, "Autumn launch", stats.Name)
+	assert.Equal(t, int64(2), stats.Links)
+	assert.Equal(t, int64(4), stats.Clicks)
+	assert.Equal(t, []storage.CampaignLinkStats{{Key: "launch02", Clicks: 3}, {Key: "launch01", Clicks: 1}}, stats.Top)
+
+	// Deleted links drop out of the campaign
+	require.NoError(t, store.Delete(ctx, "launch01"))
+	w = send(http.MethodGet, "/api/v1/campaigns/"+campaign.ID, "owner-key", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&campaign))
+	assert.Equal(t, []string{"launch02"}, campaign.Keys)
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/stats", "other-key", nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/api/v1/campaigns/unknown", "owner-key", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/api/v1/campaigns", "owner-key", map[string]string{"name": " "}).Code)
+}
+
+func TestPrivacy_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+	store.EnableVisits(storage.VisitOptions{})
+
+	manager := auth.NewManager(store, auth.Config{
+		APIKeys: map[string]string{"alice-key": "alice", "bob-key": "bob", "admin-key": "admin"},
+		Admins:  map[string]bool{"admin": true},
+	})
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithAuth(manager), WithStats(store, recorder), WithPrivacy(store, store)).SetupRoutes(router)
+
+	send := func(method, path, apiKey string) *httptest.ResponseRecorder {
+		return sendJSONWithHeaders(t, router, method, path, map[string]string{auth.APIKeyHeader: apiKey}, nil)
+	}
+	create := func(apiKey string) string {
+		w := sendJSONWithHeaders(t, router, http.MethodPost, "/api/v1/urls",
+			map[string]string{auth.APIKeyHeader: apiKey}, map[string]interface{}{"url": "https://example.com"})
+		require.Equal(t, http.StatusCreated, w.Code)
+		var link URLResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&link))
+		return link.ShortKey
+	}
+	export := func(path, apiKey string) PersonalDataExport {
+		w := send(http.MethodGet, path, apiKey)
+		require.Equal(t, http.StatusOK, w.Code)
+		var export PersonalDataExport
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&export))
+		return export
+	}
+
+	key := create("alice-key")
+	create("bob-key")
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/"+key, nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	require.NoError(t, recorder.Flush(ctx))
+
+	// Owners export their links with statistics and visits, IPs truncated at ingest
+	data := export("/api/v1/privacy/export", "alice-key")
+	assert.Equal(t, "alice", data.Owner)
+	require.Len(t, data.Links, 1)
+	assert.Equal(t, key, data.Links[0].ShortKey)
+	assert.Equal(t, int64(2), data.Links[0].Stats.Clicks)
+	require.Len(t, data.Links[0].Visits, 2)
+	assert.Equal(t, "192.0.2.0", data.Links[0].Visits[0].IP)
+	assert.Equal(t, "Mozilla/5.0", data.Links[0].Visits[0].UserAgent)
+
+	// Only admins act on another owner's data
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/api/v1/privacy/export?owner=alice", "bob-key").Code)
+	assert.Len(t, export("/api/v1/privacy/export?owner=alice", "admin-key").Links, 1)
+
+	w := send(http.MethodDelete, "/api/v1/privacy/visits", "alice-key")
+	require.Equal(t, http.StatusOK, w.Code)
+	var erased ErasureResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&erased))
+	assert.Equal(t, ErasureResponse{Owner: "alice", Mode: EraseAnonymize, Links: 1, Visits: 2}, erased)
+
+	visits := export("/api/v1/privacy/export", "alice-key").Links[0].Visits
+	require.Len(t, visits, 2)
+	assert.Empty(t, visits[0].IP)
+	assert.Empty(t, visits[0].UserAgent)
+	assert.False(t, visits[0].Time.IsZero())
+
+	w = send(http.MethodDelete, "/api/v1/privacy/visits?mode=purge", "alice-key")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&erased))
+	assert.Equal(t, 2, erased.Visits)
+	assert.Empty(t, export("/api/v1/privacy/export", "alice-key").Links[0].Visits)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodDelete, "/api/v1/privacy/visits?mode=shred", "alice-key").Code)
+}
+
+func TestDoNotTrack_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+	store.EnableVisits(storage.VisitOptions{})
+
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithStats(store, recorder), WithPrivacy(store, store), WithDoNotTrack()).SetupRoutes(router)
+
+	link := createTestURL(t, router, "https://example.com")
+	for _, headers := range []map[string]string{
+		{"DNT": "1"},
+		{"Sec-GPC": "1"},
+		{"DNT": "0", "User-Agent": "Mozilla/5.0"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	require.NoError(t, recorder.Flush(ctx))
+
+	// Every click is counted, but only the consenting visitor is recorded
+	stats, err := store.GetStats(ctx, link.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+	visits, err := store.GetVisits(ctx, link.ShortKey)
+	require.NoError(t, err)
+	require.Len(t, visits, 1)
+	assert.Equal(t, "Mozilla/5.0", visits[0].UserAgent)
+}
+
+func TestRedirectHeadOptions_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080", WithStats(store, recorder)).SetupRoutes(router)
+
+	link := createTestURL(t, router, "https://example.com/page")
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	// HEAD answers like GET, without a body or a click
+	w := serve(http.MethodHead, "/"+link.ShortKey)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://example.com/page", w.Header().Get("Location"))
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodHead, "/missing1").Code)
+
+	require.NoError(t, recorder.Flush(ctx))
+	stats, err := store.GetStats(ctx, link.ShortKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Clicks)
+
+	w = serve(http.MethodOptions, "/"+link.ShortKey)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+}
+
+func TestStatsExport_Integration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+	store.EnableRollups(storage.RollupOptions{})
+	store.EnableVisits(storage.VisitOptions{})
+
+	recorder := analytics.NewRecorder(store, 100)
+	defer recorder.Close()
+	router := gin.New()
+	NewHandler(store, id.NewGenerator(), "http://localhost:8080",
+		WithStats(store, recorder), WithRollups(store), WithPrivacy(store, store), WithCampaigns(store)).SetupRoutes(router)
+
+	link := createTestURL(t, router, "https://example.com")
+	for _, country := range []string{"FR", "DE", "FR"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+link.ShortKey, nil)
+		req.Header.Set("CF-IPCountry", country)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	require.NoError(t, recorder.Flush(ctx))
+	_, err := store.Aggregate(ctx)
+	require.NoError(t, err)
+
+	// Daily rollups by default, with a row per bucket
+	w := sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats/export?format=csv", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="`+link.ShortKey+`-day.csv"`, w.Header().Get("Content-Disposition"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 32)
+	assert.Equal(t, "start,clicks,countries,referrers,channels,devices,os,browsers", lines[0])
+	today := time.Now().UTC().Format("2006-01-02") + "T00:00:00Z"
+	assert.Equal(t, today+",3,FR:2 DE:1,,direct:3,mobile:3,ios:3,safari:3", lines[31])
+
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats/export?data=clicks", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "time,country,referrer,variant,user_agent,ip", lines[0])
+
+	// Campaign exports have a row per link and bucket
+	w = sendJSON(t, router, http.MethodPost, "/api/v1/campaigns", map[string]interface{}{"name": "Launch", "keys": []string{link.ShortKey}})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var campaign CampaignResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&campaign))
+	w = sendJSON(t, router, http.MethodGet, "/api/v1/campaigns/"+campaign.ID+"/stats/export?granularity=hour", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 26)
+	assert.Equal(t, "key,start,clicks,countries,referrers,channels,devices,os,browsers", lines[0])
+	assert.True(t, strings.HasPrefix(lines[25], link.ShortKey+","), lines[25])
+
+	for _, query := range []string{"format=xlsx", "data=visitors", "interval=month"} {
+		assert.Equal(t, http.StatusBadRequest, sendJSON(t, router, http.MethodGet, "/api/v1/urls/"+link.ShortKey+"/stats/export?"+query, nil).Code, query)
+	}
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, router, http.MethodGet, "/api/v1/urls/missing1/stats/export", nil).Code)
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// streamHeartbeatInterval is how often an idle stream sends a comment so
+// proxies keep the connection open
+const streamHeartbeatInterval = 15 * time.Second
+
+// ClickEvent is a click pushed to live stream subscribers
+type ClickEvent struct {
+	ShortKey string    `json:"short_key"`
+	Time     time.Time `json:"time"`
+	Variant  string    `json:"variant,omitempty"`
+	Country  string    `json:"country,omitempty"`
+	Referrer string    `json:"referrer,omitempty"`
+}
+
+// WithClickStream enables live click streams. Open streams are ended once
+// done is closed, so they don't hold up a graceful shutdown.
+func WithClickStream(stream storage.ClickStream, done <-chan struct{}) Option {
+	return func(h *Handler) {
+		h.clickStream = stream
+		h.streamsDone = done
+	}
+}
+
+// StreamClicks pushes the clicks of a short link to the caller as
+// Server-Sent Events until the caller disconnects
+func (h *Handler) StreamClicks(c *gin.Context) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	sub, err := h.clickStream.SubscribeClicks(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to clicks"})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case click, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent("click", ClickEvent{
+				ShortKey: click.Key,
+				Time:     click.Time,
+				Variant:  click.Variant,
+				Country:  click.Country,
+				Referrer: click.Referrer,
+			})
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-h.streamsDone:
+			return false
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxTags is the maximum number of tags per link
+const maxTags = 20
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ListURLsResponse represents the links matched by a search
+type ListURLsResponse struct {
+	URLs []LinkResponse `json:"urls"`
+}
+
+// normalizeTags lowercases and deduplicates tags, reporting false if any is invalid
+func normalizeTags(tags []string) ([]string, bool) {
+	if len(tags) > maxTags {
+		return nil, false
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, false
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, true
+	}
+	return normalized, true
+}
+
+// ListURLs returns links filtered by tag, destination substring and
+// destination status, newest first. When authentication is enabled, callers
+// only see the links of their workspace, or their own links outside any
+// workspace, unless they are admins.
+func (h *Handler) ListURLs(c *gin.Context) {
+	results, ok := h.searchLinks(c)
+	if !ok {
+		return
+	}
+
+	response := ListURLsResponse{URLs: make([]LinkResponse, 0, len(results))}
+	for _, r := range results {
+		link := linkResponse(r.Key, r.Record)
+		link.ExpiresAt = r.ExpiresAt
+		response.URLs = append(response.URLs, link)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// searchLinks runs the search described by the query string, answering the
+// request itself if it can't
+func (h *Handler) searchLinks(c *gin.Context) ([]storage.SearchResult, bool) {
+	q := storage.SearchQuery{
+		Query: strings.TrimSpace(c.Query("q")),
+	}
+
+	if tag := c.Query("tag"); tag != "" {
+		tags, ok := normalizeTags([]string{tag})
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag"})
+			return nil, false
+		}
+		q.Tag = tags[0]
+	}
+	switch status := storage.CheckStatus(c.Query("status")); status {
+	case "":
+	case storage.CheckOK, storage.CheckBroken, storage.CheckUnchecked:
+		q.Status = status
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Must be ok, broken or unchecked"})
+		return nil, false
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > storage.MaxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return nil, false
+		}
+		q.Limit = n
+	}
+
+	if !h.scopeSearch(c, &q) {
+		return nil, false
+	}
+
+	results, err := h.store.Search(c.Request.Context(), q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search URLs"})
+		return nil, false
+	}
+
+	return results, true
+}
+
+// scopeSearch restricts a search to the links the caller may see: those of
+// their workspace, or their own outside any workspace, unless they are an
+// admin. It writes an error response and returns false without a caller.
+func (h *Handler) scopeSearch(c *gin.Context, q *storage.SearchQuery) bool {
+	if h.auth == nil {
+		return true
+	}
+
+	principal := auth.PrincipalFrom(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return false
+	}
+	switch {
+	case principal.Admin:
+	case principal.Workspace != "":
+		q.Workspace = principal.Workspace
+	default:
+		q.Owner = principal.Subject
+	}
+	return true
+}
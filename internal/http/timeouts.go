@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultRedirectTimeout is the default time a redirect may take
+	DefaultRedirectTimeout = 2 * time.Second
+
+	// DefaultAPITimeout is the default time an API request may take
+	DefaultAPITimeout = 10 * time.Second
+
+	// DefaultBatchTimeout is the default time a request working on many
+	// links at once may take
+	DefaultBatchTimeout = 2 * time.Minute
+)
+
+// RouteTimeouts bounds the time requests may take, by kind of route. A zero
+// timeout leaves those requests unbounded.
+type RouteTimeouts struct {
+	Redirect time.Duration
+	API      time.Duration
+	Batch    time.Duration
+}
+
+// batchRoutes are the API routes working on many links at once
+var batchRoutes = map[string]bool{
+	"DELETE /api/v1/urls":                    true,
+	"POST /api/v1/campaigns/:campaign/links": true,
+	"POST /api/v1/admin/import":              true,
+	"GET /api/v1/admin/export":               true,
+	"GET /api/v1/admin/backup":               true,
+	"POST /api/v1/admin/restore":             true,
+	"GET /api/v1/privacy/export":             true,
+	"DELETE /api/v1/privacy/visits":          true,
+}
+
+// streamRoutes are the API routes holding the connection open for as long as
+// the client listens
+var streamRoutes = map[string]bool{
+	"GET /api/v1/urls/:key/stream": true,
+}
+
+// WithRouteTimeouts answers requests still running past their route's
+// timeout with 504 Gateway Timeout. The request's context is cancelled at
+// the deadline, so the Redis calls it is waiting on give up too.
+func WithRouteTimeouts(timeouts RouteTimeouts) Option {
+	return func(h *Handler) {
+		h.timeouts = timeouts
+	}
+}
+
+// redirectDeadline bounds the time a redirect may take
+func (h *Handler) redirectDeadline(c *gin.Context) {
+	h.deadline(c, h.timeouts.Redirect)
+}
+
+// apiDeadline bounds the time an API request may take, giving batch routes
+// longer and leaving streams unbounded
+func (h *Handler) apiDeadline(c *gin.Context) {
+	route := c.Request.Method + " " + c.FullPath()
+	switch {
+	case streamRoutes[route]:
+		c.Next()
+	case batchRoutes[route]:
+		h.deadline(c, h.timeouts.Batch)
+	default:
+		h.deadline(c, h.timeouts.API)
+	}
+}
+
+// deadline runs the rest of the chain with a context expiring after
+// timeout, answering 504 in place of whatever the handler answers once it
+// has expired
+func (h *Handler) deadline(c *gin.Context, timeout time.Duration) {
+	if timeout <= 0 {
+		c.Next()
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	writer := &deadlineWriter{ResponseWriter: c.Writer, ctx: ctx}
+	c.Request = c.Request.WithContext(ctx)
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	if writer.expired() {
+		c.Header("Location", "")
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Request timed out"})
+	}
+}
+
+// deadlineWriter drops the response of a handler that only answers after
+// its deadline, typically with the error of a cancelled Redis call
+type deadlineWriter struct {
+	gin.ResponseWriter
+	ctx     context.Context
+	dropped bool
+}
+
+// expired reports whether the deadline passed before the response started
+func (w *deadlineWriter) expired() bool {
+	if !w.dropped && !w.ResponseWriter.Written() && w.ctx.Err() == context.DeadlineExceeded {
+		w.dropped = true
+	}
+	return w.dropped
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *deadlineWriter) WriteHeaderNow() {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *deadlineWriter) Write(data []byte) (int, error) {
+	if w.expired() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *deadlineWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/notify"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// ToggleResponse represents the state of a link after disabling or enabling it
+type ToggleResponse struct {
+	ShortKey string `json:"short_key"`
+	Disabled bool   `json:"disabled"`
+}
+
+// DisableURL pauses a link's redirects while keeping its key and statistics
+func (h *Handler) DisableURL(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// EnableURL resumes the redirects of a disabled link
+func (h *Handler) EnableURL(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+// setDisabled stores the disabled toggle of the requested link
+func (h *Handler) setDisabled(c *gin.Context, disabled bool) {
+	key, rec := h.managedRecord(c)
+	if rec == nil {
+		return
+	}
+
+	if rec.Disabled != disabled {
+		before := h.snapshot(rec)
+		rec.Disabled = disabled
+		err := h.store.Update(c.Request.Context(), key, rec)
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+			return
+		}
+		h.purgeLink(key, rec)
+
+		action := storage.AuditEnable
+		if disabled {
+			action = storage.AuditDisable
+			h.notify(notify.Disabled, key, rec, disabledBy(c))
+		}
+		h.audit(c, action, key, before, rec)
+	}
+
+	c.JSON(http.StatusOK, ToggleResponse{ShortKey: key, Disabled: disabled})
+}
+
+// disabledBy describes who disabled a link, for its owners' notifications
+func disabledBy(c *gin.Context) string {
+	if actor := owner(c); actor != "" {
+		return "Disabled by " + actor
+	}
+	return ""
+}
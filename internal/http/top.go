@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultTopWindow and DefaultTopLimit are used when the request sets none
+	DefaultTopWindow = 24 * time.Hour
+	DefaultTopLimit  = 20
+
+	// MaxTopLimit is the most links a top links request returns
+	MaxTopLimit = 100
+)
+
+// TopLinksResponse represents the most clicked links of a window
+type TopLinksResponse struct {
+	Window string `json:"window"`
+	*storage.TopLinks
+}
+
+// WithTopLinks enables the top links endpoint for the operator dashboard
+func WithTopLinks(top storage.TopLinkStore) Option {
+	return func(h *Handler) {
+		h.topLinks = top
+	}
+}
+
+// GetTopLinks returns the links clicked most in the requested window, along
+// with the window's total clicks and number of clicked links
+func (h *Handler) GetTopLinks(c *gin.Context) {
+	window := DefaultTopWindow
+	if v := c.Query("window"); v != "" {
+		var err error
+		if window, err = time.ParseDuration(v); err != nil || window < time.Hour || window > storage.MaxTopWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window. Must be a duration from 1h to 168h"})
+			return
+		}
+	}
+
+	limit := DefaultTopLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxTopLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit. Must be from 1 to 100"})
+			return
+		}
+		limit = n
+	}
+
+	top, err := h.topLinks.TopLinks(c.Request.Context(), window, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TopLinksResponse{Window: window.String(), TopLinks: top})
+}
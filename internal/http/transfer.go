@@ -0,0 +1,380 @@
+package http
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// FormatJSONL and FormatCSV are the supported import and export formats
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+
+	// importBatchSize is the number of links created per store round trip
+	importBatchSize = 500
+
+	// exportFlushInterval is the number of links written between flushes
+	exportFlushInterval = 500
+
+	// maxImportErrors is the most failed rows reported in an import response
+	maxImportErrors = 100
+
+	// maxImportLine is the longest JSONL line accepted on import
+	maxImportLine = 1 << 20
+)
+
+// csvColumns is the header of CSV imports and exports
+var csvColumns = []string{"key", "url", "created_at", "expires_at", "tags"}
+
+// TransferRecord is a link as imported and exported. Tags are separated by
+// spaces in CSV.
+type TransferRecord struct {
+	Key       string     `json:"key"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Tags      []string   `json:"tags"`
+}
+
+// ImportResponse summarizes an import
+type ImportResponse struct {
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError reports a row that was not imported
+type ImportError struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+// WithImportExport enables the admin import and export endpoints
+func WithImportExport(bulk storage.BulkStore, exporter storage.ExportStore) Option {
+	return func(h *Handler) {
+		h.bulk = bulk
+		h.exporter = exporter
+	}
+}
+
+// transferFormat returns the format requested in the query string
+func transferFormat(c *gin.Context) (string, bool) {
+	switch format := c.DefaultQuery("format", FormatJSONL); format {
+	case FormatJSONL, FormatCSV:
+		return format, true
+	}
+	return "", false
+}
+
+// ImportURLs creates links from a CSV or JSONL request body, streaming it in
+// batches so large migrations don't have to fit in memory. Existing keys are
+// left untouched and reported as failed rows. Links without an expiry get
+// the default TTL.
+func (h *Handler) ImportURLs(c *gin.Context) {
+	format, ok := transferFormat(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be jsonl or csv"})
+		return
+	}
+
+	imp := &importer{h: h, c: c, owner: owner(c), workspace: workspace(c), upgraded: map[string]string{}}
+	var err error
+	if format == FormatCSV {
+		err = imp.readCSV(c.Request.Body)
+	} else {
+		err = imp.readJSONL(c.Request.Body)
+	}
+	if err == nil {
+		err = imp.flush()
+	}
+
+	var syntax *csv.ParseError
+	switch {
+	case errors.As(err, &syntax) || errors.Is(err, errMissingColumns) || errors.Is(err, bufio.ErrTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import: " + err.Error(), "imported": imp.resp.Imported})
+	case err != nil:
+		log.Printf("import failed after %d links: %v", imp.resp.Imported, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import links", "imported": imp.resp.Imported})
+	default:
+		c.JSON(http.StatusOK, imp.resp)
+	}
+}
+
+// errMissingColumns is returned for CSV imports without a key or url column
+var errMissingColumns = errors.New("header must name the key and url columns")
+
+// importer accumulates parsed rows into batches and tallies the outcome
+type importer struct {
+	h         *Handler
+	c         *gin.Context
+	owner     string
+	workspace string
+
+	batch []storage.BulkRecord
+	lines []int
+	resp  ImportResponse
+
+	// upgraded remembers the plain-HTTP destinations checked so far
+	upgraded map[string]string
+}
+
+// readJSONL parses one link per line, skipping blank lines
+func (imp *importer) readJSONL(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var row TransferRecord
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			imp.fail(line, "", "invalid JSON")
+			continue
+		}
+		if err := imp.add(line, row); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readCSV parses links from a CSV document whose header names its columns
+func (imp *importer) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["key"]; !ok {
+		return errMissingColumns
+	}
+	if _, ok := columns["url"]; !ok {
+		return errMissingColumns
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		row := TransferRecord{Key: field("key"), URL: field("url"), Tags: strings.Fields(field("tags"))}
+		if v := field("created_at"); v != "" {
+			if row.CreatedAt, err = time.Parse(time.RFC3339, v); err != nil {
+				imp.fail(line, row.Key, "invalid created_at")
+				continue
+			}
+		}
+		if v := field("expires_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				imp.fail(line, row.Key, "invalid expires_at")
+				continue
+			}
+			row.ExpiresAt = &t
+		}
+		if err := imp.add(line, row); err != nil {
+			return err
+		}
+	}
+}
+
+// add validates a row and queues it, writing the batch once it is full
+func (imp *importer) add(line int, row TransferRecord) error {
+	// Keys must be ones redirects resolve, so keys issued by other
+	// shorteners in their own format need custom keys enabled
+	row.Key = imp.h.foldKey(row.Key)
+	if !imp.h.validKey(row.Key) {
+		imp.fail(line, row.Key, "invalid key")
+		return nil
+	}
+	if !validDestination(row.URL) {
+		imp.fail(line, row.Key, "invalid url")
+		return nil
+	}
+	row.URL = canonicalDestination(row.URL)
+	if !imp.h.applyHTTPPolicy(imp.c.Request.Context(), []*string{&row.URL}, imp.upgraded) {
+		imp.fail(line, row.Key, "plain http not allowed")
+		return nil
+	}
+	tags, ok := normalizeTags(row.Tags)
+	if !ok {
+		imp.fail(line, row.Key, "invalid tags")
+		return nil
+	}
+	if row.CreatedAt.IsZero() {
+		row.CreatedAt = time.Now()
+	}
+
+	imp.batch = append(imp.batch, storage.BulkRecord{
+		Key: row.Key,
+		Record: &storage.Record{
+			URL:       row.URL,
+			Owner:     imp.owner,
+			Workspace: imp.workspace,
+			CreatedAt: row.CreatedAt.UTC(),
+			Tags:      tags,
+		},
+		ExpiresAt: row.ExpiresAt,
+	})
+	imp.lines = append(imp.lines, line)
+	if len(imp.batch) >= importBatchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+// flush creates the queued links
+func (imp *importer) flush() error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+
+	errs, err := imp.h.bulk.CreateMany(imp.c.Request.Context(), imp.batch)
+	if err != nil {
+		return err
+	}
+	var entries []*storage.AuditEntry
+	for i, err := range errs {
+		if err != nil {
+			imp.fail(imp.lines[i], imp.batch[i].Key, err.Error())
+			continue
+		}
+		imp.resp.Imported++
+		imp.h.meterUsage(imp.batch[i].Record, metering.LinksCreated)
+		if imp.h.auditLog != nil {
+			entries = append(entries, imp.h.auditEntry(imp.c, storage.AuditImport, imp.batch[i].Key, nil, imp.batch[i].Record))
+		}
+	}
+	imp.h.appendAudit(imp.c, entries...)
+
+	imp.batch = imp.batch[:0]
+	imp.lines = imp.lines[:0]
+	return nil
+}
+
+// fail records a row that was not imported
+func (imp *importer) fail(line int, key, reason string) {
+	imp.resp.Failed++
+	if len(imp.resp.Errors) < maxImportErrors {
+		imp.resp.Errors = append(imp.resp.Errors, ImportError{Line: line, Key: key, Error: reason})
+	}
+}
+
+// ExportURLs streams every link as CSV or JSONL
+func (h *Handler) ExportURLs(c *gin.Context) {
+	format, ok := transferFormat(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Must be jsonl or csv"})
+		return
+	}
+
+	// Headers are only committed once the first link is read, so a store
+	// failure up front still gets an error response
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(c.Writer)
+	written := 0
+	start := func() {
+		if format == FormatCSV {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", `attachment; filename="links.csv"`)
+			csvWriter = csv.NewWriter(c.Writer)
+			csvWriter.Write(csvColumns)
+		} else {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Header("Content-Disposition", `attachment; filename="links.jsonl"`)
+		}
+		c.Status(http.StatusOK)
+	}
+	flush := func() error {
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	err := h.exporter.Walk(c.Request.Context(), func(r storage.SearchResult) error {
+		if written == 0 {
+			start()
+		}
+		row := TransferRecord{
+			Key:       r.Key,
+			URL:       r.Record.URL,
+			CreatedAt: r.Record.CreatedAt,
+			ExpiresAt: r.ExpiresAt,
+			Tags:      r.Record.Tags,
+		}
+		if row.Tags == nil {
+			row.Tags = []string{}
+		}
+
+		var err error
+		if csvWriter != nil {
+			expiresAt := ""
+			if row.ExpiresAt != nil {
+				expiresAt = row.ExpiresAt.Format(time.RFC3339)
+			}
+			err = csvWriter.Write([]string{row.Key, row.URL, row.CreatedAt.Format(time.RFC3339), expiresAt, strings.Join(row.Tags, " ")})
+		} else {
+			err = encoder.Encode(row)
+		}
+		if err != nil {
+			return err
+		}
+
+		if written++; written%exportFlushInterval == 0 {
+			return flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err != nil && written == 0:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export links"})
+	case err != nil:
+		// The response is already committed; the client sees a truncated body
+		log.Printf("export failed after %d links: %v", written, err)
+	default:
+		if written == 0 {
+			start()
+		}
+		flush()
+	}
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/upgrade"
+)
+
+// HTTPPolicy selects what happens to destinations using plain HTTP
+type HTTPPolicy string
+
+// Supported plain-HTTP policies
+const (
+	// HTTPAllow stores plain-HTTP destinations as given, the default
+	HTTPAllow HTTPPolicy = "allow"
+
+	// HTTPUpgrade stores plain-HTTP destinations with https:// when their
+	// host is found to serve them over HTTPS
+	HTTPUpgrade HTTPPolicy = "upgrade"
+
+	// HTTPReject refuses plain-HTTP destinations
+	HTTPReject HTTPPolicy = "reject"
+)
+
+// WithHTTPDestinations sets the policy for plain-HTTP destinations. The
+// checker is only used to upgrade them.
+func WithHTTPDestinations(policy HTTPPolicy, checker *upgrade.Checker) Option {
+	return func(h *Handler) {
+		h.httpPolicy = policy
+		h.upgrader = checker
+	}
+}
+
+// ParseHTTPPolicy parses a plain-HTTP destination policy
+func ParseHTTPPolicy(spec string) (HTTPPolicy, error) {
+	switch policy := HTTPPolicy(spec); policy {
+	case HTTPAllow, HTTPUpgrade, HTTPReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown plain-HTTP policy %q", spec)
+}
+
+// secureDestinations applies the plain-HTTP policy to destinations,
+// upgrading them in place. It answers the request itself and returns false
+// if one is refused.
+func (h *Handler) secureDestinations(c *gin.Context, dests []*string) bool {
+	if !h.applyHTTPPolicy(c.Request.Context(), dests, map[string]string{}) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL. Plain HTTP destinations are not allowed, use https"})
+		return false
+	}
+	return true
+}
+
+// applyHTTPPolicy applies the plain-HTTP policy to destinations, upgrading
+// them in place. Each URL is checked once, however many destinations
+// repeat it, with the results kept in upgraded. It returns false if one is
+// refused.
+func (h *Handler) applyHTTPPolicy(ctx context.Context, dests []*string, upgraded map[string]string) bool {
+	switch h.httpPolicy {
+	case HTTPReject:
+		for _, dest := range dests {
+			if upgrade.IsPlainHTTP(*dest) {
+				return false
+			}
+		}
+	case HTTPUpgrade:
+		for _, dest := range dests {
+			if !upgrade.IsPlainHTTP(*dest) {
+				continue
+			}
+			if secure, ok := upgraded[*dest]; ok {
+				*dest = secure
+				continue
+			}
+			secure, _ := h.upgrader.Upgrade(ctx, *dest)
+			upgraded[*dest] = secure
+			*dest = secure
+		}
+	}
+	return true
+}
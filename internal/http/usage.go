@@ -0,0 +1,80 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/metering"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// maxUsageDays is the longest range of days the usage endpoint covers
+const maxUsageDays = 366
+
+// UsageResponse lists the daily usage of a range of days
+type UsageResponse struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Usage []storage.Usage `json:"usage"`
+}
+
+// WithUsageMetering counts links created, redirects served and analytics
+// events stored per workspace, or per owner outside workspaces, and serves
+// the daily totals to admins
+func WithUsageMetering(meter *metering.Meter, usage storage.UsageStore) Option {
+	return func(h *Handler) {
+		h.meter = meter
+		h.usage = usage
+	}
+}
+
+// meterUsage counts one of a metric against a link's scope
+func (h *Handler) meterUsage(rec *storage.Record, metric string) {
+	if h.meter != nil {
+		h.meter.Add(storage.ScopeOf(rec), metric, 1)
+	}
+}
+
+// GetUsage returns the daily usage from the from date to the to date, both
+// included, defaulting to the current month. The workspace, owner and
+// metric query parameters filter the usage returned.
+func (h *Handler) GetUsage(c *gin.Context) {
+	now := time.Now().UTC()
+	to := storage.GranularityDay.Truncate(now)
+	from := to.AddDate(0, 0, 1-to.Day())
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from. Must be a date like 2006-01-02"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.DateOnly, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to. Must be a date like 2006-01-02"})
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= maxUsageDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid range. from must not be after to and span at most 366 days"})
+		return
+	}
+
+	usage, err := h.usage.GetUsage(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve usage"})
+		return
+	}
+
+	response := UsageResponse{From: from.Format(time.DateOnly), To: to.Format(time.DateOnly), Usage: []storage.Usage{}}
+	workspace, owner, metric := c.Query("workspace"), c.Query("owner"), c.Query("metric")
+	for _, u := range usage {
+		if (workspace == "" || u.Workspace == workspace) && (owner == "" || u.Owner == owner) && (metric == "" || u.Metric == metric) {
+			response.Usage = append(response.Usage, u)
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/rules"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// V1Deprecated is when API v1 was deprecated in favor of v2. v1 keeps its
+// behavior until its sunset.
+var V1Deprecated = time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+// Link is the v2 representation of a stored link
+type Link struct {
+	ShortKey   string    `json:"short_key"`
+	ShortURL   string    `json:"short_url"`
+	URL        string    `json:"url"`
+	DisplayURL string    `json:"display_url,omitempty"`
+	Domain     string    `json:"domain,omitempty"`
+	Owner      string    `json:"owner,omitempty"`
+	Workspace  string    `json:"workspace,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	Disabled    bool       `json:"disabled"`
+	Unverified  bool       `json:"unverified,omitempty"`
+	Expiry      LinkExpiry `json:"expiry"`
+
+	QueryParams    map[string]string              `json:"query_params,omitempty"`
+	Rules          []rules.Rule                   `json:"rules,omitempty"`
+	DeviceRules    []storage.DeviceRule           `json:"device_rules,omitempty"`
+	LocaleRules    []storage.LocaleRule           `json:"locale_rules,omitempty"`
+	Variants       []storage.Variant              `json:"variants,omitempty"`
+	StickyVariants bool                           `json:"sticky_variants,omitempty"`
+	Fallbacks      []string                       `json:"fallbacks,omitempty"`
+	Down           []string                       `json:"down,omitempty"`
+	Schedule       []storage.ScheduledDestination `json:"schedule,omitempty"`
+
+	Tags        []string `json:"tags"`
+	Label       string   `json:"label,omitempty"`
+	Note        string   `json:"note,omitempty"`
+	Permanent   bool     `json:"permanent"`
+	CacheMaxAge *int     `json:"cache_max_age,omitempty"`
+	Signed      bool     `json:"signed,omitempty"`
+	SingleUse   bool     `json:"single_use,omitempty"`
+	MaxClicks   int      `json:"max_clicks,omitempty"`
+
+	Interstitial *storage.Interstitial `json:"interstitial,omitempty"`
+
+	// Aliases are the link's former keys, redirecting to it until their
+	// grace period is over
+	Aliases []storage.Alias `json:"aliases,omitempty"`
+
+	// Metadata is the destination's page metadata, once fetched
+	Metadata *storage.Preview `json:"metadata"`
+
+	Clicks *int64                    `json:"clicks,omitempty"`
+	Check  *storage.DestinationCheck `json:"check,omitempty"`
+}
+
+// LinkExpiry describes when a link expires
+type LinkExpiry struct {
+	// Policy is sliding if reads extend the link's lifetime
+	Policy storage.ExpiryPolicy `json:"policy,omitempty"`
+
+	// ExpiresAt is nil for links that never expire
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// LinkList is the v2 response to a link search
+type LinkList struct {
+	Links []Link `json:"links"`
+}
+
+// WithV1Sunset announces when API v1 stops being served, through the
+// Sunset header of every v1 response
+func WithV1Sunset(sunset time.Time) Option {
+	return func(h *Handler) {
+		h.v1Sunset = sunset
+	}
+}
+
+// setupV2 registers the v2 routes, which serve links as Link resources.
+// Request bodies are the same as in v1.
+func (h *Handler) setupV2(v2 *gin.RouterGroup) {
+	v2.GET("/links", h.ListLinks)
+	v2.POST("/links", h.dryRunnable(h.CreateLink)...)
+	v2.GET("/links/:key", h.GetLink)
+	v2.PATCH("/links/:key", h.editor(h.UpdateLink)...)
+	v2.DELETE("/links/:key", h.editor(h.DeleteURL)...)
+	v2.POST("/links/:key/clone", h.creation(h.CloneLink)...)
+	if h.verification != nil {
+		v2.GET("/links/:key/verify", h.rejectWhileReadOnly, h.VerifyLink)
+	}
+	if h.expiry != nil {
+		v2.POST("/links/:key/extend", h.editor(h.ExtendLink)...)
+	}
+	if h.aliases != nil {
+		v2.POST("/links/:key/rename", h.editor(h.RenameLink)...)
+	}
+}
+
+// deprecateV1 marks v1 responses as deprecated (RFC 9745), pointing clients
+// at v2 and, once scheduled, at the sunset (RFC 8594)
+func (h *Handler) deprecateV1(c *gin.Context) {
+	c.Header("Deprecation", "@"+strconv.FormatInt(V1Deprecated.Unix(), 10))
+	c.Header("Link", `</api/v2>; rel="successor-version"`)
+	if !h.v1Sunset.IsZero() {
+		c.Header("Sunset", h.v1Sunset.UTC().Format(http.TimeFormat))
+	}
+	c.Next()
+}
+
+// ListLinks returns links filtered like ListURLs
+func (h *Handler) ListLinks(c *gin.Context) {
+	results, ok := h.searchLinks(c)
+	if !ok {
+		return
+	}
+
+	response := LinkList{Links: make([]Link, 0, len(results))}
+	for _, r := range results {
+		details := linkResponse(r.Key, r.Record)
+		details.ExpiresAt = r.ExpiresAt
+		response.Links = append(response.Links, h.link(details, r.Record))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateLink handles link creation, answering with the created Link
+func (h *Handler) CreateLink(c *gin.Context) {
+	key, rec, ok := h.createLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	if dryRun(c) {
+		link := h.link(details, rec)
+		if key == "" {
+			link.ShortURL = ""
+		}
+		c.JSON(http.StatusOK, link)
+		return
+	}
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusCreated, h.link(details, rec))
+}
+
+// GetLink returns a Link
+func (h *Handler) GetLink(c *gin.Context) {
+	if details, rec, ok := h.linkDetails(c); ok {
+		writeConditionalJSON(c, http.StatusOK, h.link(details, rec))
+	}
+}
+
+// UpdateLink handles changes to a link, answering with the updated Link
+func (h *Handler) UpdateLink(c *gin.Context) {
+	key, rec, ok := h.updateLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusOK, h.link(details, rec))
+}
+
+// CloneLink handles link cloning, answering with the clone's Link
+func (h *Handler) CloneLink(c *gin.Context) {
+	key, rec, ok := h.cloneLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusCreated, h.link(details, rec))
+}
+
+// link builds the Link resource of a link from its v1 details
+func (h *Handler) link(details LinkResponse, rec *storage.Record) Link {
+	return Link{
+		ShortKey:       details.ShortKey,
+		ShortURL:       h.linkURL(details.ShortKey, rec),
+		URL:            details.URL,
+		DisplayURL:     details.DisplayURL,
+		Domain:         details.Domain,
+		Owner:          details.Owner,
+		Workspace:      details.Workspace,
+		CreatedAt:      details.CreatedAt,
+		ActiveFrom:     details.ActiveFrom,
+		ActiveUntil:    details.ActiveUntil,
+		Disabled:       details.Disabled,
+		Unverified:     details.Unverified,
+		Expiry:         LinkExpiry{Policy: details.Expiry, ExpiresAt: details.ExpiresAt},
+		QueryParams:    details.QueryParams,
+		Rules:          details.Rules,
+		DeviceRules:    details.DeviceRules,
+		LocaleRules:    details.LocaleRules,
+		Variants:       details.Variants,
+		StickyVariants: details.StickyVariants,
+		Fallbacks:      details.Fallbacks,
+		Down:           details.Down,
+		Schedule:       details.Schedule,
+		Tags:           details.Tags,
+		Label:          details.Label,
+		Note:           details.Note,
+		Permanent:      details.Permanent,
+		CacheMaxAge:    details.CacheMaxAge,
+		Signed:         details.Signed,
+		SingleUse:      details.SingleUse,
+		MaxClicks:      details.MaxClicks,
+		Interstitial:   details.Interstitial,
+		Aliases:        details.Aliases,
+		Metadata:       rec.Preview,
+		Clicks:         details.Clicks,
+		Check:          details.Check,
+	}
+}
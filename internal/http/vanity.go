@@ -0,0 +1,322 @@
+package http
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultSuggestions and MaxSuggestions bound the number of suggested keys
+	DefaultSuggestions = 5
+	MaxSuggestions     = 20
+
+	// maxSuggestionCandidates is the most candidates checked per request
+	maxSuggestionCandidates = 100
+
+	// minVanityKeyLength and maxVanityKeyLength bound the length of custom
+	// keys
+	minVanityKeyLength = 3
+	maxVanityKeyLength = 64
+
+	// maxSlugLength leaves room for a numeric suffix within a vanity key
+	maxSlugLength = 56
+
+	// maxSuffixAttempts bounds the suffixed keys tried for a taken custom
+	// key, of which the last randomSuffixAttempts get random suffixes
+	maxSuffixAttempts    = 12
+	randomSuffixAttempts = 4
+)
+
+// Policies for a requested custom key that is already taken
+const (
+	// ConflictError answers 409 Conflict, the default
+	ConflictError = "error"
+
+	// ConflictSuggest answers 409 Conflict with available alternatives
+	ConflictSuggest = "suggest"
+
+	// ConflictSuffix creates the link under the key with a short suffix
+	ConflictSuffix = "suffix"
+)
+
+// suffixAlphabet is used for random suffixes, which start with a letter so
+// they don't read like the numeric ones
+const suffixAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// vanityKeyPattern accepts custom keys: 3 to 64 letters, digits, '-' or
+// '_', starting with a letter or digit. Colons are reserved for auxiliary
+// keys.
+var vanityKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{` +
+	strconv.Itoa(minVanityKeyLength-1) + `,` + strconv.Itoa(maxVanityKeyLength-1) + `}$`)
+
+// invalidVanityKey is the error for custom keys vanityKeyPattern refuses
+var invalidVanityKey = "Invalid key. Must be " + strconv.Itoa(minVanityKeyLength) + " to " +
+	strconv.Itoa(maxVanityKeyLength) + " letters, digits, '-' or '_'"
+
+// reservedKeys can't be chosen as custom keys, so they stay free for
+// top-level routes
+var reservedKeys = map[string]bool{
+	"admin":  true,
+	"api":    true,
+	"app":    true,
+	"assets": true,
+	"debug":  true,
+	"health": true,
+	"static": true,
+}
+
+// KeySuggestionsResponse represents available custom keys derived from a hint
+type KeySuggestionsResponse struct {
+	Hint        string   `json:"hint"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// Reasons a custom key isn't available
+const (
+	// KeyInvalid keys aren't well-formed custom keys
+	KeyInvalid = "invalid"
+
+	// KeyReserved keys are kept free for top-level routes
+	KeyReserved = "reserved"
+
+	// KeyTaken keys hold a link or landing page
+	KeyTaken = "taken"
+)
+
+// KeyAvailabilityResponse represents whether a custom key can be chosen.
+// For taken keys, Expired is set if the link holding the key is past its
+// active window, and Owned if the caller may manage what holds it.
+type KeyAvailabilityResponse struct {
+	Key       string `json:"key"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+	Expired   bool   `json:"expired,omitempty"`
+	Owned     bool   `json:"owned,omitempty"`
+}
+
+// WithVanityKeys lets links be created under custom keys, and enables
+// suggesting available ones
+func WithVanityKeys(checker storage.KeyChecker) Option {
+	return func(h *Handler) {
+		h.vanity = checker
+	}
+}
+
+// validVanityKey checks a requested custom key
+func validVanityKey(key string) bool {
+	return vanityKeyPattern.MatchString(key) && !reservedKeys[strings.ToLower(key)]
+}
+
+// slugify turns a hint into a lowercase key of letters, digits and single
+// hyphens
+func slugify(hint string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(hint) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+		if b.Len() >= maxSlugLength {
+			break
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// suggestionCandidates lists keys derived from a slug, most readable first:
+// the slug itself, the slug without hyphens, then numeric suffixes
+func suggestionCandidates(slug string) []string {
+	candidates := make([]string, 0, maxSuggestionCandidates)
+	add := func(key string) {
+		if validVanityKey(key) && len(candidates) < maxSuggestionCandidates {
+			candidates = append(candidates, key)
+		}
+	}
+
+	add(slug)
+	if compact := strings.ReplaceAll(slug, "-", ""); compact != slug {
+		add(compact)
+	}
+	for n := 2; len(candidates) < maxSuggestionCandidates; n++ {
+		add(slug + "-" + strconv.Itoa(n))
+	}
+	return candidates
+}
+
+// SuggestKeys returns available custom keys derived from the hint query
+// parameter, checked against the store
+func (h *Handler) SuggestKeys(c *gin.Context) {
+	hint := c.Query("hint")
+	slug := slugify(hint)
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hint. Must contain letters or digits"})
+		return
+	}
+
+	count := DefaultSuggestions
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxSuggestions {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid count. Must be from 1 to 20"})
+			return
+		}
+		count = n
+	}
+
+	suggestions, err := h.availableKeys(c.Request.Context(), suggestionCandidates(slug), count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check keys"})
+		return
+	}
+	c.JSON(http.StatusOK, KeySuggestionsResponse{Hint: hint, Suggestions: suggestions})
+}
+
+// KeyAvailability reports whether a custom key can be chosen, and if not,
+// why. Malformed keys are answered like unavailable ones rather than with
+// 400, as they are checked while being typed.
+func (h *Handler) KeyAvailability(c *gin.Context) {
+	key := h.foldKey(c.Param("key"))
+	response := KeyAvailabilityResponse{Key: key}
+	switch {
+	case reservedKeys[strings.ToLower(key)]:
+		response.Reason = KeyReserved
+	case !validVanityKey(key):
+		response.Reason = KeyInvalid
+	}
+	if response.Reason != "" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	ctx := c.Request.Context()
+	rec, err := h.store.GetRecord(ctx, key)
+	switch err {
+	case nil:
+		response.Reason = KeyTaken
+		response.Expired = rec.CheckActive(time.Now()) == storage.ErrNoLongerActive
+		response.Owned = h.canManage(c, rec)
+		c.JSON(http.StatusOK, response)
+		return
+	case storage.ErrNotFound:
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check key"})
+		return
+	}
+
+	if h.landingPages != nil {
+		page, err := h.landingPages.GetLandingPage(ctx, key)
+		switch err {
+		case nil:
+			response.Reason = KeyTaken
+			response.Owned = h.ownedBy(c, page.Owner, page.Workspace)
+			c.JSON(http.StatusOK, response)
+			return
+		case storage.ErrLandingPageNotFound:
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check key"})
+			return
+		}
+	}
+
+	response.Available = true
+	c.JSON(http.StatusOK, response)
+}
+
+// availableKeys returns up to count of the candidates that are free, in
+// order. Candidates are checked in small batches, stopping once enough are
+// free.
+func (h *Handler) availableKeys(ctx context.Context, candidates []string, count int) ([]string, error) {
+	available := []string{}
+	for len(candidates) > 0 && len(available) < count {
+		batch := candidates[:min(len(candidates), count+2)]
+		candidates = candidates[len(batch):]
+
+		taken, err := h.vanity.KeysExist(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, key := range batch {
+			if !taken[i] && len(available) < count {
+				available = append(available, key)
+			}
+		}
+	}
+	return available, nil
+}
+
+// validConflictPolicy checks the on_conflict field of a create request
+func validConflictPolicy(policy string) bool {
+	switch policy {
+	case "", ConflictError, ConflictSuggest, ConflictSuffix:
+		return true
+	}
+	return false
+}
+
+// suffixCandidates lists keys for a taken custom key: numeric suffixes
+// first, then random two-character ones like "-x9". Keys that would be too
+// long are left out.
+func suffixCandidates(key string) []string {
+	candidates := make([]string, 0, maxSuffixAttempts)
+	add := func(k string) {
+		if validVanityKey(k) {
+			candidates = append(candidates, k)
+		}
+	}
+	for n := 2; n < 2+maxSuffixAttempts-randomSuffixAttempts; n++ {
+		add(key + "-" + strconv.Itoa(n))
+	}
+	for range randomSuffixAttempts {
+		add(key + "-" + string(suffixAlphabet[rand.IntN(26)]) + string(suffixAlphabet[rand.IntN(len(suffixAlphabet))]))
+	}
+	return candidates
+}
+
+// keyConflict applies the on_conflict policy to a taken custom key. With
+// the suffix policy, the link is created under the first free suffixed
+// key, which is returned. Otherwise, or if none is free, the response is
+// written and false returned.
+func (h *Handler) keyConflict(c *gin.Context, key, policy string, rec *storage.Record) (string, bool) {
+	ctx := c.Request.Context()
+	switch policy {
+	case ConflictSuggest:
+		suggestions, err := h.availableKeys(ctx, suffixCandidates(key), DefaultSuggestions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check keys"})
+			return "", false
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Key is already taken", "suggestions": suggestions})
+		return "", false
+
+	case ConflictSuffix:
+		// Create is atomic, so a suffixed key taken concurrently is skipped
+		for _, candidate := range suffixCandidates(key) {
+			err := h.store.Create(ctx, candidate, rec)
+			if err == nil {
+				return candidate, true
+			}
+			if err != storage.ErrKeyExists {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store URL"})
+				return "", false
+			}
+		}
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Key is already taken"})
+	return "", false
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/mail"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// DefaultVerificationTTL is how long a verification email's link is valid
+const DefaultVerificationTTL = 24 * time.Hour
+
+// EmailVerification holds anonymous creations until their creator follows
+// the link emailed to them. Links carry a token signed with Secret, valid
+// for TTL.
+type EmailVerification struct {
+	Sender mail.Sender
+	Secret []byte
+	TTL    time.Duration
+}
+
+// WithEmailVerification requires anonymous callers to pass their email with
+// every link creation. Links are created unverified, answering 403 instead
+// of redirecting, until the creator follows the link emailed to them.
+// Authenticated callers are trusted.
+func WithEmailVerification(v EmailVerification) Option {
+	return func(h *Handler) {
+		if v.TTL <= 0 {
+			v.TTL = DefaultVerificationTTL
+		}
+		h.verification = &v
+	}
+}
+
+// holdForVerification marks an anonymous caller's new link unverified,
+// recording the email to verify, answering the request itself if the email
+// is missing or invalid
+func (h *Handler) holdForVerification(c *gin.Context, email string, rec *storage.Record) bool {
+	rec.Email = ""
+	rec.Unverified = false
+	if h.verification == nil || auth.PrincipalFrom(c) != nil {
+		return true
+	}
+
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+		return false
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Name != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return false
+	}
+	rec.Email = addr.Address
+	rec.Unverified = true
+	return true
+}
+
+// sendVerification emails the creator of an unverified link the link to
+// verify it. The email is sent in the background, logging failures, so a
+// slow mail server doesn't hold up the creation.
+func (h *Handler) sendVerification(key string, rec *storage.Record) {
+	if h.verification == nil || !rec.Unverified {
+		return
+	}
+
+	expires := time.Now().Add(h.verification.TTL)
+	link := h.baseURL + "/api/v1/urls/" + url.PathEscape(key) + "/verify?token=" +
+		url.QueryEscape(h.verificationToken(key, rec.Email, expires))
+	m := mail.Message{
+		To:      rec.Email,
+		Subject: "Confirm your short link",
+		Text: fmt.Sprintf("Your short link %s to %s is waiting for you to confirm your email.\n\n"+
+			"Follow this link to activate it:\n%s\n\n"+
+			"The link is valid until %s. If you didn't create this short link, ignore this email.",
+			h.linkURL(key, rec), rec.URL, link, expires.UTC().Format(time.RFC1123)),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mail.DefaultTimeout)
+		defer cancel()
+		if err := h.verification.Sender.Send(ctx, m); err != nil {
+			log.Printf("failed to send verification email for %s: %v", key, err)
+		}
+	}()
+}
+
+// verificationToken signs the verification of a link's email until expires.
+// The token is the expiry in Unix seconds and the signature, dot separated.
+func (h *Handler) verificationToken(key, email string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return unix + "." + h.verificationSignature(key, email, unix)
+}
+
+// verificationSignature is the HMAC-SHA256 of the link, email and expiry
+func (h *Handler) verificationSignature(key, email, expires string) string {
+	mac := hmac.New(sha256.New, h.verification.Secret)
+	mac.Write([]byte(key + "\x00" + email + "\x00" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validVerificationToken reports whether token verifies the link's email
+// and hasn't expired
+func (h *Handler) validVerificationToken(key, email, token string, now time.Time) bool {
+	expires, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	want := h.verificationSignature(key, email, expires)
+	return hmac.Equal([]byte(signature), []byte(want))
+}
+
+// VerifyURL activates an unverified link, answering with the link
+func (h *Handler) VerifyURL(c *gin.Context) {
+	if key, rec, ok := h.verifyLink(c); ok {
+		c.JSON(http.StatusOK, h.urlResponse(c, key, rec))
+	}
+}
+
+// VerifyLink activates an unverified link, answering with its Link
+func (h *Handler) VerifyLink(c *gin.Context) {
+	key, rec, ok := h.verifyLink(c)
+	if !ok {
+		return
+	}
+	details := linkResponse(key, rec)
+	details.ExpiresAt, _ = h.store.ExpiresAt(c.Request.Context(), key)
+	c.JSON(http.StatusOK, h.link(details, rec))
+}
+
+// verifyLink checks the token emailed to the creator of the requested link
+// and activates the link, answering the request itself if it can't.
+// Verifying a link again is harmless.
+func (h *Handler) verifyLink(c *gin.Context) (string, *storage.Record, bool) {
+	key := c.Param("key")
+	if !h.validKey(key) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL key format"})
+		return "", nil, false
+	}
+
+	rec, err := h.store.GetRecord(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return "", nil, false
+	}
+	if rec.Email == "" || !h.validVerificationToken(key, rec.Email, c.Query("token"), time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired verification token"})
+		return "", nil, false
+	}
+	if !rec.Unverified {
+		return key, rec, true
+	}
+
+	before := h.snapshot(rec)
+	rec.Unverified = false
+	err = h.store.Update(c.Request.Context(), key, rec)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return "", nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update URL"})
+		return "", nil, false
+	}
+	h.purgeLink(key, rec)
+	h.audit(c, storage.AuditVerify, key, before, rec)
+
+	return key, rec, true
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRobotsTxt lets crawlers index the root page but not resolve short
+// keys, which would inflate click statistics
+const DefaultRobotsTxt = `User-agent: *
+Allow: /$
+Disallow: /
+`
+
+// WellKnownConfig holds the documents served for crawlers and security
+// researchers. An empty SecurityTxt leaves /.well-known/security.txt
+// unserved.
+type WellKnownConfig struct {
+	RobotsTxt   string
+	SecurityTxt string
+}
+
+// SecurityTxtFields are the fields of a generated security.txt (RFC 9116)
+type SecurityTxtFields struct {
+	// Contact lists URIs for reporting vulnerabilities, such as
+	// mailto:security@example.com
+	Contact []string
+
+	// Expires is when the document should no longer be trusted
+	Expires time.Time
+
+	// Policy and Canonical are optional URLs of the disclosure policy and
+	// of the document itself
+	Policy    string
+	Canonical string
+}
+
+// WithWellKnown serves robots.txt and security.txt
+func WithWellKnown(cfg WellKnownConfig) Option {
+	return func(h *Handler) {
+		h.wellKnown = cfg
+	}
+}
+
+// FormatSecurityTxt generates a security.txt document
+func FormatSecurityTxt(f SecurityTxtFields) string {
+	var b strings.Builder
+	for _, contact := range f.Contact {
+		b.WriteString("Contact: " + contact + "\n")
+	}
+	b.WriteString("Expires: " + f.Expires.UTC().Format(time.RFC3339) + "\n")
+	if f.Policy != "" {
+		b.WriteString("Policy: " + f.Policy + "\n")
+	}
+	if f.Canonical != "" {
+		b.WriteString("Canonical: " + f.Canonical + "\n")
+	}
+	return b.String()
+}
+
+// ParseContacts parses a comma-separated list of security contact URIs
+func ParseContacts(spec string) []string {
+	var contacts []string
+	for _, contact := range strings.Split(spec, ",") {
+		if contact = strings.TrimSpace(contact); contact != "" {
+			contacts = append(contacts, contact)
+		}
+	}
+	return contacts
+}
+
+// RobotsTxt serves the crawler rules
+func (h *Handler) RobotsTxt(c *gin.Context) {
+	robots := h.wellKnown.RobotsTxt
+	if robots == "" {
+		robots = DefaultRobotsTxt
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.String(http.StatusOK, robots)
+}
+
+// SecurityTxt serves the security contact document
+func (h *Handler) SecurityTxt(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.String(http.StatusOK, h.wellKnown.SecurityTxt)
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/auth"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// WithWorkspaces enables the workspace statistics endpoint
+func WithWorkspaces(ws storage.WorkspaceStore) Option {
+	return func(h *Handler) {
+		h.workspaces = ws
+	}
+}
+
+// GetWorkspaceStats returns the number of links and total clicks of a workspace
+func (h *Handler) GetWorkspaceStats(c *gin.Context) {
+	name, ok := h.workspaceName(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.workspaces.GetWorkspaceStats(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// workspaceName validates the requested workspace and the caller's access
+// to it, answering the request itself if it can't be used
+func (h *Handler) workspaceName(c *gin.Context) (string, bool) {
+	name := c.Param("workspace")
+	if !auth.ValidWorkspace(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace name"})
+		return "", false
+	}
+	if !h.canAccessWorkspace(c, name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only workspace members or an admin may do this"})
+		return "", false
+	}
+	return name, true
+}
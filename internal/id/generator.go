@@ -3,6 +3,9 @@ package id
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
 )
 
@@ -10,41 +13,117 @@ const (
 	// Base62Chars contains all characters used in base62 encoding
 	Base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 
+	// Base36Chars contains the characters of case-insensitive keys
+	Base36Chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	// UnambiguousChars leaves out characters readers confuse: 0/O and 1/l/I
+	UnambiguousChars = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+	// UnambiguousBase36Chars leaves the same characters out of
+	// case-insensitive keys, along with o and i
+	UnambiguousBase36Chars = "23456789abcdefghjkmnpqrstuvwxyz"
+
 	// KeyLength is the length of generated keys
 	KeyLength = 8
 )
 
+// Alphabet names a profile of characters keys are generated from
+type Alphabet string
+
+const (
+	// AlphabetDefault uses every letter and digit
+	AlphabetDefault Alphabet = "default"
+
+	// AlphabetUnambiguous leaves out visually confusable characters
+	AlphabetUnambiguous Alphabet = "unambiguous"
+)
+
+// ErrUnknownAlphabet is returned for unsupported alphabet profiles
+var ErrUnknownAlphabet = errors.New("alphabet must be default or unambiguous")
+
+// ParseAlphabet parses an alphabet profile name
+func ParseAlphabet(s string) (Alphabet, error) {
+	switch a := Alphabet(s); a {
+	case AlphabetDefault, AlphabetUnambiguous:
+		return a, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownAlphabet, s)
+}
+
+// KeyGenerator issues new keys and recognizes the keys it may have issued
+type KeyGenerator interface {
+	Generate() (string, error)
+	ValidateKey(key string) bool
+}
+
 // Generator handles the generation of unique IDs
 type Generator struct {
-	chars string
+	chars  string
+	reader io.Reader
+
+	// accept holds the characters of valid keys. It keeps the full alphabet
+	// when generating from a profile, so keys issued before still validate.
+	accept string
+
+	// foldCase accepts keys in any case, for base36 keys
+	foldCase bool
 }
 
 // NewGenerator creates a new ID generator
 func NewGenerator() *Generator {
 	return &Generator{
-		chars: Base62Chars,
+		chars:  Base62Chars,
+		reader: rand.Reader,
+		accept: Base62Chars,
+	}
+}
+
+// NewCaseInsensitiveGenerator creates a new ID generator issuing lowercase
+// base36 keys, which survive being read out or typed in any case
+func NewCaseInsensitiveGenerator() *Generator {
+	return &Generator{
+		chars:    Base36Chars,
+		reader:   rand.Reader,
+		accept:   Base36Chars,
+		foldCase: true,
+	}
+}
+
+// UseAlphabet switches the characters new keys are generated from.
+// Keys generated before remain valid.
+func (g *Generator) UseAlphabet(a Alphabet) {
+	switch {
+	case a == AlphabetUnambiguous && g.foldCase:
+		g.chars = UnambiguousBase36Chars
+	case a == AlphabetUnambiguous:
+		g.chars = UnambiguousChars
+	case g.foldCase:
+		g.chars = Base36Chars
+	default:
+		g.chars = Base62Chars
 	}
 }
 
-// Generate creates a new random base62 encoded ID
+// Generate creates a new random ID in the generator's alphabet
 func (g *Generator) Generate() (string, error) {
 	// Generate 48 bits (6 bytes) of random data
 	buf := make([]byte, 6)
-	if _, err := rand.Read(buf); err != nil {
+	if _, err := io.ReadFull(g.reader, buf); err != nil {
 		return "", err
 	}
 
 	// Convert to uint64 for easier manipulation
 	num := binary.BigEndian.Uint64(append(make([]byte, 2), buf...))
 
-	// Convert to base62
+	// Convert to the generator's base
+	base := uint64(len(g.chars))
 	var builder strings.Builder
 	builder.Grow(KeyLength)
 
 	// Fill the key to exact length
 	for i := 0; i < KeyLength; i++ {
-		builder.WriteByte(g.chars[num%62])
-		num /= 62
+		builder.WriteByte(g.chars[num%base])
+		num /= base
 	}
 
 	return builder.String(), nil
@@ -55,9 +134,12 @@ func (g *Generator) ValidateKey(key string) bool {
 	if len(key) != KeyLength {
 		return false
 	}
+	if g.foldCase {
+		key = strings.ToLower(key)
+	}
 
 	for _, c := range key {
-		if !strings.ContainsRune(g.chars, c) {
+		if !strings.ContainsRune(g.accept, c) {
 			return false
 		}
 	}
@@ -3,9 +3,11 @@ package id
 import (
 	"crypto/rand"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewGenerator(t *testing.T) {
@@ -103,6 +105,66 @@ func TestGenerator_Generate_Distribution(t *testing.T) {
 	assert.Equal(t, len(Base62Chars), len(charCount), "Unexpected characters in generated keys")
 }
 
+func TestCaseInsensitiveGenerator(t *testing.T) {
+	g := NewCaseInsensitiveGenerator()
+
+	for i := 0; i < 100; i++ {
+		key, err := g.Generate()
+		require.NoError(t, err)
+		assert.Len(t, key, KeyLength)
+		assert.Equal(t, strings.ToLower(key), key)
+		assert.True(t, g.ValidateKey(key))
+		assert.True(t, g.ValidateKey(strings.ToUpper(key)))
+	}
+	assert.False(t, g.ValidateKey("abcd-123"))
+	assert.False(t, NewGenerator().ValidateKey("abcd-123"))
+}
+
+func TestGenerator_UseAlphabet(t *testing.T) {
+	t.Run("unambiguous", func(t *testing.T) {
+		g := NewGenerator()
+		g.UseAlphabet(AlphabetUnambiguous)
+
+		for i := 0; i < 200; i++ {
+			key, err := g.Generate()
+			require.NoError(t, err)
+			assert.Len(t, key, KeyLength)
+			assert.False(t, strings.ContainsAny(key, "0O1lI"), "key %q has a confusable character", key)
+			assert.True(t, g.ValidateKey(key))
+		}
+		assert.True(t, g.ValidateKey("0Ol1I234"), "keys from the full alphabet stay valid")
+		assert.False(t, g.ValidateKey("abcd-123"))
+	})
+
+	t.Run("case insensitive", func(t *testing.T) {
+		g := NewCaseInsensitiveGenerator()
+		g.UseAlphabet(AlphabetUnambiguous)
+
+		for i := 0; i < 200; i++ {
+			key, err := g.Generate()
+			require.NoError(t, err)
+			assert.False(t, strings.ContainsAny(key, "0o1li"), "key %q has a confusable character", key)
+		}
+		assert.True(t, g.ValidateKey("0OL1I234"))
+	})
+
+	t.Run("back to default", func(t *testing.T) {
+		g := NewGenerator()
+		g.UseAlphabet(AlphabetUnambiguous)
+		g.UseAlphabet(AlphabetDefault)
+		assert.Equal(t, Base62Chars, g.chars)
+	})
+}
+
+func TestParseAlphabet(t *testing.T) {
+	a, err := ParseAlphabet("unambiguous")
+	require.NoError(t, err)
+	assert.Equal(t, AlphabetUnambiguous, a)
+
+	_, err = ParseAlphabet("base58")
+	assert.ErrorIs(t, err, ErrUnknownAlphabet)
+}
+
 func TestGenerator_Generate_RandomError(t *testing.T) {
 	// Replace rand.Reader with a reader that always fails
 	originalReader := rand.Reader
@@ -0,0 +1,104 @@
+package id
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	// DefaultHashKeyLength is the default length of hash-derived keys
+	DefaultHashKeyLength = 10
+
+	// MinHashKeyLength is the shortest hash-derived key allowed
+	MinHashKeyLength = 6
+
+	// MaxHashKeyLength is the longest hash-derived key allowed, bounded by
+	// the 256 bits of the digest
+	MaxHashKeyLength = 40
+
+	// MaxHashKeyExtension is how many characters a key may be extended by,
+	// one at a time, when its truncated hash collides with another input's
+	MaxHashKeyExtension = 4
+)
+
+// ErrInvalidKeyLength is returned when a hash key length is out of range
+var ErrInvalidKeyLength = errors.New("hash key length out of range")
+
+// HashGenerator derives keys from a salted hash of their input, so the same
+// input always maps to the same key on every instance sharing the salt
+type HashGenerator struct {
+	salt   []byte
+	length int
+	chars  string
+}
+
+// NewHashGenerator creates a new hash-based key generator
+func NewHashGenerator(salt string, length int) (*HashGenerator, error) {
+	if length < MinHashKeyLength || length > MaxHashKeyLength {
+		return nil, ErrInvalidKeyLength
+	}
+	return &HashGenerator{
+		salt:   []byte(salt),
+		length: length,
+		chars:  Base62Chars,
+	}, nil
+}
+
+// Key returns the base62 key derived from the input
+func (g *HashGenerator) Key(input string) string {
+	return g.derive(input, g.length)
+}
+
+// Keys returns the key derived from the input followed by its extensions,
+// each one character longer than the last. Every key is a prefix of the
+// next, so inputs whose shorter keys collide are told apart by a longer one.
+func (g *HashGenerator) Keys(input string) []string {
+	full := g.derive(input, g.maxLength())
+	keys := make([]string, 0, len(full)-g.length+1)
+	for n := g.length; n <= len(full); n++ {
+		keys = append(keys, full[:n])
+	}
+	return keys
+}
+
+// maxLength is the length of the longest extension of a key
+func (g *HashGenerator) maxLength() int {
+	return min(g.length+MaxHashKeyExtension, MaxHashKeyLength)
+}
+
+// derive returns the first n base62 digits of the input's salted hash
+func (g *HashGenerator) derive(input string, n int) string {
+	mac := hmac.New(sha256.New, g.salt)
+	mac.Write([]byte(input))
+	num := new(big.Int).SetBytes(mac.Sum(nil))
+
+	base := big.NewInt(int64(len(g.chars)))
+	digit := new(big.Int)
+
+	var builder strings.Builder
+	builder.Grow(n)
+	for i := 0; i < n; i++ {
+		num.DivMod(num, base, digit)
+		builder.WriteByte(g.chars[digit.Int64()])
+	}
+	return builder.String()
+}
+
+// ValidateKey checks if a key could have been derived by this generator,
+// including extended keys
+func (g *HashGenerator) ValidateKey(key string) bool {
+	if len(key) < g.length || len(key) > g.maxLength() {
+		return false
+	}
+
+	for _, c := range key {
+		if !strings.ContainsRune(g.chars, c) {
+			return false
+		}
+	}
+
+	return true
+}
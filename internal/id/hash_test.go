@@ -0,0 +1,80 @@
+package id
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHashGenerator(t *testing.T) {
+	_, err := NewHashGenerator("salt", MinHashKeyLength-1)
+	assert.Equal(t, ErrInvalidKeyLength, err)
+
+	_, err = NewHashGenerator("salt", MaxHashKeyLength+1)
+	assert.Equal(t, ErrInvalidKeyLength, err)
+
+	g, err := NewHashGenerator("salt", DefaultHashKeyLength)
+	require.NoError(t, err)
+	assert.NotNil(t, g)
+}
+
+func TestHashGenerator_Key(t *testing.T) {
+	g, err := NewHashGenerator("salt", DefaultHashKeyLength)
+	require.NoError(t, err)
+
+	key := g.Key("https://example.com/")
+	assert.Len(t, key, DefaultHashKeyLength)
+	assert.True(t, g.ValidateKey(key))
+
+	// The same input always maps to the same key
+	assert.Equal(t, key, g.Key("https://example.com/"))
+
+	// Another instance sharing the salt derives the same key
+	other, err := NewHashGenerator("salt", DefaultHashKeyLength)
+	require.NoError(t, err)
+	assert.Equal(t, key, other.Key("https://example.com/"))
+
+	// Different inputs and salts derive different keys
+	assert.NotEqual(t, key, g.Key("https://example.org/"))
+	salted, err := NewHashGenerator("pepper", DefaultHashKeyLength)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, salted.Key("https://example.com/"))
+
+	// Longest keys still use the full alphabet
+	long, err := NewHashGenerator("salt", MaxHashKeyLength)
+	require.NoError(t, err)
+	assert.True(t, long.ValidateKey(long.Key("https://example.com/")))
+}
+
+func TestHashGenerator_Keys(t *testing.T) {
+	g, err := NewHashGenerator("salt", DefaultHashKeyLength)
+	require.NoError(t, err)
+
+	keys := g.Keys("https://example.com/")
+	require.Len(t, keys, MaxHashKeyExtension+1)
+	assert.Equal(t, g.Key("https://example.com/"), keys[0])
+	for i, key := range keys {
+		assert.Len(t, key, DefaultHashKeyLength+i)
+		assert.True(t, g.ValidateKey(key))
+		if i > 0 {
+			assert.Equal(t, keys[i-1], key[:len(key)-1])
+		}
+	}
+
+	// Keys are never extended past the longest length
+	long, err := NewHashGenerator("salt", MaxHashKeyLength-1)
+	require.NoError(t, err)
+	assert.Len(t, long.Keys("https://example.com/"), 2)
+}
+
+func TestHashGenerator_ValidateKey(t *testing.T) {
+	g, err := NewHashGenerator("salt", 8)
+	require.NoError(t, err)
+
+	assert.True(t, g.ValidateKey("aB1cD2eF"))
+	assert.True(t, g.ValidateKey("aB1cD2eF9"))
+	assert.False(t, g.ValidateKey("aB1cD2e"))
+	assert.False(t, g.ValidateKey("aB1cD2eF9xyzw"))
+	assert.False(t, g.ValidateKey("aB1cD2e!"))
+}
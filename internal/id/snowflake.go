@@ -0,0 +1,127 @@
+package id
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SnowflakeKeyLength is the length of Snowflake keys, enough for 63 bits
+	SnowflakeKeyLength = 11
+
+	// MaxWorkerID is the largest worker ID a Snowflake key can carry
+	MaxWorkerID = 1<<workerBits - 1
+
+	workerBits   = 10
+	sequenceBits = 12
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+// SnowflakeEpoch is the start of Snowflake timestamps, which last about 69
+// years from it
+var SnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ErrInvalidWorkerID is returned for worker IDs outside 0 to MaxWorkerID
+var ErrInvalidWorkerID = errors.New("worker id out of range")
+
+// SnowflakeGenerator issues keys from a millisecond timestamp, the
+// instance's worker ID and a per-millisecond sequence, Snowflake-style.
+// Instances with distinct worker IDs never issue the same key, and keys
+// sort by creation time.
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	chars    string
+	worker   int64
+	last     int64
+	sequence int64
+	now      func() time.Time
+}
+
+// NewSnowflakeGenerator creates a new Snowflake key generator for a worker
+func NewSnowflakeGenerator(worker int) (*SnowflakeGenerator, error) {
+	if worker < 0 || worker > MaxWorkerID {
+		return nil, ErrInvalidWorkerID
+	}
+	return &SnowflakeGenerator{chars: Base62Chars, worker: int64(worker), now: time.Now}, nil
+}
+
+// UseAlphabet switches the characters new keys are generated from. Both
+// alphabets are in ascending order, so keys keep sorting by creation time
+// within each, and keys generated before remain valid.
+func (g *SnowflakeGenerator) UseAlphabet(a Alphabet) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.chars = Base62Chars
+	if a == AlphabetUnambiguous {
+		g.chars = UnambiguousChars
+	}
+}
+
+// Generate issues the next key. Up to 4096 keys are issued per millisecond;
+// beyond that, and while the clock runs behind the last key issued, it
+// waits for the clock to catch up.
+func (g *SnowflakeGenerator) Generate() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := g.millis()
+	if ms == g.last {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			ms = g.waitAfter(g.last)
+		}
+	} else {
+		if ms < g.last {
+			ms = g.waitAfter(g.last - 1)
+		}
+		g.sequence = 0
+	}
+	g.last = ms
+
+	num := uint64(ms)<<(workerBits+sequenceBits) | uint64(g.worker)<<sequenceBits | uint64(g.sequence)
+
+	// Most significant digit first, so keys sort like their numbers
+	base := uint64(len(g.chars))
+	key := make([]byte, SnowflakeKeyLength)
+	for i := SnowflakeKeyLength - 1; i >= 0; i-- {
+		key[i] = g.chars[num%base]
+		num /= base
+	}
+	return string(key), nil
+}
+
+// millis returns the milliseconds elapsed since the epoch
+func (g *SnowflakeGenerator) millis() int64 {
+	return g.now().Sub(SnowflakeEpoch).Milliseconds()
+}
+
+// waitAfter waits until the clock passes the given millisecond
+func (g *SnowflakeGenerator) waitAfter(ms int64) int64 {
+	for {
+		now := g.millis()
+		if now > ms {
+			return now
+		}
+		time.Sleep(time.Duration(ms-now+1) * time.Millisecond / 2)
+	}
+}
+
+// ValidateKey checks if a key could have been issued by this generator, or
+// by the random generator before switching to it, so existing links keep
+// resolving
+func (g *SnowflakeGenerator) ValidateKey(key string) bool {
+	if len(key) != SnowflakeKeyLength && len(key) != KeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		if !strings.ContainsRune(Base62Chars, c) {
+			return false
+		}
+	}
+
+	return true
+}
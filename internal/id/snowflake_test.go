@@ -0,0 +1,95 @@
+package id
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSnowflakeGenerator(t *testing.T) {
+	_, err := NewSnowflakeGenerator(-1)
+	assert.Equal(t, ErrInvalidWorkerID, err)
+
+	_, err = NewSnowflakeGenerator(MaxWorkerID + 1)
+	assert.Equal(t, ErrInvalidWorkerID, err)
+
+	g, err := NewSnowflakeGenerator(MaxWorkerID)
+	require.NoError(t, err)
+	assert.NotNil(t, g)
+}
+
+func TestSnowflakeGenerator_Generate(t *testing.T) {
+	now := SnowflakeEpoch.Add(100 * 24 * time.Hour)
+	clock := func() time.Time { return now }
+
+	a, err := NewSnowflakeGenerator(1)
+	require.NoError(t, err)
+	a.now = clock
+	b, err := NewSnowflakeGenerator(2)
+	require.NoError(t, err)
+	b.now = clock
+
+	// Keys are unique across workers within the same millisecond
+	seen := make(map[string]bool)
+	var keys []string
+	for i := 0; i < 100; i++ {
+		for _, g := range []*SnowflakeGenerator{a, b} {
+			key, err := g.Generate()
+			require.NoError(t, err)
+			assert.Len(t, key, SnowflakeKeyLength)
+			assert.True(t, g.ValidateKey(key))
+			assert.False(t, seen[key], key)
+			seen[key] = true
+		}
+		key, err := a.Generate()
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	// Keys sort by creation time, even when the clock steps back
+	now = now.Add(time.Millisecond)
+	later, err := a.Generate()
+	require.NoError(t, err)
+	calls := 0
+	a.now = func() time.Time {
+		if calls++; calls == 1 {
+			return now.Add(-time.Second)
+		}
+		return now.Add(time.Millisecond)
+	}
+	latest, err := a.Generate()
+	require.NoError(t, err)
+	keys = append(keys, later, latest)
+	assert.True(t, sort.StringsAreSorted(keys))
+}
+
+func TestSnowflakeGenerator_ValidateKey(t *testing.T) {
+	g, err := NewSnowflakeGenerator(0)
+	require.NoError(t, err)
+
+	assert.True(t, g.ValidateKey("0Ab3Kd9x2Qz"))
+	assert.True(t, g.ValidateKey("Ab3Kd9x2"), "random keys from before the switch stay valid")
+	assert.False(t, g.ValidateKey("0Ab3Kd9x2Q"))
+	assert.False(t, g.ValidateKey("0Ab3Kd9x2Q!"))
+}
+
+func TestSnowflakeGenerator_UseAlphabet(t *testing.T) {
+	g, err := NewSnowflakeGenerator(1)
+	require.NoError(t, err)
+	g.UseAlphabet(AlphabetUnambiguous)
+
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key, err := g.Generate()
+		require.NoError(t, err)
+		assert.Len(t, key, SnowflakeKeyLength)
+		assert.False(t, strings.ContainsAny(key, "0O1lI"), "key %q has a confusable character", key)
+		keys = append(keys, key)
+	}
+	assert.True(t, sort.StringsAreSorted(keys))
+	assert.True(t, g.ValidateKey("0Ab3Kd9x2Qz"), "keys from the full alphabet stay valid")
+}
@@ -0,0 +1,232 @@
+// Package jobs runs background jobs on schedules, keeping a history of
+// their runs in the store and letting admins run them on demand.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/lock"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// recordTimeout bounds recording a run once its job ends, even during
+// shutdown
+const recordTimeout = 5 * time.Second
+
+var (
+	// ErrDuplicateJob is returned when registering a job under a name
+	// already taken
+	ErrDuplicateJob = errors.New("job already registered")
+
+	// ErrUnknownJob is returned for jobs that aren't registered
+	ErrUnknownJob = errors.New("unknown job")
+
+	// ErrJobRunning is returned when triggering a job this instance is
+	// already running
+	ErrJobRunning = errors.New("job already running")
+
+	// errLockHeld records manual runs skipped because another instance
+	// was running the job
+	errLockHeld = errors.New("skipped: another instance is running the job")
+)
+
+// Func runs a job once, returning how many items it processed
+type Func func(ctx context.Context) (int, error)
+
+// Info describes a registered job
+type Info struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Running  bool   `json:"running"`
+
+	// NextRun is when the job runs next, unset until the scheduler starts
+	// and for schedules that never run again
+	NextRun *time.Time `json:"next_run,omitempty"`
+
+	// LastRun is the job's latest run on any instance
+	LastRun *storage.JobRun `json:"last_run,omitempty"`
+}
+
+// job is a registered job
+type job struct {
+	name     string
+	schedule Schedule
+	fn       Func
+	lock     *lock.Lock
+	running  atomic.Bool
+
+	// next is guarded by the scheduler's mutex
+	next time.Time
+}
+
+// Scheduler runs registered jobs on their schedules. Runs of a job never
+// overlap on an instance, and jobs registered with a lock run on one
+// instance at a time.
+type Scheduler struct {
+	store storage.JobStore
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	ctx  context.Context
+	runs sync.WaitGroup
+}
+
+// NewScheduler creates a Scheduler recording runs in store
+func NewScheduler(store storage.JobStore) *Scheduler {
+	return &Scheduler{store: store, jobs: make(map[string]*job), ctx: context.Background()}
+}
+
+// Register adds a job running fn on schedule, under l if it is set. Jobs
+// are registered before the scheduler runs.
+func (s *Scheduler) Register(name string, schedule Schedule, fn Func, l *lock.Lock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return ErrDuplicateJob
+	}
+	s.jobs[name] = &job{name: name, schedule: schedule, fn: fn, lock: l}
+	return nil
+}
+
+// Run runs the registered jobs on their schedules until ctx is done, then
+// waits for the runs in progress to stop
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	for _, j := range s.jobs {
+		s.runs.Add(1)
+		go func() {
+			defer s.runs.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+	s.runs.Wait()
+}
+
+// loop runs a job each time its schedule comes up until ctx is done
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		s.mu.Lock()
+		j.next = next
+		s.mu.Unlock()
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if j.running.CompareAndSwap(false, true) {
+			s.run(ctx, j, storage.JobScheduled)
+		}
+	}
+}
+
+// Trigger starts a run of a job now, in the background. Its outcome is
+// recorded in the job's history like any other run.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+	if !j.running.CompareAndSwap(false, true) {
+		return ErrJobRunning
+	}
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.run(s.ctx, j, storage.JobManual)
+	}()
+	return nil
+}
+
+// run runs a job once and records the run. Scheduled runs skipped because
+// another instance holds the job's lock aren't recorded, since every other
+// instance would record one each time the job comes up. The caller marks
+// the job running.
+func (s *Scheduler) run(ctx context.Context, j *job, trigger storage.JobTrigger) {
+	defer j.running.Store(false)
+
+	run := storage.JobRun{Job: j.name, Trigger: trigger, StartedAt: time.Now().UTC()}
+	ran, err := j.lock.Do(ctx, func(ctx context.Context) error {
+		n, err := j.fn(ctx)
+		run.Processed = n
+		return err
+	})
+	if !ran && err == nil {
+		if trigger == storage.JobScheduled {
+			return
+		}
+		err = errLockHeld
+	}
+	run.FinishedAt = time.Now().UTC()
+	if err != nil {
+		run.Error = err.Error()
+		if ctx.Err() == nil {
+			log.Printf("job %s failed: %v", j.name, err)
+		}
+	}
+
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	if err := s.store.RecordJobRun(recordCtx, &run); err != nil {
+		log.Printf("failed to record run of job %s: %v", j.name, err)
+	}
+}
+
+// Jobs describes the registered jobs, sorted by name
+func (s *Scheduler) Jobs(ctx context.Context) ([]Info, error) {
+	s.mu.Lock()
+	infos := make([]Info, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		info := Info{Name: j.name, Schedule: j.schedule.String(), Running: j.running.Load()}
+		if !j.next.IsZero() {
+			next := j.next
+			info.NextRun = &next
+		}
+		infos = append(infos, info)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	for i := range infos {
+		runs, err := s.store.JobRuns(ctx, infos[i].Name, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			infos[i].LastRun = &runs[0]
+		}
+	}
+	return infos, nil
+}
+
+// Runs returns up to n of a job's latest runs on any instance, newest first
+func (s *Scheduler) Runs(ctx context.Context, name string, n int) ([]storage.JobRun, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return s.store.JobRuns(ctx, name, n)
+}
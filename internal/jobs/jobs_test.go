@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// memoryStore keeps run history in memory, newest first
+type memoryStore struct {
+	mu   sync.Mutex
+	runs map[string][]storage.JobRun
+}
+
+func (m *memoryStore) RecordJobRun(_ context.Context, run *storage.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[run.Job] = append([]storage.JobRun{*run}, m.runs[run.Job]...)
+	return nil
+}
+
+func (m *memoryStore) JobRuns(_ context.Context, job string, n int) ([]storage.JobRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := m.runs[job]
+	return runs[:min(n, len(runs))], nil
+}
+
+func TestScheduler_Run(t *testing.T) {
+	store := &memoryStore{runs: make(map[string][]storage.JobRun)}
+	s := NewScheduler(store)
+
+	ran := make(chan struct{}, 10)
+	require.NoError(t, s.Register("tick", Every(10*time.Millisecond), func(context.Context) (int, error) {
+		ran <- struct{}{}
+		return 3, nil
+	}, nil))
+	assert.ErrorIs(t, s.Register("tick", Every(time.Minute), nil, nil), ErrDuplicateJob)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("job didn't run on schedule")
+		}
+	}
+	cancel()
+	<-done
+
+	runs, err := s.Runs(context.Background(), "tick", 10)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(runs), 2)
+	assert.Equal(t, storage.JobScheduled, runs[0].Trigger)
+	assert.Equal(t, 3, runs[0].Processed)
+	assert.Empty(t, runs[0].Error)
+
+	_, err = s.Runs(context.Background(), "missing", 10)
+	assert.ErrorIs(t, err, ErrUnknownJob)
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	store := &memoryStore{runs: make(map[string][]storage.JobRun)}
+	s := NewScheduler(store)
+
+	release := make(chan struct{})
+	failed := errors.New("destination unreachable")
+	require.NoError(t, s.Register("check", Every(time.Hour), func(context.Context) (int, error) {
+		<-release
+		return 1, failed
+	}, nil))
+
+	assert.ErrorIs(t, s.Trigger("missing"), ErrUnknownJob)
+	require.NoError(t, s.Trigger("check"))
+	assert.ErrorIs(t, s.Trigger("check"), ErrJobRunning)
+
+	infos, err := s.Jobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "check", infos[0].Name)
+	assert.Equal(t, "@every 1h0m0s", infos[0].Schedule)
+	assert.True(t, infos[0].Running)
+	assert.Nil(t, infos[0].LastRun)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		infos, err := s.Jobs(context.Background())
+		return err == nil && !infos[0].Running && infos[0].LastRun != nil
+	}, time.Second, 5*time.Millisecond)
+
+	infos, err = s.Jobs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, storage.JobManual, infos[0].LastRun.Trigger)
+	assert.Equal(t, 1, infos[0].LastRun.Processed)
+	assert.Equal(t, failed.Error(), infos[0].LastRun.Error)
+}
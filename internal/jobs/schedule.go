@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxScheduleSearch bounds the search for a cron schedule's next time, so
+// schedules that never match, such as February 30th, don't loop forever
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// ErrInvalidSchedule is returned for schedules that can't be parsed
+var ErrInvalidSchedule = errors.New("schedule must be @every <duration>, @hourly, @daily, @weekly or a 5-field cron expression")
+
+// Schedule tells when a job runs next
+type Schedule interface {
+	// Next returns the first time after t the job runs, or the zero time
+	// if it never does
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// Every returns a schedule running a job every interval
+func Every(interval time.Duration) Schedule {
+	return every(interval)
+}
+
+// every runs a job at a fixed interval
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func (e every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+// cron runs a job at the minutes matching each of its fields, in UTC. Each
+// field is a bit set of the values it matches.
+type cron struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	anyDayOfMonth, anyDayOfWeek   bool
+}
+
+// cronFields are the bounds of the fields of a cron expression
+var cronFields = [5]struct{ min, max int }{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0 and 7 both being Sunday
+}
+
+// ParseSchedule parses @every <duration>, @hourly, @daily, @weekly, or a
+// cron expression of minute, hour, day of month, month and day of week.
+// Cron fields take *, values, ranges, lists and steps such as */15, in UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	}
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidSchedule
+		}
+		return Every(d), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, ErrInvalidSchedule
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSchedule, field)
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cron{
+		spec:          spec,
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+// ParseSchedules parses a semicolon-separated list of job schedules, such
+// as "link-rot=0 3 * * *;rollups=@every 5m", into schedules by job name
+func ParseSchedules(spec string) (map[string]Schedule, error) {
+	schedules := make(map[string]Schedule)
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, scheduleSpec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSchedule, entry)
+		}
+		schedule, err := ParseSchedule(scheduleSpec)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		schedules[name] = schedule
+	}
+	return schedules, nil
+}
+
+// parseCronField parses a comma-separated list of *, values and ranges,
+// each optionally stepped, into the set of values it matches
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepSpec, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step <= 0 {
+				return 0, ErrInvalidSchedule
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loSpec, hiSpec, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loSpec); err != nil {
+				return 0, ErrInvalidSchedule
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiSpec); err != nil {
+					return 0, ErrInvalidSchedule
+				}
+			} else if stepped {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, ErrInvalidSchedule
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether a day matches the day fields. As in cron, a
+// day matches either restricted field when both are.
+func (c *cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDayOfMonth && c.anyDayOfWeek:
+		return true
+	case c.anyDayOfMonth:
+		return dow
+	case c.anyDayOfWeek:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2026, time.March, 14, 10, 7, 30, 0, time.UTC) // a Saturday
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 90s", from.Add(90 * time.Second)},
+		{"@hourly", time.Date(2026, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.March, 14, 10, 15, 0, 0, time.UTC)},
+		{"30 3 * * 1-5", time.Date(2026, time.March, 16, 3, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * 6 7", time.Date(2026, time.June, 7, 12, 0, 0, 0, time.UTC)},
+		// Either restricted day field matches
+		{"0 0 20 * 0", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(from))
+		})
+	}
+
+	for _, spec := range []string{"", "@every", "@every -1m", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseSchedule(spec)
+		assert.ErrorIs(t, err, ErrInvalidSchedule, spec)
+	}
+}
+
+func TestParseSchedules(t *testing.T) {
+	schedules, err := ParseSchedules("link-rot=0 3 * * *; rollups=@every 5m;")
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	assert.Equal(t, "0 3 * * *", schedules["link-rot"].String())
+	assert.Equal(t, "@every 5m0s", schedules["rollups"].String())
+
+	_, err = ParseSchedules("rollups")
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+	_, err = ParseSchedules("rollups=sometimes")
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+}
@@ -0,0 +1,113 @@
+package kgs
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultRefillInterval is the default time between pool level checks
+	DefaultRefillInterval = time.Second
+
+	// popTimeout bounds how long issuing a key waits on the pool before
+	// falling back to generating one
+	popTimeout = 100 * time.Millisecond
+
+	// refillBatchSize is the number of keys checked and pushed per round trip
+	refillBatchSize = 500
+)
+
+// poolStats counts keys issued from the pool and generated on a miss,
+// published at /debug/vars
+var poolStats = expvar.NewMap("key_pool")
+
+// Pool issues keys pre-generated into a shared Redis list, so creating a
+// link takes one atomic pop instead of generating keys until one is free.
+// It implements id.KeyGenerator and falls back to its generator whenever
+// the pool is empty or unreachable.
+type Pool struct {
+	store    storage.KeyPoolStore
+	gen      id.KeyGenerator
+	size     int
+	interval time.Duration
+}
+
+// New creates a new Pool keeping about size keys in store, generated by gen
+func New(store storage.KeyPoolStore, gen id.KeyGenerator, size int, interval time.Duration) *Pool {
+	if interval <= 0 {
+		interval = DefaultRefillInterval
+	}
+	return &Pool{store: store, gen: gen, size: size, interval: interval}
+}
+
+// Generate issues a key from the pool, or from the generator on a miss
+func (p *Pool) Generate() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), popTimeout)
+	defer cancel()
+
+	key, err := p.store.PopKey(ctx)
+	if err == nil {
+		poolStats.Add("hits", 1)
+		return key, nil
+	}
+	poolStats.Add("misses", 1)
+	return p.gen.Generate()
+}
+
+// ValidateKey checks if a key could have been issued by the generator
+func (p *Pool) ValidateKey(key string) bool {
+	return p.gen.ValidateKey(key)
+}
+
+// Refill tops the pool up to its size once it has drained below half,
+// returning how many keys it added. Instances refilling together may
+// overshoot the size, which only costs memory.
+func (p *Pool) Refill(ctx context.Context) (int, error) {
+	n, err := p.store.KeyPoolSize(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n >= int64(p.size/2) {
+		return 0, nil
+	}
+
+	added := 0
+	for missing := p.size - int(n); missing > 0; {
+		batch := make([]string, min(missing, refillBatchSize))
+		for i := range batch {
+			if batch[i], err = p.gen.Generate(); err != nil {
+				return added, err
+			}
+		}
+		pushed, err := p.store.PushKeys(ctx, batch)
+		added += pushed
+		if err != nil {
+			return added, err
+		}
+		missing -= len(batch)
+	}
+	return added, nil
+}
+
+// Run keeps the pool topped up until ctx is done
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.Refill(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("key pool refill failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
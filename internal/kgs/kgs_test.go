@@ -0,0 +1,61 @@
+package kgs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/id"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+func TestPool(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	gen := id.NewGenerator()
+	pool := New(store, gen, 10, time.Second)
+
+	added, err := pool.Refill(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 10, added)
+
+	// Nothing is added until the pool drains below half
+	seen := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		key, err := pool.Generate()
+		require.NoError(t, err)
+		assert.True(t, pool.ValidateKey(key))
+		assert.False(t, seen[key])
+		seen[key] = true
+	}
+	added, err = pool.Refill(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, added)
+
+	key, err := pool.Generate()
+	require.NoError(t, err)
+	assert.False(t, seen[key])
+	added, err = pool.Refill(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 6, added)
+
+	// Keys already holding a link are never pooled
+	require.NoError(t, store.Set(ctx, "taken123", "https://example.com"))
+	pushed, err := store.PushKeys(ctx, []string{"taken123", "free1234"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pushed)
+
+	// An empty pool falls back to the generator
+	require.NoError(t, store.FlushDB(ctx))
+	_, err = store.PopKey(ctx)
+	assert.Equal(t, storage.ErrKeyPoolEmpty, err)
+	key, err = pool.Generate()
+	require.NoError(t, err)
+	assert.True(t, gen.ValidateKey(key))
+}
@@ -0,0 +1,134 @@
+// Package lock runs jobs under locks shared by every instance, so
+// maintenance work runs on one replica at a time.
+package lock
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTTL is how long a lock outlives its holder, bounding how long a
+	// job pauses after the instance running it dies
+	DefaultTTL = 30 * time.Second
+
+	// releaseTimeout bounds releasing a lock after its job ends
+	releaseTimeout = 5 * time.Second
+)
+
+// lockStats counts, per lock, the runs that took it, the runs skipped
+// because another instance held it, the locks lost mid-run and the
+// failures to take them, published at /debug/vars
+var lockStats = expvar.NewMap("locks")
+
+// statsMu serializes creating the stats of a lock
+var statsMu sync.Mutex
+
+// Store takes named locks shared by instances. Holders are told apart by
+// the token they were given, so only they can renew or release a lock.
+type Store interface {
+	AcquireLock(ctx context.Context, name string, ttl time.Duration) (string, error)
+	RenewLock(ctx context.Context, name, token string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name, token string) error
+}
+
+// Lock is a named lock held by one instance at a time. A nil Lock is
+// always free, for jobs running on a single instance.
+type Lock struct {
+	store Store
+	name  string
+	ttl   time.Duration
+	stats *expvar.Map
+}
+
+// New creates a Lock named name that outlives a dead holder by ttl
+func New(store Store, name string, ttl time.Duration) *Lock {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Lock{store: store, name: name, ttl: ttl, stats: stats(name)}
+}
+
+// Do runs fn while holding the lock, renewing it every third of its TTL.
+// It reports false without running fn if another instance holds the lock.
+// Should the lock be lost mid-run, fn's context is cancelled.
+func (l *Lock) Do(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	if l == nil {
+		return true, fn(ctx)
+	}
+	token, err := l.store.AcquireLock(ctx, l.name, l.ttl)
+	if err != nil {
+		l.stats.Add("errors", 1)
+		return false, err
+	}
+	if token == "" {
+		l.stats.Add("contended", 1)
+		return false, nil
+	}
+	l.stats.Add("acquired", 1)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		l.renew(runCtx, token, cancel)
+	}()
+	err = fn(runCtx)
+	cancel()
+	<-renewed
+
+	releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancelRelease()
+	if err := l.store.ReleaseLock(releaseCtx, l.name, token); err != nil {
+		log.Printf("failed to release lock %s: %v", l.name, err)
+	}
+	return true, err
+}
+
+// renew extends the lock until ctx is done. Renewals failing are retried
+// while the lock lasts; once it is lost, the run is cancelled.
+func (l *Lock) renew(ctx context.Context, token string, cancel context.CancelFunc) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	expires := time.Now().Add(l.ttl)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		ok, err := l.store.RenewLock(ctx, l.name, token, l.ttl)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err == nil && ok:
+			expires = start.Add(l.ttl)
+			continue
+		case err != nil && time.Now().Before(expires):
+			log.Printf("failed to renew lock %s: %v", l.name, err)
+			continue
+		}
+		log.Printf("lost lock %s; stopping its job", l.name)
+		l.stats.Add("lost", 1)
+		cancel()
+		return
+	}
+}
+
+// stats returns the counters of a lock, creating them on first use
+func stats(name string) *expvar.Map {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	if m, ok := lockStats.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map)
+	lockStats.Set(name, m)
+	return m
+}
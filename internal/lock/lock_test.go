@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore holds locks in memory. Setting lost makes renewals fail as if
+// another instance took the lock.
+type memoryStore struct {
+	mu      sync.Mutex
+	holders map[string]string
+	lost    bool
+}
+
+func (m *memoryStore) AcquireLock(_ context.Context, name string, _ time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holders[name] != "" {
+		return "", nil
+	}
+	m.holders[name] = "token-" + name
+	return m.holders[name], nil
+}
+
+func (m *memoryStore) RenewLock(_ context.Context, name, token string, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.lost && m.holders[name] == token, nil
+}
+
+func (m *memoryStore) ReleaseLock(_ context.Context, name, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holders[name] == token {
+		delete(m.holders, name)
+	}
+	return nil
+}
+
+func TestLock_Do(t *testing.T) {
+	store := &memoryStore{holders: make(map[string]string)}
+	first := New(store, "test-do", time.Minute)
+	second := New(store, "test-do", time.Minute)
+	ctx := context.Background()
+
+	failed := errors.New("job failed")
+	ran, err := first.Do(ctx, func(ctx context.Context) error {
+		// Another instance finds the lock taken and skips its run
+		ran, err := second.Do(ctx, func(context.Context) error {
+			t.Error("ran while the lock was held")
+			return nil
+		})
+		assert.False(t, ran)
+		assert.NoError(t, err)
+		return failed
+	})
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, failed)
+
+	// The lock is released once the job ends
+	ran, err = second.Do(ctx, func(context.Context) error { return nil })
+	assert.True(t, ran)
+	assert.NoError(t, err)
+
+	stats := lockStats.Get("test-do").String()
+	assert.JSONEq(t, `{"acquired": 2, "contended": 1}`, stats)
+
+	// Jobs without a lock always run
+	var unlocked *Lock
+	ran, err = unlocked.Do(ctx, func(context.Context) error { return nil })
+	assert.True(t, ran)
+	assert.NoError(t, err)
+}
+
+func TestLock_Lost(t *testing.T) {
+	store := &memoryStore{holders: make(map[string]string), lost: true}
+	l := New(store, "test-lost", 30*time.Millisecond)
+
+	ran, err := l.Do(context.Background(), func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	assert.True(t, ran)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.JSONEq(t, `{"acquired": 1, "lost": 1}`, lockStats.Get("test-lost").String())
+}
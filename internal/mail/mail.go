@@ -0,0 +1,80 @@
+// Package mail sends transactional email to users, such as link creators
+// confirming their address
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the default time allowed to send a message
+const DefaultTimeout = 10 * time.Second
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Sender delivers email
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SMTP sends email through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it. Username and Password are optional;
+// when set, PLAIN authentication is used. Amazon SES is reached through its
+// SMTP interface, such as email-smtp.us-east-1.amazonaws.com:587, with SMTP
+// credentials generated for it.
+type SMTP struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send mails the message
+func (s SMTP) Send(ctx context.Context, m Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, s.message(m, time.Now()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message formats the message as an RFC 5322 message
+func (s SMTP) message(m Message, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + headerValue(s.From) + "\r\n")
+	b.WriteString("To: " + headerValue(m.To) + "\r\n")
+	b.WriteString("Subject: " + headerValue(m.Subject) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks, so a value can't inject headers
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
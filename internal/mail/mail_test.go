@@ -0,0 +1,23 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTP_Message(t *testing.T) {
+	s := SMTP{From: "shortener@example.com"}
+	msg := string(s.message(Message{
+		To:      "alice@example.com\r\nBcc: attacker@example.com",
+		Subject: "Confirm your short link",
+		Text:    "line one\nline two",
+	}, time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)))
+
+	assert.Contains(t, msg, "From: shortener@example.com\r\n")
+	assert.Contains(t, msg, "To: alice@example.com  Bcc: attacker@example.com\r\n", "line breaks can't inject headers")
+	assert.Contains(t, msg, "Date: Thu, 15 Oct 2026 09:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nline one\r\nline two\r\n"))
+}
@@ -0,0 +1,305 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/prayushdave/url-shortener/internal/netguard"
+	"github.com/prayushdave/url-shortener/internal/queue"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultQueueSize is the default number of pending fetches before new ones are dropped
+	DefaultQueueSize = 1000
+
+	// QueueName identifies the preview queue in the dropped items metric
+	QueueName = "previews"
+
+	// DefaultTimeout is the default time allowed to fetch a page
+	DefaultTimeout = 10 * time.Second
+
+	// maxBodySize is the most of a page read while looking for metadata
+	maxBodySize = 1 << 20
+
+	// maxTitleLength and maxDescriptionLength bound the cached text
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+
+	// userAgent identifies the fetcher to destination sites
+	userAgent = "url-shortener-preview/1.0"
+)
+
+// Errors returned by the fetcher
+var (
+	ErrNotHTML          = errors.New("destination is not an html page")
+	ErrPrivateAddress   = netguard.ErrPrivateAddress
+	ErrUnexpectedStatus = errors.New("destination returned an unexpected status")
+)
+
+// Fetcher reads Open Graph and Twitter Card metadata from web pages
+type Fetcher struct {
+	// Client is used for requests. The default client refuses to connect to
+	// loopback, private and link-local addresses.
+	Client *http.Client
+}
+
+// Fetch downloads a page and extracts its preview metadata
+func (f Fetcher) Fetch(ctx context.Context, pageURL string) (*storage.Preview, error) {
+	client := f.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return nil, ErrNotHTML
+	}
+
+	p := Parse(io.LimitReader(resp.Body, maxBodySize), resp.Request.URL)
+	p.FetchedAt = time.Now().UTC()
+	return p, nil
+}
+
+// Parse extracts preview metadata from the head of an HTML document. Open
+// Graph tags take precedence over Twitter Card tags, which take precedence
+// over the document title and description. Relative image URLs are resolved
+// against base.
+func Parse(r io.Reader, base *url.URL) *storage.Preview {
+	var og, twitter, plain storage.Preview
+	z := html.NewTokenizer(r)
+	inTitle := false
+
+parse:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break parse
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				if hasAttr {
+					parseMeta(z, &og, &twitter, &plain)
+				}
+			case "body":
+				break parse
+			}
+		case html.TextToken:
+			if inTitle && plain.Title == "" {
+				plain.Title = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break parse
+			}
+		}
+	}
+
+	return &storage.Preview{
+		Title:       truncate(first(og.Title, twitter.Title, plain.Title), maxTitleLength),
+		Description: truncate(first(og.Description, twitter.Description, plain.Description), maxDescriptionLength),
+		Image:       resolve(base, first(og.Image, twitter.Image)),
+		SiteName:    og.SiteName,
+	}
+}
+
+// parseMeta records the content of a meta tag under the matching source
+func parseMeta(z *html.Tokenizer, og, twitter, plain *storage.Preview) {
+	var property, content string
+	for {
+		key, val, more := z.TagAttr()
+		switch string(key) {
+		case "property", "name":
+			if property == "" {
+				property = strings.ToLower(string(val))
+			}
+		case "content":
+			content = strings.TrimSpace(string(val))
+		}
+		if !more {
+			break
+		}
+	}
+
+	switch property {
+	case "og:title":
+		og.Title = content
+	case "og:description":
+		og.Description = content
+	case "og:image", "og:image:url":
+		if og.Image == "" {
+			og.Image = content
+		}
+	case "og:site_name":
+		og.SiteName = content
+	case "twitter:title":
+		twitter.Title = content
+	case "twitter:description":
+		twitter.Description = content
+	case "twitter:image", "twitter:image:src":
+		twitter.Image = content
+	case "description":
+		plain.Description = content
+	}
+}
+
+// Prefetcher fetches previews in the background and caches them on the record
+type Prefetcher struct {
+	fetcher Fetcher
+	store   storage.PreviewStore
+	timeout time.Duration
+	queue   *queue.Queue[job]
+}
+
+// job is a destination waiting to be fetched for a key
+type job struct {
+	key string
+	url string
+}
+
+// NewPrefetcher creates a new Prefetcher and starts its worker. Each fetch
+// is abandoned after timeout.
+func NewPrefetcher(fetcher Fetcher, store storage.PreviewStore, queueSize int, timeout time.Duration) *Prefetcher {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	p := &Prefetcher{
+		fetcher: fetcher,
+		store:   store,
+		timeout: timeout,
+	}
+	p.queue = queue.New(QueueName, queueSize, p.fetch)
+	return p
+}
+
+// Enqueue schedules a fetch of a key's destination, dropping it if the queue is full
+func (p *Prefetcher) Enqueue(key, url string) {
+	if !p.queue.Push(job{key: key, url: url}) {
+		log.Printf("preview queue full or closed, dropping fetch for %s", key)
+	}
+}
+
+// Flush waits until queued fetches have finished or ctx is done
+func (p *Prefetcher) Flush(ctx context.Context) error {
+	return p.queue.Flush(ctx)
+}
+
+// Shutdown stops accepting fetches and waits for queued ones to finish
+// until ctx is done
+func (p *Prefetcher) Shutdown(ctx context.Context) error {
+	return p.queue.Shutdown(ctx)
+}
+
+// Close stops accepting fetches and waits for queued ones to finish
+func (p *Prefetcher) Close() {
+	p.queue.Close()
+}
+
+// fetch downloads a queued destination and saves its preview
+func (p *Prefetcher) fetch(j job) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	preview, err := p.fetcher.Fetch(ctx, j.url)
+	if err == nil {
+		err = p.store.SavePreview(ctx, j.key, j.url, preview)
+	}
+	if err != nil && err != storage.ErrNotFound {
+		log.Printf("failed to fetch preview for %s: %v", j.key, err)
+	}
+}
+
+// defaultClient only connects to public addresses so user-supplied
+// destinations cannot be used to probe internal services
+var defaultClient = PublicClient(DefaultTimeout)
+
+// PublicClient returns a client that refuses to connect to loopback,
+// private and link-local addresses, for requests to user-supplied URLs
+func PublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: netguard.Control,
+			}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// first returns the first non-empty value
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// resolve makes ref absolute relative to base, dropping non-http(s) results
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
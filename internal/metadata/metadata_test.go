@@ -0,0 +1,118 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Plain title</title>
+  <meta name="description" content="Plain description">
+  <meta property="og:title" content="OG &amp; title">
+  <meta name="twitter:description" content="Twitter description">
+  <meta property="og:image" content="/images/cover.png">
+  <meta property="og:site_name" content="Example">
+</head>
+<body>
+  <meta property="og:title" content="Ignored">
+</body>
+</html>`
+
+func TestParse(t *testing.T) {
+	base, err := url.Parse("https://example.com/articles/1")
+	require.NoError(t, err)
+
+	p := Parse(strings.NewReader(page), base)
+	assert.Equal(t, "OG & title", p.Title)
+	assert.Equal(t, "Twitter description", p.Description)
+	assert.Equal(t, "https://example.com/images/cover.png", p.Image)
+	assert.Equal(t, "Example", p.SiteName)
+
+	// The document title and description are used without social tags
+	p = Parse(strings.NewReader(`<html><head><title> Plain </title><meta name="description" content="Text"></head></html>`), base)
+	assert.Equal(t, "Plain", p.Title)
+	assert.Equal(t, "Text", p.Description)
+	assert.Empty(t, p.Image)
+
+	// Unsafe image URLs are dropped
+	p = Parse(strings.NewReader(`<meta property="og:image" content="javascript:alert(1)">`), base)
+	assert.Empty(t, p.Image)
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		case "/file":
+			w.Header().Set("Content-Type", "application/pdf")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f := Fetcher{Client: server.Client()}
+	ctx := context.Background()
+
+	p, err := f.Fetch(ctx, server.URL+"/page")
+	require.NoError(t, err)
+	assert.Equal(t, "OG & title", p.Title)
+	assert.Equal(t, server.URL+"/images/cover.png", p.Image)
+	assert.False(t, p.FetchedAt.IsZero())
+
+	_, err = f.Fetch(ctx, server.URL+"/file")
+	assert.Equal(t, ErrNotHTML, err)
+
+	_, err = f.Fetch(ctx, server.URL+"/missing")
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+
+	// The default client refuses internal addresses
+	_, err = Fetcher{}.Fetch(ctx, server.URL+"/page")
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+}
+
+// memoryStore keeps saved previews in memory
+type memoryStore struct {
+	mu       sync.Mutex
+	previews map[string]*storage.Preview
+}
+
+func (m *memoryStore) SavePreview(_ context.Context, key, _ string, p *storage.Preview) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.previews[key] = p
+	return nil
+}
+
+func TestPrefetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	store := &memoryStore{previews: make(map[string]*storage.Preview)}
+	p := NewPrefetcher(Fetcher{Client: server.Client()}, store, 10, time.Second)
+	p.Enqueue("aB1cD2eF", server.URL)
+
+	// Close waits for queued fetches
+	p.Close()
+	require.Contains(t, store.previews, "aB1cD2eF")
+	assert.Equal(t, "OG & title", store.previews["aB1cD2eF"].Title)
+	assert.WithinDuration(t, time.Now(), store.previews["aB1cD2eF"].FetchedAt, time.Minute)
+}
@@ -0,0 +1,180 @@
+// Package metering accounts for the usage of each workspace, or of each
+// owner outside workspaces, so internal teams can be billed for it
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// Metrics usage is metered by
+const (
+	LinksCreated    = "links_created"
+	Redirects       = "redirects"
+	AnalyticsEvents = "analytics_events"
+)
+
+const (
+	// DefaultFlushInterval is the default time between flushes
+	DefaultFlushInterval = time.Minute
+
+	// DefaultTimeout is the default time allowed for delivering usage
+	DefaultTimeout = 10 * time.Second
+)
+
+// ErrUnexpectedStatus is returned when a webhook answers with a non-2xx status
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// Sink receives usage records, such as a billing system
+type Sink interface {
+	Emit(ctx context.Context, usage []storage.Usage) error
+}
+
+// Webhook posts usage records as a JSON array to a URL
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Emit posts the usage, failing unless the receiver answers with a 2xx status
+func (w Webhook) Emit(ctx context.Context, usage []storage.Usage) error {
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	body, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
+}
+
+// counter identifies a metric of a scope within a day
+type counter struct {
+	day    time.Time
+	scope  storage.Scope
+	metric string
+}
+
+// Meter counts usage in memory so requests never wait on metering, and
+// periodically flushes it to the store's daily totals. With a sink, the
+// flushed usage is then delivered to it; usage that fails to be delivered
+// is retried on the next flush.
+type Meter struct {
+	store    storage.UsageStore
+	sink     Sink
+	interval time.Duration
+
+	mu     sync.Mutex
+	counts map[counter]int64
+}
+
+// NewMeter creates a Meter flushing every interval. sink may be nil.
+func NewMeter(store storage.UsageStore, sink Sink, interval time.Duration) *Meter {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	return &Meter{store: store, sink: sink, interval: interval, counts: make(map[counter]int64)}
+}
+
+// Add counts n of a metric against a scope
+func (m *Meter) Add(scope storage.Scope, metric string, n int64) {
+	c := counter{day: storage.GranularityDay.Truncate(time.Now()), scope: scope, metric: metric}
+	m.mu.Lock()
+	m.counts[c] += n
+	m.mu.Unlock()
+}
+
+// AddClick counts a click stored for analytics against its link's scope
+func (m *Meter) AddClick(click analytics.Click) {
+	scope := storage.Scope{Workspace: click.Workspace}
+	if scope.Workspace == "" {
+		scope.Owner = click.Owner
+	}
+	m.Add(scope, AnalyticsEvents, 1)
+}
+
+// Run flushes usage every interval until ctx is done. Usage counted
+// afterwards, such as clicks drained on shutdown, needs a final Flush.
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := m.Flush(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("usage flush failed: %v", err)
+		}
+	}
+}
+
+// Flush adds the usage counted since the last flush to the store, then
+// delivers the usage awaiting metering to the sink
+func (m *Meter) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	counts := m.counts
+	m.counts = make(map[counter]int64)
+	m.mu.Unlock()
+
+	usage := make([]storage.Usage, 0, len(counts))
+	for c, n := range counts {
+		usage = append(usage, storage.Usage{
+			Day:       c.day,
+			Workspace: c.scope.Workspace,
+			Owner:     c.scope.Owner,
+			Metric:    c.metric,
+			Quantity:  n,
+		})
+	}
+	if err := m.store.AddUsage(ctx, usage, m.sink != nil); err != nil {
+		// Keep the counts for the next flush
+		m.mu.Lock()
+		for c, n := range counts {
+			m.counts[c] += n
+		}
+		m.mu.Unlock()
+		return err
+	}
+	if m.sink == nil {
+		return nil
+	}
+
+	pending, err := m.store.TakePendingUsage(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	if err := m.sink.Emit(ctx, pending); err != nil {
+		return err
+	}
+	return m.store.AckPendingUsage(ctx)
+}
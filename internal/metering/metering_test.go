@@ -0,0 +1,130 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// memoryStore keeps usage in memory
+type memoryStore struct {
+	totals  []storage.Usage
+	queued  []storage.Usage
+	pending []storage.Usage
+	err     error
+}
+
+func (m *memoryStore) AddUsage(_ context.Context, usage []storage.Usage, queue bool) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.totals = append(m.totals, usage...)
+	if queue {
+		m.queued = append(m.queued, usage...)
+	}
+	return nil
+}
+
+func (m *memoryStore) TakePendingUsage(context.Context) ([]storage.Usage, error) {
+	if m.pending == nil {
+		m.pending, m.queued = m.queued, nil
+	}
+	return m.pending, nil
+}
+
+func (m *memoryStore) AckPendingUsage(context.Context) error {
+	m.pending = nil
+	return nil
+}
+
+func (m *memoryStore) GetUsage(context.Context, time.Time, time.Time) ([]storage.Usage, error) {
+	return m.totals, nil
+}
+
+// memorySink keeps emitted usage, failing while err is set
+type memorySink struct {
+	emitted []storage.Usage
+	err     error
+}
+
+func (m *memorySink) Emit(_ context.Context, usage []storage.Usage) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.emitted = append(m.emitted, usage...)
+	return nil
+}
+
+func TestMeter_Flush(t *testing.T) {
+	store := &memoryStore{}
+	sink := &memorySink{}
+	m := NewMeter(store, sink, time.Minute)
+	ctx := context.Background()
+	today := storage.GranularityDay.Truncate(time.Now())
+
+	m.Add(storage.Scope{Workspace: "marketing"}, Redirects, 1)
+	m.Add(storage.Scope{Workspace: "marketing"}, Redirects, 2)
+	m.AddClick(analytics.Click{Key: "abc", Owner: "alice"})
+	require.NoError(t, m.Flush(ctx))
+
+	assert.ElementsMatch(t, []storage.Usage{
+		{Day: today, Workspace: "marketing", Metric: Redirects, Quantity: 3},
+		{Day: today, Owner: "alice", Metric: AnalyticsEvents, Quantity: 1},
+	}, store.totals)
+	assert.ElementsMatch(t, store.totals, sink.emitted)
+
+	// Usage the sink refused is delivered on the next flush
+	sink.err = errors.New("billing is down")
+	m.Add(storage.Scope{Owner: "bob"}, LinksCreated, 1)
+	assert.Error(t, m.Flush(ctx))
+	sink.err = nil
+	require.NoError(t, m.Flush(ctx))
+	assert.Contains(t, sink.emitted, storage.Usage{Day: today, Owner: "bob", Metric: LinksCreated, Quantity: 1})
+
+	// Counts the store refused are kept for the next flush
+	store.err = errors.New("redis is down")
+	m.Add(storage.Scope{Owner: "bob"}, LinksCreated, 1)
+	assert.Error(t, m.Flush(ctx))
+	store.err = nil
+	require.NoError(t, m.Flush(ctx))
+	assert.Equal(t, storage.Usage{Day: today, Owner: "bob", Metric: LinksCreated, Quantity: 1}, store.totals[len(store.totals)-1])
+}
+
+func TestMeter_WithoutSink(t *testing.T) {
+	store := &memoryStore{}
+	m := NewMeter(store, nil, 0)
+
+	m.Add(storage.Scope{Workspace: "marketing"}, LinksCreated, 1)
+	require.NoError(t, m.Flush(context.Background()))
+	assert.Len(t, store.totals, 1)
+	assert.Empty(t, store.queued)
+}
+
+func TestWebhook_Emit(t *testing.T) {
+	var received []storage.Usage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	usage := []storage.Usage{{Day: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), Workspace: "marketing", Metric: Redirects, Quantity: 42}}
+	require.NoError(t, Webhook{URL: server.URL}.Emit(context.Background(), usage))
+	assert.Equal(t, usage, received)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.ErrorIs(t, Webhook{URL: failing.URL}.Emit(context.Background(), usage), ErrUnexpectedStatus)
+}
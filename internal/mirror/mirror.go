@@ -0,0 +1,182 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
+)
+
+const (
+	// DefaultQueueSize is the default number of requests buffered before new ones are dropped
+	DefaultQueueSize = 1000
+
+	// DefaultTimeout is the default time allowed for each mirrored request
+	DefaultTimeout = 5 * time.Second
+
+	// QueueName identifies the mirror queue in the dropped items metric
+	QueueName = "mirror"
+
+	// MirroredHeader marks requests forwarded by the mirror so staging can tell them apart
+	MirroredHeader = "X-Mirrored-Request"
+)
+
+// ErrInvalidTarget is returned when the staging URL is not an absolute http(s) URL
+var ErrInvalidTarget = errors.New("mirror target must be an absolute http(s) url")
+
+// hopHeaders are connection-specific headers that must not be forwarded
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// credentialHeaders carry the caller's credentials, which must not reach
+// the staging instance
+var credentialHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"X-API-Key",
+	"X-CSRF-Token",
+}
+
+// request is a copy of the parts of a production request that are mirrored
+type request struct {
+	method string
+	uri    string
+	host   string
+	header http.Header
+}
+
+// Mirror forwards a sample of requests to a staging instance in the
+// background. Only the method, path, query and headers are forwarded;
+// request bodies are never sent and staging responses are discarded.
+type Mirror struct {
+	target *url.URL
+	rate   float64
+	client *http.Client
+	queue  *queue.Queue[request]
+}
+
+// New creates a new Mirror forwarding the given fraction of requests to target
+func New(target string, rate float64, queueSize int) (*Mirror, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidTarget
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	m := &Mirror{
+		target: u,
+		rate:   rate,
+		client: &http.Client{
+			Timeout: DefaultTimeout,
+			// Staging answers redirects with redirects; never follow them
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	m.queue = queue.New(QueueName, queueSize, m.forward)
+	return m, nil
+}
+
+// Middleware queues a sample of requests for mirroring
+func (m *Mirror) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.sampled() {
+			m.enqueue(c.Request)
+		}
+		c.Next()
+	}
+}
+
+// Flush waits until queued requests have been sent or ctx is done
+func (m *Mirror) Flush(ctx context.Context) error {
+	return m.queue.Flush(ctx)
+}
+
+// Shutdown stops accepting requests and waits for queued ones to be sent
+// until ctx is done
+func (m *Mirror) Shutdown(ctx context.Context) error {
+	return m.queue.Shutdown(ctx)
+}
+
+// Close stops accepting requests and waits for queued ones to be sent
+func (m *Mirror) Close() {
+	m.queue.Close()
+}
+
+// sampled reports whether the current request should be mirrored
+func (m *Mirror) sampled() bool {
+	return m.rate >= 1 || (m.rate > 0 && rand.Float64() < m.rate)
+}
+
+// enqueue copies a request onto the queue without its credentials,
+// dropping it if the queue is full
+func (m *Mirror) enqueue(r *http.Request) {
+	req := request{
+		method: r.Method,
+		uri:    r.URL.RequestURI(),
+		host:   r.Host,
+		header: r.Header.Clone(),
+	}
+	for _, h := range credentialHeaders {
+		req.header.Del(h)
+	}
+	if !m.queue.Push(req) {
+		log.Printf("mirror queue full or closed, dropping request for %s", req.uri)
+	}
+}
+
+// forward sends a queued request to the staging instance
+func (m *Mirror) forward(req request) {
+	if err := m.send(req); err != nil {
+		log.Printf("failed to mirror request for %s: %v", req.uri, err)
+	}
+}
+
+// send forwards a single request and discards the response
+func (m *Mirror) send(req request) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	target, err := m.target.Parse(req.uri)
+	if err != nil {
+		return err
+	}
+	if m.target.Path != "" && m.target.Path != "/" {
+		target.Path = m.target.Path + target.Path
+	}
+
+	out, err := http.NewRequestWithContext(ctx, req.method, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	out.Header = req.header
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	out.Header.Set(MirroredHeader, "1")
+	out.Header.Set("X-Forwarded-Host", req.host)
+
+	resp, err := m.client.Do(out)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,115 @@
+package mirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staging records the requests it receives
+type staging struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (s *staging) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	http.Redirect(w, r, "https://example.com", http.StatusFound)
+}
+
+func setupRouter(m *Mirror) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/:key", m.Middleware(), func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "https://example.com")
+	})
+	return router
+}
+
+func TestMirror_Forward(t *testing.T) {
+	target := &staging{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	m, err := New(server.URL+"/mirror", 1, 10)
+	require.NoError(t, err)
+	router := setupRouter(m)
+
+	req := httptest.NewRequest(http.MethodGet, "/aB1cD2eF?utm_source=test", strings.NewReader("ignored"))
+	req.Host = "sho.rt"
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone)")
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	// Close waits for queued requests to be sent
+	m.Close()
+
+	require.Len(t, target.requests, 1)
+	got := target.requests[0]
+	assert.Equal(t, http.MethodGet, got.Method)
+	assert.Equal(t, "/mirror/aB1cD2eF", got.URL.Path)
+	assert.Equal(t, "utm_source=test", got.URL.RawQuery)
+	assert.Equal(t, "Mozilla/5.0 (iPhone)", got.Header.Get("User-Agent"))
+	assert.Equal(t, "1", got.Header.Get(MirroredHeader))
+	assert.Equal(t, "sho.rt", got.Header.Get("X-Forwarded-Host"))
+	assert.Equal(t, int64(0), got.ContentLength)
+
+	// Credentials never leave production
+	assert.Empty(t, got.Header.Get("Authorization"))
+	assert.Empty(t, got.Header.Get("Cookie"))
+	assert.Empty(t, got.Header.Get("X-API-Key"))
+}
+
+func TestMirror_Sampling(t *testing.T) {
+	target := &staging{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	m, err := New(server.URL, 0, 10)
+	require.NoError(t, err)
+	router := setupRouter(m)
+
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/aB1cD2eF", nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+	}
+	m.Close()
+
+	assert.Empty(t, target.requests)
+}
+
+func TestMirror_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	m, err := New(server.URL, 1, 10)
+	require.NoError(t, err)
+	router := setupRouter(m)
+
+	// Production requests are unaffected when staging is down
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/aB1cD2eF", nil))
+	assert.Equal(t, http.StatusFound, w.Code)
+	m.Close()
+}
+
+func TestNew_InvalidTarget(t *testing.T) {
+	for _, target := range []string{"", "staging:8080", "ftp://staging"} {
+		_, err := New(target, 1, 10)
+		assert.Equal(t, ErrInvalidTarget, err, target)
+	}
+}
@@ -0,0 +1,35 @@
+// Package netguard keeps requests to user-supplied URLs from reaching
+// loopback, private and link-local addresses, so destinations can't be
+// used to probe internal services
+package netguard
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned when a connection would reach an address
+// that isn't public
+var ErrPrivateAddress = errors.New("destination resolves to a private address")
+
+// Control refuses connections to addresses that aren't public. Set it as a
+// net.Dialer's Control, so it checks the address actually dialed after DNS
+// resolution.
+func Control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !PublicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// PublicIP reports whether ip is routable on the public internet
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
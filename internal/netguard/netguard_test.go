@@ -0,0 +1,18 @@
+package netguard
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControl(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:80", "10.0.0.1:443", "[::1]:80", "169.254.169.254:80", "0.0.0.0:80"} {
+		assert.ErrorIs(t, Control("tcp", address, nil), ErrPrivateAddress, address)
+	}
+	assert.NoError(t, Control("tcp", "93.184.216.34:443", nil))
+	assert.NoError(t, Control("tcp6", "[2606:2800:220:1::1]:443", nil))
+	assert.Error(t, Control("tcp", "no port", nil))
+	assert.True(t, PublicIP(net.ParseIP("8.8.8.8")))
+}
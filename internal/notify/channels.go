@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+
+	"github.com/prayushdave/url-shortener/internal/mail"
+)
+
+// SlackHost is the host of Slack's incoming webhooks
+const SlackHost = "hooks.slack.com"
+
+// ErrUnexpectedStatus is returned when a webhook rejects a notification
+var ErrUnexpectedStatus = errors.New("webhook returned an unexpected status")
+
+// Email delivers events as plain text mail to email addresses
+type Email struct {
+	Sender mail.Sender
+}
+
+// Name identifies the channel as email
+func (Email) Name() string {
+	return "email"
+}
+
+// Validate checks the recipient is a bare email address
+func (Email) Validate(recipient string) error {
+	addr, err := netmail.ParseAddress(recipient)
+	if err != nil || addr.Name != "" || addr.Address != recipient {
+		return fmt.Errorf("invalid email address %q", recipient)
+	}
+	return nil
+}
+
+// Send mails the event to the recipient
+func (e Email) Send(ctx context.Context, recipient string, ev Event) error {
+	return e.Sender.Send(ctx, mail.Message{To: recipient, Subject: ev.Subject(), Text: ev.Text()})
+}
+
+// Slack posts events to Slack incoming webhooks. Recipients are webhook
+// URLs, which must be on SlackHost so link owners can't have the service
+// post to arbitrary addresses.
+type Slack struct {
+	Client *http.Client
+}
+
+// Name identifies the channel as slack
+func (Slack) Name() string {
+	return "slack"
+}
+
+// Validate checks the recipient is a Slack incoming webhook URL
+func (Slack) Validate(recipient string) error {
+	u, err := url.Parse(recipient)
+	if err != nil || u.Scheme != "https" || u.Host != SlackHost || u.User != nil {
+		return fmt.Errorf("invalid Slack webhook %q", recipient)
+	}
+	return nil
+}
+
+// slackMessage is the body of an incoming webhook request
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// Send posts the event to the webhook, failing unless Slack answers with a
+// 2xx status
+func (s Slack) Send(ctx context.Context, recipient string, e Event) error {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	body, err := json.Marshal(slackMessage{Text: e.Text()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recipient, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
+}
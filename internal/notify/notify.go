@@ -0,0 +1,364 @@
+// Package notify tells link owners about events on their links, such as
+// click milestones, upcoming expiry or disabled and broken links, through
+// pluggable channels like email and Slack. Each link uses its own settings,
+// or else those of its workspace.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultTimeout is the default time allowed to deliver a notification
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultInterval is the default time between milestone checks
+	DefaultInterval = time.Minute
+
+	// maxRecipients bounds the recipients of each channel
+	maxRecipients = 10
+
+	// maxMilestones bounds the milestones of a link or workspace
+	maxMilestones = 10
+)
+
+// DefaultMilestones are the click counts announced when settings name none
+var DefaultMilestones = []int64{1000}
+
+// Kind is the kind of an event
+type Kind string
+
+// Kinds of events
+const (
+	// Milestone is a link reaching a number of clicks
+	Milestone Kind = "milestone"
+
+	// Expiring is a link about to expire
+	Expiring Kind = "expiring"
+
+	// Disabled is a link whose redirects were paused
+	Disabled Kind = "disabled"
+
+	// Broken is a link whose destination no longer resolves
+	Broken Kind = "broken"
+)
+
+// Kinds lists every kind of event
+var Kinds = []Kind{Milestone, Expiring, Disabled, Broken}
+
+// ErrInvalidSettings is returned for notification settings naming unknown
+// channels or events, invalid recipients or milestones
+var ErrInvalidSettings = errors.New("invalid notification settings")
+
+// Event is something that happened to a link. Detail describes it, such as
+// the milestone reached or why the destination is broken.
+type Event struct {
+	Kind      Kind      `json:"kind"`
+	Key       string    `json:"key"`
+	ShortURL  string    `json:"short_url"`
+	URL       string    `json:"url"`
+	Owner     string    `json:"owner,omitempty"`
+	Workspace string    `json:"workspace,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Subject summarizes the event in a line
+func (e Event) Subject() string {
+	switch e.Kind {
+	case Milestone:
+		return fmt.Sprintf("%s reached %s", e.ShortURL, e.Detail)
+	case Expiring:
+		return fmt.Sprintf("%s expires soon", e.ShortURL)
+	case Disabled:
+		return fmt.Sprintf("%s was disabled", e.ShortURL)
+	case Broken:
+		return fmt.Sprintf("%s points to a broken destination", e.ShortURL)
+	}
+	return fmt.Sprintf("%s: %s", e.ShortURL, e.Kind)
+}
+
+// Text describes the event in full
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Subject() + ".\n\n")
+	fmt.Fprintf(&b, "Short link: %s\nDestination: %s\n", e.ShortURL, e.URL)
+	if e.Workspace != "" {
+		fmt.Fprintf(&b, "Workspace: %s\n", e.Workspace)
+	}
+	if e.Detail != "" && e.Kind != Milestone {
+		fmt.Fprintf(&b, "Detail: %s\n", e.Detail)
+	}
+	return b.String()
+}
+
+// Channel delivers events to recipients, such as email addresses or chat
+// webhooks
+type Channel interface {
+	// Name identifies the channel in notification settings
+	Name() string
+
+	// Validate reports whether a recipient can be reached through the channel
+	Validate(recipient string) error
+
+	// Send delivers the event to a recipient
+	Send(ctx context.Context, recipient string, e Event) error
+}
+
+// Store is what the notifier reads settings, links and click counts from
+type Store interface {
+	storage.NotificationStore
+	GetRecord(ctx context.Context, key string) (*storage.Record, error)
+	GetStats(ctx context.Context, key string) (*storage.Stats, error)
+}
+
+// ParseMilestones parses a comma-separated list of positive click counts
+func ParseMilestones(spec string) ([]int64, error) {
+	var milestones []int64
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		m, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || m <= 0 {
+			return nil, fmt.Errorf("invalid milestone %q", field)
+		}
+		milestones = append(milestones, m)
+	}
+	return milestones, nil
+}
+
+// Notifier delivers the events of links to the recipients named by their
+// settings, and watches clicked links for milestones
+type Notifier struct {
+	store    Store
+	baseURL  string
+	channels map[string]Channel
+
+	// Milestones are announced for links with no milestones of their own
+	Milestones []int64
+
+	// clicked holds the keys clicked since the last milestone check
+	mu      sync.Mutex
+	clicked map[string]struct{}
+}
+
+// New creates a Notifier delivering through the given channels. Short links
+// in events are built from baseURL, the URL links are served under.
+func New(store Store, baseURL string, channels ...Channel) *Notifier {
+	n := &Notifier{
+		store:      store,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		channels:   make(map[string]Channel, len(channels)),
+		Milestones: DefaultMilestones,
+		clicked:    make(map[string]struct{}),
+	}
+	for _, ch := range channels {
+		n.channels[ch.Name()] = ch
+	}
+	return n
+}
+
+// Validate checks notification settings against the notifier's channels
+func (n *Notifier) Validate(s *storage.Notifications) error {
+	for name, recipients := range s.Channels {
+		ch, ok := n.channels[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidSettings, name)
+		}
+		if len(recipients) == 0 || len(recipients) > maxRecipients {
+			return fmt.Errorf("%w: %s needs 1 to %d recipients", ErrInvalidSettings, name, maxRecipients)
+		}
+		for _, r := range recipients {
+			if err := ch.Validate(r); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+			}
+		}
+	}
+	for _, kind := range s.Events {
+		if !slices.Contains(Kinds, Kind(kind)) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidSettings, kind)
+		}
+	}
+	if len(s.Milestones) > maxMilestones {
+		return fmt.Errorf("%w: at most %d milestones", ErrInvalidSettings, maxMilestones)
+	}
+	for _, m := range s.Milestones {
+		if m <= 0 {
+			return fmt.Errorf("%w: milestones must be positive", ErrInvalidSettings)
+		}
+	}
+	return nil
+}
+
+// Event builds an event of a link
+func (n *Notifier) Event(kind Kind, key string, rec *storage.Record, detail string) Event {
+	return Event{
+		Kind:      kind,
+		Key:       key,
+		ShortURL:  n.shortURL(key, rec.Domain),
+		URL:       rec.URL,
+		Owner:     rec.Owner,
+		Workspace: rec.Workspace,
+		Detail:    detail,
+		Time:      time.Now().UTC(),
+	}
+}
+
+// Settings returns the notification settings of a link: its own, or else
+// those of its workspace. It returns nil if neither has any.
+func (n *Notifier) Settings(ctx context.Context, rec *storage.Record) (*storage.Notifications, error) {
+	if rec.Notifications != nil || rec.Workspace == "" {
+		return rec.Notifications, nil
+	}
+	return n.store.GetWorkspaceNotifications(ctx, rec.Workspace)
+}
+
+// Notify delivers an event of a link to every recipient its settings name,
+// if they include events of its kind. It returns the errors of the
+// deliveries that failed.
+func (n *Notifier) Notify(ctx context.Context, e Event, rec *storage.Record) error {
+	settings, err := n.Settings(ctx, rec)
+	if err != nil || !wants(settings, e.Kind) {
+		return err
+	}
+
+	var errs []error
+	for name, recipients := range settings.Channels {
+		ch, ok := n.channels[name]
+		if !ok {
+			continue
+		}
+		for _, r := range recipients {
+			if err := ch.Send(ctx, r, e); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Dispatch notifies of an event in the background, logging failures, so
+// requests never wait on channels
+func (n *Notifier) Dispatch(e Event, rec *storage.Record) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancel()
+		if err := n.Notify(ctx, e, rec); err != nil {
+			log.Printf("failed to notify %s of %s: %v", e.Kind, e.Key, err)
+		}
+	}()
+}
+
+// Clicked notes a click of a key, whose milestones are checked next run
+func (n *Notifier) Clicked(key string) {
+	n.mu.Lock()
+	n.clicked[key] = struct{}{}
+	n.mu.Unlock()
+}
+
+// Run checks clicked links for milestones every interval until ctx is done
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.CheckMilestones(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("milestone check failed: %v", err)
+			}
+		}
+	}
+}
+
+// CheckMilestones announces the milestones reached by the links clicked
+// since the last check. Each milestone is claimed in the store first, so
+// it is announced once however many instances count clicks.
+func (n *Notifier) CheckMilestones(ctx context.Context) error {
+	n.mu.Lock()
+	clicked := n.clicked
+	n.clicked = make(map[string]struct{})
+	n.mu.Unlock()
+
+	for key := range clicked {
+		if err := n.checkMilestones(ctx, key); err != nil && err != storage.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMilestones announces the milestones reached by a link
+func (n *Notifier) checkMilestones(ctx context.Context, key string) error {
+	rec, err := n.store.GetRecord(ctx, key)
+	if err != nil {
+		return err
+	}
+	settings, err := n.Settings(ctx, rec)
+	if err != nil || !wants(settings, Milestone) {
+		return err
+	}
+	stats, err := n.store.GetStats(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	milestones := settings.Milestones
+	if len(milestones) == 0 {
+		milestones = n.Milestones
+	}
+	for _, m := range milestones {
+		if stats.Clicks < m {
+			continue
+		}
+		claimed, err := n.store.ClaimMilestone(ctx, key, m)
+		if err != nil {
+			return err
+		}
+		if claimed {
+			n.Dispatch(n.Event(Milestone, key, rec, fmt.Sprintf("%d clicks", m)), rec)
+		}
+	}
+	return nil
+}
+
+// shortURL returns the short link of a key, under its custom domain if set
+func (n *Notifier) shortURL(key, domain string) string {
+	if domain == "" {
+		return n.baseURL + "/" + key
+	}
+	// Custom domains serve links under the same path prefix
+	scheme, prefix := "https", ""
+	if u, err := url.Parse(n.baseURL); err == nil {
+		if u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		prefix = u.Path
+	}
+	return scheme + "://" + domain + prefix + "/" + key
+}
+
+// wants reports whether settings announce events of a kind
+func wants(settings *storage.Notifications, kind Kind) bool {
+	if settings == nil || len(settings.Channels) == 0 {
+		return false
+	}
+	return len(settings.Events) == 0 || slices.Contains(settings.Events, string(kind))
+}
@@ -0,0 +1,214 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+	"github.com/prayushdave/url-shortener/internal/mail"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// delivery is an event sent to a recipient
+type delivery struct {
+	recipient string
+	event     Event
+}
+
+// stubChannel collects the events sent through it
+type stubChannel struct {
+	sent chan delivery
+}
+
+func (stubChannel) Name() string { return "stub" }
+
+func (stubChannel) Validate(string) error { return nil }
+
+func (c stubChannel) Send(_ context.Context, recipient string, e Event) error {
+	c.sent <- delivery{recipient, e}
+	return nil
+}
+
+// received waits for the next delivery
+func (c stubChannel) received(t *testing.T) delivery {
+	t.Helper()
+	select {
+	case d := <-c.sent:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no notification sent")
+		return delivery{}
+	}
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	ch := stubChannel{sent: make(chan delivery, 10)}
+	n := New(store, "https://sho.rt/", ch)
+
+	// Links without settings of their own use their workspace's
+	require.NoError(t, store.SetWorkspaceNotifications(ctx, "acme", &storage.Notifications{
+		Channels: map[string][]string{"stub": {"team"}},
+		Events:   []string{string(Broken)},
+	}))
+	rec := &storage.Record{URL: "https://example.com", Workspace: "acme"}
+	require.NoError(t, n.Notify(ctx, n.Event(Disabled, "abc12345", rec, ""), rec))
+	require.NoError(t, n.Notify(ctx, n.Event(Broken, "abc12345", rec, "404 Not Found"), rec))
+	d := ch.received(t)
+	assert.Equal(t, "team", d.recipient)
+	assert.Equal(t, "https://sho.rt/abc12345", d.event.ShortURL)
+	assert.Equal(t, "https://sho.rt/abc12345 points to a broken destination", d.event.Subject())
+	assert.Contains(t, d.event.Text(), "Detail: 404 Not Found")
+	assert.Empty(t, ch.sent, "the workspace doesn't announce disabled links")
+
+	rec.Notifications = &storage.Notifications{Channels: map[string][]string{"stub": {"alice"}}}
+	rec.Domain = "go.acme.com"
+	require.NoError(t, n.Notify(ctx, n.Event(Disabled, "abc12345", rec, ""), rec))
+	d = ch.received(t)
+	assert.Equal(t, "alice", d.recipient)
+	assert.Equal(t, "https://go.acme.com/abc12345", d.event.ShortURL)
+
+	// Custom domains serve links under the same path prefix
+	prefixed := New(store, "https://sho.rt/r", ch)
+	assert.Equal(t, "https://go.acme.com/r/abc12345", prefixed.Event(Disabled, "abc12345", rec, "").ShortURL)
+
+	assert.ErrorIs(t, n.Validate(&storage.Notifications{Channels: map[string][]string{"fax": {"555"}}}), ErrInvalidSettings)
+	assert.ErrorIs(t, n.Validate(&storage.Notifications{Channels: map[string][]string{"stub": {"alice"}}, Events: []string{"deleted"}}), ErrInvalidSettings)
+	assert.ErrorIs(t, n.Validate(&storage.Notifications{Channels: map[string][]string{"stub": {"alice"}}, Milestones: []int64{0}}), ErrInvalidSettings)
+}
+
+func TestNotifier_CheckMilestones(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	ch := stubChannel{sent: make(chan delivery, 10)}
+	n := New(store, "https://sho.rt", ch)
+	require.NoError(t, store.Create(ctx, "abc12345", &storage.Record{
+		URL:           "https://example.com",
+		Notifications: &storage.Notifications{Channels: map[string][]string{"stub": {"alice"}}, Milestones: []int64{2, 5}},
+	}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "abc12345", Time: time.Now()}))
+		n.Clicked("abc12345")
+	}
+	require.NoError(t, n.CheckMilestones(ctx))
+	d := ch.received(t)
+	assert.Equal(t, Milestone, d.event.Kind)
+	assert.Equal(t, "https://sho.rt/abc12345 reached 2 clicks", d.event.Subject())
+
+	// Milestones are announced once, and only for links clicked since
+	n.Clicked("abc12345")
+	require.NoError(t, n.CheckMilestones(ctx))
+	require.NoError(t, n.CheckMilestones(ctx))
+	assert.Empty(t, ch.sent)
+
+	// Deleted links are skipped
+	n.Clicked("missing1")
+	assert.NoError(t, n.CheckMilestones(ctx))
+}
+
+func TestReminder_RemindExpiring(t *testing.T) {
+	store := storage.NewRedisStore("localhost:6379", "", 0)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.FlushDB(ctx))
+
+	ch := stubChannel{sent: make(chan delivery, 10)}
+	n := New(store, "https://sho.rt", ch)
+	settings := &storage.Notifications{Channels: map[string][]string{"stub": {"alice"}}}
+	require.NoError(t, store.Create(ctx, "abc12345", &storage.Record{URL: "https://example.com", Notifications: settings}))
+	require.NoError(t, store.Create(ctx, "xyz67890", &storage.Record{URL: "https://example.org"}))
+
+	// Links expire after the store's TTL, so a window longer than it finds both
+	r := NewReminder(store, n, store.TTL()+time.Minute, 0)
+	reminded, err := r.RemindExpiring(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reminded, "links without settings aren't announced")
+	d := ch.received(t)
+	assert.Equal(t, Expiring, d.event.Kind)
+	assert.Equal(t, "https://sho.rt/abc12345 expires soon", d.event.Subject())
+	assert.Contains(t, d.event.Detail, "/api/v1/urls/abc12345/extend")
+
+	// Each expiry is announced once
+	reminded, err = r.RemindExpiring(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, reminded)
+
+	// Extending a link moves it out of the window until its new expiry nears
+	require.NoError(t, store.Extend(ctx, "abc12345", time.Now().Add(2*store.TTL())))
+	reminded, err = r.RemindExpiring(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, reminded)
+	assert.Empty(t, ch.sent)
+}
+
+func TestParseMilestones(t *testing.T) {
+	milestones, err := ParseMilestones("100, 1000,,10000")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{100, 1000, 10000}, milestones)
+
+	_, err = ParseMilestones("100,-5")
+	assert.Error(t, err)
+}
+
+func TestEmail_Send(t *testing.T) {
+	sender := &mailRecorder{}
+	email := Email{Sender: sender}
+	assert.NoError(t, email.Validate("alice@example.com"))
+	assert.Error(t, email.Validate("Alice <alice@example.com>"))
+	assert.Error(t, email.Validate("not an email"))
+
+	e := Event{Kind: Disabled, ShortURL: "https://sho.rt/abc12345", URL: "https://example.com", Detail: "Disabled by bob"}
+	require.NoError(t, email.Send(context.Background(), "alice@example.com", e))
+	require.Len(t, sender.messages, 1)
+	assert.Equal(t, "alice@example.com", sender.messages[0].To)
+	assert.Equal(t, "https://sho.rt/abc12345 was disabled", sender.messages[0].Subject)
+	assert.Contains(t, sender.messages[0].Text, "Detail: Disabled by bob")
+}
+
+// mailRecorder collects the messages sent to it
+type mailRecorder struct {
+	messages []mail.Message
+}
+
+func (r *mailRecorder) Send(_ context.Context, m mail.Message) error {
+	r.messages = append(r.messages, m)
+	return nil
+}
+
+func TestSlack_Send(t *testing.T) {
+	var got slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	slack := Slack{}
+	assert.NoError(t, slack.Validate("https://hooks.slack.com/services/T000/B000/XXXX"))
+	assert.Error(t, slack.Validate("https://internal.example.com/hook"))
+	assert.Error(t, slack.Validate("http://hooks.slack.com/services/T000/B000/XXXX"))
+
+	e := Event{Kind: Milestone, ShortURL: "https://sho.rt/abc12345", URL: "https://example.com", Detail: "1000 clicks"}
+	require.NoError(t, slack.Send(context.Background(), server.URL, e))
+	assert.Contains(t, got.Text, "https://sho.rt/abc12345 reached 1000 clicks")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	assert.ErrorIs(t, slack.Send(context.Background(), failing.URL, e), ErrUnexpectedStatus)
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/lock"
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultReminderWindow is the default time before expiry owners are reminded
+	DefaultReminderWindow = 7 * 24 * time.Hour
+
+	// DefaultReminderInterval is the default time between reminder runs
+	DefaultReminderInterval = time.Hour
+
+	// DefaultReminderBatch is the default number of links reminded per run
+	DefaultReminderBatch = 1000
+)
+
+// Reminder periodically finds links expiring within a window and tells
+// their owners, once per expiry, through a Notifier
+type Reminder struct {
+	store    storage.ExpiryStore
+	notifier *Notifier
+	window   time.Duration
+	batch    int
+
+	// Lock, if set, keeps reminder runs to one instance at a time
+	Lock *lock.Lock
+}
+
+// NewReminder creates a Reminder announcing links that expire within window
+func NewReminder(store storage.ExpiryStore, notifier *Notifier, window time.Duration, batch int) *Reminder {
+	if window <= 0 {
+		window = DefaultReminderWindow
+	}
+	if batch <= 0 {
+		batch = DefaultReminderBatch
+	}
+	return &Reminder{store: store, notifier: notifier, window: window, batch: batch}
+}
+
+// Run reminds owners of expiring links every interval until ctx is done
+func (r *Reminder) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReminderInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.Lock.Do(ctx, func(ctx context.Context) error {
+				_, err := r.RemindExpiring(ctx)
+				return err
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Printf("expiry reminders failed: %v", err)
+			}
+		}
+	}
+}
+
+// RemindExpiring notifies the owners of links expiring within the window
+// that asked for expiry events, returning how many links were announced.
+// Each expiry is claimed in the store first, so it is announced once however
+// many instances run reminders. Failed deliveries are logged, not retried.
+func (r *Reminder) RemindExpiring(ctx context.Context) (int, error) {
+	results, err := r.store.Expiring(ctx, time.Now().Add(r.window), r.batch)
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, res := range results {
+		settings, err := r.notifier.Settings(ctx, res.Record)
+		if err != nil {
+			return reminded, err
+		}
+		if !wants(settings, Expiring) {
+			continue
+		}
+		claimed, err := r.store.ClaimExpiryReminder(ctx, res.Key, *res.ExpiresAt)
+		if err != nil {
+			return reminded, err
+		}
+		if !claimed {
+			continue
+		}
+
+		detail := fmt.Sprintf("Expires %s. Extend it with POST /api/v1/urls/%s/extend.",
+			res.ExpiresAt.UTC().Format(time.RFC1123), res.Key)
+		e := r.notifier.Event(Expiring, res.Key, res.Record, detail)
+		if err := r.notifier.Notify(ctx, e, res.Record); err != nil {
+			log.Printf("failed to notify %s of %s: %v", e.Kind, e.Key, err)
+		}
+		reminded++
+	}
+	return reminded, nil
+}
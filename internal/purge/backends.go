@@ -0,0 +1,144 @@
+package purge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Backend names accepted by NewBackend
+const (
+	BackendCloudflare = "cloudflare"
+	BackendFastly     = "fastly"
+)
+
+// cloudflareMaxFiles is the most URLs Cloudflare accepts per purge request
+const cloudflareMaxFiles = 30
+
+// Errors returned when configuring or calling a backend
+var (
+	ErrUnknownBackend   = errors.New("unknown purge backend. Must be cloudflare or fastly")
+	ErrMissingSetting   = errors.New("purge backend setting missing")
+	ErrUnexpectedStatus = errors.New("purge request returned an unexpected status")
+)
+
+// Config holds the settings of the purge backends
+type Config struct {
+	// Token authenticates with the CDN's API
+	Token string
+
+	// ZoneID identifies the Cloudflare zone serving the short links
+	ZoneID string
+}
+
+// NewBackend creates the named backend
+func NewBackend(name string, config Config) (Backend, error) {
+	if config.Token == "" {
+		return nil, fmt.Errorf("%w: token", ErrMissingSetting)
+	}
+
+	switch strings.ToLower(name) {
+	case BackendCloudflare:
+		if config.ZoneID == "" {
+			return nil, fmt.Errorf("%w: zone id", ErrMissingSetting)
+		}
+		return &Cloudflare{ZoneID: config.ZoneID, Token: config.Token}, nil
+	case BackendFastly:
+		return &Fastly{Token: config.Token}, nil
+	}
+	return nil, ErrUnknownBackend
+}
+
+// Cloudflare purges URLs through the Cloudflare API
+type Cloudflare struct {
+	ZoneID string
+	Token  string
+
+	// Endpoint overrides the API base URL, for tests
+	Endpoint string
+
+	// Client is used for requests; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+// Purge removes the URLs from the zone's cache
+func (cf *Cloudflare) Purge(ctx context.Context, urls []string) error {
+	endpoint := cf.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.cloudflare.com/client/v4"
+	}
+
+	for start := 0; start < len(urls); start += cloudflareMaxFiles {
+		end := min(start+cloudflareMaxFiles, len(urls))
+		body, err := json.Marshal(map[string][]string{"files": urls[start:end]})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/zones/"+cf.ZoneID+"/purge_cache", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+cf.Token)
+		req.Header.Set("Content-Type", "application/json")
+
+		if err := send(client(cf.Client), req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fastly purges URLs through Fastly's single URL purge
+type Fastly struct {
+	Token string
+
+	// Client is used for requests; nil uses http.DefaultClient
+	Client *http.Client
+}
+
+// Purge removes each URL from the cache of the service serving it
+func (f *Fastly) Purge(ctx context.Context, urls []string) error {
+	var errs []error
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, "PURGE", u, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		req.Header.Set("Fastly-Key", f.Token)
+
+		if err := send(client(f.Client), req); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send performs a purge request and checks it succeeded
+func send(c *http.Client, req *http.Request) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
+}
+
+// client returns c, or the default client if c is nil
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
@@ -0,0 +1,84 @@
+package purge
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
+)
+
+const (
+	// DefaultQueueSize is the default number of pending purges before new ones are dropped
+	DefaultQueueSize = 1000
+
+	// DefaultTimeout is the default time allowed for each purge request
+	DefaultTimeout = 10 * time.Second
+
+	// QueueName identifies the purge queue in the dropped items metric
+	QueueName = "purge"
+)
+
+// Backend removes cached copies of URLs from an edge cache
+type Backend interface {
+	Purge(ctx context.Context, urls []string) error
+}
+
+// Purger purges URLs from an edge cache in the background, so link updates
+// never wait on the CDN's API
+type Purger struct {
+	backend Backend
+	timeout time.Duration
+	queue   *queue.Queue[[]string]
+}
+
+// New creates a new Purger and starts its worker
+func New(backend Backend, queueSize int) *Purger {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	p := &Purger{
+		backend: backend,
+		timeout: DefaultTimeout,
+	}
+	p.queue = queue.New(QueueName, queueSize, p.purge)
+	return p
+}
+
+// Enqueue schedules a purge of the URLs, dropping it if the queue is full
+func (p *Purger) Enqueue(urls ...string) {
+	if len(urls) == 0 {
+		return
+	}
+	if !p.queue.Push(urls) {
+		log.Printf("purge queue full or closed, dropping purge of %s", strings.Join(urls, ", "))
+	}
+}
+
+// Flush waits until queued purges have been sent or ctx is done
+func (p *Purger) Flush(ctx context.Context) error {
+	return p.queue.Flush(ctx)
+}
+
+// Shutdown stops accepting purges and waits for queued ones to be sent
+// until ctx is done
+func (p *Purger) Shutdown(ctx context.Context) error {
+	return p.queue.Shutdown(ctx)
+}
+
+// Close stops accepting purges and waits for queued ones to be sent
+func (p *Purger) Close() {
+	p.queue.Close()
+}
+
+// purge sends a queued purge to the backend
+func (p *Purger) purge(urls []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	if err := p.backend.Purge(ctx, urls); err != nil {
+		log.Printf("failed to purge %s: %v", strings.Join(urls, ", "), err)
+	}
+}
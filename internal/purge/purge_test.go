@@ -0,0 +1,117 @@
+package purge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingBackend records the URLs it is asked to purge
+type recordingBackend struct {
+	mu   sync.Mutex
+	urls []string
+	err  error
+}
+
+func (b *recordingBackend) Purge(_ context.Context, urls []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.urls = append(b.urls, urls...)
+	return b.err
+}
+
+func TestPurger(t *testing.T) {
+	backend := &recordingBackend{err: errors.New("unavailable")}
+	p := New(backend, 10)
+
+	p.Enqueue("https://sho.rt/abc", "https://go.example.com/abc")
+	p.Enqueue()
+	p.Enqueue("https://sho.rt/def")
+	require.NoError(t, p.Flush(context.Background()))
+
+	// Failures are logged and later purges still go out
+	assert.Equal(t, []string{"https://sho.rt/abc", "https://go.example.com/abc", "https://sho.rt/def"}, backend.urls)
+
+	p.Close()
+	p.Enqueue("https://sho.rt/ghi")
+	assert.Len(t, backend.urls, 3)
+}
+
+func TestCloudflare_Purge(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/zones/zone1/purge_cache", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var body struct {
+			Files []string `json:"files"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		batches = append(batches, body.Files)
+		w.Write([]byte(`{"success":true}`))
+	}))
+	defer server.Close()
+
+	cf := &Cloudflare{ZoneID: "zone1", Token: "secret", Endpoint: server.URL}
+	urls := make([]string, 31)
+	for i := range urls {
+		urls[i] = "https://sho.rt/" + strconv.Itoa(i)
+	}
+	require.NoError(t, cf.Purge(context.Background(), urls))
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 30)
+	assert.Equal(t, []string{"https://sho.rt/30"}, batches[1])
+
+	cf.Token = "wrong"
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	assert.ErrorIs(t, cf.Purge(context.Background(), urls[:1]), ErrUnexpectedStatus)
+}
+
+func TestFastly_Purge(t *testing.T) {
+	var purged []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PURGE", r.Method)
+		assert.Equal(t, "secret", r.Header.Get("Fastly-Key"))
+		purged = append(purged, r.URL.Path)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	f := &Fastly{Token: "secret"}
+	require.NoError(t, f.Purge(context.Background(), []string{server.URL + "/abc"}))
+
+	// Every URL is attempted even when one fails
+	err := f.Purge(context.Background(), []string{server.URL + "/missing", server.URL + "/def"})
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+	assert.Equal(t, []string{"/abc", "/missing", "/def"}, purged)
+}
+
+func TestNewBackend(t *testing.T) {
+	b, err := NewBackend("Cloudflare", Config{Token: "t", ZoneID: "z"})
+	require.NoError(t, err)
+	assert.IsType(t, &Cloudflare{}, b)
+
+	b, err = NewBackend("fastly", Config{Token: "t"})
+	require.NoError(t, err)
+	assert.IsType(t, &Fastly{}, b)
+
+	_, err = NewBackend("cloudflare", Config{Token: "t"})
+	assert.ErrorIs(t, err, ErrMissingSetting)
+	_, err = NewBackend("fastly", Config{})
+	assert.ErrorIs(t, err, ErrMissingSetting)
+	_, err = NewBackend("akamai", Config{Token: "t"})
+	assert.Equal(t, ErrUnknownBackend, err)
+}
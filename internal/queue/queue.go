@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flushPollInterval is how often Flush checks whether the queue has drained
+const flushPollInterval = 10 * time.Millisecond
+
+// dropped counts items discarded by each named queue, published at /debug/vars
+var dropped = expvar.NewMap("queue_dropped")
+
+// Queue hands items to a single background worker. Producers never block:
+// items pushed while the queue is full or shut down are dropped and counted.
+type Queue[T any] struct {
+	name    string
+	items   chan T
+	handle  func(T)
+	pending atomic.Int64
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New creates a queue holding up to size items and starts its worker
+func New[T any](name string, size int, handle func(T)) *Queue[T] {
+	q := &Queue[T]{
+		name:   name,
+		items:  make(chan T, size),
+		handle: handle,
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Push queues an item, reporting false if it was dropped
+func (q *Queue[T]) Push(item T) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		dropped.Add(q.name, 1)
+		return false
+	}
+
+	q.pending.Add(1)
+	select {
+	case q.items <- item:
+		return true
+	default:
+		q.pending.Add(-1)
+		dropped.Add(q.name, 1)
+		return false
+	}
+}
+
+// Flush waits until every queued item has been handled or ctx is done
+func (q *Queue[T]) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+
+	for q.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting items and waits for queued ones to be handled.
+// Items still pending when ctx is done are counted as dropped.
+func (q *Queue[T]) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		dropped.Add(q.name, q.pending.Load())
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting items and waits for every queued one to be handled
+func (q *Queue[T]) Close() {
+	q.Shutdown(context.Background())
+}
+
+// Dropped returns the number of items the named queue has dropped
+func Dropped(name string) int64 {
+	if v, ok := dropped.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// run handles queued items until the queue is shut down
+func (q *Queue[T]) run() {
+	defer close(q.done)
+
+	for item := range q.items {
+		q.handle(item)
+		q.pending.Add(-1)
+	}
+}
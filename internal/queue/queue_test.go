@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_Flush(t *testing.T) {
+	var handled atomic.Int64
+	q := New("test-flush", 10, func(int) {
+		time.Sleep(time.Millisecond)
+		handled.Add(1)
+	})
+	defer q.Close()
+
+	for i := 0; i < 5; i++ {
+		require.True(t, q.Push(i))
+	}
+	require.NoError(t, q.Flush(context.Background()))
+	assert.Equal(t, int64(5), handled.Load())
+
+	// The queue keeps accepting items after a flush
+	assert.True(t, q.Push(5))
+}
+
+func TestQueue_Full(t *testing.T) {
+	release := make(chan struct{})
+	q := New("test-full", 1, func(int) { <-release })
+
+	// The worker holds the first item and the buffer the second
+	require.True(t, q.Push(1))
+	require.Eventually(t, func() bool { return len(q.items) == 0 }, time.Second, time.Millisecond)
+	require.True(t, q.Push(2))
+	assert.False(t, q.Push(3))
+	assert.Equal(t, int64(1), Dropped("test-full"))
+
+	close(release)
+	q.Close()
+
+	// Items pushed after shutdown are dropped
+	assert.False(t, q.Push(4))
+	assert.Equal(t, int64(2), Dropped("test-full"))
+}
+
+func TestQueue_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	q := New("test-deadline", 10, func(int) { <-release })
+
+	for i := 0; i < 3; i++ {
+		require.True(t, q.Push(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Flush(ctx), context.DeadlineExceeded)
+
+	// Items left when the deadline passes are counted as dropped
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Equal(t, int64(3), Dropped("test-deadline"))
+}
@@ -0,0 +1,63 @@
+package rules
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// languageTagPattern matches BCP 47 language tags such as "en", "pt-BR" or
+// "zh-Hant-TW"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// ValidLanguage reports whether tag is a BCP 47 language tag
+func ValidLanguage(tag string) bool {
+	return languageTagPattern.MatchString(tag)
+}
+
+// PreferredLanguages returns the language tags of an Accept-Language
+// header, most preferred first. The wildcard and tags with a weight of 0
+// are left out.
+func PreferredLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			langs = append(langs, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// LanguageMatches reports whether a visitor's language falls under a
+// rule's language, ignoring case. A rule for "en" matches "en-US" too,
+// while a rule for "en-US" only matches that.
+func LanguageMatches(lang, ruleLang string) bool {
+	if len(lang) > len(ruleLang) && lang[len(ruleLang)] == '-' {
+		lang = lang[:len(ruleLang)]
+	}
+	return strings.EqualFold(lang, ruleLang)
+}
@@ -0,0 +1,248 @@
+// Package rules evaluates declarative redirect rules: ordered conditions on
+// the visitor's country, device, language and the time of the visit, each
+// leading to a destination
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+// MaxRules is the maximum number of rules per link
+const MaxRules = 20
+
+// ErrTooManyRules is returned for rule lists longer than MaxRules
+var ErrTooManyRules = errors.New("at most 20 rules")
+
+// weekdays are the names of the days of the week in conditions
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Rule sends visitors matching its condition to URL
+type Rule struct {
+	Name string    `json:"name,omitempty"`
+	When Condition `json:"when"`
+	URL  string    `json:"url"`
+}
+
+// Condition is what a visit must satisfy for a rule to apply. A visit
+// matches when it satisfies every field that is set, and a list field when
+// it matches any of its values; an empty condition matches every visit.
+type Condition struct {
+	// Countries are ISO 3166 country codes, as reported by the CDN
+	Countries []string `json:"countries,omitempty"`
+
+	// Platforms are device platforms, "mobile" matching every mobile one
+	Platforms []useragent.Platform `json:"platforms,omitempty"`
+
+	// Languages are BCP 47 tags matched against the visitor's most
+	// preferred language. "pt" matches "pt-BR" too.
+	Languages []string `json:"languages,omitempty"`
+
+	// After and Before bound the time of the visit
+	After  *time.Time `json:"after,omitempty"`
+	Before *time.Time `json:"before,omitempty"`
+
+	// Weekdays ("mon" to "sun") and Hours ("09:00-17:00", wrapping past
+	// midnight when the end is earlier) are in TimeZone, UTC by default
+	Weekdays []string `json:"weekdays,omitempty"`
+	Hours    string   `json:"hours,omitempty"`
+	TimeZone string   `json:"time_zone,omitempty"`
+}
+
+// Request is what rules are evaluated against
+type Request struct {
+	Country        string    `json:"country"`
+	UserAgent      string    `json:"user_agent"`
+	AcceptLanguage string    `json:"accept_language"`
+	Time           time.Time `json:"time"`
+}
+
+// Evaluate returns the index of the first rule matching the request
+func Evaluate(rules []Rule, req Request) (int, bool) {
+	var platform useragent.Platform
+	var language string
+	for i, rule := range rules {
+		when := rule.When
+		if len(when.Platforms) > 0 && platform == "" {
+			platform = useragent.Detect(req.UserAgent)
+		}
+		if len(when.Languages) > 0 && language == "" {
+			if langs := PreferredLanguages(req.AcceptLanguage); len(langs) > 0 {
+				language = langs[0]
+			}
+		}
+		if when.matches(req, platform, language) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// matches reports whether a visit satisfies the condition
+func (c Condition) matches(req Request, platform useragent.Platform, language string) bool {
+	if len(c.Countries) > 0 && !anyOf(c.Countries, func(country string) bool { return strings.EqualFold(country, req.Country) }) {
+		return false
+	}
+	if len(c.Platforms) > 0 && !anyOf(c.Platforms, platform.Matches) {
+		return false
+	}
+	if len(c.Languages) > 0 && !anyOf(c.Languages, func(lang string) bool { return language != "" && LanguageMatches(language, lang) }) {
+		return false
+	}
+	if c.After != nil && req.Time.Before(*c.After) {
+		return false
+	}
+	if c.Before != nil && !req.Time.Before(*c.Before) {
+		return false
+	}
+	if len(c.Weekdays) == 0 && c.Hours == "" {
+		return true
+	}
+
+	loc, err := location(c.TimeZone)
+	if err != nil {
+		return false
+	}
+	local := req.Time.In(loc)
+	if len(c.Weekdays) > 0 && !anyOf(c.Weekdays, func(day string) bool { return weekdays[strings.ToLower(day)] == local.Weekday() }) {
+		return false
+	}
+	if c.Hours != "" {
+		from, to, err := parseHours(c.Hours)
+		if err != nil {
+			return false
+		}
+		minute := local.Hour()*60 + local.Minute()
+		if from <= to {
+			return minute >= from && minute < to
+		}
+		return minute >= from || minute < to
+	}
+	return true
+}
+
+// Validate checks rules supplied by a link owner, describing the first
+// problem found
+func Validate(rules []Rule) error {
+	if len(rules) > MaxRules {
+		return ErrTooManyRules
+	}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (r Rule) validate() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("url must be absolute with http(s) scheme")
+	}
+	c := r.When
+	for _, country := range c.Countries {
+		if len(country) != 2 || !isLetters(country) {
+			return fmt.Errorf("invalid country %q", country)
+		}
+	}
+	for _, platform := range c.Platforms {
+		if !platform.Valid() {
+			return fmt.Errorf("invalid platform %q", platform)
+		}
+	}
+	for _, lang := range c.Languages {
+		if !ValidLanguage(lang) {
+			return fmt.Errorf("invalid language %q", lang)
+		}
+	}
+	if c.After != nil && c.Before != nil && !c.Before.After(*c.After) {
+		return errors.New("before must be after after")
+	}
+	for _, day := range c.Weekdays {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+	if c.Hours != "" {
+		if from, to, err := parseHours(c.Hours); err != nil || from == to {
+			return fmt.Errorf("invalid hours %q", c.Hours)
+		}
+	}
+	if _, err := location(c.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", c.TimeZone)
+	}
+	return nil
+}
+
+// parseHours parses a range of hours such as "09:00-17:00" into minutes of
+// the day
+func parseHours(spec string) (int, int, error) {
+	fromSpec, toSpec, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, errors.New("hours must be HH:MM-HH:MM")
+	}
+	from, err := time.Parse("15:04", strings.TrimSpace(fromSpec))
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := time.Parse("15:04", strings.TrimSpace(toSpec))
+	if err != nil {
+		return 0, 0, err
+	}
+	return from.Hour()*60 + from.Minute(), to.Hour()*60 + to.Minute(), nil
+}
+
+// locations caches time zones by name, as loading one reads the zone
+// database
+var locations sync.Map
+
+// location returns the time zone of a condition, UTC if unset
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// anyOf reports whether any value satisfies match
+func anyOf[T any](values []T, match func(T) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLetters reports whether s consists of ASCII letters only
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/prayushdave/url-shortener/internal/useragent"
+)
+
+const iphone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"
+
+func TestEvaluate(t *testing.T) {
+	launch := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	rules := []Rule{
+		{Name: "launch", When: Condition{After: &launch}, URL: "https://example.com/launch"},
+		{Name: "german ios", When: Condition{Countries: []string{"DE", "AT"}, Platforms: []useragent.Platform{useragent.IOS}}, URL: "https://example.com/de-ios"},
+		{Name: "portuguese", When: Condition{Languages: []string{"pt"}}, URL: "https://example.com/pt"},
+		{Name: "office hours", When: Condition{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: "09:00-17:00", TimeZone: "Europe/Berlin"}, URL: "https://example.com/support"},
+		{Name: "nights", When: Condition{Hours: "22:00-06:00"}, URL: "https://example.com/night"},
+	}
+	// A Wednesday, 10:00 in Berlin
+	wednesday := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		req  Request
+		want int
+	}{
+		{"time bound", Request{Time: launch.Add(time.Hour)}, 0},
+		{"country and platform", Request{Country: "at", UserAgent: iphone, Time: wednesday}, 1},
+		{"country without platform", Request{Country: "DE", Time: wednesday}, 3},
+		{"most preferred language", Request{AcceptLanguage: "pt-BR, en;q=0.8", Time: wednesday}, 2},
+		{"less preferred language", Request{AcceptLanguage: "en, pt;q=0.8", Time: wednesday}, 3},
+		{"weekday and hours in time zone", Request{Time: wednesday}, 3},
+		{"hours wrapping midnight", Request{Time: time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)}, 4},
+		{"no match", Request{Time: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, ok := Evaluate(rules, tt.req)
+			assert.Equal(t, tt.want, i)
+			assert.Equal(t, tt.want >= 0, ok)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	after := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	before := after.Add(-time.Hour)
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate([]Rule{{When: Condition{Countries: []string{"us"}, Hours: "22:00-06:00", TimeZone: "America/New_York"}, URL: "https://example.com"}}))
+
+	for name, rule := range map[string]Rule{
+		"url":       {URL: "ftp://example.com"},
+		"country":   {When: Condition{Countries: []string{"USA"}}, URL: "https://example.com"},
+		"platform":  {When: Condition{Platforms: []useragent.Platform{"toaster"}}, URL: "https://example.com"},
+		"language":  {When: Condition{Languages: []string{"en_US"}}, URL: "https://example.com"},
+		"window":    {When: Condition{After: &after, Before: &before}, URL: "https://example.com"},
+		"weekday":   {When: Condition{Weekdays: []string{"someday"}}, URL: "https://example.com"},
+		"hours":     {When: Condition{Hours: "9-5"}, URL: "https://example.com"},
+		"time zone": {When: Condition{TimeZone: "Mars/Olympus"}, URL: "https://example.com"},
+	} {
+		assert.Error(t, Validate([]Rule{rule}), name)
+	}
+	assert.ErrorIs(t, Validate(make([]Rule, MaxRules+1)), ErrTooManyRules)
+}
+
+func TestPreferredLanguages(t *testing.T) {
+	assert.Equal(t, []string{"fr-CH", "fr", "en", "de"}, PreferredLanguages("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"))
+	assert.Equal(t, []string{"de", "en"}, PreferredLanguages("en;q=0.5, de, it;q=0"))
+	assert.Empty(t, PreferredLanguages(""))
+}
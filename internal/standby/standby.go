@@ -0,0 +1,281 @@
+package standby
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+const (
+	// DefaultTopN is the default number of hot keys to export
+	DefaultTopN = 1000
+
+	// DefaultInterval is the default time between exports
+	DefaultInterval = time.Minute
+)
+
+// Snapshot is a point-in-time export of the hottest URL mappings
+type Snapshot struct {
+	CreatedAt time.Time        `json:"created_at"`
+	Entries   []storage.HotKey `json:"entries"`
+}
+
+// Source provides the hottest URL mappings
+type Source interface {
+	TopKeys(ctx context.Context, n int) ([]storage.HotKey, error)
+}
+
+// Sink receives snapshots for safekeeping
+type Sink interface {
+	Write(ctx context.Context, snap *Snapshot) error
+}
+
+// Exporter periodically copies the hottest keys from a Source to one or more Sinks
+type Exporter struct {
+	source   Source
+	sinks    []Sink
+	topN     int
+	interval time.Duration
+}
+
+// NewExporter creates a new Exporter instance
+func NewExporter(source Source, topN int, interval time.Duration, sinks ...Sink) *Exporter {
+	if topN <= 0 {
+		topN = DefaultTopN
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return &Exporter{
+		source:   source,
+		sinks:    sinks,
+		topN:     topN,
+		interval: interval,
+	}
+}
+
+// Export takes a single snapshot and writes it to every sink
+func (e *Exporter) Export(ctx context.Context) error {
+	entries, err := e.source.TopKeys(ctx, e.topN)
+	if err != nil {
+		return err
+	}
+
+	snap := &Snapshot{
+		CreatedAt: time.Now().UTC(),
+		Entries:   entries,
+	}
+
+	var errs []error
+	for _, sink := range e.sinks {
+		if err := sink.Write(ctx, snap); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run exports snapshots on every interval until ctx is cancelled
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Export(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("standby export failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FileSink writes snapshots to a JSON file, replacing it atomically
+type FileSink struct {
+	path string
+}
+
+// NewFileSink creates a new FileSink instance
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+// Write stores the snapshot in the sink's file
+func (f *FileSink) Write(_ context.Context, snap *Snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := json.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// StoreSink replicates snapshot entries into a secondary Store
+type StoreSink struct {
+	store storage.Store
+}
+
+// NewStoreSink creates a new StoreSink instance
+func NewStoreSink(store storage.Store) *StoreSink {
+	return &StoreSink{store: store}
+}
+
+// Write stores every snapshot entry that is missing from the secondary store
+func (s *StoreSink) Write(ctx context.Context, snap *Snapshot) error {
+	for _, entry := range snap.Entries {
+		err := s.store.Create(ctx, entry.Key, &storage.Record{
+			URL:         entry.URL,
+			Domain:      entry.Domain,
+			ActiveFrom:  entry.ActiveFrom,
+			ActiveUntil: entry.ActiveUntil,
+			CreatedAt:   time.Now().UTC(),
+		})
+		if err != nil && err != storage.ErrKeyExists {
+			return err
+		}
+	}
+	return nil
+}
+
+// Store serves lookups from a snapshot file when the primary store is
+// unavailable, reloading the file as exports replace it
+type Store struct {
+	path string
+
+	mu        sync.RWMutex
+	entries   map[string]storage.HotKey
+	createdAt time.Time
+	modTime   time.Time
+}
+
+// NewStore creates a Store for a snapshot file, empty until Reload
+func NewStore(path string) *Store {
+	return &Store{path: path, entries: map[string]storage.HotKey{}}
+}
+
+// Load reads a snapshot file written by FileSink
+func Load(path string) (*Store, error) {
+	s := NewStore(path)
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the snapshot file again if it changed since it was last
+// read. A snapshot that can't be read leaves the current one in place.
+func (s *Store) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(f).Decode(&snap); err != nil {
+		return err
+	}
+
+	entries := make(map[string]storage.HotKey, len(snap.Entries))
+	for _, entry := range snap.Entries {
+		entries[entry.Key] = entry
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.createdAt = snap.CreatedAt
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the snapshot file on every interval until ctx is cancelled
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("standby snapshot reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// Get retrieves a URL mapping from the snapshot
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	rec, err := s.GetRecord(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return rec.URL, nil
+}
+
+// GetRecord retrieves a link from the snapshot, with what keeps it to
+// where and when it is served
+func (s *Store) GetRecord(_ context.Context, key string) (*storage.Record, error) {
+	s.mu.RLock()
+	entry, ok := s.entries[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &storage.Record{
+		URL:         entry.URL,
+		Domain:      entry.Domain,
+		ActiveFrom:  entry.ActiveFrom,
+		ActiveUntil: entry.ActiveUntil,
+	}, nil
+}
+
+// Len returns the number of mappings in the snapshot
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// CreatedAt returns the time the snapshot was taken
+func (s *Store) CreatedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.createdAt
+}
@@ -0,0 +1,107 @@
+package standby
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prayushdave/url-shortener/internal/storage"
+)
+
+// staticSource is a Source returning a fixed set of hot keys
+type staticSource struct {
+	keys []storage.HotKey
+	err  error
+}
+
+func (s staticSource) TopKeys(_ context.Context, n int) ([]storage.HotKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if n < len(s.keys) {
+		return s.keys[:n], nil
+	}
+	return s.keys, nil
+}
+
+func TestExporter_FileSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "standby.json")
+	source := staticSource{keys: []storage.HotKey{
+		{Key: "aB1cD2eF", URL: "https://example.com/a", Hits: 10},
+		{Key: "gH3iJ4kL", URL: "https://example.com/b", Hits: 5},
+		{Key: "mN5oP6qR", URL: "https://example.com/c", Hits: 1},
+	}}
+
+	exporter := NewExporter(source, 2, time.Minute, NewFileSink(path))
+	require.NoError(t, exporter.Export(context.Background()))
+
+	snapshot, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, snapshot.Len())
+	assert.False(t, snapshot.CreatedAt().IsZero())
+
+	url, err := snapshot.Get(context.Background(), "aB1cD2eF")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", url)
+
+	// Keys outside the top N are not exported
+	_, err = snapshot.Get(context.Background(), "mN5oP6qR")
+	assert.Equal(t, storage.ErrNotFound, err)
+}
+
+func TestStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "standby.json")
+	sink := NewFileSink(path)
+	ctx := context.Background()
+	until := time.Now().Add(time.Hour).UTC()
+
+	require.NoError(t, sink.Write(ctx, &Snapshot{Entries: []storage.HotKey{{Key: "aB1cD2eF", URL: "https://example.com/a"}}}))
+	snapshot, err := Load(path)
+	require.NoError(t, err)
+
+	// A newer export replaces the snapshot on reload
+	require.NoError(t, sink.Write(ctx, &Snapshot{Entries: []storage.HotKey{
+		{Key: "gH3iJ4kL", URL: "https://example.com/b", Domain: "go.example.com", ActiveUntil: &until},
+	}}))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	require.NoError(t, snapshot.Reload())
+
+	_, err = snapshot.GetRecord(ctx, "aB1cD2eF")
+	assert.Equal(t, storage.ErrNotFound, err)
+	rec, err := snapshot.GetRecord(ctx, "gH3iJ4kL")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", rec.URL)
+	assert.Equal(t, "go.example.com", rec.Domain)
+	assert.True(t, until.Equal(*rec.ActiveUntil))
+
+	// A snapshot that can't be read leaves the current one in place
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	later = later.Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	assert.Error(t, snapshot.Reload())
+	assert.Equal(t, 1, snapshot.Len())
+}
+
+func TestExporter_SourceError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "standby.json")
+	exporter := NewExporter(staticSource{err: errors.New("boom")}, 10, time.Minute, NewFileSink(path))
+
+	assert.Error(t, exporter.Export(context.Background()))
+
+	// A failed export must not leave a snapshot behind
+	_, err := Load(path)
+	assert.Error(t, err)
+}
+
+func TestNewExporter_Defaults(t *testing.T) {
+	exporter := NewExporter(staticSource{}, 0, 0)
+	assert.Equal(t, DefaultTopN, exporter.topN)
+	assert.Equal(t, DefaultInterval, exporter.interval)
+}
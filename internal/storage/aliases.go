@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// aliasKeyPrefix prefixes the key a renamed link's former key redirects
+	// to, expiring with its grace period
+	aliasKeyPrefix = "alias:"
+
+	// DefaultAliasGrace is the default time a renamed link's former key
+	// keeps redirecting to it
+	DefaultAliasGrace = 30 * 24 * time.Hour
+
+	// maxAliases is the number of former keys kept in a link's history
+	maxAliases = 20
+)
+
+// ErrLinkChanged is returned when a link changes while it is renamed
+var ErrLinkChanged = errors.New("link changed while it was renamed")
+
+// Alias is a former key of a renamed link, redirecting to it until Until
+type Alias struct {
+	Key       string    `json:"key"`
+	RenamedAt time.Time `json:"renamed_at"`
+	Until     time.Time `json:"until"`
+}
+
+// AliasStore renames links, keeping their former keys redirecting to them
+type AliasStore interface {
+	Rename(ctx context.Context, key, newKey string, grace time.Duration) (*Record, error)
+	ResolveAlias(ctx context.Context, key string) (string, error)
+}
+
+// renameScript moves a link to a new key unless the key is taken or the
+// link changed since it was read, moves what tracks it along, and points
+// its former key and the aliases still in their grace period at the new
+// key. KEYS are the link, its new key, and then for the former and the new
+// key in turn their aliases, claimed clicks, counters, statistics periods,
+// prefix of archived periods and visits, followed by ARGV[5] pairs of
+// former and new aggregates and the live aliases. ARGV are the value read,
+// the new value, the link's TTL in milliseconds or 0 if it never expires,
+// the grace period in milliseconds and the number of aggregates.
+var renameScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return -1
+end
+local function move(from, to)
+	if redis.call('EXISTS', from) == 1 then
+		redis.call('RENAME', from, to)
+	else
+		redis.call('DEL', to)
+	end
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+redis.call('DEL', KEYS[1])
+redis.call('SET', KEYS[3], KEYS[2], 'PX', ARGV[4])
+redis.call('DEL', KEYS[4])
+local stale = tonumber(redis.call('GET', KEYS[10]) or '0')
+for period = 1, stale do
+	redis.call('DEL', KEYS[12] .. period)
+end
+local periods = tonumber(redis.call('GET', KEYS[9]) or '0')
+for period = 1, periods do
+	move(KEYS[11] .. period, KEYS[12] .. period)
+end
+for i = 5, 13, 2 do
+	if i ~= 11 then
+		move(KEYS[i], KEYS[i + 1])
+	end
+end
+local aggregates = tonumber(ARGV[5])
+for i = 15, 14 + 2 * aggregates, 2 do
+	move(KEYS[i], KEYS[i + 1])
+end
+for i = 15 + 2 * aggregates, #KEYS do
+	redis.call('SET', KEYS[i], KEYS[2], 'XX', 'KEEPTTL')
+end
+return 1
+`)
+
+// Rename moves a link to newKey, along with its indexes, click counters,
+// claimed clicks, visits and aggregated rollups, and has its former key
+// redirect to it for grace. The link's former keys still in their grace
+// period redirect to the new key too. It returns ErrKeyExists if newKey is
+// taken and ErrLinkChanged if the link changed meanwhile.
+func (s *RedisStore) Rename(ctx context.Context, key, newKey string, grace time.Duration) (*Record, error) {
+	if grace <= 0 {
+		grace = DefaultAliasGrace
+	}
+
+	var value *redis.StringCmd
+	var ttl *redis.DurationCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		value = pipe.Get(ctx, key)
+		ttl = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if value.Err() == redis.Nil {
+		return nil, ErrNotFound
+	}
+	rec, err := decodeRecord(value.Val())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	keys := []string{
+		key, newKey, aliasKeyPrefix + key, aliasKeyPrefix + newKey,
+		claimedClicksKey(key), claimedClicksKey(newKey),
+		statsKeyPrefix + key, statsKeyPrefix + newKey,
+		statsKeyPrefix + key + periodsSuffix, statsKeyPrefix + newKey + periodsSuffix,
+		statsKeyPrefix + key + periodSuffix, statsKeyPrefix + newKey + periodSuffix,
+		visitsKey(key), visitsKey(newKey),
+	}
+	var aggregates int
+	if s.rollups != nil {
+		former, renamed := rollupKeys(key, *s.rollups, now), rollupKeys(newKey, *s.rollups, now)
+		for i := range former {
+			keys = append(keys, former[i], renamed[i])
+		}
+		aggregates = len(former)
+	}
+	aliases := []Alias{{Key: key, RenamedAt: now, Until: now.Add(grace)}}
+	for _, a := range rec.Aliases {
+		if a.Key == newKey {
+			continue
+		}
+		if a.Until.After(now) {
+			keys = append(keys, aliasKeyPrefix+a.Key)
+		}
+		if len(aliases) < maxAliases {
+			aliases = append(aliases, a)
+		}
+	}
+	rec.Aliases = aliases
+	encoded, err := encodeRecord(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := ttl.Val()
+	if expiry < 0 {
+		expiry = 0
+	}
+	n, err := renameScript.Run(ctx, s.client, keys, value.Val(), encoded, expiry.Milliseconds(), grace.Milliseconds(), aggregates).Int()
+	s.invalidate(key)
+	s.invalidate(newKey)
+	if err != nil {
+		return nil, err
+	}
+	switch n {
+	case 0:
+		return nil, ErrKeyExists
+	case -1:
+		return nil, ErrLinkChanged
+	}
+	s.found(newKey)
+
+	// Move the link's indexes to its new key. This is best effort, like
+	// cleaning up after a deletion.
+	s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		unindexTags(ctx, pipe, key, rec.Tags)
+		unindexScope(ctx, pipe, key, rec)
+		pipe.SRem(ctx, fallbackLinksKey, key)
+		pipe.ZRem(ctx, hotKeysKey, key)
+		pipe.ZRem(ctx, expiryIndexKey, key)
+		pipe.Del(ctx, expiryReminderKeyPrefix+key)
+		indexTags(ctx, pipe, newKey, rec.Tags)
+		indexScope(ctx, pipe, newKey, rec)
+		if len(rec.Fallbacks) > 0 {
+			indexFallbacks(ctx, pipe, newKey, rec)
+		}
+		if expiry > 0 {
+			indexExpiry(ctx, pipe, newKey, time.Now().Add(expiry))
+		}
+		return nil
+	})
+	return rec, nil
+}
+
+// ResolveAlias returns the key a renamed link's former key redirects to,
+// or ErrNotFound once its grace period is over
+func (s *RedisStore) ResolveAlias(ctx context.Context, key string) (string, error) {
+	newKey, err := s.client.Get(ctx, aliasKeyPrefix+key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return newKey, err
+}
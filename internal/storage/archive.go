@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// archiveCursorKey holds the ID of the last click event archived
+	archiveCursorKey = "archive:cursor"
+
+	// archiveLeaseKey holds the token of the instance archiving click events
+	archiveLeaseKey = "archive:lease"
+)
+
+// ClickEvent is a raw click event as archived
+type ClickEvent struct {
+	ID       string    `json:"id"`
+	Key      string    `json:"key"`
+	Time     time.Time `json:"time"`
+	Country  string    `json:"country,omitempty"`
+	Referrer string    `json:"referrer,omitempty"`
+	Channel  string    `json:"channel,omitempty"`
+	Device   string    `json:"device,omitempty"`
+	OS       string    `json:"os,omitempty"`
+	Browser  string    `json:"browser,omitempty"`
+}
+
+// ArchiveStore represents the storage interface for archiving raw click
+// events before they are trimmed
+type ArchiveStore interface {
+	ClaimArchive(ctx context.Context, ttl time.Duration) (string, error)
+	ReleaseArchive(ctx context.Context, token string) error
+	UnarchivedClickEvents(ctx context.Context, n int) ([]ClickEvent, error)
+	MarkArchived(ctx context.Context, id string) error
+}
+
+// EnableArchive keeps raw click events until they are archived, as well as
+// aggregated. It requires rollups, which record the events, and must be
+// called before the store is used.
+func (s *RedisStore) EnableArchive() {
+	s.archive = true
+}
+
+// ClaimArchive claims the right to archive click events for ttl, so only one
+// instance uploads each event. It returns the token releasing the claim, or
+// "" if another instance holds it.
+func (s *RedisStore) ClaimArchive(ctx context.Context, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := s.client.SetNX(ctx, archiveLeaseKey, token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+// ReleaseArchive frees a claim on archiving if the token still holds it
+func (s *RedisStore) ReleaseArchive(ctx context.Context, token string) error {
+	return releaseWorkerScript.Run(ctx, s.client, []string{archiveLeaseKey}, token).Err()
+}
+
+// UnarchivedClickEvents returns up to n of the oldest click events not yet
+// archived
+func (s *RedisStore) UnarchivedClickEvents(ctx context.Context, n int) ([]ClickEvent, error) {
+	start := "-"
+	cursor, err := s.client.Get(ctx, archiveCursorKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if cursor != "" {
+		start = "(" + cursor
+	}
+
+	messages, err := s.client.XRangeN(ctx, clickEventsKey, start, "+", int64(n)).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ClickEvent, len(messages))
+	for i, m := range messages {
+		unix, _ := strconv.ParseInt(stringValue(m.Values["time"]), 10, 64)
+		events[i] = ClickEvent{
+			ID:       m.ID,
+			Key:      stringValue(m.Values["key"]),
+			Time:     time.Unix(unix, 0).UTC(),
+			Country:  stringValue(m.Values["country"]),
+			Referrer: stringValue(m.Values["referrer"]),
+			Channel:  stringValue(m.Values["channel"]),
+			Device:   stringValue(m.Values["device"]),
+			OS:       stringValue(m.Values["os"]),
+			Browser:  stringValue(m.Values["browser"]),
+		}
+	}
+	return events, nil
+}
+
+// MarkArchived records that click events up to and including id are
+// archived, so they may be trimmed
+func (s *RedisStore) MarkArchived(ctx context.Context, id string) error {
+	return s.client.Set(ctx, archiveCursorKey, id, 0).Err()
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// auditLogKey is the stream of audit entries. It is never trimmed.
+	auditLogKey = "audit:log"
+
+	// auditBatchSize is the number of entries read per scan of the log
+	auditBatchSize = 1000
+
+	// MaxAuditLimit is the most audit entries returned by one query
+	MaxAuditLimit = 500
+)
+
+// AuditAction is the kind of change an audit entry records
+type AuditAction string
+
+const (
+	AuditCreate     AuditAction = "create"
+	AuditImport     AuditAction = "import"
+	AuditRestore    AuditAction = "restore"
+	AuditUpdate     AuditAction = "update"
+	AuditDelete     AuditAction = "delete"
+	AuditDisable    AuditAction = "disable"
+	AuditEnable     AuditAction = "enable"
+	AuditVerify     AuditAction = "verify"
+	AuditExtend     AuditAction = "extend"
+	AuditResetStats AuditAction = "reset_stats"
+	AuditRename     AuditAction = "rename"
+	AuditConsume    AuditAction = "consume"
+
+	AuditCreatePage AuditAction = "create_page"
+	AuditUpdatePage AuditAction = "update_page"
+	AuditDeletePage AuditAction = "delete_page"
+)
+
+// ErrInvalidCursor is returned for audit cursors that aren't entry IDs
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// auditIDPattern matches stream entry IDs
+var auditIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+
+// AuditEntry records a change to a link: who made it, from where, and the
+// link before and after the change. Before is empty for creations and After
+// for deletions.
+type AuditEntry struct {
+	ID     string          `json:"id"`
+	Time   time.Time       `json:"time"`
+	Actor  string          `json:"actor,omitempty"`
+	IP     string          `json:"ip,omitempty"`
+	Action AuditAction     `json:"action"`
+	Key    string          `json:"key"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditQuery filters the audit log. Empty fields match every entry. Before
+// is the ID of an entry returned by a previous query; only older entries
+// are returned, so queries can page backwards through the log.
+type AuditQuery struct {
+	Key    string
+	Actor  string
+	Action AuditAction
+	Since  time.Time
+	Until  time.Time
+	Before string
+	Limit  int
+}
+
+// AuditStore represents the storage interface for the append-only audit log
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entries ...*AuditEntry) error
+	ListAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+}
+
+// AppendAudit appends entries to the audit log, setting their IDs
+func (s *RedisStore) AppendAudit(ctx context.Context, entries ...*AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(entries))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, e := range entries {
+			e.ID = ""
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			cmds[i] = pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: auditLogKey,
+				Values: []interface{}{"entry", string(data)},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, cmd := range cmds {
+		entries[i].ID = cmd.Val()
+	}
+	return nil
+}
+
+// ListAudit returns the entries matching a query, newest first. The log is
+// scanned in batches until enough entries match, so selective filters over
+// long histories should be bounded with Since.
+func (s *RedisStore) ListAudit(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	if q.Before != "" && !auditIDPattern.MatchString(q.Before) {
+		return nil, ErrInvalidCursor
+	}
+	if q.Limit <= 0 || q.Limit > MaxAuditLimit {
+		q.Limit = MaxAuditLimit
+	}
+
+	end := "+"
+	if !q.Until.IsZero() {
+		end = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+	if q.Before != "" {
+		end = "(" + q.Before
+	}
+	start := "-"
+	if !q.Since.IsZero() {
+		start = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+
+	entries := []AuditEntry{}
+	for len(entries) < q.Limit {
+		messages, err := s.client.XRevRangeN(ctx, auditLogKey, end, start, auditBatchSize).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			data, _ := m.Values["entry"].(string)
+			var e AuditEntry
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				continue
+			}
+			e.ID = m.ID
+			if q.matches(&e) {
+				entries = append(entries, e)
+				if len(entries) == q.Limit {
+					break
+				}
+			}
+		}
+		if len(messages) < auditBatchSize {
+			break
+		}
+		end = "(" + messages[len(messages)-1].ID
+	}
+	return entries, nil
+}
+
+// matches reports whether an entry passes the query's filters
+func (q AuditQuery) matches(e *AuditEntry) bool {
+	return (q.Key == "" || e.Key == q.Key) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Action == "" || e.Action == q.Action)
+}
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultBreakerFailures is the default number of consecutive failures
+	// that opens the circuit
+	DefaultBreakerFailures = 5
+
+	// DefaultBreakerCooldown is the default time the circuit stays open
+	// before a trial call is let through
+	DefaultBreakerCooldown = 5 * time.Second
+
+	// DefaultBreakerRetries is the default number of times a failed read is
+	// retried
+	DefaultBreakerRetries = 2
+
+	// DefaultBreakerBackoff is the default backoff before the first retry,
+	// doubled before each further one
+	DefaultBreakerBackoff = 25 * time.Millisecond
+
+	// DefaultStaleSize is the default number of records kept to serve while
+	// the store is unavailable
+	DefaultStaleSize = 10000
+
+	// DefaultStaleTTL is the default time a record is kept to serve while
+	// the store is unavailable
+	DefaultStaleTTL = time.Hour
+)
+
+// ErrUnavailable is returned without calling the store while its circuit is
+// open
+var ErrUnavailable = errors.New("storage is unavailable")
+
+// breakerStats counts circuit breaker trips, retries and stale reads,
+// published at /debug/vars
+var breakerStats = expvar.NewMap("storage_breaker")
+
+// BreakerOptions tunes a BreakerStore. Zero values keep the defaults.
+type BreakerOptions struct {
+	// Failures is the number of consecutive failures that opens the circuit
+	Failures int
+
+	// Cooldown is the time the circuit stays open before a single trial
+	// call decides whether it closes again
+	Cooldown time.Duration
+
+	// Retries is the number of times a failed read is retried, after a
+	// jittered exponential backoff starting at Backoff. Writes are never
+	// retried, since a write that timed out may have been applied.
+	Retries int
+	Backoff time.Duration
+
+	// StaleSize and StaleTTL bound the last known records kept to serve
+	// reads while the store is unavailable
+	StaleSize int
+	StaleTTL  time.Duration
+}
+
+// circuitState is the state of a circuit breaker
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// String returns the name of a circuit state
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerStore guards a store with a circuit breaker. Reads failing with a
+// connection error or timeout are retried a few times; once calls keep
+// failing the circuit opens and every call returns ErrUnavailable at once
+// instead of waiting on timeouts, until a trial call after the cooldown
+// succeeds. Meanwhile records are read from the last known copies, so
+// redirects keep being served while Redis is flapping.
+type BreakerStore struct {
+	store Store
+	opts  BreakerOptions
+	stale *recordCache
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewBreakerStore creates a new BreakerStore guarding store
+func NewBreakerStore(store Store, opts BreakerOptions) *BreakerStore {
+	if opts.Failures <= 0 {
+		opts.Failures = DefaultBreakerFailures
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = DefaultBreakerCooldown
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBreakerBackoff
+	}
+	if opts.StaleSize <= 0 {
+		opts.StaleSize = DefaultStaleSize
+	}
+	if opts.StaleTTL <= 0 {
+		opts.StaleTTL = DefaultStaleTTL
+	}
+
+	s := &BreakerStore{store: store, opts: opts, stale: newRecordCache(opts.StaleSize, opts.StaleTTL)}
+	s.stale.stats = new(expvar.Map)
+	breakerStats.Set("state", expvar.Func(func() any {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.state.String()
+	}))
+	return s
+}
+
+// Open reports whether calls are currently rejected with ErrUnavailable,
+// and for how long at most
+func (s *BreakerStore) Open() (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == circuitClosed {
+		return false, 0
+	}
+	wait := s.opts.Cooldown - time.Since(s.openedAt)
+	if s.state == circuitOpen && wait <= 0 {
+		// The next call is the trial
+		return false, 0
+	}
+	return true, max(wait, 0)
+}
+
+// Set stores a URL mapping with the specified key
+func (s *BreakerStore) Set(ctx context.Context, key, url string) error {
+	return s.call(ctx, false, func() error { return s.store.Set(ctx, key, url) })
+}
+
+// Create stores a URL mapping record
+func (s *BreakerStore) Create(ctx context.Context, key string, rec *Record) error {
+	return s.call(ctx, false, func() error { return s.store.Create(ctx, key, rec) })
+}
+
+// Get retrieves a URL mapping by key
+func (s *BreakerStore) Get(ctx context.Context, key string) (string, error) {
+	var url string
+	err := s.call(ctx, true, func() (err error) {
+		url, err = s.store.Get(ctx, key)
+		return err
+	})
+	return url, err
+}
+
+// GetRecord retrieves a URL mapping record, falling back to the last known
+// copy of the record while the store is unavailable
+func (s *BreakerStore) GetRecord(ctx context.Context, key string) (*Record, error) {
+	return s.read(ctx, key, s.store.GetRecord)
+}
+
+// GetAndRefreshTTL retrieves a URL mapping record and refreshes its TTL,
+// falling back to the last known copy like GetRecord
+func (s *BreakerStore) GetAndRefreshTTL(ctx context.Context, key string) (*Record, error) {
+	return s.read(ctx, key, s.store.GetAndRefreshTTL)
+}
+
+// GetMulti retrieves several URL mapping records
+func (s *BreakerStore) GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error) {
+	var recs []*Record
+	var errs []error
+	err := s.call(ctx, true, func() (err error) {
+		recs, errs, err = s.store.GetMulti(ctx, keys)
+		return err
+	})
+	return recs, errs, err
+}
+
+// read retrieves a record with get, remembering it for stale reads while
+// the store is unavailable
+func (s *BreakerStore) read(ctx context.Context, key string, get func(context.Context, string) (*Record, error)) (*Record, error) {
+	var rec *Record
+	err := s.call(ctx, true, func() (err error) {
+		rec, err = get(ctx, key)
+		return err
+	})
+
+	switch {
+	case err == nil:
+		s.remember(key, rec)
+		return rec, nil
+	case err == ErrNotFound:
+		s.stale.remove(key)
+	case err == ErrUnavailable || transient(err):
+		if value, ok, _ := s.stale.get(key, time.Now()); ok {
+			if stale, decodeErr := decodeRecord(value); decodeErr == nil {
+				breakerStats.Add("stale_reads", 1)
+				return stale, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// Update replaces a URL mapping record
+func (s *BreakerStore) Update(ctx context.Context, key string, rec *Record) error {
+	if err := s.call(ctx, false, func() error { return s.store.Update(ctx, key, rec) }); err != nil {
+		return err
+	}
+	s.remember(key, rec)
+	return nil
+}
+
+// Delete removes a URL mapping
+func (s *BreakerStore) Delete(ctx context.Context, key string) error {
+	if err := s.call(ctx, false, func() error { return s.store.Delete(ctx, key) }); err != nil {
+		return err
+	}
+	s.stale.remove(key)
+	return nil
+}
+
+// DeleteIfCreated removes a URL mapping created at createdAt
+func (s *BreakerStore) DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error {
+	if err := s.call(ctx, false, func() error { return s.store.DeleteIfCreated(ctx, key, createdAt) }); err != nil {
+		return err
+	}
+	s.stale.remove(key)
+	return nil
+}
+
+// ExpiresAt returns when a URL mapping expires
+func (s *BreakerStore) ExpiresAt(ctx context.Context, key string) (*time.Time, error) {
+	var at *time.Time
+	err := s.call(ctx, true, func() (err error) {
+		at, err = s.store.ExpiresAt(ctx, key)
+		return err
+	})
+	return at, err
+}
+
+// Search returns the URL mappings matching a query
+func (s *BreakerStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	var results []SearchResult
+	err := s.call(ctx, true, func() (err error) {
+		results, err = s.store.Search(ctx, q)
+		return err
+	})
+	return results, err
+}
+
+// remember keeps a copy of a record to serve while the store is unavailable
+func (s *BreakerStore) remember(key string, rec *Record) {
+	if value, err := encodeRecord(rec); err == nil {
+		s.stale.add(key, value, time.Now())
+	}
+}
+
+// call runs fn through the circuit breaker, retrying it when it is
+// idempotent and failed with a transient error
+func (s *BreakerStore) call(ctx context.Context, idempotent bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if !s.allow(time.Now()) {
+			breakerStats.Add("rejected", 1)
+			return ErrUnavailable
+		}
+		err := fn()
+		s.record(err, time.Now())
+		if !idempotent || attempt >= s.opts.Retries || !transient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		// Full jitter keeps instances from retrying in lockstep
+		breakerStats.Add("retries", 1)
+		backoff := time.Duration(rand.Int64N(int64(s.opts.Backoff<<attempt)) + 1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// allow reports whether a call may go to the store. Once the cooldown has
+// passed, a single trial call is let through while the circuit is half-open.
+func (s *BreakerStore) allow(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch s.state {
+	case circuitOpen:
+		if now.Sub(s.openedAt) < s.opts.Cooldown {
+			return false
+		}
+		s.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the circuit with the outcome of a call. Errors about the
+// request itself, such as a missing key, show the store is reachable.
+func (s *BreakerStore) record(err error, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !transient(err) {
+		s.state = circuitClosed
+		s.failures = 0
+		return
+	}
+
+	s.failures++
+	if s.state == circuitHalfOpen || s.failures >= s.opts.Failures {
+		if s.state != circuitOpen {
+			breakerStats.Add("trips", 1)
+		}
+		s.state = circuitOpen
+		s.openedAt = now
+	}
+}
+
+// transient reports whether an error means the store couldn't be reached or
+// didn't answer in time, rather than that it refused the request
+func transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, redis.ErrClosed) ||
+		errors.Is(err, redis.ErrPoolTimeout) ||
+		errors.Is(err, driver.ErrBadConn)
+}
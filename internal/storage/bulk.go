@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BulkRecord is a record to be created under a key. ExpiresAt overrides
+// the store's TTL when set.
+type BulkRecord struct {
+	Key       string
+	Record    *Record
+	ExpiresAt *time.Time
+}
+
+// BulkStore represents the storage interface for creating many links at once
+type BulkStore interface {
+	CreateMany(ctx context.Context, items []BulkRecord) ([]error, error)
+}
+
+// ErrExpired is returned for bulk records whose expiry has already passed
+var ErrExpired = errors.New("expiry is in the past")
+
+// createUnclaimedScript stores a link unless a link or a landing page holds
+// its key. KEYS are the link and the landing page; ARGV the record and its
+// TTL in milliseconds.
+var createUnclaimedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+return 1
+`)
+
+// CreateMany stores many records in two round trips instead of one or two
+// per record. It returns the outcome of each item in order: nil,
+// ErrKeyExists, ErrExpired or a validation error. The second return value
+// reports a failure of the whole batch, such as Redis being unreachable.
+func (s *RedisStore) CreateMany(ctx context.Context, items []BulkRecord) ([]error, error) {
+	errs := make([]error, len(items))
+	cmds := make([]*redis.Cmd, len(items))
+	now := time.Now()
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, item := range items {
+			value, err := encodeNew(item.Key, item.Record)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			ttl := s.lifetime(item.Record, now)
+			if item.ExpiresAt != nil {
+				if ttl = item.ExpiresAt.Sub(now); ttl <= 0 {
+					errs[i] = ErrExpired
+					continue
+				}
+			}
+			cmds[i] = createUnclaimedScript.Eval(ctx, pipe, []string{item.Key, landingPageKeyPrefix + item.Key}, value, ttl.Milliseconds())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var created []BulkRecord
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		s.found(items[i].Key)
+		if created, _ := cmd.Int(); created == 0 {
+			errs[i] = ErrKeyExists
+			continue
+		}
+		created = append(created, items[i])
+	}
+
+	if len(created) > 0 {
+		_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, item := range created {
+				expires := now.Add(s.lifetime(item.Record, now))
+				if item.ExpiresAt != nil {
+					expires = *item.ExpiresAt
+				}
+				indexExpiry(ctx, pipe, item.Key, expires)
+				indexTags(ctx, pipe, item.Key, item.Record.Tags)
+				indexScope(ctx, pipe, item.Key, item.Record)
+				clearStats(ctx, pipe, item.Key)
+				pipe.Del(ctx, claimedClicksKey(item.Key))
+			}
+			return nil
+		})
+	}
+	return errs, err
+}
+
+// ExportStore represents the storage interface for streaming every link
+type ExportStore interface {
+	Walk(ctx context.Context, fn func(SearchResult) error) error
+}
+
+// Walk calls fn for every stored link in batches, in no particular order,
+// stopping at the first error fn returns. Links created or deleted during
+// the walk may or may not be visited.
+func (s *RedisStore) Walk(ctx context.Context, fn func(SearchResult) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, "*", scanBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		results, err := s.matchKeys(ctx, linkKeys(keys), SearchQuery{})
+		if err != nil {
+			return err
+		}
+		if err := s.fillExpiry(ctx, results); err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
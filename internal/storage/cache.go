@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is the default time a record stays in the in-process
+	// cache
+	DefaultCacheTTL = 5 * time.Second
+
+	// DefaultNegativeCacheTTL is the default time a key found not to exist
+	// is remembered
+	DefaultNegativeCacheTTL = 2 * time.Second
+)
+
+// cacheStats counts record cache lookups, published at /debug/vars
+var cacheStats = expvar.NewMap("record_cache")
+
+// negativeCacheStats counts lookups of keys recently found not to exist,
+// published at /debug/vars
+var negativeCacheStats = expvar.NewMap("negative_cache")
+
+func init() {
+	cacheStats.Set("hit_rate", expvar.Func(func() any {
+		hits, misses := cacheCounter("hits"), cacheCounter("misses")
+		if hits+misses == 0 {
+			return 0.0
+		}
+		return float64(hits) / float64(hits+misses)
+	}))
+}
+
+// cacheCounter returns the value of a record cache counter
+func cacheCounter(name string) int64 {
+	if v, ok := cacheStats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// recordCache is a size-bounded LRU cache of encoded records with a short
+// TTL. It holds encoded values so every caller decodes its own copy.
+type recordCache struct {
+	size  int
+	ttl   time.Duration
+	stats *expvar.Map
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+// cacheEntry is a cached record together with the hits it has served
+type cacheEntry struct {
+	key     string
+	value   string
+	expires time.Time
+	hits    int64
+}
+
+// newRecordCache creates a cache holding up to size records for ttl each
+func newRecordCache(size int, ttl time.Duration) *recordCache {
+	return &recordCache{
+		size:  size,
+		ttl:   ttl,
+		stats: cacheStats,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// get returns the cached value of a key. When the entry has expired it is
+// dropped and the hits it served are returned, so the caller can account
+// for them in Redis.
+func (c *recordCache) get(key string, now time.Time) (string, bool, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.stats.Add("misses", 1)
+		return "", false, 0
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.removeElement(elem)
+		c.stats.Add("misses", 1)
+		return "", false, entry.hits
+	}
+
+	entry.hits++
+	c.order.MoveToFront(elem)
+	c.stats.Add("hits", 1)
+	return entry.value, true, 0
+}
+
+// add caches the value of a key, evicting the least recently used entry
+// when the cache is full
+func (c *recordCache) add(key, value string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.value = value
+		entry.expires = now.Add(c.ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, value: value, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.stats.Add("evictions", 1)
+	}
+}
+
+// remove drops a key so the next read goes to Redis
+func (c *recordCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// removeElement unlinks an entry; the caller must hold the lock
+func (c *recordCache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
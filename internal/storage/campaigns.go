@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// campaignKeyPrefix prefixes the key holding each campaign
+	campaignKeyPrefix = "campaign:"
+
+	// campaignLinksSuffix names the set of keys grouped in a campaign
+	campaignLinksSuffix = ":links"
+)
+
+// ErrCampaignNotFound is returned when a campaign does not exist
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// Campaign groups links launched together, so their statistics roll up
+type Campaign struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner,omitempty"`
+	Workspace string    `json:"workspace,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CampaignStats are the aggregate counters of a campaign, with the clicks
+// of each link most clicked first
+type CampaignStats struct {
+	Links  int64               `json:"links"`
+	Clicks int64               `json:"clicks"`
+	Top    []CampaignLinkStats `json:"top"`
+}
+
+// CampaignLinkStats are the clicks of one link in a campaign
+type CampaignLinkStats struct {
+	Key    string `json:"key"`
+	Clicks int64  `json:"clicks"`
+}
+
+// CampaignStore represents the storage interface for campaigns
+type CampaignStore interface {
+	CreateCampaign(ctx context.Context, c *Campaign) error
+	GetCampaign(ctx context.Context, id string) (*Campaign, error)
+	AddCampaignLinks(ctx context.Context, id string, keys []string) error
+	CampaignLinks(ctx context.Context, id string) ([]string, error)
+	GetCampaignStats(ctx context.Context, id string) (*CampaignStats, error)
+}
+
+// CreateCampaign stores a new campaign
+func (s *RedisStore) CreateCampaign(ctx context.Context, c *Campaign) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	success, err := s.client.SetNX(ctx, campaignKeyPrefix+c.ID, data, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !success {
+		return ErrKeyExists
+	}
+	return nil
+}
+
+// GetCampaign retrieves a campaign by ID
+func (s *RedisStore) GetCampaign(ctx context.Context, id string) (*Campaign, error) {
+	data, err := s.client.Get(ctx, campaignKeyPrefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Campaign
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// AddCampaignLinks adds keys to a campaign. Keys already in it are ignored.
+func (s *RedisStore) AddCampaignLinks(ctx context.Context, id string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return s.client.SAdd(ctx, campaignLinksKey(id), members...).Err()
+}
+
+// CampaignLinks returns the keys of a campaign's live links, sorted. Links
+// that have expired or been deleted are pruned from the campaign.
+func (s *RedisStore) CampaignLinks(ctx context.Context, id string) ([]string, error) {
+	keys, err := s.liveMembers(ctx, campaignLinksKey(id))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetCampaignStats returns the number of live links in a campaign and their
+// clicks in the current measurement period
+func (s *RedisStore) GetCampaignStats(ctx context.Context, id string) (*CampaignStats, error) {
+	keys, err := s.liveMembers(ctx, campaignLinksKey(id))
+	if err != nil {
+		return nil, err
+	}
+	stats := &CampaignStats{Links: int64(len(keys)), Top: make([]CampaignLinkStats, 0, len(keys))}
+	if len(keys) == 0 {
+		return stats, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.HGet(ctx, statsKeyPrefix+key, "clicks")
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		clicks, _ := strconv.ParseInt(cmd.Val(), 10, 64)
+		stats.Clicks += clicks
+		stats.Top = append(stats.Top, CampaignLinkStats{Key: keys[i], Clicks: clicks})
+	}
+	sort.Slice(stats.Top, func(i, j int) bool {
+		if stats.Top[i].Clicks != stats.Top[j].Clicks {
+			return stats.Top[i].Clicks > stats.Top[j].Clicks
+		}
+		return stats.Top[i].Key < stats.Top[j].Key
+	})
+	return stats, nil
+}
+
+// campaignLinksKey returns the key of the set of keys grouped in a campaign
+func campaignLinksKey(id string) string {
+	return campaignKeyPrefix + id + campaignLinksSuffix
+}
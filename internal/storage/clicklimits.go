@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// claimedClicksKeyPrefix prefixes the counter of redirects claimed by each
+// link with a click limit
+const claimedClicksKeyPrefix = "limit:clicks:"
+
+// ErrClickLimitReached is returned when a link has used up its clicks
+var ErrClickLimitReached = errors.New("click limit reached")
+
+// ClickLimiter represents the storage interface for counting the redirects
+// of links with a click limit
+type ClickLimiter interface {
+	ClaimClick(ctx context.Context, key string, limit int) error
+	CheckClickLimit(ctx context.Context, key string, limit int) error
+	ClaimedClicks(ctx context.Context, key string) (int64, error)
+}
+
+// claimClickScript counts a redirect of a link unless it has used up its
+// limit. The counter never expires, since extending the link or sliding
+// its expiry would outlive it; deleting the link or creating another at
+// its key drops it. KEYS are the link and its claimed clicks; ARGV the
+// limit and 1 to claim a click or 0 to check. It returns -1 if the link
+// doesn't exist, 0 if the limit is reached and otherwise the clicks
+// claimed.
+var claimClickScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local claimed = tonumber(redis.call('GET', KEYS[2]) or '0')
+if claimed >= tonumber(ARGV[1]) then
+	return 0
+end
+if ARGV[2] == '1' then
+	claimed = redis.call('INCR', KEYS[2])
+	redis.call('PERSIST', KEYS[2])
+end
+return claimed
+`)
+
+// claimedClicksKey returns the key counting a link's claimed clicks
+func claimedClicksKey(key string) string {
+	return claimedClicksKeyPrefix + key
+}
+
+// ClaimClick counts a redirect of a link allowed limit of them. The check
+// and the count are one script, so however many requests race for a
+// link's last click, only one gets it; the others, and every later one,
+// get ErrClickLimitReached. It returns ErrNotFound if the link doesn't
+// exist.
+func (s *RedisStore) ClaimClick(ctx context.Context, key string, limit int) error {
+	return s.runClaimClick(ctx, key, limit, true)
+}
+
+// CheckClickLimit returns ErrClickLimitReached if a link allowed limit
+// redirects has used them up, without counting one
+func (s *RedisStore) CheckClickLimit(ctx context.Context, key string, limit int) error {
+	return s.runClaimClick(ctx, key, limit, false)
+}
+
+// runClaimClick runs claimClickScript, claiming a click if claim is set
+func (s *RedisStore) runClaimClick(ctx context.Context, key string, limit int, claim bool) error {
+	flag := "0"
+	if claim {
+		flag = "1"
+	}
+	n, err := claimClickScript.Run(ctx, s.client, []string{key, claimedClicksKey(key)}, limit, flag).Int()
+	if err != nil {
+		return err
+	}
+	switch n {
+	case -1:
+		return ErrNotFound
+	case 0:
+		return ErrClickLimitReached
+	}
+	return nil
+}
+
+// ClaimedClicks returns how many redirects a link has claimed of its limit
+func (s *RedisStore) ClaimedClicks(ctx context.Context, key string) (int64, error) {
+	n, err := s.client.Get(ctx, claimedClicksKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
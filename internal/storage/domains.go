@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// domainKeyPrefix prefixes the key holding each custom domain
+	domainKeyPrefix = "domain:"
+
+	// domainsKey is the set of all registered custom domain names, kept
+	// out of the way of a link keyed "domains"
+	domainsKey = "domains:all"
+)
+
+// ErrDomainNotFound is returned when a custom domain is not registered
+var ErrDomainNotFound = errors.New("domain not found")
+
+// DomainStatus is the verification state of a custom domain
+type DomainStatus string
+
+// Verification states of a custom domain
+const (
+	DomainPending  DomainStatus = "pending"
+	DomainVerified DomainStatus = "verified"
+	DomainFailed   DomainStatus = "failed"
+)
+
+// Domain is a custom domain registered for short links
+type Domain struct {
+	Name       string       `json:"name"`
+	Method     string       `json:"method"`
+	Token      string       `json:"token"`
+	Workspace  string       `json:"workspace,omitempty"`
+	Status     DomainStatus `json:"status"`
+	Failures   int          `json:"failures"`
+	LastError  string       `json:"last_error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	CheckedAt  *time.Time   `json:"checked_at,omitempty"`
+	VerifiedAt *time.Time   `json:"verified_at,omitempty"`
+}
+
+// createDomainScript registers a domain unless it is verified. KEYS are
+// the domain and the set of domain names; ARGV the domain, its TTL in
+// milliseconds and its name. It returns 0 if the domain is verified.
+var createDomainScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and cjson.decode(current).status == 'verified' then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('SADD', KEYS[2], ARGV[3])
+return 1
+`)
+
+// saveDomainScript updates a domain registered with the token it was read
+// with. KEYS are the domain; ARGV the domain, the TTL in milliseconds of an
+// unverified domain, its token and its status. It returns 0 if the domain
+// is gone or registered again.
+var saveDomainScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current or cjson.decode(current).token ~= ARGV[3] then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ARGV[4] == 'verified' then
+	redis.call('SET', KEYS[1], ARGV[1])
+elseif ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return 1
+`)
+
+// DomainStore represents the storage interface for custom domains
+type DomainStore interface {
+	CreateDomain(ctx context.Context, d *Domain, ttl time.Duration) error
+	GetDomain(ctx context.Context, name string) (*Domain, error)
+	SaveDomain(ctx context.Context, d *Domain, ttl time.Duration) error
+	DeleteDomain(ctx context.Context, name string) error
+	ListDomains(ctx context.Context) ([]*Domain, error)
+}
+
+// CreateDomain registers a custom domain, unverified until it passes its
+// challenge, for ttl. A domain nobody has verified is claimed again by
+// whoever registers it next, so a claim left pending can't lock its owner
+// out. It returns ErrKeyExists if the domain is verified.
+func (s *RedisStore) CreateDomain(ctx context.Context, d *Domain, ttl time.Duration) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	n, err := createDomainScript.Run(ctx, s.client, []string{domainKeyPrefix + d.Name, domainsKey}, data, ttl.Milliseconds(), d.Name).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyExists
+	}
+	return nil
+}
+
+// GetDomain retrieves a custom domain by name
+func (s *RedisStore) GetDomain(ctx context.Context, name string) (*Domain, error) {
+	data, err := s.client.Get(ctx, domainKeyPrefix+name).Bytes()
+	if err == redis.Nil {
+		return nil, ErrDomainNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var d Domain
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SaveDomain updates a custom domain unless it has been registered again
+// since it was read. A verified domain is kept until deleted; one that
+// isn't expires ttl after it was registered or stopped being verified.
+func (s *RedisStore) SaveDomain(ctx context.Context, d *Domain, ttl time.Duration) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	n, err := saveDomainScript.Run(ctx, s.client, []string{domainKeyPrefix + d.Name}, data, ttl.Milliseconds(), d.Token, d.Status).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// DeleteDomain removes a custom domain. Links under it stay, but aren't
+// served until the domain is registered and verified again.
+func (s *RedisStore) DeleteDomain(ctx context.Context, name string) error {
+	n, err := s.client.Del(ctx, domainKeyPrefix+name).Result()
+	if err != nil {
+		return err
+	}
+	if err := s.client.SRem(ctx, domainsKey, name).Err(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// ListDomains returns all registered custom domains
+func (s *RedisStore) ListDomains(ctx context.Context) ([]*Domain, error) {
+	names, err := s.client.SMembers(ctx, domainsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]*Domain, 0, len(names))
+	for _, name := range names {
+		d, err := s.GetDomain(ctx, name)
+		if err == ErrDomainNotFound {
+			// Unverified domains expire, leaving their name behind
+			s.client.SRem(ctx, domainsKey, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
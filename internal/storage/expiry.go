@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
+)
+
+// ExpiryPolicy decides whether reading a link extends its lifetime
+type ExpiryPolicy string
+
+const (
+	// ExpirySliding resets a link's TTL every time it is read, so links
+	// only expire once they go unused
+	ExpirySliding ExpiryPolicy = "sliding"
+
+	// ExpiryAbsolute keeps the TTL set at creation, so popular links expire
+	// on schedule too
+	ExpiryAbsolute ExpiryPolicy = "absolute"
+
+	// DefaultAccessQueueSize is the default number of link accesses buffered
+	// for asynchronous bookkeeping before new ones are dropped
+	DefaultAccessQueueSize = 10000
+
+	// AccessQueueName identifies the access queue in the dropped items metric
+	AccessQueueName = "access"
+)
+
+// ErrInvalidExpiryPolicy is returned for unknown expiry policies
+var ErrInvalidExpiryPolicy = errors.New("expiry policy must be sliding or absolute")
+
+// ParseExpiryPolicy parses a deployment's default expiry policy
+func ParseExpiryPolicy(s string) (ExpiryPolicy, error) {
+	p := ExpiryPolicy(s)
+	if p == "" || !p.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrInvalidExpiryPolicy, s)
+	}
+	return p, nil
+}
+
+// Valid reports whether the policy is known. The empty policy defers to
+// the deployment's default.
+func (p ExpiryPolicy) Valid() bool {
+	switch p {
+	case "", ExpirySliding, ExpiryAbsolute:
+		return true
+	}
+	return false
+}
+
+// access is a read of a link whose bookkeeping is still to be written
+type access struct {
+	key     string
+	hits    int64
+	sliding bool
+}
+
+// SetExpiryPolicy sets the policy of links without their own. Links
+// default to sliding expiry.
+func (s *RedisStore) SetExpiryPolicy(p ExpiryPolicy) {
+	s.policy = p
+}
+
+// EnableAsyncAccess moves the TTL refresh and access count of each read
+// off the request path, onto a queue of up to size accesses written by a
+// background worker. Accesses are dropped when the queue is full.
+func (s *RedisStore) EnableAsyncAccess(size int) {
+	if size <= 0 {
+		size = DefaultAccessQueueSize
+	}
+	s.accesses = queue.New(AccessQueueName, size, s.writeAccess)
+}
+
+// ShutdownAccess stops queueing accesses and waits for queued ones to be
+// written until ctx is done
+func (s *RedisStore) ShutdownAccess(ctx context.Context) error {
+	if s.accesses == nil {
+		return nil
+	}
+	return s.accesses.Shutdown(ctx)
+}
+
+// sliding reports whether reading a record extends its TTL. Links yet to
+// activate already live the TTL past their activation.
+func (s *RedisStore) sliding(rec *Record) bool {
+	if rec.ActiveFrom != nil && rec.ActiveFrom.After(time.Now()) {
+		return false
+	}
+	p := rec.Expiry
+	if p == "" {
+		p = s.policy
+	}
+	return p != ExpiryAbsolute
+}
+
+// recordAccess refreshes the TTL of a sliding link and tracks its access
+// count for hot key exports, queueing the work when accesses are
+// asynchronous. Both are best effort.
+func (s *RedisStore) recordAccess(ctx context.Context, a access) {
+	if s.accesses != nil {
+		s.accesses.Push(a)
+		return
+	}
+	s.applyAccess(ctx, a)
+}
+
+// writeAccess applies a queued access
+func (s *RedisStore) writeAccess(a access) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.applyAccess(ctx, a); err != nil {
+		log.Printf("failed to record access to %s: %v", a.key, err)
+	}
+}
+
+// applyAccess writes an access with the script redirects read links with,
+// so a link deleted since it was read isn't tracked again
+func (s *RedisStore) applyAccess(ctx context.Context, a access) error {
+	_, err := s.getAndTouch(ctx, a.key, a.hits, a.sliding)
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+const (
+	// expiryIndexKey is the sorted set of keys scored by when they expire,
+	// in Unix milliseconds
+	expiryIndexKey = "expiry:index"
+
+	// expiryReminderKeyPrefix prefixes the marker of each reminder sent
+	expiryReminderKeyPrefix = "expiry:reminded:"
+)
+
+// ExpiryStore represents the storage interface for finding links about to
+// expire and extending them
+type ExpiryStore interface {
+	TTL() time.Duration
+	Expiring(ctx context.Context, before time.Time, limit int) ([]SearchResult, error)
+	ClaimExpiryReminder(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+	Extend(ctx context.Context, key string, until time.Time) error
+}
+
+// SetTTL sets the lifetime of new links, which reads of sliding links
+// restart. Links default to DefaultTTL.
+func (s *RedisStore) SetTTL(ttl time.Duration) {
+	if ttl > 0 {
+		s.ttl = ttl
+	}
+}
+
+// TTL returns the lifetime of new links
+func (s *RedisStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// indexExpiry records when a key expires in the expiry index
+func indexExpiry(ctx context.Context, pipe redis.Pipeliner, key string, at time.Time) {
+	pipe.ZAdd(ctx, expiryIndexKey, redis.Z{Score: float64(at.UnixMilli()), Member: key})
+}
+
+// Expiring returns up to limit links expiring before the given time,
+// soonest first, with when they expire. Keys that no longer exist are
+// pruned from the index, and keys whose expiry moved are rescored.
+func (s *RedisStore) Expiring(ctx context.Context, before time.Time, limit int) ([]SearchResult, error) {
+	keys, err := s.client.ZRangeByScore(ctx, expiryIndexKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	values := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			values[i] = pipe.Get(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var results []SearchResult
+	var stale []interface{}
+	var moved []redis.Z
+	for i, key := range keys {
+		// PTTL reports -2 for missing keys and -1 for keys without expiry
+		ttl := ttls[i].Val()
+		if values[i].Err() == redis.Nil || ttl < 0 {
+			stale = append(stale, key)
+			continue
+		}
+		at := now.Add(ttl).Truncate(time.Second)
+		if !at.Before(before) {
+			moved = append(moved, redis.Z{Score: float64(at.UnixMilli()), Member: key})
+			continue
+		}
+		rec, err := decodeRecord(values[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{Key: key, Record: rec, ExpiresAt: &at})
+	}
+
+	if len(stale) > 0 || len(moved) > 0 {
+		s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			if len(stale) > 0 {
+				pipe.ZRem(ctx, expiryIndexKey, stale...)
+			}
+			if len(moved) > 0 {
+				pipe.ZAdd(ctx, expiryIndexKey, moved...)
+			}
+			return nil
+		})
+	}
+	return results, nil
+}
+
+// ClaimExpiryReminder marks the reminder that a key expires at the given
+// time as sent, reporting whether this call claimed it, so instances send
+// each reminder once. The mark lapses when the link would have expired, so
+// a link extended since is reminded again before its new expiry.
+func (s *RedisStore) ClaimExpiryReminder(ctx context.Context, key string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return false, nil
+	}
+	return s.client.SetNX(ctx, expiryReminderKeyPrefix+key, expiresAt.Unix(), ttl).Result()
+}
+
+// Extend moves the expiry of a link to the given time, clearing any
+// reminder sent about its previous expiry
+func (s *RedisStore) Extend(ctx context.Context, key string, until time.Time) error {
+	extended, err := s.client.PExpireAt(ctx, key, until).Result()
+	if err != nil {
+		return err
+	}
+	if !extended {
+		return ErrNotFound
+	}
+
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		indexExpiry(ctx, pipe, key, until)
+		pipe.Del(ctx, expiryReminderKeyPrefix+key)
+		return nil
+	})
+	return err
+}
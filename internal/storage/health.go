@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fallbackLinksKey is the set of keys that define fallback destinations
+const fallbackLinksKey = "fallbacks:links"
+
+// HealthStore represents the storage interface for destination health checks
+type HealthStore interface {
+	FallbackLinks(ctx context.Context) ([]SearchResult, error)
+	SaveHealth(ctx context.Context, key string, down []string) error
+}
+
+// FallbackLinks returns the links that define fallback destinations. Records
+// are read without counting as accesses, so checks never extend a link's
+// TTL. Links that have expired are pruned from the index.
+func (s *RedisStore) FallbackLinks(ctx context.Context) ([]SearchResult, error) {
+	keys, err := s.client.SMembers(ctx, fallbackLinksKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	var stale []interface{}
+	for start := 0; start < len(keys); start += scanBatchSize {
+		batch := keys[start:min(start+scanBatchSize, len(keys))]
+		values, err := s.client.MGet(ctx, batch...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			value, ok := v.(string)
+			if !ok {
+				stale = append(stale, batch[i])
+				continue
+			}
+			rec, err := decodeRecord(value)
+			if err != nil {
+				continue
+			}
+			results = append(results, SearchResult{Key: batch[i], Record: rec})
+		}
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, fallbackLinksKey, stale...)
+	}
+	return results, nil
+}
+
+// SaveHealth marks the destinations of a record found down. Destinations the
+// record no longer has are ignored, so a slow check never marks a newer
+// destination down.
+func (s *RedisStore) SaveHealth(ctx context.Context, key string, down []string) error {
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, err := decodeRecord(value)
+		if err != nil {
+			return err
+		}
+		var current []string
+		for _, dest := range rec.Destinations() {
+			if slices.Contains(down, dest) {
+				current = append(current, dest)
+			}
+		}
+		if slices.Equal(current, rec.Down) {
+			return nil
+		}
+
+		rec.Down = current
+		value, err = encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true})
+			return nil
+		})
+		return err
+	}, key)
+	s.invalidate(key)
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	return err
+}
+
+// indexFallbacks adds a key to the index of links whose destinations are
+// health checked, or removes it once the link has no fallbacks left
+func indexFallbacks(ctx context.Context, pipe redis.Pipeliner, key string, rec *Record) {
+	if len(rec.Fallbacks) > 0 {
+		pipe.SAdd(ctx, fallbackLinksKey, key)
+	} else {
+		pipe.SRem(ctx, fallbackLinksKey, key)
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyKeyPrefix prefixes the keys holding idempotent responses
+const idempotencyKeyPrefix = "idempotency:"
+
+// IdempotentResponse is the outcome of a request made with an idempotency
+// key. Status is zero while the first request is still being handled.
+type IdempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// IdempotencyStore represents the storage interface for idempotent requests
+type IdempotencyStore interface {
+	BeginIdempotent(ctx context.Context, client, key, fingerprint string, lock time.Duration) (*IdempotentResponse, error)
+	CompleteIdempotent(ctx context.Context, client, key string, resp *IdempotentResponse, ttl time.Duration) error
+	AbortIdempotent(ctx context.Context, client, key string) error
+}
+
+// BeginIdempotent claims an idempotency key for a client. It returns nil if
+// the key was free, or the entry of the request that claimed it first. The
+// claim lapses after lock unless the response is stored, so a request that
+// never finishes doesn't hold the key for long.
+func (s *RedisStore) BeginIdempotent(ctx context.Context, client, key, fingerprint string, lock time.Duration) (*IdempotentResponse, error) {
+	data, err := json.Marshal(IdempotentResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.client.SetArgs(ctx, idempotencyKey(client, key), data, redis.SetArgs{
+		Mode: "NX",
+		TTL:  lock,
+		Get:  true,
+	}).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp IdempotentResponse
+	if err := json.Unmarshal(previous, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CompleteIdempotent stores the response to replay for a claimed key,
+// keeping it for ttl
+func (s *RedisStore) CompleteIdempotent(ctx context.Context, client, key string, resp *IdempotentResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, idempotencyKey(client, key), data, ttl).Err()
+}
+
+// AbortIdempotent releases a claimed key so the request can be retried
+func (s *RedisStore) AbortIdempotent(ctx context.Context, client, key string) error {
+	return s.client.Del(ctx, idempotencyKey(client, key)).Err()
+}
+
+// idempotencyKey returns the storage key for a client's idempotency key.
+// Both are hashed so arbitrary header values stay out of the keyspace.
+func idempotencyKey(client, key string) string {
+	sum := sha256.Sum256([]byte(client + "\x00" + key))
+	return idempotencyKeyPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// jobRunsKeyPrefix prefixes the list of each job's latest runs, newest
+	// first
+	jobRunsKeyPrefix = "jobs:runs:"
+
+	// MaxJobRuns is the number of runs kept per job
+	MaxJobRuns = 100
+)
+
+// JobTrigger is what started a job run
+type JobTrigger string
+
+const (
+	JobScheduled JobTrigger = "schedule"
+	JobManual    JobTrigger = "manual"
+)
+
+// JobRun records a run of a background job: when it ran, how many items
+// it processed and why it failed, if it did
+type JobRun struct {
+	Job        string     `json:"job"`
+	Trigger    JobTrigger `json:"trigger"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+	Processed  int        `json:"processed"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// JobStore keeps the run history of background jobs
+type JobStore interface {
+	RecordJobRun(ctx context.Context, run *JobRun) error
+	JobRuns(ctx context.Context, job string, n int) ([]JobRun, error)
+}
+
+// RecordJobRun adds a run to its job's history, dropping the oldest beyond
+// MaxJobRuns
+func (s *RedisStore) RecordJobRun(ctx context.Context, run *JobRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	key := jobRunsKeyPrefix + run.Job
+	pipe := s.client.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, MaxJobRuns-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// JobRuns returns up to n of a job's latest runs, newest first
+func (s *RedisStore) JobRuns(ctx context.Context, job string, n int) ([]JobRun, error) {
+	values, err := s.client.LRange(ctx, jobRunsKeyPrefix+job, 0, int64(n)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]JobRun, 0, len(values))
+	for _, v := range values {
+		var run JobRun
+		if json.Unmarshal([]byte(v), &run) == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPoolKey holds the list of pre-generated keys waiting to be issued
+const keyPoolKey = "keypool:keys"
+
+// ErrKeyPoolEmpty is returned when the key pool has no keys left
+var ErrKeyPoolEmpty = errors.New("key pool is empty")
+
+// KeyPoolStore represents the storage interface for the pre-generated key pool
+type KeyPoolStore interface {
+	PopKey(ctx context.Context) (string, error)
+	PushKeys(ctx context.Context, keys []string) (int, error)
+	KeyPoolSize(ctx context.Context) (int64, error)
+}
+
+// pushKeysScript appends the keys that don't already hold a link to the
+// pool, returning how many it added
+var pushKeysScript = redis.NewScript(`
+local added = 0
+for _, key in ipairs(ARGV) do
+	if redis.call('EXISTS', key) == 0 then
+		redis.call('RPUSH', KEYS[1], key)
+		added = added + 1
+	end
+end
+return added
+`)
+
+// PopKey takes the next key from the pool. Each key is handed out once,
+// even to instances popping concurrently.
+func (s *RedisStore) PopKey(ctx context.Context) (string, error) {
+	key, err := s.client.LPop(ctx, keyPoolKey).Result()
+	if err == redis.Nil {
+		return "", ErrKeyPoolEmpty
+	}
+	return key, err
+}
+
+// PushKeys adds keys to the pool, skipping those already taken by a link
+func (s *RedisStore) PushKeys(ctx context.Context, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	return pushKeysScript.Run(ctx, s.client, []string{keyPoolKey}, args...).Int()
+}
+
+// KeyPoolSize returns the number of keys waiting in the pool
+func (s *RedisStore) KeyPoolSize(ctx context.Context) (int64, error) {
+	return s.client.LLen(ctx, keyPoolKey).Result()
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyChecker represents the storage interface for checking whether keys
+// are taken without resolving them
+type KeyChecker interface {
+	KeysExist(ctx context.Context, keys []string) ([]bool, error)
+}
+
+// KeysExist reports, in order, whether each key holds a link. Unlike
+// GetRecord, checking a key doesn't count as an access.
+func (s *RedisStore) KeysExist(ctx context.Context, keys []string) ([]bool, error) {
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exist := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		exist[i] = cmd.Val() > 0
+	}
+	return exist, nil
+}
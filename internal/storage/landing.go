@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// landingPageKeyPrefix prefixes the key holding each landing page, so pages
+// live beside links without being resolved as one
+const landingPageKeyPrefix = "page:"
+
+// ErrLandingPageNotFound is returned when a landing page does not exist
+var ErrLandingPageNotFound = errors.New("landing page not found")
+
+// LandingPage is a page served under a key in place of a redirect, listing
+// buttons to several destinations in order
+type LandingPage struct {
+	Key         string        `json:"key"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Links       []LandingLink `json:"links"`
+	Owner       string        `json:"owner,omitempty"`
+	Workspace   string        `json:"workspace,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// LandingLink is a button on a landing page. Icon is the URL of an image
+// shown on the button.
+type LandingLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// LandingPageStore represents the storage interface for landing pages
+type LandingPageStore interface {
+	CreateLandingPage(ctx context.Context, page *LandingPage) error
+	GetLandingPage(ctx context.Context, key string) (*LandingPage, error)
+	VisitLandingPage(ctx context.Context, key string) (*LandingPage, error)
+	UpdateLandingPage(ctx context.Context, page *LandingPage) error
+	DeleteLandingPage(ctx context.Context, key string) error
+}
+
+// createLandingPageScript stores a landing page unless a link or another
+// page already holds its key. KEYS are the link and the page; ARGV the page
+// and its TTL in milliseconds.
+var createLandingPageScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 or redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// CreateLandingPage stores a new landing page, expiring after the TTL of
+// links. It returns ErrKeyExists if a link or another page holds its key.
+func (s *RedisStore) CreateLandingPage(ctx context.Context, page *LandingPage) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	created, err := createLandingPageScript.Run(ctx, s.client, []string{page.Key, landingPageKeyPrefix + page.Key}, data, s.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if created == 0 {
+		return ErrKeyExists
+	}
+	return nil
+}
+
+// GetLandingPage retrieves a landing page by key
+func (s *RedisStore) GetLandingPage(ctx context.Context, key string) (*LandingPage, error) {
+	return decodeLandingPage(s.client.Get(ctx, landingPageKeyPrefix+key).Bytes())
+}
+
+// VisitLandingPage retrieves a landing page being served. Unless links
+// expire on an absolute schedule, its TTL is refreshed, so pages in use
+// don't expire.
+func (s *RedisStore) VisitLandingPage(ctx context.Context, key string) (*LandingPage, error) {
+	if s.policy == ExpiryAbsolute {
+		return s.GetLandingPage(ctx, key)
+	}
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, landingPageKeyPrefix+key)
+		pipe.PExpire(ctx, landingPageKeyPrefix+key, s.ttl)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	return decodeLandingPage(get.Bytes())
+}
+
+// decodeLandingPage parses a stored landing page
+func decodeLandingPage(data []byte, err error) (*LandingPage, error) {
+	if err == redis.Nil {
+		return nil, ErrLandingPageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var page LandingPage
+	if err := json.Unmarshal(data, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// UpdateLandingPage replaces an existing landing page, keeping its TTL
+func (s *RedisStore) UpdateLandingPage(ctx context.Context, page *LandingPage) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.client.SetXX(ctx, landingPageKeyPrefix+page.Key, data, redis.KeepTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrLandingPageNotFound
+	}
+	return nil
+}
+
+// DeleteLandingPage removes a landing page, freeing its key
+func (s *RedisStore) DeleteLandingPage(ctx context.Context, key string) error {
+	n, err := s.client.Del(ctx, landingPageKeyPrefix+key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLandingPageNotFound
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LinkCheckStore represents the storage interface for link-rot checks
+type LinkCheckStore interface {
+	Walk(ctx context.Context, fn func(SearchResult) error) error
+	SaveCheck(ctx context.Context, key, url string, check *DestinationCheck) error
+}
+
+// SaveCheck records the outcome of checking a record's destination. The
+// outcome is discarded if the record was deleted or now points elsewhere, so
+// a slow check never overwrites the status of a newer destination.
+func (s *RedisStore) SaveCheck(ctx context.Context, key, url string, check *DestinationCheck) error {
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, err := decodeRecord(value)
+		if err != nil {
+			return err
+		}
+		if rec.URL != url {
+			return nil
+		}
+
+		rec.Check = check
+		value, err = encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true})
+			return nil
+		})
+		return err
+	}, key)
+	s.invalidate(key)
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// liveChannelPrefix prefixes the Pub/Sub channel carrying each key's clicks
+	liveChannelPrefix = "clicks:live:"
+
+	// liveBufferSize is the number of clicks buffered per subscriber before
+	// new ones are dropped for it
+	liveBufferSize = 64
+)
+
+// ClickStream represents the storage interface for following clicks live
+type ClickStream interface {
+	SubscribeClicks(ctx context.Context, key string) (*ClickSubscription, error)
+}
+
+// ClickSubscription delivers the clicks of one key as they are recorded
+type ClickSubscription struct {
+	// C receives the clicks; it is closed when the subscription ends
+	C <-chan analytics.Click
+
+	pubsub *redis.PubSub
+	once   sync.Once
+}
+
+// Close ends the subscription
+func (s *ClickSubscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.pubsub.Close()
+	})
+	return err
+}
+
+// EnableLiveClicks publishes every recorded click to subscribers of its key,
+// across every instance sharing the Redis server
+func (s *RedisStore) EnableLiveClicks() {
+	s.live = true
+}
+
+// publishClick announces a click to live subscribers of its key
+func publishClick(ctx context.Context, pipe redis.Pipeliner, click analytics.Click) {
+	data, err := json.Marshal(click)
+	if err != nil {
+		return
+	}
+	pipe.Publish(ctx, liveChannelPrefix+click.Key, data)
+}
+
+// SubscribeClicks follows the clicks of a key. Clicks arriving faster than
+// the subscriber reads them are dropped rather than slowing down recording.
+func (s *RedisStore) SubscribeClicks(ctx context.Context, key string) (*ClickSubscription, error) {
+	pubsub := s.client.Subscribe(ctx, liveChannelPrefix+key)
+
+	// Wait for the subscription to be confirmed so no click recorded after
+	// this returns is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	clicks := make(chan analytics.Click, liveBufferSize)
+	go func() {
+		defer close(clicks)
+		for msg := range pubsub.Channel() {
+			var click analytics.Click
+			if err := json.Unmarshal([]byte(msg.Payload), &click); err != nil {
+				continue
+			}
+			select {
+			case clicks <- click:
+			default:
+			}
+		}
+	}()
+
+	return &ClickSubscription{C: clicks, pubsub: pubsub}, nil
+}
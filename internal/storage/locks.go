@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+// lockKeyPrefix prefixes the key of each named lock, holding its holder's
+// token
+const lockKeyPrefix = "lock:"
+
+// AcquireLock takes a named lock for ttl, returning the token renewing and
+// releasing it, or "" if another instance holds it
+func (s *RedisStore) AcquireLock(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := s.client.SetNX(ctx, lockKeyPrefix+name, token, ttl).Result()
+	if err != nil || !ok {
+		return "", err
+	}
+	return token, nil
+}
+
+// RenewLock extends a lock held by the token for another ttl. It returns
+// false if another instance took the lock since it lapsed.
+func (s *RedisStore) RenewLock(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	n, err := renewWorkerScript.Run(ctx, s.client, []string{lockKeyPrefix + name}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// ReleaseLock frees a lock if the token still holds it
+func (s *RedisStore) ReleaseLock(ctx context.Context, name, token string) error {
+	return releaseWorkerScript.Run(ctx, s.client, []string{lockKeyPrefix + name}, token).Err()
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// workspaceNotificationsSuffix names the notification settings of a workspace
+	workspaceNotificationsSuffix = ":notifications"
+
+	// milestoneFieldPrefix prefixes the stats fields marking the click
+	// milestones already announced in the current measurement period
+	milestoneFieldPrefix = "milestone:"
+)
+
+// Notifications chooses which events of a link are announced to whom
+type Notifications struct {
+	// Channels maps each channel, such as email or slack, to its recipients
+	Channels map[string][]string `json:"channels"`
+
+	// Events lists the kinds of events announced; every kind if empty
+	Events []string `json:"events,omitempty"`
+
+	// Milestones are the click counts announced; the deployment's default
+	// milestones if empty
+	Milestones []int64 `json:"milestones,omitempty"`
+}
+
+// NotificationStore represents the storage interface for notification
+// settings and for the milestones already announced
+type NotificationStore interface {
+	GetWorkspaceNotifications(ctx context.Context, workspace string) (*Notifications, error)
+	SetWorkspaceNotifications(ctx context.Context, workspace string, n *Notifications) error
+	ClaimMilestone(ctx context.Context, key string, clicks int64) (bool, error)
+}
+
+// GetWorkspaceNotifications returns the notification settings of the links
+// of a workspace, or nil if it has none
+func (s *RedisStore) GetWorkspaceNotifications(ctx context.Context, workspace string) (*Notifications, error) {
+	data, err := s.client.Get(ctx, workspaceNotificationsKey(workspace)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var n Notifications
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// SetWorkspaceNotifications replaces the notification settings of the links
+// of a workspace. Nil settings clear them.
+func (s *RedisStore) SetWorkspaceNotifications(ctx context.Context, workspace string, n *Notifications) error {
+	if n == nil {
+		return s.client.Del(ctx, workspaceNotificationsKey(workspace)).Err()
+	}
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, workspaceNotificationsKey(workspace), data, 0).Err()
+}
+
+// ClaimMilestone marks a click milestone of a key as announced, reporting
+// whether this call claimed it, so instances announce each milestone once.
+// Marks live with the key's counters, so they are dropped with them when
+// statistics are reset or the link is deleted.
+func (s *RedisStore) ClaimMilestone(ctx context.Context, key string, clicks int64) (bool, error) {
+	return s.client.HSetNX(ctx, statsKeyPrefix+key, milestoneFieldPrefix+strconv.FormatInt(clicks, 10), 1).Result()
+}
+
+// workspaceNotificationsKey returns the key of a workspace's notification settings
+func workspaceNotificationsKey(workspace string) string {
+	return workspaceKeyPrefix + workspace + workspaceNotificationsSuffix
+}
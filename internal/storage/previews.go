@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Preview is the link-preview metadata of a destination page
+type Preview struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// PreviewStore represents the storage interface for cached link previews
+type PreviewStore interface {
+	SavePreview(ctx context.Context, key, url string, p *Preview) error
+}
+
+// SavePreview caches the preview of a record's destination. The preview is
+// discarded if the record was deleted or now points elsewhere, so a slow
+// fetch never overwrites the preview of a newer destination.
+func (s *RedisStore) SavePreview(ctx context.Context, key, url string, p *Preview) error {
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, err := decodeRecord(value)
+		if err != nil {
+			return err
+		}
+		if rec.URL != url {
+			return nil
+		}
+
+		rec.Preview = p
+		value, err = encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true})
+			return nil
+		})
+		return err
+	}, key)
+	s.invalidate(key)
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	return err
+}
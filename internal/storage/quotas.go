@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ownerKeyPrefix prefixes the keys holding each owner's indexes
+	ownerKeyPrefix = "owner:"
+
+	// quotaKeyPrefix prefixes the daily creation counters
+	quotaKeyPrefix = "quota:"
+
+	// quotaDayFormat names the day a creation counter covers
+	quotaDayFormat = "20060102"
+
+	// quotaCounterTTL keeps daily counters a little past the day they cover
+	quotaCounterTTL = 48 * time.Hour
+)
+
+// ErrQuotaExceeded is returned when a daily creation quota is used up
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Scope identifies who a link is charged to: its workspace, or its owner
+// when it belongs to no workspace
+type Scope struct {
+	Workspace string
+	Owner     string
+}
+
+// ScopeOf returns the scope a link is charged to
+func ScopeOf(rec *Record) Scope {
+	if rec.Workspace != "" {
+		return Scope{Workspace: rec.Workspace}
+	}
+	return Scope{Owner: rec.Owner}
+}
+
+// IsZero reports whether the scope names neither a workspace nor an owner
+func (s Scope) IsZero() bool {
+	return s.Workspace == "" && s.Owner == ""
+}
+
+// prefix returns the key prefix of the scope's indexes
+func (s Scope) prefix() string {
+	if s.Workspace != "" {
+		return workspaceKeyPrefix + s.Workspace
+	}
+	return ownerKeyPrefix + s.Owner
+}
+
+// linksKey returns the key of the set of keys charged to the scope
+func (s Scope) linksKey() string {
+	return s.prefix() + workspaceLinksSuffix
+}
+
+// QuotaStore represents the storage interface for creation quotas
+type QuotaStore interface {
+	ReserveDaily(ctx context.Context, scope Scope, day time.Time, limit int64) (int64, error)
+	ReleaseDaily(ctx context.Context, scope Scope, day time.Time) error
+	ActiveLinks(ctx context.Context, scope Scope, limit int64) (int64, error)
+}
+
+// reserveDailyScript counts a creation against a daily quota, refusing it
+// once the limit is reached so concurrent requests can never overshoot.
+var reserveDailyScript = redis.NewScript(`
+local used = tonumber(redis.call('GET', KEYS[1]) or '0')
+if used >= tonumber(ARGV[1]) then
+	return -1
+end
+used = redis.call('INCR', KEYS[1])
+if used == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return used
+`)
+
+// ReserveDaily counts one link creation against the scope's quota for the
+// UTC day and returns the number of creations used that day. It returns
+// ErrQuotaExceeded without counting once limit creations have been made.
+func (s *RedisStore) ReserveDaily(ctx context.Context, scope Scope, day time.Time, limit int64) (int64, error) {
+	ttl := int64(quotaCounterTTL / time.Second)
+	used, err := reserveDailyScript.Run(ctx, s.client, []string{dailyQuotaKey(scope, day)}, limit, ttl).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if used < 0 {
+		return limit, ErrQuotaExceeded
+	}
+	return used, nil
+}
+
+// ReleaseDaily returns a reserved creation to the scope's quota for the day,
+// for creations that did not go through
+func (s *RedisStore) ReleaseDaily(ctx context.Context, scope Scope, day time.Time) error {
+	return s.client.Decr(ctx, dailyQuotaKey(scope, day)).Err()
+}
+
+// ActiveLinks returns the number of live links charged to the scope. The
+// index is only pruned of expired links when the count reaches limit, so
+// checks well under the quota stay cheap.
+func (s *RedisStore) ActiveLinks(ctx context.Context, scope Scope, limit int64) (int64, error) {
+	n, err := s.client.SCard(ctx, scope.linksKey()).Result()
+	if err != nil || n < limit {
+		return n, err
+	}
+	return s.countLive(ctx, scope.linksKey())
+}
+
+// dailyQuotaKey returns the key of the scope's creation counter for a day
+func dailyQuotaKey(scope Scope, day time.Time) string {
+	return quotaKeyPrefix + scope.prefix() + ":" + day.UTC().Format(quotaDayFormat)
+}
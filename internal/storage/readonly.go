@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// readOnlyKey is set while read-only mode is on for every instance
+const readOnlyKey = "settings:read-only"
+
+// ReadOnlyStore shares read-only mode between instances
+type ReadOnlyStore interface {
+	ReadOnly(ctx context.Context) (bool, error)
+	SetReadOnly(ctx context.Context, enabled bool) error
+}
+
+// ReadOnly reports whether read-only mode is on
+func (s *RedisStore) ReadOnly(ctx context.Context) (bool, error) {
+	_, err := s.client.Get(ctx, readOnlyKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetReadOnly turns read-only mode on or off for every instance
+func (s *RedisStore) SetReadOnly(ctx context.Context, enabled bool) error {
+	if !enabled {
+		return s.client.Del(ctx, readOnlyKey).Err()
+	}
+	return s.client.Set(ctx, readOnlyKey, "1", 0).Err()
+}
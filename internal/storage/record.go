@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/rules"
+)
+
+// Errors returned when a record is resolved outside its activation window,
+// while disabled or before its creator verified their email
+var (
+	ErrNotYetActive    = errors.New("url mapping is not yet active")
+	ErrNoLongerActive  = errors.New("url mapping is no longer active")
+	ErrDisabled        = errors.New("url mapping is disabled")
+	ErrUnverified      = errors.New("url mapping awaits email verification")
+	ErrInvalidSchedule = errors.New("active_until must be after active_from")
+)
+
+// Record is a stored URL mapping together with its metadata
+type Record struct {
+	URL         string     `json:"url"`
+	Domain      string     `json:"domain,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Workspace   string     `json:"workspace,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+
+	// Label names the link in listings and Note holds free-form remarks
+	// for its owners; neither affects redirects
+	Label string `json:"label,omitempty"`
+	Note  string `json:"note,omitempty"`
+
+	// Disabled pauses the link's redirects until it is enabled again
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Email is the address of an anonymous creator. Unverified holds the
+	// link's redirects until they follow the verification email sent to it.
+	Email      string `json:"email,omitempty"`
+	Unverified bool   `json:"unverified,omitempty"`
+
+	// QueryParams are appended to the destination at redirect time.
+	// Values may contain {key} and {domain} placeholders.
+	QueryParams map[string]string `json:"query_params,omitempty"`
+
+	// Rules route visitors matching ordered conditions on their country,
+	// device, language and the time of the visit. The first match wins,
+	// ahead of every other routing.
+	Rules []rules.Rule `json:"rules,omitempty"`
+
+	// DeviceRules route visitors to platform-specific destinations.
+	// Rules are evaluated in order and the first match wins.
+	DeviceRules []DeviceRule `json:"device_rules,omitempty"`
+
+	// LocaleRules route visitors by their preferred languages, after
+	// device rules
+	LocaleRules []LocaleRule `json:"locale_rules,omitempty"`
+
+	// Variants split traffic for the default destination by weight.
+	// StickyVariants keeps returning visitors on the same variant.
+	Variants       []Variant `json:"variants,omitempty"`
+	StickyVariants bool      `json:"sticky_variants,omitempty"`
+
+	// Fallbacks stand in for URL, in order, while the health checker finds
+	// the destinations before them down. Down lists the destinations it
+	// last found failing.
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	Down      []string `json:"down,omitempty"`
+
+	// Schedule rotates the default destination at set times. Each entry
+	// takes over from its start until the next one starts; before the
+	// first, URL is used. Entries are kept sorted by start.
+	Schedule []ScheduledDestination `json:"schedule,omitempty"`
+
+	// Tags label the link for filtering; each tag is indexed for search
+	Tags []string `json:"tags,omitempty"`
+
+	// Signed requires redirects to carry the signature of the key, so the
+	// link can't be found by guessing keys
+	Signed bool `json:"signed,omitempty"`
+
+	// SingleUse deletes the link on its first redirect
+	SingleUse bool `json:"single_use,omitempty"`
+
+	// MaxClicks is how many redirects the link allows before it is no
+	// longer active, or 0 for no limit
+	MaxClicks int `json:"max_clicks,omitempty"`
+
+	// Interstitial shows a page before redirecting to the destination
+	Interstitial *Interstitial `json:"interstitial,omitempty"`
+
+	// Permanent redirects with 301 instead of 302. CacheMaxAge overrides,
+	// in seconds, how long browsers and CDNs may cache the redirect.
+	Permanent   bool `json:"permanent,omitempty"`
+	CacheMaxAge *int `json:"cache_max_age,omitempty"`
+
+	// Expiry overrides the deployment's policy for extending the link's TTL
+	// when it is read
+	Expiry ExpiryPolicy `json:"expiry,omitempty"`
+
+	// Preview caches the destination's metadata for link-preview bots
+	Preview *Preview `json:"preview,omitempty"`
+
+	// Check is the outcome of the last link-rot check of the destination
+	Check *DestinationCheck `json:"check,omitempty"`
+
+	// Notifications overrides the workspace's settings for announcing the
+	// link's events to its owners
+	Notifications *Notifications `json:"notifications,omitempty"`
+
+	// Aliases are the link's former keys, newest first, which redirect to
+	// it until their grace period is over
+	Aliases []Alias `json:"aliases,omitempty"`
+}
+
+// Variant is a weighted destination in a split test
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// CheckStatus is the outcome of a destination check
+type CheckStatus string
+
+// Outcomes of a destination check
+const (
+	CheckUnchecked CheckStatus = "unchecked"
+	CheckOK        CheckStatus = "ok"
+	CheckBroken    CheckStatus = "broken"
+)
+
+// DestinationCheck is the outcome of requesting a link's destination.
+// Status is the HTTP status answered, or 0 if the request failed.
+type DestinationCheck struct {
+	Status    int         `json:"status,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Result    CheckStatus `json:"result"`
+	CheckedAt time.Time   `json:"checked_at"`
+}
+
+// ScheduledDestination is a destination taking over at a set time
+type ScheduledDestination struct {
+	From time.Time `json:"from"`
+	URL  string    `json:"url"`
+}
+
+// LocaleRule sends visitors preferring a language, a BCP 47 tag such as
+// "de" or "pt-BR", to a specific destination
+type LocaleRule struct {
+	Language string `json:"language"`
+	URL      string `json:"url"`
+}
+
+// Interstitial is a page shown for Seconds before redirecting, such as a
+// disclaimer carrying Message
+type Interstitial struct {
+	Seconds int    `json:"seconds"`
+	Message string `json:"message,omitempty"`
+}
+
+// DeviceRule sends visitors on a platform to a specific destination
+type DeviceRule struct {
+	Platform string `json:"platform"`
+	URL      string `json:"url"`
+}
+
+// CheckActive reports whether the record may be resolved at the given time
+func (r *Record) CheckActive(now time.Time) error {
+	if r.Disabled {
+		return ErrDisabled
+	}
+	if r.Unverified {
+		return ErrUnverified
+	}
+	if r.ActiveFrom != nil && now.Before(*r.ActiveFrom) {
+		return ErrNotYetActive
+	}
+	if r.ActiveUntil != nil && !now.Before(*r.ActiveUntil) {
+		return ErrNoLongerActive
+	}
+	return nil
+}
+
+// HealthyURL returns the first of URL and its fallbacks not marked down.
+// If every one is down, URL is returned.
+func (r *Record) HealthyURL() string {
+	if len(r.Down) == 0 {
+		return r.URL
+	}
+	for _, dest := range r.Destinations() {
+		if !slices.Contains(r.Down, dest) {
+			return dest
+		}
+	}
+	return r.URL
+}
+
+// Destinations returns URL followed by its fallbacks
+func (r *Record) Destinations() []string {
+	return append([]string{r.URL}, r.Fallbacks...)
+}
+
+// ScheduledURL returns the scheduled destination in effect at the given
+// time, reporting false before the first entry takes over
+func (r *Record) ScheduledURL(now time.Time) (string, bool) {
+	dest, ok := "", false
+	for _, entry := range r.Schedule {
+		if now.Before(entry.From) {
+			break
+		}
+		dest, ok = entry.URL, true
+	}
+	return dest, ok
+}
+
+// NextScheduleChange returns when the scheduled destination next changes
+// after the given time, or nil if it never does
+func (r *Record) NextScheduleChange(now time.Time) *time.Time {
+	for _, entry := range r.Schedule {
+		if now.Before(entry.From) {
+			from := entry.From
+			return &from
+		}
+	}
+	return nil
+}
+
+// CheckStatus returns the outcome of the last destination check
+func (r *Record) CheckStatus() CheckStatus {
+	if r.Check == nil {
+		return CheckUnchecked
+	}
+	return r.Check.Result
+}
+
+// Matches reports whether the record's destination, label or note contains
+// the lowercase query
+func (r *Record) Matches(query string) bool {
+	return strings.Contains(strings.ToLower(r.URL), query) ||
+		strings.Contains(strings.ToLower(r.Label), query) ||
+		strings.Contains(strings.ToLower(r.Note), query)
+}
+
+// Validate checks the record's metadata for consistency
+func (r *Record) Validate() error {
+	if r.ActiveFrom != nil && r.ActiveUntil != nil && !r.ActiveUntil.After(*r.ActiveFrom) {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
+// encodeRecord serializes a record for storage, stamped with the current
+// schema version
+func encodeRecord(rec *Record) (string, error) {
+	data, err := json.Marshal(storedRecord{Schema: SchemaVersion, Record: rec})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeRecord parses a stored value, migrating records of older schema
+// versions. Values written before records were introduced hold the bare URL
+// and are returned as a record without metadata. Records of newer versions,
+// written by newer builds during a rolling deploy, are read as far as this
+// build understands them.
+func decodeRecord(value string) (*Record, error) {
+	if !strings.HasPrefix(value, "{") {
+		return &Record{URL: value}, nil
+	}
+	if v := recordSchema(value); v < SchemaVersion {
+		migrated, err := migrateRecord(value, v)
+		if err != nil {
+			return nil, err
+		}
+		value = migrated
+	}
+
+	var rec Record
+	if err := json.Unmarshal([]byte(value), &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord_CheckActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		rec  Record
+		want error
+	}{
+		{name: "No window", rec: Record{}, want: nil},
+		{name: "Within window", rec: Record{ActiveFrom: &past, ActiveUntil: &future}, want: nil},
+		{name: "Before window", rec: Record{ActiveFrom: &future}, want: ErrNotYetActive},
+		{name: "After window", rec: Record{ActiveUntil: &past}, want: ErrNoLongerActive},
+		{name: "At window end", rec: Record{ActiveUntil: &now}, want: ErrNoLongerActive},
+		{name: "Disabled", rec: Record{Disabled: true, ActiveFrom: &past}, want: ErrDisabled},
+		{name: "Unverified", rec: Record{Unverified: true}, want: ErrUnverified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rec.CheckActive(now))
+		})
+	}
+}
+
+func TestRecord_Validate(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	assert.NoError(t, (&Record{ActiveFrom: &now, ActiveUntil: &later}).Validate())
+	assert.NoError(t, (&Record{ActiveFrom: &now}).Validate())
+	assert.Equal(t, ErrInvalidSchedule, (&Record{ActiveFrom: &later, ActiveUntil: &now}).Validate())
+	assert.Equal(t, ErrInvalidSchedule, (&Record{ActiveFrom: &now, ActiveUntil: &now}).Validate())
+}
+
+func TestRecord_HealthyURL(t *testing.T) {
+	rec := &Record{URL: "https://example.com/sale", Fallbacks: []string{"https://backup.example.com", "https://example.com/soon"}}
+	assert.Equal(t, "https://example.com/sale", rec.HealthyURL())
+
+	rec.Down = []string{"https://example.com/sale"}
+	assert.Equal(t, "https://backup.example.com", rec.HealthyURL())
+
+	rec.Down = []string{"https://example.com/sale", "https://backup.example.com"}
+	assert.Equal(t, "https://example.com/soon", rec.HealthyURL())
+
+	// With every destination down the primary is served
+	rec.Down = rec.Destinations()
+	assert.Equal(t, "https://example.com/sale", rec.HealthyURL())
+}
+
+func TestRecord_ScheduledURL(t *testing.T) {
+	start := time.Date(2026, 11, 1, 18, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	rec := &Record{URL: "https://example.com/teaser", Schedule: []ScheduledDestination{
+		{From: start, URL: "https://example.com/live"},
+		{From: end, URL: "https://example.com/replay"},
+	}}
+
+	_, ok := rec.ScheduledURL(start.Add(-time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, &start, rec.NextScheduleChange(start.Add(-time.Minute)))
+
+	dest, ok := rec.ScheduledURL(start)
+	assert.True(t, ok)
+	assert.Equal(t, "https://example.com/live", dest)
+	assert.Equal(t, &end, rec.NextScheduleChange(start))
+
+	dest, _ = rec.ScheduledURL(end.Add(time.Hour))
+	assert.Equal(t, "https://example.com/replay", dest)
+	assert.Nil(t, rec.NextScheduleChange(end))
+}
+
+func TestRecord_Matches(t *testing.T) {
+	rec := &Record{URL: "https://example.com/Pricing", Label: "Spring Launch", Note: "Shared in the April newsletter"}
+
+	assert.True(t, rec.Matches("pricing"))
+	assert.True(t, rec.Matches("launch"))
+	assert.True(t, rec.Matches("newsletter"))
+	assert.False(t, rec.Matches("summer"))
+}
+
+func TestDecodeRecord(t *testing.T) {
+	rec, err := decodeRecord("https://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{URL: "https://example.com"}, rec)
+
+	rec, err = decodeRecord(`{"url":"https://example.com","domain":"go.example.com"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "go.example.com", rec.Domain)
+
+	_, err = decodeRecord(`{"url":`)
+	assert.Error(t, err)
+}
+
+func TestRecordSchema(t *testing.T) {
+	// Every version below the current one has a migration
+	assert.Len(t, migrations, SchemaVersion)
+
+	value, err := encodeRecord(&Record{URL: "https://example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaVersion, recordSchema(value))
+	assert.Zero(t, recordSchema(`{"url":"https://example.com"}`))
+	assert.Zero(t, recordSchema("https://example.com"))
+
+	// Records of newer builds are read as far as they are understood
+	rec, err := decodeRecord(`{"schema":99,"url":"https://example.com","label":"New","added_later":true}`)
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{URL: "https://example.com", Label: "New"}, rec)
+
+	rec, err = ParseRecord([]byte(`{"url":"https://example.com","tags":["old"]}`), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{URL: "https://example.com", Tags: []string{"old"}}, rec)
+}
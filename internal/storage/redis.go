@@ -3,20 +3,31 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/queue"
 )
 
 const (
 	// DefaultTTL is the default time-to-live for URL mappings (3 hours)
 	DefaultTTL = 3 * time.Hour
+
+	// hotKeysKey is the sorted set tracking access counts per key
+	hotKeysKey = "stats:hot"
 )
 
 // Error types for storage operations
 var (
 	ErrNotFound  = errors.New("url mapping not found")
 	ErrKeyExists = errors.New("key already exists")
+
+	// ErrLinkReplaced is returned when a key holds another link than the
+	// one a conditional delete was meant for
+	ErrLinkReplaced = errors.New("key holds a link created at another time")
 )
 
 // Store represents the storage interface for URL mappings
@@ -24,20 +35,118 @@ type Store interface {
 	Set(ctx context.Context, key, url string) error
 	Get(ctx context.Context, key string) (string, error)
 	Delete(ctx context.Context, key string) error
+
+	// DeleteIfCreated deletes a link only if it was created at createdAt,
+	// returning ErrLinkReplaced otherwise. The check and the delete are one
+	// operation, so a link created meanwhile under the key is left alone.
+	DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error
+
+	Create(ctx context.Context, key string, rec *Record) error
+	GetRecord(ctx context.Context, key string) (*Record, error)
+	Update(ctx context.Context, key string, rec *Record) error
+	ExpiresAt(ctx context.Context, key string) (*time.Time, error)
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+
+	// GetAndRefreshTTL reads a record and refreshes its TTL as one
+	// operation, so a link deleted meanwhile isn't tracked again
+	GetAndRefreshTTL(ctx context.Context, key string) (*Record, error)
+
+	// GetMulti reads the records of several keys at once, in order, with
+	// nil for keys holding no link. Records that can't be read get an error
+	// in the same position, without failing the others. Reads don't refresh
+	// TTLs.
+	GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error)
+}
+
+// Reader is the read-only subset of Store used to resolve keys
+type Reader interface {
+	GetRecord(ctx context.Context, key string) (*Record, error)
+}
+
+// Consumer deletes links as they are used, for single-use links
+type Consumer interface {
+	Consume(ctx context.Context, key string) (*Record, error)
+}
+
+// HotKey is a URL mapping together with its access count
+type HotKey struct {
+	Key  string  `json:"key"`
+	URL  string  `json:"url"`
+	Hits float64 `json:"hits"`
+
+	// Domain and the activation window keep the link to where and when it
+	// is served
+	Domain      string     `json:"domain,omitempty"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
 }
 
 // RedisStore implements the Store interface using Redis
 type RedisStore struct {
 	client *redis.Client
 	ttl    time.Duration
+	cache  *recordCache
+
+	// missing remembers keys recently found not to exist
+	missing *recordCache
+
+	policy   ExpiryPolicy
+	accesses *queue.Queue[access]
+
+	rollups *RollupOptions
+	archive bool
+	visits  *VisitOptions
+	live    bool
+	replica *replica
+}
+
+// RedisOptions tunes the Redis client's connection pool, timeouts and
+// retries. Zero values keep the go-redis defaults.
+type RedisOptions struct {
+	// PoolSize is the maximum number of connections; MinIdleConns are kept
+	// open so bursts don't wait on new connections
+	PoolSize     int
+	MinIdleConns int
+
+	// DialTimeout, ReadTimeout and WriteTimeout bound each network operation;
+	// PoolTimeout bounds the wait for a free connection when the pool is busy
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+
+	// MaxRetries is the number of retries of failed commands, waiting between
+	// MinRetryBackoff and MaxRetryBackoff before each one. -1 disables retries.
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
 }
 
 // NewRedisStore creates a new RedisStore instance
 func NewRedisStore(addr, password string, db int) *RedisStore {
+	return NewRedisStoreWithOptions(addr, password, db, RedisOptions{})
+}
+
+// NewRedisStoreWithOptions creates a new RedisStore instance with a tuned client
+func NewRedisStoreWithOptions(addr, password string, db int, opts RedisOptions) *RedisStore {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
+
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolTimeout:  opts.PoolTimeout,
+
+		MaxRetries:      opts.MaxRetries,
+		MinRetryBackoff: opts.MinRetryBackoff,
+		MaxRetryBackoff: opts.MaxRetryBackoff,
+
+		// Commands give up when the request they serve times out
+		ContextTimeoutEnabled: true,
 	})
 
 	return &RedisStore{
@@ -46,61 +155,559 @@ func NewRedisStore(addr, password string, db int) *RedisStore {
 	}
 }
 
+// EnableCache serves records from an in-process LRU cache of up to size
+// entries, each kept for ttl. Updates and deletions through this store
+// invalidate its cache; changes made by other instances are seen once the
+// entry expires, so ttl should stay short.
+func (s *RedisStore) EnableCache(size int, ttl time.Duration) {
+	if size <= 0 {
+		s.cache = nil
+		return
+	}
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	s.cache = newRecordCache(size, ttl)
+}
+
+// EnableNegativeCache remembers up to size keys found not to exist for ttl
+// each, answering further lookups of them with ErrNotFound without reading
+// Redis, so scanners guessing keys don't translate into storage load. Keys
+// created through this store are forgotten at once; keys created by other
+// instances are found once the entry expires, so ttl should stay short.
+func (s *RedisStore) EnableNegativeCache(size int, ttl time.Duration) {
+	if size <= 0 {
+		s.missing = nil
+		return
+	}
+	if ttl <= 0 {
+		ttl = DefaultNegativeCacheTTL
+	}
+	s.missing = newRecordCache(size, ttl)
+	s.missing.stats = negativeCacheStats
+}
+
+// invalidate drops a changed key from the in-process cache, and reads it
+// from the primary until the change has replicated
+func (s *RedisStore) invalidate(key string) {
+	if s.cache != nil {
+		s.cache.remove(key)
+	}
+	s.markWritten(key)
+}
+
+// found forgets that a key was missing once it has been created
+func (s *RedisStore) found(key string) {
+	if s.missing != nil {
+		s.missing.remove(key)
+	}
+}
+
 // Set stores a URL mapping with the specified key
 func (s *RedisStore) Set(ctx context.Context, key, url string) error {
-	// Validate inputs
+	return s.Create(ctx, key, &Record{
+		URL:       url,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// createScript stores a link unless its key is taken by a link or a landing
+// page, indexing it in the same step so no link is ever found without its
+// indexes, and dropping the claimed clicks and statistics a former link at
+// the key left behind. KEYS are the link, the expiry index, its claimed
+// clicks, its counters, its statistics periods, the prefix of its archived
+// periods, the landing page at its key and the sets indexing it; ARGV the
+// record, its TTL in milliseconds and when it then expires in Unix
+// milliseconds.
+var createScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[7]) == 1 then
+	return 0
+end
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[3], KEYS[1])
+local periods = tonumber(redis.call('GET', KEYS[5]) or '0')
+for period = 1, periods do
+	redis.call('DEL', KEYS[6] .. period)
+end
+redis.call('DEL', KEYS[3], KEYS[4], KEYS[5])
+for i = 8, #KEYS do
+	redis.call('SADD', KEYS[i], KEYS[1])
+end
+return 1
+`)
+
+// Create stores a URL mapping record with the specified key, together with
+// its indexes. It returns ErrKeyExists if a link or a landing page holds key.
+func (s *RedisStore) Create(ctx context.Context, key string, rec *Record) error {
+	value, err := encodeNew(key, rec)
+	if err != nil {
+		return err
+	}
+
+	life := s.lifetime(rec, time.Now())
+	keys := []string{
+		key, expiryIndexKey, claimedClicksKey(key),
+		statsKeyPrefix + key, statsKeyPrefix + key + periodsSuffix, statsKeyPrefix + key + periodSuffix,
+		landingPageKeyPrefix + key,
+	}
+	for _, tag := range rec.Tags {
+		keys = append(keys, tagKeyPrefix+tag)
+	}
+	if scope := ScopeOf(rec); !scope.IsZero() {
+		keys = append(keys, scope.linksKey())
+	}
+	if len(rec.Fallbacks) > 0 {
+		keys = append(keys, fallbackLinksKey)
+	}
+	created, err := createScript.Run(ctx, s.client, keys,
+		value, life.Milliseconds(), time.Now().Add(life).UnixMilli(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	s.found(key)
+	if created == 0 {
+		return ErrKeyExists
+	}
+	return nil
+}
+
+// encodeNew validates a record about to be created and serializes it
+func encodeNew(key string, rec *Record) (string, error) {
 	if key == "" {
-		return errors.New("key cannot be empty")
+		return "", errors.New("key cannot be empty")
 	}
-	if url == "" {
+	if rec == nil || rec.URL == "" {
+		return "", errors.New("url cannot be empty")
+	}
+	if err := rec.Validate(); err != nil {
+		return "", err
+	}
+	return encodeRecord(rec)
+}
+
+// Update replaces the record of an existing URL mapping, keeping its TTL
+func (s *RedisStore) Update(ctx context.Context, key string, rec *Record) error {
+	if rec == nil || rec.URL == "" {
 		return errors.New("url cannot be empty")
 	}
+	if err := rec.Validate(); err != nil {
+		return err
+	}
 
-	// Try to set the key only if it doesn't exist
-	success, err := s.client.SetNX(ctx, key, url, s.ttl).Result()
+	value, err := encodeRecord(rec)
 	if err != nil {
 		return err
 	}
-	if !success {
-		return ErrKeyExists
+
+	// Swap the value, keeping the previous one to update the tag indexes
+	previous, err := s.client.SetArgs(ctx, key, value, redis.SetArgs{Mode: "XX", KeepTTL: true, Get: true}).Result()
+	s.invalidate(key)
+	if err == redis.Nil {
+		if previous == "" {
+			return ErrNotFound
+		}
+		err = nil
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+
+	old, err := decodeRecord(previous)
+	if err != nil {
+		old = &Record{}
+	}
+	added, removed := tagDiff(rec.Tags, old.Tags), tagDiff(old.Tags, rec.Tags)
+	fallbacksChanged := (len(rec.Fallbacks) > 0) != (len(old.Fallbacks) > 0)
+	if len(added) > 0 || len(removed) > 0 || fallbacksChanged {
+		_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			indexTags(ctx, pipe, key, added)
+			unindexTags(ctx, pipe, key, removed)
+			if fallbacksChanged {
+				indexFallbacks(ctx, pipe, key, rec)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.outliveActivation(ctx, key, rec)
 }
 
-// Get retrieves a URL mapping by key
+// lifetime is how long a new link lives: the TTL, counted from when the
+// link activates if that is later than now
+func (s *RedisStore) lifetime(rec *Record, now time.Time) time.Duration {
+	if rec.ActiveFrom != nil && rec.ActiveFrom.After(now) {
+		return rec.ActiveFrom.Sub(now) + s.ttl
+	}
+	return s.ttl
+}
+
+// outliveActivation extends the TTL of a link moved to activate after it
+// would expire, so it lives the TTL past its activation
+func (s *RedisStore) outliveActivation(ctx context.Context, key string, rec *Record) error {
+	now := time.Now()
+	life := s.lifetime(rec, now)
+	if life == s.ttl {
+		return nil
+	}
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil || ttl <= 0 || ttl >= life {
+		// Links that never expire or already outlive it are left alone
+		return err
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.PExpire(ctx, key, life)
+		indexExpiry(ctx, pipe, key, now.Add(life))
+		return nil
+	})
+	return err
+}
+
+// Get retrieves a URL mapping by key. Mappings outside their activation
+// window return ErrNotYetActive or ErrNoLongerActive.
 func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
-	url, err := s.client.Get(ctx, key).Result()
+	rec, err := s.GetRecord(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if err := rec.CheckActive(time.Now()); err != nil {
+		return "", err
+	}
+	return rec.URL, nil
+}
+
+// GetRecord retrieves a URL mapping record by key, refreshing the TTL of
+// links with sliding expiry. With the cache enabled, recently read records
+// are served from memory; their TTL refresh and access counts are applied
+// when the cached entry expires. With a replica enabled, records are read
+// from it while it can be trusted.
+func (s *RedisStore) GetRecord(ctx context.Context, key string) (*Record, error) {
+	var cachedHits int64
+	if s.cache != nil {
+		value, ok, hits := s.cache.get(key, time.Now())
+		if ok {
+			return decodeRecord(value)
+		}
+		cachedHits = hits
+	}
+	if s.missing != nil {
+		if _, ok, _ := s.missing.get(key, time.Now()); ok {
+			return nil, ErrNotFound
+		}
+	}
+
+	// Without a replica or queued accesses, the read and its bookkeeping
+	// share a round trip
+	touched := s.replica == nil && s.accesses == nil
+	var value string
+	var err error
+	if touched {
+		value, err = s.getAndTouch(ctx, key, 1+cachedHits, s.policy != ExpiryAbsolute)
+	} else {
+		value, err = s.readValue(ctx, key)
+	}
 	if err == redis.Nil {
-		return "", ErrNotFound
+		if s.missing != nil {
+			s.missing.add(key, "", time.Now())
+		}
+		return nil, ErrNotFound
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Refresh TTL on access
-	if err := s.client.Expire(ctx, key, s.ttl).Err(); err != nil {
-		// Log warning but don't fail the get operation
-		// TODO: Add proper logging
-		_ = err
+	rec, err := decodeRecord(value)
+	if err != nil {
+		return nil, err
+	}
+	if recordSchema(value) < SchemaVersion {
+		// Upgrades are best effort; the record is read alike either way
+		if ok, _ := s.upgradeRecord(ctx, key, value, rec); ok {
+			value, _ = encodeRecord(rec)
+		}
+	}
+	if s.cache != nil {
+		s.cache.add(key, value, time.Now())
 	}
 
-	return url, nil
+	if !touched {
+		s.recordAccess(ctx, access{key: key, hits: 1 + cachedHits, sliding: s.sliding(rec)})
+	}
+	return rec, nil
 }
 
-// Delete removes a URL mapping
+// getAndTouchScript reads a link and writes the bookkeeping of the read:
+// the TTL of a link with sliding expiry is refreshed and indexed unless
+// that would shorten it, as for links yet to activate, and its access
+// counted for hot key exports. A missing link is left untracked.
+// KEYS are the link, the expiry index and the hot keys; ARGV the TTL in
+// milliseconds, when the link then expires in Unix milliseconds, whether
+// links slide by default and the accesses to count.
+var getAndTouchScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+local sliding = ARGV[3] == '1'
+if string.sub(value, 1, 1) == '{' then
+	local ok, rec = pcall(cjson.decode, value)
+	if ok and type(rec) == 'table' and rec.expiry then
+		sliding = rec.expiry ~= 'absolute'
+	end
+end
+if sliding and redis.call('PTTL', KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+	redis.call('ZADD', KEYS[2], ARGV[2], KEYS[1])
+end
+redis.call('ZINCRBY', KEYS[3], ARGV[4], KEYS[1])
+return value
+`)
+
+// getAndTouch reads a stored value from the primary, writing the
+// bookkeeping of hits reads in the same round trip. sliding says whether
+// links without an expiry of their own slide.
+func (s *RedisStore) getAndTouch(ctx context.Context, key string, hits int64, sliding bool) (string, error) {
+	flag := "0"
+	if sliding {
+		flag = "1"
+	}
+	return getAndTouchScript.Run(ctx, s.client,
+		[]string{key, expiryIndexKey, hotKeysKey},
+		s.ttl.Milliseconds(), time.Now().Add(s.ttl).UnixMilli(), flag, hits,
+	).Text()
+}
+
+// GetAndRefreshTTL reads a record from the primary, bypassing the cache
+// and replica, refreshing the TTL of links with sliding expiry and counting
+// the access in the same atomic operation
+func (s *RedisStore) GetAndRefreshTTL(ctx context.Context, key string) (*Record, error) {
+	value, err := s.getAndTouch(ctx, key, 1, s.policy != ExpiryAbsolute)
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(value)
+}
+
+// GetMulti reads the records of several keys in one round trip, in order,
+// with nil for keys holding no link. TTLs and access counts are left alone,
+// since bulk reads aren't visits.
+func (s *RedisStore) GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error) {
+	recs := make([]*Record, len(keys))
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return recs, errs, nil
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if recs[i], err = decodeRecord(value); err != nil {
+			errs[i] = fmt.Errorf("decoding %s: %w", keys[i], err)
+		}
+	}
+	return recs, errs, nil
+}
+
+// ExpiresAt returns when a URL mapping expires, or nil if it never does
+func (s *RedisStore) ExpiresAt(ctx context.Context, key string) (*time.Time, error) {
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	// PTTL reports -2 for missing keys and -1 for keys without expiry
+	switch ttl {
+	case -2:
+		return nil, ErrNotFound
+	case -1:
+		return nil, nil
+	}
+	t := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	return &t, nil
+}
+
+// Delete removes a URL mapping together with its indexes and statistics
 func (s *RedisStore) Delete(ctx context.Context, key string) error {
-	result, err := s.client.Del(ctx, key).Result()
+	_, err := s.take(ctx, key)
+	return err
+}
+
+// DeleteIfCreated removes a URL mapping created at createdAt together with
+// its indexes and statistics
+func (s *RedisStore) DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error {
+	_, err := s.take(ctx, key, createdStamp(createdAt))
+	return err
+}
+
+// createdStamp formats a creation time the way records store it, or as
+// empty for the zero time, which records written as bare URLs report
+func createdStamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Consume deletes a URL mapping, returning its record. The link is read and
+// deleted by one script, so of concurrent calls for a key, only one gets
+// the record; the others return ErrNotFound.
+func (s *RedisStore) Consume(ctx context.Context, key string) (*Record, error) {
+	rec, err := s.take(ctx, key)
+	if err == nil && rec.URL == "" {
+		return nil, fmt.Errorf("consuming %s: undecodable record", key)
+	}
+	return rec, err
+}
+
+// takeScript deletes a link together with what tracks it regardless of
+// its record, returning the link and its number of statistics periods, or
+// nothing if it doesn't exist. KEYS are the link, its statistics periods,
+// the fallback links, the hot keys, the expiry index, its expiry reminder,
+// its visits and its claimed clicks. If ARGV[1] is given, the link is only
+// deleted if it was created then, and 0 is returned otherwise.
+var takeScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return false
+end
+if ARGV[1] then
+	local created = ''
+	local ok, rec = pcall(cjson.decode, value)
+	if ok and type(rec) == 'table' and type(rec.created_at) == 'string' then
+		created = rec.created_at
+	end
+	if created == '0001-01-01T00:00:00Z' then
+		created = ''
+	end
+	if created ~= ARGV[1] then
+		return 0
+	end
+end
+local periods = redis.call('GET', KEYS[2]) or '0'
+redis.call('DEL', KEYS[1], KEYS[6], KEYS[7], KEYS[8])
+redis.call('SREM', KEYS[3], KEYS[1])
+redis.call('ZREM', KEYS[4], KEYS[1])
+redis.call('ZREM', KEYS[5], KEYS[1])
+return {value, periods}
+`)
+
+// take deletes a URL mapping and whatever tracks it, returning its record,
+// or an empty one if it can't be decoded. Given a creation stamp, it only
+// deletes the link created then.
+func (s *RedisStore) take(ctx context.Context, key string, created ...string) (*Record, error) {
+	args := make([]interface{}, len(created))
+	for i, c := range created {
+		args[i] = c
+	}
+	res, err := takeScript.Run(ctx, s.client, []string{
+		key, statsKeyPrefix + key + periodsSuffix, fallbackLinksKey, hotKeysKey,
+		expiryIndexKey, expiryReminderKeyPrefix + key, visitsKey(key), claimedClicksKey(key),
+	}, args...).Result()
+	s.invalidate(key)
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if result == 0 {
-		return ErrNotFound
+	taken, ok := res.([]interface{})
+	if !ok || len(taken) != 2 {
+		return nil, ErrLinkReplaced
 	}
-	return nil
+
+	// Stop tracking the deleted key by what its record says. Cleanup is
+	// best effort.
+	value, _ := taken[0].(string)
+	periods, _ := taken[1].(string)
+	rec, err := decodeRecord(value)
+	if err != nil {
+		rec = &Record{}
+	}
+	n, _ := strconv.Atoi(periods)
+	s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		unindexTags(ctx, pipe, key, rec.Tags)
+		unindexScope(ctx, pipe, key, rec)
+		pipe.Del(ctx, statsKeys(key, n)...)
+		for _, a := range rec.Aliases {
+			pipe.Del(ctx, aliasKeyPrefix+a.Key)
+		}
+		return nil
+	})
+	return rec, nil
+}
+
+// TopKeys returns up to n of the most accessed keys with their URLs.
+// Keys that have expired since they were last accessed are pruned.
+func (s *RedisStore) TopKeys(ctx context.Context, n int) ([]HotKey, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	scored, err := s.client.ZRevRangeWithScores(ctx, hotKeysKey, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(scored) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(scored))
+	for i, z := range scored {
+		keys[i] = z.Member.(string)
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	hot := make([]HotKey, 0, len(keys))
+	var stale []interface{}
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			stale = append(stale, keys[i])
+			continue
+		}
+		rec, err := decodeRecord(value)
+		if err != nil || rec.Signed || rec.SingleUse || rec.MaxClicks > 0 || rec.Disabled || rec.Unverified {
+			// Signed, single-use and click-limited links are left out,
+			// since whatever serves the keys can't check signatures or
+			// count clicks, and so are links taken down
+			continue
+		}
+		hot = append(hot, HotKey{
+			Key: keys[i], URL: rec.URL, Hits: scored[i].Score,
+			Domain: rec.Domain, ActiveFrom: rec.ActiveFrom, ActiveUntil: rec.ActiveUntil,
+		})
+	}
+
+	if len(stale) > 0 {
+		s.client.ZRem(ctx, hotKeysKey, stale...)
+	}
+
+	return hot, nil
 }
 
-// Close closes the Redis connection
+// Close writes queued accesses and closes the Redis connection
 func (s *RedisStore) Close() error {
+	if s.accesses != nil {
+		s.accesses.Close()
+	}
+	if s.replica != nil {
+		s.replica.client.Close()
+	}
 	return s.client.Close()
 }
 
@@ -2,13 +2,22 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
 )
 
 func setupTestRedis(t *testing.T) *RedisStore {
@@ -116,6 +125,28 @@ func TestRedisStore_Delete(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRedisStore_DeleteIfCreated(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	created := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "reused", &Record{URL: "https://example.com", CreatedAt: created}))
+
+	// A link re-created under the key is left alone
+	assert.Equal(t, ErrLinkReplaced, store.DeleteIfCreated(ctx, "reused", created.Add(-time.Minute)))
+	_, err := store.GetRecord(ctx, "reused")
+	require.NoError(t, err)
+
+	require.NoError(t, store.DeleteIfCreated(ctx, "reused", created))
+	assert.Equal(t, ErrNotFound, store.DeleteIfCreated(ctx, "reused", created))
+
+	// Values stored as bare URLs have no creation time
+	require.NoError(t, store.client.Set(ctx, "bare", "https://example.com", 0).Err())
+	assert.Equal(t, ErrLinkReplaced, store.DeleteIfCreated(ctx, "bare", created))
+	require.NoError(t, store.DeleteIfCreated(ctx, "bare", time.Time{}))
+}
+
 func TestRedisStore_ConnectionFailure(t *testing.T) {
 	// Try to connect to a non-existent Redis server
 	store := NewRedisStore("localhost:6380", "", 0)
@@ -204,3 +235,2080 @@ func TestRedisStore_TTLExpiration(t *testing.T) {
 	_, err = store.Get(ctx, "expiring")
 	assert.Equal(t, ErrNotFound, err)
 }
+
+func TestRedisStore_TopKeys(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "hot", "http://example.com/hot"))
+	require.NoError(t, store.Set(ctx, "warm", "http://example.com/warm"))
+	require.NoError(t, store.Set(ctx, "gone", "http://example.com/gone"))
+
+	for i := 0; i < 3; i++ {
+		_, err := store.Get(ctx, "hot")
+		require.NoError(t, err)
+	}
+	_, err := store.Get(ctx, "warm")
+	require.NoError(t, err)
+	_, err = store.Get(ctx, "gone")
+	require.NoError(t, err)
+
+	// Expired keys are dropped from the results, and taken down links are
+	// left out
+	require.NoError(t, store.client.Del(ctx, "gone").Err())
+	require.NoError(t, store.Create(ctx, "off", &Record{URL: "http://example.com/off", Disabled: true}))
+	_, err = store.Get(ctx, "off")
+	require.NoError(t, err)
+
+	top, err := store.TopKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	assert.Equal(t, HotKey{Key: "hot", URL: "http://example.com/hot", Hits: 3}, top[0])
+	assert.Equal(t, "warm", top[1].Key)
+
+	// Deleted keys stop being tracked
+	require.NoError(t, store.Delete(ctx, "hot"))
+	top, err = store.TopKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, "warm", top[0].Key)
+}
+
+func TestRedisStore_Record(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := store.Create(ctx, "rec1", &Record{URL: "http://example.com", Domain: "go.example.com", CreatedAt: createdAt})
+	require.NoError(t, err)
+
+	rec, err := store.GetRecord(ctx, "rec1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", rec.URL)
+	assert.Equal(t, "go.example.com", rec.Domain)
+	assert.Equal(t, createdAt, rec.CreatedAt)
+
+	// Values stored before records were introduced hold the bare URL
+	require.NoError(t, store.client.Set(ctx, "legacy1", "http://legacy.example.com", DefaultTTL).Err())
+	rec, err = store.GetRecord(ctx, "legacy1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://legacy.example.com", rec.URL)
+
+	url, err := store.Get(ctx, "legacy1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://legacy.example.com", url)
+}
+
+func TestRedisStore_Domains(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	d := &Domain{Name: "go.example.com", Method: "dns", Token: "token", Status: DomainPending}
+	require.NoError(t, store.CreateDomain(ctx, d, time.Hour))
+	assert.True(t, store.client.TTL(ctx, domainKeyPrefix+d.Name).Val() > 0)
+
+	// Unverified domains are claimed again, and saves of the former claim
+	// are refused
+	claim := &Domain{Name: "go.example.com", Method: "dns", Token: "other", Status: DomainPending}
+	require.NoError(t, store.CreateDomain(ctx, claim, time.Hour))
+	d.Status = DomainVerified
+	assert.Equal(t, ErrDomainNotFound, store.SaveDomain(ctx, d, time.Hour))
+
+	claim.Status = DomainVerified
+	require.NoError(t, store.SaveDomain(ctx, claim, time.Hour))
+	assert.Equal(t, time.Duration(-1), store.client.TTL(ctx, domainKeyPrefix+d.Name).Val())
+	assert.Equal(t, ErrKeyExists, store.CreateDomain(ctx, d, time.Hour))
+
+	got, err := store.GetDomain(ctx, "go.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, DomainVerified, got.Status)
+
+	_, err = store.GetDomain(ctx, "unknown.example.com")
+	assert.Equal(t, ErrDomainNotFound, err)
+	assert.Equal(t, ErrDomainNotFound, store.SaveDomain(ctx, &Domain{Name: "unknown.example.com"}, time.Hour))
+
+	domains, err := store.ListDomains(ctx)
+	require.NoError(t, err)
+	require.Len(t, domains, 1)
+	assert.Equal(t, "go.example.com", domains[0].Name)
+
+	require.NoError(t, store.DeleteDomain(ctx, "go.example.com"))
+	assert.Equal(t, ErrDomainNotFound, store.DeleteDomain(ctx, "go.example.com"))
+	_, err = store.GetDomain(ctx, "go.example.com")
+	assert.Equal(t, ErrDomainNotFound, err)
+}
+
+func TestRedisStore_Sessions(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	sess := &Session{ID: "sess1", Subject: "alice", CSRFToken: "csrf", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, store.CreateSession(ctx, sess))
+	assert.Equal(t, ErrKeyExists, store.CreateSession(ctx, sess))
+
+	// Sessions expire with their expiry time
+	ttl, err := store.client.TTL(ctx, sessionKeyPrefix+"sess1").Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Hour)
+
+	got, err := store.GetSession(ctx, "sess1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Subject)
+	assert.Equal(t, "csrf", got.CSRFToken)
+
+	require.NoError(t, store.DeleteSession(ctx, "sess1"))
+	_, err = store.GetSession(ctx, "sess1")
+	assert.Equal(t, ErrSessionNotFound, err)
+
+	assert.Error(t, store.CreateSession(ctx, &Session{ID: "old", ExpiresAt: time.Now().Add(-time.Minute)}))
+}
+
+func TestRedisStore_Update(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "upd1", "http://example.com"))
+	require.NoError(t, store.client.Expire(ctx, "upd1", time.Hour).Err())
+
+	rec, err := store.GetRecord(ctx, "upd1")
+	require.NoError(t, err)
+	rec.URL = "http://example.com/new"
+	rec.DeviceRules = []DeviceRule{{Platform: "ios", URL: "http://apps.example.com"}}
+	require.NoError(t, store.Update(ctx, "upd1", rec))
+
+	got, err := store.GetRecord(ctx, "upd1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/new", got.URL)
+	assert.Equal(t, rec.DeviceRules, got.DeviceRules)
+
+	// Update keeps the existing TTL
+	ttl, err := store.client.TTL(ctx, "upd1").Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	// Missing keys are not created
+	err = store.Update(ctx, "missing", &Record{URL: "http://example.com"})
+	assert.Equal(t, ErrNotFound, err)
+
+	// Empty URL
+	assert.Error(t, store.Update(ctx, "upd1", &Record{}))
+}
+
+func TestRedisStore_Stats(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "stats1", "http://example.com"))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "stats1"}))
+	}
+
+	stats, err := store.GetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+	assert.Equal(t, 1, stats.Period)
+
+	// Reset archives the current period
+	archived, err := store.ResetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived.Period)
+	assert.Equal(t, int64(3), archived.Clicks)
+	assert.NotNil(t, archived.Until)
+
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "stats1"}))
+	stats, err = store.GetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Clicks)
+	assert.Equal(t, 2, stats.Period)
+	assert.NotNil(t, stats.Since)
+
+	// Resetting a period without clicks archives zero counters
+	_, err = store.ResetStats(ctx, "stats1")
+	require.NoError(t, err)
+	archived, err = store.ResetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, archived.Period)
+	assert.Equal(t, int64(0), archived.Clicks)
+
+	all, err := store.GetArchivedStats(ctx, "stats1")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, int64(3), all[0].Clicks)
+	assert.Equal(t, int64(1), all[1].Clicks)
+
+	// Deleting the key removes its statistics
+	require.NoError(t, store.Delete(ctx, "stats1"))
+	stats, err = store.GetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Clicks)
+	assert.Equal(t, 1, stats.Period)
+
+	// A link created at the key of one that expired doesn't inherit its
+	// statistics or announced milestones
+	require.NoError(t, store.Set(ctx, "stats1", "http://example.com"))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "stats1"}))
+	_, err = store.ResetStats(ctx, "stats1")
+	require.NoError(t, err)
+	claimed, err := store.ClaimMilestone(ctx, "stats1", 10)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, store.client.Del(ctx, "stats1").Err())
+
+	require.NoError(t, store.Set(ctx, "stats1", "http://example.com"))
+	stats, err = store.GetStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Clicks)
+	assert.Equal(t, 1, stats.Period)
+	all, err = store.GetArchivedStats(ctx, "stats1")
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	assert.Equal(t, int64(0), store.client.Exists(ctx, "clicks:stats1:period:1").Val())
+	claimed, err = store.ClaimMilestone(ctx, "stats1", 10)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestRedisStore_VariantStats(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "variants1", "http://example.com"))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "variants1", Variant: "a"}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "variants1", Variant: "a"}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "variants1", Variant: "b"}))
+
+	stats, err := store.GetStats(ctx, "variants1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Clicks)
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, stats.Variants)
+
+	// Variant counters are archived with the period
+	archived, err := store.ResetStats(ctx, "variants1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 2, "b": 1}, archived.Variants)
+
+	stats, err = store.GetStats(ctx, "variants1")
+	require.NoError(t, err)
+	assert.Nil(t, stats.Variants)
+}
+
+func TestRedisStore_ChannelStats(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "channel1", "http://example.com"))
+	for _, channel := range []string{"search", "social:twitter", "search", ""} {
+		require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "channel1", Channel: channel}))
+	}
+	_, err := store.ClaimMilestone(ctx, "channel1", 1)
+	require.NoError(t, err)
+
+	stats, err := store.GetStats(ctx, "channel1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Clicks)
+	assert.Equal(t, map[string]int64{"search": 2, "social:twitter": 1}, stats.Channels)
+	assert.Nil(t, stats.Variants)
+}
+
+func TestRedisStore_AgentStats(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.EnableRollups(RollupOptions{})
+
+	require.NoError(t, store.Set(ctx, "device01", "http://example.com"))
+	clicks := []analytics.Click{
+		{Key: "device01", Device: "mobile", OS: "ios", Browser: "safari"},
+		{Key: "device01", Device: "mobile", OS: "android", Browser: "chrome"},
+		{Key: "device01", Device: "desktop", OS: "windows", Browser: "chrome"},
+		{Key: "device01"},
+	}
+	for _, click := range clicks {
+		click.Time = time.Now()
+		require.NoError(t, store.RecordClick(ctx, click))
+	}
+
+	stats, err := store.GetStats(ctx, "device01")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.Clicks)
+	assert.Equal(t, map[string]int64{"mobile": 2, "desktop": 1}, stats.Devices)
+	assert.Equal(t, map[string]int64{"ios": 1, "android": 1, "windows": 1}, stats.OS)
+	assert.Equal(t, map[string]int64{"safari": 1, "chrome": 2}, stats.Browsers)
+
+	_, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	now := time.Now()
+	rollups, err := store.GetRollups(ctx, "device01", GranularityHour, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, stats.Devices, rollups.Devices)
+	assert.Equal(t, stats.OS, rollups.OS)
+	assert.Equal(t, stats.Browsers, rollups.Browsers)
+
+	weekly := rollups.Regroup(GranularityWeek, time.UTC)
+	require.Len(t, weekly.Buckets, 1)
+	assert.Equal(t, stats.Browsers, weekly.Buckets[0].Browsers)
+}
+
+func TestRedisStore_SavePreview(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "preview1", "http://example.com"))
+
+	preview := &Preview{Title: "Example", FetchedAt: time.Now().UTC()}
+	require.NoError(t, store.SavePreview(ctx, "preview1", "http://example.com", preview))
+
+	rec, err := store.GetRecord(ctx, "preview1")
+	require.NoError(t, err)
+	require.NotNil(t, rec.Preview)
+	assert.Equal(t, "Example", rec.Preview.Title)
+
+	// A preview for a previous destination is discarded
+	rec.URL = "http://example.org"
+	rec.Preview = nil
+	require.NoError(t, store.Update(ctx, "preview1", rec))
+	require.NoError(t, store.SavePreview(ctx, "preview1", "http://example.com", preview))
+	rec, err = store.GetRecord(ctx, "preview1")
+	require.NoError(t, err)
+	assert.Nil(t, rec.Preview)
+
+	assert.Equal(t, ErrNotFound, store.SavePreview(ctx, "missing", "http://example.com", preview))
+}
+
+func TestRedisStore_ExpiresAt(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "exp1", "http://example.com"))
+	expiresAt, err := store.ExpiresAt(ctx, "exp1")
+	require.NoError(t, err)
+	require.NotNil(t, expiresAt)
+	assert.WithinDuration(t, time.Now().Add(DefaultTTL), *expiresAt, time.Minute)
+
+	_, err = store.ExpiresAt(ctx, "missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRedisStore_Search(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "search1", &Record{URL: "https://example.com/spring-sale", Owner: "alice", CreatedAt: now.Add(-2 * time.Minute), Tags: []string{"sale", "spring"}}))
+	require.NoError(t, store.Create(ctx, "search2", &Record{URL: "https://example.com/summer-sale", Owner: "bob", CreatedAt: now.Add(-time.Minute), Tags: []string{"sale"}}))
+	require.NoError(t, store.Create(ctx, "search3", &Record{URL: "https://example.org/blog", Owner: "alice", CreatedAt: now}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "search3"}))
+
+	keys := func(results []SearchResult) []string {
+		var keys []string
+		for _, r := range results {
+			keys = append(keys, r.Key)
+		}
+		return keys
+	}
+
+	// Every link, newest first, skipping auxiliary keys
+	results, err := store.Search(ctx, SearchQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search3", "search2", "search1"}, keys(results))
+	require.NotNil(t, results[0].ExpiresAt)
+
+	results, err = store.Search(ctx, SearchQuery{Tag: "sale"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search2", "search1"}, keys(results))
+
+	results, err = store.Search(ctx, SearchQuery{Query: "SUMMER"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search2"}, keys(results))
+
+	results, err = store.Search(ctx, SearchQuery{Owner: "alice", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search3"}, keys(results))
+
+	// Updating and deleting links keeps the tag index in sync
+	rec, err := store.GetRecord(ctx, "search1")
+	require.NoError(t, err)
+	rec.Tags = []string{"spring"}
+	require.NoError(t, store.Update(ctx, "search1", rec))
+	require.NoError(t, store.Delete(ctx, "search2"))
+
+	results, err = store.Search(ctx, SearchQuery{Tag: "sale"})
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = store.Search(ctx, SearchQuery{Tag: "spring"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search1"}, keys(results))
+}
+
+func TestRedisStore_Workspaces(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "acme1", &Record{URL: "https://example.com/a", Workspace: "acme", CreatedAt: now.Add(-time.Minute)}))
+	require.NoError(t, store.Create(ctx, "acme2", &Record{URL: "https://example.com/b", Workspace: "acme", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "globex1", &Record{URL: "https://example.com/c", Workspace: "globex", CreatedAt: now}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "acme1", Workspace: "acme"}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "acme2", Workspace: "acme", Variant: "a"}))
+
+	results, err := store.Search(ctx, SearchQuery{Workspace: "acme"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "acme2", results[0].Key)
+	assert.Equal(t, "acme1", results[1].Key)
+
+	stats, err := store.GetWorkspaceStats(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, &WorkspaceStats{Workspace: "acme", Links: 2, Clicks: 2}, stats)
+
+	// Deleted links leave the workspace, but their clicks still count
+	require.NoError(t, store.Delete(ctx, "acme1"))
+	stats, err = store.GetWorkspaceStats(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, &WorkspaceStats{Workspace: "acme", Links: 1, Clicks: 2}, stats)
+
+	stats, err = store.GetWorkspaceStats(ctx, "initech")
+	require.NoError(t, err)
+	assert.Equal(t, &WorkspaceStats{Workspace: "initech"}, stats)
+}
+
+func TestRedisStore_Quotas(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	acme := Scope{Workspace: "acme"}
+
+	for i := int64(1); i <= 2; i++ {
+		used, err := store.ReserveDaily(ctx, acme, day, 2)
+		require.NoError(t, err)
+		assert.Equal(t, i, used)
+	}
+	_, err := store.ReserveDaily(ctx, acme, day, 2)
+	assert.Equal(t, ErrQuotaExceeded, err)
+
+	// Released creations can be made again, and days are counted separately
+	require.NoError(t, store.ReleaseDaily(ctx, acme, day))
+	_, err = store.ReserveDaily(ctx, acme, day, 2)
+	require.NoError(t, err)
+	used, err := store.ReserveDaily(ctx, acme, day.Add(24*time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), used)
+
+	// Owners and workspaces of the same name are separate scopes
+	used, err = store.ReserveDaily(ctx, Scope{Owner: "acme"}, day, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), used)
+
+	require.NoError(t, store.Create(ctx, "quota1", &Record{URL: "https://example.com/1", Workspace: "acme"}))
+	require.NoError(t, store.Create(ctx, "quota2", &Record{URL: "https://example.com/2", Owner: "alice"}))
+	require.NoError(t, store.Create(ctx, "quota3", &Record{URL: "https://example.com/3", Owner: "alice"}))
+
+	active, err := store.ActiveLinks(ctx, acme, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), active)
+	active, err = store.ActiveLinks(ctx, Scope{Owner: "alice"}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), active)
+
+	// Deleted and expired links no longer count
+	require.NoError(t, store.Delete(ctx, "quota2"))
+	require.NoError(t, store.client.Del(ctx, "quota3").Err())
+	active, err = store.ActiveLinks(ctx, Scope{Owner: "alice"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), active)
+}
+
+func TestRedisStore_Idempotency(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	previous, err := store.BeginIdempotent(ctx, "subject:alice", "retry-1", "abc", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+
+	// A concurrent repeat sees the request in progress
+	previous, err = store.BeginIdempotent(ctx, "subject:alice", "retry-1", "abc", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, &IdempotentResponse{Fingerprint: "abc"}, previous)
+
+	resp := &IdempotentResponse{Fingerprint: "abc", Status: 201, ContentType: "application/json", Body: []byte(`{"short_key":"x"}`)}
+	require.NoError(t, store.CompleteIdempotent(ctx, "subject:alice", "retry-1", resp, time.Hour))
+	previous, err = store.BeginIdempotent(ctx, "subject:alice", "retry-1", "abc", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, resp, previous)
+
+	// Keys are scoped to the client
+	previous, err = store.BeginIdempotent(ctx, "subject:bob", "retry-1", "abc", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+
+	require.NoError(t, store.AbortIdempotent(ctx, "subject:bob", "retry-1"))
+	previous, err = store.BeginIdempotent(ctx, "subject:bob", "retry-1", "def", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+
+	// A claim lapses after its lock, and a stored response lives its TTL
+	previous, err = store.BeginIdempotent(ctx, "subject:carol", "retry-1", "abc", 10*time.Second)
+	require.NoError(t, err)
+	assert.Nil(t, previous)
+	assert.LessOrEqual(t, store.client.TTL(ctx, idempotencyKey("subject:carol", "retry-1")).Val(), 10*time.Second)
+	require.NoError(t, store.CompleteIdempotent(ctx, "subject:carol", "retry-1", resp, time.Hour))
+	assert.Greater(t, store.client.TTL(ctx, idempotencyKey("subject:carol", "retry-1")).Val(), 10*time.Second)
+}
+
+func TestRedisStore_Cache(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	store.EnableCache(2, time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "cached1", "https://example.com/1"))
+	rec, err := store.GetRecord(ctx, "cached1")
+	require.NoError(t, err)
+
+	// Hits are served from memory, each as its own copy
+	hits := cacheCounter("hits")
+	rec.URL = "https://mutated.example"
+	rec, err = store.GetRecord(ctx, "cached1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", rec.URL)
+	assert.Equal(t, hits+1, cacheCounter("hits"))
+
+	// Writes bypassing the store are only seen once the entry expires
+	require.NoError(t, store.client.Set(ctx, "cached1", `{"url":"https://elsewhere.example"}`, 0).Err())
+	url, err := store.Get(ctx, "cached1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", url)
+
+	// Updates and deletes through the store invalidate the cache
+	rec.URL = "https://example.com/updated"
+	require.NoError(t, store.Update(ctx, "cached1", rec))
+	url, err = store.Get(ctx, "cached1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/updated", url)
+
+	require.NoError(t, store.Delete(ctx, "cached1"))
+	_, err = store.Get(ctx, "cached1")
+	assert.Equal(t, ErrNotFound, err)
+
+	// The least recently used entry is evicted
+	evictions := cacheCounter("evictions")
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("cached%d", i+2)
+		require.NoError(t, store.Set(ctx, key, "https://example.com"))
+		_, err := store.Get(ctx, key)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, evictions+1, cacheCounter("evictions"))
+}
+
+func TestRecordCache_Expiry(t *testing.T) {
+	cache := newRecordCache(10, time.Second)
+	now := time.Now()
+
+	cache.add("key", "value", now)
+	for i := 0; i < 3; i++ {
+		value, ok, _ := cache.get("key", now)
+		require.True(t, ok)
+		assert.Equal(t, "value", value)
+	}
+
+	// Expired entries report the hits they served
+	_, ok, hits := cache.get("key", now.Add(time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, int64(3), hits)
+	_, ok, hits = cache.get("key", now)
+	assert.False(t, ok)
+	assert.Zero(t, hits)
+}
+
+func TestRedisStore_CreateMany(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "bulk2", "https://example.com/existing"))
+
+	errs, err := store.CreateMany(ctx, []BulkRecord{
+		{Key: "bulk1", Record: &Record{URL: "https://example.com/1", Tags: []string{"import"}, Workspace: "acme"}},
+		{Key: "bulk2", Record: &Record{URL: "https://example.com/2"}},
+		{Key: "bulk3", Record: &Record{}},
+		{Key: "bulk4", Record: &Record{URL: "https://example.com/4"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, ErrKeyExists, errs[1])
+	assert.Error(t, errs[2])
+	assert.NoError(t, errs[3])
+
+	url, err := store.Get(ctx, "bulk4")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/4", url)
+	url, err = store.Get(ctx, "bulk2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/existing", url)
+
+	// Created records are indexed like single creations
+	results, err := store.Search(ctx, SearchQuery{Tag: "import", Workspace: "acme"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "bulk1", results[0].Key)
+}
+
+func TestNewRedisStoreWithOptions(t *testing.T) {
+	store := NewRedisStoreWithOptions("localhost:6379", "", 0, RedisOptions{
+		PoolSize:     4,
+		MinIdleConns: 1,
+		ReadTimeout:  time.Second,
+		MaxRetries:   -1,
+	})
+	defer store.Close()
+
+	opts := store.client.Options()
+	assert.Equal(t, 4, opts.PoolSize)
+	assert.Equal(t, 1, opts.MinIdleConns)
+	assert.Equal(t, time.Second, opts.ReadTimeout)
+	assert.Equal(t, 0, opts.MaxRetries)
+	require.NoError(t, store.client.Ping(context.Background()).Err())
+}
+
+// memDurable is an in-memory DurableStore for TieredStore tests
+type memDurable struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func newMemDurable() *memDurable {
+	return &memDurable{records: make(map[string]Record)}
+}
+
+func (m *memDurable) Create(ctx context.Context, key string, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[key]; ok {
+		return ErrKeyExists
+	}
+	m.records[key] = *rec
+	return nil
+}
+
+func (m *memDurable) GetRecord(ctx context.Context, key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (m *memDurable) GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recs := make([]*Record, len(keys))
+	for i, key := range keys {
+		if rec, ok := m.records[key]; ok {
+			recs[i] = &rec
+		}
+	}
+	return recs, make([]error, len(keys)), nil
+}
+
+func (m *memDurable) Update(ctx context.Context, key string, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[key]; !ok {
+		return ErrNotFound
+	}
+	m.records[key] = *rec
+	return nil
+}
+
+func (m *memDurable) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.records, key)
+	return nil
+}
+
+func (m *memDurable) DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if !rec.CreatedAt.Equal(createdAt) {
+		return ErrLinkReplaced
+	}
+	delete(m.records, key)
+	return nil
+}
+
+func (m *memDurable) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var results []SearchResult
+	for key, rec := range m.records {
+		rec := rec
+		if q.Owner == "" || rec.Owner == q.Owner {
+			results = append(results, SearchResult{Key: key, Record: &rec})
+		}
+	}
+	return results, nil
+}
+
+func (m *memDurable) Walk(ctx context.Context, fn func(SearchResult) error) error {
+	results, _ := m.Search(ctx, SearchQuery{})
+	for _, r := range results {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTieredStore(t *testing.T) {
+	cache := setupTestRedis(t)
+	defer cache.Close()
+	durable := newMemDurable()
+	store := NewTieredStore(durable, cache)
+	ctx := context.Background()
+
+	rec := &Record{URL: "https://example.com", Owner: "alice", CreatedAt: time.Now().UTC()}
+	require.NoError(t, store.Create(ctx, "tiered", rec))
+	assert.Equal(t, ErrKeyExists, store.Create(ctx, "tiered", rec))
+
+	t.Run("Writes both tiers", func(t *testing.T) {
+		_, err := durable.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		_, err = cache.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+	})
+
+	t.Run("Repopulates the cache on a miss", func(t *testing.T) {
+		require.NoError(t, cache.client.Del(ctx, "tiered").Err())
+
+		url, err := store.Get(ctx, "tiered")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", url)
+
+		cached, err := cache.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", cached.URL)
+	})
+
+	t.Run("Links never expire", func(t *testing.T) {
+		expiresAt, err := store.ExpiresAt(ctx, "tiered")
+		require.NoError(t, err)
+		assert.Nil(t, expiresAt)
+	})
+
+	t.Run("Update refreshes the cached copy", func(t *testing.T) {
+		updated := *rec
+		updated.URL = "https://example.org"
+		require.NoError(t, store.Update(ctx, "tiered", &updated))
+
+		cached, err := cache.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.org", cached.URL)
+		stored, err := durable.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.org", stored.URL)
+	})
+
+	t.Run("Preview is saved in both tiers", func(t *testing.T) {
+		require.NoError(t, store.SavePreview(ctx, "tiered", "https://example.org", &Preview{Title: "Example"}))
+
+		stored, err := durable.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		require.NotNil(t, stored.Preview)
+		assert.Equal(t, "Example", stored.Preview.Title)
+		cached, err := cache.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+		require.NotNil(t, cached.Preview)
+	})
+
+	t.Run("Search reads the durable store", func(t *testing.T) {
+		require.NoError(t, cache.client.Del(ctx, "tiered").Err())
+
+		results, err := store.Search(ctx, SearchQuery{Owner: "alice"})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "tiered", results[0].Key)
+	})
+
+	t.Run("Delete removes both tiers", func(t *testing.T) {
+		_, err := store.GetRecord(ctx, "tiered")
+		require.NoError(t, err)
+
+		require.NoError(t, store.Delete(ctx, "tiered"))
+		_, err = store.GetRecord(ctx, "tiered")
+		assert.Equal(t, ErrNotFound, err)
+		_, err = cache.GetRecord(ctx, "tiered")
+		assert.Equal(t, ErrNotFound, err)
+		assert.Equal(t, ErrNotFound, store.Delete(ctx, "tiered"))
+	})
+}
+
+func TestSQLStore_Rebind(t *testing.T) {
+	query := "UPDATE links SET record = ? WHERE link_key = ?"
+	assert.Equal(t, query, NewSQLStore(nil, "sqlite3").rebind(query))
+	assert.Equal(t, "UPDATE links SET record = $1 WHERE link_key = $2", NewSQLStore(nil, "pgx").rebind(query))
+}
+
+// setupTestSQL opens a SQLStore over a fresh SQLite database
+func setupTestSQL(t *testing.T) *SQLStore {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "links.db"))
+	require.NoError(t, err)
+	store := NewSQLStore(db, "sqlite")
+	t.Cleanup(func() { store.Close() })
+	require.NoError(t, store.Migrate(context.Background()))
+	require.NoError(t, store.Migrate(context.Background()))
+	return store
+}
+
+func TestSQLStore(t *testing.T) {
+	store := setupTestSQL(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Create(ctx, "first", &Record{URL: "https://example.com/1", Owner: "alice", Tags: []string{"docs"}, CreatedAt: now.Add(-time.Hour)}))
+	require.NoError(t, store.Create(ctx, "second", &Record{URL: "https://example.com/2", Owner: "alice", Workspace: "acme", CreatedAt: now}))
+	require.NoError(t, store.Create(ctx, "third", &Record{URL: "https://example.com/3", Owner: "bob", CreatedAt: now}))
+	assert.Equal(t, ErrKeyExists, store.Create(ctx, "first", &Record{URL: "https://example.org", CreatedAt: now}))
+	assert.Error(t, store.Create(ctx, "empty", &Record{CreatedAt: now}))
+
+	rec, err := store.GetRecord(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1", rec.URL)
+	assert.Equal(t, []string{"docs"}, rec.Tags)
+	_, err = store.GetRecord(ctx, "missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Updating a link to the same record still finds it
+	rec.URL = "https://example.org/1"
+	require.NoError(t, store.Update(ctx, "first", rec))
+	require.NoError(t, store.Update(ctx, "first", rec))
+	rec, err = store.GetRecord(ctx, "first")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/1", rec.URL)
+	assert.Equal(t, ErrNotFound, store.Update(ctx, "missing", rec))
+
+	// Searches filter by owner and workspace in SQL, newest first
+	results, err := store.Search(ctx, SearchQuery{Owner: "alice"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "second", results[0].Key)
+	assert.Equal(t, "first", results[1].Key)
+	results, err = store.Search(ctx, SearchQuery{Owner: "alice", Workspace: "acme"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "second", results[0].Key)
+	results, err = store.Search(ctx, SearchQuery{Tag: "docs"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "first", results[0].Key)
+
+	var walked []string
+	require.NoError(t, store.Walk(ctx, func(r SearchResult) error {
+		walked = append(walked, r.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"first", "second", "third"}, walked)
+
+	require.NoError(t, store.Delete(ctx, "first"))
+	assert.Equal(t, ErrNotFound, store.Delete(ctx, "first"))
+	_, err = store.GetRecord(ctx, "first")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Multi-gets read every key in one query, keeping the order asked for
+	_, err = store.db.ExecContext(ctx, "INSERT INTO links (link_key, record, created_at) VALUES ('broken', '{', 0)")
+	require.NoError(t, err)
+	recs, errs, err := store.GetMulti(ctx, []string{"third", "first", "broken", "second"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/3", recs[0].URL)
+	assert.Nil(t, recs[1])
+	assert.Nil(t, recs[2])
+	assert.Error(t, errs[2])
+	assert.Equal(t, "https://example.com/2", recs[3].URL)
+	assert.NoError(t, errs[3])
+
+	// Conditional deletes leave links created at another time alone
+	assert.Equal(t, ErrLinkReplaced, store.DeleteIfCreated(ctx, "second", now.Add(-time.Hour)))
+	require.NoError(t, store.DeleteIfCreated(ctx, "second", now))
+	assert.Equal(t, ErrNotFound, store.DeleteIfCreated(ctx, "second", now))
+}
+
+func TestTieredStore_RedisDown(t *testing.T) {
+	// Nothing listens on port 1, so every cache command fails
+	cache := NewRedisStore("127.0.0.1:1", "", 0)
+	defer cache.Close()
+	durable := setupTestSQL(t)
+	store := NewTieredStore(durable, cache)
+	ctx := context.Background()
+
+	require.NoError(t, durable.Create(ctx, "durable", &Record{URL: "https://example.com", CreatedAt: time.Now().UTC()}))
+
+	url, err := store.Get(ctx, "durable")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", url)
+	_, err = store.GetRecord(ctx, "missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	recs, errs, err := store.GetMulti(ctx, []string{"missing", "durable"})
+	require.NoError(t, err)
+	assert.Nil(t, recs[0])
+	assert.Equal(t, "https://example.com", recs[1].URL)
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	rec, err := store.GetAndRefreshTTL(ctx, "durable")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", rec.URL)
+}
+
+func TestRedisStore_ExpiryPolicy(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	shorten := func(key string) {
+		require.NoError(t, store.client.Expire(ctx, key, time.Minute).Err())
+	}
+	ttlOf := func(key string) time.Duration {
+		ttl, err := store.client.TTL(ctx, key).Result()
+		require.NoError(t, err)
+		return ttl
+	}
+
+	require.NoError(t, store.Create(ctx, "slide", &Record{URL: "https://example.com"}))
+	require.NoError(t, store.Create(ctx, "fixed", &Record{URL: "https://example.com", Expiry: ExpiryAbsolute}))
+	shorten("slide")
+	shorten("fixed")
+
+	// Sliding links are extended on read, absolute ones keep their TTL
+	_, err := store.GetRecord(ctx, "slide")
+	require.NoError(t, err)
+	_, err = store.GetRecord(ctx, "fixed")
+	require.NoError(t, err)
+	assert.Greater(t, ttlOf("slide"), time.Minute)
+	assert.LessOrEqual(t, ttlOf("fixed"), time.Minute)
+
+	// The deployment default applies to links without their own policy
+	store.SetExpiryPolicy(ExpiryAbsolute)
+	shorten("slide")
+	_, err = store.GetRecord(ctx, "slide")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttlOf("slide"), time.Minute)
+
+	// Asynchronous accesses are written by the time the queue drains
+	store.SetExpiryPolicy(ExpirySliding)
+	store.EnableAsyncAccess(10)
+	_, err = store.GetRecord(ctx, "slide")
+	require.NoError(t, err)
+	require.NoError(t, store.ShutdownAccess(ctx))
+	assert.Greater(t, ttlOf("slide"), time.Minute)
+	score, err := store.client.ZScore(ctx, hotKeysKey, "slide").Result()
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), score)
+
+	_, err = ParseExpiryPolicy("forever")
+	assert.ErrorIs(t, err, ErrInvalidExpiryPolicy)
+}
+
+func TestRedisStore_Audit(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	start := time.Now().UTC()
+	entries := []*AuditEntry{
+		{Time: start, Actor: "alice", Action: AuditCreate, Key: "aud1", After: json.RawMessage(`{"url":"https://example.com"}`)},
+		{Time: start, Actor: "bob", Action: AuditCreate, Key: "aud2"},
+		{Time: start, Actor: "alice", Action: AuditDelete, Key: "aud1", Before: json.RawMessage(`{"url":"https://example.com"}`)},
+	}
+	require.NoError(t, store.AppendAudit(ctx, entries...))
+	for _, e := range entries {
+		assert.NotEmpty(t, e.ID)
+	}
+
+	// Entries are listed newest first
+	all, err := store.ListAudit(ctx, AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, AuditDelete, all[0].Action)
+	assert.JSONEq(t, `{"url":"https://example.com"}`, string(all[0].Before))
+	assert.Equal(t, entries[0].ID, all[2].ID)
+
+	byKey, err := store.ListAudit(ctx, AuditQuery{Key: "aud1", Action: AuditCreate})
+	require.NoError(t, err)
+	require.Len(t, byKey, 1)
+	assert.Equal(t, "alice", byKey[0].Actor)
+
+	byActor, err := store.ListAudit(ctx, AuditQuery{Actor: "bob"})
+	require.NoError(t, err)
+	require.Len(t, byActor, 1)
+	assert.Equal(t, "aud2", byActor[0].Key)
+
+	// Pages continue before the last entry returned
+	page, err := store.ListAudit(ctx, AuditQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	page, err = store.ListAudit(ctx, AuditQuery{Limit: 2, Before: page[1].ID})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, entries[0].ID, page[0].ID)
+
+	future, err := store.ListAudit(ctx, AuditQuery{Since: start.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, future)
+
+	_, err = store.ListAudit(ctx, AuditQuery{Before: "not-an-id"})
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestRedisStore_Visits(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	// Without visits enabled no visitor details are kept
+	require.NoError(t, store.Create(ctx, "visited", &Record{URL: "https://example.com"}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "visited", Time: time.Now(), IP: "192.0.2.0"}))
+	visits, err := store.GetVisits(ctx, "visited")
+	require.NoError(t, err)
+	assert.Empty(t, visits)
+
+	store.EnableVisits(VisitOptions{Limit: 2})
+	for _, ua := range []string{"first", "second", "third"} {
+		require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "visited", Time: time.Now(), IP: "192.0.2.0", UserAgent: ua, Country: "DE"}))
+	}
+
+	// Only the latest visits are kept, newest first
+	visits, err = store.GetVisits(ctx, "visited")
+	require.NoError(t, err)
+	require.Len(t, visits, 2)
+	assert.Equal(t, "third", visits[0].UserAgent)
+	assert.Equal(t, "second", visits[1].UserAgent)
+
+	n, err := store.AnonymizeVisits(ctx, "visited")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	visits, err = store.GetVisits(ctx, "visited")
+	require.NoError(t, err)
+	require.Len(t, visits, 2)
+	assert.Equal(t, Visit{Time: visits[0].Time, Country: "DE"}, visits[0])
+	ttl, err := store.client.TTL(ctx, visitsKey("visited")).Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	// Anonymized visits are left alone
+	n, err = store.AnonymizeVisits(ctx, "visited")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Visits go with their link
+	require.NoError(t, store.Delete(ctx, "visited"))
+	n, err = store.DeleteVisits(ctx, "visited")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRedisStore_Rollups(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	// Without rollups enabled clicks are only counted
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "roll", Time: time.Now()}))
+	n, err := store.Aggregate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	store.EnableRollups(RollupOptions{})
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	clicks := []analytics.Click{
+		{Key: "roll", Time: day.Add(9*time.Hour + 5*time.Minute), Country: "US", Referrer: "news.example"},
+		{Key: "roll", Time: day.Add(9*time.Hour + 50*time.Minute), Country: "DE"},
+		{Key: "roll", Time: day.Add(11 * time.Hour), Country: "US", Referrer: "news.example"},
+		{Key: "other", Time: day.Add(9 * time.Hour)},
+	}
+	for _, click := range clicks {
+		require.NoError(t, store.RecordClick(ctx, click))
+	}
+
+	n, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// Events are only aggregated once
+	n, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	hourly, err := store.GetRollups(ctx, "roll", GranularityHour, day.Add(9*time.Hour), day.Add(12*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, hourly.Buckets, 3)
+	assert.Equal(t, int64(3), hourly.Clicks)
+	assert.Equal(t, Bucket{
+		Start:     day.Add(9 * time.Hour),
+		Clicks:    2,
+		Countries: map[string]int64{"US": 1, "DE": 1},
+		Referrers: map[string]int64{"news.example": 1},
+	}, hourly.Buckets[0])
+	assert.Equal(t, Bucket{Start: day.Add(10 * time.Hour)}, hourly.Buckets[1])
+	assert.Equal(t, int64(1), hourly.Buckets[2].Clicks)
+	assert.Equal(t, map[string]int64{"US": 2, "DE": 1}, hourly.Countries)
+
+	daily, err := store.GetRollups(ctx, "roll", GranularityDay, day.Add(-time.Hour), day.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, daily.Buckets, 2)
+	assert.Zero(t, daily.Buckets[0].Clicks)
+	assert.Equal(t, int64(3), daily.Buckets[1].Clicks)
+	assert.Equal(t, map[string]int64{"news.example": 2}, daily.Referrers)
+
+	ttl, err := store.client.TTL(ctx, rollupKey("roll", GranularityHour, day.Add(9*time.Hour))).Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= DefaultHourlyRetention)
+
+	// Aggregated events past their retention are trimmed
+	store.rollups.RawRetention = time.Nanosecond
+	_, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	length, err := store.client.XLen(ctx, clickEventsKey).Result()
+	require.NoError(t, err)
+	assert.LessOrEqual(t, length, int64(1))
+
+	_, err = store.GetRollups(ctx, "roll", "week", day, day)
+	assert.Equal(t, ErrInvalidGranularity, err)
+}
+
+func TestRedisStore_Archive(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.EnableRollups(RollupOptions{RawRetention: time.Nanosecond})
+	store.EnableArchive()
+
+	at := time.Now().Truncate(time.Second)
+	require.NoError(t, store.Set(ctx, "archive1", "http://example.com"))
+	for _, country := range []string{"FR", "DE", "US"} {
+		require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "archive1", Time: at, Country: country, Device: "mobile"}))
+	}
+
+	token, err := store.ClaimArchive(ctx, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	other, err := store.ClaimArchive(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+
+	events, err := store.UnarchivedClickEvents(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, ClickEvent{ID: events[0].ID, Key: "archive1", Time: at.UTC(), Country: "FR", Device: "mobile"}, events[0])
+
+	// Aggregated events are kept until they are archived
+	_, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	length, err := store.client.XLen(ctx, clickEventsKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), length)
+
+	require.NoError(t, store.MarkArchived(ctx, events[1].ID))
+	events, err = store.UnarchivedClickEvents(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "US", events[0].Country)
+
+	// Events within the last millisecond are within retention
+	time.Sleep(2 * time.Millisecond)
+	_, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+	length, err = store.client.XLen(ctx, clickEventsKey).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	require.NoError(t, store.ReleaseArchive(ctx, token))
+	token, err = store.ClaimArchive(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
+
+func TestRedisStore_SchemaUpgrade(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.SetExpiryPolicy(ExpiryAbsolute)
+
+	require.NoError(t, store.client.Set(ctx, "schema01", `{"url":"https://example.com/one","label":"One"}`, time.Hour).Err())
+	require.NoError(t, store.client.Set(ctx, "schema02", `{"url":"https://example.com/two"}`, 0).Err())
+	require.NoError(t, store.client.Set(ctx, "schema03", "https://example.com/three", 0).Err())
+	require.NoError(t, store.Set(ctx, "schema04", "https://example.com/four"))
+
+	// Reading a record upgrades it in place, keeping its TTL
+	rec, err := store.GetRecord(ctx, "schema01")
+	require.NoError(t, err)
+	assert.Equal(t, "One", rec.Label)
+	value, err := store.client.Get(ctx, "schema01").Result()
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, recordSchema(value))
+	ttl, err := store.client.TTL(ctx, "schema01").Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Hour, ttl)
+
+	// Records changed since they were read aren't overwritten
+	ok, err := store.upgradeRecord(ctx, "schema02", `{"url":"https://example.com/stale"}`, &Record{URL: "https://example.com/stale"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The migrator upgrades the rest, including bare URLs
+	n, err := store.MigrateRecords(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	rec, err = store.GetRecord(ctx, "schema03")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/three", rec.URL)
+	n, err = store.MigrateRecords(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRedisStore_LiveClicks(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.EnableLiveClicks()
+
+	sub, err := store.SubscribeClicks(ctx, "live")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "other", Time: now}))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "live", Time: now, Country: "US"}))
+
+	select {
+	case click := <-sub.C:
+		assert.Equal(t, "live", click.Key)
+		assert.Equal(t, "US", click.Country)
+		assert.True(t, now.Equal(click.Time))
+	case <-time.After(2 * time.Second):
+		t.Fatal("click was not delivered")
+	}
+
+	require.NoError(t, sub.Close())
+	assert.Eventually(t, func() bool {
+		_, ok := <-sub.C
+		return !ok
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRedisStore_TopLinks(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	require.NoError(t, store.Create(ctx, "hot", &Record{URL: "https://example.com/hot"}))
+	require.NoError(t, store.Create(ctx, "warm", &Record{URL: "https://example.com/warm"}))
+	clicks := map[string][]time.Time{
+		"hot":  {now, now, now.Add(-2 * time.Hour)},
+		"warm": {now, now.Add(-30 * time.Hour)},
+		"gone": {now},
+	}
+	for key, times := range clicks {
+		for _, at := range times {
+			require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: key, Time: at}))
+		}
+	}
+
+	top, err := store.TopLinks(ctx, 24*time.Hour, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), top.Clicks)
+	assert.Equal(t, int64(3), top.ClickedLinks)
+	assert.Equal(t, []TopLink{
+		{Key: "hot", URL: "https://example.com/hot", Clicks: 3},
+		{Key: "warm", URL: "https://example.com/warm", Clicks: 1},
+	}, top.Links)
+	assert.Equal(t, now.UTC().Truncate(time.Hour).Add(-23*time.Hour), top.Since)
+
+	top, err = store.TopLinks(ctx, 48*time.Hour, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), top.Clicks)
+	require.Len(t, top.Links, 1)
+	assert.Equal(t, "hot", top.Links[0].Key)
+
+	_, err = store.TopLinks(ctx, 30*time.Minute, 10)
+	assert.Equal(t, ErrInvalidWindow, err)
+}
+
+func TestRedisStore_WorkerLease(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	// Distinct instances claim distinct IDs until none are left
+	a, err := store.ClaimWorkerID(ctx, 1, time.Minute)
+	require.NoError(t, err)
+	b, err := store.ClaimWorkerID(ctx, 1, time.Minute)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{0, 1}, []int{a.ID, b.ID})
+	_, err = store.ClaimWorkerID(ctx, 1, time.Minute)
+	assert.Equal(t, ErrNoWorkerID, err)
+
+	ok, err := a.Renew(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A lapsed lease is taken back on renewal if nobody claimed it
+	require.NoError(t, store.client.Del(ctx, a.key()).Err())
+	ok, err = a.Renew(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Released IDs can be claimed again, and the old holder can't renew
+	require.NoError(t, a.Release(ctx))
+	c, err := store.ClaimWorkerID(ctx, 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, a.ID, c.ID)
+	ok, err = a.Renew(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRedisStore_Usage(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	next := day.AddDate(0, 0, 1)
+	require.NoError(t, store.AddUsage(ctx, []Usage{
+		{Day: day, Workspace: "marketing", Metric: "redirects", Quantity: 3},
+		{Day: day, Owner: "auth0|alice", Metric: "redirects", Quantity: 2},
+	}, true))
+	require.NoError(t, store.AddUsage(ctx, []Usage{
+		{Day: day, Workspace: "marketing", Metric: "redirects", Quantity: 4},
+		{Day: next, Workspace: "marketing", Metric: "links_created", Quantity: 1},
+	}, false))
+
+	usage, err := store.GetUsage(ctx, day, next)
+	require.NoError(t, err)
+	assert.Equal(t, []Usage{
+		{Day: day, Owner: "auth0|alice", Metric: "redirects", Quantity: 2},
+		{Day: day, Workspace: "marketing", Metric: "redirects", Quantity: 7},
+		{Day: next, Workspace: "marketing", Metric: "links_created", Quantity: 1},
+	}, usage)
+
+	// Only queued usage awaits metering, and it is taken again until acknowledged
+	pending, err := store.TakePendingUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Usage{
+		{Day: day, Owner: "auth0|alice", Metric: "redirects", Quantity: 2},
+		{Day: day, Workspace: "marketing", Metric: "redirects", Quantity: 3},
+	}, pending)
+
+	require.NoError(t, store.AddUsage(ctx, []Usage{{Day: next, Workspace: "marketing", Metric: "redirects", Quantity: 5}}, true))
+	again, err := store.TakePendingUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, pending, again)
+
+	require.NoError(t, store.AckPendingUsage(ctx))
+	pending, err = store.TakePendingUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Usage{{Day: next, Workspace: "marketing", Metric: "redirects", Quantity: 5}}, pending)
+
+	require.NoError(t, store.AckPendingUsage(ctx))
+	pending, err = store.TakePendingUsage(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRedisStore_Replica(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	// Database 1 stands in for the replica, which the test fills by hand
+	store.EnableReplica("localhost:6379", "", 1, ReplicaOptions{MaxLag: time.Minute})
+	r := store.replica
+	require.NoError(t, r.client.FlushDB(ctx).Err())
+	replicate := func(key, url string) {
+		value, err := encodeRecord(&Record{URL: url})
+		require.NoError(t, err)
+		require.NoError(t, r.client.Set(ctx, key, value, 0).Err())
+	}
+
+	require.NoError(t, store.Create(ctx, "abc", &Record{URL: "https://example.com/primary"}))
+	require.NoError(t, store.Create(ctx, "def", &Record{URL: "https://example.com/fresh"}))
+	replicate("abc", "https://example.com/replica")
+
+	// The replica isn't read before its lag is known
+	rec, err := store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/primary", rec.URL)
+
+	now := time.Now()
+	require.NoError(t, store.probeReplica(ctx, now, now))
+	_, caughtUp := store.ReplicaLag()
+	require.True(t, caughtUp)
+	rec, err = store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/replica", rec.URL)
+
+	// Keys missing from the replica haven't replicated yet
+	rec, err = store.GetRecord(ctx, "def")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/fresh", rec.URL)
+
+	// Changed keys are read from the primary until the change replicates
+	require.NoError(t, store.Update(ctx, "abc", &Record{URL: "https://example.com/updated"}))
+	rec, err = store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/updated", rec.URL)
+	r.written["abc"] = now.Add(-2 * time.Minute)
+	rec, err = store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/replica", rec.URL)
+
+	// A lagging replica isn't read at all
+	require.NoError(t, r.client.Set(ctx, r.heartbeatKey, now.Add(-2*time.Minute).UnixNano(), 0).Err())
+	require.NoError(t, store.probeReplica(ctx, now, now))
+	lag, caughtUp := store.ReplicaLag()
+	assert.False(t, caughtUp)
+	assert.GreaterOrEqual(t, lag, 2*time.Minute)
+	assert.Empty(t, r.written)
+	rec, err = store.GetRecord(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/updated", rec.URL)
+}
+
+// flakyStore fails every call with a connection error while down is set
+type flakyStore struct {
+	*RedisStore
+	down  bool
+	calls int
+}
+
+func (f *flakyStore) GetRecord(ctx context.Context, key string) (*Record, error) {
+	f.calls++
+	if f.down {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+	return f.RedisStore.GetRecord(ctx, key)
+}
+
+func TestBreakerStore(t *testing.T) {
+	flaky := &flakyStore{RedisStore: setupTestRedis(t)}
+	store := NewBreakerStore(flaky, BreakerOptions{Failures: 3, Cooldown: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "stale", &Record{URL: "https://example.com/stale", CreatedAt: time.Now().UTC()}))
+	_, err := store.GetRecord(ctx, "stale")
+	require.NoError(t, err)
+
+	// A failed read is retried, and keys read before are served stale
+	flaky.down = true
+	rec, err := store.GetRecord(ctx, "stale")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/stale", rec.URL)
+	assert.Equal(t, 3, flaky.calls)
+
+	_, err = store.GetRecord(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrUnavailable, "the third failure opens the circuit")
+	open, _ := store.Open()
+	assert.True(t, open)
+
+	// While open, calls fail fast without reaching the store
+	calls := flaky.calls
+	_, err = store.GetRecord(ctx, "unknown")
+	assert.Equal(t, ErrUnavailable, err)
+	assert.Equal(t, calls, flaky.calls)
+
+	// A failed trial after the cooldown opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.GetRecord(ctx, "unknown")
+	assert.Error(t, err)
+	open, _ = store.Open()
+	assert.True(t, open)
+
+	// A successful trial closes it
+	flaky.down = false
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.GetRecord(ctx, "unknown")
+	assert.Equal(t, ErrNotFound, err)
+	open, _ = store.Open()
+	assert.False(t, open)
+}
+
+func TestRedisStore_NegativeCache(t *testing.T) {
+	store := setupTestRedis(t)
+	store.EnableNegativeCache(10, time.Minute)
+	ctx := context.Background()
+
+	_, err := store.GetRecord(ctx, "unknown")
+	require.Equal(t, ErrNotFound, err)
+
+	// A key created elsewhere is still reported missing until the entry expires
+	require.NoError(t, store.client.Set(ctx, "unknown", "https://example.com", 0).Err())
+	_, err = store.GetRecord(ctx, "unknown")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Creating the key through the store forgets it at once
+	assert.Equal(t, ErrKeyExists, store.Set(ctx, "unknown", "https://example.com"))
+	rec, err := store.GetRecord(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", rec.URL)
+
+	_, err = store.GetRecord(ctx, "later")
+	require.Equal(t, ErrNotFound, err)
+	require.NoError(t, store.Set(ctx, "later", "https://example.com/later"))
+	_, err = store.GetRecord(ctx, "later")
+	assert.NoError(t, err)
+
+	_, err = store.GetRecord(ctx, "bulk")
+	require.Equal(t, ErrNotFound, err)
+	errs, err := store.CreateMany(ctx, []BulkRecord{{Key: "bulk", Record: &Record{URL: "https://example.com"}}})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	_, err = store.GetRecord(ctx, "bulk")
+	assert.NoError(t, err)
+}
+
+func TestRedisStore_Notifications(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	settings, err := store.GetWorkspaceNotifications(ctx, "acme")
+	require.NoError(t, err)
+	assert.Nil(t, settings)
+
+	want := &Notifications{Channels: map[string][]string{"email": {"ops@acme.com"}}, Milestones: []int64{100}}
+	require.NoError(t, store.SetWorkspaceNotifications(ctx, "acme", want))
+	settings, err = store.GetWorkspaceNotifications(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, want, settings)
+
+	require.NoError(t, store.SetWorkspaceNotifications(ctx, "acme", nil))
+	settings, err = store.GetWorkspaceNotifications(ctx, "acme")
+	require.NoError(t, err)
+	assert.Nil(t, settings)
+
+	// Milestones are claimed once per measurement period
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "abc12345"}))
+	claimed, err := store.ClaimMilestone(ctx, "abc12345", 1)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimMilestone(ctx, "abc12345", 1)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	stats, err := store.GetStats(ctx, "abc12345")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Clicks)
+	assert.Empty(t, stats.Variants)
+
+	_, err = store.ResetStats(ctx, "abc12345")
+	require.NoError(t, err)
+	claimed, err = store.ClaimMilestone(ctx, "abc12345", 1)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestRedisStore_Expiring(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	store.SetTTL(time.Hour)
+	assert.Equal(t, time.Hour, store.TTL())
+	require.NoError(t, store.Create(ctx, "abc12345", &Record{URL: "https://example.com"}))
+	require.NoError(t, store.Create(ctx, "xyz67890", &Record{URL: "https://example.org"}))
+	require.NoError(t, store.Extend(ctx, "xyz67890", time.Now().Add(30*time.Minute)))
+
+	results, err := store.Expiring(ctx, time.Now().Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "xyz67890", results[0].Key, "soonest first")
+	assert.Equal(t, "https://example.org", results[0].Record.URL)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), *results[0].ExpiresAt, 2*time.Second)
+
+	results, err = store.Expiring(ctx, time.Now().Add(45*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "xyz67890", results[0].Key)
+
+	// Reminders are claimed once per expiry, and extending resets them
+	claimed, err := store.ClaimExpiryReminder(ctx, "xyz67890", *results[0].ExpiresAt)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimExpiryReminder(ctx, "xyz67890", *results[0].ExpiresAt)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	require.NoError(t, store.Extend(ctx, "xyz67890", time.Now().Add(40*time.Minute)))
+	claimed, err = store.ClaimExpiryReminder(ctx, "xyz67890", time.Now().Add(40*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Deleted links and links that no longer expire drop out of the index
+	require.NoError(t, store.Delete(ctx, "xyz67890"))
+	require.NoError(t, store.client.Persist(ctx, "abc12345").Err())
+	results, err = store.Expiring(ctx, time.Now().Add(2*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Zero(t, store.client.ZCard(ctx, expiryIndexKey).Val())
+
+	assert.Equal(t, ErrNotFound, store.Extend(ctx, "missing1", time.Now().Add(time.Minute)))
+}
+
+func TestRollups_Regroup(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+
+	// Hours either side of midnight in Paris, on a Sunday and a Monday
+	start := time.Date(2026, 10, 11, 20, 0, 0, 0, time.UTC)
+	hourly := &Rollups{Granularity: GranularityHour, Clicks: 6}
+	for i := 0; i < 6; i++ {
+		hourly.Buckets = append(hourly.Buckets, Bucket{Start: start.Add(time.Duration(i) * time.Hour), Clicks: 1, Countries: map[string]int64{"FR": 1}})
+	}
+
+	days := hourly.Regroup(GranularityDay, paris)
+	assert.Equal(t, GranularityDay, days.Granularity)
+	assert.Equal(t, int64(6), days.Clicks)
+	require.Len(t, days.Buckets, 2)
+	assert.Equal(t, "2026-10-11T00:00:00+02:00", days.Buckets[0].Start.Format(time.RFC3339))
+	assert.Equal(t, int64(2), days.Buckets[0].Clicks)
+	assert.Equal(t, "2026-10-12T00:00:00+02:00", days.Buckets[1].Start.Format(time.RFC3339))
+	assert.Equal(t, int64(4), days.Buckets[1].Clicks)
+	assert.Equal(t, map[string]int64{"FR": 4}, days.Buckets[1].Countries)
+
+	weeks := hourly.Regroup(GranularityWeek, paris)
+	require.Len(t, weeks.Buckets, 2)
+	assert.Equal(t, "2026-10-05T00:00:00+02:00", weeks.Buckets[0].Start.Format(time.RFC3339))
+	assert.Equal(t, "2026-10-12T00:00:00+02:00", weeks.Buckets[1].Start.Format(time.RFC3339))
+
+	assert.Len(t, hourly.Regroup(GranularityHour, paris).Buckets, 6)
+	assert.Equal(t, time.Date(2026, 10, 19, 0, 0, 0, 0, paris), GranularityWeek.Next(weeks.Buckets[1].Start))
+}
+
+func TestRedisStore_ReadOnly(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	enabled, err := store.ReadOnly(ctx)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	require.NoError(t, store.SetReadOnly(ctx, true))
+	enabled, err = store.ReadOnly(ctx)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	require.NoError(t, store.SetReadOnly(ctx, false))
+	enabled, err = store.ReadOnly(ctx)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestRedisStore_Locks(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	token, err := store.AcquireLock(ctx, "rollups", time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	other, err := store.AcquireLock(ctx, "rollups", time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+
+	ok, err := store.RenewLock(ctx, "rollups", token, 2*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ttl, err := store.client.PTTL(ctx, lockKeyPrefix+"rollups").Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Minute)
+	ok, err = store.RenewLock(ctx, "rollups", "not-the-holder", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Only the holder releases the lock
+	require.NoError(t, store.ReleaseLock(ctx, "rollups", "not-the-holder"))
+	other, err = store.AcquireLock(ctx, "rollups", time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+	require.NoError(t, store.ReleaseLock(ctx, "rollups", token))
+	other, err = store.AcquireLock(ctx, "rollups", time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, other)
+}
+
+func TestRedisStore_JobRuns(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < MaxJobRuns+5; i++ {
+		require.NoError(t, store.RecordJobRun(ctx, &JobRun{
+			Job:        "rollups",
+			Trigger:    JobScheduled,
+			StartedAt:  start.Add(time.Duration(i) * time.Minute),
+			FinishedAt: start.Add(time.Duration(i) * time.Minute),
+			Processed:  i,
+		}))
+	}
+
+	runs, err := store.JobRuns(ctx, "rollups", 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, MaxJobRuns+4, runs[0].Processed)
+	assert.Equal(t, MaxJobRuns+3, runs[1].Processed)
+
+	// Only the latest runs are kept
+	runs, err = store.JobRuns(ctx, "rollups", MaxJobRuns*2)
+	require.NoError(t, err)
+	assert.Len(t, runs, MaxJobRuns)
+
+	runs, err = store.JobRuns(ctx, "link-rot", 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestRedisStore_Rename(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.EnableRollups(RollupOptions{})
+
+	require.NoError(t, store.Create(ctx, "summer-sale", &Record{URL: "https://example.com/sale", Tags: []string{"promo"}, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, store.Set(ctx, "taken", "https://example.com/taken"))
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "summer-sale", Time: time.Now()}))
+	_, err := store.ResetStats(ctx, "summer-sale")
+	require.NoError(t, err)
+	require.NoError(t, store.RecordClick(ctx, analytics.Click{Key: "summer-sale", Time: time.Now()}))
+	_, err = store.Aggregate(ctx)
+	require.NoError(t, err)
+
+	_, err = store.Rename(ctx, "summer-sale", "taken", time.Hour)
+	assert.Equal(t, ErrKeyExists, err)
+	_, err = store.Rename(ctx, "missing", "free", time.Hour)
+	assert.Equal(t, ErrNotFound, err)
+
+	rec, err := store.Rename(ctx, "summer-sale", "autumn-sale", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, rec.Aliases, 1)
+	assert.Equal(t, "summer-sale", rec.Aliases[0].Key)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), rec.Aliases[0].Until, time.Minute)
+
+	_, err = store.GetRecord(ctx, "summer-sale")
+	assert.Equal(t, ErrNotFound, err)
+	moved, err := store.GetRecord(ctx, "autumn-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sale", moved.URL)
+	newKey, err := store.ResolveAlias(ctx, "summer-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "autumn-sale", newKey)
+	_, err = store.ResolveAlias(ctx, "autumn-sale")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Click counters, archived periods, rollups and indexes follow the
+	// link, leaving nothing for a link created at the former key
+	stats, err := store.GetStats(ctx, "autumn-sale")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Clicks)
+	assert.Equal(t, 2, stats.Period)
+	archived, err := store.GetArchivedStats(ctx, "autumn-sale")
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, int64(1), archived[0].Clicks)
+	now := time.Now()
+	rollups, err := store.GetRollups(ctx, "autumn-sale", GranularityDay, now.Add(-24*time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rollups.Clicks)
+	rollups, err = store.GetRollups(ctx, "summer-sale", GranularityHour, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), rollups.Clicks)
+	assert.Zero(t, store.client.Exists(ctx, statsKeyPrefix+"summer-sale", statsKeyPrefix+"summer-sale"+periodsSuffix, statsKeyPrefix+"summer-sale"+periodSuffix+"1", visitsKey("summer-sale")).Val())
+	results, err := store.Search(ctx, SearchQuery{Tag: "promo"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "autumn-sale", results[0].Key)
+
+	ttl, err := store.client.PTTL(ctx, aliasKeyPrefix+"summer-sale").Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 59*time.Minute)
+}
+
+func TestRedisStore_Consume(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "once", &Record{URL: "https://example.com/once", SingleUse: true, CreatedAt: time.Now().UTC()}))
+
+	// Of concurrent consumers, exactly one gets the link
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var got, missed int
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec, err := store.Consume(ctx, "once")
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				assert.Equal(t, "https://example.com/once", rec.URL)
+				got++
+			case ErrNotFound:
+				missed++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, got)
+	assert.Equal(t, 19, missed)
+
+	_, err := store.GetRecord(ctx, "once")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRedisStore_LandingPages(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "taken", "https://example.com/taken"))
+	assert.Equal(t, ErrKeyExists, store.CreateLandingPage(ctx, &LandingPage{Key: "taken", Title: "Taken"}))
+
+	page := &LandingPage{Key: "jane", Title: "Jane", Links: []LandingLink{{Title: "Blog", URL: "https://example.com/blog"}}}
+	require.NoError(t, store.CreateLandingPage(ctx, page))
+	assert.Equal(t, ErrKeyExists, store.CreateLandingPage(ctx, page))
+
+	// Pages aren't resolved as links, and hold their key against them
+	_, err := store.GetRecord(ctx, "jane")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrKeyExists, store.Create(ctx, "jane", &Record{URL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	errs, err := store.CreateMany(ctx, []BulkRecord{{Key: "jane", Record: &Record{URL: "https://example.com", CreatedAt: time.Now().UTC()}}})
+	require.NoError(t, err)
+	assert.Equal(t, []error{ErrKeyExists}, errs)
+	_, err = store.GetRecord(ctx, "jane")
+	assert.Equal(t, ErrNotFound, err)
+
+	// Pages expire like links, keeping their TTL through edits and
+	// refreshing it while they are served
+	ttl, err := store.client.PTTL(ctx, landingPageKeyPrefix+"jane").Result()
+	require.NoError(t, err)
+	assert.InDelta(t, DefaultTTL, ttl, float64(time.Minute))
+	require.NoError(t, store.client.PExpire(ctx, landingPageKeyPrefix+"jane", time.Minute).Err())
+
+	page.Title = "Jane Doe"
+	require.NoError(t, store.UpdateLandingPage(ctx, page))
+	ttl, err = store.client.PTTL(ctx, landingPageKeyPrefix+"jane").Result()
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttl, time.Minute)
+
+	got, err := store.VisitLandingPage(ctx, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", got.Title)
+	assert.Equal(t, page.Links, got.Links)
+	ttl, err = store.client.PTTL(ctx, landingPageKeyPrefix+"jane").Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Minute)
+	_, err = store.VisitLandingPage(ctx, "missing")
+	assert.Equal(t, ErrLandingPageNotFound, err)
+
+	require.NoError(t, store.DeleteLandingPage(ctx, "jane"))
+	_, err = store.GetLandingPage(ctx, "jane")
+	assert.Equal(t, ErrLandingPageNotFound, err)
+	assert.Equal(t, ErrLandingPageNotFound, store.UpdateLandingPage(ctx, page))
+	assert.Equal(t, ErrLandingPageNotFound, store.DeleteLandingPage(ctx, "jane"))
+}
+
+func TestRedisStore_ApplyAccess(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "slide", &Record{URL: "https://example.com"}))
+	require.NoError(t, store.client.Expire(ctx, "slide", time.Minute).Err())
+
+	// Deferred accesses slide the TTL like redirects do
+	require.NoError(t, store.applyAccess(ctx, access{key: "slide", hits: 2, sliding: true}))
+	assert.Greater(t, store.client.TTL(ctx, "slide").Val(), time.Minute)
+	assert.Equal(t, float64(2), store.client.ZScore(ctx, hotKeysKey, "slide").Val())
+
+	// A link deleted since it was read isn't tracked again
+	require.NoError(t, store.Delete(ctx, "slide"))
+	require.NoError(t, store.client.ZRem(ctx, expiryIndexKey, "slide").Err())
+	require.NoError(t, store.applyAccess(ctx, access{key: "slide", hits: 1, sliding: true}))
+	assert.Equal(t, int64(0), store.client.Exists(ctx, "slide").Val())
+	assert.Equal(t, redis.Nil, store.client.ZScore(ctx, expiryIndexKey, "slide").Err())
+	assert.Equal(t, redis.Nil, store.client.ZScore(ctx, hotKeysKey, "slide").Err())
+}
+
+func TestRedisStore_GetAndRefreshTTL(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "slide", &Record{URL: "https://example.com"}))
+	require.NoError(t, store.Create(ctx, "fixed", &Record{URL: "https://example.com", Expiry: ExpiryAbsolute}))
+	require.NoError(t, store.client.Expire(ctx, "slide", time.Minute).Err())
+	require.NoError(t, store.client.Expire(ctx, "fixed", time.Minute).Err())
+
+	rec, err := store.GetAndRefreshTTL(ctx, "slide")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", rec.URL)
+	assert.Greater(t, store.client.TTL(ctx, "slide").Val(), time.Minute)
+
+	_, err = store.GetAndRefreshTTL(ctx, "fixed")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, store.client.TTL(ctx, "fixed").Val(), time.Minute)
+	assert.Equal(t, float64(1), store.client.ZScore(ctx, hotKeysKey, "fixed").Val())
+
+	// A deleted link isn't tracked again
+	require.NoError(t, store.Delete(ctx, "slide"))
+	_, err = store.GetAndRefreshTTL(ctx, "slide")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, redis.Nil, store.client.ZScore(ctx, expiryIndexKey, "slide").Err())
+}
+
+func TestRedisStore_GetMulti(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "one", &Record{URL: "https://example.com/1"}))
+	require.NoError(t, store.Create(ctx, "two", &Record{URL: "https://example.com/2"}))
+
+	require.NoError(t, store.client.Set(ctx, "broken", "{", 0).Err())
+
+	recs, errs, err := store.GetMulti(ctx, []string{"two", "missing", "broken", "one"})
+	require.NoError(t, err)
+	require.Len(t, recs, 4)
+	assert.Equal(t, "https://example.com/2", recs[0].URL)
+	assert.Nil(t, recs[1])
+	assert.NoError(t, errs[1])
+
+	// An undecodable record only fails its own key
+	assert.Nil(t, recs[2])
+	assert.Error(t, errs[2])
+	assert.Equal(t, "https://example.com/1", recs[3].URL)
+
+	// Bulk reads aren't visits
+	assert.Equal(t, redis.Nil, store.client.ZScore(ctx, hotKeysKey, "one").Err())
+
+	recs, _, err = store.GetMulti(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, recs)
+}
+
+func TestRedisStore_ClickLimits(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "limited", &Record{URL: "https://example.com", MaxClicks: 5, Tags: []string{"promo"}}))
+
+	// Of concurrent claims, exactly the limit succeed
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var claimed, refused int
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.ClaimClick(ctx, "limited", 5)
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				claimed++
+			case ErrClickLimitReached:
+				refused++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, claimed)
+	assert.Equal(t, 15, refused)
+
+	n, err := store.ClaimedClicks(ctx, "limited")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, ErrClickLimitReached, store.CheckClickLimit(ctx, "limited", 5))
+	assert.NoError(t, store.CheckClickLimit(ctx, "limited", 6))
+
+	// The counter outlives the link's current expiry, which extending the
+	// link would push out
+	assert.Equal(t, time.Duration(-1), store.client.TTL(ctx, claimedClicksKey("limited")).Val())
+
+	// Deleting the link drops its counter and indexes, and a new link
+	// under the key starts over
+	require.NoError(t, store.Delete(ctx, "limited"))
+	assert.Equal(t, ErrNotFound, store.ClaimClick(ctx, "limited", 5))
+	assert.Equal(t, int64(0), store.client.Exists(ctx, claimedClicksKey("limited")).Val())
+	assert.False(t, store.client.SIsMember(ctx, tagKeyPrefix+"promo", "limited").Val())
+	assert.Equal(t, redis.Nil, store.client.ZScore(ctx, expiryIndexKey, "limited").Err())
+
+	require.NoError(t, store.client.Set(ctx, claimedClicksKey("limited"), 5, 0).Err())
+	require.NoError(t, store.Create(ctx, "limited", &Record{URL: "https://example.com", MaxClicks: 5, Tags: []string{"promo"}}))
+	assert.NoError(t, store.CheckClickLimit(ctx, "limited", 5))
+	assert.True(t, store.client.SIsMember(ctx, tagKeyPrefix+"promo", "limited").Val())
+	assert.NoError(t, store.client.ZScore(ctx, expiryIndexKey, "limited").Err())
+}
+
+func TestRedisStore_ActivationOutlivesTTL(t *testing.T) {
+	store := setupTestRedis(t)
+	defer store.Close()
+	ctx := context.Background()
+	store.SetTTL(time.Hour)
+
+	// A link activating after the TTL lives the TTL past its activation,
+	// and reads before then don't shorten it
+	from := time.Now().Add(48 * time.Hour).UTC()
+	require.NoError(t, store.Create(ctx, "later", &Record{URL: "https://example.com", ActiveFrom: &from}))
+	_, err := store.GetRecord(ctx, "later")
+	require.NoError(t, err)
+	assert.Greater(t, store.client.TTL(ctx, "later").Val(), 48*time.Hour)
+	score, err := store.client.ZScore(ctx, expiryIndexKey, "later").Result()
+	require.NoError(t, err)
+	assert.Greater(t, score, float64(from.UnixMilli()))
+
+	// Moving a link's activation past its expiry extends it
+	require.NoError(t, store.Create(ctx, "moved", &Record{URL: "https://example.com"}))
+	rec, err := store.GetRecord(ctx, "moved")
+	require.NoError(t, err)
+	rec.ActiveFrom = &from
+	require.NoError(t, store.Update(ctx, "moved", rec))
+	assert.Greater(t, store.client.TTL(ctx, "moved").Val(), 48*time.Hour)
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"expvar"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultReplicaMaxLag is the default replication lag past which links
+	// are read from the primary
+	DefaultReplicaMaxLag = 2 * time.Second
+
+	// DefaultReplicaHeartbeat is the default time between lag probes
+	DefaultReplicaHeartbeat = time.Second
+
+	// heartbeatKeyPrefix prefixes the heartbeat each instance writes to the
+	// primary and reads back from its replica
+	heartbeatKeyPrefix = "replication:heartbeat:"
+
+	// heartbeatTTL keeps the heartbeats of stopped instances from piling up
+	heartbeatTTL = time.Hour
+)
+
+// replicaStats counts where links were read from and tracks the replica's
+// lag, published at /debug/vars
+var replicaStats = expvar.NewMap("redis_replica")
+
+// ReplicaOptions sets when a read replica is trusted. Zero values keep the
+// defaults.
+type ReplicaOptions struct {
+	// MaxLag is the replication lag past which every read goes to the
+	// primary. Keys changed through this store are also read from the
+	// primary for MaxLag afterwards, so callers see their own writes.
+	MaxLag time.Duration
+
+	// Heartbeat is the time between lag probes
+	Heartbeat time.Duration
+}
+
+// replica is a read replica of the primary, typically in the serving region
+type replica struct {
+	client       *redis.Client
+	heartbeatKey string
+	maxLag       time.Duration
+	interval     time.Duration
+
+	// lag is the last measured replication lag in nanoseconds, and caughtUp
+	// whether it was within maxLag
+	lag      atomic.Int64
+	caughtUp atomic.Bool
+
+	mu      sync.Mutex
+	written map[string]time.Time
+}
+
+// EnableReplica reads links from a read replica of the primary, such as one
+// in the region serving redirects, while every write still goes to the
+// primary. Reads fall back to the primary while the replica lags by more
+// than MaxLag or fails, for keys it doesn't hold yet, and for keys recently
+// changed through this store. The replica is only read once MonitorReplica
+// has measured its lag.
+func (s *RedisStore) EnableReplica(addr, password string, db int, opts ReplicaOptions) {
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = DefaultReplicaMaxLag
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = DefaultReplicaHeartbeat
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	s.replica = &replica{
+		client:       redis.NewClient(&redis.Options{Addr: addr, Password: password, DB: db, ContextTimeoutEnabled: true}),
+		heartbeatKey: heartbeatKeyPrefix + hex.EncodeToString(id),
+		maxLag:       opts.MaxLag,
+		interval:     opts.Heartbeat,
+		written:      make(map[string]time.Time),
+	}
+	replicaStats.Set("lag_ms", expvar.Func(func() any {
+		lag, _ := s.ReplicaLag()
+		return lag.Milliseconds()
+	}))
+}
+
+// MonitorReplica measures the replica's lag every heartbeat until ctx is
+// done. Each probe writes the current time to the primary and reads back
+// the latest time the replica has received, so lag is measured against this
+// instance's own clock.
+func (s *RedisStore) MonitorReplica(ctx context.Context) {
+	if s.replica == nil {
+		return
+	}
+	ticker := time.NewTicker(s.replica.interval)
+	defer ticker.Stop()
+
+	var started time.Time
+	for {
+		now := time.Now()
+		if started.IsZero() {
+			started = now
+		}
+		if err := s.probeReplica(ctx, now, started); err != nil && ctx.Err() == nil {
+			log.Printf("replica lag probe failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeReplica writes a heartbeat to the primary and updates the lag from
+// the heartbeat the replica holds. A replica without any heartbeat yet lags
+// by the time since probing started.
+func (s *RedisStore) probeReplica(ctx context.Context, now, started time.Time) error {
+	r := s.replica
+	r.prune(now)
+	if err := s.client.Set(ctx, r.heartbeatKey, now.UnixNano(), heartbeatTTL).Err(); err != nil {
+		return err
+	}
+
+	seen := started
+	value, err := r.client.Get(ctx, r.heartbeatKey).Result()
+	if err != nil && err != redis.Nil {
+		r.caughtUp.Store(false)
+		return err
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		seen = time.Unix(0, n)
+	}
+
+	lag := max(time.Since(seen), 0)
+	r.lag.Store(int64(lag))
+	r.caughtUp.Store(lag <= r.maxLag)
+	return nil
+}
+
+// ReplicaLag returns the last measured replication lag, and whether the
+// replica is caught up enough to be read
+func (s *RedisStore) ReplicaLag() (time.Duration, bool) {
+	if s.replica == nil {
+		return 0, false
+	}
+	return time.Duration(s.replica.lag.Load()), s.replica.caughtUp.Load()
+}
+
+// readValue reads the encoded record of a key, from the replica when it can
+// be trusted with the key
+func (s *RedisStore) readValue(ctx context.Context, key string) (string, error) {
+	if r := s.replica; r != nil && r.caughtUp.Load() && !r.recentlyWritten(key, time.Now()) {
+		value, err := r.client.Get(ctx, key).Result()
+		if err == nil {
+			replicaStats.Add("replica_reads", 1)
+			return value, nil
+		}
+		// The key may not have replicated yet, or the replica may be down
+		replicaStats.Add("primary_fallbacks", 1)
+	}
+	return s.client.Get(ctx, key).Result()
+}
+
+// markWritten sends reads of a changed key to the primary until the change
+// has had time to replicate
+func (s *RedisStore) markWritten(key string) {
+	if r := s.replica; r != nil {
+		r.mu.Lock()
+		r.written[key] = time.Now()
+		r.mu.Unlock()
+	}
+}
+
+// recentlyWritten reports whether a key was changed less than maxLag ago
+func (r *replica) recentlyWritten(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.written[key]
+	return ok && now.Sub(at) < r.maxLag
+}
+
+// prune forgets keys changed long enough ago to have replicated
+func (r *replica) prune(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, at := range r.written {
+		if now.Sub(at) >= r.maxLag {
+			delete(r.written, key)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// clickEventsKey is the stream of raw click events awaiting aggregation
+	clickEventsKey = "events:clicks"
+
+	// rollupCursorKey holds the ID of the last aggregated click event
+	rollupCursorKey = "rollup:cursor"
+
+	// rollupKeyPrefix prefixes the hash holding each key's aggregates for
+	// one hour or day
+	rollupKeyPrefix = "rollup:"
+
+	// countryFieldPrefix and referrerFieldPrefix prefix the per-country and
+	// per-referrer counters of an aggregate; the other dimensions use the
+	// prefixes of the click counters
+	countryFieldPrefix  = "country:"
+	referrerFieldPrefix = "referrer:"
+
+	// aggregateBatchSize is the number of click events aggregated per transaction
+	aggregateBatchSize = 1000
+
+	// DefaultRawRetention is how long raw click events are kept by default
+	DefaultRawRetention = 24 * time.Hour
+
+	// DefaultHourlyRetention and DefaultDailyRetention are how long hourly
+	// and daily aggregates are kept by default
+	DefaultHourlyRetention = 7 * 24 * time.Hour
+	DefaultDailyRetention  = 400 * 24 * time.Hour
+)
+
+// Granularity is the length of the buckets clicks are aggregated into
+type Granularity string
+
+const (
+	GranularityHour Granularity = "hour"
+	GranularityDay  Granularity = "day"
+
+	// GranularityWeek buckets start on Mondays. Weeks aren't stored, but
+	// built from stored aggregates by Regroup.
+	GranularityWeek Granularity = "week"
+)
+
+// ErrInvalidGranularity is returned for unknown rollup granularities
+var ErrInvalidGranularity = errors.New("granularity must be hour or day")
+
+// RollupOptions sets how long raw click events and aggregates are kept.
+// Zero values keep the defaults.
+type RollupOptions struct {
+	RawRetention    time.Duration
+	HourlyRetention time.Duration
+	DailyRetention  time.Duration
+}
+
+// Bucket counts the clicks of a key in one hour or day
+type Bucket struct {
+	Start     time.Time        `json:"start"`
+	Clicks    int64            `json:"clicks"`
+	Countries map[string]int64 `json:"countries,omitempty"`
+	Referrers map[string]int64 `json:"referrers,omitempty"`
+	Channels  map[string]int64 `json:"channels,omitempty"`
+	Devices   map[string]int64 `json:"devices,omitempty"`
+	OS        map[string]int64 `json:"os,omitempty"`
+	Browsers  map[string]int64 `json:"browsers,omitempty"`
+}
+
+// breakdowns maps the prefix of each of a bucket's aggregate fields to its
+// counts
+func (b *Bucket) breakdowns() map[string]*map[string]int64 {
+	return map[string]*map[string]int64{
+		countryFieldPrefix:  &b.Countries,
+		referrerFieldPrefix: &b.Referrers,
+		channelFieldPrefix:  &b.Channels,
+		deviceFieldPrefix:   &b.Devices,
+		osFieldPrefix:       &b.OS,
+		browserFieldPrefix:  &b.Browsers,
+	}
+}
+
+// Rollups are the aggregated clicks of a key over a time range, bucket by
+// bucket and in total
+type Rollups struct {
+	Granularity Granularity      `json:"granularity"`
+	Buckets     []Bucket         `json:"buckets"`
+	Clicks      int64            `json:"clicks"`
+	Countries   map[string]int64 `json:"countries,omitempty"`
+	Referrers   map[string]int64 `json:"referrers,omitempty"`
+	Channels    map[string]int64 `json:"channels,omitempty"`
+	Devices     map[string]int64 `json:"devices,omitempty"`
+	OS          map[string]int64 `json:"os,omitempty"`
+	Browsers    map[string]int64 `json:"browsers,omitempty"`
+}
+
+// breakdowns maps the prefix of each aggregate field to its total counts
+func (r *Rollups) breakdowns() map[string]*map[string]int64 {
+	return map[string]*map[string]int64{
+		countryFieldPrefix:  &r.Countries,
+		referrerFieldPrefix: &r.Referrers,
+		channelFieldPrefix:  &r.Channels,
+		deviceFieldPrefix:   &r.Devices,
+		osFieldPrefix:       &r.OS,
+		browserFieldPrefix:  &r.Browsers,
+	}
+}
+
+// RollupStore represents the storage interface for aggregated click statistics
+type RollupStore interface {
+	Aggregate(ctx context.Context) (int, error)
+	GetRollups(ctx context.Context, key string, g Granularity, from, to time.Time) (*Rollups, error)
+}
+
+// ParseGranularity parses a rollup granularity
+func ParseGranularity(s string) (Granularity, error) {
+	switch g := Granularity(s); g {
+	case GranularityHour, GranularityDay:
+		return g, nil
+	}
+	return "", ErrInvalidGranularity
+}
+
+// Truncate returns the start of the bucket containing t
+func (g Granularity) Truncate(t time.Time) time.Time {
+	return g.TruncateIn(t, time.UTC)
+}
+
+// TruncateIn returns the start of the bucket containing t, with days and
+// weeks starting at midnight in loc
+func (g Granularity) TruncateIn(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch g {
+	case GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case GranularityWeek:
+		monday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-monday, 0, 0, 0, 0, loc)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// Next returns the start of the bucket following the one starting at t
+func (g Granularity) Next(t time.Time) time.Time {
+	switch g {
+	case GranularityDay:
+		return t.AddDate(0, 0, 1)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.Add(time.Hour)
+}
+
+// Span returns the nominal length of a bucket
+func (g Granularity) Span() time.Duration {
+	switch g {
+	case GranularityDay:
+		return 24 * time.Hour
+	case GranularityWeek:
+		return 7 * 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// rollupKey returns the hash holding a key's aggregates for the bucket starting at t
+func rollupKey(key string, g Granularity, t time.Time) string {
+	layout := "2006010215"
+	if g == GranularityDay {
+		layout = "20060102"
+	}
+	return rollupKeyPrefix + key + ":" + string(g) + ":" + t.Format(layout)
+}
+
+// rollupKeys returns every aggregate of a key that can still exist at now
+// under the retention of opts. Buckets live their retention past their last
+// click, which may have been aggregated up to the raw retention late.
+func rollupKeys(key string, opts RollupOptions, now time.Time) []string {
+	var keys []string
+	for g, retention := range map[Granularity]time.Duration{
+		GranularityHour: opts.HourlyRetention,
+		GranularityDay:  opts.DailyRetention,
+	} {
+		for t := g.Truncate(now.Add(-retention - opts.RawRetention)); !t.After(now); t = g.Next(t) {
+			keys = append(keys, rollupKey(key, g, t))
+		}
+	}
+	return keys
+}
+
+// EnableRollups records every click as a raw event for aggregation into
+// hourly and daily rollups, keeping events and rollups as long as opts says
+func (s *RedisStore) EnableRollups(opts RollupOptions) {
+	if opts.RawRetention <= 0 {
+		opts.RawRetention = DefaultRawRetention
+	}
+	if opts.HourlyRetention <= 0 {
+		opts.HourlyRetention = DefaultHourlyRetention
+	}
+	if opts.DailyRetention <= 0 {
+		opts.DailyRetention = DefaultDailyRetention
+	}
+	s.rollups = &opts
+}
+
+// addClickEvent appends a click to the raw event stream
+func addClickEvent(ctx context.Context, pipe redis.Pipeliner, click analytics.Click) {
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: clickEventsKey,
+		Values: []interface{}{
+			"key", click.Key,
+			"time", click.Time.Unix(),
+			"country", click.Country,
+			"referrer", click.Referrer,
+			"channel", click.Channel,
+			"device", click.Device,
+			"os", click.OS,
+			"browser", click.Browser,
+		},
+	})
+}
+
+// Aggregate rolls the click events recorded since the last run into hourly
+// and daily aggregates, then trims raw events past their retention. Events
+// are aggregated in batches, each committed together with the cursor so no
+// event is counted twice; when another instance is aggregating concurrently
+// this one backs off until the next run. It returns the number of events
+// aggregated.
+func (s *RedisStore) Aggregate(ctx context.Context) (int, error) {
+	if s.rollups == nil {
+		return 0, nil
+	}
+
+	total := 0
+	for {
+		n, err := s.aggregateBatch(ctx)
+		if err == redis.TxFailedErr {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+		if total += n; n < aggregateBatchSize {
+			break
+		}
+	}
+	return total, s.trimClickEvents(ctx)
+}
+
+// aggregateBatch aggregates the next batch of click events
+func (s *RedisStore) aggregateBatch(ctx context.Context) (int, error) {
+	n := 0
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		start := "-"
+		cursor, err := tx.Get(ctx, rollupCursorKey).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if cursor != "" {
+			start = "(" + cursor
+		}
+
+		events, err := tx.XRangeN(ctx, clickEventsKey, start, "+", aggregateBatchSize).Result()
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		buckets := make(map[string]map[string]int64)
+		ttls := make(map[string]time.Duration)
+		for _, event := range events {
+			key, _ := event.Values["key"].(string)
+			if key == "" {
+				continue
+			}
+			unix, _ := strconv.ParseInt(stringValue(event.Values["time"]), 10, 64)
+			t := time.Unix(unix, 0)
+
+			for g, ttl := range map[Granularity]time.Duration{
+				GranularityHour: s.rollups.HourlyRetention,
+				GranularityDay:  s.rollups.DailyRetention,
+			} {
+				bucket := rollupKey(key, g, g.Truncate(t))
+				fields := buckets[bucket]
+				if fields == nil {
+					fields = make(map[string]int64)
+					buckets[bucket] = fields
+					ttls[bucket] = ttl
+				}
+				fields["clicks"]++
+				if country := stringValue(event.Values["country"]); country != "" {
+					fields[countryFieldPrefix+country]++
+				}
+				if referrer := stringValue(event.Values["referrer"]); referrer != "" {
+					fields[referrerFieldPrefix+referrer]++
+				}
+				for _, d := range clickDimensions {
+					if value := stringValue(event.Values[d.field]); value != "" {
+						fields[d.prefix+value]++
+					}
+				}
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for bucket, fields := range buckets {
+				for field, count := range fields {
+					pipe.HIncrBy(ctx, bucket, field, count)
+				}
+				pipe.Expire(ctx, bucket, ttls[bucket])
+			}
+			pipe.Set(ctx, rollupCursorKey, events[len(events)-1].ID, 0)
+			return nil
+		})
+		n = len(events)
+		return err
+	}, rollupCursorKey)
+	return n, err
+}
+
+// trimClickEvents drops raw events past their retention, keeping those not
+// yet aggregated, nor archived when archiving
+func (s *RedisStore) trimClickEvents(ctx context.Context) error {
+	minID := strconv.FormatInt(time.Now().Add(-s.rollups.RawRetention).UnixMilli(), 10)
+
+	cursors := []string{rollupCursorKey}
+	if s.archive {
+		cursors = append(cursors, archiveCursorKey)
+	}
+	for _, key := range cursors {
+		cursor, err := s.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ms, _, _ := strings.Cut(cursor, "-"); len(ms) < len(minID) || (len(ms) == len(minID) && ms < minID) {
+			minID = cursor
+		}
+	}
+	return s.client.XTrimMinID(ctx, clickEventsKey, minID).Err()
+}
+
+// GetRollups returns the aggregated clicks of a key in the buckets
+// overlapping [from, to), oldest first. Buckets without clicks are included
+// so the series has no gaps.
+func (s *RedisStore) GetRollups(ctx context.Context, key string, g Granularity, from, to time.Time) (*Rollups, error) {
+	if _, err := ParseGranularity(string(g)); err != nil {
+		return nil, err
+	}
+
+	var starts []time.Time
+	for t := g.Truncate(from); t.Before(to); t = g.Next(t) {
+		starts = append(starts, t)
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(starts))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, t := range starts {
+			cmds[i] = pipe.HGetAll(ctx, rollupKey(key, g, t))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := &Rollups{Granularity: g, Buckets: make([]Bucket, len(starts))}
+	for i, cmd := range cmds {
+		bucket := Bucket{Start: starts[i]}
+		for field, value := range cmd.Val() {
+			count, _ := strconv.ParseInt(value, 10, 64)
+			if field == "clicks" {
+				bucket.Clicks = count
+				continue
+			}
+			totals := rollups.breakdowns()
+			for prefix, counts := range bucket.breakdowns() {
+				if name, ok := strings.CutPrefix(field, prefix); ok {
+					addCount(counts, name, count)
+					addCount(totals[prefix], name, count)
+				}
+			}
+		}
+		rollups.Clicks += bucket.Clicks
+		rollups.Buckets[i] = bucket
+	}
+	return rollups, nil
+}
+
+// Regroup sums the buckets of rollups into buckets of a coarser
+// granularity, with days and weeks starting at midnight in loc, so clients
+// needn't re-aggregate them. Rollups are regrouped into the same
+// granularity to move hourly buckets into another time zone.
+func (r *Rollups) Regroup(g Granularity, loc *time.Location) *Rollups {
+	regrouped := *r
+	regrouped.Granularity, regrouped.Buckets = g, []Bucket{}
+	for _, b := range r.Buckets {
+		start := g.TruncateIn(b.Start, loc)
+		n := len(regrouped.Buckets)
+		if n == 0 || !regrouped.Buckets[n-1].Start.Equal(start) {
+			regrouped.Buckets = append(regrouped.Buckets, Bucket{Start: start})
+			n++
+		}
+		bucket := &regrouped.Buckets[n-1]
+		bucket.Clicks += b.Clicks
+		totals := bucket.breakdowns()
+		for prefix, counts := range b.breakdowns() {
+			for name, count := range *counts {
+				addCount(totals[prefix], name, count)
+			}
+		}
+	}
+	return &regrouped
+}
+
+// addCount adds to a counter, allocating the map on first use
+func addCount(counts *map[string]int64, name string, n int64) {
+	if *counts == nil {
+		*counts = make(map[string]int64)
+	}
+	(*counts)[name] += n
+}
+
+// stringValue returns a stream field value as a string
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// scanMissesKeyPrefix prefixes the counters of unknown keys each client
+	// requested in its current window
+	scanMissesKeyPrefix = "scan:misses:"
+
+	// scannersKey is a sorted set of flagged clients, scored by the time
+	// their block ends
+	scannersKey = "scan:blocked"
+
+	// scannerMissesKey holds the misses that got each client flagged
+	scannerMissesKey = "scan:blocked:misses"
+)
+
+// Scanner is a client flagged for requesting too many unknown keys
+type Scanner struct {
+	IP           string    `json:"ip"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	Misses       int64     `json:"misses"`
+}
+
+// ScannerStore represents the storage interface for detecting clients
+// enumerating the keyspace
+type ScannerStore interface {
+	CountMiss(ctx context.Context, ip string, window time.Duration, limit int64, block time.Duration) (bool, error)
+	Scanners(ctx context.Context) ([]Scanner, error)
+	Unblock(ctx context.Context, ip string) error
+}
+
+// countMissScript counts an unknown key requested by a client in a window
+// starting at its first miss, flagging the client until ARGV[3] once its
+// misses pass the limit. Every further miss pushes the end of the block.
+var countMissScript = redis.NewScript(`
+local misses = redis.call('INCR', KEYS[1])
+if misses == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if misses <= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[4])
+redis.call('HSET', KEYS[3], ARGV[4], misses)
+return 1
+`)
+
+// CountMiss counts an unknown key requested by a client and reports whether
+// the client is flagged as a scanner, having requested more than limit
+// unknown keys within window. Flagged clients stay blocked for block after
+// their latest miss.
+func (s *RedisStore) CountMiss(ctx context.Context, ip string, window time.Duration, limit int64, block time.Duration) (bool, error) {
+	until := time.Now().Add(block).UnixMilli()
+	keys := []string{scanMissesKeyPrefix + ip, scannersKey, scannerMissesKey}
+	flagged, err := countMissScript.Run(ctx, s.client, keys, window.Milliseconds(), limit, until, ip).Int()
+	if err != nil {
+		return false, err
+	}
+	return flagged == 1, nil
+}
+
+// Scanners returns the clients currently blocked, forgetting those whose
+// block has ended
+func (s *RedisStore) Scanners(ctx context.Context) ([]Scanner, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	ended, err := s.client.ZRangeByScore(ctx, scannersKey, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ended) > 0 {
+		members := make([]interface{}, len(ended))
+		for i, ip := range ended {
+			members[i] = ip
+		}
+		_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, scannersKey, members...)
+			pipe.HDel(ctx, scannerMissesKey, ended...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	blocked, err := s.client.ZRangeByScoreWithScores(ctx, scannersKey, &redis.ZRangeBy{Min: "(" + now, Max: "+inf"}).Result()
+	if err != nil || len(blocked) == 0 {
+		return []Scanner{}, err
+	}
+	ips := make([]string, len(blocked))
+	for i, z := range blocked {
+		ips[i] = z.Member.(string)
+	}
+	misses, err := s.client.HMGet(ctx, scannerMissesKey, ips...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	scanners := make([]Scanner, len(blocked))
+	for i, z := range blocked {
+		scanners[i] = Scanner{IP: ips[i], BlockedUntil: time.UnixMilli(int64(z.Score)).UTC()}
+		if v, ok := misses[i].(string); ok {
+			scanners[i].Misses, _ = strconv.ParseInt(v, 10, 64)
+		}
+	}
+	return scanners, nil
+}
+
+// Unblock lifts the block on a client and resets its misses
+func (s *RedisStore) Unblock(ctx context.Context, ip string) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, scannersKey, ip)
+		pipe.HDel(ctx, scannerMissesKey, ip)
+		pipe.Del(ctx, scanMissesKeyPrefix+ip)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SchemaVersion is the version of the record schema this build writes.
+// Changing how records are stored means adding a migration and bumping it.
+const SchemaVersion = 1
+
+// migrations upgrade a stored record one schema version at a time:
+// migrations[v] turns the fields of a version v record into those of
+// version v+1. Records written before versioning are version 0.
+var migrations = []func(fields map[string]json.RawMessage) error{
+	// 0 → 1 only stamps the version
+	func(map[string]json.RawMessage) error { return nil },
+}
+
+// upgradeRecordScript replaces a stored record with its upgrade unless it
+// changed since it was read, keeping its TTL
+var upgradeRecordScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+return 1
+`)
+
+// storedRecord is a record as stored, stamped with its schema version
+type storedRecord struct {
+	Schema int `json:"schema"`
+	*Record
+}
+
+// recordSchema returns the schema version of a stored value. Values written
+// before records were introduced hold the bare URL and are version 0.
+func recordSchema(value string) int {
+	if !strings.HasPrefix(value, "{") {
+		return 0
+	}
+	var stamp struct {
+		Schema int `json:"schema"`
+	}
+	json.Unmarshal([]byte(value), &stamp)
+	return stamp.Schema
+}
+
+// migrateRecord upgrades the JSON of a record from its schema version to
+// the current one
+func migrateRecord(value string, from int) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", err
+	}
+	for v := from; v < SchemaVersion; v++ {
+		if err := migrations[v](fields); err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// ParseRecord parses a record serialized with the given schema version
+// outside the store, such as in a backup, migrating it to the current one
+func ParseRecord(data []byte, schema int) (*Record, error) {
+	value := string(data)
+	if schema < SchemaVersion {
+		var err error
+		if value, err = migrateRecord(value, schema); err != nil {
+			return nil, err
+		}
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(value), &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// upgradeRecord stores the current encoding of a record read with an older
+// schema, unless the record changed meanwhile. It reports whether it did.
+func (s *RedisStore) upgradeRecord(ctx context.Context, key, value string, rec *Record) (bool, error) {
+	upgraded, err := encodeRecord(rec)
+	if err != nil {
+		return false, err
+	}
+	n, err := upgradeRecordScript.Run(ctx, s.client, []string{key}, value, upgraded).Int()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	s.invalidate(key)
+	return true, nil
+}
+
+// MigrateRecords upgrades every record stored with an older schema version,
+// so later versions can drop migrations. Records are otherwise upgraded as
+// they are read. It returns the number of records upgraded.
+func (s *RedisStore) MigrateRecords(ctx context.Context) (int, error) {
+	upgraded := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, "*", scanBatchSize).Result()
+		if err != nil {
+			return upgraded, err
+		}
+		if keys = linkKeys(keys); len(keys) > 0 {
+			values, err := s.client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return upgraded, err
+			}
+			for i, v := range values {
+				value, ok := v.(string)
+				if !ok || recordSchema(value) >= SchemaVersion {
+					continue
+				}
+				rec, err := decodeRecord(value)
+				if err != nil {
+					continue
+				}
+				ok, err = s.upgradeRecord(ctx, keys[i], value, rec)
+				if err != nil {
+					return upgraded, err
+				}
+				if ok {
+					upgraded++
+				}
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return upgraded, nil
+		}
+	}
+}
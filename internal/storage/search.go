@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// tagKeyPrefix prefixes the set of keys carrying each tag
+	tagKeyPrefix = "tag:"
+
+	// DefaultSearchLimit is the default number of links returned by a search
+	DefaultSearchLimit = 50
+
+	// MaxSearchLimit is the most links a single search returns
+	MaxSearchLimit = 500
+
+	// scanBatchSize is the number of keys read per SCAN and MGET round trip
+	scanBatchSize = 500
+)
+
+// SearchQuery selects links by tag, substring of the destination, label or
+// note, destination host, creation time, destination status, owner and
+// workspace. Empty fields match every link.
+type SearchQuery struct {
+	Tag       string
+	Query     string
+	Owner     string
+	Workspace string
+	Limit     int
+
+	// Host matches destinations on the host or any of its subdomains
+	Host string
+
+	// CreatedBefore matches links created before the time
+	CreatedBefore time.Time
+
+	// Status matches links by the outcome of their last destination check
+	Status CheckStatus
+}
+
+// matches reports whether a record satisfies every field of the query but
+// the tag, which is matched through its index. Query and Host must already
+// be lowercase.
+func (q SearchQuery) matches(rec *Record) bool {
+	if q.Owner != "" && rec.Owner != q.Owner {
+		return false
+	}
+	if q.Workspace != "" && rec.Workspace != q.Workspace {
+		return false
+	}
+	if q.Query != "" && !rec.Matches(q.Query) {
+		return false
+	}
+	if q.Host != "" {
+		u, err := url.Parse(rec.URL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		if host != q.Host && !strings.HasSuffix(host, "."+q.Host) {
+			return false
+		}
+	}
+	if !q.CreatedBefore.IsZero() && !rec.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.Status != "" && rec.CheckStatus() != q.Status {
+		return false
+	}
+	return true
+}
+
+// SearchResult is a link matched by a search
+type SearchResult struct {
+	Key       string
+	Record    *Record
+	ExpiresAt *time.Time
+}
+
+// Search returns links matching the query, newest first. Tag searches read
+// the tag's index and workspace searches the workspace's index; other
+// searches scan every link.
+func (s *RedisStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	q.Query = strings.ToLower(q.Query)
+	q.Host = strings.ToLower(q.Host)
+
+	var results []SearchResult
+	collect := func(keys []string) error {
+		matched, err := s.matchKeys(ctx, keys, q)
+		results = append(results, matched...)
+		return err
+	}
+
+	if q.Tag != "" || q.Workspace != "" {
+		index := workspaceLinksKey(q.Workspace)
+		if q.Tag != "" {
+			index = tagKeyPrefix + q.Tag
+		}
+		keys, err := s.client.SMembers(ctx, index).Result()
+		if err != nil {
+			return nil, err
+		}
+		for len(keys) > 0 {
+			n := min(len(keys), scanBatchSize)
+			if err := collect(keys[:n]); err != nil {
+				return nil, err
+			}
+			keys = keys[n:]
+		}
+	} else {
+		var cursor uint64
+		for {
+			keys, next, err := s.client.Scan(ctx, cursor, "*", scanBatchSize).Result()
+			if err != nil {
+				return nil, err
+			}
+			if err := collect(linkKeys(keys)); err != nil {
+				return nil, err
+			}
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i].Record.CreatedAt, results[j].Record.CreatedAt
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return results[i].Key < results[j].Key
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	if err := s.fillExpiry(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// matchKeys loads the records of keys and returns those matching the query.
+// Keys of an index that have since expired are pruned from it.
+func (s *RedisStore) matchKeys(ctx context.Context, keys []string, q SearchQuery) ([]SearchResult, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []SearchResult
+	var stale []interface{}
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			stale = append(stale, keys[i])
+			continue
+		}
+		rec, err := decodeRecord(value)
+		if err != nil {
+			continue
+		}
+		if !q.matches(rec) {
+			continue
+		}
+		matched = append(matched, SearchResult{Key: keys[i], Record: rec})
+	}
+
+	if len(stale) > 0 {
+		switch {
+		case q.Tag != "":
+			s.client.SRem(ctx, tagKeyPrefix+q.Tag, stale...)
+		case q.Workspace != "":
+			s.client.SRem(ctx, workspaceLinksKey(q.Workspace), stale...)
+		}
+	}
+	return matched, nil
+}
+
+// fillExpiry looks up the expiry of every result in one round trip
+func (s *RedisStore) fillExpiry(ctx context.Context, results []SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	cmds := make([]*redis.DurationCmd, len(results))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, r := range results {
+			cmds[i] = pipe.PTTL(ctx, r.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for i, cmd := range cmds {
+		if ttl := cmd.Val(); ttl > 0 {
+			t := now.Add(ttl).Truncate(time.Second)
+			results[i].ExpiresAt = &t
+		}
+	}
+	return nil
+}
+
+// linkKeys filters out auxiliary keys, which always contain a colon
+func linkKeys(keys []string) []string {
+	links := keys[:0]
+	for _, key := range keys {
+		if !strings.Contains(key, ":") {
+			links = append(links, key)
+		}
+	}
+	return links
+}
+
+// indexTags adds a key to the index of each of its tags
+func indexTags(ctx context.Context, pipe redis.Pipeliner, key string, tags []string) {
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagKeyPrefix+tag, key)
+	}
+}
+
+// indexScope adds a key to the index of the workspace or owner it is charged to
+func indexScope(ctx context.Context, pipe redis.Pipeliner, key string, rec *Record) {
+	if scope := ScopeOf(rec); !scope.IsZero() {
+		pipe.SAdd(ctx, scope.linksKey(), key)
+	}
+}
+
+// unindexScope removes a key from the index of its workspace or owner
+func unindexScope(ctx context.Context, pipe redis.Pipeliner, key string, rec *Record) {
+	if scope := ScopeOf(rec); !scope.IsZero() {
+		pipe.SRem(ctx, scope.linksKey(), key)
+	}
+}
+
+// unindexTags removes a key from the index of each of the given tags
+func unindexTags(ctx context.Context, pipe redis.Pipeliner, key string, tags []string) {
+	for _, tag := range tags {
+		pipe.SRem(ctx, tagKeyPrefix+tag, key)
+	}
+}
+
+// tagDiff returns the tags in a but not in b
+func tagDiff(a, b []string) []string {
+	var diff []string
+	for _, tag := range a {
+		found := false
+		for _, other := range b {
+			if tag == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, tag)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sessionKeyPrefix prefixes the key holding each dashboard session
+const sessionKeyPrefix = "session:"
+
+// ErrSessionNotFound is returned when a session does not exist or has expired
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is an authenticated dashboard session
+type Session struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	CSRFToken string    `json:"csrf_token"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionStore represents the storage interface for dashboard sessions
+type SessionStore interface {
+	CreateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	DeleteSession(ctx context.Context, id string) error
+}
+
+// CreateSession stores a session until its expiry time
+func (s *RedisStore) CreateSession(ctx context.Context, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(sess.ExpiresAt)
+	if ttl <= 0 {
+		return errors.New("session already expired")
+	}
+
+	success, err := s.client.SetNX(ctx, sessionKeyPrefix+sess.ID, data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !success {
+		return ErrKeyExists
+	}
+	return nil
+}
+
+// GetSession retrieves a session by ID
+func (s *RedisStore) GetSession(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, sessionKeyPrefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// DeleteSession removes a session
+func (s *RedisStore) DeleteSession(ctx context.Context, id string) error {
+	return s.client.Del(ctx, sessionKeyPrefix+id).Err()
+}
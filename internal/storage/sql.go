@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlSchema creates the links table. Records are stored as JSON alongside
+// the columns searches filter on; timestamps are Unix seconds so the schema
+// works unchanged on PostgreSQL, MySQL and SQLite.
+const sqlSchema = `CREATE TABLE IF NOT EXISTS links (
+	link_key VARCHAR(255) PRIMARY KEY,
+	owner VARCHAR(255) NOT NULL DEFAULT '',
+	workspace VARCHAR(64) NOT NULL DEFAULT '',
+	record TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+)`
+
+// DurableStore is the source of truth behind a TieredStore
+type DurableStore interface {
+	Create(ctx context.Context, key string, rec *Record) error
+	GetRecord(ctx context.Context, key string) (*Record, error)
+	GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error)
+	Update(ctx context.Context, key string, rec *Record) error
+	Delete(ctx context.Context, key string) error
+	DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+	Walk(ctx context.Context, fn func(SearchResult) error) error
+}
+
+// SQLStore keeps URL mappings in a SQL database. Links stored here never
+// expire. The database driver must be registered by the caller.
+type SQLStore struct {
+	db       *sql.DB
+	numbered bool
+}
+
+// NewSQLStore creates a new SQLStore over db. driver selects the placeholder
+// style: PostgreSQL drivers use $1, $2, ..., every other driver uses ?.
+func NewSQLStore(db *sql.DB, driver string) *SQLStore {
+	switch driver {
+	case "postgres", "pgx":
+		return &SQLStore{db: db, numbered: true}
+	}
+	return &SQLStore{db: db}
+}
+
+// Migrate creates the links table if it does not exist
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, sqlSchema)
+	return err
+}
+
+// Create stores a URL mapping record with the specified key
+func (s *SQLStore) Create(ctx context.Context, key string, rec *Record) error {
+	value, err := encodeNew(key, rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		s.rebind("INSERT INTO links (link_key, owner, workspace, record, created_at) VALUES (?, ?, ?, ?, ?)"),
+		key, rec.Owner, rec.Workspace, value, rec.CreatedAt.Unix())
+	if err == nil {
+		return nil
+	}
+
+	// Drivers report unique violations differently, so check for the key
+	// rather than parsing the error
+	if _, lookupErr := s.GetRecord(ctx, key); lookupErr == nil {
+		return ErrKeyExists
+	}
+	return err
+}
+
+// GetRecord retrieves a URL mapping record by key
+func (s *SQLStore) GetRecord(ctx context.Context, key string) (*Record, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT record FROM links WHERE link_key = ?"), key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(value)
+}
+
+// GetMulti retrieves the records of several keys in one query, in order,
+// with nil for keys holding no link and an error for records that can't be
+// decoded
+func (s *SQLStore) GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error) {
+	recs := make([]*Record, len(keys))
+	errs := make([]error, len(keys))
+	if len(keys) == 0 {
+		return recs, errs, nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	query := "SELECT link_key, record FROM links WHERE link_key IN (?" + strings.Repeat(", ?", len(keys)-1) + ")"
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string, len(keys))
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, nil, err
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	for i, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if recs[i], err = decodeRecord(value); err != nil {
+			errs[i] = fmt.Errorf("decoding %s: %w", key, err)
+		}
+	}
+	return recs, errs, nil
+}
+
+// Update replaces the record of an existing URL mapping
+func (s *SQLStore) Update(ctx context.Context, key string, rec *Record) error {
+	if rec == nil || rec.URL == "" {
+		return errors.New("url cannot be empty")
+	}
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+
+	value, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		s.rebind("UPDATE links SET owner = ?, workspace = ?, record = ? WHERE link_key = ?"),
+		rec.Owner, rec.Workspace, value, key)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(res); err != ErrNotFound {
+		return err
+	}
+
+	// Some drivers only count rows whose values changed
+	_, err = s.GetRecord(ctx, key)
+	return err
+}
+
+// Delete removes a URL mapping
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM links WHERE link_key = ?"), key)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// DeleteIfCreated removes a URL mapping created at createdAt. Creation
+// times are compared to the second, as the links table stores them.
+func (s *SQLStore) DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		s.rebind("DELETE FROM links WHERE link_key = ? AND created_at = ?"), key, createdAt.Unix())
+	if err != nil {
+		return err
+	}
+	if err := requireRow(res); err != ErrNotFound {
+		return err
+	}
+	if _, err := s.GetRecord(ctx, key); err != nil {
+		return err
+	}
+	return ErrLinkReplaced
+}
+
+// Search returns links matching the query, newest first. Owner and
+// workspace are filtered by the database, the other fields as rows are read.
+func (s *SQLStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	q.Query = strings.ToLower(q.Query)
+	q.Host = strings.ToLower(q.Host)
+
+	query := "SELECT link_key, record FROM links"
+	var where []string
+	var args []interface{}
+	if q.Owner != "" {
+		where = append(where, "owner = ?")
+		args = append(args, q.Owner)
+	}
+	if q.Workspace != "" {
+		where = append(where, "workspace = ?")
+		args = append(args, q.Workspace)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, link_key"
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() && len(results) < q.Limit {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		rec, err := decodeRecord(value)
+		if err != nil {
+			continue
+		}
+		if q.Tag != "" && len(tagDiff([]string{q.Tag}, rec.Tags)) > 0 {
+			continue
+		}
+		if !q.matches(rec) {
+			continue
+		}
+		results = append(results, SearchResult{Key: key, Record: rec})
+	}
+	return results, rows.Err()
+}
+
+// Walk calls fn for every stored link, oldest first, stopping at the first
+// error fn returns
+func (s *SQLStore) Walk(ctx context.Context, fn func(SearchResult) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT link_key, record FROM links ORDER BY created_at, link_key")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		rec, err := decodeRecord(value)
+		if err != nil {
+			continue
+		}
+		if err := fn(SearchResult{Key: key, Record: rec}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Close closes the database connection
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites ? placeholders for drivers using numbered placeholders
+func (s *SQLStore) rebind(query string) string {
+	if !s.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// requireRow reports ErrNotFound when a statement matched no rows
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
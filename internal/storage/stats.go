@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// statsKeyPrefix prefixes the hash holding each key's click counters
+	statsKeyPrefix = "clicks:"
+
+	// periodsSuffix names the counter of archived measurement periods
+	periodsSuffix = ":periods"
+
+	// periodSuffix prefixes the hash holding an archived measurement period
+	periodSuffix = ":period:"
+
+	// channelFieldPrefix, deviceFieldPrefix, osFieldPrefix and
+	// browserFieldPrefix prefix the per-channel, per-device type, per-OS
+	// and per-browser click counters
+	channelFieldPrefix = "channel:"
+	deviceFieldPrefix  = "device:"
+	osFieldPrefix      = "os:"
+	browserFieldPrefix = "browser:"
+
+	// variantFieldPrefix prefixes the per-variant click counters
+	variantFieldPrefix = "variant:"
+)
+
+// Stats are the click counters of a short link for one measurement period
+type Stats struct {
+	Period int   `json:"period"`
+	Clicks int64 `json:"clicks"`
+
+	// Variants counts clicks per split test variant
+	Variants map[string]int64 `json:"variants,omitempty"`
+
+	// Channels counts clicks per referral channel, such as search or
+	// social:twitter
+	Channels map[string]int64 `json:"channels,omitempty"`
+
+	// Devices, OS and Browsers count clicks per device type, operating
+	// system and browser
+	Devices  map[string]int64 `json:"devices,omitempty"`
+	OS       map[string]int64 `json:"os,omitempty"`
+	Browsers map[string]int64 `json:"browsers,omitempty"`
+
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// StatsStore represents the storage interface for click statistics
+type StatsStore interface {
+	analytics.Sink
+	GetStats(ctx context.Context, key string) (*Stats, error)
+	GetArchivedStats(ctx context.Context, key string) ([]*Stats, error)
+	ResetStats(ctx context.Context, key string) (*Stats, error)
+}
+
+// resetStatsScript archives the current counters of a key as a numbered
+// period and starts a fresh one, atomically so no click is lost or counted twice.
+var resetStatsScript = redis.NewScript(`
+local period = redis.call('INCR', KEYS[2])
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('RENAME', KEYS[1], KEYS[3] .. period)
+	redis.call('HSET', KEYS[3] .. period, 'until', ARGV[1])
+else
+	redis.call('HSET', KEYS[3] .. period, 'clicks', 0, 'until', ARGV[1])
+end
+redis.call('HSET', KEYS[1], 'since', ARGV[1])
+return period
+`)
+
+// clearStatsScript deletes the counters of a key with its archived periods.
+// KEYS are the counters, the periods counter and the prefix of the
+// archived periods.
+var clearStatsScript = redis.NewScript(`
+local periods = tonumber(redis.call('GET', KEYS[2]) or '0')
+for period = 1, periods do
+	redis.call('DEL', KEYS[3] .. period)
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return periods
+`)
+
+// clearStats deletes the counters a former link at key left behind
+func clearStats(ctx context.Context, pipe redis.Pipeliner, key string) {
+	clearStatsScript.Eval(ctx, pipe, []string{
+		statsKeyPrefix + key, statsKeyPrefix + key + periodsSuffix, statsKeyPrefix + key + periodSuffix,
+	})
+}
+
+// RecordClick increments the click counters of a key and the hourly top
+// links. With rollups enabled
+// it appends the click to the raw event stream, and with live clicks enabled
+// it publishes the click to the key's subscribers.
+func (s *RedisStore) RecordClick(ctx context.Context, click analytics.Click) error {
+	statsKey := statsKeyPrefix + click.Key
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, statsKey, "clicks", 1)
+		countTopClick(ctx, pipe, click)
+		if click.Variant != "" {
+			pipe.HIncrBy(ctx, statsKey, variantFieldPrefix+click.Variant, 1)
+		}
+		for _, d := range clickDimensions {
+			if value := d.value(click); value != "" {
+				pipe.HIncrBy(ctx, statsKey, d.prefix+value, 1)
+			}
+		}
+		if click.Workspace != "" {
+			pipe.HIncrBy(ctx, workspaceClicksKey(click.Workspace), "clicks", 1)
+		}
+		if s.rollups != nil {
+			addClickEvent(ctx, pipe, click)
+		}
+		if s.visits != nil && !click.NoTrack {
+			addVisit(ctx, pipe, click, s.visits)
+		}
+		if s.live && !click.NoTrack {
+			publishClick(ctx, pipe, click)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetStats returns the counters of the current measurement period
+func (s *RedisStore) GetStats(ctx context.Context, key string) (*Stats, error) {
+	fields, err := s.client.HGetAll(ctx, statsKeyPrefix+key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	period, err := s.client.Get(ctx, statsKeyPrefix+key+periodsSuffix).Int()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := parseStats(fields)
+	stats.Period = period + 1
+	return stats, nil
+}
+
+// GetArchivedStats returns the counters of every archived measurement period
+func (s *RedisStore) GetArchivedStats(ctx context.Context, key string) ([]*Stats, error) {
+	periods, err := s.client.Get(ctx, statsKeyPrefix+key+periodsSuffix).Int()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archived := make([]*Stats, 0, periods)
+	for period := 1; period <= periods; period++ {
+		fields, err := s.client.HGetAll(ctx, statsKeyPrefix+key+periodSuffix+strconv.Itoa(period)).Result()
+		if err != nil {
+			return nil, err
+		}
+		stats := parseStats(fields)
+		stats.Period = period
+		archived = append(archived, stats)
+	}
+	return archived, nil
+}
+
+// ResetStats zeroes the counters of a key and returns the archived period
+func (s *RedisStore) ResetStats(ctx context.Context, key string) (*Stats, error) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	keys := []string{
+		statsKeyPrefix + key,
+		statsKeyPrefix + key + periodsSuffix,
+		statsKeyPrefix + key + periodSuffix,
+	}
+
+	period, err := resetStatsScript.Run(ctx, s.client, keys, now).Int()
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := s.client.HGetAll(ctx, keys[2]+strconv.Itoa(period)).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := parseStats(fields)
+	stats.Period = period
+	return stats, nil
+}
+
+// statsKeys returns the keys holding the current and archived counters of a
+// key with the given number of archived periods
+func statsKeys(key string, periods int) []string {
+	keys := []string{statsKeyPrefix + key, statsKeyPrefix + key + periodsSuffix}
+	for period := 1; period <= periods; period++ {
+		keys = append(keys, statsKeyPrefix+key+periodSuffix+strconv.Itoa(period))
+	}
+	return keys
+}
+
+// clickDimensions are the dimensions clicks are counted by in both the
+// click counters and rollups: the click event field holding each, the
+// prefix of its counters and its value in a click
+var clickDimensions = []struct {
+	field  string
+	prefix string
+	value  func(analytics.Click) string
+}{
+	{"channel", channelFieldPrefix, func(c analytics.Click) string { return c.Channel }},
+	{"device", deviceFieldPrefix, func(c analytics.Click) string { return c.Device }},
+	{"os", osFieldPrefix, func(c analytics.Click) string { return c.OS }},
+	{"browser", browserFieldPrefix, func(c analytics.Click) string { return c.Browser }},
+}
+
+// breakdowns maps the prefix of each dimension's counters to its counts
+func (s *Stats) breakdowns() map[string]*map[string]int64 {
+	return map[string]*map[string]int64{
+		channelFieldPrefix: &s.Channels,
+		deviceFieldPrefix:  &s.Devices,
+		osFieldPrefix:      &s.OS,
+		browserFieldPrefix: &s.Browsers,
+	}
+}
+
+// parseStats converts a counters hash into Stats
+func parseStats(fields map[string]string) *Stats {
+	stats := &Stats{}
+	stats.Clicks, _ = strconv.ParseInt(fields["clicks"], 10, 64)
+	if t, err := time.Parse(time.RFC3339Nano, fields["since"]); err == nil {
+		stats.Since = &t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, fields["until"]); err == nil {
+		stats.Until = &t
+	}
+	for field, value := range fields {
+		count, _ := strconv.ParseInt(value, 10, 64)
+		if name, ok := strings.CutPrefix(field, variantFieldPrefix); ok {
+			addCount(&stats.Variants, name, count)
+			continue
+		}
+		for prefix, counts := range stats.breakdowns() {
+			if name, ok := strings.CutPrefix(field, prefix); ok {
+				addCount(counts, name, count)
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// TieredStore keeps links in a durable store and caches them in Redis.
+// Writes go to the durable store first; reads are served from Redis and
+// fall back to the durable store on a miss, repopulating the cache. Cached
+// copies expire after the Redis store's TTL, but the links themselves never
+// do.
+type TieredStore struct {
+	durable DurableStore
+	cache   *RedisStore
+}
+
+// NewTieredStore creates a new TieredStore caching durable in cache
+func NewTieredStore(durable DurableStore, cache *RedisStore) *TieredStore {
+	return &TieredStore{durable: durable, cache: cache}
+}
+
+// Set stores a URL mapping with the specified key
+func (s *TieredStore) Set(ctx context.Context, key, url string) error {
+	return s.Create(ctx, key, &Record{
+		URL:       url,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// Create stores a URL mapping record in the durable store and caches it.
+// Landing pages live in the cache alone, so keys holding one are refused
+// when the cache answers, though not atomically with storing the link.
+func (s *TieredStore) Create(ctx context.Context, key string, rec *Record) error {
+	if _, err := s.cache.GetLandingPage(ctx, key); err == nil {
+		return ErrKeyExists
+	}
+	if err := s.durable.Create(ctx, key, rec); err != nil {
+		return err
+	}
+	s.fill(ctx, key, rec)
+	return nil
+}
+
+// Get retrieves a URL mapping by key. Mappings outside their activation
+// window return ErrNotYetActive or ErrNoLongerActive.
+func (s *TieredStore) Get(ctx context.Context, key string) (string, error) {
+	rec, err := s.GetRecord(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if err := rec.CheckActive(time.Now()); err != nil {
+		return "", err
+	}
+	return rec.URL, nil
+}
+
+// GetRecord retrieves a URL mapping record from the cache, falling back to
+// the durable store on a miss or when Redis fails. Misses repopulate the
+// cache, so links keep resolving while Redis is down.
+func (s *TieredStore) GetRecord(ctx context.Context, key string) (*Record, error) {
+	rec, err := s.cache.GetRecord(ctx, key)
+	if err == nil {
+		return rec, nil
+	}
+
+	missed := err == ErrNotFound
+	rec, err = s.durable.GetRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if missed {
+		s.fill(ctx, key, rec)
+	}
+	return rec, nil
+}
+
+// GetAndRefreshTTL retrieves a record, refreshing the TTL of its cached
+// copy. Misses and Redis failures fall back to the durable store like
+// GetRecord.
+func (s *TieredStore) GetAndRefreshTTL(ctx context.Context, key string) (*Record, error) {
+	rec, err := s.cache.GetAndRefreshTTL(ctx, key)
+	if err == nil {
+		return rec, nil
+	}
+
+	missed := err == ErrNotFound
+	rec, err = s.durable.GetRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if missed {
+		s.fill(ctx, key, rec)
+	}
+	return rec, nil
+}
+
+// GetMulti retrieves several records from the cache, reading those it
+// misses, or all of them while the cache fails, from the durable store in
+// one query without caching them
+func (s *TieredStore) GetMulti(ctx context.Context, keys []string) ([]*Record, []error, error) {
+	recs, errs, err := s.cache.GetMulti(ctx, keys)
+	if err != nil {
+		recs, errs = make([]*Record, len(keys)), make([]error, len(keys))
+	}
+
+	var missed []string
+	var at []int
+	for i, rec := range recs {
+		if rec == nil {
+			missed = append(missed, keys[i])
+			at = append(at, i)
+		}
+	}
+	if len(missed) == 0 {
+		return recs, errs, nil
+	}
+	found, foundErrs, err := s.durable.GetMulti(ctx, missed)
+	if err != nil {
+		return nil, nil, err
+	}
+	for j, i := range at {
+		recs[i], errs[i] = found[j], foundErrs[j]
+	}
+	return recs, errs, nil
+}
+
+// Update replaces the record in the durable store and refreshes any cached copy
+func (s *TieredStore) Update(ctx context.Context, key string, rec *Record) error {
+	if err := s.durable.Update(ctx, key, rec); err != nil {
+		return err
+	}
+	if err := s.cache.Update(ctx, key, rec); err != nil && err != ErrNotFound {
+		// A stale cached copy must not outlive the update
+		s.cache.Delete(ctx, key)
+	}
+	return nil
+}
+
+// Delete removes a URL mapping from the durable store, then drops the cached
+// copy together with its indexes and statistics
+func (s *TieredStore) Delete(ctx context.Context, key string) error {
+	if err := s.durable.Delete(ctx, key); err != nil {
+		return err
+	}
+	if err := s.cache.Delete(ctx, key); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// DeleteIfCreated removes a URL mapping created at createdAt from the
+// durable store, then drops the cached copy together with its indexes and
+// statistics
+func (s *TieredStore) DeleteIfCreated(ctx context.Context, key string, createdAt time.Time) error {
+	if err := s.durable.DeleteIfCreated(ctx, key, createdAt); err != nil {
+		return err
+	}
+	if err := s.cache.Delete(ctx, key); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// ExpiresAt returns nil for every existing link, since durable links never expire
+func (s *TieredStore) ExpiresAt(ctx context.Context, key string) (*time.Time, error) {
+	if _, err := s.GetRecord(ctx, key); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Search returns links matching the query from the durable store
+func (s *TieredStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	return s.durable.Search(ctx, q)
+}
+
+// CreateMany stores records in the durable store one at a time and caches
+// those created. Expiries are ignored since durable links never expire.
+func (s *TieredStore) CreateMany(ctx context.Context, items []BulkRecord) ([]error, error) {
+	errs := make([]error, len(items))
+	for i, item := range items {
+		if _, err := encodeNew(item.Key, item.Record); err != nil {
+			errs[i] = err
+			continue
+		}
+		err := s.durable.Create(ctx, item.Key, item.Record)
+		if err == ErrKeyExists {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.fill(ctx, item.Key, item.Record)
+	}
+	return errs, nil
+}
+
+// KeysExist reports, in order, whether each key holds a link in the
+// durable store
+func (s *TieredStore) KeysExist(ctx context.Context, keys []string) ([]bool, error) {
+	exist := make([]bool, len(keys))
+	for i, key := range keys {
+		_, err := s.durable.GetRecord(ctx, key)
+		if err != nil && err != ErrNotFound {
+			return nil, err
+		}
+		exist[i] = err == nil
+	}
+	return exist, nil
+}
+
+// Walk calls fn for every link in the durable store
+func (s *TieredStore) Walk(ctx context.Context, fn func(SearchResult) error) error {
+	return s.durable.Walk(ctx, fn)
+}
+
+// SavePreview caches the preview of a record's destination in both tiers.
+// The preview is discarded if the record was deleted or now points elsewhere.
+func (s *TieredStore) SavePreview(ctx context.Context, key, url string, p *Preview) error {
+	rec, err := s.durable.GetRecord(ctx, key)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rec.URL != url {
+		return nil
+	}
+
+	rec.Preview = p
+	if err := s.durable.Update(ctx, key, rec); err != nil && err != ErrNotFound {
+		return err
+	}
+	if err := s.cache.SavePreview(ctx, key, url, p); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// SaveCheck records the outcome of checking a record's destination in both
+// tiers. The outcome is discarded if the record was deleted or now points
+// elsewhere.
+func (s *TieredStore) SaveCheck(ctx context.Context, key, url string, check *DestinationCheck) error {
+	rec, err := s.durable.GetRecord(ctx, key)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if rec.URL != url {
+		return nil
+	}
+
+	rec.Check = check
+	if err := s.durable.Update(ctx, key, rec); err != nil && err != ErrNotFound {
+		return err
+	}
+	if err := s.cache.SaveCheck(ctx, key, url, check); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// fill caches a record read from or written to the durable store. Caching
+// is best effort; a copy cached concurrently is kept.
+func (s *TieredStore) fill(ctx context.Context, key string, rec *Record) {
+	s.cache.Create(ctx, key, rec)
+}
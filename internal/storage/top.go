@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// topLinksKeyPrefix prefixes the sorted set of clicks per key for each hour
+	topLinksKeyPrefix = "top:hour:"
+
+	// topClicksKeyPrefix prefixes the counter of all clicks for each hour
+	topClicksKeyPrefix = "top:clicks:"
+
+	// topUnionKey holds the union of the hours in a window for the duration
+	// of a transaction, so concurrent queries never see each other's
+	topUnionKey = "top:union"
+
+	// MaxTopWindow is the longest window top links are kept for
+	MaxTopWindow = 7 * 24 * time.Hour
+)
+
+// ErrInvalidWindow is returned for top link windows outside 1h to MaxTopWindow
+var ErrInvalidWindow = errors.New("window must be between 1h and 168h")
+
+// TopLink is a key with its clicks in a window
+type TopLink struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Clicks int64  `json:"clicks"`
+}
+
+// TopLinks are the most clicked keys of a window and the window's totals
+type TopLinks struct {
+	Since time.Time `json:"since"`
+
+	// Clicks counts every click in the window and ClickedLinks the keys
+	// clicked at least once
+	Clicks       int64 `json:"clicks"`
+	ClickedLinks int64 `json:"clicked_links"`
+
+	Links []TopLink `json:"links"`
+}
+
+// TopLinkStore represents the storage interface for the most clicked links
+type TopLinkStore interface {
+	TopLinks(ctx context.Context, window time.Duration, limit int) (*TopLinks, error)
+}
+
+// hourSuffix names the hourly bucket containing t
+func hourSuffix(t time.Time) string {
+	return t.UTC().Format("2006010215")
+}
+
+// countTopClick adds a click to the hourly top links and totals
+func countTopClick(ctx context.Context, pipe redis.Pipeliner, click analytics.Click) {
+	hour := hourSuffix(click.Time)
+	pipe.ZIncrBy(ctx, topLinksKeyPrefix+hour, 1, click.Key)
+	pipe.Expire(ctx, topLinksKeyPrefix+hour, MaxTopWindow+time.Hour)
+	pipe.Incr(ctx, topClicksKeyPrefix+hour)
+	pipe.Expire(ctx, topClicksKeyPrefix+hour, MaxTopWindow+time.Hour)
+}
+
+// TopLinks returns up to limit of the keys clicked most in the window
+// ending now, counted in whole hours, together with the window's totals.
+// Keys deleted since are left out of the ranking but not the totals.
+func (s *RedisStore) TopLinks(ctx context.Context, window time.Duration, limit int) (*TopLinks, error) {
+	if window < time.Hour || window > MaxTopWindow {
+		return nil, ErrInvalidWindow
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	now := time.Now().UTC()
+	hours := int((window + time.Hour - 1) / time.Hour)
+	since := now.Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	sets := make([]string, hours)
+	counters := make([]string, hours)
+	for i := range sets {
+		hour := hourSuffix(since.Add(time.Duration(i) * time.Hour))
+		sets[i] = topLinksKeyPrefix + hour
+		counters[i] = topClicksKeyPrefix + hour
+	}
+
+	var ranked *redis.ZSliceCmd
+	var clicked *redis.IntCmd
+	var totals *redis.SliceCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, topUnionKey, &redis.ZStore{Keys: sets})
+		ranked = pipe.ZRevRangeWithScores(ctx, topUnionKey, 0, int64(limit-1))
+		clicked = pipe.ZCard(ctx, topUnionKey)
+		pipe.Del(ctx, topUnionKey)
+		totals = pipe.MGet(ctx, counters...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	top := &TopLinks{Since: since, ClickedLinks: clicked.Val(), Links: []TopLink{}}
+	for _, v := range totals.Val() {
+		if value, ok := v.(string); ok {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			top.Clicks += n
+		}
+	}
+
+	scored := ranked.Val()
+	if limit <= 0 || len(scored) == 0 {
+		return top, nil
+	}
+	keys := make([]string, len(scored))
+	for i, z := range scored {
+		keys[i] = z.Member.(string)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			continue
+		}
+		rec, err := decodeRecord(value)
+		if err != nil {
+			continue
+		}
+		top.Links = append(top.Links, TopLink{Key: keys[i], URL: rec.URL, Clicks: int64(scored[i].Score)})
+	}
+	return top, nil
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// usageKeyPrefix prefixes the hash of each day's usage totals
+	usageKeyPrefix = "usage:"
+
+	// usagePendingKey accumulates usage not yet taken for metering, and
+	// usageFlushingKey holds the usage taken until it is acknowledged
+	usagePendingKey  = "usage:pending"
+	usageFlushingKey = "usage:flushing"
+
+	// usageDayFormat names the day a usage hash covers
+	usageDayFormat = "20060102"
+
+	// usageRetention is how long daily usage totals are kept
+	usageRetention = 400 * 24 * time.Hour
+)
+
+// Usage is the quantity of a metric a scope used during a day
+type Usage struct {
+	Day       time.Time `json:"day"`
+	Workspace string    `json:"workspace,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Metric    string    `json:"metric"`
+	Quantity  int64     `json:"quantity"`
+}
+
+// Scope returns the scope the usage is charged to
+func (u Usage) Scope() Scope {
+	return Scope{Workspace: u.Workspace, Owner: u.Owner}
+}
+
+// UsageStore represents the storage interface for usage metering. Usage is
+// added to daily totals and, when queued, to the usage awaiting metering,
+// which is taken and acknowledged once delivered.
+type UsageStore interface {
+	AddUsage(ctx context.Context, usage []Usage, queue bool) error
+	TakePendingUsage(ctx context.Context) ([]Usage, error)
+	AckPendingUsage(ctx context.Context) error
+	GetUsage(ctx context.Context, from, to time.Time) ([]Usage, error)
+}
+
+// takePendingUsageScript moves the pending usage aside for delivery, unless
+// usage taken earlier is still unacknowledged, and returns what was taken
+var takePendingUsageScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	if redis.call('EXISTS', KEYS[1]) == 0 then
+		return {}
+	end
+	redis.call('RENAME', KEYS[1], KEYS[2])
+end
+return redis.call('HGETALL', KEYS[2])
+`)
+
+// usageKey returns the hash of the usage totals of a day
+func usageKey(day time.Time) string {
+	return usageKeyPrefix + day.UTC().Format(usageDayFormat)
+}
+
+// usageField names a metric of a scope within a day's totals
+func usageField(metric string, scope Scope) string {
+	return metric + "|" + scope.prefix()
+}
+
+// parseUsageField parses a field named by usageField
+func parseUsageField(field string) (string, Scope, bool) {
+	metric, prefix, ok := strings.Cut(field, "|")
+	if !ok {
+		return "", Scope{}, false
+	}
+	if name, ok := strings.CutPrefix(prefix, workspaceKeyPrefix); ok {
+		return metric, Scope{Workspace: name}, true
+	}
+	if subject, ok := strings.CutPrefix(prefix, ownerKeyPrefix); ok {
+		return metric, Scope{Owner: subject}, true
+	}
+	return "", Scope{}, false
+}
+
+// AddUsage adds usage to the daily totals, and to the usage awaiting
+// metering if queue is set
+func (s *RedisStore) AddUsage(ctx context.Context, usage []Usage, queue bool) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	pipe := s.client.Pipeline()
+	for _, u := range usage {
+		key := usageKey(u.Day)
+		field := usageField(u.Metric, u.Scope())
+		pipe.HIncrBy(ctx, key, field, u.Quantity)
+		pipe.Expire(ctx, key, usageRetention)
+		if queue {
+			pipe.HIncrBy(ctx, usagePendingKey, u.Day.UTC().Format(usageDayFormat)+"|"+field, u.Quantity)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TakePendingUsage takes the usage awaiting metering. Until it is
+// acknowledged, the same usage is returned again, so delivery is at least
+// once; usage added meanwhile waits for the next take.
+func (s *RedisStore) TakePendingUsage(ctx context.Context) ([]Usage, error) {
+	values, err := takePendingUsageScript.Run(ctx, s.client, []string{usagePendingKey, usageFlushingKey}).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	var usage []Usage
+	for i := 0; i+1 < len(values); i += 2 {
+		day, field, ok := strings.Cut(values[i], "|")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(usageDayFormat, day)
+		if err != nil {
+			continue
+		}
+		if u, ok := newUsage(t, field, values[i+1]); ok {
+			usage = append(usage, u)
+		}
+	}
+	sortUsage(usage)
+	return usage, nil
+}
+
+// AckPendingUsage acknowledges the delivery of the usage last taken
+func (s *RedisStore) AckPendingUsage(ctx context.Context) error {
+	return s.client.Del(ctx, usageFlushingKey).Err()
+}
+
+// GetUsage returns the daily usage totals of every scope from the day of
+// from to the day of to, both included
+func (s *RedisStore) GetUsage(ctx context.Context, from, to time.Time) ([]Usage, error) {
+	var days []time.Time
+	for day := GranularityDay.Truncate(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(days))
+	for i, day := range days {
+		cmds[i] = pipe.HGetAll(ctx, usageKey(day))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	usage := []Usage{}
+	for i, cmd := range cmds {
+		for field, value := range cmd.Val() {
+			if u, ok := newUsage(days[i], field, value); ok {
+				usage = append(usage, u)
+			}
+		}
+	}
+	sortUsage(usage)
+	return usage, nil
+}
+
+// newUsage builds the usage of a day from a stored field and quantity
+func newUsage(day time.Time, field, value string) (Usage, bool) {
+	metric, scope, ok := parseUsageField(field)
+	if !ok {
+		return Usage{}, false
+	}
+	quantity, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return Usage{}, false
+	}
+	return Usage{Day: day, Workspace: scope.Workspace, Owner: scope.Owner, Metric: metric, Quantity: quantity}, true
+}
+
+// sortUsage orders usage by day, then workspace, owner and metric
+func sortUsage(usage []Usage) {
+	slices.SortFunc(usage, func(a, b Usage) int {
+		return cmp.Or(
+			a.Day.Compare(b.Day),
+			cmp.Compare(a.Workspace, b.Workspace),
+			cmp.Compare(a.Owner, b.Owner),
+			cmp.Compare(a.Metric, b.Metric),
+		)
+	})
+}
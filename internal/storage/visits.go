@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prayushdave/url-shortener/internal/analytics"
+)
+
+const (
+	// visitKeyPrefix prefixes the list of recent visits to a key, newest first
+	visitKeyPrefix = "visits:"
+
+	// DefaultVisitLimit is the default number of visits kept per key
+	DefaultVisitLimit = 1000
+
+	// DefaultVisitRetention is how long visits are kept by default after a
+	// key's last click
+	DefaultVisitRetention = 30 * 24 * time.Hour
+)
+
+// Visit is a single click with the visitor details it was recorded with.
+// IP is stored as the analytics pipeline passed it, truncated if IP
+// anonymization is enabled.
+type Visit struct {
+	Time      time.Time `json:"time"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Country   string    `json:"country,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	Variant   string    `json:"variant,omitempty"`
+}
+
+// VisitOptions sets how many visits are kept per key and for how long.
+// Zero values keep the defaults.
+type VisitOptions struct {
+	Limit     int
+	Retention time.Duration
+}
+
+// VisitStore represents the storage interface for per-visitor click data
+type VisitStore interface {
+	GetVisits(ctx context.Context, key string) ([]Visit, error)
+	AnonymizeVisits(ctx context.Context, key string) (int, error)
+	DeleteVisits(ctx context.Context, key string) (int, error)
+}
+
+// visitsKey returns the list holding a key's visits
+func visitsKey(key string) string {
+	return visitKeyPrefix + key
+}
+
+// EnableVisits records the visitor details of every click, keeping the
+// latest visits of each key as long as opts says
+func (s *RedisStore) EnableVisits(opts VisitOptions) {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultVisitLimit
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultVisitRetention
+	}
+	s.visits = &opts
+}
+
+// addVisit prepends a click to its key's visits, dropping the oldest ones
+// past the limit
+func addVisit(ctx context.Context, pipe redis.Pipeliner, click analytics.Click, opts *VisitOptions) {
+	data, err := json.Marshal(Visit{
+		Time:      click.Time.UTC(),
+		IP:        click.IP,
+		UserAgent: click.UserAgent,
+		Country:   click.Country,
+		Referrer:  click.Referrer,
+		Variant:   click.Variant,
+	})
+	if err != nil {
+		return
+	}
+	key := visitsKey(click.Key)
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, int64(opts.Limit-1))
+	pipe.Expire(ctx, key, opts.Retention)
+}
+
+// GetVisits returns the recorded visits to a key, newest first
+func (s *RedisStore) GetVisits(ctx context.Context, key string) ([]Visit, error) {
+	values, err := s.client.LRange(ctx, visitsKey(key), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeVisits(values), nil
+}
+
+// AnonymizeVisits strips the IP and user agent from a key's visits, keeping
+// when, where from and through which variant they happened. It returns the
+// number of visits anonymized.
+func (s *RedisStore) AnonymizeVisits(ctx context.Context, key string) (int, error) {
+	listKey := visitsKey(key)
+	n := 0
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		values, err := tx.LRange(ctx, listKey, 0, -1).Result()
+		if err != nil || len(values) == 0 {
+			return err
+		}
+		ttl, err := tx.PTTL(ctx, listKey).Result()
+		if err != nil {
+			return err
+		}
+
+		visits := decodeVisits(values)
+		anonymized := make([]interface{}, 0, len(visits))
+		n = 0
+		for _, v := range visits {
+			if v.IP != "" || v.UserAgent != "" {
+				n++
+			}
+			v.IP, v.UserAgent = "", ""
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			anonymized = append(anonymized, data)
+		}
+		if n == 0 {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, listKey)
+			pipe.RPush(ctx, listKey, anonymized...)
+			if ttl > 0 {
+				pipe.PExpire(ctx, listKey, ttl)
+			}
+			return nil
+		})
+		return err
+	}, listKey)
+	return n, err
+}
+
+// DeleteVisits removes every recorded visit to a key, returning how many
+// were removed
+func (s *RedisStore) DeleteVisits(ctx context.Context, key string) (int, error) {
+	var length *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.LLen(ctx, visitsKey(key))
+		pipe.Del(ctx, visitsKey(key))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(length.Val()), nil
+}
+
+// decodeVisits decodes stored visits, skipping malformed ones
+func decodeVisits(values []string) []Visit {
+	visits := make([]Visit, 0, len(values))
+	for _, value := range values {
+		var v Visit
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			visits = append(visits, v)
+		}
+	}
+	return visits
+}
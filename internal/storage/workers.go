@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// workerKeyPrefix prefixes the lease of each claimed worker ID
+	workerKeyPrefix = "worker:"
+
+	// DefaultWorkerLeaseTTL is how long a worker ID stays claimed without
+	// being renewed
+	DefaultWorkerLeaseTTL = 30 * time.Second
+)
+
+// ErrNoWorkerID is returned when every worker ID is claimed
+var ErrNoWorkerID = errors.New("every worker id is claimed")
+
+// renewWorkerScript extends a lease held by the token, taking it back if it
+// lapsed and nobody else claimed the ID meanwhile
+var renewWorkerScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder and holder ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// releaseWorkerScript frees a lease only if the token still holds it
+var releaseWorkerScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// WorkerLease is a worker ID claimed by one instance, so no two live
+// instances issue keys under the same ID
+type WorkerLease struct {
+	ID int
+
+	client *redis.Client
+	token  string
+	ttl    time.Duration
+}
+
+// ClaimWorkerID claims a free worker ID from 0 to maxID for ttl. IDs are
+// tried from a random offset so instances starting together rarely race.
+func (s *RedisStore) ClaimWorkerID(ctx context.Context, maxID int, ttl time.Duration) (*WorkerLease, error) {
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	offset, err := rand.Int(rand.Reader, big.NewInt(int64(maxID)+1))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= maxID; i++ {
+		id := (int(offset.Int64()) + i) % (maxID + 1)
+		ok, err := s.client.SetNX(ctx, workerKeyPrefix+strconv.Itoa(id), token, ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &WorkerLease{ID: id, client: s.client, token: token, ttl: ttl}, nil
+		}
+	}
+	return nil, ErrNoWorkerID
+}
+
+// Renew extends the lease. It returns false if another instance has
+// claimed the ID since the lease lapsed.
+func (l *WorkerLease) Renew(ctx context.Context) (bool, error) {
+	n, err := renewWorkerScript.Run(ctx, l.client, []string{l.key()}, l.token, l.ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release frees the ID for other instances
+func (l *WorkerLease) Release(ctx context.Context) error {
+	return releaseWorkerScript.Run(ctx, l.client, []string{l.key()}, l.token).Err()
+}
+
+// Run renews the lease every third of its TTL until ctx is done, then
+// releases it
+func (l *WorkerLease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := l.Release(releaseCtx); err != nil {
+				log.Printf("failed to release worker id %d: %v", l.ID, err)
+			}
+			return
+		case <-ticker.C:
+			ok, err := l.Renew(ctx)
+			if err != nil {
+				log.Printf("failed to renew worker id %d: %v", l.ID, err)
+			} else if !ok {
+				log.Printf("worker id %d was claimed by another instance; keys may collide", l.ID)
+			}
+		}
+	}
+}
+
+// key names the lease's Redis key
+func (l *WorkerLease) key() string {
+	return workerKeyPrefix + strconv.Itoa(l.ID)
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// workspaceKeyPrefix prefixes the keys holding each workspace's indexes
+	workspaceKeyPrefix = "workspace:"
+
+	// workspaceLinksSuffix names the set of keys owned by a workspace
+	workspaceLinksSuffix = ":links"
+
+	// workspaceClicksSuffix names the hash of a workspace's click counters
+	workspaceClicksSuffix = ":clicks"
+)
+
+// WorkspaceStats are the aggregate counters of a workspace
+type WorkspaceStats struct {
+	Workspace string `json:"workspace"`
+	Links     int64  `json:"links"`
+	Clicks    int64  `json:"clicks"`
+}
+
+// WorkspaceStore represents the storage interface for workspace aggregates
+type WorkspaceStore interface {
+	GetWorkspaceStats(ctx context.Context, workspace string) (*WorkspaceStats, error)
+}
+
+// GetWorkspaceStats returns the number of live links and the total clicks of
+// a workspace. Links that have expired are pruned from the workspace index.
+func (s *RedisStore) GetWorkspaceStats(ctx context.Context, workspace string) (*WorkspaceStats, error) {
+	links, err := s.countLive(ctx, workspaceLinksKey(workspace))
+	if err != nil {
+		return nil, err
+	}
+	stats := &WorkspaceStats{Workspace: workspace, Links: links}
+
+	clicks, err := s.client.HGet(ctx, workspaceClicksKey(workspace), "clicks").Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	stats.Clicks, _ = strconv.ParseInt(clicks, 10, 64)
+	return stats, nil
+}
+
+// countLive returns the number of keys in an index that still exist,
+// pruning those that have expired
+func (s *RedisStore) countLive(ctx context.Context, index string) (int64, error) {
+	keys, err := s.liveMembers(ctx, index)
+	return int64(len(keys)), err
+}
+
+// liveMembers returns the keys in an index that still exist, pruning those
+// that have expired
+func (s *RedisStore) liveMembers(ctx context.Context, index string) ([]string, error) {
+	keys, err := s.client.SMembers(ctx, index).Result()
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Exists(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	live := keys[:0]
+	var stale []interface{}
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			stale = append(stale, keys[i])
+			continue
+		}
+		live = append(live, keys[i])
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, index, stale...)
+	}
+	return live, nil
+}
+
+// workspaceLinksKey returns the key of the set of keys owned by a workspace
+func workspaceLinksKey(workspace string) string {
+	return Scope{Workspace: workspace}.linksKey()
+}
+
+// workspaceClicksKey returns the key of the click counters of a workspace
+func workspaceClicksKey(workspace string) string {
+	return workspaceKeyPrefix + workspace + workspaceClicksSuffix
+}
@@ -0,0 +1,118 @@
+// Package upgrade checks whether plain-HTTP destinations are also served
+// over HTTPS, so they can be stored with the secure scheme
+package upgrade
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prayushdave/url-shortener/internal/netguard"
+)
+
+const (
+	// DefaultTimeout is the default time allowed for each check
+	DefaultTimeout = 5 * time.Second
+
+	// userAgent identifies the checker to destinations
+	userAgent = "url-shortener-upgrader/1.0"
+)
+
+// Checker upgrades http:// URLs to https:// once their host is found to
+// serve them securely
+type Checker struct {
+	client *http.Client
+}
+
+// NewChecker creates a Checker allowing timeout for each check. It only
+// connects to public addresses, so whether a check succeeds can't reveal
+// internal services.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{client: &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: timeout,
+				Control: netguard.Control,
+			}).DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// IsPlainHTTP reports whether a URL uses the http scheme
+func IsPlainHTTP(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(u.Scheme, "http")
+}
+
+// Secure returns the https:// form of an http:// URL, dropping the
+// default HTTP port. It reports false for other URLs.
+func Secure(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "http") || u.Host == "" {
+		return "", false
+	}
+	rest := rawURL[len(u.Scheme)+len("://"):]
+	if u.Port() == "80" {
+		// Only the host's port is dropped, so the rest of the URL keeps its
+		// encoding
+		authority := rest
+		if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+			authority = rest[:i]
+		}
+		host := u.Hostname()
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		userinfo := ""
+		if i := strings.LastIndex(authority, "@"); i >= 0 {
+			userinfo = authority[:i+1]
+		}
+		rest = userinfo + host + rest[len(authority):]
+	}
+	return "https://" + rest, true
+}
+
+// Upgrade returns the https:// form of an http:// URL if its host answers
+// a HEAD request for it over HTTPS, without redirecting back to plain HTTP.
+// Otherwise, and for URLs that aren't http://, rawURL is returned and false
+// reported.
+func (c *Checker) Upgrade(ctx context.Context, rawURL string) (string, bool) {
+	secure, ok := Secure(rawURL)
+	if !ok {
+		return rawURL, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, secure, nil)
+	if err != nil {
+		return rawURL, false
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// Certificate errors, refused connections and timeouts all mean the
+		// host doesn't serve HTTPS usably
+		return rawURL, false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return rawURL, false
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		if next, err := resp.Request.URL.Parse(location); err == nil && next.Scheme == "http" {
+			return rawURL, false
+		}
+	}
+	return secure, true
+}
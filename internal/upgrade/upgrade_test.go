@@ -0,0 +1,73 @@
+package upgrade
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecure(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"http://example.com/a%2Fb?q=1", "https://example.com/a%2Fb?q=1", true},
+		{"HTTP://example.com", "https://example.com", true},
+		{"http://example.com:80/path", "https://example.com/path", true},
+		{"http://user@[::1]:80/", "https://user@[::1]/", true},
+		{"http://example.com:8080/", "https://example.com:8080/", true},
+		{"https://example.com/", "", false},
+		{"ftp://example.com/", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Secure(tt.in)
+		assert.Equal(t, tt.ok, ok, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
+
+func TestChecker_Upgrade(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/insecure":
+			http.Redirect(w, req, "http://example.com/insecure", http.StatusMovedPermanently)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	c := NewChecker(0)
+	c.client.Transport = server.Client().Transport
+	plain := "http://" + strings.TrimPrefix(server.URL, "https://")
+
+	got, ok := c.Upgrade(context.Background(), plain+"/page?q=1")
+	assert.True(t, ok)
+	assert.Equal(t, server.URL+"/page?q=1", got)
+
+	// Redirects back to plain HTTP and server errors don't count
+	for _, path := range []string{"/insecure", "/broken"} {
+		got, ok = c.Upgrade(context.Background(), plain+path)
+		assert.False(t, ok)
+		assert.Equal(t, plain+path, got)
+	}
+
+	// Internal hosts aren't checked, even with a trusted certificate, so
+	// checks can't probe them
+	internal := NewChecker(0)
+	internal.client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	got, ok = internal.Upgrade(context.Background(), plain+"/page")
+	assert.False(t, ok)
+	assert.Equal(t, plain+"/page", got)
+
+	got, ok = c.Upgrade(context.Background(), server.URL)
+	assert.False(t, ok)
+	assert.Equal(t, server.URL, got)
+}
@@ -0,0 +1,210 @@
+package urlutil
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// idnProfile converts internationalized host names. Unlike idna.Lookup it
+// allows underscores, which real hosts use even though DNS names may not.
+var idnProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule())
+
+// Canonical returns the form of rawURL to store and redirect to: a host
+// with non-ASCII characters is converted to punycode, and non-ASCII
+// characters elsewhere are percent-encoded as UTF-8. Everything else is
+// left as given, so ASCII URLs come back unchanged.
+func Canonical(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", ErrNotAbsolute
+	}
+
+	host := u.Hostname()
+	if !isASCII(host) {
+		if host, err = idnProfile.ToASCII(host); err != nil {
+			return "", err
+		}
+	}
+	return rebuild(rawURL, u, host, escapeNonASCII), nil
+}
+
+// Display returns the form of a canonical URL to show people: punycode
+// labels are converted back to Unicode, and percent-encoded UTF-8 outside
+// the host is decoded. Labels mixing scripts stay in punycode, so a
+// lookalike such as a Cyrillic "а" in "аpple" can't pass for the real
+// host. Encoded ASCII is left alone, so reserved characters keep their
+// meaning. URLs that can't be parsed are returned unchanged.
+func Display(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	host := u.Hostname()
+	if strings.Contains(strings.ToLower(host), "xn--") {
+		labels := strings.Split(host, ".")
+		for i, label := range labels {
+			if !strings.HasPrefix(strings.ToLower(label), "xn--") {
+				continue
+			}
+			if decoded, err := idnProfile.ToUnicode(label); err == nil && !mixesScripts(decoded) {
+				labels[i] = decoded
+			}
+		}
+		host = strings.Join(labels, ".")
+	}
+	return rebuild(rawURL, u, host, decodeNonASCII)
+}
+
+// cjkScripts are written together in Chinese, Japanese and Korean, so
+// labels mixing only these are not suspicious
+var cjkScripts = []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo}
+
+// mixesScripts reports whether the letters of a host label come from more
+// than one script. Digits, hyphens and other characters shared by every
+// script don't count.
+func mixesScripts(label string) bool {
+	seen := ""
+	for _, r := range label {
+		script := scriptOf(r)
+		if script == "" {
+			continue
+		}
+		if seen != "" && script != seen {
+			return true
+		}
+		seen = script
+	}
+	return false
+}
+
+// scriptOf names the script of r, "CJK" for any of cjkScripts, or returns
+// "" for characters shared by every script
+func scriptOf(r rune) string {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return ""
+	}
+	if unicode.In(r, cjkScripts...) {
+		return "CJK"
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// rebuild reassembles rawURL, parsed as u, around a new host name, passing
+// the userinfo and everything after the host through transform
+func rebuild(rawURL string, u *url.URL, host string, transform func(string) string) string {
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	// Parse found "scheme://", so the authority starts after it and ends at
+	// the first of "/?#"
+	authority := rawURL[len(u.Scheme)+len("://"):]
+	rest := ""
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority, rest = authority[:i], authority[i:]
+	}
+	userinfo := ""
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userinfo = transform(authority[:i+1])
+	}
+	return u.Scheme + "://" + userinfo + host + transform(rest)
+}
+
+// isASCII reports whether s has only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeNonASCII percent-encodes the bytes of non-ASCII characters in s
+func escapeNonASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// decodeNonASCII decodes the runs of percent-encoded bytes in s that spell
+// non-ASCII UTF-8 characters, leaving other escapes encoded
+func decodeNonASCII(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		// Collect a run of escaped bytes of non-ASCII characters
+		var run []byte
+		j := i
+		for j+2 < len(s) && s[j] == '%' {
+			c, ok := unhex(s[j+1], s[j+2])
+			if !ok || c < utf8.RuneSelf {
+				break
+			}
+			run = append(run, c)
+			j += 3
+		}
+		if len(run) == 0 {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		if utf8.Valid(run) {
+			b.Write(run)
+		} else {
+			b.WriteString(s[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// unhex decodes the two hex digits of a percent-encoded byte
+func unhex(hi, lo byte) (byte, bool) {
+	h, ok1 := hexValue(hi)
+	l, ok2 := hexValue(lo)
+	return h<<4 | l, ok1 && ok2
+}
+
+// hexValue is the value of a hex digit
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package urlutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii unchanged", "https://Example.com/a%2Fb?q=1#top", "https://Example.com/a%2Fb?q=1#top"},
+		{"idn host", "https://bücher.example/", "https://xn--bcher-kva.example/"},
+		{"idn host mapped", "https://BÜCHER.example", "https://xn--bcher-kva.example"},
+		{"punycode host", "https://xn--bcher-kva.example/", "https://xn--bcher-kva.example/"},
+		{"unicode path and query", "https://example.com/café?q=naïve#ñ", "https://example.com/caf%C3%A9?q=na%C3%AFve#%C3%B1"},
+		{"port and userinfo", "http://user:pw@münchen.example:8080/straße", "http://user:pw@xn--mnchen-3ya.example:8080/stra%C3%9Fe"},
+		{"underscore host", "https://my_host.example.com/", "https://my_host.example.com/"},
+		{"ipv6", "http://[::1]:8080/ü", "http://[::1]:8080/%C3%BC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonical(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// Canonical forms are stable
+			again, err := Canonical(got)
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+
+	_, err := Canonical("/relative")
+	assert.ErrorIs(t, err, ErrNotAbsolute)
+}
+
+func TestDisplay(t *testing.T) {
+	assert.Equal(t, "https://bücher.example/caf%C3", Display("https://xn--bcher-kva.example/caf%C3"))
+	assert.Equal(t, "https://bücher.example/café?q=a%26b", Display("https://xn--bcher-kva.example/caf%C3%A9?q=a%26b"))
+	assert.Equal(t, "http://user:pw@münchen.example:8080/straße", Display("http://user:pw@xn--mnchen-3ya.example:8080/stra%C3%9Fe"))
+	assert.Equal(t, "https://example.com/", Display("https://example.com/"))
+	assert.Equal(t, "not a url", Display("not a url"))
+
+	// Labels mixing scripts stay in punycode, others in the host are decoded
+	assert.Equal(t, "https://xn--pple-43d.com/", Display("https://xn--pple-43d.com/"))
+	assert.Equal(t, "https://xn--pple-43d.bücher.example/", Display("https://xn--pple-43d.xn--bcher-kva.example/"))
+	assert.Equal(t, "https://日本語ひらがな.jp/", Display("https://xn--v8j0cwa6g1563acvb2w6i.jp/"))
+}
@@ -0,0 +1,50 @@
+package urlutil
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrNotAbsolute is returned when a URL has no scheme or host
+var ErrNotAbsolute = errors.New("url must be absolute")
+
+// defaultPorts maps schemes to the port implied when none is given
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalize returns a canonical form of rawURL so equivalent URLs compare
+// equal: the scheme and host are lowercased, default ports and empty query
+// strings are dropped, an empty path becomes "/" and query parameters are
+// sorted by name. The path and fragment are otherwise left untouched.
+func Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", ErrNotAbsolute
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
@@ -0,0 +1,62 @@
+package urlutil
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ExpandTemplate replaces {name} placeholders in s with values from vars.
+// Unknown placeholders are left untouched.
+func ExpandTemplate(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// MergeQuery adds params to the query string of rawURL. Parameters already
+// present in the URL keep their position and encoding unless params sets the
+// same name, in which case the new value replaces them. Added parameters are
+// appended in name order so the result is deterministic. The fragment is kept.
+func MergeQuery(rawURL string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return rawURL, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	if u.RawQuery != "" {
+		for _, part := range strings.Split(u.RawQuery, "&") {
+			name, _, _ := strings.Cut(part, "=")
+			if decoded, err := url.QueryUnescape(name); err == nil {
+				name = decoded
+			}
+			if _, overridden := params[name]; overridden {
+				continue
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, url.QueryEscape(name)+"="+url.QueryEscape(params[name]))
+	}
+
+	u.RawQuery = strings.Join(parts, "&")
+	return u.String(), nil
+}
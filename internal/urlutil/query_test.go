@@ -0,0 +1,100 @@
+package urlutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string]string{"key": "aB1cD2eF", "domain": "go.example.com"}
+
+	assert.Equal(t, "link-aB1cD2eF", ExpandTemplate("link-{key}", vars))
+	assert.Equal(t, "go.example.com/aB1cD2eF", ExpandTemplate("{domain}/{key}", vars))
+	assert.Equal(t, "{unknown}", ExpandTemplate("{unknown}", vars))
+	assert.Equal(t, "plain", ExpandTemplate("plain", vars))
+}
+
+func TestMergeQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		params map[string]string
+		want   string
+	}{
+		{
+			name:   "No params",
+			url:    "https://example.com/path?a=1",
+			params: nil,
+			want:   "https://example.com/path?a=1",
+		},
+		{
+			name:   "No existing query",
+			url:    "https://example.com/path",
+			params: map[string]string{"utm_source": "newsletter", "utm_medium": "email"},
+			want:   "https://example.com/path?utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name:   "Existing query is kept",
+			url:    "https://example.com/path?b=2&a=1",
+			params: map[string]string{"utm_source": "newsletter"},
+			want:   "https://example.com/path?b=2&a=1&utm_source=newsletter",
+		},
+		{
+			name:   "Existing parameter is replaced",
+			url:    "https://example.com/path?utm_source=old&a=1&utm_source=older",
+			params: map[string]string{"utm_source": "new"},
+			want:   "https://example.com/path?a=1&utm_source=new",
+		},
+		{
+			name:   "Values are escaped",
+			url:    "https://example.com/",
+			params: map[string]string{"utm_campaign": "summer sale&more"},
+			want:   "https://example.com/?utm_campaign=summer+sale%26more",
+		},
+		{
+			name:   "Fragment is kept",
+			url:    "https://example.com/page?x=1#section",
+			params: map[string]string{"ref": "short"},
+			want:   "https://example.com/page?x=1&ref=short#section",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeQuery(tt.url, tt.params)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Unchanged", input: "https://example.com/path?a=1", expected: "https://example.com/path?a=1"},
+		{name: "Case", input: "HTTPS://Example.COM/Path", expected: "https://example.com/Path"},
+		{name: "Empty path", input: "https://example.com", expected: "https://example.com/"},
+		{name: "Default port", input: "http://example.com:80/", expected: "http://example.com/"},
+		{name: "Other port", input: "https://example.com:8443/", expected: "https://example.com:8443/"},
+		{name: "IPv6", input: "http://[::1]:80/", expected: "http://[::1]/"},
+		{name: "Query order", input: "https://example.com/?b=2&a=1", expected: "https://example.com/?a=1&b=2"},
+		{name: "Empty query", input: "https://example.com/?", expected: "https://example.com/"},
+		{name: "Fragment", input: "https://example.com/#top", expected: "https://example.com/#top"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+
+	_, err := Normalize("/relative")
+	assert.Equal(t, ErrNotAbsolute, err)
+}
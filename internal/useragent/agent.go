@@ -0,0 +1,92 @@
+package useragent
+
+import "strings"
+
+// Device types clicks are counted under
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// Agent is what a User-Agent header says about a visitor's device. OS
+// names iOS and Android like Platform does, so device routing can be
+// reported on.
+type Agent struct {
+	Device  string
+	OS      string
+	Browser string
+}
+
+// osMarkers map User-Agent markers to operating systems, most specific first
+var osMarkers = []struct{ marker, os string }{
+	{"iPhone", "ios"},
+	{"iPad", "ios"},
+	{"iPod", "ios"},
+	{"Android", "android"},
+	{"CrOS", "chromeos"},
+	{"Windows", "windows"},
+	{"Macintosh", "macos"},
+	{"Mac OS X", "macos"},
+	{"Linux", "linux"},
+}
+
+// browserMarkers map User-Agent markers to browsers, most specific first:
+// in-app browsers and Chromium derivatives also claim to be Chrome and
+// Safari
+var browserMarkers = []struct{ marker, browser string }{
+	{"FBAN/", "facebook"},
+	{"FBAV/", "facebook"},
+	{"Instagram", "instagram"},
+	{"LinkedInApp", "linkedin"},
+	{"Edg/", "edge"},
+	{"EdgA/", "edge"},
+	{"EdgiOS/", "edge"},
+	{"OPR/", "opera"},
+	{"Opera", "opera"},
+	{"SamsungBrowser/", "samsung"},
+	{"Firefox/", "firefox"},
+	{"FxiOS/", "firefox"},
+	{"CriOS/", "chrome"},
+	{"Chrome/", "chrome"},
+	{"Safari/", "safari"},
+}
+
+// Parse returns the device type, operating system and browser of a
+// User-Agent header. Unrecognized values are "other"; an empty header
+// yields an empty Agent.
+func Parse(ua string) Agent {
+	if strings.TrimSpace(ua) == "" {
+		return Agent{}
+	}
+	a := Agent{OS: "other", Browser: "other"}
+	for _, m := range osMarkers {
+		if strings.Contains(ua, m.marker) {
+			a.OS = m.os
+			break
+		}
+	}
+	for _, m := range browserMarkers {
+		if strings.Contains(ua, m.marker) {
+			a.Browser = m.browser
+			break
+		}
+	}
+
+	lower := strings.ToLower(ua)
+	switch {
+	case strings.Contains(lower, "bot"), strings.Contains(lower, "crawler"), strings.Contains(lower, "spider"):
+		a.Device = DeviceBot
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"), a.OS == "android" && !strings.Contains(ua, "Mobile"):
+		a.Device = DeviceTablet
+	case strings.Contains(ua, "Mobi"), a.OS == "ios", a.OS == "android":
+		a.Device = DeviceMobile
+	case a.OS == "windows", a.OS == "macos", a.OS == "linux", a.OS == "chromeos":
+		a.Device = DeviceDesktop
+	default:
+		a.Device = DeviceOther
+	}
+	return a
+}
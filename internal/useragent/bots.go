@@ -0,0 +1,103 @@
+package useragent
+
+import "strings"
+
+// knownBots are lowercase User-Agent fragments of crawlers, link-preview
+// fetchers and scripted clients
+var knownBots = []string{
+	"bot",
+	"crawler",
+	"spider",
+	"slurp",
+	"facebookexternalhit",
+	"facebookcatalog",
+	"whatsapp",
+	"skypeuripreview",
+	"embedly",
+	"pinterest",
+	"vkshare",
+	"quora link preview",
+	"bitlybot",
+	"preview",
+	"headlesschrome",
+	"lighthouse",
+	"curl/",
+	"wget/",
+	"python-requests",
+	"go-http-client",
+	"okhttp",
+}
+
+// socialPreviewBots are lowercase User-Agent fragments of the fetchers social
+// networks and messengers use to unfurl shared links
+var socialPreviewBots = []string{
+	"facebookexternalhit",
+	"facebookcatalog",
+	"twitterbot",
+	"linkedinbot",
+	"slackbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"pinterest",
+	"redditbot",
+	"embedly",
+	"vkshare",
+	"mastodon",
+}
+
+// IsSocialPreview reports whether a User-Agent header belongs to a social
+// network or messenger fetching a link preview
+func IsSocialPreview(ua string) bool {
+	ua = strings.ToLower(ua)
+	for _, p := range socialPreviewBots {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// BotDetector recognizes bots by their User-Agent header
+type BotDetector struct {
+	patterns []string
+}
+
+// NewBotDetector creates a BotDetector matching the built-in list of known
+// bots plus any extra User-Agent fragments
+func NewBotDetector(extra ...string) *BotDetector {
+	patterns := append([]string(nil), knownBots...)
+	for _, p := range extra {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return &BotDetector{patterns: patterns}
+}
+
+// IsBot reports whether a User-Agent header belongs to a bot. Requests
+// without a User-Agent are treated as bots since browsers always send one.
+func (d *BotDetector) IsBot(ua string) bool {
+	ua = strings.ToLower(strings.TrimSpace(ua))
+	if ua == "" {
+		return true
+	}
+	for _, p := range d.patterns {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePatterns parses a comma-separated list of User-Agent fragments
+func ParsePatterns(spec string) []string {
+	var patterns []string
+	for _, p := range strings.Split(spec, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
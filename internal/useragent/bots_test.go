@@ -0,0 +1,46 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotDetector_IsBot(t *testing.T) {
+	d := NewBotDetector("MonitoringAgent")
+
+	tests := []struct {
+		name string
+		ua   string
+		want bool
+	}{
+		{name: "Googlebot", ua: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: true},
+		{name: "Facebook preview", ua: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", want: true},
+		{name: "Slack preview", ua: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", want: true},
+		{name: "WhatsApp", ua: "WhatsApp/2.23.20.0 A", want: true},
+		{name: "curl", ua: "curl/8.4.0", want: true},
+		{name: "Empty", ua: "", want: true},
+		{name: "Extra pattern", ua: "monitoringagent/1.0", want: true},
+		{name: "Chrome", ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", want: false},
+		{name: "iPhone", ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, d.IsBot(tt.ua))
+		})
+	}
+}
+
+func TestIsSocialPreview(t *testing.T) {
+	assert.True(t, IsSocialPreview("facebookexternalhit/1.1"))
+	assert.True(t, IsSocialPreview("Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)"))
+	assert.True(t, IsSocialPreview("Twitterbot/1.0"))
+	assert.False(t, IsSocialPreview("Mozilla/5.0 (compatible; Googlebot/2.1)"))
+	assert.False(t, IsSocialPreview("Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0"))
+}
+
+func TestParsePatterns(t *testing.T) {
+	assert.Equal(t, []string{"agent", "probe"}, ParsePatterns(" agent, ,probe,"))
+	assert.Nil(t, ParsePatterns(""))
+}
@@ -0,0 +1,50 @@
+package useragent
+
+import "strings"
+
+// Platform is the device family a request comes from
+type Platform string
+
+// Supported platforms
+const (
+	IOS     Platform = "ios"
+	Android Platform = "android"
+	Mobile  Platform = "mobile"
+	Desktop Platform = "desktop"
+)
+
+// Platforms lists every platform a routing rule may target
+var Platforms = []Platform{IOS, Android, Mobile, Desktop}
+
+// Detect returns the platform of a User-Agent header. Requests without
+// a recognizable mobile marker are treated as desktop.
+func Detect(ua string) Platform {
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return IOS
+	case strings.Contains(ua, "Android"):
+		return Android
+	case strings.Contains(ua, "Mobi"):
+		return Mobile
+	}
+	return Desktop
+}
+
+// Matches reports whether a request from platform p satisfies a rule
+// targeting want. Rules for "mobile" match every mobile platform.
+func (p Platform) Matches(want Platform) bool {
+	if p == want {
+		return true
+	}
+	return want == Mobile && (p == IOS || p == Android)
+}
+
+// Valid reports whether p is a known platform
+func (p Platform) Valid() bool {
+	for _, known := range Platforms {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Platform
+	}{
+		{name: "iPhone", ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", want: IOS},
+		{name: "iPad", ua: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15", want: IOS},
+		{name: "Android", ua: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", want: Android},
+		{name: "Other mobile", ua: "Mozilla/5.0 (Mobile; rv:48.0) Gecko/48.0 Firefox/48.0 KAIOS/2.5", want: Mobile},
+		{name: "Desktop", ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", want: Desktop},
+		{name: "Empty", ua: "", want: Desktop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.ua))
+		})
+	}
+}
+
+func TestPlatform_Matches(t *testing.T) {
+	assert.True(t, IOS.Matches(IOS))
+	assert.True(t, IOS.Matches(Mobile))
+	assert.True(t, Android.Matches(Mobile))
+	assert.True(t, Mobile.Matches(Mobile))
+	assert.False(t, Desktop.Matches(Mobile))
+	assert.False(t, Android.Matches(IOS))
+}
+
+func TestPlatform_Valid(t *testing.T) {
+	assert.True(t, Desktop.Valid())
+	assert.False(t, Platform("toaster").Valid())
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Agent
+	}{
+		{name: "iPhone Safari", ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", want: Agent{"mobile", "ios", "safari"}},
+		{name: "iPad Chrome", ua: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0 Mobile/15E148 Safari/604.1", want: Agent{"tablet", "ios", "chrome"}},
+		{name: "Android phone", ua: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", want: Agent{"mobile", "android", "chrome"}},
+		{name: "Android tablet", ua: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0 Safari/537.36", want: Agent{"tablet", "android", "samsung"}},
+		{name: "Instagram in-app", ua: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 307.0.0.34.111", want: Agent{"mobile", "ios", "instagram"}},
+		{name: "Windows Edge", ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0", want: Agent{"desktop", "windows", "edge"}},
+		{name: "macOS Firefox", ua: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.1; rv:120.0) Gecko/20100101 Firefox/120.0", want: Agent{"desktop", "macos", "firefox"}},
+		{name: "Googlebot", ua: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: Agent{"bot", "other", "other"}},
+		{name: "curl", ua: "curl/8.4.0", want: Agent{"other", "other", "other"}},
+		{name: "Empty", ua: "", want: Agent{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Parse(tt.ua))
+		})
+	}
+}
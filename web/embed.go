@@ -0,0 +1,22 @@
+//go:build webui
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist holds the dashboard as built by npm run build
+//
+//go:embed all:dist
+var dist embed.FS
+
+// FS returns the built dashboard, with index.html at its root
+func FS() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
@@ -0,0 +1,13 @@
+//go:build !webui
+
+// Package web embeds the built dashboard into the API server. Building with
+// the webui tag after npm run build includes it; otherwise FS returns nil
+// and the dashboard is deployed on its own.
+package web
+
+import "io/fs"
+
+// FS returns the built dashboard, or nil when it wasn't embedded
+func FS() fs.FS {
+	return nil
+}
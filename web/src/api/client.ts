@@ -1,9 +1,15 @@
+// An empty VITE_API_BASE_URL calls the API on the dashboard's own origin,
+// as when the API server serves it
 const API_BASE_URL =
-  import.meta.env.VITE_API_BASE_URL || "http://localhost:8080";
+  import.meta.env.VITE_API_BASE_URL ?? "http://localhost:8080";
 
 export interface CreateUrlResponse {
   short_key: string;
+  short_url: string;
   url: string;
+  created_at: string;
+  expires_at: string | null;
+  unverified?: boolean;
 }
 
 export interface UiUrlResponse {
@@ -13,13 +19,23 @@ export interface UiUrlResponse {
   createdAt: string;
 }
 
-export async function createShortUrl(longUrl: string): Promise<UiUrlResponse> {
+// captchaToken is required when the server sets CAPTCHA_PROVIDER, and
+// email when it sets EMAIL_VERIFICATION
+export async function createShortUrl(
+  longUrl: string,
+  captchaToken?: string,
+  email?: string
+): Promise<UiUrlResponse> {
   const response = await fetch(`${API_BASE_URL}/api/v1/urls`, {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
     },
-    body: JSON.stringify({ url: longUrl }),
+    body: JSON.stringify({
+      url: longUrl,
+      captcha_token: captchaToken,
+      email,
+    }),
   });
 
   if (!response.ok) {
@@ -31,9 +47,9 @@ export async function createShortUrl(longUrl: string): Promise<UiUrlResponse> {
   // Transform API response to UI format
   return {
     key: apiResponse.short_key,
-    shortUrl: `${API_BASE_URL}/${apiResponse.short_key}`,
+    shortUrl: apiResponse.short_url,
     originalUrl: apiResponse.url,
-    createdAt: new Date().toISOString(),
+    createdAt: apiResponse.created_at,
   };
 }
 
@@ -42,7 +58,8 @@ export async function deleteUrl(key: string): Promise<void> {
     method: "DELETE",
   });
 
-  if (!response.ok && response.status !== 204) {
+  // A link that is already gone counts as deleted
+  if (!response.ok && response.status !== 404) {
     throw new Error("Failed to delete URL");
   }
 }